@@ -0,0 +1,68 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// tokenAudienceField resource servers(RFC 8707 resource indicators) of the basic, access and refresh documents,
+// absent for tokens without audience
+const tokenAudienceField = "aud"
+
+// audienceTokenInfo token information restricted to resource servers
+type audienceTokenInfo interface {
+	GetAudience() []string
+}
+
+type audienceKey struct{}
+
+// ContextWithAudience attach the audience of the tokens to create to ctx, for token information not implementing
+// GetAudience() []string
+func ContextWithAudience(ctx context.Context, audience ...string) context.Context {
+	return context.WithValue(ctx, audienceKey{}, audience)
+}
+
+// tokenAudience the audience of the token information, else the one attached to ctx
+func tokenAudience(ctx context.Context, info oauth2.TokenInfo) []string {
+	if ai, ok := info.(audienceTokenInfo); ok && len(ai.GetAudience()) > 0 {
+		return ai.GetAudience()
+	}
+
+	aud, _ := ctx.Value(audienceKey{}).([]string)
+	return aud
+}
+
+// RemoveAllByAudience delete the codes and tokens issued for the resource server aud, e.g. a decommissioned API,
+// returning the number of deleted documents. Tokens without audience are kept.
+func (ts *TokenStore) RemoveAllByAudience(ctx context.Context, aud string) (n int64, err error) {
+	o := ts.op("RemoveAllByAudience", ts.tcfg.BasicCName)
+	o.set("aud", aud)
+
+	err = ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("audience", aud); err != nil {
+			return err
+		}
+
+		return ts.dbHandler(ctx, func(ctx context.Context, d routedDB) error {
+			n = 0
+
+			for _, name := range ts.tokenCNames() {
+				res, err := d.Collection(name).DeleteMany(ctx, ts.withKeyPrefix(ts.withIssuer(ctx, bson.M{tokenAudienceField: aud})))
+
+				if err != nil {
+					return err
+				}
+
+				n += res.DeletedCount
+			}
+
+			o.set("deleted", n)
+
+			return nil
+		})
+	})
+
+	return
+}
@@ -0,0 +1,126 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// audienceToken token information restricted to resource servers
+type audienceToken struct {
+	*models.Token
+	audience []string
+}
+
+func (t audienceToken) GetAudience() []string {
+	return t.audience
+}
+
+func TestTokenAudience(t *testing.T) {
+	ts := newTestTokenStore(t)
+	ctx := context.Background()
+	api := ContextWithAudience(ctx, "https://api.example.com")
+
+	for _, kind := range []CollectionKind{CollectionBasic, CollectionAccess, CollectionRefresh} {
+		if names := tokenIndexNames(t, ts, kind); !hasIndex(names, tokenAudienceField+"_1") {
+			t.Fatalf("indexes of %s %s", ts.Collection(kind).Name(), strings.Join(names, " "))
+		}
+	}
+
+	tokens := []struct {
+		ctx  context.Context
+		info interface{}
+		// audience of the stored tokens
+		want []string
+	}{
+		// the audience of the token information prevails over the context
+		{api, audienceToken{testToken("both", "both-refresh"), []string{"https://api.example.com", "https://billing.example.com"}},
+			[]string{"https://api.example.com", "https://billing.example.com"}},
+		{api, testToken("api", ""), []string{"https://api.example.com"}},
+		{ContextWithAudience(ctx, "https://billing.example.com"), testToken("billing", "billing-refresh"), []string{"https://billing.example.com"}},
+		{ctx, testToken("any", "any-refresh"), nil},
+		{ctx, audienceToken{testToken("empty", ""), nil}, nil},
+	}
+
+	for _, tk := range tokens {
+		var err error
+
+		switch info := tk.info.(type) {
+		case audienceToken:
+			err = ts.Create(tk.ctx, info)
+		case *models.Token:
+			err = ts.Create(tk.ctx, info)
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ts.Create(api, testCode("code")); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tk := range tokens {
+		access := []string{"both", "api", "billing", "any", "empty"}[i]
+
+		res, err := ts.Introspect(ctx, access, "")
+
+		if err != nil || !res.Active || !reflect.DeepEqual(res.Audience, tk.want) {
+			t.Fatalf("%s: introspected audience %v(%v), want %v", access, res.Audience, err, tk.want)
+		}
+
+		id, err := ts.BasicIDByAccess(ctx, access)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if chain, err := ts.Lineage(ctx, id); err != nil || !reflect.DeepEqual(chain[0].Audience, tk.want) {
+			t.Fatalf("%s: recorded audience %+v: %v", access, chain, err)
+		}
+	}
+
+	if res, err := ts.Introspect(ctx, "both-refresh", TokenTypeHintRefreshToken); err != nil || len(res.Audience) != 2 {
+		t.Fatalf("audience of the refresh token %v: %v", res.Audience, err)
+	}
+
+	if record, err := ts.RecordByCode(ctx, "code"); err != nil || !reflect.DeepEqual(record.Audience, []string{"https://api.example.com"}) {
+		t.Fatalf("audience of the code %v: %v", record.Audience, err)
+	}
+
+	// the basic, access and refresh documents of both, the basic and access documents of api and the code
+	n, err := ts.RemoveAllByAudience(ctx, "https://api.example.com")
+
+	if err != nil || n != 6 {
+		t.Fatalf("removed %d documents: %v", n, err)
+	}
+
+	for access, kept := range map[string]bool{"both": false, "api": false, "billing": true, "any": true, "empty": true} {
+		if res, err := ts.Introspect(ctx, access, ""); err != nil || res.Active != kept {
+			t.Fatalf("%s active %v(%v), want %v", access, res.Active, err, kept)
+		}
+	}
+
+	for refresh, kept := range map[string]bool{"both-refresh": false, "billing-refresh": true, "any-refresh": true} {
+		if res, err := ts.Introspect(ctx, refresh, TokenTypeHintRefreshToken); err != nil || res.Active != kept {
+			t.Fatalf("%s active %v(%v), want %v", refresh, res.Active, err, kept)
+		}
+	}
+
+	if _, err := ts.RecordByCode(ctx, "code"); err == nil {
+		t.Fatal("code of the audience kept")
+	}
+
+	if n, err := ts.RemoveAllByAudience(ctx, "https://unknown.example.com"); err != nil || n != 0 {
+		t.Fatalf("removed %d documents of an unknown audience: %v", n, err)
+	}
+
+	if _, err := ts.RemoveAllByAudience(ctx, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("empty audience: %v, want ErrInvalidArgument", err)
+	}
+}
@@ -0,0 +1,258 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type actorKey struct{}
+
+// ContextWithActor attach the user or service changing the clients to ctx, recorded by the audit trail
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext the actor attached by ContextWithActor, empty if none
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// AuditChange a field changed by a client write, the secrets are redacted
+type AuditChange struct {
+	Field string `bson:"field"`
+	// nil when the field was added
+	Old interface{} `bson:"old,omitempty"`
+	// nil when the field was removed
+	New interface{} `bson:"new,omitempty"`
+}
+
+// AuditEntry a change of a client recorded in ClientConfig.AuditCName
+type AuditEntry struct {
+	ClientID string `bson:"client_id"`
+	// operation which changed the client, e.g. Set or RotateSecret
+	Op string `bson:"op"`
+	// see ContextWithActor
+	Actor   string        `bson:"actor,omitempty"`
+	At      time.Time     `bson:"at"`
+	Changes []AuditChange `bson:"changes"`
+}
+
+// auditRedacted the value recorded for the secrets
+const auditRedacted = "[REDACTED]"
+
+// auditIgnored the fields left out of the diffs, changed by every write
+var auditIgnored = map[string]bool{clientUpdatedField: true}
+
+// auditSecrets the fields whose values are never recorded
+func (cs *ClientStore) auditSecrets() map[string]bool {
+	fn := cs.fields()
+	secrets := map[string]bool{
+		clientPreviousSecretField:    true,
+		clientRegistrationTokenField: true,
+	}
+
+	for _, name := range aliases(fn.Secret, func(f FieldNames) string { return f.Secret }) {
+		secrets[name] = true
+	}
+
+	return secrets
+}
+
+// auditDiff the changed fields between the documents, either of which may be nil
+func (cs *ClientStore) auditDiff(before, after bson.Raw) []AuditChange {
+	secrets := cs.auditSecrets()
+	changes := []AuditChange{}
+
+	value := func(field string, v bson.RawValue) interface{} {
+		if secrets[field] {
+			return auditRedacted
+		}
+
+		return v
+	}
+
+	old := map[string]bson.RawValue{}
+	elems, _ := before.Elements()
+
+	for _, e := range elems {
+		old[e.Key()] = e.Value()
+	}
+
+	elems, _ = after.Elements()
+
+	for _, e := range elems {
+		field, v := e.Key(), e.Value()
+		prev, existed := old[field]
+		delete(old, field)
+
+		if auditIgnored[field] || (existed && prev.Equal(v)) {
+			continue
+		}
+
+		change := AuditChange{Field: field, New: value(field, v)}
+
+		if existed {
+			change.Old = value(field, prev)
+		}
+
+		changes = append(changes, change)
+	}
+
+	// the removed fields, in the order of the previous document
+	elems, _ = before.Elements()
+
+	for _, e := range elems {
+		if _, removed := old[e.Key()]; removed && !auditIgnored[e.Key()] {
+			changes = append(changes, AuditChange{Field: e.Key(), Old: value(e.Key(), e.Value())})
+		}
+	}
+
+	return changes
+}
+
+// auditedHandler run the write fn of the client like colHandler, recording its changes in the audit collection
+// when configured: within the transaction of the write with StrictAudit, after it otherwise
+func (cs *ClientStore) auditedHandler(ctx context.Context, o *operation, id string, fn func(context.Context, *mongo.Collection) error) error {
+	if cs.ccfg.AuditCName == "" {
+		return cs.colHandler(ctx, cs.ccfg.ClientsCName, fn)
+	}
+
+	var entry *AuditEntry
+
+	err := cs.colHandler(ctx, cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) error {
+		before, err := cs.findRaw(ctx, c, id)
+
+		if err != nil {
+			return err
+		}
+
+		if err := fn(ctx, c); err != nil {
+			return err
+		}
+
+		after, err := cs.findRaw(ctx, c, id)
+
+		if err != nil {
+			return err
+		}
+
+		changes := cs.auditDiff(before, after)
+
+		if len(changes) == 0 {
+			return nil
+		}
+
+		entry = &AuditEntry{ClientID: id, Op: o.name, Actor: ActorFromContext(ctx), At: time.Now(), Changes: changes}
+
+		if !cs.ccfg.StrictAudit {
+			return nil
+		}
+
+		// the audit collection of the same(possibly tenant) database
+		prefix := strings.TrimSuffix(c.Name(), cs.ccfg.ClientsCName)
+		_, err = c.Database().Collection(prefix+cs.ccfg.AuditCName).InsertOne(ctx, entry)
+
+		return err
+	})
+
+	if err != nil || entry == nil || cs.ccfg.StrictAudit {
+		return err
+	}
+
+	err = cs.colHandler(ctx, cs.ccfg.AuditCName, func(ctx context.Context, c *mongo.Collection) error {
+		_, err := c.InsertOne(ctx, entry)
+		return err
+	})
+
+	if err != nil {
+		cs.logger().Log(ctx, LogWarn, "audit record failed", map[string]interface{}{
+			"collection": cs.ccfg.AuditCName,
+			"client_id":  id,
+			"op":         o.name,
+			"error":      err.Error(),
+		})
+	}
+
+	return nil
+}
+
+// findRaw the document of the client ID, nil when missing
+func (cs *ClientStore) findRaw(ctx context.Context, c *mongo.Collection, id string) (bson.Raw, error) {
+	raw, err := c.FindOne(ctx, bson.M{"_id": id}, cs.findOneOptions()).DecodeBytes()
+
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	return raw, err
+}
+
+// auditIndex the index of the audit collection, read by client and time
+func auditIndex() mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    bson.D{{Key: "client_id", Value: 1}, {Key: "at", Value: 1}},
+		Options: options.Index().SetName("client_id_1_at_1"),
+	}
+}
+
+// ensureAuditIndex create the index of the audit collection when configured
+func (cs *ClientStore) ensureAuditIndex(ctx context.Context, db routedDB) error {
+	name := cs.ccfg.AuditCName
+
+	if name == "" {
+		return nil
+	}
+
+	model := auditIndex()
+
+	if _, err := db.Collection(name).Indexes().CreateOne(ctx, model); err != nil {
+		cs.logger().Log(ctx, LogWarn, "index creation failed", map[string]interface{}{
+			"collection": db.prefix + name,
+			"error":      err.Error(),
+		})
+
+		return indexError(model, err)
+	}
+
+	return nil
+}
+
+// AuditForClient the recorded changes of the client since the given time, oldest first
+func (cs *ClientStore) AuditForClient(ctx context.Context, id string, since time.Time) (entries []AuditEntry, err error) {
+	o := cs.op("AuditForClient", cs.ccfg.AuditCName)
+	o.set("client_id", id)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", id); err != nil {
+			return err
+		}
+
+		if cs.ccfg.AuditCName == "" {
+			return fmt.Errorf("%w: no audit collection configured", ErrInvalidConfig)
+		}
+
+		entries = []AuditEntry{}
+
+		return cs.readHandler(ctx, cs.ccfg.AuditCName, func(ctx context.Context, c *mongo.Collection) error {
+			filter := bson.M{"client_id": id, "at": bson.M{"$gte": since}}
+			cur, err := c.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "at", Value: 1}, {Key: "_id", Value: 1}}))
+
+			if err != nil {
+				return err
+			}
+
+			defer cur.Close(ctx)
+
+			return cur.All(ctx, &entries)
+		})
+	})
+
+	return
+}
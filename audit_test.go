@@ -0,0 +1,224 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// auditChanges the changes of the entry by field
+func auditChanges(entry AuditEntry) map[string]AuditChange {
+	changes := make(map[string]AuditChange)
+
+	for _, c := range entry.Changes {
+		changes[c.Field] = c
+	}
+
+	return changes
+}
+
+// breakAudit make the next audit record of the client id fail on a unique index
+func breakAudit(t *testing.T, cs *ClientStore, id string) {
+	t.Helper()
+
+	ctx := context.Background()
+	c := cs.Database().Collection(cs.ccfg.AuditCName)
+
+	if _, err := c.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "client_id", Value: 1}},
+		Options: options.Index().SetName("client_id_unique").SetUnique(true),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.InsertOne(ctx, bson.M{"client_id": id}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditTrail(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.AuditCName = "oauth2_client_audit"
+	cs := newTestClientStore(t, ccfg)
+	ctx := ContextWithActor(context.Background(), "alice")
+	since := time.Now().Add(-time.Second)
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "first-secret", Domain: "https://example.com", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Update(ctx, &models.Client{ID: "c", Domain: "https://example.org", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.RotateSecret(ctx, "c", "second-secret", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.SetStatus(ctx, "c", ClientSuspended); err != nil {
+		t.Fatal(err)
+	}
+
+	// a write changing nothing records nothing
+	if err := cs.SetStatus(ctx, "c", ClientSuspended); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.RemoveByID("c"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := cs.AuditForClient(context.Background(), "c", since)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops := []string{}
+
+	for _, e := range entries {
+		ops = append(ops, e.Op)
+	}
+
+	if strings.Join(ops, " ") != "Set Update RotateSecret SetStatus RemoveByID" {
+		t.Fatalf("recorded %v", ops)
+	}
+
+	// the creation has only new values, the secret redacted
+	set := auditChanges(entries[0])
+
+	if c := set["secret"]; c.Old != nil || c.New != auditRedacted {
+		t.Fatalf("secret change %+v", c)
+	}
+
+	if c := set["domain"]; c.Old != nil || c.New != "https://example.com" {
+		t.Fatalf("domain change %+v", c)
+	}
+
+	update := auditChanges(entries[1])
+
+	if c, ok := update["domain"]; !ok || c.Old != "https://example.com" || c.New != "https://example.org" {
+		t.Fatalf("domain change %+v", c)
+	}
+
+	if _, ok := update["secret"]; ok || entries[1].Actor != "alice" || entries[0].Actor != "" {
+		t.Fatalf("unexpected update entry %+v", entries[1])
+	}
+
+	rotate := auditChanges(entries[2])
+
+	if c := rotate["secret"]; c.Old != auditRedacted || c.New != auditRedacted {
+		t.Fatalf("secret change %+v", c)
+	}
+
+	if c := rotate[clientPreviousSecretField]; c.New != auditRedacted {
+		t.Fatalf("previous secret change %+v", c)
+	}
+
+	if c := auditChanges(entries[3])[clientStatusField]; c.Old != nil || c.New != string(ClientSuspended) {
+		t.Fatalf("status change %+v", c)
+	}
+
+	// the removal has only old values
+	for _, c := range entries[4].Changes {
+		if c.New != nil {
+			t.Fatalf("removal change %+v", c)
+		}
+	}
+
+	// the secrets are nowhere in the trail
+	cur, err := cs.Database().Collection(ccfg.AuditCName).Find(ctx, bson.M{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var docs []bson.Raw
+
+	if err := cur.All(ctx, &docs); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, doc := range docs {
+		if s := doc.String(); strings.Contains(s, "first-secret") || strings.Contains(s, "second-secret") {
+			t.Fatalf("secret recorded in %s", s)
+		}
+	}
+
+	if entries, err := cs.AuditForClient(ctx, "c", time.Now().Add(time.Hour)); err != nil || len(entries) != 0 {
+		t.Fatalf("entries since a later time %v: %v", entries, err)
+	}
+
+	if _, err := newTestClientStore(t).AuditForClient(ctx, "c", since); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("without audit collection: %v, want ErrInvalidConfig", err)
+	}
+}
+
+// a failed audit record is logged, the change kept
+func TestAuditBestEffort(t *testing.T) {
+	var rec logRecorder
+
+	ccfg := NewDefaultClientConfig()
+	ccfg.AuditCName = "oauth2_client_audit"
+	cs := newTestClientStore(t, ccfg, WithLogger(&rec))
+
+	breakAudit(t, cs, "c")
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cs.GetByID(context.Background(), "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.named("audit record failed")) != 1 {
+		t.Fatalf("audit failure not logged: %+v", rec.records)
+	}
+}
+
+// a failed audit record fails the change and rolls it back
+func TestStrictAudit(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.AuditCName = "oauth2_client_audit"
+	ccfg.StrictAudit = true
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+
+	requireTransactions(t, cs.Capabilities())
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	breakAudit(t, cs, "c")
+
+	if err := cs.Update(ctx, &models.Client{ID: "c", Domain: "https://example.org"}); err == nil {
+		t.Fatal("change kept without its audit record")
+	}
+
+	info, err := cs.GetByID(ctx, "c")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.GetDomain() != "https://example.com" {
+		t.Fatalf("domain %q after the failed change, want it rolled back", info.GetDomain())
+	}
+
+	if err := cs.Set(&models.Client{ID: "other", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries, err := cs.AuditForClient(ctx, "other", time.Time{}); err != nil || len(entries) != 1 {
+		t.Fatalf("entries %v: %v", entries, err)
+	}
+}
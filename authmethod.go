@@ -0,0 +1,77 @@
+package mongo
+
+import (
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// field of the RFC 7591 token endpoint authentication method
+const clientAuthMethodField = "token_endpoint_auth_method"
+
+// token endpoint authentication methods of RFC 7591
+const (
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodPrivateKeyJWT     = "private_key_jwt"
+	AuthMethodNone              = "none"
+)
+
+// knownAuthMethod report whether the store supports the authentication method
+func knownAuthMethod(method string) bool {
+	switch method {
+	case AuthMethodClientSecretBasic, AuthMethodClientSecretPost, AuthMethodPrivateKeyJWT, AuthMethodNone:
+		return true
+	}
+
+	return false
+}
+
+// usesSecret report whether clients of the method authenticate with their secret
+func usesSecret(method string) bool {
+	return method == AuthMethodClientSecretBasic || method == AuthMethodClientSecretPost
+}
+
+// effectiveAuthMethod the method of a client storing method, none for public clients and client_secret_basic
+// for the others when it stores none
+func effectiveAuthMethod(method string, public bool) string {
+	switch {
+	case method != "":
+		return method
+	case public:
+		return AuthMethodNone
+	}
+
+	return AuthMethodClientSecretBasic
+}
+
+// authMethodClient client information registering its token endpoint authentication method
+type authMethodClient interface {
+	GetTokenEndpointAuthMethod() string
+}
+
+// authMethod the token endpoint authentication method of info
+func authMethod(info oauth2.ClientInfo) string {
+	var method string
+
+	if ac, ok := info.(authMethodClient); ok {
+		method = ac.GetTokenEndpointAuthMethod()
+	}
+
+	return effectiveAuthMethod(method, isPublic(info))
+}
+
+// decodeAuthMethod the stored method, the clients registered before it was stored with the client keep it
+// in their registration
+func decodeAuthMethod(raw bson.Raw) string {
+	if method := lookupString(raw, []string{clientAuthMethodField}); method != "" {
+		return method
+	}
+
+	if v, ok := lookup(raw, []string{clientRegistrationField}); ok {
+		if doc, ok := v.DocumentOK(); ok {
+			return lookupString(doc, []string{clientAuthMethodField})
+		}
+	}
+
+	return ""
+}
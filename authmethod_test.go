@@ -0,0 +1,98 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTokenEndpointAuthMethods(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	clients := map[string]*Client{
+		AuthMethodClientSecretBasic: {Client: models.Client{ID: "basic", Secret: "secret"}},
+		AuthMethodClientSecretPost:  {Client: models.Client{ID: "post", Secret: "secret"}},
+		AuthMethodPrivateKeyJWT:     {Client: models.Client{ID: "jwt"}, JWKSURI: "https://example.com/jwks.json"},
+		AuthMethodNone:              {Client: models.Client{ID: "none"}, Public: true},
+	}
+
+	for method, c := range clients {
+		c.Domain, c.TokenEndpointAuthMethod = "https://example.com", method
+
+		if err := cs.Set(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the documents stored without the method
+	if _, err := cs.Collection().InsertMany(ctx, []interface{}{
+		bson.M{"_id": "legacy", "secret": "secret", "domain": "https://example.com"},
+		bson.M{"_id": "legacy-public", "domain": "https://example.com", "public": true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id, method string
+		// error of VerifySecret with the right secret
+		err error
+		// whether VerifySecret with the right secret and VerifyPassword with no secret succeed
+		secretOK, emptyOK bool
+	}{
+		{"basic", AuthMethodClientSecretBasic, nil, true, false},
+		{"post", AuthMethodClientSecretPost, nil, true, false},
+		{"legacy", AuthMethodClientSecretBasic, nil, true, false},
+		{"jwt", AuthMethodPrivateKeyJWT, ErrSecretNotAllowed, false, true},
+		{"none", AuthMethodNone, ErrPublicClient, false, true},
+		{"legacy-public", AuthMethodNone, ErrPublicClient, false, true},
+	}
+
+	for _, tt := range tests {
+		info, err := cs.GetByID(ctx, tt.id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := asClient(info)
+
+		if c.GetTokenEndpointAuthMethod() != tt.method || c.Registered().TokenEndpointAuthMethod != tt.method {
+			t.Fatalf("%s: method %q, want %q", tt.id, c.GetTokenEndpointAuthMethod(), tt.method)
+		}
+
+		ok, err := cs.VerifySecret(ctx, tt.id, "secret")
+
+		if ok != tt.secretOK || !errors.Is(err, tt.err) {
+			t.Fatalf("%s: VerifySecret %v(%v), want %v(%v)", tt.id, ok, err, tt.secretOK, tt.err)
+		}
+
+		var ae *AuthMethodError
+
+		if tt.err != nil && (!errors.As(err, &ae) || ae.Method != tt.method || !errors.Is(err, ErrSecretNotAllowed)) {
+			t.Fatalf("%s: %v, want an AuthMethodError of %s", tt.id, err, tt.method)
+		}
+
+		// only the public clients are ErrPublicClient
+		if tt.method == AuthMethodPrivateKeyJWT && errors.Is(err, ErrPublicClient) {
+			t.Fatalf("%s: %v is ErrPublicClient", tt.id, err)
+		}
+
+		if c.VerifyPassword("secret") != tt.secretOK || c.VerifyPassword("") != tt.emptyOK || c.VerifyPassword("wrong") {
+			t.Fatalf("%s: VerifyPassword accepts secret %v, empty %v, wrong %v", tt.id,
+				c.VerifyPassword("secret"), c.VerifyPassword(""), c.VerifyPassword("wrong"))
+		}
+	}
+
+	// a client of a secret method without secret
+	var ve *ClientValidationError
+
+	err := cs.Set(&Client{Client: models.Client{ID: "c", Domain: "https://example.com"}, TokenEndpointAuthMethod: AuthMethodClientSecretPost})
+
+	if !errors.As(err, &ve) || ve.Fields[0].Field != "secret" {
+		t.Fatalf("client_secret_post without secret: %v, want a secret error", err)
+	}
+}
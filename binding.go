@@ -0,0 +1,160 @@
+package mongo
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrBindingMismatch returned when the presented proof-of-possession doesn't match the binding of the token
+var ErrBindingMismatch = errors.New("mongo: token binding mismatch")
+
+// Confirmation proof-of-possession binding of a token(RFC 7800 cnf claim)
+type Confirmation struct {
+	// base64url SHA-256 thumbprint of the client certificate(RFC 8705 x5t#S256)
+	X5TS256 string
+	// base64url SHA-256 thumbprint of the DPoP public key(RFC 9449 jkt)
+	JKT string
+}
+
+func (cnf Confirmation) empty() bool {
+	return cnf == Confirmation{}
+}
+
+func (cnf Confirmation) doc() bson.D {
+	var doc bson.D
+
+	if cnf.X5TS256 != "" {
+		doc = append(doc, bson.E{Key: "x5t#S256", Value: cnf.X5TS256})
+	}
+
+	if cnf.JKT != "" {
+		doc = append(doc, bson.E{Key: "jkt", Value: cnf.JKT})
+	}
+
+	return doc
+}
+
+func decodeConfirmation(raw bson.Raw, names []string) Confirmation {
+	v, ok := lookup(raw, names)
+
+	if !ok {
+		return Confirmation{}
+	}
+
+	doc, ok := v.DocumentOK()
+
+	if !ok {
+		return Confirmation{}
+	}
+
+	return Confirmation{
+		X5TS256: lookupString(doc, []string{"x5t#S256"}),
+		JKT:     lookupString(doc, []string{"jkt"}),
+	}
+}
+
+// matchBinding compare a presented value with the bound one, unbound tokens match anything
+func matchBinding(bound, presented string) error {
+	if bound == "" || subtle.ConstantTimeCompare([]byte(bound), []byte(presented)) == 1 {
+		return nil
+	}
+
+	return ErrBindingMismatch
+}
+
+// CreateWithConfirmation create and store the new token information, binding the access and refresh tokens to cnf
+func (ts *TokenStore) CreateWithConfirmation(ctx context.Context, info oauth2.TokenInfo, cnf Confirmation) error {
+	o := ts.op("CreateWithConfirmation", ts.tcfg.BasicCName)
+	ctx = ts.carrySession(ctx)
+
+	return ts.run(ctx, o, func(ctx context.Context) error {
+		if err := validateTokenInfo(info); err != nil {
+			return err
+		}
+
+		o.sensitive(info.GetCode(), info.GetAccess(), info.GetRefresh())
+
+		return ts.create(ctx, o, info, cnf, tokenLineage{})
+	})
+}
+
+// GetByAccessBound use the access token for token information data, certificate-bound access tokens
+// are only returned for the matching client certificate thumbprint(ErrBindingMismatch otherwise)
+func (ts *TokenStore) GetByAccessBound(ctx context.Context, access, thumbprint string) (ti oauth2.TokenInfo, err error) {
+	o := ts.op("GetByAccessBound", ts.tcfg.AccessCName)
+	o.sensitive(access)
+
+	err = ts.run(ctx, o, func(ctx context.Context) (err error) {
+		if err := requireArg("access token", access); err != nil {
+			return err
+		}
+
+		if err := ts.accessStored(); err != nil {
+			return err
+		}
+
+		ti, err = ts.getByToken(ctx, o, access, false, func(td tokenData) error {
+			return matchBinding(td.Confirmation.X5TS256, thumbprint)
+		})
+		return
+	})
+
+	return
+}
+
+// VerifyJKTByAccess check the DPoP key thumbprint of the access token, ErrBindingMismatch when the token
+// is bound to another key, nil for unbound tokens
+func (ts *TokenStore) VerifyJKTByAccess(ctx context.Context, access, jkt string) error {
+	o := ts.op("VerifyJKTByAccess", ts.tcfg.AccessCName)
+	o.sensitive(access)
+
+	return ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("access token", access); err != nil {
+			return err
+		}
+
+		if err := ts.accessStored(); err != nil {
+			return err
+		}
+
+		td, err := ts.getTokenData(ctx, ts.tcfg.AccessCName, access, false)
+
+		if err != nil {
+			return err
+		}
+
+		o.set("basic_id", td.BasicID)
+
+		return matchBinding(td.Confirmation.JKT, jkt)
+	})
+}
+
+// GetConfirmationByRefresh the binding of the refresh token, to be carried forward to the tokens issued
+// when it is used(see CreateWithConfirmation)
+func (ts *TokenStore) GetConfirmationByRefresh(ctx context.Context, refresh string) (cnf Confirmation, err error) {
+	o := ts.op("GetConfirmationByRefresh", ts.tcfg.RefreshCName)
+	o.sensitive(refresh)
+
+	err = ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("refresh token", refresh); err != nil {
+			return err
+		}
+
+		td, err := ts.getTokenData(ctx, ts.tcfg.RefreshCName, refresh, true)
+
+		if err != nil {
+			return err
+		}
+
+		o.set("basic_id", td.BasicID)
+		cnf = td.Confirmation
+
+		return nil
+	})
+
+	return
+}
@@ -0,0 +1,109 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestCertificateBinding(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestTokenStore(t)
+
+	if err := ts.CreateWithConfirmation(ctx, testToken("bound", ""), Confirmation{X5TS256: "thumbprint"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Create(ctx, testToken("unbound", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		access     string
+		thumbprint string
+		err        error
+	}{
+		{"match", "bound", "thumbprint", nil},
+		{"mismatch", "bound", "other", ErrBindingMismatch},
+		{"no certificate", "bound", "", ErrBindingMismatch},
+		{"unbound", "unbound", "thumbprint", nil},
+		{"unbound without certificate", "unbound", "", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := ts.GetByAccessBound(ctx, tc.access, tc.thumbprint)
+
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("%v, want %v", err, tc.err)
+			}
+
+			if (err == nil) != (info != nil) {
+				t.Fatalf("token %v with %v", info, err)
+			}
+		})
+	}
+
+	// the plain lookup ignores the binding
+	if _, err := ts.GetByAccess(ctx, "bound"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDPoPBinding(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestTokenStore(t)
+	cnf := Confirmation{JKT: "jkt"}
+
+	if err := ts.CreateWithConfirmation(ctx, testToken("bound", "refresh"), cnf); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Create(ctx, testToken("unbound", "unbound-refresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		access string
+		jkt    string
+		err    error
+	}{
+		{"match", "bound", "jkt", nil},
+		{"mismatch", "bound", "other", ErrBindingMismatch},
+		{"no proof", "bound", "", ErrBindingMismatch},
+		{"unbound", "unbound", "jkt", nil},
+		{"unknown token", "unknown", "jkt", mongo.ErrNoDocuments},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ts.VerifyJKTByAccess(ctx, tc.access, tc.jkt); !errors.Is(err, tc.err) {
+				t.Fatalf("%v, want %v", err, tc.err)
+			}
+		})
+	}
+
+	// a DPoP-bound token isn't certificate-bound
+	if _, err := ts.GetByAccessBound(ctx, "bound", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// carried forward on refresh
+	got, err := ts.GetConfirmationByRefresh(ctx, "refresh")
+
+	if err != nil || got != cnf {
+		t.Fatalf("confirmation %+v: %v, want %+v", got, err, cnf)
+	}
+
+	if err := ts.CreateWithConfirmation(ctx, testToken("next", "next-refresh"), got); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.VerifyJKTByAccess(ctx, "next", "other"); !errors.Is(err, ErrBindingMismatch) {
+		t.Fatalf("descendant token: %v", err)
+	}
+
+	if got, err := ts.GetConfirmationByRefresh(ctx, "unbound-refresh"); err != nil || got != (Confirmation{}) {
+		t.Fatalf("confirmation of an unbound token %+v: %v", got, err)
+	}
+}
@@ -0,0 +1,275 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrCircuitOpen returned without contacting the server while the circuit breaker is open
+var ErrCircuitOpen = errors.New("mongo: circuit breaker is open")
+
+// BreakerState state of a circuit breaker
+type BreakerState int
+
+// circuit breaker states
+const (
+	// BreakerClosed operations run normally
+	BreakerClosed BreakerState = iota
+	// BreakerOpen operations fail fast with ErrCircuitOpen
+	BreakerOpen
+	// BreakerHalfOpen a limited number of probe operations are let through
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// BreakerConfig circuit breaker configuration parameters
+type BreakerConfig struct {
+	// consecutive failures which open the circuit(The default is 5)
+	FailureThreshold int
+	// how long the circuit stays open before probing(The default is 30s)
+	OpenDuration time.Duration
+	// successful probes required to close a half-open circuit,
+	// which is also the number of probes allowed concurrently(The default is 1)
+	HalfOpenProbes int
+	// decide whether an operation error counts as a failure, the default ignores "no documents", write errors,
+	// canceled contexts and the rejections of the store answered by a healthy server(e.g. ErrSecretChanged)
+	IsFailure func(error) bool
+	// called after every state transition, which is also reported to the Logger and Metrics of the store
+	// whose operation caused it
+	OnStateChange func(from, to BreakerState)
+}
+
+// Breaker circuit breaker guarding the mongo operations of a store,
+// a single breaker may be shared by several stores using the same cluster
+type Breaker struct {
+	cfg BreakerConfig
+
+	mu         sync.Mutex
+	state      BreakerState
+	generation uint64
+	failures   int
+	probes     int
+	successes  int
+	openedAt   time.Time
+}
+
+// NewBreaker create a circuit breaker
+func NewBreaker(cfg BreakerConfig) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+
+	if cfg.OpenDuration <= 0 {
+		cfg.OpenDuration = 30 * time.Second
+	}
+
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = isBreakerFailure
+	}
+
+	return &Breaker{cfg: cfg}
+}
+
+// State current state of the breaker
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cfg.OpenDuration {
+		return BreakerHalfOpen
+	}
+
+	return b.state
+}
+
+// Do run fn when the breaker allows it, a nil breaker always runs fn
+func (b *Breaker) Do(fn func() error) error {
+	return b.do(fn, nil)
+}
+
+// do run fn as Do, the transitions it causes are also passed to changed
+func (b *Breaker) do(fn func() error, changed func(from, to BreakerState)) error {
+	if b == nil {
+		return fn()
+	}
+
+	gen, err := b.allow(changed)
+
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	b.done(gen, err, changed)
+
+	return err
+}
+
+func (b *Breaker) allow(changed func(from, to BreakerState)) (uint64, error) {
+	b.mu.Lock()
+
+	var from BreakerState
+	halfOpen := false
+
+	if b.state == BreakerOpen {
+		if time.Since(b.openedAt) < b.cfg.OpenDuration {
+			b.mu.Unlock()
+			return 0, ErrCircuitOpen
+		}
+
+		from, halfOpen = b.setState(BreakerHalfOpen), true
+	}
+
+	if b.state == BreakerHalfOpen {
+		if b.probes >= b.cfg.HalfOpenProbes {
+			b.mu.Unlock()
+			return 0, ErrCircuitOpen
+		}
+
+		b.probes++
+	}
+
+	gen := b.generation
+	b.mu.Unlock()
+
+	if halfOpen {
+		b.notify(from, BreakerHalfOpen, changed)
+	}
+
+	return gen, nil
+}
+
+func (b *Breaker) done(gen uint64, err error, changed func(from, to BreakerState)) {
+	b.mu.Lock()
+
+	// the outcome of an operation started in an earlier state is irrelevant
+	if gen != b.generation {
+		b.mu.Unlock()
+		return
+	}
+
+	failed := err != nil && b.cfg.IsFailure(err)
+	from, to := b.state, b.state
+
+	switch b.state {
+	case BreakerClosed:
+		if !failed {
+			b.failures = 0
+			break
+		}
+
+		b.failures++
+
+		if b.failures >= b.cfg.FailureThreshold {
+			b.setState(BreakerOpen)
+			to = BreakerOpen
+		}
+	case BreakerHalfOpen:
+		if failed {
+			b.setState(BreakerOpen)
+			to = BreakerOpen
+			break
+		}
+
+		b.successes++
+
+		if b.successes >= b.cfg.HalfOpenProbes {
+			b.setState(BreakerClosed)
+			to = BreakerClosed
+		}
+	}
+
+	b.mu.Unlock()
+
+	if from != to {
+		b.notify(from, to, changed)
+	}
+}
+
+// setState switch to the given state and reset the counters, the lock must be held
+func (b *Breaker) setState(to BreakerState) BreakerState {
+	from := b.state
+
+	b.state = to
+	b.generation++
+	b.failures = 0
+	b.probes = 0
+	b.successes = 0
+
+	if to == BreakerOpen {
+		b.openedAt = time.Now()
+	}
+
+	return from
+}
+
+func (b *Breaker) notify(from, to BreakerState, changed func(from, to BreakerState)) {
+	if b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(from, to)
+	}
+
+	if changed != nil {
+		changed(from, to)
+	}
+}
+
+// breakerObserver report the breaker transitions through the logger and metrics of a store,
+// an opened circuit is a warning
+func breakerObserver(ctx context.Context, logger Logger, metrics Metrics) func(from, to BreakerState) {
+	return func(from, to BreakerState) {
+		level := LogInfo
+
+		if to == BreakerOpen {
+			level = LogWarn
+		}
+
+		logger.Log(ctx, level, "circuit breaker "+to.String(), map[string]interface{}{"from": from.String(), "to": to.String()})
+		metrics.ObserveBreaker(from, to)
+	}
+}
+
+// breakerRejections the errors of the operations the server answered, which say nothing of its health
+var breakerRejections = []error{
+	// a lost rotation race
+	ErrSecretChanged,
+	// a concurrent ownership transfer
+	ErrOwnerChanged,
+	// a user at their clients quota
+	ErrQuotaExceeded,
+}
+
+func isBreakerFailure(err error) bool {
+	if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	for _, target := range breakerRejections {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+
+	var we mongo.WriteException
+
+	return !errors.As(err, &we) || we.WriteConcernError != nil
+}
@@ -0,0 +1,241 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errInjected = errors.New("injected failure")
+
+// transitionRecorder record the state transitions of a breaker
+type transitionRecorder struct {
+	mu          sync.Mutex
+	transitions []string
+}
+
+func (r *transitionRecorder) record(from, to BreakerState) {
+	r.mu.Lock()
+	r.transitions = append(r.transitions, from.String()+"->"+to.String())
+	r.mu.Unlock()
+}
+
+func (r *transitionRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.transitions...)
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	var rec transitionRecorder
+
+	b := NewBreaker(BreakerConfig{
+		FailureThreshold: 3,
+		OpenDuration:     50 * time.Millisecond,
+		HalfOpenProbes:   2,
+		OnStateChange:    rec.record,
+	})
+
+	fail := func() error { return errInjected }
+	succeed := func() error { return nil }
+
+	for i := 0; i < 2; i++ {
+		if err := b.Do(fail); err != errInjected {
+			t.Fatalf("failure %d: %v", i, err)
+		}
+	}
+
+	// a success resets the consecutive failures
+	if err := b.Do(succeed); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_ = b.Do(fail)
+	}
+
+	if s := b.State(); s != BreakerOpen {
+		t.Fatalf("state %v after 3 failures, want open", s)
+	}
+
+	ran := false
+
+	if err := b.Do(func() error { ran = true; return nil }); err != ErrCircuitOpen || ran {
+		t.Fatalf("open breaker ran the operation(%v): %v", ran, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if s := b.State(); s != BreakerHalfOpen {
+		t.Fatalf("state %v after the open duration, want half-open", s)
+	}
+
+	if err := b.Do(succeed); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := b.State(); s != BreakerHalfOpen {
+		t.Fatalf("state %v after 1 of 2 probes, want half-open", s)
+	}
+
+	if err := b.Do(succeed); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := b.State(); s != BreakerClosed {
+		t.Fatalf("state %v after 2 successful probes, want closed", s)
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+
+	if got := rec.get(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("transitions %v, want %v", got, want)
+	}
+}
+
+func TestBreakerFailedProbeReopens(t *testing.T) {
+	var rec transitionRecorder
+
+	b := NewBreaker(BreakerConfig{FailureThreshold: 1, OpenDuration: 20 * time.Millisecond, OnStateChange: rec.record})
+
+	_ = b.Do(func() error { return errInjected })
+	time.Sleep(30 * time.Millisecond)
+
+	if err := b.Do(func() error { return errInjected }); err != errInjected {
+		t.Fatal(err)
+	}
+
+	if s := b.State(); s != BreakerOpen {
+		t.Fatalf("state %v after a failed probe, want open", s)
+	}
+
+	if got := rec.get(); len(got) != 3 || got[2] != "half-open->open" {
+		t.Fatalf("transitions %v", got)
+	}
+}
+
+// only HalfOpenProbes operations run while half-open
+func TestBreakerHalfOpenProbeLimit(t *testing.T) {
+	b := NewBreaker(BreakerConfig{FailureThreshold: 1, OpenDuration: 20 * time.Millisecond})
+
+	_ = b.Do(func() error { return errInjected })
+	time.Sleep(30 * time.Millisecond)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- b.Do(func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+
+	<-started
+
+	if err := b.Do(func() error { return nil }); err != ErrCircuitOpen {
+		t.Fatalf("second probe: %v, want ErrCircuitOpen", err)
+	}
+
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if s := b.State(); s != BreakerClosed {
+		t.Fatalf("state %v, want closed", s)
+	}
+}
+
+func TestBreakerIgnoredErrors(t *testing.T) {
+	b := NewBreaker(BreakerConfig{FailureThreshold: 1})
+
+	for _, err := range []error{
+		mongo.ErrNoDocuments,
+		context.Canceled,
+		mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}},
+		ErrSecretChanged,
+		ErrOwnerChanged,
+		&QuotaError{UserID: "u", Max: 1},
+	} {
+		_ = b.Do(func() error { return err })
+
+		if s := b.State(); s != BreakerClosed {
+			t.Fatalf("%v opened the breaker", err)
+		}
+	}
+}
+
+// once the server is known to be unreachable the store fails fast
+func TestBreakerStoreFailsFast(t *testing.T) {
+	b := NewBreaker(BreakerConfig{FailureThreshold: 1, OpenDuration: time.Minute})
+	ts := testTokenStoreWithDB(unreachableDatabase(t), WithBreaker(b))
+
+	ctx := context.Background()
+
+	if _, err := ts.GetByAccess(ctx, "access"); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("first lookup: %v, want the server selection error", err)
+	}
+
+	start := time.Now()
+
+	if _, err := ts.GetByAccess(ctx, "access"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second lookup: %v, want ErrCircuitOpen", err)
+	}
+
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatalf("open breaker took %v", d)
+	}
+}
+
+// the transitions caused by the operations of a store go through its logger and metrics
+func TestBreakerStoreHooks(t *testing.T) {
+	var (
+		rec logRecorder
+		m   memMetrics
+	)
+
+	b := NewBreaker(BreakerConfig{FailureThreshold: 2, OpenDuration: 50 * time.Millisecond})
+	ts := testTokenStoreWithDB(unreachableDatabase(t), WithBreaker(b), WithLogger(&rec), WithMetrics(&m))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, _ = ts.GetByAccess(ctx, "access")
+	}
+
+	if s := b.State(); s != BreakerOpen {
+		t.Fatalf("state %v after 2 failures, want open", s)
+	}
+
+	opened := rec.named("circuit breaker open")
+
+	if len(opened) != 1 || opened[0].level != LogWarn || opened[0].fields["from"] != "closed" {
+		t.Fatalf("open records %+v", opened)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	// the failed probe opens the circuit again
+	_, _ = ts.GetByAccess(ctx, "access")
+
+	if records := rec.named("circuit breaker half-open"); len(records) != 1 || records[0].level != LogInfo {
+		t.Fatalf("half-open records %+v", records)
+	}
+
+	m.mu.Lock()
+	got := append([]string(nil), m.transitions...)
+	m.mu.Unlock()
+
+	if want := []string{"closed->open", "open->half-open", "half-open->open"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("transitions %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// wire versions of the server releases introducing the features used by the stores
+const (
+	wireVersion36 = 6
+	wireVersion40 = 7
+	wireVersion42 = 8
+)
+
+// Capabilities the server version and features detected when the store was created
+type Capabilities struct {
+	// the server answered the probe, otherwise every feature is assumed to be available
+	Probed bool
+	// server version reported by buildInfo
+	Version string
+	// highest wire protocol version of the server
+	MaxWireVersion int32
+	// member of a replica set
+	ReplicaSet bool
+	// mongos of a sharded cluster
+	Sharded bool
+	// logical sessions
+	Sessions bool
+	// multi-document transactions: replica sets from 4.0, sharded clusters from 4.2.
+	// Without them the writes run without transactions, see WithoutTransactions
+	Transactions bool
+	// change streams on replica sets and sharded clusters from 3.6
+	ChangeStreams bool
+	// $lookup with let and pipeline from 3.6
+	LookupPipeline bool
+}
+
+// helloResult the fields of the hello(isMaster before 4.4.2) response deciding the capabilities
+type helloResult struct {
+	SetName                      string `bson:"setName"`
+	Msg                          string `bson:"msg"`
+	MaxWireVersion               int32  `bson:"maxWireVersion"`
+	LogicalSessionTimeoutMinutes *int32 `bson:"logicalSessionTimeoutMinutes"`
+}
+
+// newCapabilities decide the capabilities of a server from its hello response and version
+func newCapabilities(hello helloResult, version string) Capabilities {
+	c := Capabilities{
+		Probed:         true,
+		Version:        version,
+		MaxWireVersion: hello.MaxWireVersion,
+		ReplicaSet:     hello.SetName != "",
+		Sharded:        hello.Msg == "isdbgrid",
+		Sessions:       hello.LogicalSessionTimeoutMinutes != nil,
+	}
+
+	c.Transactions = c.Sessions &&
+		((c.ReplicaSet && c.MaxWireVersion >= wireVersion40) || (c.Sharded && c.MaxWireVersion >= wireVersion42))
+	c.ChangeStreams = (c.ReplicaSet || c.Sharded) && c.MaxWireVersion >= wireVersion36
+	c.LookupPipeline = c.MaxWireVersion >= wireVersion36
+
+	return c
+}
+
+// detectCapabilities probe the server, a server not knowing hello is asked with isMaster
+func detectCapabilities(ctx context.Context, client *mongo.Client) Capabilities {
+	admin := client.Database("admin")
+
+	var hello helloResult
+
+	if err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		if err := admin.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello); err != nil {
+			return Capabilities{}
+		}
+	}
+
+	var build struct {
+		Version string `bson:"version"`
+	}
+
+	// some compatible services restrict buildInfo, the version is then unknown
+	_ = admin.RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&build)
+
+	return newCapabilities(hello, build.Version)
+}
+
+// probeCapabilities detect the capabilities at construction and disable the transactions
+// the server doesn't support instead of failing every write
+func probeCapabilities(mode CompatibilityMode, disabled *bool, conns *connHolder, logger Logger) Capabilities {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+
+	defer cancel()
+
+	caps := detectCapabilities(ctx, conns.client())
+
+	logger.Log(ctx, LogDebug, "server capabilities", map[string]interface{}{
+		"version":      caps.Version,
+		"wire_version": caps.MaxWireVersion,
+		"transactions": caps.Transactions,
+	})
+
+	if caps.Probed && !caps.Transactions && !*disabled && mode.transactions() {
+		*disabled = true
+		logger.Log(ctx, LogInfo, "transactions disabled", map[string]interface{}{
+			"compatibility": mode.String(),
+			"version":       caps.Version,
+		})
+	}
+
+	return caps
+}
+
+// Capabilities the server version and features detected when the store was created
+func (ts *TokenStore) Capabilities() Capabilities {
+	return ts.caps
+}
+
+// Capabilities the server version and features detected when the store was created
+func (cs *ClientStore) Capabilities() Capabilities {
+	return cs.caps
+}
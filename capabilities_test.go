@@ -0,0 +1,109 @@
+package mongo
+
+import (
+	"testing"
+)
+
+func TestNewCapabilities(t *testing.T) {
+	sessions := int32(30)
+
+	cases := []struct {
+		name    string
+		hello   helloResult
+		version string
+		want    Capabilities
+	}{
+		{
+			name:    "standalone 7.0",
+			hello:   helloResult{MaxWireVersion: 21, LogicalSessionTimeoutMinutes: &sessions},
+			version: "7.0.12",
+			want:    Capabilities{Sessions: true, LookupPipeline: true},
+		},
+		{
+			name:    "replica set 3.4",
+			hello:   helloResult{SetName: "rs0", MaxWireVersion: 5},
+			version: "3.4.24",
+			want:    Capabilities{ReplicaSet: true},
+		},
+		{
+			name:    "replica set 3.6",
+			hello:   helloResult{SetName: "rs0", MaxWireVersion: 6, LogicalSessionTimeoutMinutes: &sessions},
+			version: "3.6.23",
+			want:    Capabilities{ReplicaSet: true, Sessions: true, ChangeStreams: true, LookupPipeline: true},
+		},
+		{
+			name:    "replica set 4.0",
+			hello:   helloResult{SetName: "rs0", MaxWireVersion: 7, LogicalSessionTimeoutMinutes: &sessions},
+			version: "4.0.28",
+			want:    Capabilities{ReplicaSet: true, Sessions: true, Transactions: true, ChangeStreams: true, LookupPipeline: true},
+		},
+		{
+			name:    "sharded 4.0",
+			hello:   helloResult{Msg: "isdbgrid", MaxWireVersion: 7, LogicalSessionTimeoutMinutes: &sessions},
+			version: "4.0.28",
+			want:    Capabilities{Sharded: true, Sessions: true, ChangeStreams: true, LookupPipeline: true},
+		},
+		{
+			name:    "sharded 4.2",
+			hello:   helloResult{Msg: "isdbgrid", MaxWireVersion: 8, LogicalSessionTimeoutMinutes: &sessions},
+			version: "4.2.25",
+			want:    Capabilities{Sharded: true, Sessions: true, Transactions: true, ChangeStreams: true, LookupPipeline: true},
+		},
+		{
+			name:  "replica set without sessions",
+			hello: helloResult{SetName: "rs0", MaxWireVersion: 13},
+			// e.g. a compatible service restricting buildInfo
+			version: "",
+			want:    Capabilities{ReplicaSet: true, ChangeStreams: true, LookupPipeline: true},
+		},
+	}
+
+	for _, tc := range cases {
+		tc.want.Probed = true
+		tc.want.Version = tc.version
+		tc.want.MaxWireVersion = tc.hello.MaxWireVersion
+
+		if got := newCapabilities(tc.hello, tc.version); got != tc.want {
+			t.Errorf("%s: %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestProbeCapabilities(t *testing.T) {
+	// an unreachable server is assumed to support every feature
+	var rec logRecorder
+
+	disabled := false
+	caps := probeCapabilities(CompatMongoDB, &disabled, newConnHolder(unreachableDatabase(t)), &rec)
+
+	if caps.Probed || disabled || len(rec.named("transactions disabled")) != 0 {
+		t.Fatalf("unreachable server %+v, transactions disabled %v", caps, disabled)
+	}
+
+	// a server without transactions disables them with a notice
+	db := testDatabase(t)
+
+	caps = probeCapabilities(CompatMongoDB, &disabled, newConnHolder(db), &rec)
+
+	if !caps.Probed {
+		t.Fatal("server not probed")
+	}
+
+	if caps.Transactions == disabled {
+		t.Fatalf("transactions %v and disabled %v", caps.Transactions, disabled)
+	}
+
+	if !caps.Transactions && len(rec.named("transactions disabled")) != 1 {
+		t.Fatal("transactions disabled without a notice")
+	}
+
+	// the Cosmos DB mode runs without transactions anyway
+	rec = logRecorder{}
+	disabled = false
+
+	probeCapabilities(CompatCosmosDB, &disabled, newConnHolder(db), &rec)
+
+	if disabled || len(rec.named("transactions disabled")) != 0 {
+		t.Fatalf("Cosmos DB transactions disabled %v", disabled)
+	}
+}
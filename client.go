@@ -0,0 +1,328 @@
+package mongo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Client client information with the attributes the store keeps beyond oauth2.ClientInfo, returned by
+// GetByID. Set stores the attributes of any oauth2.ClientInfo implementing their getters(e.g. IsPublic).
+type Client struct {
+	models.Client
+	// public client(e.g. native or browser app) which can't keep a secret
+	Public bool
+	// RFC 7591 token endpoint authentication method(AuthMethodClientSecretBasic...), empty for none when Public and
+	// client_secret_basic otherwise
+	TokenEndpointAuthMethod string
+	// public keys verifying the private_key_jwt assertions of the client, a JWKS or the URI serving it
+	JWKS    json.RawMessage
+	JWKSURI string
+	// attributes of the deployment(owner team, plan, notes...), keys can't start with $ or contain dots
+	Metadata map[string]interface{}
+	// scopes the client may request, empty allows every scope
+	Scopes []string
+	// registered redirect URIs, the first one is also stored as the domain
+	RedirectURIs []string
+	// grant types(e.g. client_credentials) and response types(e.g. code) the client may use, see IsGrantAllowed
+	GrantTypes    []string
+	ResponseTypes []string
+	// origins allowed to call the token endpoint from a browser, see IsOriginAllowed
+	AllowedOrigins []string
+	// first and last write of the client, zero for clients stored before they were recorded
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// time the client was disabled, only listed with ListOptions.IncludeDisabled
+	DeletedAt time.Time
+	// RFC 7591 metadata of the client, nil for clients not registered through it. Its redirect URIs and
+	// scope, grant and response types are those of the client.
+	Registration *RegisteredClientMetadata
+	// changed by SetStatus only
+	Status ClientStatus
+	// expiry of the secret, zero when it never expires
+	SecretExpiresAt time.Time
+	// owner before the last TransferOwnership and its time, changed by TransferOwnership only
+	PreviousUserID string
+	TransferredAt  time.Time
+	// token lifetimes of the client(whole seconds), zero for the server default, see TokenTTLResolver
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// refresh token policy of the client, nil to follow the server configuration, see RefreshPolicyResolver
+	RefreshPolicy *RefreshPolicy
+	// token requests the client may make, nil for no limit, see SetRateLimit
+	RateLimit *RateLimit
+
+	// secret replaced by RotateSecret during its grace window, never exposed
+	previousSecret    string
+	previousExpiresAt time.Time
+}
+
+// VerifyPassword compare secret with the current secret, or the previous one during the grace window of
+// RotateSecret. A client without secret accepts any secret like the oauth2 manager does, while public and
+// private_key_jwt clients only accept the empty one: the token endpoint checks the assertion of the latter.
+func (c *Client) VerifyPassword(secret string) bool {
+	if c.IsPublic() || !usesSecret(c.GetTokenEndpointAuthMethod()) {
+		return secret == ""
+	}
+
+	if c.Secret == "" {
+		return true
+	}
+
+	if compareSecret(c.Secret, secret) {
+		return !c.secretExpired(time.Now())
+	}
+
+	return c.previousSecret != "" && time.Now().Before(c.previousExpiresAt) && compareSecret(c.previousSecret, secret)
+}
+
+// secretExpired report whether the secret expired at now
+func (c *Client) secretExpired(now time.Time) bool {
+	return !c.SecretExpiresAt.IsZero() && !now.Before(c.SecretExpiresAt)
+}
+
+// GetSecretExpiresAt the expiry of the secret, zero when it never expires
+func (c *Client) GetSecretExpiresAt() time.Time {
+	return c.SecretExpiresAt
+}
+
+// IsPublic report whether the client authenticates without secret
+func (c *Client) IsPublic() bool {
+	return c.Public || c.TokenEndpointAuthMethod == AuthMethodNone
+}
+
+// GetTokenEndpointAuthMethod the token endpoint authentication method of the client
+func (c *Client) GetTokenEndpointAuthMethod() string {
+	return effectiveAuthMethod(c.TokenEndpointAuthMethod, c.Public)
+}
+
+// GetJWKS the JWKS of the client, nil when it has none
+func (c *Client) GetJWKS() json.RawMessage {
+	return c.JWKS
+}
+
+// GetJWKSURI the URI serving the JWKS of the client
+func (c *Client) GetJWKSURI() string {
+	return c.JWKSURI
+}
+
+// GetMetadata the client metadata
+func (c *Client) GetMetadata() map[string]interface{} {
+	return c.Metadata
+}
+
+// GetScopes the scopes the client may request
+func (c *Client) GetScopes() []string {
+	return c.Scopes
+}
+
+// GetRedirectURIs the registered redirect URIs
+func (c *Client) GetRedirectURIs() []string {
+	return c.RedirectURIs
+}
+
+// GetGrantTypes the grant types the client may use
+func (c *Client) GetGrantTypes() []string {
+	return c.GrantTypes
+}
+
+// GetResponseTypes the response types the client may use
+func (c *Client) GetResponseTypes() []string {
+	return c.ResponseTypes
+}
+
+// GetAllowedOrigins the CORS origins of the client
+func (c *Client) GetAllowedOrigins() []string {
+	return c.AllowedOrigins
+}
+
+// GetAccessTokenTTL the access token lifetime of the client, zero for the server default
+func (c *Client) GetAccessTokenTTL() time.Duration {
+	return c.AccessTokenTTL
+}
+
+// GetRefreshTokenTTL the refresh token lifetime of the client, zero for the server default
+func (c *Client) GetRefreshTokenTTL() time.Duration {
+	return c.RefreshTokenTTL
+}
+
+// GetRefreshPolicy the refresh token policy of the client, nil to follow the server configuration
+func (c *Client) GetRefreshPolicy() *RefreshPolicy {
+	return c.RefreshPolicy
+}
+
+// GetRateLimit the rate limit of the client, nil for no limit
+func (c *Client) GetRateLimit() *RateLimit {
+	return c.RateLimit
+}
+
+// publicClient client information knowing whether it is public
+type publicClient interface {
+	IsPublic() bool
+}
+
+// metadataClient client information carrying metadata
+type metadataClient interface {
+	GetMetadata() map[string]interface{}
+}
+
+// scopedClient client information restricting its scopes
+type scopedClient interface {
+	GetScopes() []string
+}
+
+// redirectClient client information registering several redirect URIs
+type redirectClient interface {
+	GetRedirectURIs() []string
+}
+
+// grantClient client information restricting its grant and response types
+type grantClient interface {
+	GetGrantTypes() []string
+	GetResponseTypes() []string
+}
+
+// originClient client information allowing CORS origins
+type originClient interface {
+	GetAllowedOrigins() []string
+}
+
+// secretExpiryClient client information whose secret expires
+type secretExpiryClient interface {
+	GetSecretExpiresAt() time.Time
+}
+
+// ttlClient client information overriding the token lifetimes
+type ttlClient interface {
+	GetAccessTokenTTL() time.Duration
+	GetRefreshTokenTTL() time.Duration
+}
+
+// refreshPolicyClient client information with its own refresh token policy
+type refreshPolicyClient interface {
+	GetRefreshPolicy() *RefreshPolicy
+}
+
+// rateLimitedClient client information with a rate limit
+type rateLimitedClient interface {
+	GetRateLimit() *RateLimit
+}
+
+// isPublic report whether info describes a public client, unknown clients are confidential
+func isPublic(info oauth2.ClientInfo) bool {
+	p, ok := info.(publicClient)
+	return ok && p.IsPublic()
+}
+
+// validateMetadataKeys reject the empty keys and those mongo would interpret as operators or paths
+func validateMetadataKeys(meta map[string]interface{}) error {
+	for key := range meta {
+		if key == "" || strings.HasPrefix(key, "$") || strings.Contains(key, ".") {
+			return fmt.Errorf("%w: metadata key %q", ErrInvalidArgument, key)
+		}
+	}
+
+	return nil
+}
+
+// validateMetadata reject the keys mongo would interpret and metadata above the size limit
+func (cs *ClientStore) validateMetadata(meta map[string]interface{}) error {
+	if err := validateMetadataKeys(meta); err != nil {
+		return err
+	}
+
+	max := cs.ccfg.MaxMetadataSize
+
+	if max <= 0 {
+		max = 16 << 10
+	}
+
+	data, err := bson.Marshal(meta)
+
+	if err != nil {
+		return fmt.Errorf("%w: metadata: %v", ErrInvalidArgument, err)
+	}
+
+	if len(data) > max {
+		return fmt.Errorf("%w: metadata of %d bytes exceeds %d", ErrInvalidArgument, len(data), max)
+	}
+
+	return nil
+}
+
+// asClient the Client of the information returned by the store, nil for other client information
+func asClient(info oauth2.ClientInfo) *Client {
+	switch c := info.(type) {
+	case *Client:
+		return c
+	case *HashedClient:
+		return &c.Client
+	}
+
+	return nil
+}
+
+// toClient a copy of the client information with the attributes it implements
+func toClient(info oauth2.ClientInfo) *Client {
+	if c := asClient(info); c != nil {
+		cp := *c
+		return &cp
+	}
+
+	c := &Client{
+		Client: models.Client{
+			ID:     info.GetID(),
+			Secret: info.GetSecret(),
+			Domain: info.GetDomain(),
+			UserID: info.GetUserID(),
+		},
+		Public: isPublic(info),
+	}
+
+	if m, ok := info.(metadataClient); ok {
+		c.Metadata = m.GetMetadata()
+	}
+
+	if sc, ok := info.(scopedClient); ok {
+		c.Scopes = sc.GetScopes()
+	}
+
+	if rc, ok := info.(redirectClient); ok {
+		c.RedirectURIs = rc.GetRedirectURIs()
+	}
+
+	if rc, ok := info.(registeredClient); ok {
+		c.Registration = rc.GetRegistration()
+	}
+
+	if gc, ok := info.(grantClient); ok {
+		c.GrantTypes, c.ResponseTypes = gc.GetGrantTypes(), gc.GetResponseTypes()
+	}
+
+	if oc, ok := info.(originClient); ok {
+		c.AllowedOrigins = oc.GetAllowedOrigins()
+	}
+
+	if ec, ok := info.(secretExpiryClient); ok {
+		c.SecretExpiresAt = ec.GetSecretExpiresAt()
+	}
+
+	if rc, ok := info.(refreshPolicyClient); ok {
+		c.RefreshPolicy = rc.GetRefreshPolicy()
+	}
+
+	if rc, ok := info.(rateLimitedClient); ok {
+		c.RateLimit = rc.GetRateLimit()
+	}
+
+	if tc, ok := info.(ttlClient); ok {
+		c.AccessTokenTTL, c.RefreshTokenTTL = tc.GetAccessTokenTTL(), tc.GetRefreshTokenTTL()
+	}
+
+	return c
+}
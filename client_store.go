@@ -2,149 +2,1213 @@ package mongo
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/go-oauth2/oauth2/v4"
 	"github.com/go-oauth2/oauth2/v4/models"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // ClientConfig client configuration parameters
 type ClientConfig struct {
 	// store clients data collection name(The default is oauth2_clients)
 	ClientsCName string
+	// read preference used by GetByID(The default is the client's read preference)
+	ReadPreference *readpref.ReadPref
+	// read concern used by GetByID(The default is the client's read concern)
+	ReadConcern *readconcern.ReadConcern
+	// circuit breaker guarding the mongo operations(The default is no breaker)
+	Breaker *Breaker
+	// logger receiving the store records(The default discards them)
+	Logger Logger
+	// metrics receiving the outcome of every operation(The default discards them)
+	Metrics Metrics
+	// tracer starting a span around every operation(The default is no tracing)
+	Tracer Tracer
+	// operations taking longer are logged as slow operations(The default 0 disables it)
+	SlowOpThreshold time.Duration
+	// timeout of the lookups, WithReadTimeout(0) adds no timeout beyond the caller's context(The default is 15s)
+	ReadTimeout time.Duration
+	// timeout of the writes and their transaction, WithWriteTimeout(0) adds no timeout beyond the caller's
+	// context(The default is 15s)
+	WriteTimeout time.Duration
+	// store the client secrets as bcrypt hashes, see VerifySecret(The default is false)
+	HashSecrets bool
+	// bcrypt cost of the secret hashes(The default is bcrypt.DefaultCost)
+	SecretHashCost int
+	// reversible encryption of the client secrets, documents stored before it was set remain readable(The default is no encryption)
+	Encrypter Encrypter
+	// key of the HMAC pseudonyms stored instead of the user ids, GetByID then returns the pseudonym
+	// as the user id(The default is none, the user ids are stored as given)
+	UserIDKey []byte
+	// database and collection prefix of the tenant of each operation, one store then serves every
+	// tenant(The default is the store's database without prefix)
+	TenantResolver TenantResolver
+	// BSON field names of the documents(The default is LegacyFieldNames)
+	FieldNames FieldNames
+	// reject two clients with the same non-empty domain through a unique index. Switching an existing
+	// collection requires dropping its domain_1 index and resolving the duplicates first(The default is false)
+	UniqueDomains bool
+	// reject two clients of the same non-empty user id through a unique index(The default is false)
+	UniqueUserIDs bool
+	// prefix of the client IDs generated by CreateClient(The default is none)
+	ClientIDPrefix string
+	// random characters of the generated client IDs after the prefix(The default is 24)
+	ClientIDLength int
+	// length of the secrets generated by RegenerateSecret and CreateClient(The default is 32)
+	SecretLength int
+	// characters of the generated secrets(The default is the ASCII letters and digits)
+	SecretAlphabet string
+	// how long RegenerateSecret keeps the previous secret valid(The default 0 revokes it at once)
+	SecretRotationGrace time.Duration
+	// make the lookups of disabled and suspended clients return ErrClientNotFound rather than ErrClientDisabled
+	HideDisabledClients bool
+	// deny every grant to the clients without grant types rather than allowing them all(see IsGrantAllowed)
+	StrictGrantTypes bool
+	// client IDs accepted by Set, Update and CreateClient(The default accepts any non-empty ID)
+	ClientIDPattern *regexp.Regexp
+	// shortest plaintext secret accepted by the writes(The default 0 accepts any)
+	MinSecretLength int
+	// require https for the domains and redirect URIs(but on loopback hosts), and the client, logo, policy and terms
+	// of service URIs of the registrations
+	RequireHTTPSURIs bool
+	// give the client a new registration access token on each UpdateRegistration
+	RotateRegistrationToken bool
+	// lifetime of the secrets set by RotateSecret, RegenerateSecret and CreateClient(The default 0 never expires them)
+	SecretLifetime time.Duration
+	// most clients a user may own, enforced when a write gives a client to a user(The default 0 is unlimited)
+	MaxClientsPerUser int
+	// collection of the per-user lock documents serializing the quota checks(The default is oauth2_client_quotas)
+	QuotaCName string
+	// collection recording the changes of the clients(The default empty records none), see AuditForClient
+	AuditCName string
+	// fail the writes whose audit record fails, recording it in their transaction(The default logs the failure)
+	StrictAudit bool
+	// how long PurgeDisabled keeps the disabled clients(The default is 90 days)
+	DisabledRetention time.Duration
+	// largest page returned by List(The default is 100)
+	MaxPageSize int
+	// largest BSON size of the client metadata(The default is 16KB)
+	MaxMetadataSize int
+	// largest JWKS of the private_key_jwt clients(The default is 64KB)
+	MaxJWKSSize int
+	// largest software statement of the registrations(The default is 16KB)
+	MaxSoftwareStatementSize int
+	// run the writes without session transactions, e.g. on standalone servers(The default is false)
+	DisableTransactions bool
+	// the MongoDB compatible service, adjusting transactions to it(The default is CompatAuto)
+	Compatibility CompatibilityMode
+	// collation of the client ID lookups, served by an index of the IDs with the collation. The simple
+	// locale compares the IDs byte by byte whatever the collection default collation(The default is the
+	// collection default collation)
+	Collation *options.Collation
+	// find the clients whatever the case of their ID, new IDs only differing by case are then rejected.
+	// Collation takes precedence(The default is false)
+	CaseInsensitiveClientIDs bool
+	// codec registry decoding the client metadata, set it to the registry of the handle given to
+	// NewClientStoreWithDB(The default is Config.Registry with NewClientStore/NewStore, else bson.DefaultRegistry)
+	Registry *bsoncodec.Registry
 }
 
 // ClientStore MongoDB storage for OAuth 2.0
 type ClientStore struct {
-	ccfg   *ClientConfig
-	dbName string
-	client *mongo.Client
+	ccfg    *ClientConfig
+	conns   *connHolder
+	tracker tracker
+	caps    Capabilities
+	// the mongo client belongs to a Store
+	shared bool
 }
 
 type client struct {
-	ID     string `bson:"_id"`
-	Secret string `bson:"secret"`
-	Domain string `bson:"domain"`
-	UserID string `bson:"userid"`
+	ID     string
+	Secret string
+	// key which encrypted the secret, empty for plaintext secrets
+	KeyID    string
+	Domain   string
+	UserID   string
+	Public   bool
+	Metadata map[string]interface{}
+	Scopes   []string
+	// empty for the documents stored before it, see effectiveAuthMethod
+	AuthMethod string
+	// public keys of the private_key_jwt clients
+	JWKS    json.RawMessage
+	JWKSURI string
+	// first one mirrored in Domain
+	RedirectURIs  []string
+	GrantTypes    []string
+	ResponseTypes []string
+	// normalized CORS origins
+	AllowedOrigins []string
+	// maintained by the writes, zero for documents stored before
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// set by Disable
+	DeletedAt time.Time
+	// secret replaced by RotateSecret, accepted until PreviousExpiresAt
+	PreviousSecret    string
+	PreviousKeyID     string
+	PreviousExpiresAt time.Time
+	// RFC 7591 metadata, without the fields stored with the client
+	Registration *RegisteredClientMetadata
+	Status       ClientStatus
+	// zero for a secret which never expires
+	SecretExpiresAt time.Time
+	// owner before the last TransferOwnership
+	PreviousUserID string
+	TransferredAt  time.Time
+	// token lifetimes of the client, zero for the server default
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	RefreshPolicy   *RefreshPolicy
+	RateLimit       *RateLimit
+}
+
+// field names of the client attributes beyond oauth2.ClientInfo
+const (
+	clientPublicField   = "public"
+	clientMetadataField = "metadata"
+	clientScopesField   = "scopes"
+	clientRedirectField = "redirect_uris"
+	clientGrantsField   = "grant_types"
+	clientResponseField = "response_types"
+	clientCreatedField  = "created_at"
+	clientUpdatedField  = "updated_at"
+	clientDeletedField  = "deleted_at"
+
+	clientSecretExpiresField = "secret_expires_at"
+
+	// set by TransferOwnership, kept by Set
+	clientPreviousOwnerField = "previous_userid"
+	clientTransferredAtField = "transferred_at"
+
+	clientRegistrationField = "registration"
+
+	// token lifetimes in seconds
+	clientAccessTTLField  = "access_token_ttl"
+	clientRefreshTTLField = "refresh_token_ttl"
+
+	clientPreviousSecretField  = "previous_secret"
+	clientPreviousKeyIDField   = "previous_key_id"
+	clientPreviousExpiresField = "previous_expires_at"
+)
+
+// optionalFields the fields a client document only has for some clients, removed when Set replaces it without them.
+// The deleted_at of a disabled client isn't one of them: Set keeps the client disabled until Restore.
+func (cs *ClientStore) optionalFields() []string {
+	fn := cs.fields()
+
+	return append(aliases(fn.KeyID, func(f FieldNames) string { return f.KeyID }),
+		clientPublicField, clientMetadataField, clientScopesField, clientRedirectField,
+		clientPreviousSecretField, clientPreviousKeyIDField, clientPreviousExpiresField, clientRegistrationField,
+		clientAccessTTLField, clientRefreshTTLField, clientGrantsField, clientResponseField,
+		clientSecretExpiresField, clientOriginsField, clientRefreshPolicyField,
+		clientRateLimitField, clientAuthMethodField, clientJWKSField, clientJWKSURIField)
+}
+
+func (c *client) doc(fn FieldNames) bson.D {
+	doc := bson.D{
+		{Key: "_id", Value: c.ID},
+		{Key: fn.Secret, Value: c.Secret},
+		{Key: fn.Domain, Value: c.Domain},
+		{Key: fn.UserID, Value: c.UserID},
+	}
+
+	if c.KeyID != "" {
+		doc = append(doc, bson.E{Key: fn.KeyID, Value: c.KeyID})
+	}
+
+	if c.Public {
+		doc = append(doc, bson.E{Key: clientPublicField, Value: true})
+	}
+
+	if c.AuthMethod != "" {
+		doc = append(doc, bson.E{Key: clientAuthMethodField, Value: c.AuthMethod})
+	}
+
+	if len(c.JWKS) > 0 {
+		doc = append(doc, bson.E{Key: clientJWKSField, Value: string(c.JWKS)})
+	}
+
+	if c.JWKSURI != "" {
+		doc = append(doc, bson.E{Key: clientJWKSURIField, Value: c.JWKSURI})
+	}
+
+	if len(c.Metadata) > 0 {
+		doc = append(doc, bson.E{Key: clientMetadataField, Value: c.Metadata})
+	}
+
+	if len(c.Scopes) > 0 {
+		doc = append(doc, bson.E{Key: clientScopesField, Value: c.Scopes})
+	}
+
+	if len(c.RedirectURIs) > 0 {
+		doc = append(doc, bson.E{Key: clientRedirectField, Value: c.RedirectURIs})
+	}
+
+	if len(c.GrantTypes) > 0 {
+		doc = append(doc, bson.E{Key: clientGrantsField, Value: c.GrantTypes})
+	}
+
+	if len(c.ResponseTypes) > 0 {
+		doc = append(doc, bson.E{Key: clientResponseField, Value: c.ResponseTypes})
+	}
+
+	if len(c.AllowedOrigins) > 0 {
+		doc = append(doc, bson.E{Key: clientOriginsField, Value: c.AllowedOrigins})
+	}
+
+	if c.Registration != nil {
+		doc = append(doc, bson.E{Key: clientRegistrationField, Value: c.Registration})
+	}
+
+	if !c.SecretExpiresAt.IsZero() {
+		doc = append(doc, bson.E{Key: clientSecretExpiresField, Value: c.SecretExpiresAt})
+	}
+
+	if c.RefreshPolicy != nil {
+		doc = append(doc, bson.E{Key: clientRefreshPolicyField, Value: c.RefreshPolicy})
+	}
+
+	if c.RateLimit != nil {
+		doc = append(doc, bson.E{Key: clientRateLimitField, Value: c.RateLimit.doc()})
+	}
+
+	if c.AccessTokenTTL > 0 {
+		doc = append(doc, bson.E{Key: clientAccessTTLField, Value: int64(c.AccessTokenTTL / time.Second)})
+	}
+
+	if c.RefreshTokenTTL > 0 {
+		doc = append(doc, bson.E{Key: clientRefreshTTLField, Value: int64(c.RefreshTokenTTL / time.Second)})
+	}
+
+	return doc
+}
+
+func decodeClient(raw bson.Raw, fn FieldNames) *client {
+	entity := &client{
+		ID:       lookupString(raw, []string{"_id"}),
+		Secret:   lookupString(raw, aliases(fn.Secret, func(f FieldNames) string { return f.Secret })),
+		KeyID:    lookupString(raw, aliases(fn.KeyID, func(f FieldNames) string { return f.KeyID })),
+		Domain:   lookupString(raw, aliases(fn.Domain, func(f FieldNames) string { return f.Domain })),
+		UserID:   lookupString(raw, aliases(fn.UserID, func(f FieldNames) string { return f.UserID })),
+		Public:   lookupBool(raw, []string{clientPublicField}),
+		Metadata: lookupMap(raw, []string{clientMetadataField}),
+		Scopes:   lookupStrings(raw, []string{clientScopesField}),
+
+		AuthMethod: decodeAuthMethod(raw),
+		JWKS:       decodeJWKS(raw),
+		JWKSURI:    decodeJWKSURI(raw),
+
+		RedirectURIs:  lookupStrings(raw, []string{clientRedirectField}),
+		GrantTypes:    lookupStrings(raw, []string{clientGrantsField}),
+		ResponseTypes: lookupStrings(raw, []string{clientResponseField}),
+
+		AllowedOrigins: lookupStrings(raw, []string{clientOriginsField}),
+
+		CreatedAt: lookupTime(raw, []string{clientCreatedField}),
+		UpdatedAt: lookupTime(raw, []string{clientUpdatedField}),
+		DeletedAt: lookupTime(raw, []string{clientDeletedField}),
+
+		PreviousSecret:    lookupString(raw, []string{clientPreviousSecretField}),
+		PreviousKeyID:     lookupString(raw, []string{clientPreviousKeyIDField}),
+		PreviousExpiresAt: lookupTime(raw, []string{clientPreviousExpiresField}),
+
+		SecretExpiresAt: lookupTime(raw, []string{clientSecretExpiresField}),
+		PreviousUserID:  lookupString(raw, []string{clientPreviousOwnerField}),
+		TransferredAt:   lookupTime(raw, []string{clientTransferredAtField}),
+
+		AccessTokenTTL:  time.Duration(lookupInt64(raw, []string{clientAccessTTLField})) * time.Second,
+		RefreshTokenTTL: time.Duration(lookupInt64(raw, []string{clientRefreshTTLField})) * time.Second,
+	}
+
+	entity.Registration = decodeRegistration(raw, entity)
+	entity.Status = decodeStatus(raw)
+	entity.RefreshPolicy = decodeRefreshPolicy(raw)
+	entity.RateLimit = decodeRateLimit(raw)
+
+	return entity
 }
 
 // NewDefaultClientConfig create a default client configuration
 func NewDefaultClientConfig() *ClientConfig {
 	return &ClientConfig{
-		ClientsCName: "oauth2_clients",
+		ClientsCName:    "oauth2_clients",
+		QuotaCName:      "oauth2_client_quotas",
+		MaxPageSize:     100,
+		MaxMetadataSize: 16 << 10,
+		MaxJWKSSize:     64 << 10,
+
+		MaxSoftwareStatementSize: 16 << 10,
+
+		ClientIDLength:    24,
+		SecretLength:      32,
+		SecretAlphabet:    defaultSecretAlphabet,
+		DisabledRetention: 90 * 24 * time.Hour,
+
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
 	}
 }
 
 // NewClientStore create a client store instance based on mongodb
-func NewClientStore(cfg *Config, ccfgs ...*ClientConfig) *ClientStore {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func NewClientStore(cfg *Config, opts ...ClientOption) *ClientStore {
+	if err := cfg.Validate(); err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.connectTimeout())
 
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URL))
+	client, err := mongo.Connect(ctx, cfg.BuildClientOptions())
 
 	if err != nil {
 		panic(err)
 	}
 
-	return NewClientStoreWithSession(client, cfg.DB, ccfgs...)
+	return NewClientStoreWithSession(client, cfg.DB, append([]ClientOption{compatibilityOption(cfg), registryOption(cfg)}, opts...)...)
+}
+
+// NewClientStoreWithSession create a client store instance based on mongodb,
+// monitoring(pool, server and command events) of the given client is the caller's responsibility
+func NewClientStoreWithSession(client *mongo.Client, dbName string, opts ...ClientOption) *ClientStore {
+	return NewClientStoreWithDB(client.Database(dbName), opts...)
+}
+
+// NewClientStoreWithDB create a client store instance using the given database handle,
+// all collections inherit its read/write concerns, read preference and codec registry
+func NewClientStoreWithDB(db *mongo.Database, opts ...ClientOption) *ClientStore {
+	return newClientStore(newConnHolder(db), false, opts)
 }
 
-// NewClientStoreWithSession create a client store instance based on mongodb
-func NewClientStoreWithSession(client *mongo.Client, dbName string, ccfgs ...*ClientConfig) *ClientStore {
+// newClientStore create a client store on top of conns, a shared store leaves the
+// disconnection to its owner
+func newClientStore(conns *connHolder, shared bool, opts []ClientOption) *ClientStore {
 	cs := &ClientStore{
-		dbName: dbName,
-		client: client,
-		ccfg:   NewDefaultClientConfig(),
+		conns:  conns,
+		shared: shared,
+		ccfg:   newClientConfig(opts),
 	}
 
-	if len(ccfgs) > 0 {
-		cs.ccfg = ccfgs[0]
+	cs.caps = probeCapabilities(cs.ccfg.Compatibility, &cs.ccfg.DisableTransactions, conns, cs.logger())
+
+	// a routed store has no database of its own, EnsureIndexes runs per tenant
+	if cs.ccfg.TenantResolver == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+
+		defer cancel()
+
+		cs.ensureIndexes(ctx, routedDB{Database: conns.database()})
 	}
 
 	return cs
 }
 
-// Close close the mongo session
+// EnsureIndexes create the indexes of the clients collection, the store creates them at construction
+// unless a TenantResolver is configured: call it then for every tenant with a context resolving to it
+func (cs *ClientStore) EnsureIndexes(ctx context.Context) error {
+	db, err := route(ctx, cs.conns, cs.ccfg.TenantResolver)
+
+	if err != nil {
+		return err
+	}
+
+	return cs.ensureIndexes(ctx, db)
+}
+
+// indexes the lookup indexes of the clients collection
+func (cs *ClientStore) indexes() []mongo.IndexModel {
+	fn := cs.fields()
+
+	domain := mongo.IndexModel{Keys: bson.D{{Key: fn.Domain, Value: 1}}, Options: options.Index().SetName(fn.Domain + "_1")}
+
+	if cs.ccfg.UniqueDomains {
+		domain = uniqueIndex(fn.Domain)
+	}
+
+	models := []mongo.IndexModel{
+		domain,
+		{Keys: bson.D{{Key: fn.UserID, Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetName(fn.UserID + "_1__id_1")},
+	}
+
+	if cs.ccfg.UniqueUserIDs {
+		models = append(models, uniqueIndex(fn.UserID))
+	}
+
+	// scanned by Search instead of the documents
+	models = append(models, mongo.IndexModel{
+		Keys:    bson.D{{Key: clientNameField, Value: 1}},
+		Options: options.Index().SetName(clientNameField + "_1"),
+	})
+
+	models = append(models, mongo.IndexModel{
+		Keys:    bson.D{{Key: clientSoftwareIDField, Value: 1}},
+		Options: options.Index().SetName(clientSoftwareIDField + "_1"),
+	})
+
+	if model, ok := cs.collationIndex(); ok {
+		models = append(models, model)
+	}
+
+	return models
+}
+
+// ensureIndexes create the indexes of the clients collection, returning the first failure
+func (cs *ClientStore) ensureIndexes(ctx context.Context, db routedDB) (err error) {
+	name := cs.ccfg.ClientsCName
+
+	for _, model := range cs.indexes() {
+		_, cerr := db.Collection(name).Indexes().CreateOne(ctx, model)
+
+		if cerr != nil {
+			cerr = indexError(model, cerr)
+
+			cs.logger().Log(ctx, LogWarn, "index creation failed", map[string]interface{}{
+				"collection": db.prefix + name,
+				"error":      cerr.Error(),
+			})
+
+			if err == nil {
+				err = cerr
+			}
+
+			continue
+		}
+
+		cs.logger().Log(ctx, LogInfo, "index ensured", map[string]interface{}{
+			"collection": db.prefix + name,
+			"index":      *model.Options.Name,
+		})
+	}
+
+	if cerr := cs.checkCollation(ctx, db); cerr != nil {
+		cs.logger().Log(ctx, LogWarn, "collation mismatch", map[string]interface{}{
+			"collection": db.prefix + name,
+			"error":      cerr.Error(),
+		})
+
+		if err == nil {
+			err = cerr
+		}
+	}
+
+	if aerr := cs.ensureAuditIndex(ctx, db); err == nil {
+		err = aerr
+	}
+
+	if qerr := cs.ensureQuotaCollection(ctx, db); err == nil {
+		err = qerr
+	}
+
+	return err
+}
+
+// Close wait up to 15 seconds for the in-flight operations and close the mongo session
 func (cs *ClientStore) Close() {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
-	cs.client.Disconnect(ctx)
+
+	if err := cs.Shutdown(ctx); err != nil {
+		cs.logger().Log(ctx, LogWarn, "close failed", map[string]interface{}{"error": err.Error()})
+	}
+}
+
+// Reconnect connect a new mongo client with opts(e.g. rotated credentials) and swap it in,
+// the previous client is disconnected once the operations using it have finished
+func (cs *ClientStore) Reconnect(ctx context.Context, opts *options.ClientOptions) error {
+	err := cs.conns.reconnect(ctx, opts)
+
+	if err == nil {
+		cs.logger().Log(ctx, LogInfo, "mongo client reconnected", nil)
+	}
+
+	return err
+}
+
+// Shutdown stop accepting new operations, wait for the in-flight ones until ctx is done
+// and close the mongo session, operations started afterwards fail with ErrStoreClosed
+func (cs *ClientStore) Shutdown(ctx context.Context) error {
+	err := cs.tracker.drain(ctx)
+
+	if cs.shared {
+		return err
+	}
+
+	if derr := cs.conns.client().Disconnect(ctx); err == nil {
+		err = derr
+	}
+
+	return err
+}
+
+// Client the current mongo client of the store
+func (cs *ClientStore) Client() *mongo.Client {
+	return cs.conns.client()
+}
+
+// Database the current database handle of the store
+func (cs *ClientStore) Database() *mongo.Database {
+	return cs.conns.database()
+}
+
+// Collection the clients collection as configured for the store
+func (cs *ClientStore) Collection() *mongo.Collection {
+	return cs.col(cs.ccfg.ClientsCName)
+}
+
+// Ping check the connection to the mongo server, honoring the deadline of ctx
+func (cs *ClientStore) Ping(ctx context.Context) error {
+	return ping(ctx, cs.conns.client(), cs.ccfg.ReadPreference)
+}
+
+// Healthy ping the mongo server and verify the clients collection exists
+func (cs *ClientStore) Healthy(ctx context.Context) HealthReport {
+	return checkHealth(ctx, cs.conns.database(), cs.ccfg.ReadPreference, cs.expectedIndexes())
+}
+
+// Preflight verify the connectivity, the read/write permissions of the clients collection
+// and the transaction support of the deployment, meant to be run once at startup
+func (cs *ClientStore) Preflight(ctx context.Context) (PreflightReport, error) {
+	return preflight(ctx, cs.conns.database(), cs.expectedIndexes())
+}
+
+// expectedIndexes index names expected on the clients collection
+func (cs *ClientStore) expectedIndexes() map[string][]string {
+	var names []string
+
+	for _, model := range cs.indexes() {
+		names = append(names, *model.Options.Name)
+	}
+
+	expected := map[string][]string{
+		cs.ccfg.ClientsCName: names,
+	}
+
+	if cs.ccfg.AuditCName != "" {
+		expected[cs.ccfg.AuditCName] = []string{*auditIndex().Options.Name}
+	}
+
+	return expected
+}
+
+// fields the effective field names of the documents
+func (cs *ClientStore) fields() FieldNames {
+	return cs.ccfg.FieldNames.withDefaults()
+}
+
+func (cs *ClientStore) logger() Logger {
+	if cs.ccfg.Logger == nil {
+		return nopLogger{}
+	}
+
+	return cs.ccfg.Logger
+}
+
+func (cs *ClientStore) metrics() Metrics {
+	if cs.ccfg.Metrics == nil {
+		return nopMetrics{}
+	}
+
+	return cs.ccfg.Metrics
+}
+
+// breaker run fn under the circuit breaker, the transitions are reported through the hooks of the store
+func (cs *ClientStore) breaker(ctx context.Context, fn func() error) error {
+	return cs.ccfg.Breaker.do(fn, breakerObserver(ctx, cs.logger(), cs.metrics()))
+}
+
+func (cs *ClientStore) op(name, cname string) *operation {
+	return newOperation("client", name, cname)
+}
+
+// run execute a public operation with the configured hooks
+func (cs *ClientStore) run(ctx context.Context, o *operation, fn func(context.Context) error) error {
+	ob := observer{
+		tracker:         &cs.tracker,
+		conns:           cs.conns,
+		logger:          cs.logger(),
+		metrics:         cs.metrics(),
+		tracer:          cs.ccfg.Tracer,
+		slowOpThreshold: cs.ccfg.SlowOpThreshold,
+	}
+
+	return ob.run(ctx, o, fn)
 }
 
 func (cs *ClientStore) col(name string) *mongo.Collection {
-	return cs.client.Database(cs.dbName).Collection(name)
+	return cs.conns.database().Collection(name)
 }
 
-func (cs *ClientStore) colHandler(name string, fn func(context.Context, *mongo.Collection) error) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// readCol returns the collection of db with the configured read preference and read concern applied
+func (cs *ClientStore) readCol(db routedDB, name string) *mongo.Collection {
+	opts := options.Collection()
 
-	return cs.client.UseSession(ctx, func(session mongo.SessionContext) error {
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	if cs.ccfg.ReadPreference != nil {
+		opts.SetReadPreference(cs.ccfg.ReadPreference)
+	}
+
+	if cs.ccfg.ReadConcern != nil {
+		opts.SetReadConcern(cs.ccfg.ReadConcern)
+	}
+
+	return db.Collection(name, opts)
+}
+
+func (cs *ClientStore) readHandler(ctx context.Context, name string, fn func(context.Context, *mongo.Collection) error) error {
+	db, err := route(ctx, cs.conns, cs.ccfg.TenantResolver)
+
+	if err != nil {
+		return err
+	}
+
+	return cs.breaker(ctx, func() error {
+		ctx, cancel := withTimeout(ctx, cs.ccfg.ReadTimeout)
 
 		defer cancel()
 
-		session.StartTransaction()
+		return fn(ctx, cs.readCol(db, name))
+	})
+}
+
+func (cs *ClientStore) colHandler(ctx context.Context, name string, fn func(context.Context, *mongo.Collection) error) error {
+	db, err := route(ctx, cs.conns, cs.ccfg.TenantResolver)
+
+	if err != nil {
+		return err
+	}
+
+	return cs.breaker(ctx, func() error {
+		return transaction(ctx, db.Client(), cs.transactionsDisabled(), cs.ccfg.WriteTimeout, cs.logger(), func(ctx context.Context) error {
+			return fn(ctx, db.Collection(name))
+		})
+	})
+}
+
+// entity the client document of info, with the secret hashed and encrypted and the user id pseudonymized as configured
+func (cs *ClientStore) entity(o *operation, info oauth2.ClientInfo) (*client, error) {
+	secret, err := cs.hashSecret(info.GetSecret())
+
+	if err != nil {
+		return nil, err
+	}
+
+	o.sensitive(secret)
+
+	keyID, secret, err := cs.sealSecret(secret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entity := &client{
+		ID:     info.GetID(),
+		Secret: secret,
+		KeyID:  keyID,
+		Domain: info.GetDomain(),
+		UserID: cs.userID(info.GetUserID()),
+		Public: isPublic(info),
+	}
+
+	if _, ok := info.(authMethodClient); ok {
+		entity.AuthMethod = authMethod(info)
+	}
+
+	if kc, ok := info.(keysClient); ok {
+		entity.JWKS, entity.JWKSURI = kc.GetJWKS(), kc.GetJWKSURI()
+	}
+
+	if m, ok := info.(metadataClient); ok {
+		if err := cs.validateMetadata(m.GetMetadata()); err != nil {
+			return nil, err
+		}
+
+		entity.Metadata = m.GetMetadata()
+	}
+
+	if sc, ok := info.(scopedClient); ok {
+		entity.Scopes = sc.GetScopes()
+	}
+
+	if rc, ok := info.(redirectClient); ok {
+		entity.RedirectURIs = rc.GetRedirectURIs()
+
+		// the clients reading only the domain see the first redirect URI
+		if len(entity.RedirectURIs) > 0 {
+			entity.Domain = entity.RedirectURIs[0]
+		}
+	}
+
+	if gc, ok := info.(grantClient); ok {
+		entity.GrantTypes, entity.ResponseTypes = gc.GetGrantTypes(), gc.GetResponseTypes()
+	}
+
+	if oc, ok := info.(originClient); ok {
+		entity.AllowedOrigins = normalizeOrigins(oc.GetAllowedOrigins())
+	}
+
+	if entity.Registration, err = cs.registration(info); err != nil {
+		return nil, err
+	}
 
-		err := fn(ctx, cs.col(name))
+	if ec, ok := info.(secretExpiryClient); ok {
+		entity.SecretExpiresAt = ec.GetSecretExpiresAt()
+	}
+
+	if rc, ok := info.(refreshPolicyClient); ok {
+		entity.RefreshPolicy = rc.GetRefreshPolicy()
+	}
+
+	if rc, ok := info.(rateLimitedClient); ok {
+		entity.RateLimit = rc.GetRateLimit()
+	}
+
+	if tc, ok := info.(ttlClient); ok {
+		entity.AccessTokenTTL, entity.RefreshTokenTTL = tc.GetAccessTokenTTL(), tc.GetRefreshTokenTTL()
+	}
+
+	return entity, nil
+}
+
+// Set set client information, replacing the stored client with the same ID
+func (cs *ClientStore) Set(info oauth2.ClientInfo) error {
+	return cs.set(context.Background(), cs.op("Set", cs.ccfg.ClientsCName), info, nil)
+}
+
+// SetWithMetadata set client information together with its metadata, replacing the stored client with the same ID
+func (cs *ClientStore) SetWithMetadata(ctx context.Context, info oauth2.ClientInfo, meta map[string]interface{}) error {
+	return cs.set(ctx, cs.op("SetWithMetadata", cs.ccfg.ClientsCName), info, meta)
+}
+
+// set replace the stored client, meta(when given) takes precedence over the metadata of info
+func (cs *ClientStore) set(ctx context.Context, o *operation, info oauth2.ClientInfo, meta map[string]interface{}) error {
+	return cs.run(ctx, o, func(ctx context.Context) error {
+		if info == nil {
+			return fmt.Errorf("%w: nil client information", ErrInvalidArgument)
+		}
+
+		o.set("client_id", info.GetID())
+		o.sensitive(info.GetSecret())
+
+		if err := cs.validateClient(info, true); err != nil {
+			return err
+		}
+
+		entity, err := cs.entity(o, info)
 
 		if err != nil {
-			return session.AbortTransaction(ctx)
+			return err
 		}
 
-		return session.CommitTransaction(ctx)
+		if meta != nil {
+			if err := cs.validateMetadata(meta); err != nil {
+				return err
+			}
+
+			entity.Metadata = meta
+		}
+
+		update := cs.replacement(entity, time.Now())
+
+		replace := func() error {
+			return cs.retryQuota(func() error {
+				return cs.duplicateKey(cs.auditedHandler(ctx, o, entity.ID, func(ctx context.Context, c *mongo.Collection) error {
+					if err := cs.checkQuota(ctx, c, entity.ID, entity.UserID); err != nil {
+						return err
+					}
+
+					_, err := c.UpdateOne(ctx, bson.M{"_id": entity.ID}, update, cs.updateOptions().SetUpsert(true))
+					return err
+				}))
+			})
+		}
+
+		err = replace()
+
+		if dup, ok := err.(*DuplicateKeyError); ok && dup.Field == "_id" {
+			// a concurrent Set inserted the client first, the document now exists
+			err = replace()
+		}
+
+		return err
 	})
 }
 
-// Set set client information
-func (cs *ClientStore) Set(info oauth2.ClientInfo) error {
-	return cs.colHandler(cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) error {
-		entity := &client{
-			ID:     info.GetID(),
-			Secret: info.GetSecret(),
-			Domain: info.GetDomain(),
-			UserID: info.GetUserID(),
+// replacement the update replacing the stored client with entity, its creation time excepted
+func (cs *ClientStore) replacement(entity *client, now time.Time) bson.M {
+	set := bson.M{clientUpdatedField: now}
+
+	for _, e := range entity.doc(cs.fields())[1:] {
+		set[e.Key] = e.Value
+	}
+
+	unset := bson.M{}
+
+	for _, name := range cs.optionalFields() {
+		if _, ok := set[name]; !ok {
+			unset[name] = ""
+		}
+	}
+
+	update := bson.M{"$set": set, "$setOnInsert": bson.M{clientCreatedField: now}}
+
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	return update
+}
+
+// Update update the domain, user id and secret of an existing client, an empty secret keeps the stored one.
+// The attributes beyond oauth2.ClientInfo are only updated when info implements their getters.
+// A missing client returns ErrClientNotFound.
+func (cs *ClientStore) Update(ctx context.Context, info oauth2.ClientInfo) error {
+	o := cs.op("Update", cs.ccfg.ClientsCName)
+
+	return cs.run(ctx, o, func(ctx context.Context) error {
+		if info == nil {
+			return fmt.Errorf("%w: nil client information", ErrInvalidArgument)
 		}
 
-		_, err := c.InsertOne(ctx, entity)
+		o.set("client_id", info.GetID())
+		o.sensitive(info.GetSecret())
+
+		// an empty secret keeps the stored one
+		if err := cs.validateClient(info, false); err != nil {
+			return err
+		}
+
+		entity, err := cs.entity(o, info)
+
+		if err != nil {
+			return err
+		}
+
+		fn := cs.fields()
+		set := bson.M{fn.Domain: entity.Domain, fn.UserID: entity.UserID, clientUpdatedField: time.Now()}
+		unset := bson.M{}
+		update := bson.M{"$set": set}
+
+		if _, ok := info.(publicClient); ok {
+			set[clientPublicField] = entity.Public
+		}
+
+		if _, ok := info.(authMethodClient); ok {
+			set[clientAuthMethodField] = entity.AuthMethod
+		}
+
+		if _, ok := info.(keysClient); ok {
+			if len(entity.JWKS) > 0 {
+				set[clientJWKSField] = string(entity.JWKS)
+			} else {
+				unset[clientJWKSField] = ""
+			}
+
+			if entity.JWKSURI != "" {
+				set[clientJWKSURIField] = entity.JWKSURI
+			} else {
+				unset[clientJWKSURIField] = ""
+			}
+		}
+
+		if _, ok := info.(metadataClient); ok {
+			set[clientMetadataField] = entity.Metadata
+		}
+
+		if _, ok := info.(scopedClient); ok {
+			set[clientScopesField] = entity.Scopes
+		}
+
+		if _, ok := info.(redirectClient); ok {
+			set[clientRedirectField] = entity.RedirectURIs
+		}
+
+		if _, ok := info.(grantClient); ok {
+			set[clientGrantsField] = entity.GrantTypes
+			set[clientResponseField] = entity.ResponseTypes
+		}
+
+		if _, ok := info.(originClient); ok {
+			set[clientOriginsField] = entity.AllowedOrigins
+		}
+
+		if _, ok := info.(registeredClient); ok {
+			set[clientRegistrationField] = entity.Registration
+		}
+
+		if _, ok := info.(refreshPolicyClient); ok {
+			if entity.RefreshPolicy == nil {
+				unset[clientRefreshPolicyField] = ""
+			} else {
+				set[clientRefreshPolicyField] = entity.RefreshPolicy
+			}
+		}
+
+		if _, ok := info.(rateLimitedClient); ok {
+			if entity.RateLimit == nil {
+				unset[clientRateLimitField] = ""
+			} else {
+				set[clientRateLimitField] = entity.RateLimit.doc()
+			}
+		}
+
+		if _, ok := info.(ttlClient); ok {
+			set[clientAccessTTLField] = int64(entity.AccessTokenTTL / time.Second)
+			set[clientRefreshTTLField] = int64(entity.RefreshTokenTTL / time.Second)
+		}
+
+		if _, ok := info.(secretExpiryClient); ok {
+			if entity.SecretExpiresAt.IsZero() {
+				unset[clientSecretExpiresField] = ""
+			} else {
+				set[clientSecretExpiresField] = entity.SecretExpiresAt
+			}
+		}
+
+		if entity.Secret != "" {
+			set[fn.Secret] = entity.Secret
+
+			// the key id of the previous secret must not outlive it
+			for _, name := range aliases(fn.KeyID, func(f FieldNames) string { return f.KeyID }) {
+				unset[name] = ""
+			}
+
+			if entity.KeyID != "" {
+				set[fn.KeyID] = entity.KeyID
+				delete(unset, fn.KeyID)
+			}
+		}
+
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		return cs.retryQuota(func() error {
+			return cs.duplicateKey(cs.auditedHandler(ctx, o, entity.ID, func(ctx context.Context, c *mongo.Collection) error {
+				if err := cs.checkQuota(ctx, c, entity.ID, entity.UserID); err != nil {
+					return err
+				}
+
+				res, err := c.UpdateOne(ctx, bson.M{"_id": entity.ID}, update, cs.updateOptions())
+
+				if err == nil && res.MatchedCount == 0 {
+					err = errClientNotFound
+				}
+
+				return err
+			}))
+		})
+	})
+}
+
+// GetByID according to the ID for the client information, a missing client returns ErrClientNotFound
+// and a disabled one ErrClientDisabled
+func (cs *ClientStore) GetByID(ctx context.Context, id string) (oauth2.ClientInfo, error) {
+	var info oauth2.ClientInfo
+
+	o := cs.op("GetByID", cs.ccfg.ClientsCName)
+	o.set("client_id", id)
+
+	err := cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", id); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, id, nil)
+
+		if err != nil {
+			return err
+		}
+
+		info, err = cs.clientInfo(o, raw)
 		return err
 	})
+
+	return info, err
 }
 
-// GetByID according to the ID for the client information
-func (cs *ClientStore) GetByID(_ context.Context, id string) (oauth2.ClientInfo, error) {
-	var info *models.Client
+// findClient the document of an active client, a disabled or suspended client returns ErrClientDisabled.
+// An inclusion projection must include the deleted_at and status fields.
+func (cs *ClientStore) findClient(ctx context.Context, id string, projection bson.M) (raw bson.Raw, err error) {
+	err = cs.readHandler(ctx, cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) error {
+		opts := cs.findOneOptions()
 
-	err := cs.colHandler(cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) error {
-		entity := new(client)
+		if projection != nil {
+			opts.SetProjection(projection)
+		}
+
+		raw, err = c.FindOne(ctx, bson.M{"_id": id}, opts).DecodeBytes()
 
-		err := c.FindOne(ctx, bson.M{"_id": id}).Decode(entity)
+		if err == mongo.ErrNoDocuments {
+			return errClientNotFound
+		}
 
 		if err != nil {
 			return err
 		}
 
-		info = &models.Client{
+		_, disabled := lookup(raw, []string{clientDeletedField})
+
+		if disabled || decodeStatus(raw) == ClientSuspended {
+			if cs.ccfg.HideDisabledClients {
+				return errClientNotFound
+			}
+
+			return errClientDisabled
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// metadata the client metadata decoded with ClientConfig.Registry, decoded the one of bson.DefaultRegistry
+func (cs *ClientStore) metadata(raw bson.Raw, decoded map[string]interface{}) map[string]interface{} {
+	if cs.ccfg.Registry == nil {
+		return decoded
+	}
+
+	v, ok := lookup(raw, []string{clientMetadataField})
+
+	if !ok {
+		return decoded
+	}
+
+	var m map[string]interface{}
+
+	if err := bson.UnmarshalWithRegistry(cs.ccfg.Registry, v.Value, &m); err != nil {
+		return decoded
+	}
+
+	return m
+}
+
+// clientInfo the client information of a stored document, with the secret decrypted
+func (cs *ClientStore) clientInfo(o *operation, raw bson.Raw) (oauth2.ClientInfo, error) {
+	entity := decodeClient(raw, cs.fields())
+	secret, err := cs.openSecret(entity.KeyID, entity.Secret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	o.sensitive(secret)
+
+	model := Client{
+		Client: models.Client{
 			ID:     entity.ID,
-			Secret: entity.Secret,
+			Secret: secret,
 			Domain: entity.Domain,
 			UserID: entity.UserID,
+		},
+		Public:   entity.Public,
+		Metadata: cs.metadata(raw, entity.Metadata),
+		Scopes:   entity.Scopes,
+
+		TokenEndpointAuthMethod: effectiveAuthMethod(entity.AuthMethod, entity.Public),
+		JWKS:                    entity.JWKS,
+		JWKSURI:                 entity.JWKSURI,
+
+		RedirectURIs:  entity.RedirectURIs,
+		GrantTypes:    entity.GrantTypes,
+		ResponseTypes: entity.ResponseTypes,
+
+		AllowedOrigins: entity.AllowedOrigins,
+
+		CreatedAt: entity.CreatedAt,
+		UpdatedAt: entity.UpdatedAt,
+		DeletedAt: entity.DeletedAt,
+
+		Registration: entity.Registration,
+		Status:       entity.Status,
+
+		SecretExpiresAt: entity.SecretExpiresAt,
+		PreviousUserID:  entity.PreviousUserID,
+		TransferredAt:   entity.TransferredAt,
+		AccessTokenTTL:  entity.AccessTokenTTL,
+		RefreshTokenTTL: entity.RefreshTokenTTL,
+		RefreshPolicy:   entity.RefreshPolicy,
+		RateLimit:       entity.RateLimit,
+	}
+
+	// the rotated secret is only kept for VerifyPassword during its grace window
+	if entity.PreviousSecret != "" && time.Now().Before(entity.PreviousExpiresAt) {
+		previous, err := cs.openSecret(entity.PreviousKeyID, entity.PreviousSecret)
+
+		if err != nil {
+			return nil, err
 		}
 
-		return nil
-	})
+		o.sensitive(previous)
 
-	return info, err
+		model.previousSecret = previous
+		model.previousExpiresAt = entity.PreviousExpiresAt
+	}
+
+	if cs.ccfg.HashSecrets {
+		return &HashedClient{Client: model}, nil
+	}
+
+	return &model, nil
 }
 
-// RemoveByID use the client id to delete the client information
+// RemoveByID use the client id to delete the client information, a missing client returns ErrClientNotFound
 func (cs *ClientStore) RemoveByID(id string) error {
-	return cs.colHandler(cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) error {
-		_, err := c.DeleteOne(ctx, bson.M{"_id": id})
-		return err
+	_, err := cs.remove(context.Background(), "RemoveByID", id, false)
+	return err
+}
+
+// RemoveClient delete the client and return the information it had, e.g. for an audit record.
+// A missing client returns ErrClientNotFound.
+func (cs *ClientStore) RemoveClient(ctx context.Context, id string) (oauth2.ClientInfo, error) {
+	return cs.remove(ctx, "RemoveClient", id, true)
+}
+
+// remove delete the client, returning its information when asked
+func (cs *ClientStore) remove(ctx context.Context, name, id string, returnInfo bool) (info oauth2.ClientInfo, err error) {
+	o := cs.op(name, cs.ccfg.ClientsCName)
+	o.set("client_id", id)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", id); err != nil {
+			return err
+		}
+
+		return cs.auditedHandler(ctx, o, id, func(ctx context.Context, c *mongo.Collection) error {
+			if !returnInfo {
+				res, err := c.DeleteOne(ctx, bson.M{"_id": id}, cs.deleteOptions())
+
+				if err == nil && res.DeletedCount == 0 {
+					err = errClientNotFound
+				}
+
+				return err
+			}
+
+			raw, err := c.FindOneAndDelete(ctx, bson.M{"_id": id}, options.FindOneAndDelete().SetCollation(cs.collation())).DecodeBytes()
+
+			if err == mongo.ErrNoDocuments {
+				return errClientNotFound
+			}
+
+			if err != nil {
+				return err
+			}
+
+			info, err = cs.clientInfo(o, raw)
+			return err
+		})
 	})
+
+	return
 }
@@ -0,0 +1,239 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestClientSet(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var before bson.M
+
+	if err := cs.Collection().FindOne(ctx, bson.M{"_id": "c"}).Decode(&before); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	// setting the same ID again overwrites rather than failing with a duplicate key
+	if err := cs.Set(&models.Client{ID: "c", Secret: "other", Domain: "https://example.org", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := cs.GetByID(ctx, "c")
+
+	if err != nil || info.GetSecret() != "other" || info.GetDomain() != "https://example.org" {
+		t.Fatalf("overwritten client %v: %v", info, err)
+	}
+
+	var after bson.M
+
+	if err := cs.Collection().FindOne(ctx, bson.M{"_id": "c"}).Decode(&after); err != nil {
+		t.Fatal(err)
+	}
+
+	// the creation time is kept, the update time moves
+	if after[clientCreatedField] != before[clientCreatedField] || after[clientUpdatedField] == before[clientUpdatedField] {
+		t.Fatalf("times before %v, after %v", before, after)
+	}
+
+	if n, err := cs.Collection().CountDocuments(ctx, bson.M{}); err != nil || n != 1 {
+		t.Fatalf("%d clients: %v", n, err)
+	}
+}
+
+func TestClientSetConcurrent(t *testing.T) {
+	cs := newTestClientStore(t)
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 10)
+
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			errs <- cs.Set(&models.Client{ID: "c", Secret: fmt.Sprintf("secret-%d", i), Domain: "https://example.com"})
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent Set: %v", err)
+		}
+	}
+
+	if n, err := cs.Collection().CountDocuments(context.Background(), bson.M{}); err != nil || n != 1 {
+		t.Fatalf("%d clients: %v", n, err)
+	}
+}
+
+func TestClientUpdate(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := cs.Update(ctx, &models.Client{ID: "missing", Domain: "https://example.com"}); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("update of a missing client: %v", err)
+	}
+
+	if err := cs.SetWithMetadata(ctx, &models.Client{ID: "c", Secret: "secret", Domain: "https://example.com", UserID: "u"}, map[string]interface{}{"name": "app"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the empty secret keeps the stored one
+	if err := cs.Update(ctx, &models.Client{ID: "c", Domain: "https://example.org", UserID: "v"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := cs.GetByID(ctx, "c")
+
+	if err != nil || info.GetSecret() != "secret" || info.GetDomain() != "https://example.org" || info.GetUserID() != "v" {
+		t.Fatalf("updated client %v: %v", info, err)
+	}
+
+	// the attributes beyond oauth2.ClientInfo are kept
+	if meta, ok := info.(*Client); !ok || meta.Metadata["name"] != "app" {
+		t.Fatalf("metadata of the updated client %+v", info)
+	}
+}
+
+func TestClientRemove(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"a", "b"} {
+		if err := cs.Set(&models.Client{ID: id, Secret: "secret-" + id, Domain: "https://example.com", UserID: "u"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cs.RemoveByID("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.RemoveByID("a"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("second removal: %v", err)
+	}
+
+	if err := cs.RemoveByID("never-registered"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("removal of a missing client: %v", err)
+	}
+
+	// the removed client is returned, e.g. for an audit record
+	info, err := cs.RemoveClient(ctx, "b")
+
+	if err != nil || info.GetID() != "b" || info.GetSecret() != "secret-b" || info.GetUserID() != "u" {
+		t.Fatalf("removed client %v: %v", info, err)
+	}
+
+	if info, err := cs.RemoveClient(ctx, "b"); !errors.Is(err, ErrClientNotFound) || info != nil {
+		t.Fatalf("second removal %v: %v", info, err)
+	}
+
+	if n, err := cs.Collection().CountDocuments(ctx, bson.M{}); err != nil || n != 0 {
+		t.Fatalf("%d clients left: %v", n, err)
+	}
+}
+
+func TestClientTimestamps(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	timestamps := func(id string) (time.Time, time.Time) {
+		t.Helper()
+
+		info, err := cs.GetByID(ctx, id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := info.(*Client)
+
+		return c.CreatedAt, c.UpdatedAt
+	}
+
+	start := time.Now().Add(-time.Second)
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	created, updated := timestamps("c")
+
+	if created.Before(start) || !created.Equal(updated) {
+		t.Fatalf("inserted at %v, updated at %v", created, updated)
+	}
+
+	// the upsert of an existing client and the update keep the creation time
+	for _, write := range []func() error{
+		func() error { return cs.Set(&models.Client{ID: "c", Secret: "other", Domain: "https://example.com"}) },
+		func() error { return cs.Update(ctx, &models.Client{ID: "c", Domain: "https://example.org"}) },
+	} {
+		time.Sleep(5 * time.Millisecond)
+
+		if err := write(); err != nil {
+			t.Fatal(err)
+		}
+
+		c, u := timestamps("c")
+
+		if !c.Equal(created) || !u.After(updated) {
+			t.Fatalf("created at %v then %v, updated at %v then %v", created, c, updated, u)
+		}
+
+		updated = u
+	}
+
+	// the clients stored before the timestamps read as zero times
+	if _, err := cs.Collection().InsertOne(ctx, bson.M{"_id": "legacy", "secret": "secret", "domain": "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, u := timestamps("legacy"); !c.IsZero() || !u.IsZero() {
+		t.Fatalf("legacy client created at %v, updated at %v", c, u)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if err := cs.Set(&models.Client{ID: "later", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// listed by creation and by update time, the clients without first
+	for sort, want := range map[ClientSort][]string{
+		SortByCreatedAt: {"legacy", "c", "later"},
+		SortByUpdatedAt: {"legacy", "c", "later"},
+	} {
+		if ids, _ := listAll(t, cs, ClientFilter{}, ListOptions{Sort: sort, Limit: 1}); !reflect.DeepEqual(ids, want) {
+			t.Errorf("sorted by %d: %v, want %v", sort, ids, want)
+		}
+	}
+
+	// an update moves the client to the end of the update order only
+	if err := cs.Update(ctx, &models.Client{ID: "legacy", Domain: "https://example.org"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids, _ := listAll(t, cs, ClientFilter{}, ListOptions{Sort: SortByUpdatedAt}); !reflect.DeepEqual(ids, []string{"c", "later", "legacy"}) {
+		t.Errorf("sorted by update time: %v", ids)
+	}
+}
@@ -0,0 +1,172 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// nativeApp client information of another package marking its public clients
+type nativeApp struct {
+	models.Client
+}
+
+func (*nativeApp) IsPublic() bool {
+	return true
+}
+
+func TestPublicClients(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name   string
+		set    func() error
+		public bool
+	}{
+		{"public", func() error {
+			return cs.Set(&Client{Client: models.Client{ID: "public", Domain: "https://example.com"}, Public: true})
+		}, true},
+		{"public of another type", func() error {
+			return cs.Set(&nativeApp{models.Client{ID: "native", Domain: "https://example.com"}})
+		}, true},
+		{"confidential", func() error {
+			return cs.Set(&models.Client{ID: "confidential", Secret: "secret", Domain: "https://example.com"})
+		}, false},
+	} {
+		if err := tc.set(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+	}
+
+	for id, public := range map[string]bool{"public": true, "native": true, "confidential": false} {
+		info, err := cs.GetByID(ctx, id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c, ok := info.(*Client); !ok || c.Public != public || c.IsPublic() != public {
+			t.Errorf("%s public %+v, want %v", id, info, public)
+		}
+	}
+
+	// a public client can't authenticate with a secret
+	if ok, err := cs.VerifySecret(ctx, "public", ""); ok || !errors.Is(err, ErrPublicClient) {
+		t.Fatalf("secret of a public client %v: %v", ok, err)
+	}
+
+	if ok, err := cs.VerifySecret(ctx, "confidential", "secret"); !ok || err != nil {
+		t.Fatalf("secret of a confidential client %v: %v", ok, err)
+	}
+
+	// the documents stored before the field default to confidential
+	if _, err := cs.Collection().InsertOne(ctx, bson.M{"_id": "legacy", "secret": "secret", "domain": "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := cs.GetByID(ctx, "legacy")
+
+	if c, ok := info.(*Client); err != nil || !ok || c.IsPublic() {
+		t.Fatalf("legacy client %+v: %v", info, err)
+	}
+
+	if ok, err := cs.VerifySecret(ctx, "legacy", "secret"); !ok || err != nil {
+		t.Fatalf("secret of a legacy client %v: %v", ok, err)
+	}
+}
+
+func TestClientMetadata(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.MaxMetadataSize = 256
+
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+
+	clients := map[string]map[string]interface{}{
+		"a": {"team": "payments", "plan": "gold"},
+		"b": {"team": "payments", "plan": "free"},
+		"c": {"team": "search", "notes": "migrated"},
+	}
+
+	for id, meta := range clients {
+		if err := cs.SetWithMetadata(ctx, &models.Client{ID: id, Secret: "secret", Domain: "https://example.com"}, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the metadata of a Client is stored by Set as well
+	if err := cs.Set(&Client{Client: models.Client{ID: "d", Secret: "secret", Domain: "https://example.com"}, Metadata: map[string]interface{}{"team": "search"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	clients["d"] = map[string]interface{}{"team": "search"}
+
+	for id, want := range clients {
+		info, err := cs.GetByID(ctx, id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := info.(*Client).GetMetadata(); !reflect.DeepEqual(got, want) {
+			t.Errorf("metadata of %s %v, want %v", id, got, want)
+		}
+	}
+
+	for _, tc := range []struct {
+		filter map[string]interface{}
+		want   []string
+	}{
+		{map[string]interface{}{"team": "payments"}, []string{"a", "b"}},
+		{map[string]interface{}{"team": "payments", "plan": "gold"}, []string{"a"}},
+		{map[string]interface{}{"notes": "migrated"}, []string{"c"}},
+		{map[string]interface{}{"team": "unknown"}, []string{}},
+	} {
+		if ids, _ := listAll(t, cs, ClientFilter{Metadata: tc.filter}, ListOptions{}); !reflect.DeepEqual(ids, tc.want) {
+			t.Errorf("clients of %v: %v, want %v", tc.filter, ids, tc.want)
+		}
+	}
+
+	// replacing the client without metadata clears it
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := cs.GetByID(ctx, "c"); err != nil || len(info.(*Client).GetMetadata()) != 0 {
+		t.Fatalf("metadata after the replacement %v: %v", info, err)
+	}
+}
+
+func TestClientMetadataValidation(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.MaxMetadataSize = 64
+
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+	info := &models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}
+
+	for name, meta := range map[string]map[string]interface{}{
+		"operator key": {"$set": "x"},
+		"dotted key":   {"a.b": "x"},
+		"empty key":    {"": "x"},
+		"too large":    {"notes": strings.Repeat("x", 64)},
+	} {
+		if err := cs.SetWithMetadata(ctx, info, meta); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if _, _, err := cs.List(ctx, ClientFilter{Metadata: map[string]interface{}{"$where": "1"}}, ListOptions{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("operator key in the filter: %v", err)
+	}
+
+	if _, err := cs.GetByID(ctx, "c"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("client stored with invalid metadata: %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package mongo
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Codec serialization of the token payload stored in the basic documents,
+// the name is stored on every document so payloads remain readable after a codec change
+type Codec interface {
+	Name() string
+	Marshal(oauth2.TokenInfo) ([]byte, error)
+	Unmarshal([]byte, oauth2.TokenInfo) error
+}
+
+// JSONCodec encoding/json payloads, the format of documents without a codec name
+type JSONCodec struct{}
+
+// Name codec identifier
+func (JSONCodec) Name() string { return "json" }
+
+// Marshal encode the token information
+func (JSONCodec) Marshal(info oauth2.TokenInfo) ([]byte, error) { return json.Marshal(info) }
+
+// Unmarshal decode into the token information
+func (JSONCodec) Unmarshal(data []byte, info oauth2.TokenInfo) error {
+	return json.Unmarshal(data, info)
+}
+
+// ExtJSONCodec canonical MongoDB extended JSON payloads, readable in any mongo tool.
+// Timestamps are stored with millisecond precision.
+type ExtJSONCodec struct{}
+
+// Name codec identifier
+func (ExtJSONCodec) Name() string { return "extjson" }
+
+// Marshal encode the token information
+func (ExtJSONCodec) Marshal(info oauth2.TokenInfo) ([]byte, error) {
+	return bson.MarshalExtJSON(info, true, false)
+}
+
+// Unmarshal decode into the token information
+func (ExtJSONCodec) Unmarshal(data []byte, info oauth2.TokenInfo) error {
+	return bson.UnmarshalExtJSON(data, true, info)
+}
+
+// BSONCodec structured payloads, stored as a subdocument of the basic documents so their fields can be
+// queried(e.g. Data.client_id with the legacy field names) and decoded into any TokenInfo. Timestamps are
+// stored with millisecond precision, the expiries as dates. Encrypted payloads remain binary.
+type BSONCodec struct{}
+
+// bsonPayload the fields of a structured payload
+type bsonPayload struct {
+	ClientID         string    `bson:"client_id,omitempty"`
+	UserID           string    `bson:"user_id,omitempty"`
+	RedirectURI      string    `bson:"redirect_uri,omitempty"`
+	Scope            string    `bson:"scope,omitempty"`
+	Code             string    `bson:"code,omitempty"`
+	CodeCreateAt     time.Time `bson:"code_created_at,omitempty"`
+	CodeExpiresAt    time.Time `bson:"code_expires_at,omitempty"`
+	Access           string    `bson:"access,omitempty"`
+	AccessCreateAt   time.Time `bson:"access_created_at,omitempty"`
+	AccessExpiresAt  time.Time `bson:"access_expires_at,omitempty"`
+	Refresh          string    `bson:"refresh,omitempty"`
+	RefreshCreateAt  time.Time `bson:"refresh_created_at,omitempty"`
+	RefreshExpiresAt time.Time `bson:"refresh_expires_at,omitempty"`
+}
+
+// expiresAt the expiry of a token, zero when it has no lifetime
+func expiresAt(createAt time.Time, expiresIn time.Duration) time.Time {
+	if expiresIn == 0 {
+		return time.Time{}
+	}
+
+	return createAt.Add(expiresIn)
+}
+
+// expiresIn the lifetime of a token from its stored expiry
+func expiresIn(createAt, expiresAt time.Time) time.Duration {
+	if expiresAt.IsZero() {
+		return 0
+	}
+
+	return expiresAt.Sub(createAt)
+}
+
+// Name codec identifier
+func (BSONCodec) Name() string { return "bson" }
+
+// Marshal encode the token information
+func (BSONCodec) Marshal(info oauth2.TokenInfo) ([]byte, error) {
+	return bson.Marshal(bsonPayload{
+		ClientID:         info.GetClientID(),
+		UserID:           info.GetUserID(),
+		RedirectURI:      info.GetRedirectURI(),
+		Scope:            info.GetScope(),
+		Code:             info.GetCode(),
+		CodeCreateAt:     info.GetCodeCreateAt(),
+		CodeExpiresAt:    expiresAt(info.GetCodeCreateAt(), info.GetCodeExpiresIn()),
+		Access:           info.GetAccess(),
+		AccessCreateAt:   info.GetAccessCreateAt(),
+		AccessExpiresAt:  expiresAt(info.GetAccessCreateAt(), info.GetAccessExpiresIn()),
+		Refresh:          info.GetRefresh(),
+		RefreshCreateAt:  info.GetRefreshCreateAt(),
+		RefreshExpiresAt: expiresAt(info.GetRefreshCreateAt(), info.GetRefreshExpiresIn()),
+	})
+}
+
+// Unmarshal decode into the token information
+func (BSONCodec) Unmarshal(data []byte, info oauth2.TokenInfo) error {
+	var p bsonPayload
+
+	if err := bson.Unmarshal(data, &p); err != nil {
+		return err
+	}
+
+	info.SetClientID(p.ClientID)
+	info.SetUserID(p.UserID)
+	info.SetRedirectURI(p.RedirectURI)
+	info.SetScope(p.Scope)
+	info.SetCode(p.Code)
+	info.SetCodeCreateAt(p.CodeCreateAt)
+	info.SetCodeExpiresIn(expiresIn(p.CodeCreateAt, p.CodeExpiresAt))
+	info.SetAccess(p.Access)
+	info.SetAccessCreateAt(p.AccessCreateAt)
+	info.SetAccessExpiresIn(expiresIn(p.AccessCreateAt, p.AccessExpiresAt))
+	info.SetRefresh(p.Refresh)
+	info.SetRefreshCreateAt(p.RefreshCreateAt)
+	info.SetRefreshExpiresIn(expiresIn(p.RefreshCreateAt, p.RefreshExpiresAt))
+
+	return nil
+}
+
+// structured report whether the payload is stored as a subdocument: a plaintext BSONCodec payload
+func structured(codec, keyID string) bool {
+	return codec == BSONCodec{}.Name() && keyID == ""
+}
+
+// codecs the codecs able to read the stored payloads
+type codecs struct {
+	write Codec
+	read  map[string]Codec
+}
+
+func newCodecs(write Codec, extra []Codec) codecs {
+	if write == nil {
+		write = JSONCodec{}
+	}
+
+	c := codecs{write: write, read: make(map[string]Codec)}
+
+	for _, codec := range append([]Codec{JSONCodec{}, ExtJSONCodec{}, BSONCodec{}, write}, extra...) {
+		c.read[codec.Name()] = codec
+	}
+
+	return c
+}
+
+// get the codec named name, documents written before codecs were recorded are JSON
+func (c codecs) get(name string) (Codec, error) {
+	if name == "" {
+		name = JSONCodec{}.Name()
+	}
+
+	codec, ok := c.read[name]
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+	}
+
+	return codec, nil
+}
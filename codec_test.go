@@ -0,0 +1,258 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// gobCodec a codec the store doesn't know by default
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(info oauth2.TokenInfo) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := gob.NewEncoder(&buf).Encode(info.(*models.Token)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, info oauth2.TokenInfo) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(info)
+}
+
+// requireSameToken compare the token informations, the timestamps to the millisecond some codecs store
+func requireSameToken(t *testing.T, got, want oauth2.TokenInfo) {
+	t.Helper()
+
+	same := func(a, b time.Time) bool { return a.Truncate(time.Millisecond).Equal(b.Truncate(time.Millisecond)) }
+
+	if got.GetClientID() != want.GetClientID() || got.GetUserID() != want.GetUserID() || got.GetScope() != want.GetScope() ||
+		got.GetRedirectURI() != want.GetRedirectURI() || got.GetCode() != want.GetCode() ||
+		got.GetAccess() != want.GetAccess() || got.GetRefresh() != want.GetRefresh() ||
+		!same(got.GetCodeCreateAt(), want.GetCodeCreateAt()) || got.GetCodeExpiresIn() != want.GetCodeExpiresIn() ||
+		!same(got.GetAccessCreateAt(), want.GetAccessCreateAt()) || got.GetAccessExpiresIn() != want.GetAccessExpiresIn() ||
+		!same(got.GetRefreshCreateAt(), want.GetRefreshCreateAt()) || got.GetRefreshExpiresIn() != want.GetRefreshExpiresIn() {
+		t.Fatalf("token %+v, want %+v", got, want)
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	for _, codec := range []Codec{JSONCodec{}, ExtJSONCodec{}, BSONCodec{}, gobCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			ts := newTestTokenStore(t, WithCodec(codec))
+			code, token := testCode("code"), testToken("access", "refresh")
+
+			for _, info := range []oauth2.TokenInfo{code, token} {
+				if err := ts.Create(ctx, info); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := ts.GetByCode(ctx, "code")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			requireSameToken(t, got, code)
+
+			for _, get := range []func() (oauth2.TokenInfo, error){
+				func() (oauth2.TokenInfo, error) { return ts.GetByAccess(ctx, "access") },
+				func() (oauth2.TokenInfo, error) { return ts.GetByRefresh(ctx, "refresh") },
+			} {
+				got, err := get()
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				requireSameToken(t, got, token)
+			}
+		})
+	}
+}
+
+// the payloads written before a codec change stay readable
+func TestCodecSwitch(t *testing.T) {
+	ctx := context.Background()
+	codecs := []Codec{JSONCodec{}, ExtJSONCodec{}, BSONCodec{}, gobCodec{}}
+
+	for _, before := range codecs {
+		for _, after := range codecs {
+			if before == after {
+				continue
+			}
+
+			t.Run(before.Name()+" to "+after.Name(), func(t *testing.T) {
+				db := testDatabase(t)
+				token := testToken("access", "refresh")
+
+				if err := testTokenStoreWithDB(db, WithCodec(before)).Create(ctx, token); err != nil {
+					t.Fatal(err)
+				}
+
+				ts := testTokenStoreWithDB(db, WithCodec(after, gobCodec{}))
+
+				if err := ts.Create(ctx, testToken("new", "")); err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := ts.GetByAccess(ctx, "access")
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				requireSameToken(t, got, token)
+
+				if _, err := ts.GetByAccess(ctx, "new"); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	}
+}
+
+// a payload of a codec no longer configured fails with ErrUnknownCodec
+func TestCodecUnknown(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	if err := testTokenStoreWithDB(db, WithCodec(gobCodec{})).Create(ctx, testToken("access", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := testTokenStoreWithDB(db).GetByAccess(ctx, "access"); !errors.Is(err, ErrUnknownCodec) {
+		t.Fatalf("payload of an unknown codec: %v", err)
+	}
+}
+
+func TestBSONCodecFields(t *testing.T) {
+	now := time.Now()
+
+	// every field set, the lifetimes distinct
+	info := &models.Token{
+		ClientID:         "client",
+		UserID:           "user",
+		RedirectURI:      "https://example.com/cb",
+		Scope:            "read write",
+		Code:             "code",
+		CodeCreateAt:     now.Add(-time.Minute),
+		CodeExpiresIn:    5 * time.Minute,
+		Access:           "access",
+		AccessCreateAt:   now,
+		AccessExpiresIn:  time.Hour,
+		Refresh:          "refresh",
+		RefreshCreateAt:  now.Add(time.Second),
+		RefreshExpiresIn: 24 * time.Hour,
+	}
+
+	for _, want := range []*models.Token{info, {ClientID: "client", Access: "access", AccessCreateAt: now}} {
+		data, err := BSONCodec{}.Marshal(want)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got models.Token
+
+		if err := (BSONCodec{}).Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+
+		requireSameToken(t, &got, want)
+	}
+
+	data, err := BSONCodec{}.Marshal(info)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the expiries are stored as dates
+	if expiresAt := bson.Raw(data).Lookup("access_expires_at").Time(); !expiresAt.Equal(now.Add(time.Hour).Truncate(time.Millisecond)) {
+		t.Fatalf("access expiry %v", expiresAt)
+	}
+
+	if err := (BSONCodec{}).Unmarshal([]byte("{}"), &models.Token{}); err == nil {
+		t.Fatal("JSON decoded as BSON")
+	}
+}
+
+func TestBSONCodecDocuments(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+	ts := testTokenStoreWithDB(db, WithCodec(BSONCodec{}))
+
+	for _, info := range []oauth2.TokenInfo{testToken("access", "refresh"), testCode("code")} {
+		if err := ts.Create(ctx, info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the payload fields are filtered on the server
+	basic := ts.Collection(CollectionBasic)
+
+	if n, err := basic.CountDocuments(ctx, bson.M{"Data.client_id": "c", "Data.refresh": "refresh"}); err != nil || n != 1 {
+		t.Fatalf("%d payloads of the refresh token: %v", n, err)
+	}
+
+	if n, err := basic.CountDocuments(ctx, bson.M{"Data.redirect_uri": "https://example.com/cb"}); err != nil || n != 1 {
+		t.Fatalf("%d payloads of the code: %v", n, err)
+	}
+
+	// both payload kinds coexist during a migration
+	plain := testTokenStoreWithDB(db)
+
+	if err := plain.Create(ctx, testToken("json", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []*TokenStore{ts, plain} {
+		for _, access := range []string{"access", "json"} {
+			if _, err := s.GetByAccess(ctx, access); err != nil {
+				t.Fatalf("%s: %v", access, err)
+			}
+		}
+	}
+
+	if n, err := basic.CountDocuments(ctx, bson.M{"Data": bson.M{"$type": "binData"}}); err != nil || n != 1 {
+		t.Fatalf("%d binary payloads: %v", n, err)
+	}
+
+	// the encrypted payloads stay binary
+	enc := testTokenStoreWithDB(db, WithCodec(BSONCodec{}), WithEncrypter(testEncrypter(t, "k1")))
+
+	if err := enc.Create(ctx, testToken("encrypted", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := basic.FindOne(ctx, bson.M{"KeyID": "k1"}).DecodeBytes()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := raw.Lookup("Data"); v.Type != bsontype.Binary || lookupString(raw, []string{"Codec"}) != "bson" {
+		t.Fatalf("encrypted payload %s", raw)
+	}
+
+	if info, err := enc.GetByAccess(ctx, "encrypted"); err != nil || info.GetClientID() != "c" {
+		t.Fatalf("encrypted token %v: %v", info, err)
+	}
+}
@@ -0,0 +1,253 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// name of the index of the client IDs under ClientConfig.Collation
+const clientIDCollationIndex = "_id_1_collation"
+
+// caseInsensitiveCollation the collation of ClientConfig.CaseInsensitiveClientIDs: case and diacritics insensitive
+var caseInsensitiveCollation = options.Collation{Locale: "en", Strength: 2}
+
+// collation the collation of the client ID lookups, nil for the collection default
+func (cs *ClientStore) collation() *options.Collation {
+	if cs.ccfg.Collation != nil {
+		return cs.ccfg.Collation
+	}
+
+	if cs.ccfg.CaseInsensitiveClientIDs {
+		c := caseInsensitiveCollation
+		return &c
+	}
+
+	return nil
+}
+
+// the options of the writes and counts filtering on client IDs, under the collation of the lookups so they
+// find the same clients
+func (cs *ClientStore) updateOptions() *options.UpdateOptions {
+	return options.Update().SetCollation(cs.collation())
+}
+
+func (cs *ClientStore) replaceOptions() *options.ReplaceOptions {
+	return options.Replace().SetCollation(cs.collation())
+}
+
+func (cs *ClientStore) deleteOptions() *options.DeleteOptions {
+	return options.Delete().SetCollation(cs.collation())
+}
+
+func (cs *ClientStore) countOptions() *options.CountOptions {
+	return options.Count().SetCollation(cs.collation())
+}
+
+func (cs *ClientStore) findOneOptions() *options.FindOneOptions {
+	return options.FindOne().SetCollation(cs.collation())
+}
+
+// collationIndex the index of the client IDs under the lookup collation, the simple collation is served by the
+// _id index. The server rejects unique indexes of _id other than _id_, checkCollationConflict rejects the IDs
+// equal under the collation instead.
+func (cs *ClientStore) collationIndex() (mongo.IndexModel, bool) {
+	c := cs.collation()
+
+	if c == nil || c.Locale == "simple" {
+		return mongo.IndexModel{}, false
+	}
+
+	return mongo.IndexModel{
+		Keys:    bson.D{{Key: "_id", Value: 1}},
+		Options: options.Index().SetName(clientIDCollationIndex).SetCollation(c),
+	}, true
+}
+
+// checkCollationConflict reject the new client id when a client ID equal under the lookup collation, e.g. only
+// differing by case, is stored
+func (cs *ClientStore) checkCollationConflict(ctx context.Context, c *mongo.Collection, id string) error {
+	if _, ok := cs.collationIndex(); !ok {
+		return nil
+	}
+
+	raw, err := c.FindOne(ctx, bson.M{"_id": id}, cs.findOneOptions().SetProjection(bson.M{"_id": 1})).DecodeBytes()
+
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return &DuplicateKeyError{Field: "_id", Index: clientIDCollationIndex, Err: fmt.Errorf("client %s exists", lookupString(raw, []string{"_id"}))}
+}
+
+// indexCollation a collation as listed by the server, with every option set
+type indexCollation struct {
+	Locale          string `bson:"locale"`
+	CaseLevel       bool   `bson:"caseLevel"`
+	CaseFirst       string `bson:"caseFirst"`
+	Strength        int    `bson:"strength"`
+	NumericOrdering bool   `bson:"numericOrdering"`
+	Alternate       string `bson:"alternate"`
+	MaxVariable     string `bson:"maxVariable"`
+	Normalization   bool   `bson:"normalization"`
+	Backwards       bool   `bson:"backwards"`
+}
+
+// effectiveCollation the collation c resolves to on the server, nil and the simple collation are the binary
+// comparison. The options defaulting per locale are left empty.
+func effectiveCollation(c *options.Collation) indexCollation {
+	if c == nil || c.Locale == "simple" {
+		return indexCollation{Locale: "simple"}
+	}
+
+	ic := indexCollation{
+		Locale:          c.Locale,
+		CaseLevel:       c.CaseLevel,
+		CaseFirst:       c.CaseFirst,
+		Strength:        c.Strength,
+		NumericOrdering: c.NumericOrdering,
+		Alternate:       c.Alternate,
+		MaxVariable:     c.MaxVariable,
+		Normalization:   c.Normalization,
+		Backwards:       c.Backwards,
+	}
+
+	if ic.Strength == 0 {
+		ic.Strength = 3
+	}
+
+	return ic
+}
+
+// matches report whether the index collation got compares like ic, the options ic leaves empty take the
+// default of the locale
+func (ic indexCollation) matches(got indexCollation) bool {
+	same := func(want, got string) bool { return want == "" || want == got }
+
+	return ic.Locale == got.Locale &&
+		ic.Strength == got.Strength &&
+		ic.CaseLevel == got.CaseLevel &&
+		same(ic.CaseFirst, got.CaseFirst) &&
+		ic.NumericOrdering == got.NumericOrdering &&
+		same(ic.Alternate, got.Alternate) &&
+		same(ic.MaxVariable, got.MaxVariable) &&
+		ic.Normalization == got.Normalization &&
+		ic.Backwards == got.Backwards
+}
+
+func (ic indexCollation) String() string {
+	if ic.Locale == "simple" {
+		return "simple"
+	}
+
+	s := fmt.Sprintf("%s(strength %d", ic.Locale, ic.Strength)
+
+	for _, opt := range []struct {
+		set  bool
+		name string
+	}{
+		{ic.CaseLevel, "caseLevel"},
+		{ic.CaseFirst != "" && ic.CaseFirst != "off", "caseFirst " + ic.CaseFirst},
+		{ic.NumericOrdering, "numericOrdering"},
+		{ic.Alternate != "" && ic.Alternate != "non-ignorable", "alternate " + ic.Alternate},
+		{ic.Normalization, "normalization"},
+		{ic.Backwards, "backwards"},
+	} {
+		if opt.set {
+			s += ", " + opt.name
+		}
+	}
+
+	return s + ")"
+}
+
+// CollationMismatchError the index serving the client ID lookups doesn't have their collation, the lookups then
+// scan the collection(or the simple collation isn't the one of the collection)
+type CollationMismatchError struct {
+	Collection string
+	Index      string
+	// collation of the lookups
+	Want string
+	// collation of the index
+	Got string
+}
+
+func (e *CollationMismatchError) Error() string {
+	return fmt.Sprintf("mongo: index %s of %s has the %s collation, the client ID lookups use %s", e.Index, e.Collection, e.Got, e.Want)
+}
+
+// Is match ErrInvalidConfig
+func (e *CollationMismatchError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// CheckCollation compare the collation of the client ID lookups with the one of the index serving them,
+// a mismatch returns a CollationMismatchError. Without configured collation there is nothing to compare,
+// a missing index is reported by Healthy.
+func (cs *ClientStore) CheckCollation(ctx context.Context) error {
+	db, err := route(ctx, cs.conns, cs.ccfg.TenantResolver)
+
+	if err != nil {
+		return err
+	}
+
+	return cs.checkCollation(ctx, db)
+}
+
+func (cs *ClientStore) checkCollation(ctx context.Context, db routedDB) error {
+	c := cs.collation()
+
+	if c == nil {
+		return nil
+	}
+
+	index := "_id_"
+
+	if model, ok := cs.collationIndex(); ok {
+		index = *model.Options.Name
+	}
+
+	cur, err := db.Collection(cs.ccfg.ClientsCName).Indexes().List(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var idx struct {
+			Name      string          `bson:"name"`
+			Collation *indexCollation `bson:"collation"`
+		}
+
+		if err := cur.Decode(&idx); err != nil {
+			return err
+		}
+
+		if idx.Name != index {
+			continue
+		}
+
+		got := indexCollation{Locale: "simple"}
+
+		if idx.Collation != nil {
+			got = *idx.Collation
+		}
+
+		if want := effectiveCollation(c); !want.matches(got) {
+			return &CollationMismatchError{Collection: db.prefix + cs.ccfg.ClientsCName, Index: index, Want: want.String(), Got: got.String()}
+		}
+
+		return nil
+	}
+
+	return cur.Err()
+}
@@ -0,0 +1,225 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// requireClients fail unless the clients collection holds n documents
+func requireClients(t *testing.T, cs *ClientStore, n int64) {
+	t.Helper()
+
+	if got, err := cs.Collection().CountDocuments(context.Background(), bson.M{}); err != nil || got != n {
+		t.Fatalf("%d clients, want %d: %v", got, n, err)
+	}
+}
+
+// requireCollation skip the tests of the collations on servers without them, e.g. FerretDB
+func requireCollation(t *testing.T, cs *ClientStore) {
+	t.Helper()
+
+	err := cs.Collection().FindOne(context.Background(), bson.M{"_id": "probe"}, cs.findOneOptions()).Err()
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		skipNotImplemented(t, err)
+		t.Fatal(err)
+	}
+}
+
+func TestCaseInsensitiveClientIDs(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.AuditCName = "oauth2_client_audit"
+	cs := newTestClientStore(t, ccfg, WithCaseInsensitiveClientIDs())
+	ctx := context.Background()
+	requireCollation(t, cs)
+
+	if err := cs.EnsureIndexes(ctx); err != nil {
+		skipNotImplemented(t, err)
+		t.Fatal(err)
+	}
+
+	if err := cs.CheckCollation(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "AcmeApp", Secret: "s", Domain: "https://acme.example.com", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := cs.GetByID(ctx, "acmeapp"); err != nil || info.GetID() != "AcmeApp" {
+		t.Fatalf("client %v: %v", info, err)
+	}
+
+	if ok, err := cs.Exists(ctx, "ACMEAPP"); err != nil || !ok {
+		t.Fatalf("client exists %v: %v", ok, err)
+	}
+
+	var de *DuplicateKeyError
+
+	// a new client of the ID in another case
+	if _, err := cs.CreateClient(ctx, &models.Client{ID: "ACMEAPP", Secret: "s", Domain: "https://acme.example.com", UserID: "u"}); !errors.As(err, &de) || de.Index != clientIDCollationIndex {
+		t.Fatalf("client ID in another case: %v, want a DuplicateKeyError of %s", err, clientIDCollationIndex)
+	}
+
+	// the writes find the client whatever the case of its ID, no other client is created
+	if err := cs.Update(ctx, &models.Client{ID: "ACMEAPP", Domain: "https://acme.example.org", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "acmeApp", Secret: "s", Domain: "https://acme.example.net", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	requireClients(t, cs, 1)
+
+	if info, err := cs.GetByID(ctx, "AcmeApp"); err != nil || info.GetDomain() != "https://acme.example.net" {
+		t.Fatalf("client %v: %v", info, err)
+	}
+
+	rl := RateLimit{Requests: 10, Window: time.Minute}
+
+	if err := cs.SetRateLimit(ctx, "acmeapp", rl); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := cs.RateLimit(ctx, "ACMEAPP"); err != nil || got == nil || *got != rl {
+		t.Fatalf("rate limit %v: %v", got, err)
+	}
+
+	if _, err := cs.IssueRegistrationToken(ctx, "ACMEapp"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.SetClientKeys(ctx, "acmeAPP", nil, "https://acme.example.com/jwks"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Disable(ctx, "acmeapp"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cs.GetByID(ctx, "AcmeApp"); !errors.Is(err, ErrClientDisabled) {
+		t.Fatalf("disabled client: %v, want ErrClientDisabled", err)
+	}
+
+	// a disabled client keeps its rate limit
+	if err := cs.SetRateLimit(ctx, "AcmeApp", RateLimit{}); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("rate limit of a disabled client: %v, want ErrClientNotFound", err)
+	}
+
+	if err := cs.Restore(ctx, "ACMEAPP"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the audit trail follows the writes under any case
+	entries, err := cs.AuditForClient(ctx, "AcmeApp", time.Now().Add(-time.Minute))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var audited int
+
+	for _, entry := range entries {
+		audited += len(entry.Changes)
+	}
+
+	if audited == 0 {
+		t.Fatalf("audit entries %+v", entries)
+	}
+
+	// an import of the client under another case
+	source := newTestClientStore(t)
+
+	if err := source.Set(&models.Client{ID: "acmeapp", Secret: "imported", Domain: "https://acme.example.com", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cs.ImportClients(ctx, exportClients(t, source, ExportOptions{}), ImportOptions{}); !errors.As(err, &de) {
+		t.Fatalf("import of a stored client: %v, want a DuplicateKeyError", err)
+	}
+
+	if report, err := cs.ImportClients(ctx, exportClients(t, source, ExportOptions{}), ImportOptions{OnConflict: ImportSkip}); err != nil || report.Skipped != 1 {
+		t.Fatalf("report %+v: %v", report, err)
+	}
+
+	if report, err := cs.ImportClients(ctx, exportClients(t, source, ExportOptions{}), ImportOptions{OnConflict: ImportUpsert}); err != nil || report.Replaced != 1 {
+		t.Fatalf("report %+v: %v", report, err)
+	}
+
+	requireClients(t, cs, 1)
+
+	if err := cs.RemoveByID("ACMEAPP"); err != nil {
+		t.Fatal(err)
+	}
+
+	requireClients(t, cs, 0)
+
+	if _, err := cs.RemoveClient(ctx, "acmeapp"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("removal of a removed client: %v, want ErrClientNotFound", err)
+	}
+}
+
+func TestCaseSensitiveClientIDs(t *testing.T) {
+	simple := &options.Collation{Locale: "simple"}
+	cs := newTestClientStore(t, WithClientIDCollation(simple))
+	ctx := context.Background()
+	requireCollation(t, cs)
+
+	if err := cs.CheckCollation(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"AcmeApp", "acmeapp"} {
+		if err := cs.Set(&models.Client{ID: id, Secret: "s", Domain: "https://" + id + ".example.com", UserID: "u"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	requireClients(t, cs, 2)
+
+	if _, err := cs.GetByID(ctx, "ACMEAPP"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("client of another case: %v, want ErrClientNotFound", err)
+	}
+
+	if err := cs.Update(ctx, &models.Client{ID: "ACMEAPP", Domain: "https://acme.example.org"}); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("update of another case: %v, want ErrClientNotFound", err)
+	}
+
+	if err := cs.RemoveByID("acmeapp"); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := cs.GetByID(ctx, "AcmeApp"); err != nil || info.GetDomain() != "https://AcmeApp.example.com" {
+		t.Fatalf("client %v: %v", info, err)
+	}
+
+	// the simple collation compares the IDs byte by byte whatever the collection default
+	db := testDatabase(t)
+
+	if err := db.CreateCollection(ctx, "oauth2_clients", options.CreateCollection().SetCollation(&caseInsensitiveCollation)); err != nil {
+		skipNotImplemented(t, err)
+		t.Fatal(err)
+	}
+
+	var ce *CollationMismatchError
+
+	if err := NewClientStoreWithDB(db, WithClientIDCollation(simple)).CheckCollation(ctx); !errors.As(err, &ce) || ce.Index != "_id_" || ce.Want != "simple" {
+		t.Fatalf("collection default collation: %v, want a CollationMismatchError", err)
+	}
+
+	if err := NewClientStoreWithDB(db, WithCaseInsensitiveClientIDs()).EnsureIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewClientStoreWithDB(db, WithClientIDCollation(&options.Collation{Locale: "fr", Strength: 1})).CheckCollation(ctx); !errors.As(err, &ce) || ce.Index != clientIDCollationIndex {
+		t.Fatalf("index of another collation: %v, want a CollationMismatchError", err)
+	}
+}
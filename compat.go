@@ -0,0 +1,120 @@
+package mongo
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// CompatibilityMode the MongoDB compatible service the stores run against
+type CompatibilityMode int
+
+// compatibility modes
+const (
+	// CompatAuto detected from the hosts of the Config by NewTokenStore/NewClientStore, MongoDB otherwise
+	CompatAuto CompatibilityMode = iota
+	// CompatMongoDB MongoDB server
+	CompatMongoDB
+	// CompatCosmosDB Azure Cosmos DB API for MongoDB: no transactions, TTL through the _ts index
+	CompatCosmosDB
+	// CompatDocumentDB Amazon DocumentDB: transactions only from engine 4.0(see Capabilities), see RemoveExpired
+	CompatDocumentDB
+)
+
+func (m CompatibilityMode) String() string {
+	switch m {
+	case CompatMongoDB:
+		return "mongodb"
+	case CompatCosmosDB:
+		return "cosmosdb"
+	case CompatDocumentDB:
+		return "documentdb"
+	}
+
+	return "auto"
+}
+
+// transactions report whether the service supports the multi-document transactions used by the stores
+func (m CompatibilityMode) transactions() bool {
+	return m != CompatCosmosDB
+}
+
+// DetectCompatibility the compatibility mode of the service the configuration connects to, from its host names
+func DetectCompatibility(cfg *Config) CompatibilityMode {
+	hosts := cfg.Hosts
+
+	if cfg.URL != "" {
+		cs, err := connstring.Parse(cfg.URL)
+
+		if err != nil {
+			return CompatMongoDB
+		}
+
+		hosts = cs.Hosts
+	}
+
+	for _, host := range hosts {
+		name, port, err := net.SplitHostPort(host)
+
+		if err != nil {
+			name = host
+		}
+
+		name = strings.ToLower(name)
+
+		switch {
+		case strings.HasSuffix(name, ".cosmos.azure.com"), strings.HasSuffix(name, ".documents.azure.com"):
+			return CompatCosmosDB
+		case port == "10255" && (name == "localhost" || name == "127.0.0.1"):
+			// the Cosmos DB emulator
+			return CompatCosmosDB
+		case strings.HasSuffix(name, ".docdb.amazonaws.com"), strings.HasSuffix(name, ".docdb-elastic.amazonaws.com"):
+			return CompatDocumentDB
+		}
+	}
+
+	return CompatMongoDB
+}
+
+// compatibilityOption resolve the automatic compatibility mode from cfg, applied before the caller's options
+func compatibilityOption(cfg *Config) Option {
+	mode := DetectCompatibility(cfg)
+
+	return Option{
+		token: func(c *TokenConfig) {
+			if c.Compatibility == CompatAuto {
+				c.Compatibility = mode
+			}
+		},
+		client: func(c *ClientConfig) {
+			if c.Compatibility == CompatAuto {
+				c.Compatibility = mode
+			}
+		},
+	}
+}
+
+// transactionsDisabled report whether the writes run without transactions
+func (ts *TokenStore) transactionsDisabled() bool {
+	return ts.tcfg.DisableTransactions || !ts.tcfg.Compatibility.transactions()
+}
+
+// transactionsDisabled report whether the writes run without transactions
+func (cs *ClientStore) transactionsDisabled() bool {
+	return cs.ccfg.DisableTransactions || !cs.ccfg.Compatibility.transactions()
+}
+
+// compensate remove the documents inserted by a write which failed without a transaction to roll it back
+func (ts *TokenStore) compensate(ctx context.Context, d routedDB, inserted map[string]bson.D) {
+	for name, doc := range inserted {
+		if _, err := d.Collection(ts.cname(name)).DeleteOne(ctx, bson.D{doc[0]}); err != nil {
+			ts.logger().Log(ctx, LogWarn, "compensating delete failed", map[string]interface{}{
+				"collection": d.prefix + ts.cname(name),
+				"error":      err.Error(),
+			})
+		}
+	}
+}
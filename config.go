@@ -1,9 +1,61 @@
 package mongo
 
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
 // Config mongodb configuration parameters
 type Config struct {
+	// connection string, mutually exclusive with the structured fields below
 	URL string
 	DB  string
+
+	// server addresses(host:port) used when URL is empty
+	Hosts []string
+	// resolve the single host as a DNS seed list(mongodb+srv)
+	SRV        bool
+	Username   string
+	Password   string
+	AuthSource string
+	ReplicaSet string
+	// enable TLS with the given configuration
+	TLSConfig *tls.Config
+
+	// timeout for establishing the connection in NewTokenStore/NewClientStore(The default 0 uses 10s)
+	ConnectTimeout time.Duration
+	// network compressors in order of preference, e.g. "zstd", "snappy", "zlib"
+	// (The default is no compression)
+	Compressors []string
+	// compression level used when the zlib compressor is negotiated(0 uses the driver default)
+	ZlibLevel int
+	// compression level used when the zstd compressor is negotiated(0 uses the driver default)
+	ZstdLevel int
+	// SDAM monitor notified about topology changes and server heartbeats of the
+	// client created by NewTokenStore/NewClientStore(The default is no monitor)
+	ServerMonitor *event.ServerMonitor
+	// connection pool monitor of the client created by NewTokenStore/NewClientStore,
+	// see PoolStats for a ready made counter(The default is no monitor)
+	PoolMonitor *event.PoolMonitor
+	// command monitor(query logging, APM) of the client created by NewTokenStore/NewClientStore,
+	// every store operation is issued as a plain find/insert/delete command against the
+	// configured collection so the events can be attributed(The default is no monitor)
+	CommandMonitor *event.CommandMonitor
+	// codec registry of the client created by NewTokenStore/NewClientStore, stores built on an
+	// existing client or database handle use the registry of that handle(The default is bson.DefaultRegistry)
+	Registry *bsoncodec.Registry
+	// client-side field level encryption of the client created by NewTokenStore/NewClientStore,
+	// see CSFLESchemaMap for the fields which may be encrypted(The default is no encryption)
+	AutoEncryption *options.AutoEncryptionOptions
+	// additional client options(TLS, auth, pool sizes, app name...) merged over the URL
+	// and the fields above(The default is none)
+	ClientOptions *options.ClientOptions
 }
 
 // NewConfig create mongodb configuration
@@ -13,3 +65,117 @@ func NewConfig(url, db string) *Config {
 		DB:  db,
 	}
 }
+
+// Validate report conflicting or missing connection settings
+func (cfg *Config) Validate() error {
+	switch {
+	case cfg.URL != "" && len(cfg.Hosts) > 0:
+		return fmt.Errorf("%w: URL and Hosts are mutually exclusive", ErrInvalidConfig)
+	case cfg.URL == "" && len(cfg.Hosts) == 0 && cfg.ClientOptions == nil:
+		return fmt.Errorf("%w: either URL, Hosts or ClientOptions is required", ErrInvalidConfig)
+	case cfg.URL != "" && (cfg.SRV || cfg.Username != "" || cfg.Password != "" || cfg.AuthSource != "" || cfg.ReplicaSet != ""):
+		return fmt.Errorf("%w: connection fields can't be combined with URL, set them in the URL instead", ErrInvalidConfig)
+	case cfg.SRV && len(cfg.Hosts) != 1:
+		return fmt.Errorf("%w: SRV requires exactly one host", ErrInvalidConfig)
+	case cfg.SRV && strings.Contains(cfg.Hosts[0], ":"):
+		return fmt.Errorf("%w: SRV host can't have a port", ErrInvalidConfig)
+	case cfg.Password != "" && cfg.Username == "":
+		return fmt.Errorf("%w: Password requires Username", ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// BuildClientOptions build the mongo client options described by the configuration
+func (cfg *Config) BuildClientOptions() *options.ClientOptions {
+	opts := options.Client()
+
+	switch {
+	case cfg.URL != "":
+		opts.ApplyURI(cfg.URL)
+	case cfg.SRV && len(cfg.Hosts) > 0:
+		// the seed list can only be resolved from a connection string, the
+		// credentials are still set below so they never need to be escaped
+		opts.ApplyURI("mongodb+srv://" + cfg.Hosts[0])
+	case len(cfg.Hosts) > 0:
+		opts.SetHosts(cfg.Hosts)
+	}
+
+	if cfg.Username != "" {
+		opts.SetAuth(options.Credential{
+			Username:    cfg.Username,
+			Password:    cfg.Password,
+			PasswordSet: cfg.Password != "",
+			AuthSource:  cfg.AuthSource,
+		})
+	}
+
+	if cfg.ReplicaSet != "" {
+		opts.SetReplicaSet(cfg.ReplicaSet)
+	}
+
+	if cfg.TLSConfig != nil {
+		opts.SetTLSConfig(cfg.TLSConfig)
+	}
+
+	if cfg.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(cfg.ConnectTimeout)
+	}
+
+	if len(cfg.Compressors) > 0 {
+		opts.SetCompressors(cfg.Compressors)
+	}
+
+	if cfg.ZlibLevel != 0 {
+		opts.SetZlibLevel(cfg.ZlibLevel)
+	}
+
+	if cfg.ZstdLevel != 0 {
+		opts.SetZstdLevel(cfg.ZstdLevel)
+	}
+
+	if cfg.CommandMonitor != nil {
+		opts.SetMonitor(cfg.CommandMonitor)
+	}
+
+	if cfg.PoolMonitor != nil {
+		opts.SetPoolMonitor(cfg.PoolMonitor)
+	}
+
+	if cfg.ServerMonitor != nil {
+		opts.SetServerMonitor(cfg.ServerMonitor)
+	}
+
+	if cfg.Registry != nil {
+		opts.SetRegistry(cfg.Registry)
+	}
+
+	if cfg.AutoEncryption != nil {
+		opts.SetAutoEncryptionOptions(cfg.AutoEncryption)
+	}
+
+	if cfg.ClientOptions != nil {
+		opts = options.MergeClientOptions(opts, cfg.ClientOptions)
+	}
+
+	return opts
+}
+
+func (cfg *Config) connectTimeout() time.Duration {
+	if cfg.ConnectTimeout > 0 {
+		return cfg.ConnectTimeout
+	}
+
+	return 10 * time.Second
+}
+
+// registryOption decode the client metadata with the registry of the client cfg creates, unless configured
+func registryOption(cfg *Config) Option {
+	return Option{
+		client: func(c *ClientConfig) {
+			if c.Registry == nil {
+				c.Registry = cfg.Registry
+			}
+		},
+	}
+}
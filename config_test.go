@@ -0,0 +1,400 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestBuildClientOptionsCompressors(t *testing.T) {
+	cfg := NewConfig("mongodb://127.0.0.1:27017", "oauth2")
+	cfg.Compressors = []string{"zstd", "zlib", "snappy"}
+	cfg.ZlibLevel = 6
+	cfg.ZstdLevel = 3
+
+	opts := cfg.BuildClientOptions()
+
+	if !reflect.DeepEqual(opts.Compressors, cfg.Compressors) {
+		t.Fatalf("compressors %v, want %v", opts.Compressors, cfg.Compressors)
+	}
+
+	if opts.ZlibLevel == nil || *opts.ZlibLevel != 6 {
+		t.Fatalf("zlib level %v, want 6", opts.ZlibLevel)
+	}
+
+	if opts.ZstdLevel == nil || *opts.ZstdLevel != 3 {
+		t.Fatalf("zstd level %v, want 3", opts.ZstdLevel)
+	}
+}
+
+func TestBuildClientOptionsNoCompressors(t *testing.T) {
+	opts := NewConfig("mongodb://127.0.0.1:27017", "oauth2").BuildClientOptions()
+
+	if len(opts.Compressors) != 0 || opts.ZlibLevel != nil || opts.ZstdLevel != nil {
+		t.Fatalf("compression set without configuration: %v %v %v", opts.Compressors, opts.ZlibLevel, opts.ZstdLevel)
+	}
+}
+
+// the compressors of the connection string are kept when Config doesn't set any
+func TestBuildClientOptionsURICompressors(t *testing.T) {
+	opts := NewConfig("mongodb://127.0.0.1:27017/?compressors=snappy", "oauth2").BuildClientOptions()
+
+	if !reflect.DeepEqual(opts.Compressors, []string{"snappy"}) {
+		t.Fatalf("compressors %v, want [snappy]", opts.Compressors)
+	}
+}
+
+func TestServerMonitorFiresOnConnect(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		opened  int
+		changes int
+	)
+
+	cfg := testConfig(t)
+	cfg.ServerMonitor = &event.ServerMonitor{
+		TopologyOpening: func(*event.TopologyOpeningEvent) {
+			mu.Lock()
+			opened++
+			mu.Unlock()
+		},
+		ServerDescriptionChanged: func(*event.ServerDescriptionChangedEvent) {
+			mu.Lock()
+			changes++
+			mu.Unlock()
+		},
+	}
+
+	for _, connect := range []func() interface{ Close() }{
+		func() interface{ Close() } { return testTokenStore(cfg) },
+		func() interface{ Close() } { return NewClientStore(cfg) },
+	} {
+		mu.Lock()
+		opened, changes = 0, 0
+		mu.Unlock()
+
+		store := connect()
+
+		// the server is described before the store construction selects it
+		mu.Lock()
+		o, h := opened, changes
+		mu.Unlock()
+
+		store.Close()
+
+		if o != 1 || h == 0 {
+			t.Fatalf("%d topology opening and %d server description events, want 1 and some", o, h)
+		}
+	}
+}
+
+func TestCommandMonitorCapturesCommands(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+
+	ts := testTokenStore(cfg)
+	defer ts.Close()
+
+	ctx := context.Background()
+	rec.reset()
+
+	if err := ts.Create(ctx, testToken("access", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByAccess(ctx, "access"); err != nil {
+		t.Fatal(err)
+	}
+
+	collections := func(name string) map[string]bool {
+		found := make(map[string]bool)
+
+		for _, cmd := range rec.named(name) {
+			found[cmd.Lookup(name).StringValue()] = true
+		}
+
+		return found
+	}
+
+	basic, access := ts.Collection(CollectionBasic).Name(), ts.Collection(CollectionAccess).Name()
+
+	if inserts := collections("insert"); !inserts[basic] || !inserts[access] {
+		t.Fatalf("inserts into %v, want %s and %s", inserts, basic, access)
+	}
+
+	if finds := collections("find"); !finds[access] || !finds[basic] {
+		t.Fatalf("finds in %v, want %s and %s", finds, access, basic)
+	}
+}
+
+// the client options are merged over the URI
+func TestBuildClientOptionsMerge(t *testing.T) {
+	cfg := NewConfig("mongodb://127.0.0.1:27017/?appName=uri&maxPoolSize=50&replicaSet=rs0", "oauth2")
+	cfg.ClientOptions = options.Client().SetAppName("oauth2-test").SetMaxPoolSize(3)
+
+	opts := cfg.BuildClientOptions()
+
+	if opts.AppName == nil || *opts.AppName != "oauth2-test" {
+		t.Errorf("app name %v, want oauth2-test", opts.AppName)
+	}
+
+	if opts.MaxPoolSize == nil || *opts.MaxPoolSize != 3 {
+		t.Errorf("max pool size %v, want 3", opts.MaxPoolSize)
+	}
+
+	if opts.ReplicaSet == nil || *opts.ReplicaSet != "rs0" {
+		t.Errorf("replica set %v, want the URI's rs0", opts.ReplicaSet)
+	}
+}
+
+// both constructors connect the client with the custom options
+func TestClientOptionsTakeEffect(t *testing.T) {
+	for _, connect := range []func(*Config) interface{ Close() }{
+		func(cfg *Config) interface{ Close() } { return testTokenStore(cfg) },
+		func(cfg *Config) interface{ Close() } { return NewClientStore(cfg) },
+	} {
+		var (
+			mu      sync.Mutex
+			maxSize []uint64
+		)
+
+		cfg := testConfig(t)
+		cfg.ClientOptions = options.Client().SetMaxPoolSize(3).SetPoolMonitor(&event.PoolMonitor{
+			Event: func(e *event.PoolEvent) {
+				if e.Type == event.PoolCreated {
+					mu.Lock()
+					maxSize = append(maxSize, e.PoolOptions.MaxPoolSize)
+					mu.Unlock()
+				}
+			},
+		})
+
+		connect(cfg).Close()
+
+		mu.Lock()
+
+		if len(maxSize) == 0 || maxSize[0] != 3 {
+			t.Errorf("pools created with max sizes %v, want 3", maxSize)
+		}
+
+		mu.Unlock()
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		cfg   Config
+		valid bool
+	}{
+		{"url", Config{URL: "mongodb://127.0.0.1:27017"}, true},
+		{"hosts", Config{Hosts: []string{"a:27017", "b:27017"}, Username: "u", Password: "p", ReplicaSet: "rs0"}, true},
+		{"srv", Config{Hosts: []string{"cluster0.example.net"}, SRV: true}, true},
+		{"client options only", Config{ClientOptions: options.Client().SetHosts([]string{"a:27017"})}, true},
+		{"url and hosts", Config{URL: "mongodb://a", Hosts: []string{"b"}}, false},
+		{"nothing", Config{DB: "oauth2"}, false},
+		{"url and username", Config{URL: "mongodb://a", Username: "u"}, false},
+		{"url and replica set", Config{URL: "mongodb://a", ReplicaSet: "rs0"}, false},
+		{"url and srv", Config{URL: "mongodb://a", SRV: true}, false},
+		{"srv with several hosts", Config{Hosts: []string{"a", "b"}, SRV: true}, false},
+		{"srv with port", Config{Hosts: []string{"a:27017"}, SRV: true}, false},
+		{"password without username", Config{Hosts: []string{"a"}, Password: "p"}, false},
+	} {
+		err := tc.cfg.Validate()
+
+		if tc.valid && err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+
+		if !tc.valid && !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: %v, want ErrInvalidConfig", tc.name, err)
+		}
+	}
+}
+
+// the structured fields build the client options, the credentials are never escaped into a URI
+func TestBuildClientOptionsStructured(t *testing.T) {
+	cfg := &Config{
+		Hosts:      []string{"a:27017", "b:27017"},
+		Username:   "user",
+		Password:   "p@ss:w/rd%",
+		AuthSource: "admin",
+		ReplicaSet: "rs0",
+		TLSConfig:  &tls.Config{ServerName: "a"},
+	}
+
+	opts := cfg.BuildClientOptions()
+
+	if !reflect.DeepEqual(opts.Hosts, cfg.Hosts) {
+		t.Errorf("hosts %v", opts.Hosts)
+	}
+
+	if a := opts.Auth; a == nil || a.Username != "user" || a.Password != "p@ss:w/rd%" || !a.PasswordSet || a.AuthSource != "admin" {
+		t.Errorf("credential %+v", a)
+	}
+
+	if opts.ReplicaSet == nil || *opts.ReplicaSet != "rs0" || opts.TLSConfig != cfg.TLSConfig {
+		t.Errorf("replica set %v, TLS %v", opts.ReplicaSet, opts.TLSConfig)
+	}
+}
+
+// the client options override the structured fields
+func TestBuildClientOptionsPrecedence(t *testing.T) {
+	cfg := &Config{
+		Hosts:         []string{"a:27017"},
+		ReplicaSet:    "rs0",
+		ClientOptions: options.Client().SetReplicaSet("rs1").SetHosts([]string{"b:27017"}),
+	}
+
+	opts := cfg.BuildClientOptions()
+
+	if *opts.ReplicaSet != "rs1" || !reflect.DeepEqual(opts.Hosts, []string{"b:27017"}) {
+		t.Fatalf("replica set %s and hosts %v, want the client options'", *opts.ReplicaSet, opts.Hosts)
+	}
+}
+
+func TestBuildClientOptionsSRV(t *testing.T) {
+	cfg := &Config{Hosts: []string{"cluster0.example.net"}, SRV: true, Username: "u", Password: "p"}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the credentials are set whether or not the seed list resolves
+	opts := cfg.BuildClientOptions()
+
+	if opts.Auth == nil || opts.Auth.Username != "u" || opts.Auth.Password != "p" {
+		t.Fatalf("credential %+v", opts.Auth)
+	}
+}
+
+// an invalid configuration makes the constructors panic before connecting
+func TestConstructorsRejectInvalidConfig(t *testing.T) {
+	for _, connect := range []func(){
+		func() { testTokenStore(&Config{URL: "mongodb://a", Hosts: []string{"b"}}) },
+		func() { NewClientStore(&Config{DB: "oauth2"}) },
+	} {
+		func() {
+			defer func() {
+				if err, ok := recover().(error); !ok || !errors.Is(err, ErrInvalidConfig) {
+					t.Errorf("panic %v, want ErrInvalidConfig", err)
+				}
+			}()
+
+			connect()
+		}()
+	}
+}
+
+// rgb a metadata value stored as user-defined binary by testRegistry
+type rgb struct{ R, G, B byte }
+
+const rgbSubtype = 0x80
+
+// testRegistry the default registry with a codec of rgb, which the binaries decode to
+func testRegistry() *bsoncodec.Registry {
+	rb := bson.NewRegistryBuilder()
+
+	rb.RegisterTypeEncoder(reflect.TypeOf(rgb{}), bsoncodec.ValueEncoderFunc(func(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, v reflect.Value) error {
+		c := v.Interface().(rgb)
+		return vw.WriteBinaryWithSubtype([]byte{c.R, c.G, c.B}, rgbSubtype)
+	}))
+
+	rb.RegisterTypeDecoder(reflect.TypeOf(rgb{}), bsoncodec.ValueDecoderFunc(func(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, v reflect.Value) error {
+		data, subtype, err := vr.ReadBinary()
+
+		if err != nil {
+			return err
+		}
+
+		if subtype != rgbSubtype || len(data) != 3 {
+			return fmt.Errorf("not a color: subtype %x, %d bytes", subtype, len(data))
+		}
+
+		v.Set(reflect.ValueOf(rgb{data[0], data[1], data[2]}))
+
+		return nil
+	}))
+
+	rb.RegisterTypeMapEntry(bsontype.Binary, reflect.TypeOf(rgb{}))
+
+	return rb.Build()
+}
+
+func TestRegistryRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	meta := map[string]interface{}{"color": rgb{1, 2, 3}, "name": "app"}
+
+	for _, tc := range []struct {
+		name string
+		open func(t *testing.T) *ClientStore
+	}{
+		{"config", func(t *testing.T) *ClientStore {
+			cfg := testConfig(t)
+			cfg.Registry = testRegistry()
+
+			cs := NewClientStore(cfg)
+			t.Cleanup(cs.Close)
+
+			return cs
+		}},
+		{"database handle", func(t *testing.T) *ClientStore {
+			cfg := testConfig(t)
+
+			client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URL).SetRegistry(testRegistry()))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+
+			return NewClientStoreWithDB(client.Database(cfg.DB), WithRegistry(testRegistry()))
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cs := tc.open(t)
+
+			if err := cs.SetWithMetadata(ctx, &models.Client{ID: "c", Secret: "s"}, meta); err != nil {
+				t.Fatal(err)
+			}
+
+			// encoded by the codec of the registry
+			raw, err := cs.Client().Database(cs.conns.database().Name()).Collection("oauth2_clients").FindOne(ctx, bson.M{"_id": "c"}).DecodeBytes()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if subtype, data, ok := raw.Lookup("metadata", "color").BinaryOK(); !ok || subtype != rgbSubtype || !bytes.Equal(data, []byte{1, 2, 3}) {
+				t.Fatalf("stored color %s", raw.Lookup("metadata", "color"))
+			}
+
+			info, err := cs.GetByID(ctx, "c")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := info.(*Client).Metadata; !reflect.DeepEqual(got, meta) {
+				t.Fatalf("metadata %#v, want %#v", got, meta)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// conn a mongo database handle together with the operations which started while it was current
+type conn struct {
+	db *mongo.Database
+	wg sync.WaitGroup
+}
+
+// connHolder the swappable mongo client of a store
+type connHolder struct {
+	mu  sync.RWMutex
+	cur *conn
+}
+
+func newConnHolder(db *mongo.Database) *connHolder {
+	return &connHolder{cur: &conn{db: db}}
+}
+
+// database current mongo database handle
+func (h *connHolder) database() *mongo.Database {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.cur.db
+}
+
+// client current mongo client
+func (h *connHolder) client() *mongo.Client {
+	return h.database().Client()
+}
+
+// acquire pin the current client for the duration of an operation
+func (h *connHolder) acquire() *conn {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	h.cur.wg.Add(1)
+
+	return h.cur
+}
+
+func (c *conn) release() {
+	c.wg.Done()
+}
+
+// swap replace the current client, disconnecting the previous one once
+// the operations pinning it have finished. An expired ctx only stops the
+// waiting, the previous client is still disconnected in the background.
+func (h *connHolder) swap(ctx context.Context, client *mongo.Client) error {
+	h.mu.Lock()
+	old := h.cur
+	h.cur = &conn{db: sibling(client, old.db.Name(), old.db)}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		old.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return old.db.Client().Disconnect(ctx)
+	case <-ctx.Done():
+		go func() {
+			<-done
+			old.db.Client().Disconnect(context.Background())
+		}()
+
+		return ctx.Err()
+	}
+}
+
+// sibling the database name of client with the database level settings of base
+func sibling(client *mongo.Client, name string, base *mongo.Database) *mongo.Database {
+	return client.Database(name, options.Database().
+		SetReadConcern(base.ReadConcern()).
+		SetReadPreference(base.ReadPreference()).
+		SetWriteConcern(base.WriteConcern()))
+}
+
+// reconnect connect a new client with opts and swap it in
+func (h *connHolder) reconnect(ctx context.Context, opts *options.ClientOptions) error {
+	client, err := mongo.Connect(ctx, opts)
+
+	if err != nil {
+		return err
+	}
+
+	return h.swap(ctx, client)
+}
@@ -0,0 +1,79 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// no operation fails while the client is swapped under it
+func TestReconnectUnderLoad(t *testing.T) {
+	cfg := testConfig(t)
+
+	ts := testTokenStore(cfg)
+	defer ts.Close()
+
+	ctx := context.Background()
+
+	if err := ts.Create(ctx, testToken("access", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		stop   = make(chan struct{})
+		ops    int64
+		failed int64
+	)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				if _, err := ts.GetByAccess(ctx, "access"); err != nil {
+					atomic.AddInt64(&failed, 1)
+					t.Error(err)
+				}
+
+				atomic.AddInt64(&ops, 1)
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+
+		if err := ts.Reconnect(ctx, options.Client().ApplyURI(cfg.URL)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+
+	if atomic.LoadInt64(&ops) == 0 {
+		t.Fatal("no operation ran during the swaps")
+	}
+
+	if n := atomic.LoadInt64(&failed); n > 0 {
+		t.Fatalf("%d of %d operations failed", n, ops)
+	}
+
+	// the operations after the swaps use the new client
+	if _, err := ts.GetByAccess(ctx, "access"); err != nil {
+		t.Fatal(err)
+	}
+}
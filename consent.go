@@ -0,0 +1,255 @@
+package mongo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fields of the consent documents
+const (
+	consentUserField    = "user_id"
+	consentClientField  = "client_id"
+	consentScopesField  = "scopes"
+	consentGrantedField = "granted_at"
+	consentUpdatedField = "updated_at"
+	consentExpiresField = "expires_at"
+)
+
+// Consent the scopes a user granted to a client
+type Consent struct {
+	UserID   string
+	ClientID string
+	Scopes   []string
+	// first and last Save of the consent
+	GrantedAt time.Time
+	UpdatedAt time.Time
+	// zero when the consent never expires
+	ExpiresAt time.Time
+}
+
+// Covers report whether the consent grants every scope, e.g. to skip the consent screen
+func (c *Consent) Covers(scopes []string) bool {
+	granted := make(map[string]bool, len(c.Scopes))
+
+	for _, scope := range c.Scopes {
+		granted[scope] = true
+	}
+
+	for _, scope := range scopes {
+		if !granted[scope] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ConsentStore the scopes users granted to clients, remembered to skip the consent screen of the next
+// authorizations. It shares the connection, configuration and hooks of the token store it is created from,
+// and its collection(TokenConfig.ConsentsCName) has a unique index on the user and client.
+type ConsentStore struct {
+	ts *TokenStore
+}
+
+// NewConsentStore create the consent store of ts and its indexes, unless a TenantResolver is configured:
+// call EnsureIndexes then for every tenant
+func NewConsentStore(ts *TokenStore) *ConsentStore {
+	s := &ConsentStore{ts: ts}
+	ts.initCollectionIndexes(ts.tcfg.ConsentsCName, s.indexes())
+
+	return s
+}
+
+// indexes the unique user and client index, the latter first for ListByUser, and the TTL index of the expiring consents
+func (s *ConsentStore) indexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: consentUserField, Value: 1}, {Key: consentClientField, Value: 1}},
+			Options: options.Index().SetName(consentUserField + "_1_" + consentClientField + "_1").SetUnique(true),
+		},
+		expiryIndex(consentExpiresField),
+	}
+}
+
+// EnsureIndexes create the indexes of the consents collection in the database of the tenant of ctx
+func (s *ConsentStore) EnsureIndexes(ctx context.Context) error {
+	return s.ts.routedIndexes(ctx, s.ts.tcfg.ConsentsCName, s.indexes())
+}
+
+func (s *ConsentStore) op(name string) *operation {
+	return newOperation("consent", name, s.ts.tcfg.ConsentsCName)
+}
+
+// Save record that the user granted the scopes to the client, added to those granted before. expiresAt(zero for
+// never) replaces the expiry of the consent.
+func (s *ConsentStore) Save(ctx context.Context, userID, clientID string, scopes []string, expiresAt time.Time) error {
+	o := s.op("Save")
+	o.set("client_id", clientID)
+
+	return s.ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("user ID", userID); err != nil {
+			return err
+		}
+
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		if scopes == nil {
+			scopes = []string{}
+		}
+
+		now := time.Now()
+		set := bson.M{consentUpdatedField: now}
+		update := bson.M{
+			"$addToSet":    bson.M{consentScopesField: bson.M{"$each": scopes}},
+			"$set":         set,
+			"$setOnInsert": bson.M{consentGrantedField: now},
+		}
+
+		if expiresAt.IsZero() {
+			update["$unset"] = bson.M{consentExpiresField: ""}
+		} else {
+			set[consentExpiresField] = expiresAt
+		}
+
+		filter := bson.M{consentUserField: userID, consentClientField: clientID}
+		var err error
+
+		// two concurrent first saves both upsert, the loser of the unique index updates the winner's document
+		for attempt := 0; attempt < 2; attempt++ {
+			err = s.ts.colHandler(ctx, s.ts.tcfg.ConsentsCName, func(ctx context.Context, c *mongo.Collection) error {
+				// an expired consent not yet removed by the TTL monitor isn't merged
+				expired := bson.M{consentUserField: userID, consentClientField: clientID, consentExpiresField: bson.M{"$lte": now}}
+
+				if _, err := c.DeleteOne(ctx, expired); err != nil {
+					return err
+				}
+
+				_, err := c.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+				return err
+			})
+
+			if !mongo.IsDuplicateKeyError(err) {
+				break
+			}
+		}
+
+		return err
+	})
+}
+
+// decodeConsent the consent of a stored document
+func decodeConsent(raw bson.Raw) *Consent {
+	scopes := lookupStrings(raw, []string{consentScopesField})
+	sort.Strings(scopes)
+
+	return &Consent{
+		UserID:    lookupString(raw, []string{consentUserField}),
+		ClientID:  lookupString(raw, []string{consentClientField}),
+		Scopes:    scopes,
+		GrantedAt: lookupTime(raw, []string{consentGrantedField}),
+		UpdatedAt: lookupTime(raw, []string{consentUpdatedField}),
+		ExpiresAt: lookupTime(raw, []string{consentExpiresField}),
+	}
+}
+
+// Get the consent of the user to the client, ErrConsentNotFound when there is none or it expired
+func (s *ConsentStore) Get(ctx context.Context, userID, clientID string) (consent *Consent, err error) {
+	o := s.op("Get")
+	o.set("client_id", clientID)
+
+	err = s.ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("user ID", userID); err != nil {
+			return err
+		}
+
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		return s.ts.readHandler(ctx, s.ts.tcfg.ConsentsCName, false, func(ctx context.Context, c *mongo.Collection) error {
+			filter := notExpired(consentExpiresField, bson.M{consentUserField: userID, consentClientField: clientID})
+			raw, err := c.FindOne(ctx, filter).DecodeBytes()
+
+			if err == mongo.ErrNoDocuments {
+				return errConsentNotFound
+			}
+
+			if err != nil {
+				return err
+			}
+
+			consent = decodeConsent(raw)
+			return nil
+		})
+	})
+
+	return
+}
+
+// Revoke remove the consent of the user to the client, the next authorization asks again. Revoking a missing
+// consent does nothing.
+func (s *ConsentStore) Revoke(ctx context.Context, userID, clientID string) error {
+	o := s.op("Revoke")
+	o.set("client_id", clientID)
+
+	return s.ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("user ID", userID); err != nil {
+			return err
+		}
+
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		return s.ts.colHandler(ctx, s.ts.tcfg.ConsentsCName, func(ctx context.Context, c *mongo.Collection) error {
+			res, err := c.DeleteOne(ctx, bson.M{consentUserField: userID, consentClientField: clientID})
+
+			if err == nil {
+				o.set("deleted", res.DeletedCount)
+			}
+
+			return err
+		})
+	})
+}
+
+// ListByUser the unexpired consents of the user by client ID, e.g. for the account page, empty if none
+func (s *ConsentStore) ListByUser(ctx context.Context, userID string) (consents []*Consent, err error) {
+	o := s.op("ListByUser")
+
+	err = s.ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("user ID", userID); err != nil {
+			return err
+		}
+
+		consents = []*Consent{}
+
+		return s.ts.readHandler(ctx, s.ts.tcfg.ConsentsCName, false, func(ctx context.Context, c *mongo.Collection) error {
+			filter := notExpired(consentExpiresField, bson.M{consentUserField: userID})
+			cur, err := c.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: consentClientField, Value: 1}}))
+
+			if err != nil {
+				return err
+			}
+
+			defer cur.Close(ctx)
+
+			for cur.Next(ctx) {
+				consents = append(consents, decodeConsent(cur.Current))
+			}
+
+			o.set("documents", len(consents))
+
+			return cur.Err()
+		})
+	})
+
+	return
+}
@@ -0,0 +1,180 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestConsentStore(t *testing.T) {
+	ts := newTestTokenStore(t, WithCollectionNames(CollectionNames{Consents: "approvals"}))
+	s := NewConsentStore(ts)
+	ctx := context.Background()
+
+	// the configured collection, one consent per user and client
+	c := ts.Database().Collection("approvals")
+
+	if _, err := c.InsertOne(ctx, bson.M{consentUserField: "u", consentClientField: "dup"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.InsertOne(ctx, bson.M{consentUserField: "u", consentClientField: "dup"}); !mongo.IsDuplicateKeyError(err) {
+		t.Fatalf("second consent of the user and client: %v, want a duplicate key error", err)
+	}
+
+	if _, err := c.DeleteMany(ctx, bson.M{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// created
+	if _, err := s.Get(ctx, "alice", "web"); !errors.Is(err, ErrConsentNotFound) {
+		t.Fatalf("missing consent: %v, want ErrConsentNotFound", err)
+	}
+
+	if err := s.Save(ctx, "alice", "web", []string{"read", "profile"}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := s.Get(ctx, "alice", "web")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.UserID != "alice" || first.ClientID != "web" || !reflect.DeepEqual(first.Scopes, []string{"profile", "read"}) ||
+		first.GrantedAt.IsZero() || !first.ExpiresAt.IsZero() {
+		t.Fatalf("consent %+v", first)
+	}
+
+	if !first.Covers([]string{"read"}) || !first.Covers(nil) || first.Covers([]string{"read", "write"}) {
+		t.Fatalf("consent %v covers", first.Scopes)
+	}
+
+	// the scopes of a new Save are added to those granted before
+	time.Sleep(10 * time.Millisecond)
+
+	if err := s.Save(ctx, "alice", "web", []string{"write", "read"}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := s.Get(ctx, "alice", "web")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(merged.Scopes, []string{"profile", "read", "write"}) {
+		t.Fatalf("merged scopes %v", merged.Scopes)
+	}
+
+	if !merged.GrantedAt.Equal(first.GrantedAt) || !merged.UpdatedAt.After(first.UpdatedAt) {
+		t.Fatalf("merged consent granted at %v, updated at %v, first %+v", merged.GrantedAt, merged.UpdatedAt, first)
+	}
+
+	if n, err := c.CountDocuments(ctx, bson.M{}); err != nil || n != 1 {
+		t.Fatalf("%d consents: %v", n, err)
+	}
+
+	// listed by client ID, those of the other users left out
+	for _, consent := range []struct{ userID, clientID string }{{"alice", "cli"}, {"bob", "web"}} {
+		if err := s.Save(ctx, consent.userID, consent.clientID, []string{"read"}, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	consents, err := s.ListByUser(ctx, "alice")
+
+	if err != nil || len(consents) != 2 || consents[0].ClientID != "cli" || consents[1].ClientID != "web" {
+		t.Fatalf("consents of alice %+v: %v", consents, err)
+	}
+
+	if consents, err = s.ListByUser(ctx, "carol"); err != nil || consents == nil || len(consents) != 0 {
+		t.Fatalf("consents of a user without one %v: %v", consents, err)
+	}
+
+	// revoked, the other consents kept
+	if err := s.Revoke(ctx, "alice", "web"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Get(ctx, "alice", "web"); !errors.Is(err, ErrConsentNotFound) {
+		t.Fatalf("revoked consent: %v, want ErrConsentNotFound", err)
+	}
+
+	if _, err := s.Get(ctx, "bob", "web"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Revoke(ctx, "alice", "web"); err != nil {
+		t.Fatalf("revoking a missing consent: %v", err)
+	}
+
+	// the errors are those of the consent store
+	_, err = s.Get(ctx, "", "web")
+
+	var oe *OpError
+
+	if !errors.Is(err, ErrInvalidArgument) || !errors.As(err, &oe) || oe.Store != "consent" || oe.Collection != "approvals" {
+		t.Fatalf("empty user ID: %v, want ErrInvalidArgument of the consent store", err)
+	}
+
+	for name, err := range map[string]error{
+		"Save":       s.Save(ctx, "alice", "", []string{"read"}, time.Time{}),
+		"Revoke":     s.Revoke(ctx, "", "web"),
+		"ListByUser": func() error { _, err := s.ListByUser(ctx, ""); return err }(),
+	} {
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("%s without ID: %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
+
+func TestConsentExpiry(t *testing.T) {
+	s := NewConsentStore(newTestTokenStore(t))
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+
+	if err := s.Save(ctx, "alice", "web", []string{"read"}, expiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	if consent, err := s.Get(ctx, "alice", "web"); err != nil || !consent.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("expiring consent %+v: %v", consent, err)
+	}
+
+	// a Save without expiry makes the consent permanent
+	if err := s.Save(ctx, "alice", "web", []string{"read"}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if consent, err := s.Get(ctx, "alice", "web"); err != nil || !consent.ExpiresAt.IsZero() {
+		t.Fatalf("permanent consent %+v: %v", consent, err)
+	}
+
+	// expired before the TTL monitor removes it
+	if err := s.Save(ctx, "alice", "web", []string{"write"}, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Get(ctx, "alice", "web"); !errors.Is(err, ErrConsentNotFound) {
+		t.Fatalf("expired consent: %v, want ErrConsentNotFound", err)
+	}
+
+	if consents, err := s.ListByUser(ctx, "alice"); err != nil || len(consents) != 0 {
+		t.Fatalf("consents %+v with an expired one: %v", consents, err)
+	}
+
+	// the scopes of the expired consent aren't merged
+	if err := s.Save(ctx, "alice", "web", []string{"profile"}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if consent, err := s.Get(ctx, "alice", "web"); err != nil || !reflect.DeepEqual(consent.Scopes, []string{"profile"}) {
+		t.Fatalf("consent %+v after the expired one: %v", consent, err)
+	}
+}
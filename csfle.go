@@ -0,0 +1,53 @@
+package mongo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// csfleRandom the CSFLE algorithm of the encrypted fields, none of them is ever queried
+const csfleRandom = "AEAD_AES_256_CBC_HMAC_SHA_512-Random"
+
+// CSFLESchemaMap the client-side field level encryption schemas of the store collections, keyed by
+// "<db>.<collection>" as expected by AutoEncryptionOptions.SetSchemaMap. Only the token payload(Data)
+// and the client secret are encrypted: the _id lookup keys, the BasicID references and the indexed
+// ExpiredAt field must remain queryable. A nil configuration skips the collections of that store. The payloads
+// must be binary: BSONCodec is not supported.
+func CSFLESchemaMap(db string, keyID primitive.Binary, tcfg *TokenConfig, ccfg *ClientConfig) map[string]interface{} {
+	schemas := make(map[string]interface{})
+
+	encrypted := func(field, bsonType string) bson.M {
+		return bson.M{
+			"bsonType": "object",
+			"encryptMetadata": bson.M{
+				"keyId": bson.A{keyID},
+			},
+			"properties": bson.M{
+				field: bson.M{
+					"encrypt": bson.M{
+						"bsonType":  bsonType,
+						"algorithm": csfleRandom,
+					},
+				},
+			},
+		}
+	}
+
+	if tcfg != nil {
+		fn := tcfg.FieldNames.withDefaults()
+		name := tcfg.BasicCName
+
+		if tcfg.SingleCollection {
+			name = tcfg.TokensCName
+		}
+
+		schemas[db+"."+name] = encrypted(fn.Data, "binData")
+	}
+
+	if ccfg != nil {
+		fn := ccfg.FieldNames.withDefaults()
+		schemas[db+"."+ccfg.ClientsCName] = encrypted(fn.Secret, "string")
+	}
+
+	return schemas
+}
@@ -0,0 +1,88 @@
+//go:build cse
+// +build cse
+
+package mongo
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// requires libmongocrypt and mongocryptd or crypt_shared: go test -tags cse
+func TestCSFLEStore(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+
+	localKey := make([]byte, 96)
+
+	if _, err := rand.Read(localKey); err != nil {
+		t.Fatal(err)
+	}
+
+	kms := map[string]map[string]interface{}{"local": {"key": localKey}}
+	vault := cfg.DB + ".__keyVault"
+
+	plain, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URL))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer plain.Disconnect(ctx)
+
+	ce, err := mongo.NewClientEncryption(plain, options.ClientEncryption().SetKeyVaultNamespace(vault).SetKmsProviders(kms))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ce.Close(ctx)
+
+	keyID, err := ce.CreateDataKey(ctx, "local")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.AutoEncryption = options.AutoEncryption().
+		SetKeyVaultNamespace(vault).
+		SetKmsProviders(kms).
+		SetSchemaMap(CSFLESchemaMap(cfg.DB, keyID, NewDefaultTokenConfig(), nil))
+
+	ts := testTokenStore(cfg)
+	defer ts.Close()
+
+	token := testToken("access", "refresh")
+
+	if err := ts.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ts.GetByAccess(ctx, "access")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireSameToken(t, got, token)
+
+	// the payload is encrypted on the server, the lookup keys aren't
+	raw, err := plain.Database(cfg.DB).Collection("oauth2_basic").FindOne(ctx, bson.M{}).DecodeBytes()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subtype, _, ok := raw.Lookup("Data").BinaryOK(); !ok || subtype != 6 {
+		t.Fatalf("stored payload %s, want an encrypted binary", raw.Lookup("Data"))
+	}
+
+	if n, err := plain.Database(cfg.DB).Collection("oauth2_access").CountDocuments(ctx, bson.M{"_id": "access"}); err != nil || n != 1 {
+		t.Fatalf("%d access documents by plaintext key: %v", n, err)
+	}
+}
@@ -0,0 +1,76 @@
+package mongo
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// encryptedFields the encrypted fields of a schema and their BSON types
+func encryptedFields(t *testing.T, schema interface{}) map[string]string {
+	t.Helper()
+
+	fields := make(map[string]string)
+
+	for name, prop := range schema.(bson.M)["properties"].(bson.M) {
+		encrypt := prop.(bson.M)["encrypt"].(bson.M)
+
+		if encrypt["algorithm"] != csfleRandom {
+			t.Errorf("%s encrypted with %v", name, encrypt["algorithm"])
+		}
+
+		fields[name] = encrypt["bsonType"].(string)
+	}
+
+	return fields
+}
+
+func TestCSFLESchemaMap(t *testing.T) {
+	keyID := primitive.Binary{Subtype: 4, Data: make([]byte, 16)}
+
+	snake := NewDefaultTokenConfig()
+	snake.FieldNames = SnakeCaseFieldNames()
+
+	single := NewDefaultTokenConfig()
+	single.SingleCollection = true
+
+	for _, tc := range []struct {
+		name string
+		tcfg *TokenConfig
+		ccfg *ClientConfig
+		want map[string]map[string]string
+	}{
+		{"default", NewDefaultTokenConfig(), NewDefaultClientConfig(), map[string]map[string]string{
+			"oauth2.oauth2_basic":   {"Data": "binData"},
+			"oauth2.oauth2_clients": {"secret": "string"},
+		}},
+		{"snake case", snake, nil, map[string]map[string]string{
+			"oauth2.oauth2_basic": {"data": "binData"},
+		}},
+		{"single collection", single, nil, map[string]map[string]string{
+			"oauth2." + single.TokensCName: {"Data": "binData"},
+		}},
+		{"client store", nil, NewDefaultClientConfig(), map[string]map[string]string{
+			"oauth2.oauth2_clients": {"secret": "string"},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			schemas := CSFLESchemaMap("oauth2", keyID, tc.tcfg, tc.ccfg)
+			got := make(map[string]map[string]string)
+
+			for ns, schema := range schemas {
+				got[ns] = encryptedFields(t, schema)
+
+				if keys := schema.(bson.M)["encryptMetadata"].(bson.M)["keyId"]; !reflect.DeepEqual(keys, bson.A{keyID}) {
+					t.Errorf("%s key ids %v", ns, keys)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("encrypted fields %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DeniedJTI a revoked JWT id, denied until the token expires
+type DeniedJTI struct {
+	JTI       string
+	ExpiresAt time.Time
+}
+
+// denylistIndex the index removing the denylist entries once their token has expired, Cosmos DB
+// only supports TTL through the _ts index and the per-document ttl field
+func (ts *TokenStore) denylistIndex() mongo.IndexModel {
+	if ts.tcfg.Compatibility == CompatCosmosDB {
+		return mongo.IndexModel{
+			Keys:    bson.M{"_ts": 1},
+			Options: options.Index().SetExpireAfterSeconds(-1),
+		}
+	}
+
+	return mongo.IndexModel{
+		Keys:    bson.M{ts.fields().ExpiredAt: 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+}
+
+// denylistIndexName name of the denylist TTL index generated by the server
+func (ts *TokenStore) denylistIndexName() string {
+	if ts.tcfg.Compatibility == CompatCosmosDB {
+		return "_ts_1"
+	}
+
+	return ts.fields().expiredAtIndexName()
+}
+
+// denyModel the upsert of a denylist entry, denying an id again keeps the latest expiry(the last one on Cosmos DB)
+func (ts *TokenStore) denyModel(d DeniedJTI) mongo.WriteModel {
+	if ts.tcfg.Compatibility == CompatCosmosDB {
+		ttl := int32(time.Until(d.ExpiresAt).Seconds()) + 1
+
+		if ttl < 1 {
+			ttl = 1
+		}
+
+		return mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": d.JTI}).
+			SetUpdate(bson.M{"$set": bson.M{ts.fields().ExpiredAt: d.ExpiresAt, "ttl": ttl}}).
+			SetUpsert(true)
+	}
+
+	return mongo.NewUpdateOneModel().
+		SetFilter(bson.M{"_id": d.JTI}).
+		SetUpdate(bson.M{"$max": bson.M{ts.fields().ExpiredAt: d.ExpiresAt}}).
+		SetUpsert(true)
+}
+
+// DenyJTI revoke the JWT id until expiresAt
+func (ts *TokenStore) DenyJTI(ctx context.Context, jti string, expiresAt time.Time) error {
+	return ts.DenyJTIs(ctx, []DeniedJTI{{JTI: jti, ExpiresAt: expiresAt}})
+}
+
+// DenyJTIs revoke the JWT ids in a single bulk write, e.g. for mass revocations
+func (ts *TokenStore) DenyJTIs(ctx context.Context, denied []DeniedJTI) error {
+	o := ts.op("DenyJTIs", ts.tcfg.DenylistCName)
+	o.set("documents", len(denied))
+
+	return ts.run(ctx, o, func(ctx context.Context) error {
+		models := make([]mongo.WriteModel, 0, len(denied))
+
+		for _, d := range denied {
+			if err := requireArg("jti", d.JTI); err != nil {
+				return err
+			}
+
+			models = append(models, ts.denyModel(d))
+		}
+
+		if len(models) == 0 {
+			return nil
+		}
+
+		return ts.colHandler(ctx, ts.tcfg.DenylistCName, func(ctx context.Context, c *mongo.Collection) error {
+			_, err := c.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+			return err
+		})
+	})
+}
+
+// IsDenied report whether the JWT id is revoked, a single lookup by _id on the primary. Entries past their
+// expiry are ignored even before the TTL monitor removes them.
+func (ts *TokenStore) IsDenied(ctx context.Context, jti string) (denied bool, err error) {
+	o := ts.op("IsDenied", ts.tcfg.DenylistCName)
+
+	err = ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("jti", jti); err != nil {
+			return err
+		}
+
+		denied, err = ts.denied(ctx, jti)
+		return err
+	})
+
+	return
+}
+
+// denied look the JWT id up in the denylist
+func (ts *TokenStore) denied(ctx context.Context, jti string) (denied bool, err error) {
+	err = ts.readHandler(ctx, ts.tcfg.DenylistCName, true, func(ctx context.Context, c *mongo.Collection) error {
+		err := c.FindOne(ctx,
+			bson.M{"_id": jti, ts.fields().ExpiredAt: bson.M{"$gt": time.Now()}},
+			options.FindOne().SetProjection(bson.M{"_id": 1}),
+		).Err()
+
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+
+		denied = err == nil
+		return err
+	})
+
+	return
+}
@@ -0,0 +1,128 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDenylist(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestTokenStore(t)
+
+	if err := ts.DenyJTI(ctx, "revoked", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.DenyJTIs(ctx, []DeniedJTI{
+		{JTI: "bulk1", ExpiresAt: time.Now().Add(time.Hour)},
+		{JTI: "bulk2", ExpiresAt: time.Now().Add(time.Hour)},
+		{JTI: "expired", ExpiresAt: time.Now().Add(-time.Second)},
+		{JTI: "expiring", ExpiresAt: time.Now().Add(500 * time.Millisecond)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for jti, want := range map[string]bool{"revoked": true, "bulk1": true, "bulk2": true, "expiring": true, "expired": false, "unknown": false} {
+		if denied, err := ts.IsDenied(ctx, jti); err != nil || denied != want {
+			t.Errorf("%s denied %v: %v, want %v", jti, denied, err, want)
+		}
+	}
+
+	// gone once expired, before the TTL monitor removes it
+	time.Sleep(600 * time.Millisecond)
+
+	if denied, err := ts.IsDenied(ctx, "expiring"); err != nil || denied {
+		t.Errorf("expired entry denied %v: %v", denied, err)
+	}
+
+	// denying again keeps the latest expiry
+	if err := ts.DenyJTI(ctx, "revoked", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if denied, err := ts.IsDenied(ctx, "revoked"); err != nil || !denied {
+		t.Errorf("revoked denied %v: %v after an earlier expiry", denied, err)
+	}
+
+	for name, err := range map[string]error{
+		"deny":      ts.DenyJTI(ctx, "", time.Now()),
+		"is denied": func() error { _, err := ts.IsDenied(ctx, ""); return err }(),
+	} {
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s empty jti: %v", name, err)
+		}
+	}
+}
+
+// IsDenied is a single find by _id, served by the _id index
+func TestIsDeniedSingleLookup(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+
+	ts := testTokenStore(cfg)
+	defer ts.Close()
+
+	ctx := context.Background()
+
+	if err := ts.DenyJTI(ctx, "revoked", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	rec.reset()
+
+	if _, err := ts.IsDenied(ctx, "revoked"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec.mu.Lock()
+	commands := len(rec.commands)
+	rec.mu.Unlock()
+
+	finds := rec.named("find")
+
+	if commands != 1 || len(finds) != 1 {
+		t.Fatalf("%d commands, %d finds", commands, len(finds))
+	}
+
+	find := finds[0]
+
+	if find.Lookup("find").StringValue() != "oauth2_denylist" || find.Lookup("filter", "_id").StringValue() != "revoked" {
+		t.Fatalf("find %s", find)
+	}
+
+	if limit, ok := find.Lookup("limit").AsInt64OK(); !ok || limit != 1 {
+		t.Fatalf("find without limit 1: %s", find)
+	}
+}
+
+// a configuration written before the denylist existed gets the default collection
+func TestDenylistDefaultCollection(t *testing.T) {
+	ctx := context.Background()
+
+	tcfg := &TokenConfig{BasicCName: "basic", AccessCName: "access", RefreshCName: "refresh"}
+	db := testDatabase(t)
+
+	for name, ts := range map[string]*TokenStore{
+		"config":             testTokenStoreWithDB(db, tcfg),
+		"with token config":  testTokenStoreWithDB(db, WithTokenConfig(tcfg)),
+		"empty option names": testTokenStoreWithDB(db, WithCollectionNames(CollectionNames{})),
+	} {
+		if ts.tcfg.DenylistCName != "oauth2_denylist" {
+			t.Fatalf("%s: denylist collection %q", name, ts.tcfg.DenylistCName)
+		}
+	}
+
+	ts := testTokenStoreWithDB(db, tcfg)
+
+	if err := ts.DenyJTI(ctx, "revoked", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := db.Collection("oauth2_denylist").CountDocuments(ctx, map[string]string{"_id": "revoked"}); err != nil || n != 1 {
+		t.Fatalf("%d denylist entries: %v", n, err)
+	}
+}
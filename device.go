@@ -0,0 +1,355 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fields of the device authorization documents, keyed by device code
+const (
+	deviceUserCodeField     = "user_code"
+	deviceUserCodeNormField = "user_code_norm"
+	deviceClientField       = "client_id"
+	deviceScopesField       = "scopes"
+	deviceIntervalField     = "interval"
+	deviceStatusField       = "status"
+	deviceUserField         = "user_id"
+	deviceCreatedField      = "created_at"
+	deviceDecidedField      = "decided_at"
+	deviceExpiresField      = "expires_at"
+)
+
+// DeviceAuthStatus state of a device authorization
+type DeviceAuthStatus string
+
+// device authorization states
+const (
+	// waiting for the user to enter the user code
+	DeviceAuthPending  DeviceAuthStatus = "pending"
+	DeviceAuthApproved DeviceAuthStatus = "approved"
+	DeviceAuthDenied   DeviceAuthStatus = "denied"
+)
+
+// DeviceAuthorization a RFC 8628 device authorization
+type DeviceAuthorization struct {
+	// polled by the device at the token endpoint
+	DeviceCode string
+	// entered by the user at the verification URI, matched ignoring case, dashes and spaces
+	UserCode string
+	ClientID string
+	Scopes   []string
+	// minimum polling interval of the device(whole seconds)
+	Interval  time.Duration
+	ExpiresAt time.Time
+	// set by the store, SaveDeviceAuth always stores DeviceAuthPending
+	Status DeviceAuthStatus
+	// user who approved or denied the authorization
+	UserID    string
+	CreatedAt time.Time
+	// time of the approval or denial
+	DecidedAt time.Time
+}
+
+// normalizeUserCode the user code compared by the lookups, e.g. wdjb-mjht and WDJB MJHT match WDJBMJHT
+func normalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+
+		return unicode.ToUpper(r)
+	}, code)
+}
+
+// DeviceStore the RFC 8628 device authorizations, from the device authorization request to the token request of
+// the approved device. It shares the connection, configuration and hooks of the token store it is created from,
+// and a TTL index on its collection(TokenConfig.DeviceCName) removes the expired authorizations.
+type DeviceStore struct {
+	ts *TokenStore
+}
+
+// NewDeviceStore create the device authorization store of ts and its indexes, unless a TenantResolver is
+// configured: call EnsureIndexes then for every tenant
+func NewDeviceStore(ts *TokenStore) *DeviceStore {
+	s := &DeviceStore{ts: ts}
+	ts.initCollectionIndexes(ts.tcfg.DeviceCName, s.indexes())
+
+	return s
+}
+
+// indexes the unique normalized user code index and the TTL index
+func (s *DeviceStore) indexes() []mongo.IndexModel {
+	return []mongo.IndexModel{uniqueIndex(deviceUserCodeNormField), expiryIndex(deviceExpiresField)}
+}
+
+// EnsureIndexes create the indexes of the device authorizations collection in the database of the tenant of ctx
+func (s *DeviceStore) EnsureIndexes(ctx context.Context) error {
+	return s.ts.routedIndexes(ctx, s.ts.tcfg.DeviceCName, s.indexes())
+}
+
+func (s *DeviceStore) op(name string) *operation {
+	return newOperation("device", name, s.ts.tcfg.DeviceCName)
+}
+
+// SaveDeviceAuth store the pending authorization of the device authorization request. A device or user code
+// already in use returns a DuplicateKeyError, generate another one then.
+func (s *DeviceStore) SaveDeviceAuth(ctx context.Context, auth DeviceAuthorization) error {
+	o := s.op("SaveDeviceAuth")
+	o.set("client_id", auth.ClientID)
+	o.sensitive(auth.DeviceCode, auth.UserCode, normalizeUserCode(auth.UserCode))
+
+	return s.ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("device code", auth.DeviceCode); err != nil {
+			return err
+		}
+
+		if err := requireArg("user code", normalizeUserCode(auth.UserCode)); err != nil {
+			return err
+		}
+
+		if err := requireArg("client ID", auth.ClientID); err != nil {
+			return err
+		}
+
+		if auth.ExpiresAt.IsZero() {
+			return fmt.Errorf("%w: device authorization without expiry", ErrInvalidArgument)
+		}
+
+		if auth.Interval < 0 {
+			return fmt.Errorf("%w: negative polling interval", ErrInvalidArgument)
+		}
+
+		scopes := auth.Scopes
+
+		if scopes == nil {
+			scopes = []string{}
+		}
+
+		doc := bson.D{
+			{Key: "_id", Value: auth.DeviceCode},
+			{Key: deviceUserCodeField, Value: auth.UserCode},
+			{Key: deviceUserCodeNormField, Value: normalizeUserCode(auth.UserCode)},
+			{Key: deviceClientField, Value: auth.ClientID},
+			{Key: deviceScopesField, Value: scopes},
+			{Key: deviceIntervalField, Value: int64(auth.Interval / time.Second)},
+			{Key: deviceStatusField, Value: string(DeviceAuthPending)},
+			{Key: deviceCreatedField, Value: time.Now()},
+			{Key: deviceExpiresField, Value: auth.ExpiresAt},
+		}
+
+		return s.ts.colHandler(ctx, s.ts.tcfg.DeviceCName, func(ctx context.Context, c *mongo.Collection) error {
+			_, err := c.InsertOne(ctx, doc)
+
+			if mongo.IsDuplicateKeyError(err) {
+				dup := &DuplicateKeyError{Field: "_id", Index: "_id_", Err: err}
+
+				if index := *uniqueIndex(deviceUserCodeNormField).Options.Name; strings.Contains(err.Error(), "index: "+index+" ") {
+					dup.Field, dup.Index = deviceUserCodeField, index
+				}
+
+				return dup
+			}
+
+			return err
+		})
+	})
+}
+
+// decodeDeviceAuth the device authorization of a stored document
+func decodeDeviceAuth(raw bson.Raw) *DeviceAuthorization {
+	return &DeviceAuthorization{
+		DeviceCode: lookupString(raw, []string{"_id"}),
+		UserCode:   lookupString(raw, []string{deviceUserCodeField}),
+		ClientID:   lookupString(raw, []string{deviceClientField}),
+		Scopes:     lookupStrings(raw, []string{deviceScopesField}),
+		Interval:   time.Duration(lookupInt64(raw, []string{deviceIntervalField})) * time.Second,
+		ExpiresAt:  lookupTime(raw, []string{deviceExpiresField}),
+		Status:     DeviceAuthStatus(lookupString(raw, []string{deviceStatusField})),
+		UserID:     lookupString(raw, []string{deviceUserField}),
+		CreatedAt:  lookupTime(raw, []string{deviceCreatedField}),
+		DecidedAt:  lookupTime(raw, []string{deviceDecidedField}),
+	}
+}
+
+// find the device authorization matching filter, ErrDeviceAuthExpired for one the TTL monitor hasn't removed yet
+func (s *DeviceStore) find(ctx context.Context, c *mongo.Collection, filter bson.M) (*DeviceAuthorization, error) {
+	raw, err := c.FindOne(ctx, filter).DecodeBytes()
+
+	if err == mongo.ErrNoDocuments {
+		return nil, errDeviceAuthNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	auth := decodeDeviceAuth(raw)
+
+	if !time.Now().Before(auth.ExpiresAt) {
+		return nil, errDeviceAuthExpired
+	}
+
+	return auth, nil
+}
+
+// get the device authorization matching filter, read from the primary as the device polls right after the approval
+func (s *DeviceStore) get(ctx context.Context, o *operation, filter bson.M) (auth *DeviceAuthorization, err error) {
+	err = s.ts.run(ctx, o, func(ctx context.Context) error {
+		return s.ts.readHandler(ctx, s.ts.tcfg.DeviceCName, true, func(ctx context.Context, c *mongo.Collection) (err error) {
+			auth, err = s.find(ctx, c, filter)
+
+			if auth != nil {
+				o.set("client_id", auth.ClientID)
+			}
+
+			return
+		})
+	})
+
+	return
+}
+
+// GetByUserCode the device authorization of the user code entered at the verification URI, e.g. to show the
+// client and scopes before the approval. The code is matched ignoring case, dashes and spaces.
+func (s *DeviceStore) GetByUserCode(ctx context.Context, userCode string) (*DeviceAuthorization, error) {
+	o := s.op("GetByUserCode")
+	o.sensitive(userCode, normalizeUserCode(userCode))
+
+	if err := requireArg("user code", normalizeUserCode(userCode)); err != nil {
+		return nil, s.ts.run(ctx, o, func(context.Context) error { return err })
+	}
+
+	return s.get(ctx, o, bson.M{deviceUserCodeNormField: normalizeUserCode(userCode)})
+}
+
+// GetByDeviceCode the device authorization of the device code
+func (s *DeviceStore) GetByDeviceCode(ctx context.Context, deviceCode string) (*DeviceAuthorization, error) {
+	o := s.op("GetByDeviceCode")
+	o.sensitive(deviceCode)
+
+	if err := requireArg("device code", deviceCode); err != nil {
+		return nil, s.ts.run(ctx, o, func(context.Context) error { return err })
+	}
+
+	return s.get(ctx, o, bson.M{"_id": deviceCode})
+}
+
+// decide record the decision of the user on the pending authorization of the user code
+func (s *DeviceStore) decide(ctx context.Context, o *operation, userCode, userID string, status DeviceAuthStatus) error {
+	code := normalizeUserCode(userCode)
+	o.sensitive(userCode, code)
+
+	return s.ts.run(ctx, o, func(ctx context.Context) error {
+
+		if err := requireArg("user code", code); err != nil {
+			return err
+		}
+
+		if err := requireArg("user ID", userID); err != nil {
+			return err
+		}
+
+		return s.ts.colHandler(ctx, s.ts.tcfg.DeviceCName, func(ctx context.Context, c *mongo.Collection) error {
+			filter := bson.M{
+				deviceUserCodeNormField: code,
+				deviceStatusField:       string(DeviceAuthPending),
+				deviceExpiresField:      bson.M{"$gt": time.Now()},
+			}
+			update := bson.M{"$set": bson.M{
+				deviceStatusField:  string(status),
+				deviceUserField:    userID,
+				deviceDecidedField: time.Now(),
+			}}
+
+			res, err := c.UpdateOne(ctx, filter, update)
+
+			if err != nil {
+				return err
+			}
+
+			if res.MatchedCount == 1 {
+				return nil
+			}
+
+			// tell a missing or expired authorization from a decided one
+			if _, err := s.find(ctx, c, bson.M{deviceUserCodeNormField: code}); err != nil {
+				return err
+			}
+
+			return ErrDeviceAuthDecided
+		})
+	})
+}
+
+// Approve record that the user approved the pending authorization of the user code, the next poll of the device
+// gets its token. A decided authorization returns ErrDeviceAuthDecided, an expired one ErrDeviceAuthExpired.
+func (s *DeviceStore) Approve(ctx context.Context, userCode, userID string) error {
+	return s.decide(ctx, s.op("Approve"), userCode, userID, DeviceAuthApproved)
+}
+
+// Deny record that the user denied the pending authorization of the user code, the next polls of the device
+// return ErrDeviceAuthDenied
+func (s *DeviceStore) Deny(ctx context.Context, userCode, userID string) error {
+	return s.decide(ctx, s.op("Deny"), userCode, userID, DeviceAuthDenied)
+}
+
+// Consume remove the approved authorization of the device code and return it, a single atomic FindOneAndDelete
+// so only one token is issued. The polls before return ErrAuthorizationPending, or ErrDeviceAuthDenied when the
+// user denied it, ErrDeviceAuthExpired once expired and ErrDeviceAuthNotFound after it was consumed.
+func (s *DeviceStore) Consume(ctx context.Context, deviceCode string) (auth *DeviceAuthorization, err error) {
+	o := s.op("Consume")
+	o.sensitive(deviceCode)
+
+	err = s.ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("device code", deviceCode); err != nil {
+			return err
+		}
+
+		return s.ts.colHandler(ctx, s.ts.tcfg.DeviceCName, func(ctx context.Context, c *mongo.Collection) error {
+			filter := bson.M{
+				"_id":              deviceCode,
+				deviceStatusField:  string(DeviceAuthApproved),
+				deviceExpiresField: bson.M{"$gt": time.Now()},
+			}
+			raw, err := c.FindOneAndDelete(ctx, filter, options.FindOneAndDelete()).DecodeBytes()
+
+			if err == nil {
+				auth = decodeDeviceAuth(raw)
+				o.set("client_id", auth.ClientID)
+
+				return nil
+			}
+
+			if err != mongo.ErrNoDocuments {
+				return err
+			}
+
+			pending, err := s.find(ctx, c, bson.M{"_id": deviceCode})
+
+			if err != nil {
+				return err
+			}
+
+			o.set("client_id", pending.ClientID)
+
+			if pending.Status == DeviceAuthDenied {
+				return ErrDeviceAuthDenied
+			}
+
+			return ErrAuthorizationPending
+		})
+	})
+
+	if err != nil {
+		auth = nil
+	}
+
+	return
+}
@@ -0,0 +1,225 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDeviceAuth the device authorization of RFC 8628 section 3.2
+func testDeviceAuth() DeviceAuthorization {
+	return DeviceAuthorization{
+		DeviceCode: "GmRhmhcxhwAzkoEqiMEg_DnyEysNkuNhszIySk9eS",
+		UserCode:   "WDJB-MJHT",
+		ClientID:   "tv",
+		Scopes:     []string{"read"},
+		Interval:   5 * time.Second,
+		ExpiresAt:  time.Now().Add(30 * time.Minute).Truncate(time.Millisecond),
+	}
+}
+
+func TestDeviceFlow(t *testing.T) {
+	s := NewDeviceStore(newTestTokenStore(t, WithCollectionNames(CollectionNames{Device: "devices"})))
+	ctx := context.Background()
+	want := testDeviceAuth()
+
+	if err := s.SaveDeviceAuth(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+
+	// the device polls before the user enters the code
+	if _, err := s.Consume(ctx, want.DeviceCode); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("poll of a pending authorization: %v, want ErrAuthorizationPending", err)
+	}
+
+	// the user code is matched ignoring case, dashes and spaces
+	for _, code := range []string{"WDJB-MJHT", "wdjb-mjht", "wdjb mjht", "WDJBMJHT"} {
+		auth, err := s.GetByUserCode(ctx, code)
+
+		if err != nil {
+			t.Fatalf("user code %q: %v", code, err)
+		}
+
+		if auth.DeviceCode != want.DeviceCode || auth.UserCode != want.UserCode || auth.ClientID != "tv" ||
+			!reflect.DeepEqual(auth.Scopes, want.Scopes) || auth.Interval != want.Interval ||
+			!auth.ExpiresAt.Equal(want.ExpiresAt) || auth.Status != DeviceAuthPending || auth.CreatedAt.IsZero() {
+			t.Fatalf("device authorization %+v", auth)
+		}
+	}
+
+	if err := s.Approve(ctx, "wdjb-mjht", "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	approved, err := s.GetByDeviceCode(ctx, want.DeviceCode)
+
+	if err != nil || approved.Status != DeviceAuthApproved || approved.UserID != "alice" || approved.DecidedAt.IsZero() {
+		t.Fatalf("approved authorization %+v: %v", approved, err)
+	}
+
+	if err := s.Deny(ctx, "WDJB-MJHT", "alice"); !errors.Is(err, ErrDeviceAuthDecided) {
+		t.Fatalf("denial of an approved authorization: %v, want ErrDeviceAuthDecided", err)
+	}
+
+	// the next poll gets the token, once
+	auth, err := s.Consume(ctx, want.DeviceCode)
+
+	if err != nil || auth.UserID != "alice" || auth.ClientID != "tv" || !reflect.DeepEqual(auth.Scopes, want.Scopes) {
+		t.Fatalf("consumed authorization %+v: %v", auth, err)
+	}
+
+	if auth, err := s.Consume(ctx, want.DeviceCode); !errors.Is(err, ErrDeviceAuthNotFound) || auth != nil {
+		t.Fatalf("authorization consumed again %+v: %v, want ErrDeviceAuthNotFound", auth, err)
+	}
+
+	if _, err := s.GetByUserCode(ctx, "WDJB-MJHT"); !errors.Is(err, ErrDeviceAuthNotFound) {
+		t.Fatalf("user code of a consumed authorization: %v, want ErrDeviceAuthNotFound", err)
+	}
+
+	if err := s.Approve(ctx, "WDJB-MJHT", "alice"); !errors.Is(err, ErrDeviceAuthNotFound) {
+		t.Fatalf("approval of a consumed authorization: %v, want ErrDeviceAuthNotFound", err)
+	}
+}
+
+func TestDeviceDeny(t *testing.T) {
+	s := NewDeviceStore(newTestTokenStore(t))
+	ctx := context.Background()
+	auth := testDeviceAuth()
+
+	if err := s.SaveDeviceAuth(ctx, auth); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Deny(ctx, auth.UserCode, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	// every poll is denied until the authorization expires
+	for i := 0; i < 2; i++ {
+		if _, err := s.Consume(ctx, auth.DeviceCode); !errors.Is(err, ErrDeviceAuthDenied) {
+			t.Fatalf("poll of a denied authorization: %v, want ErrDeviceAuthDenied", err)
+		}
+	}
+
+	if err := s.Approve(ctx, auth.UserCode, "alice"); !errors.Is(err, ErrDeviceAuthDecided) {
+		t.Fatalf("approval of a denied authorization: %v, want ErrDeviceAuthDecided", err)
+	}
+}
+
+func TestDeviceExpiry(t *testing.T) {
+	s := NewDeviceStore(newTestTokenStore(t))
+	ctx := context.Background()
+	auth := testDeviceAuth()
+	auth.ExpiresAt = time.Now().Add(50 * time.Millisecond)
+
+	if err := s.SaveDeviceAuth(ctx, auth); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// expired before the TTL monitor removes it
+	if _, err := s.GetByUserCode(ctx, auth.UserCode); !errors.Is(err, ErrDeviceAuthExpired) || !errors.Is(err, ErrDeviceAuthNotFound) {
+		t.Fatalf("expired user code: %v, want ErrDeviceAuthExpired", err)
+	}
+
+	if err := s.Approve(ctx, auth.UserCode, "alice"); !errors.Is(err, ErrDeviceAuthExpired) {
+		t.Fatalf("approval of an expired authorization: %v, want ErrDeviceAuthExpired", err)
+	}
+
+	if _, err := s.Consume(ctx, auth.DeviceCode); !errors.Is(err, ErrDeviceAuthExpired) {
+		t.Fatalf("poll of an expired authorization: %v, want ErrDeviceAuthExpired", err)
+	}
+}
+
+func TestDeviceUserCodeInUse(t *testing.T) {
+	s := NewDeviceStore(newTestTokenStore(t))
+	ctx := context.Background()
+
+	if err := s.EnsureIndexes(ctx); err != nil {
+		skipNotImplemented(t, err)
+		t.Fatal(err)
+	}
+
+	if err := s.SaveDeviceAuth(ctx, testDeviceAuth()); err != nil {
+		t.Fatal(err)
+	}
+
+	// given in another form
+	other := testDeviceAuth()
+	other.DeviceCode, other.UserCode = "other", "wdjb mjht"
+
+	var de *DuplicateKeyError
+
+	err := s.SaveDeviceAuth(ctx, other)
+
+	if !errors.As(err, &de) || de.Field != deviceUserCodeField {
+		t.Fatalf("user code in use: %v, want a DuplicateKeyError of the user code", err)
+	}
+
+	// the key of the duplicate isn't in the error
+	for _, code := range []string{"WDJBMJHT", "wdjb mjht"} {
+		if strings.Contains(err.Error(), code) {
+			t.Fatalf("error %q with the user code", err)
+		}
+	}
+}
+
+func TestDeviceCodesRedacted(t *testing.T) {
+	var rec logRecorder
+
+	s := NewDeviceStore(newTestTokenStore(t, WithLogger(&rec)))
+	ctx := context.Background()
+	auth := testDeviceAuth()
+
+	if err := s.SaveDeviceAuth(ctx, auth); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GetByUserCode(ctx, "wdjb-mjht"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, notFound := s.GetByUserCode(ctx, "zzzz-zzzz")
+
+	if !errors.Is(notFound, ErrDeviceAuthNotFound) {
+		t.Fatalf("unknown user code: %v, want ErrDeviceAuthNotFound", notFound)
+	}
+
+	if err := s.Approve(ctx, auth.UserCode, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	decided := s.Deny(ctx, "wdjb mjht", "alice")
+
+	if !errors.Is(decided, ErrDeviceAuthDecided) {
+		t.Fatalf("denial of an approved authorization: %v, want ErrDeviceAuthDecided", decided)
+	}
+
+	for _, code := range []string{auth.DeviceCode, "WDJB-MJHT", "WDJBMJHT", "wdjb", "ZZZZ"} {
+		for _, err := range []error{notFound, decided} {
+			if strings.Contains(err.Error(), code) {
+				t.Fatalf("error %q with the code %s", err, code)
+			}
+		}
+
+		if rec.contains(code) {
+			t.Fatalf("code %s logged", code)
+		}
+	}
+
+	for name, err := range map[string]error{
+		"without device code":   s.SaveDeviceAuth(ctx, DeviceAuthorization{UserCode: "A", ClientID: "tv", ExpiresAt: time.Now().Add(time.Minute)}),
+		"blank user code":       s.SaveDeviceAuth(ctx, DeviceAuthorization{DeviceCode: "d", UserCode: " - ", ClientID: "tv", ExpiresAt: time.Now().Add(time.Minute)}),
+		"without client":        s.SaveDeviceAuth(ctx, DeviceAuthorization{DeviceCode: "d", UserCode: "A", ExpiresAt: time.Now().Add(time.Minute)}),
+		"without expiry":        s.SaveDeviceAuth(ctx, DeviceAuthorization{DeviceCode: "d", UserCode: "A", ClientID: "tv"}),
+		"approval without user": s.Approve(ctx, auth.UserCode, ""),
+	} {
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("%s: %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// active restrict filter to the clients which aren't disabled
+func active(filter bson.M) bson.M {
+	return bson.M{"$and": bson.A{filter, bson.M{clientDeletedField: bson.M{"$exists": false}}}}
+}
+
+// Disable soft delete the client: lookups treat it as missing(ErrClientDisabled) while the record is kept
+// until Restore or PurgeDisabled, Set and Update leave it disabled. A missing client returns ErrClientNotFound.
+func (cs *ClientStore) Disable(ctx context.Context, id string) error {
+	now := time.Now()
+
+	return cs.setDisabled(ctx, "Disable", id, bson.M{
+		"$set": bson.M{clientDeletedField: now, clientUpdatedField: now},
+	})
+}
+
+// Restore enable a disabled client again, a missing client returns ErrClientNotFound
+func (cs *ClientStore) Restore(ctx context.Context, id string) error {
+	return cs.setDisabled(ctx, "Restore", id, bson.M{
+		"$set":   bson.M{clientUpdatedField: time.Now()},
+		"$unset": bson.M{clientDeletedField: ""},
+	})
+}
+
+func (cs *ClientStore) setDisabled(ctx context.Context, name, id string, update bson.M) error {
+	o := cs.op(name, cs.ccfg.ClientsCName)
+	o.set("client_id", id)
+
+	return cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", id); err != nil {
+			return err
+		}
+
+		return cs.auditedHandler(ctx, o, id, func(ctx context.Context, c *mongo.Collection) error {
+			res, err := c.UpdateOne(ctx, bson.M{"_id": id}, update, cs.updateOptions())
+
+			if err == nil && res.MatchedCount == 0 {
+				err = errClientNotFound
+			}
+
+			return err
+		})
+	})
+}
+
+// PurgeDisabled delete the clients disabled for longer than ClientConfig.DisabledRetention, returning their number
+func (cs *ClientStore) PurgeDisabled(ctx context.Context) (n int64, err error) {
+	o := cs.op("PurgeDisabled", cs.ccfg.ClientsCName)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		retention := cs.ccfg.DisabledRetention
+
+		if retention <= 0 {
+			retention = 90 * 24 * time.Hour
+		}
+
+		cutoff := time.Now().Add(-retention)
+
+		return cs.colHandler(ctx, cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) error {
+			res, err := c.DeleteMany(ctx, bson.M{clientDeletedField: bson.M{"$lt": cutoff}})
+
+			if err == nil {
+				n = res.DeletedCount
+				o.set("documents", n)
+				cs.metrics().ObserveCleanup(cs.ccfg.ClientsCName, n)
+			}
+
+			return err
+		})
+	})
+
+	return
+}
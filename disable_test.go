@@ -0,0 +1,134 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDisableClient(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// active
+	if _, err := cs.GetByID(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	// disabled: the lookups treat it as missing, the record is kept
+	if err := cs.Disable(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cs.GetByID(ctx, "c"); !errors.Is(err, ErrClientDisabled) || !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("disabled client: %v", err)
+	}
+
+	if infos, err := cs.GetByUserID(ctx, "u", 0); err != nil || len(infos) != 0 {
+		t.Fatalf("clients of the user %v: %v", infos, err)
+	}
+
+	if ids, _ := listAll(t, cs, ClientFilter{}, ListOptions{}); len(ids) != 0 {
+		t.Fatalf("listed %v", ids)
+	}
+
+	infos, _, err := cs.List(ctx, ClientFilter{}, ListOptions{IncludeDisabled: true})
+
+	if err != nil || len(infos) != 1 || infos[0].(*Client).DeletedAt.IsZero() {
+		t.Fatalf("listed with the disabled %v: %v", infos, err)
+	}
+
+	// neither Set nor Update restore it
+	if err := cs.Set(&models.Client{ID: "c", Secret: "other", Domain: "https://example.com", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Update(ctx, &models.Client{ID: "c", Domain: "https://example.org", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cs.GetByID(ctx, "c"); !errors.Is(err, ErrClientDisabled) {
+		t.Fatalf("disabled client after Set and Update: %v", err)
+	}
+
+	// restored with the changes made meanwhile
+	if err := cs.Restore(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := cs.GetByID(ctx, "c"); err != nil || info.GetSecret() != "other" || info.GetDomain() != "https://example.org" {
+		t.Fatalf("restored client %v: %v", info, err)
+	}
+
+	for name, fn := range map[string]func(context.Context, string) error{"Disable": cs.Disable, "Restore": cs.Restore} {
+		if err := fn(ctx, "missing"); !errors.Is(err, ErrClientNotFound) || errors.Is(err, ErrClientDisabled) {
+			t.Errorf("%s of a missing client: %v", name, err)
+		}
+	}
+}
+
+func TestHideDisabledClients(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.HideDisabledClients = true
+
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Disable(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cs.GetByID(ctx, "c"); !errors.Is(err, ErrClientNotFound) || errors.Is(err, ErrClientDisabled) {
+		t.Fatalf("hidden disabled client: %v", err)
+	}
+}
+
+func TestPurgeDisabled(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.DisabledRetention = time.Hour
+
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+
+	for _, id := range []string{"active", "recent", "old", "older"} {
+		if err := cs.Set(&models.Client{ID: id, Secret: "secret", Domain: "https://example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cs.Disable(ctx, "recent"); err != nil {
+		t.Fatal(err)
+	}
+
+	// disabled before the cutoff
+	for id, at := range map[string]time.Time{"old": time.Now().Add(-time.Hour - time.Minute), "older": time.Now().Add(-48 * time.Hour)} {
+		if _, err := cs.Collection().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{clientDeletedField: at}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := cs.PurgeDisabled(ctx); err != nil || n != 2 {
+		t.Fatalf("%d clients purged: %v", n, err)
+	}
+
+	if ids, _ := listAll(t, cs, ClientFilter{}, ListOptions{IncludeDisabled: true}); !reflect.DeepEqual(ids, []string{"active", "recent"}) {
+		t.Fatalf("clients left %v", ids)
+	}
+
+	if n, err := cs.PurgeDisabled(ctx); err != nil || n != 0 {
+		t.Fatalf("%d clients purged again: %v", n, err)
+	}
+}
@@ -0,0 +1,92 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetByDomain the client registered for the domain. A domain without client returns ErrClientNotFound
+// and a domain shared by several clients ErrAmbiguousDomain, see GetAllByDomain.
+func (cs *ClientStore) GetByDomain(ctx context.Context, domain string) (info oauth2.ClientInfo, err error) {
+	o := cs.op("GetByDomain", cs.ccfg.ClientsCName)
+	o.set("domain", domain)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		infos, err := cs.byDomain(ctx, o, domain, 2)
+
+		if err != nil {
+			return err
+		}
+
+		switch len(infos) {
+		case 0:
+			return errClientNotFound
+		case 1:
+			info = infos[0]
+			return nil
+		}
+
+		return ErrAmbiguousDomain
+	})
+
+	return
+}
+
+// GetAllByDomain the clients registered for the domain in ID order, empty if none
+func (cs *ClientStore) GetAllByDomain(ctx context.Context, domain string) (infos []oauth2.ClientInfo, err error) {
+	o := cs.op("GetAllByDomain", cs.ccfg.ClientsCName)
+	o.set("domain", domain)
+
+	err = cs.run(ctx, o, func(ctx context.Context) (err error) {
+		infos, err = cs.byDomain(ctx, o, domain, 0)
+		return
+	})
+
+	return
+}
+
+// byDomain up to limit(0 for all) clients of the domain
+func (cs *ClientStore) byDomain(ctx context.Context, o *operation, domain string, limit int64) ([]oauth2.ClientInfo, error) {
+	if err := requireArg("domain", domain); err != nil {
+		return nil, err
+	}
+
+	match := anyOf(aliases(cs.fields().Domain, func(f FieldNames) string { return f.Domain }), domain)
+
+	// a suspended client can't be used for its domain either
+	return cs.findClients(ctx, o, bson.M{"$and": bson.A{match, statusFilter(ClientActive)}}, limit)
+}
+
+// findClients up to limit(0 for all) active clients matching filter in ID order
+func (cs *ClientStore) findClients(ctx context.Context, o *operation, filter bson.M, limit int64) ([]oauth2.ClientInfo, error) {
+	infos := []oauth2.ClientInfo{}
+	filter = active(filter)
+
+	err := cs.readHandler(ctx, cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) error {
+		cur, err := c.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}).SetLimit(limit))
+
+		if err != nil {
+			return err
+		}
+
+		defer cur.Close(ctx)
+
+		for cur.Next(ctx) {
+			info, err := cs.clientInfo(o, cur.Current)
+
+			if err != nil {
+				return err
+			}
+
+			infos = append(infos, info)
+		}
+
+		return cur.Err()
+	})
+
+	return infos, err
+}
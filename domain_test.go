@@ -0,0 +1,101 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// indexNames the names of the indexes of the clients collection
+func indexNames(t *testing.T, cs *ClientStore) []string {
+	t.Helper()
+
+	ctx := context.Background()
+	cur, err := cs.Collection().Indexes().List(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var specs []bson.M
+
+	if err := cur.All(ctx, &specs); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+
+	for _, spec := range specs {
+		names = append(names, spec["name"].(string))
+	}
+
+	return names
+}
+
+// hasIndex report whether name is among the indexes
+func hasIndex(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestGetByDomain(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if names := indexNames(t, cs); !hasIndex(names, "domain_1") {
+		t.Fatalf("indexes %v", names)
+	}
+
+	for _, c := range []*models.Client{
+		{ID: "unique", Domain: "https://unique.example.com"},
+		{ID: "shared-b", Domain: "https://shared.example.com"},
+		{ID: "shared-a", Domain: "https://shared.example.com"},
+	} {
+		c.Secret = "secret"
+
+		if err := cs.Set(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if info, err := cs.GetByDomain(ctx, "https://unique.example.com"); err != nil || info.GetID() != "unique" {
+		t.Fatalf("client of the domain %v: %v", info, err)
+	}
+
+	if _, err := cs.GetByDomain(ctx, "https://shared.example.com"); !errors.Is(err, ErrAmbiguousDomain) {
+		t.Fatalf("shared domain: %v", err)
+	}
+
+	if _, err := cs.GetByDomain(ctx, "https://missing.example.com"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing domain: %v", err)
+	}
+
+	// every match, in ID order
+	infos, err := cs.GetAllByDomain(ctx, "https://shared.example.com")
+
+	if err != nil || !reflect.DeepEqual(listIDs(infos), []string{"shared-a", "shared-b"}) {
+		t.Fatalf("clients of the shared domain %v: %v", listIDs(infos), err)
+	}
+
+	if infos, err := cs.GetAllByDomain(ctx, "https://missing.example.com"); err != nil || infos == nil || len(infos) != 0 {
+		t.Fatalf("clients of a missing domain %v: %v", infos, err)
+	}
+
+	// a suspended client no longer owns its domain
+	if err := cs.SetStatus(ctx, "shared-a", ClientSuspended); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := cs.GetByDomain(ctx, "https://shared.example.com"); err != nil || info.GetID() != "shared-b" {
+		t.Fatalf("client of the domain %v: %v", info, err)
+	}
+}
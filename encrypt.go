@@ -0,0 +1,197 @@
+package mongo
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// Encrypter application level encryption of the token payloads, the key id returned by Encrypt
+// is stored on every document so payloads encrypted with previous keys remain readable
+type Encrypter interface {
+	Encrypt(plaintext []byte) (keyID string, ciphertext []byte, err error)
+	Decrypt(keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// DecryptError returned when a stored payload can't be decrypted: unknown key, wrong key or corrupted ciphertext
+type DecryptError struct {
+	KeyID string
+	Err   error
+}
+
+func (e *DecryptError) Error() string {
+	return fmt.Sprintf("mongo: decrypt payload with key %q: %v", e.KeyID, e.Err)
+}
+
+// Unwrap the underlying error
+func (e *DecryptError) Unwrap() error {
+	return e.Err
+}
+
+// errUnknownKey decryption key not configured
+var errUnknownKey = errors.New("unknown key")
+
+// AESGCMEncrypter AES-256-GCM encryption, the random nonce is prepended to the ciphertext
+type AESGCMEncrypter struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewAESGCMEncrypter create an encrypter from 32 bytes keys indexed by key id, payloads are encrypted
+// with the current key and decrypted with any of them
+func NewAESGCMEncrypter(current string, keys map[string][]byte) (*AESGCMEncrypter, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%w: key %q is missing", ErrInvalidConfig, current)
+	}
+
+	e := &AESGCMEncrypter{current: current, aeads: make(map[string]cipher.AEAD, len(keys))}
+
+	for id, key := range keys {
+		if id == "" {
+			return nil, fmt.Errorf("%w: empty key id", ErrInvalidConfig)
+		}
+
+		if len(key) != 32 {
+			return nil, fmt.Errorf("%w: key %q must be 32 bytes", ErrInvalidConfig, id)
+		}
+
+		block, err := aes.NewCipher(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := cipher.NewGCM(block)
+
+		if err != nil {
+			return nil, err
+		}
+
+		e.aeads[id] = aead
+	}
+
+	return e, nil
+}
+
+// Encrypt encrypt with the current key
+func (e *AESGCMEncrypter) Encrypt(plaintext []byte) (string, []byte, error) {
+	aead := e.aeads[e.current]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", nil, err
+	}
+
+	return e.current, aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt decrypt with the key keyID
+func (e *AESGCMEncrypter) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	aead, ok := e.aeads[keyID]
+
+	if !ok {
+		return nil, &DecryptError{KeyID: keyID, Err: errUnknownKey}
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, &DecryptError{KeyID: keyID, Err: errors.New("ciphertext too short")}
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+
+	if err != nil {
+		return nil, &DecryptError{KeyID: keyID, Err: err}
+	}
+
+	return plaintext, nil
+}
+
+// seal encrypt the serialized payload of info when an encrypter or a key resolver is configured,
+// returning the tenant whose key was used
+func (ts *TokenStore) seal(ctx context.Context, info oauth2.TokenInfo, data []byte) (tenantID, keyID string, ciphertext []byte, err error) {
+	e := ts.tcfg.Encrypter
+
+	if ts.tcfg.KeyResolver != nil {
+		tenantID = ts.tenantOf(ctx, info)
+
+		if e, err = ts.tenantEncrypter(ctx, tenantID, ""); err != nil {
+			return
+		}
+	}
+
+	if e == nil {
+		return "", "", data, nil
+	}
+
+	keyID, ciphertext, err = e.Encrypt(data)
+
+	return
+}
+
+// open decrypt the stored payload, documents without key id were stored in plaintext
+// and documents without tenant were encrypted by the Encrypter
+func (ts *TokenStore) open(ctx context.Context, tenantID, keyID string, data []byte) ([]byte, error) {
+	if keyID == "" {
+		return data, nil
+	}
+
+	e := ts.tcfg.Encrypter
+
+	if tenantID != "" && ts.tcfg.KeyResolver != nil {
+		var err error
+
+		if e, err = ts.tenantEncrypter(ctx, tenantID, keyID); err != nil {
+			return nil, err
+		}
+	}
+
+	if e == nil {
+		return nil, &DecryptError{KeyID: keyID, Err: errors.New("no encrypter configured")}
+	}
+
+	return e.Decrypt(keyID, data)
+}
+
+// sealSecret encrypt the client secret when an encrypter is configured, base64 encoded so the field stays a string
+func (cs *ClientStore) sealSecret(secret string) (string, string, error) {
+	if cs.ccfg.Encrypter == nil || secret == "" {
+		return "", secret, nil
+	}
+
+	keyID, ciphertext, err := cs.ccfg.Encrypter.Encrypt([]byte(secret))
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return keyID, base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// openSecret decrypt the stored client secret, documents without key id were stored in plaintext
+func (cs *ClientStore) openSecret(keyID, stored string) (string, error) {
+	if keyID == "" {
+		return stored, nil
+	}
+
+	if cs.ccfg.Encrypter == nil {
+		return "", &DecryptError{KeyID: keyID, Err: errors.New("no encrypter configured")}
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(stored)
+
+	if err != nil {
+		return "", &DecryptError{KeyID: keyID, Err: err}
+	}
+
+	secret, err := cs.ccfg.Encrypter.Decrypt(keyID, ciphertext)
+
+	return string(secret), err
+}
@@ -0,0 +1,212 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// testEncrypter an AES-GCM encrypter of the keys derived from their ids, encrypting with current
+func testEncrypter(t *testing.T, current string, ids ...string) *AESGCMEncrypter {
+	t.Helper()
+
+	keys := make(map[string][]byte)
+
+	for _, id := range append(ids, current) {
+		key := sha256.Sum256([]byte(id))
+		keys[id] = key[:]
+	}
+
+	e, err := NewAESGCMEncrypter(current, keys)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+func TestEncrypterRoundTrip(t *testing.T) {
+	e := testEncrypter(t, "k1")
+
+	keyID, ciphertext, err := e.Encrypt([]byte("payload"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keyID != "k1" || bytes.Contains(ciphertext, []byte("payload")) {
+		t.Fatalf("key %s ciphertext %x", keyID, ciphertext)
+	}
+
+	plaintext, err := e.Decrypt(keyID, ciphertext)
+
+	if err != nil || string(plaintext) != "payload" {
+		t.Fatalf("plaintext %q: %v", plaintext, err)
+	}
+}
+
+func TestNewAESGCMEncrypterInvalid(t *testing.T) {
+	for name, keys := range map[string]map[string][]byte{
+		"missing current": {"other": make([]byte, 32)},
+		"short key":       {"k1": make([]byte, 16)},
+		"empty key id":    {"k1": make([]byte, 32), "": make([]byte, 32)},
+	} {
+		if _, err := NewAESGCMEncrypter("k1", keys); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestEncryptedPayloads(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	// written before encryption was enabled
+	if err := testTokenStoreWithDB(db).Create(ctx, testToken("plain", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := testTokenStoreWithDB(db, WithEncrypter(testEncrypter(t, "k1")))
+	token := testToken("access", "refresh")
+
+	if err := ts.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ts.Collection(CollectionBasic).FindOne(ctx, bson.M{"KeyID": "k1"}).DecodeBytes()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(raw, []byte("access")) || bytes.Contains(raw, []byte(`"client_id"`)) {
+		t.Fatalf("plaintext payload stored: %s", raw)
+	}
+
+	got, err := ts.GetByAccess(ctx, "access")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireSameToken(t, got, token)
+
+	if _, err := ts.GetByAccess(ctx, "plain"); err != nil {
+		t.Fatalf("payload stored before encryption: %v", err)
+	}
+
+	// the previous key still decrypts after a rotation
+	rotated := testTokenStoreWithDB(db, WithEncrypter(testEncrypter(t, "k2", "k1")))
+
+	if _, err := rotated.GetByRefresh(ctx, "refresh"); err != nil {
+		t.Fatalf("payload of the previous key: %v", err)
+	}
+
+	// without the key
+	var de *DecryptError
+
+	if _, err := testTokenStoreWithDB(db, WithEncrypter(testEncrypter(t, "k2"))).GetByAccess(ctx, "access"); !errors.As(err, &de) || de.KeyID != "k1" {
+		t.Fatalf("payload of an unknown key: %v", err)
+	}
+}
+
+func TestEncryptedPayloadCorrupted(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+	ts := testTokenStoreWithDB(db, WithEncrypter(testEncrypter(t, "k1")))
+
+	if err := ts.Create(ctx, testToken("access", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	basic := ts.Collection(CollectionBasic)
+	raw, err := basic.FindOne(ctx, testKindFilter(ts, CollectionBasic, bson.M{})).DecodeBytes()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, data := raw.Lookup("Data").Binary()
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)-1] ^= 0xff
+
+	if _, err := basic.UpdateOne(ctx, bson.M{"_id": raw.Lookup("_id")}, bson.M{"$set": bson.M{"Data": corrupted}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var de *DecryptError
+
+	if _, err := ts.GetByAccess(ctx, "access"); !errors.As(err, &de) || de.KeyID != "k1" {
+		t.Fatalf("corrupted payload: %v", err)
+	}
+}
+
+func TestEncryptedSecrets(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	// stored before encryption was enabled
+	if err := NewClientStoreWithDB(db).Set(&models.Client{ID: "legacy", Secret: "legacy-secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cs := NewClientStoreWithDB(db, WithSecretEncrypter(testEncrypter(t, "k1")))
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored := storedSecret(t, db, "c"); stored == "" || stored == "secret" {
+		t.Fatalf("stored secret %q", stored)
+	}
+
+	for id, secret := range map[string]string{"c": "secret", "legacy": "legacy-secret"} {
+		info, err := cs.GetByID(ctx, id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.GetSecret() != secret {
+			t.Fatalf("%s secret %q, want %q", id, info.GetSecret(), secret)
+		}
+
+		if ok, err := cs.VerifySecret(ctx, id, secret); !ok || err != nil {
+			t.Fatalf("%s secret verified %v: %v", id, ok, err)
+		}
+	}
+
+	// the previous key still decrypts after a rotation
+	if info, err := NewClientStoreWithDB(db, WithSecretEncrypter(testEncrypter(t, "k2", "k1"))).GetByID(ctx, "c"); err != nil || info.GetSecret() != "secret" {
+		t.Fatalf("secret of the previous key %v: %v", info, err)
+	}
+
+	// with the wrong key or without encrypter
+	var de *DecryptError
+
+	for name, store := range map[string]*ClientStore{
+		"wrong key":    NewClientStoreWithDB(db, WithSecretEncrypter(testEncrypter(t, "k2"))),
+		"no encrypter": NewClientStoreWithDB(db),
+	} {
+		if _, err := store.GetByID(ctx, "c"); !errors.As(err, &de) || de.KeyID != "k1" {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	// same key id, other key material
+	other, err := NewAESGCMEncrypter("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewClientStoreWithDB(db, WithSecretEncrypter(other)).GetByID(ctx, "c"); !errors.As(err, &de) {
+		t.Fatalf("other key material: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidConfig returned when the connection configuration is incomplete or conflicting
+var ErrInvalidConfig = errors.New("mongo: invalid config")
+
+// ErrUnknownCodec returned when a stored payload was written with a codec the store doesn't know
+var ErrUnknownCodec = errors.New("mongo: unknown payload codec")
+
+// ErrInvalidArgument returned before any database access when a token, code or client ID
+// is empty or the token information is incomplete
+var ErrInvalidArgument = errors.New("mongo: invalid argument")
+
+// ErrStoreClosed returned by operations started after Shutdown or Close was called
+var ErrStoreClosed = errors.New("mongo: store is closed")
+
+// ErrClientNotFound returned when no client has the given ID,
+// errors.Is(err, mongo.ErrNoDocuments) holds as well
+var ErrClientNotFound = errors.New("mongo: client not found")
+
+// ErrClientDisabled returned when the client was disabled by Disable or suspended by SetStatus, errors.Is(err, ErrClientNotFound) holds as well
+var ErrClientDisabled = errors.New("mongo: client is disabled")
+
+// ErrPublicClient returned by VerifySecret for a public client, which must not authenticate with a secret
+var ErrPublicClient = errors.New("mongo: public client has no secret")
+
+// ErrInvalidClientCredentials returned by GetByIDAndSecret for an unknown client ID as well as a wrong secret
+var ErrInvalidClientCredentials = errors.New("mongo: invalid client credentials")
+
+// ErrSecretNotAllowed returned by VerifySecret when the client authenticates without its secret, see AuthMethodError
+var ErrSecretNotAllowed = errors.New("mongo: client doesn't authenticate with a secret")
+
+// AuthMethodError a secret verification refused by the token endpoint authentication method of the client
+type AuthMethodError struct {
+	ClientID string
+	// none or private_key_jwt
+	Method string
+}
+
+func (e *AuthMethodError) Error() string {
+	return fmt.Sprintf("mongo: client %s authenticates with %s, not a secret", e.ClientID, e.Method)
+}
+
+// Is match ErrSecretNotAllowed, and ErrPublicClient for the clients of the none method
+func (e *AuthMethodError) Is(target error) bool {
+	return target == ErrSecretNotAllowed || (target == ErrPublicClient && e.Method == AuthMethodNone)
+}
+
+// ErrSecretExpired returned by VerifySecret when the secret matches but passed its SecretExpiresAt
+var ErrSecretExpired = errors.New("mongo: client secret expired")
+
+// ErrSecretChanged returned by RotateSecret when the secret was changed concurrently
+var ErrSecretChanged = errors.New("mongo: client secret changed concurrently")
+
+// ErrInvalidRegistrationToken returned by VerifyRegistrationToken for a wrong registration access token
+var ErrInvalidRegistrationToken = errors.New("mongo: invalid registration access token")
+
+// ErrQuotaExceeded returned when a write would give a user more than ClientConfig.MaxClientsPerUser clients,
+// see QuotaError
+var ErrQuotaExceeded = errors.New("mongo: clients per user quota exceeded")
+
+// ErrOwnerChanged returned by TransferOwnership when the owner was changed concurrently
+var ErrOwnerChanged = errors.New("mongo: client owner changed concurrently")
+
+// ErrConsentNotFound returned by ConsentStore.Get when the user granted nothing to the client or the consent expired
+var ErrConsentNotFound = errors.New("mongo: consent not found")
+
+// ErrRequestURINotFound returned by PARStore.Consume for an unknown or already consumed request_uri
+var ErrRequestURINotFound = errors.New("mongo: request_uri not found")
+
+// ErrRequestURIExpired returned by PARStore.Consume for a request_uri past its expiry, errors.Is(err,
+// ErrRequestURINotFound) holds as well
+var ErrRequestURIExpired = errors.New("mongo: request_uri expired")
+
+// ErrKeyNotFound returned by the KeyStore for an unknown key ID
+var ErrKeyNotFound = errors.New("mongo: signing key not found")
+
+// ErrNoActiveKey returned by KeyStore.ActiveKey when every key is retired or past its signing period
+var ErrNoActiveKey = errors.New("mongo: no active signing key")
+
+// ErrStateNotFound returned by StateStore.Take for an unknown, already taken or expired key
+var ErrStateNotFound = errors.New("mongo: state not found")
+
+// ErrDeviceAuthNotFound returned by the DeviceStore for an unknown or consumed device or user code
+var ErrDeviceAuthNotFound = errors.New("mongo: device authorization not found")
+
+// ErrDeviceAuthExpired returned by the DeviceStore for a device authorization past its expiry(RFC 8628
+// expired_token), errors.Is(err, ErrDeviceAuthNotFound) holds as well
+var ErrDeviceAuthExpired = errors.New("mongo: device authorization expired")
+
+// ErrAuthorizationPending returned by DeviceStore.Consume while the user hasn't approved or denied the
+// authorization(RFC 8628 authorization_pending)
+var ErrAuthorizationPending = errors.New("mongo: device authorization pending")
+
+// ErrDeviceAuthDenied returned by DeviceStore.Consume when the user denied the authorization(RFC 8628 access_denied)
+var ErrDeviceAuthDenied = errors.New("mongo: device authorization denied")
+
+// ErrDeviceAuthDecided returned by DeviceStore.Approve and Deny when the user already approved or denied the
+// authorization
+var ErrDeviceAuthDecided = errors.New("mongo: device authorization already approved or denied")
+
+// ErrUnsupported returned by the operations the store configuration rules out, e.g. GetByAccess with
+// TokenConfig.SkipAccessTokenStorage or RecordByCode with TokenConfig.MemoryCodes
+var ErrUnsupported = errors.New("mongo: operation unsupported by the store configuration")
+
+// ErrReadOnlyStore returned by the writes of a store configured with TokenConfig.ReadOnly
+var ErrReadOnlyStore = errors.New("mongo: store is read-only")
+
+// ErrAmbiguousDomain returned by GetByDomain when several clients share the domain
+var ErrAmbiguousDomain = errors.New("mongo: several clients share the domain")
+
+// ErrDuplicateKey returned when a write violates a unique index, see DuplicateKeyError
+var ErrDuplicateKey = errors.New("mongo: duplicate key")
+
+// DuplicateKeyError a write or index creation rejected by a unique index
+type DuplicateKeyError struct {
+	// field of the violated constraint, e.g. _id or domain
+	Field string
+	// name of the unique index
+	Index string
+	Err   error
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("mongo: duplicate client %s(index %s): %v", e.Field, e.Index, e.Err)
+}
+
+// Is match ErrDuplicateKey
+func (e *DuplicateKeyError) Is(target error) bool {
+	return target == ErrDuplicateKey
+}
+
+// Unwrap the driver error
+func (e *DuplicateKeyError) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalidClient returned when client information breaks the validation rules, see ClientValidationError
+var ErrInvalidClient = errors.New("mongo: invalid client")
+
+// ClientFieldError a validation rule broken by a client field
+type ClientFieldError struct {
+	// e.g. id, domain or redirect_uris
+	Field  string
+	Reason string
+}
+
+// ClientValidationError client information rejected by Set, Update, CreateClient or SetRateLimit
+type ClientValidationError struct {
+	Fields []ClientFieldError
+}
+
+func (e *ClientValidationError) Error() string {
+	msg := ErrInvalidClient.Error()
+
+	for i, f := range e.Fields {
+		sep := ", "
+
+		if i == 0 {
+			sep = ": "
+		}
+
+		msg += sep + f.Field + " " + f.Reason
+	}
+
+	return msg
+}
+
+// Is match ErrInvalidClient, and ErrInvalidArgument like the other rejected arguments
+func (e *ClientValidationError) Is(target error) bool {
+	return target == ErrInvalidClient || target == ErrInvalidArgument
+}
+
+// notFoundError a missing document reported with the sentinel of the store,
+// it still unwraps to mongo.ErrNoDocuments for the callers checking the driver error
+type notFoundError struct {
+	sentinel error
+	// broader sentinel also matched
+	parent error
+}
+
+func (e notFoundError) Error() string {
+	return e.sentinel.Error()
+}
+
+// Is match the sentinels
+func (e notFoundError) Is(target error) bool {
+	return target == e.sentinel || (e.parent != nil && target == e.parent)
+}
+
+// Unwrap the driver error
+func (e notFoundError) Unwrap() error {
+	return mongo.ErrNoDocuments
+}
+
+// errClientNotFound the error of a missing client
+var errClientNotFound error = notFoundError{sentinel: ErrClientNotFound}
+
+// errClientDisabled the error of a disabled client
+var errClientDisabled error = notFoundError{sentinel: ErrClientDisabled, parent: ErrClientNotFound}
+
+// errConsentNotFound the error of a missing consent
+var errConsentNotFound error = notFoundError{sentinel: ErrConsentNotFound}
+
+// errRequestURINotFound the error of a missing pushed authorization request
+var errRequestURINotFound error = notFoundError{sentinel: ErrRequestURINotFound}
+
+// errKeyNotFound the error of a missing signing key
+var errKeyNotFound error = notFoundError{sentinel: ErrKeyNotFound}
+
+// errNoActiveKey the error of a key store without active key
+var errNoActiveKey error = notFoundError{sentinel: ErrNoActiveKey}
+
+// errStateNotFound the error of a missing state
+var errStateNotFound error = notFoundError{sentinel: ErrStateNotFound}
+
+// errDeviceAuthNotFound the error of a missing device authorization
+var errDeviceAuthNotFound error = notFoundError{sentinel: ErrDeviceAuthNotFound}
+
+// errDeviceAuthExpired the error of an expired device authorization
+var errDeviceAuthExpired error = notFoundError{sentinel: ErrDeviceAuthExpired, parent: ErrDeviceAuthNotFound}
+
+// errRequestURIExpired the error of an expired pushed authorization request
+var errRequestURIExpired error = notFoundError{sentinel: ErrRequestURIExpired, parent: ErrRequestURINotFound}
+
+// OpError error returned by a public store operation, it keeps the store,
+// operation and collection which produced the underlying error. The tokens, codes
+// and secrets given to the operation are redacted from its message.
+type OpError struct {
+	// "token", "client" or the store built on the token store(e.g. "consent")
+	Store      string
+	Op         string
+	Collection string
+	Err        error
+}
+
+func (e *OpError) Error() string {
+	return fmt.Sprintf("mongo %s store: %s(%s): %v", e.Store, e.Op, e.Collection, e.Err)
+}
+
+// Unwrap the underlying error so errors.Is and errors.As keep working
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
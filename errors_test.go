@@ -0,0 +1,92 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// requireOpError assert err is an OpError of the operation, with the stable prefix of its message
+func requireOpError(t *testing.T, err error, store, op, collection string) *OpError {
+	t.Helper()
+
+	var oe *OpError
+
+	if !errors.As(err, &oe) {
+		t.Fatalf("%v(%T) isn't an OpError", err, err)
+	}
+
+	if oe.Store != store || oe.Op != op || oe.Collection != collection {
+		t.Fatalf("OpError %s/%s/%s, want %s/%s/%s", oe.Store, oe.Op, oe.Collection, store, op, collection)
+	}
+
+	if prefix := "mongo " + store + " store: " + op + "(" + collection + "): "; !strings.HasPrefix(err.Error(), prefix) {
+		t.Fatalf("message %q, want the prefix %q", err.Error(), prefix)
+	}
+
+	return oe
+}
+
+func TestOpErrorNotFound(t *testing.T) {
+	ctx := context.Background()
+
+	ts := newTestTokenStore(t)
+
+	_, err := ts.GetByAccess(ctx, "missing")
+	requireOpError(t, err, "token", "GetByAccess", "oauth2_access")
+
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("%v doesn't unwrap to mongo.ErrNoDocuments", err)
+	}
+
+	cs := newTestClientStore(t)
+
+	_, err = cs.GetByID(ctx, "missing")
+	requireOpError(t, err, "client", "GetByID", "oauth2_clients")
+
+	if !errors.Is(err, ErrClientNotFound) || !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("%v doesn't unwrap to ErrClientNotFound and mongo.ErrNoDocuments", err)
+	}
+}
+
+func TestOpErrorSentinels(t *testing.T) {
+	ctx := context.Background()
+
+	ts := newTestTokenStore(t)
+
+	err := ts.RemoveByAccess(ctx, "")
+	requireOpError(t, err, "token", "RemoveByAccess", "oauth2_access")
+
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("%v doesn't unwrap to ErrInvalidArgument", err)
+	}
+
+	if err := ts.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ts.GetByRefresh(ctx, "refresh")
+	requireOpError(t, err, "token", "GetByRefresh", "oauth2_refresh")
+
+	if !errors.Is(err, ErrStoreClosed) {
+		t.Fatalf("%v doesn't unwrap to ErrStoreClosed", err)
+	}
+}
+
+// the driver errors keep their context errors
+func TestOpErrorContext(t *testing.T) {
+	ts := newTestTokenStore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := ts.Create(ctx, testToken("access", "refresh"))
+	requireOpError(t, err, "token", "Create", "oauth2_basic")
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("%v doesn't unwrap to context.Canceled", err)
+	}
+}
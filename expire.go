@@ -0,0 +1,56 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RemoveExpired delete the token and denylist documents whose expiry has passed, returning the number
+// of deleted documents. Meant to be run periodically where the TTL sweeps are too slow or unavailable,
+// e.g. on DocumentDB.
+func (ts *TokenStore) RemoveExpired(ctx context.Context) (int64, error) {
+	var total int64
+
+	now := time.Now()
+	names := aliases(ts.fields().ExpiredAt, func(f FieldNames) string { return f.ExpiredAt })
+
+	for _, name := range append(ts.tokenCNames(), ts.tcfg.DenylistCName) {
+		o := ts.op("RemoveExpired", name)
+
+		err := ts.run(ctx, o, func(ctx context.Context) error {
+			match := bson.A{}
+
+			for _, field := range names {
+				match = append(match, bson.M{field: bson.M{"$lt": now}})
+			}
+
+			filter := bson.M{"$or": match}
+
+			// the denylist entries aren't issuer scoped
+			if name != ts.tcfg.DenylistCName {
+				filter = ts.withKeyPrefix(ts.withIssuer(ctx, filter))
+			}
+
+			return ts.colHandler(ctx, name, func(ctx context.Context, c *mongo.Collection) error {
+				res, err := c.DeleteMany(ctx, filter)
+
+				if err == nil {
+					o.set("deleted", res.DeletedCount)
+					total += res.DeletedCount
+					ts.metrics().ObserveCleanup(name, res.DeletedCount)
+				}
+
+				return err
+			})
+		})
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
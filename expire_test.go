@@ -0,0 +1,72 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testExpired create live and expired codes, tokens and denylist entries, removed by RemoveExpired
+func testExpired(t *testing.T, ts *TokenStore) {
+	t.Helper()
+
+	ctx := context.Background()
+	past := time.Now().Add(-2 * time.Hour)
+
+	expired := testToken("expired-access", "expired-refresh")
+	expired.AccessCreateAt, expired.RefreshCreateAt = past, past
+	expired.RefreshExpiresIn = time.Hour
+
+	code := testCode("expired-code")
+	code.CodeCreateAt = past
+
+	for _, step := range []struct {
+		name string
+		err  error
+	}{
+		{"live", ts.Create(ctx, testToken("access", "refresh"))},
+		{"live code", ts.Create(ctx, testCode("code"))},
+		{"expired", ts.Create(ctx, expired)},
+		{"expired code", ts.Create(ctx, code)},
+		{"denied", ts.DenyJTIs(ctx, []DeniedJTI{{JTI: "live", ExpiresAt: time.Now().Add(time.Hour)}, {JTI: "expired", ExpiresAt: past}})},
+	} {
+		if step.err != nil {
+			t.Fatalf("%s: %v", step.name, step.err)
+		}
+	}
+
+	// the expired basic, access, refresh, code and denylist documents
+	if n, err := ts.RemoveExpired(ctx); err != nil || n != 5 {
+		t.Fatalf("%d documents removed: %v", n, err)
+	}
+
+	if _, err := ts.GetByRefresh(ctx, "expired-refresh"); err == nil {
+		t.Fatal("expired refresh token left")
+	}
+
+	if info, err := ts.GetByAccess(ctx, "access"); err != nil || info == nil {
+		t.Fatalf("live access token %v: %v", info, err)
+	}
+
+	if info, err := ts.GetByCode(ctx, "code"); err != nil || info == nil {
+		t.Fatalf("live code %v: %v", info, err)
+	}
+
+	if denied, err := ts.IsDenied(ctx, "live"); err != nil || !denied {
+		t.Fatalf("live denylist entry %v: %v", denied, err)
+	}
+
+	if n, err := ts.RemoveExpired(ctx); err != nil || n != 0 {
+		t.Fatalf("%d documents removed again: %v", n, err)
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	t.Run("collections", func(t *testing.T) {
+		testExpired(t, newTestTokenStore(t))
+	})
+
+	t.Run("single collection", func(t *testing.T) {
+		testExpired(t, newTestTokenStore(t, WithSingleCollection()))
+	})
+}
@@ -0,0 +1,497 @@
+package mongo
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExportOptions options of ExportClients
+type ExportOptions struct {
+	// leave the secrets, previous secrets and registration access token hashes out, the imported clients need new
+	// ones(The default exports them)
+	RedactSecrets bool
+	// encrypt the secrets for the transport with this encrypter, given to ImportOptions.TransportEncrypter on the
+	// importing side(The default exports them as stored, encrypted with the keys of the store if any)
+	TransportEncrypter Encrypter
+	// also export the disabled clients(The default leaves them out)
+	IncludeDisabled bool
+}
+
+// ImportConflict what ImportClients does with a client whose ID is already stored
+type ImportConflict int
+
+// conflict policies of ImportClients
+const (
+	// import nothing and return a DuplicateKeyError
+	ImportFail ImportConflict = iota
+	// keep the stored client
+	ImportSkip
+	// replace the stored client
+	ImportUpsert
+)
+
+// ImportOptions options of ImportClients
+type ImportOptions struct {
+	// the clients already stored(The default is ImportFail)
+	OnConflict ImportConflict
+	// decrypt the secrets encrypted by ExportOptions.TransportEncrypter(The default reads them as stored, encrypted
+	// with the keys of this store if any)
+	TransportEncrypter Encrypter
+}
+
+// ImportReport outcome of ImportClients
+type ImportReport struct {
+	// clients read from the export
+	Read int
+	// clients stored, replaced and left in place by ImportSkip
+	Inserted int
+	Replaced int
+	Skipped  int
+}
+
+// secretFields the names of the secret fields the export rewrites
+func (cs *ClientStore) secretFields() []string {
+	fn := cs.fields()
+
+	return append(append(aliases(fn.Secret, func(f FieldNames) string { return f.Secret }),
+		aliases(fn.KeyID, func(f FieldNames) string { return f.KeyID })...),
+		clientPreviousSecretField, clientPreviousKeyIDField)
+}
+
+// without the document without the fields
+func without(doc bson.D, names ...string) bson.D {
+	out := make(bson.D, 0, len(doc))
+
+	for _, e := range doc {
+		drop := false
+
+		for _, name := range names {
+			if e.Key == name {
+				drop = true
+				break
+			}
+		}
+
+		if !drop {
+			out = append(out, e)
+		}
+	}
+
+	return out
+}
+
+// withSecrets the document with its secrets replaced, stored under the configured names
+func (cs *ClientStore) withSecrets(doc bson.D, keyID, secret, previousKeyID, previous string) bson.D {
+	fn := cs.fields()
+	doc = append(without(doc, cs.secretFields()...), bson.E{Key: fn.Secret, Value: secret})
+
+	if keyID != "" {
+		doc = append(doc, bson.E{Key: fn.KeyID, Value: keyID})
+	}
+
+	if previous != "" {
+		doc = append(doc, bson.E{Key: clientPreviousSecretField, Value: previous})
+
+		if previousKeyID != "" {
+			doc = append(doc, bson.E{Key: clientPreviousKeyIDField, Value: previousKeyID})
+		}
+	}
+
+	return doc
+}
+
+// sealTransport decrypt the stored secret and encrypt it for the transport
+func (cs *ClientStore) sealTransport(e Encrypter, keyID, stored string) (string, string, error) {
+	if stored == "" {
+		return "", "", nil
+	}
+
+	secret, err := cs.openSecret(keyID, stored)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	keyID, ciphertext, err := e.Encrypt([]byte(secret))
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return keyID, base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// openTransport decrypt the exported secret, with the transport encrypter when given
+func (cs *ClientStore) openTransport(e Encrypter, keyID, exported string) (string, error) {
+	if e == nil || keyID == "" {
+		return cs.openSecret(keyID, exported)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(exported)
+
+	if err != nil {
+		return "", &DecryptError{KeyID: keyID, Err: err}
+	}
+
+	secret, err := e.Decrypt(keyID, ciphertext)
+
+	if err != nil {
+		return "", &DecryptError{KeyID: keyID, Err: err}
+	}
+
+	return string(secret), nil
+}
+
+// exportDoc the client document as exported
+func (cs *ClientStore) exportDoc(raw bson.Raw, opts ExportOptions) (bson.D, error) {
+	var doc bson.D
+
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	if opts.RedactSecrets {
+		return without(doc, append(cs.secretFields(), clientPreviousExpiresField, clientRegistrationTokenField)...), nil
+	}
+
+	if opts.TransportEncrypter == nil {
+		return doc, nil
+	}
+
+	entity := decodeClient(raw, cs.fields())
+	keyID, secret, err := cs.sealTransport(opts.TransportEncrypter, entity.KeyID, entity.Secret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	previousKeyID, previous, err := cs.sealTransport(opts.TransportEncrypter, entity.PreviousKeyID, entity.PreviousSecret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cs.withSecrets(doc, keyID, secret, previousKeyID, previous), nil
+}
+
+// validateFilter reject the metadata keys and status List can't filter on
+func validateFilter(filter ClientFilter) error {
+	if err := validateMetadataKeys(filter.Metadata); err != nil {
+		return err
+	}
+
+	if filter.Status != "" && !filter.Status.valid() {
+		return fmt.Errorf("%w: client status %q", ErrInvalidArgument, filter.Status)
+	}
+
+	return nil
+}
+
+// ExportClients write the client documents matching filter to w in ID order, one canonical Extended JSON document
+// per line, e.g. to promote them to another environment with ImportClients. Every stored field is kept, the secrets
+// are redacted or encrypted for the transport as opts asks. The owners stay pseudonymized with the UserIDKey of
+// this store.
+func (cs *ClientStore) ExportClients(ctx context.Context, w io.Writer, filter ClientFilter, opts ExportOptions) error {
+	o := cs.op("ExportClients", cs.ccfg.ClientsCName)
+
+	return cs.run(ctx, o, func(ctx context.Context) error {
+		if w == nil {
+			return fmt.Errorf("%w: nil writer", ErrInvalidArgument)
+		}
+
+		if err := validateFilter(filter); err != nil {
+			return err
+		}
+
+		query := cs.query(filter)
+
+		if !opts.IncludeDisabled {
+			query = active(query)
+		}
+
+		return cs.readHandler(ctx, cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) error {
+			cur, err := c.Find(ctx, query, options.Find().SetSort(bson.M{"_id": 1}))
+
+			if err != nil {
+				return err
+			}
+
+			defer cur.Close(ctx)
+
+			n := 0
+			bw := bufio.NewWriter(w)
+
+			for cur.Next(ctx) {
+				doc, err := cs.exportDoc(cur.Current, opts)
+
+				if err != nil {
+					return err
+				}
+
+				data, err := bson.MarshalExtJSON(doc, true, false)
+
+				if err != nil {
+					return err
+				}
+
+				if _, err := bw.Write(append(data, '\n')); err != nil {
+					return err
+				}
+
+				n++
+			}
+
+			o.set("documents", n)
+
+			if err := cur.Err(); err != nil {
+				return err
+			}
+
+			return bw.Flush()
+		})
+	})
+}
+
+// importDoc the exported client document with its secrets sealed for this store
+func (cs *ClientStore) importDoc(o *operation, line []byte, e Encrypter) (bson.D, error) {
+	var doc bson.D
+
+	if err := bson.UnmarshalExtJSON(line, true, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+
+	raw, err := bson.Marshal(doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entity := decodeClient(raw, cs.fields())
+	secret, err := cs.openTransport(e, entity.KeyID, entity.Secret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	o.sensitive(secret)
+
+	if secret, err = cs.hashSecret(secret); err != nil {
+		return nil, err
+	}
+
+	keyID, secret, err := cs.sealSecret(secret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var previousKeyID, previous string
+
+	if entity.PreviousSecret != "" {
+		if previous, err = cs.openTransport(e, entity.PreviousKeyID, entity.PreviousSecret); err != nil {
+			return nil, err
+		}
+
+		o.sensitive(previous)
+
+		if previousKeyID, previous, err = cs.sealSecret(previous); err != nil {
+			return nil, err
+		}
+	}
+
+	return cs.withSecrets(doc, keyID, secret, previousKeyID, previous), nil
+}
+
+// validateImport check the imported client like the writes do, a secret isn't required as it may be redacted
+func (cs *ClientStore) validateImport(o *operation, doc bson.D) error {
+	raw, err := bson.Marshal(doc)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := cs.clientInfo(o, raw)
+
+	if err != nil {
+		return err
+	}
+
+	if err := cs.validateClient(info, false); err != nil {
+		return err
+	}
+
+	if err := cs.validateMetadata(asClient(info).Metadata); err != nil {
+		return err
+	}
+
+	_, err = cs.registration(info)
+	return err
+}
+
+// ImportClients store the clients exported by ExportClients, read from r. Every client is validated like the writes
+// do before any is stored, and the existing ones are handled as opts.OnConflict says: ImportFail checks up front that
+// none exists. The quota of their owners is enforced and the changes are audited like the other writes.
+func (cs *ClientStore) ImportClients(ctx context.Context, r io.Reader, opts ImportOptions) (report ImportReport, err error) {
+	o := cs.op("ImportClients", cs.ccfg.ClientsCName)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if r == nil {
+			return fmt.Errorf("%w: nil reader", ErrInvalidArgument)
+		}
+
+		var docs []bson.D
+
+		scanner := bufio.NewScanner(r)
+		// a client document is at most 16MB
+		scanner.Buffer(make([]byte, 64<<10), 32<<20)
+
+		for line := 1; scanner.Scan(); line++ {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+
+			doc, err := cs.importDoc(o, scanner.Bytes(), opts.TransportEncrypter)
+
+			if err == nil {
+				err = cs.validateImport(o, doc)
+			}
+
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+
+			docs = append(docs, doc)
+		}
+
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+
+		report.Read = len(docs)
+		o.set("documents", len(docs))
+
+		if opts.OnConflict == ImportFail {
+			if err := cs.checkImportConflicts(ctx, docs); err != nil {
+				return err
+			}
+		}
+
+		for _, doc := range docs {
+			if err := cs.importClient(ctx, o, doc, opts.OnConflict, &report); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// docID the _id of the client document
+func docID(doc bson.D) string {
+	for _, e := range doc {
+		if e.Key == "_id" {
+			id, _ := e.Value.(string)
+			return id
+		}
+	}
+
+	return ""
+}
+
+// checkImportConflicts return a DuplicateKeyError when one of the clients is already stored
+func (cs *ClientStore) checkImportConflicts(ctx context.Context, docs []bson.D) error {
+	ids := bson.A{}
+
+	for _, doc := range docs {
+		ids = append(ids, docID(doc))
+	}
+
+	return cs.readHandler(ctx, cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) error {
+		raw, err := c.FindOne(ctx, bson.M{"_id": bson.M{"$in": ids}}, cs.findOneOptions().SetProjection(bson.M{"_id": 1})).DecodeBytes()
+
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return &DuplicateKeyError{Field: "_id", Index: "_id_", Err: fmt.Errorf("client %s exists", lookupString(raw, []string{"_id"}))}
+	})
+}
+
+// importClient store the client document as the conflict policy says
+func (cs *ClientStore) importClient(ctx context.Context, o *operation, doc bson.D, conflict ImportConflict, report *ImportReport) error {
+	raw, err := bson.Marshal(doc)
+
+	if err != nil {
+		return err
+	}
+
+	id := docID(doc)
+	userID := lookupString(raw, aliases(cs.fields().UserID, func(f FieldNames) string { return f.UserID }))
+
+	var inserted, replaced, skipped bool
+
+	err = cs.retryQuota(func() error {
+		inserted, replaced, skipped = false, false, false
+
+		return cs.duplicateKey(cs.auditedHandler(ctx, o, id, func(ctx context.Context, c *mongo.Collection) error {
+			if conflict == ImportSkip {
+				n, err := c.CountDocuments(ctx, bson.M{"_id": id}, cs.countOptions())
+
+				if err != nil {
+					return err
+				}
+
+				if n > 0 {
+					skipped = true
+					return nil
+				}
+			}
+
+			if err := cs.checkQuota(ctx, c, id, userID); err != nil {
+				return err
+			}
+
+			if conflict != ImportUpsert {
+				_, err := c.InsertOne(ctx, doc)
+				inserted = err == nil
+				return err
+			}
+
+			res, err := c.ReplaceOne(ctx, bson.M{"_id": id}, doc, cs.replaceOptions().SetUpsert(true))
+
+			if err == nil {
+				replaced, inserted = res.MatchedCount > 0, res.MatchedCount == 0
+			}
+
+			return err
+		}))
+	})
+
+	if err != nil {
+		return fmt.Errorf("client %s: %w", id, err)
+	}
+
+	switch {
+	case skipped:
+		report.Skipped++
+	case replaced:
+		report.Replaced++
+	case inserted:
+		report.Inserted++
+	}
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// exportTestClients store the clients of the export tests
+func exportTestClients(t *testing.T, cs *ClientStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	clients := []*Client{
+		{
+			Client:          models.Client{ID: "web", Secret: "web-secret", Domain: "https://web.example.com", UserID: "u"},
+			RedirectURIs:    []string{"https://web.example.com/callback"},
+			Scopes:          []string{"read", "write"},
+			GrantTypes:      []string{"authorization_code", "refresh_token"},
+			AllowedOrigins:  []string{"https://web.example.com"},
+			AccessTokenTTL:  time.Hour,
+			RateLimit:       &RateLimit{Requests: 60, Window: time.Minute},
+			RefreshPolicy:   &RefreshPolicy{Rotation: true},
+			SecretExpiresAt: time.Now().Add(24 * time.Hour).Truncate(time.Millisecond),
+			Metadata:        map[string]interface{}{"team": "web"},
+			Registration:    &RegisteredClientMetadata{ClientName: "Web", Extra: map[string]interface{}{"tier": "gold"}},
+		},
+		{
+			Client:                  models.Client{ID: "jwt", Domain: "https://jwt.example.com"},
+			TokenEndpointAuthMethod: AuthMethodPrivateKeyJWT,
+			JWKS:                    json.RawMessage(testJWKS),
+		},
+		{Client: models.Client{ID: "spa", Domain: "https://spa.example.com"}, Public: true},
+		{Client: models.Client{ID: "suspended", Secret: "suspended-secret", Domain: "https://example.com"}},
+	}
+
+	for _, c := range clients {
+		if err := cs.Set(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cs.RotateSecret(ctx, "web", "web-secret-2", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.SetStatus(ctx, "suspended", ClientSuspended); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// exportClients the export of every client of cs
+func exportClients(t *testing.T, cs *ClientStore, opts ExportOptions) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	if err := cs.ExportClients(context.Background(), &buf, ClientFilter{}, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf
+}
+
+func TestExportImportClients(t *testing.T) {
+	ctx := context.Background()
+	transport := testEncrypter(t, "transport")
+
+	staging := newTestClientStore(t, WithSecretEncrypter(testEncrypter(t, "staging")))
+	production := newTestClientStore(t, WithSecretEncrypter(testEncrypter(t, "production")))
+
+	exportTestClients(t, staging)
+
+	export := exportClients(t, staging, ExportOptions{TransportEncrypter: transport})
+
+	if lines := strings.Count(export.String(), "\n"); lines != 4 {
+		t.Fatalf("%d exported lines:\n%s", lines, export)
+	}
+
+	for _, secret := range []string{"web-secret", "suspended-secret"} {
+		if strings.Contains(export.String(), secret) {
+			t.Fatalf("secret %s exported in plaintext", secret)
+		}
+	}
+
+	report, err := production.ImportClients(ctx, export, ImportOptions{TransportEncrypter: transport})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report != (ImportReport{Read: 4, Inserted: 4}) {
+		t.Fatalf("report %+v", report)
+	}
+
+	// every field intact
+	for _, id := range []string{"web", "jwt", "spa"} {
+		want, err := staging.GetByID(ctx, id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := production.GetByID(ctx, id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("imported %+v, want %+v", got, want)
+		}
+	}
+
+	if _, err := production.GetByID(ctx, "suspended"); !errors.Is(err, ErrClientDisabled) {
+		t.Fatalf("imported suspended client: %v, want ErrClientDisabled", err)
+	}
+
+	// the secrets work with the keys of the production store, the previous one during its grace window
+	for _, secret := range []string{"web-secret-2", "web-secret"} {
+		if ok, err := production.VerifySecret(ctx, "web", secret); err != nil || !ok {
+			t.Fatalf("imported secret %s: %v(%v)", secret, ok, err)
+		}
+	}
+}
+
+func TestExportRedactSecrets(t *testing.T) {
+	ctx := context.Background()
+	staging := newTestClientStore(t)
+	production := newTestClientStore(t)
+
+	exportTestClients(t, staging)
+
+	export := exportClients(t, staging, ExportOptions{RedactSecrets: true})
+
+	for _, secret := range []string{"web-secret", "suspended-secret"} {
+		if strings.Contains(export.String(), secret) {
+			t.Fatalf("secret %s exported", secret)
+		}
+	}
+
+	if _, err := production.ImportClients(ctx, export, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := production.GetByID(ctx, "web")
+
+	if err != nil || info.GetSecret() != "" || len(asClient(info).RedirectURIs) != 1 {
+		t.Fatalf("imported redacted client %+v: %v", info, err)
+	}
+
+	if _, err := production.RegenerateSecret(ctx, "web"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportConflicts(t *testing.T) {
+	ctx := context.Background()
+	staging := newTestClientStore(t)
+	exportTestClients(t, staging)
+	export := exportClients(t, staging, ExportOptions{}).String()
+
+	production := newTestClientStore(t)
+
+	if err := production.Set(&models.Client{ID: "web", Secret: "production-secret", Domain: "https://production.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing imported
+	var de *DuplicateKeyError
+
+	if _, err := production.ImportClients(ctx, strings.NewReader(export), ImportOptions{OnConflict: ImportFail}); !errors.As(err, &de) {
+		t.Fatalf("conflict: %v, want a DuplicateKeyError", err)
+	}
+
+	if n, err := production.Collection().CountDocuments(ctx, map[string]interface{}{}); err != nil || n != 1 {
+		t.Fatalf("%d clients after the failed import: %v", n, err)
+	}
+
+	report, err := production.ImportClients(ctx, strings.NewReader(export), ImportOptions{OnConflict: ImportSkip})
+
+	if err != nil || report != (ImportReport{Read: 4, Inserted: 3, Skipped: 1}) {
+		t.Fatalf("report %+v: %v", report, err)
+	}
+
+	if info, err := production.GetByID(ctx, "web"); err != nil || info.GetDomain() != "https://production.example.com" {
+		t.Fatalf("skipped client %+v: %v", info, err)
+	}
+
+	report, err = production.ImportClients(ctx, strings.NewReader(export), ImportOptions{OnConflict: ImportUpsert})
+
+	if err != nil || report != (ImportReport{Read: 4, Replaced: 4}) {
+		t.Fatalf("report %+v: %v", report, err)
+	}
+
+	if info, err := production.GetByID(ctx, "web"); err != nil || info.GetDomain() == "https://production.example.com" {
+		t.Fatalf("replaced client %+v: %v", info, err)
+	}
+}
+
+func TestImportValidation(t *testing.T) {
+	ctx := context.Background()
+	staging := newTestClientStore(t)
+	exportTestClients(t, staging)
+	export := exportClients(t, staging, ExportOptions{}).String()
+
+	tests := []struct {
+		name, input string
+	}{
+		{"not JSON", export + "{\n"},
+		{"invalid domain", export + `{"_id":"bad","domain":"example.com/cb"}` + "\n"},
+		{"without ID", export + `{"domain":"https://example.com"}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		production := newTestClientStore(t)
+
+		_, err := production.ImportClients(ctx, strings.NewReader(tt.input), ImportOptions{})
+
+		if err == nil || !strings.Contains(err.Error(), "line 5") {
+			t.Fatalf("%s: %v, want an error of line 5", tt.name, err)
+		}
+
+		// validated before any is stored
+		if n, err := production.Collection().CountDocuments(ctx, map[string]interface{}{}); err != nil || n != 0 {
+			t.Fatalf("%s: %d clients stored: %v", tt.name, n, err)
+		}
+	}
+
+	if _, err := newTestClientStore(t).ImportClients(ctx, nil, ImportOptions{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("nil reader: %v, want ErrInvalidArgument", err)
+	}
+}
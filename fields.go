@@ -0,0 +1,265 @@
+package mongo
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FieldNames BSON field names of the stored documents, empty names use the legacy ones.
+//
+// Changing the naming of an existing deployment is safe: documents are read with either
+// naming, while new documents and the ExpiredAt index use the configured one. The index
+// on the previous field name is left in place until the old documents have expired.
+type FieldNames struct {
+	// token payload of the basic documents
+	Data string
+	// name of the codec which serialized the payload
+	Codec string
+	// id of the key which encrypted the payload
+	KeyID string
+	// tenant whose key encrypted the payload
+	Tenant string
+	// issuer of the token documents
+	Issuer string
+	// expiry of the token documents, also the indexed field
+	ExpiredAt string
+	// reference from the access/refresh documents to the basic document
+	BasicID string
+	// proof-of-possession binding(cnf) of the access/refresh documents
+	Confirmation string
+	// client secret
+	Secret string
+	// client domain
+	Domain string
+	// client owner
+	UserID string
+}
+
+// LegacyFieldNames the field names used since the first release(Data, Codec, KeyID, Tenant, Issuer, ExpiredAt, BasicID, Confirmation, secret, domain, userid)
+func LegacyFieldNames() FieldNames {
+	return FieldNames{
+		Data:         "Data",
+		Codec:        "Codec",
+		KeyID:        "KeyID",
+		Tenant:       "Tenant",
+		Issuer:       "Issuer",
+		ExpiredAt:    "ExpiredAt",
+		BasicID:      "BasicID",
+		Confirmation: "Confirmation",
+		Secret:       "secret",
+		Domain:       "domain",
+		UserID:       "userid",
+	}
+}
+
+// SnakeCaseFieldNames lowercase snake_case field names(data, codec, key_id, tenant, issuer, expired_at, basic_id, cnf, secret, domain, user_id)
+func SnakeCaseFieldNames() FieldNames {
+	return FieldNames{
+		Data:         "data",
+		Codec:        "codec",
+		KeyID:        "key_id",
+		Tenant:       "tenant",
+		Issuer:       "issuer",
+		ExpiredAt:    "expired_at",
+		BasicID:      "basic_id",
+		Confirmation: "cnf",
+		Secret:       "secret",
+		Domain:       "domain",
+		UserID:       "user_id",
+	}
+}
+
+// withDefaults fill the empty names with the legacy ones
+func (fn FieldNames) withDefaults() FieldNames {
+	legacy := LegacyFieldNames()
+
+	set := func(dst *string, name string) {
+		if *dst == "" {
+			*dst = name
+		}
+	}
+
+	set(&fn.Data, legacy.Data)
+	set(&fn.Codec, legacy.Codec)
+	set(&fn.KeyID, legacy.KeyID)
+	set(&fn.Tenant, legacy.Tenant)
+	set(&fn.Issuer, legacy.Issuer)
+	set(&fn.ExpiredAt, legacy.ExpiredAt)
+	set(&fn.BasicID, legacy.BasicID)
+	set(&fn.Confirmation, legacy.Confirmation)
+	set(&fn.Secret, legacy.Secret)
+	set(&fn.Domain, legacy.Domain)
+	set(&fn.UserID, legacy.UserID)
+
+	return fn
+}
+
+// expiredAtIndexName name of the ExpiredAt index generated by the server
+func (fn FieldNames) expiredAtIndexName() string {
+	return fn.ExpiredAt + "_1"
+}
+
+// aliases the names a field may be stored under: the configured one first, then the known presets
+func aliases(name string, pick func(FieldNames) string) []string {
+	names := []string{name}
+
+	for _, preset := range []FieldNames{LegacyFieldNames(), SnakeCaseFieldNames()} {
+		if alias := pick(preset); alias != name {
+			names = append(names, alias)
+		}
+	}
+
+	return names
+}
+
+// lookup the first present value of the given field names
+func lookup(raw bson.Raw, names []string) (bson.RawValue, bool) {
+	for _, name := range names {
+		if v, err := raw.LookupErr(name); err == nil {
+			return v, true
+		}
+	}
+
+	return bson.RawValue{}, false
+}
+
+// presentName the first of the given field names present in the document
+func presentName(raw bson.Raw, names []string) (string, bool) {
+	for _, name := range names {
+		if _, err := raw.LookupErr(name); err == nil {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+func lookupString(raw bson.Raw, names []string) string {
+	v, ok := lookup(raw, names)
+
+	if !ok {
+		return ""
+	}
+
+	s, _ := v.StringValueOK()
+
+	return s
+}
+
+func lookupBinary(raw bson.Raw, names []string) []byte {
+	v, ok := lookup(raw, names)
+
+	if !ok {
+		return nil
+	}
+
+	_, data, _ := v.BinaryOK()
+
+	return data
+}
+
+// lookupPayload the bytes of a binary payload or of a structured one
+func lookupPayload(raw bson.Raw, names []string) []byte {
+	v, ok := lookup(raw, names)
+
+	if !ok {
+		return nil
+	}
+
+	if doc, ok := v.DocumentOK(); ok {
+		return doc
+	}
+
+	_, data, _ := v.BinaryOK()
+
+	return data
+}
+
+func lookupBool(raw bson.Raw, names []string) bool {
+	v, ok := lookup(raw, names)
+
+	if !ok {
+		return false
+	}
+
+	b, _ := v.BooleanOK()
+
+	return b
+}
+
+func lookupInt64(raw bson.Raw, names []string) int64 {
+	v, ok := lookup(raw, names)
+
+	if !ok {
+		return 0
+	}
+
+	n, _ := v.AsInt64OK()
+
+	return n
+}
+
+func lookupStrings(raw bson.Raw, names []string) []string {
+	v, ok := lookup(raw, names)
+
+	if !ok {
+		return nil
+	}
+
+	arr, ok := v.ArrayOK()
+
+	if !ok {
+		return nil
+	}
+
+	values, err := arr.Values()
+
+	if err != nil {
+		return nil
+	}
+
+	var out []string
+
+	for _, value := range values {
+		if s, ok := value.StringValueOK(); ok {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
+func lookupMap(raw bson.Raw, names []string) map[string]interface{} {
+	v, ok := lookup(raw, names)
+
+	if !ok {
+		return nil
+	}
+
+	doc, ok := v.DocumentOK()
+
+	if !ok {
+		return nil
+	}
+
+	var m map[string]interface{}
+
+	if err := bson.Unmarshal(doc, &m); err != nil {
+		return nil
+	}
+
+	return m
+}
+
+func lookupTime(raw bson.Raw, names []string) time.Time {
+	v, ok := lookup(raw, names)
+
+	if !ok {
+		return time.Time{}
+	}
+
+	t, _ := v.TimeOK()
+
+	return t
+}
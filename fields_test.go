@@ -0,0 +1,135 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestFieldNamesOperations(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		fields FieldNames
+	}{
+		{"legacy", LegacyFieldNames()},
+		{"snake case", SnakeCaseFieldNames()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testTokenOperations(t, newTestTokenStore(t, WithFieldNames(tc.fields)))
+			testClientOperations(t, newTestClientStore(t, WithFieldNames(tc.fields)))
+		})
+	}
+}
+
+// the documents and the ExpiredAt index use the configured names
+func TestFieldNamesDocuments(t *testing.T) {
+	ctx := context.Background()
+	fn := SnakeCaseFieldNames()
+
+	db := testDatabase(t)
+	ts := testTokenStoreWithDB(db, WithFieldNames(fn))
+	cs := NewClientStoreWithDB(db, WithFieldNames(fn))
+
+	if err := ts.Create(ctx, testToken("access", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "s", Domain: "https://example.com", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, doc := range map[string]struct {
+		c      *mongo.Collection
+		filter bson.M
+		fields []string
+	}{
+		"basic":  {ts.Collection(CollectionBasic), testKindFilter(ts, CollectionBasic, bson.M{}), []string{fn.Data, fn.Codec, fn.ExpiredAt}},
+		"access": {ts.Collection(CollectionAccess), testKindFilter(ts, CollectionAccess, bson.M{}), []string{fn.BasicID, fn.ExpiredAt}},
+		"client": {cs.Collection(), bson.M{}, []string{fn.Secret, fn.Domain, fn.UserID}},
+	} {
+		raw, err := doc.c.FindOne(ctx, doc.filter).DecodeBytes()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, field := range doc.fields {
+			if _, err := raw.LookupErr(field); err != nil {
+				t.Errorf("%s document without %s: %s", name, field, raw)
+			}
+		}
+
+		for _, legacy := range []string{"Data", "ExpiredAt", "BasicID", "userid"} {
+			if _, err := raw.LookupErr(legacy); err == nil {
+				t.Errorf("%s document with the legacy %s: %s", name, legacy, raw)
+			}
+		}
+	}
+
+	indexes := ts.expectedIndexes()[ts.Collection(CollectionAccess).Name()]
+
+	if indexes[0] != fn.ExpiredAt+"_1" {
+		t.Errorf("expiry index %s, want %s_1", indexes[0], fn.ExpiredAt)
+	}
+}
+
+// the documents written with the legacy names stay readable after switching, and the other way around
+func TestFieldNamesDualRead(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name        string
+		write, read FieldNames
+	}{
+		{"legacy to snake case", LegacyFieldNames(), SnakeCaseFieldNames()},
+		{"snake case to legacy", SnakeCaseFieldNames(), LegacyFieldNames()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := testDatabase(t)
+
+			before := testTokenStoreWithDB(db, WithFieldNames(tc.write))
+
+			if err := before.Create(ctx, testToken("access", "refresh")); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := NewClientStoreWithDB(db, WithFieldNames(tc.write)).Set(&models.Client{ID: "c", Secret: "s", Domain: "https://example.com", UserID: "u"}); err != nil {
+				t.Fatal(err)
+			}
+
+			after := testTokenStoreWithDB(db, WithFieldNames(tc.read))
+
+			for _, get := range []func() error{
+				func() error { _, err := after.GetByAccess(ctx, "access"); return err },
+				func() error { _, err := after.GetByRefresh(ctx, "refresh"); return err },
+			} {
+				if err := get(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cs := NewClientStoreWithDB(db, WithFieldNames(tc.read))
+
+			info, err := cs.GetByID(ctx, "c")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.GetSecret() != "s" || info.GetDomain() != "https://example.com" || info.GetUserID() != "u" {
+				t.Fatalf("client %+v", info)
+			}
+
+			if err := after.RemoveByRefresh(ctx, "refresh"); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := after.GetByRefresh(ctx, "refresh"); err == nil {
+				t.Fatal("refresh token of the previous naming not removed")
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package mongo
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// defaultSecretAlphabet characters of the generated secrets and client IDs
+const defaultSecretAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// randomString n characters drawn uniformly from alphabet with crypto/rand
+func randomString(n int, alphabet string) (string, error) {
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		return "", fmt.Errorf("%w: alphabet of %d characters", ErrInvalidConfig, len(alphabet))
+	}
+
+	// bytes above the largest multiple of the alphabet size are rejected to keep the draw unbiased
+	max := 256 - 256%len(alphabet)
+	out := make([]byte, 0, n)
+	buf := make([]byte, n)
+
+	for len(out) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+
+		for _, b := range buf {
+			if int(b) < max && len(out) < n {
+				out = append(out, alphabet[int(b)%len(alphabet)])
+			}
+		}
+	}
+
+	return string(out), nil
+}
+
+// generateSecret a random secret of the configured length and alphabet
+func (cs *ClientStore) generateSecret() (string, error) {
+	length, alphabet := cs.ccfg.SecretLength, cs.ccfg.SecretAlphabet
+
+	if length <= 0 {
+		length = 32
+	}
+
+	if alphabet == "" {
+		alphabet = defaultSecretAlphabet
+	}
+
+	return randomString(length, alphabet)
+}
+
+// RegenerateSecret replace the secret of the client with a random one and return it, the only time it is
+// available in plaintext when HashSecrets is set. The previous secret stays valid for ClientConfig.SecretRotationGrace.
+func (cs *ClientStore) RegenerateSecret(ctx context.Context, id string) (plaintext string, err error) {
+	o := cs.op("RegenerateSecret", cs.ccfg.ClientsCName)
+	o.set("client_id", id)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", id); err != nil {
+			return err
+		}
+
+		secret, err := cs.generateSecret()
+
+		if err != nil {
+			return err
+		}
+
+		o.sensitive(secret)
+
+		raw, err := cs.findClient(ctx, id, nil)
+
+		if err != nil {
+			return err
+		}
+
+		if err := cs.rotate(ctx, o, decodeClient(raw, cs.fields()), secret, cs.ccfg.SecretRotationGrace); err != nil {
+			return err
+		}
+
+		plaintext = secret
+		return nil
+	})
+
+	return
+}
+
+// generateClientID a random client ID with the configured prefix and length
+func (cs *ClientStore) generateClientID() (string, error) {
+	length := cs.ccfg.ClientIDLength
+
+	if length <= 0 {
+		length = 24
+	}
+
+	id, err := randomString(length, defaultSecretAlphabet)
+
+	return cs.ccfg.ClientIDPrefix + id, err
+}
+
+// CreateClient store a new client from tmpl, generating its ID and(for the secret based methods) its secret when
+// tmpl leaves them empty. The returned client carries the plaintext secret, the only time it is available when
+// HashSecrets is set. An existing client with the ID of tmpl returns a DuplicateKeyError.
+func (cs *ClientStore) CreateClient(ctx context.Context, tmpl oauth2.ClientInfo) (info oauth2.ClientInfo, err error) {
+	o := cs.op("CreateClient", cs.ccfg.ClientsCName)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if tmpl == nil {
+			return fmt.Errorf("%w: nil client information", ErrInvalidArgument)
+		}
+
+		var err error
+
+		c := toClient(tmpl)
+		generatedID := c.ID == ""
+		o.sensitive(c.Secret)
+
+		if c.Secret == "" && usesSecret(c.GetTokenEndpointAuthMethod()) {
+			if c.Secret, err = cs.generateSecret(); err != nil {
+				return err
+			}
+
+			o.sensitive(c.Secret)
+		}
+
+		if lifetime := cs.ccfg.SecretLifetime; lifetime > 0 && c.Secret != "" && c.SecretExpiresAt.IsZero() {
+			c.SecretExpiresAt = time.Now().Add(lifetime)
+		}
+
+		// a generated ID colliding with an existing client is generated again
+		for attempt := 0; ; attempt++ {
+			if generatedID {
+				if c.ID, err = cs.generateClientID(); err != nil {
+					return err
+				}
+			}
+
+			o.set("client_id", c.ID)
+
+			now := time.Now()
+			err = cs.insert(ctx, o, c, now)
+
+			if dup, ok := err.(*DuplicateKeyError); ok && dup.Field == "_id" && generatedID && attempt < 2 {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			c.CreatedAt, c.UpdatedAt = now, now
+			info = c
+
+			return nil
+		}
+	})
+
+	return
+}
+
+// insert store a new client created at now
+func (cs *ClientStore) insert(ctx context.Context, o *operation, info oauth2.ClientInfo, now time.Time) error {
+	if err := cs.validateClient(info, true); err != nil {
+		return err
+	}
+
+	entity, err := cs.entity(o, info)
+
+	if err != nil {
+		return err
+	}
+
+	doc := append(entity.doc(cs.fields()), bson.E{Key: clientCreatedField, Value: now}, bson.E{Key: clientUpdatedField, Value: now})
+
+	return cs.retryQuota(func() error {
+		return cs.duplicateKey(cs.auditedHandler(ctx, o, entity.ID, func(ctx context.Context, c *mongo.Collection) error {
+			if err := cs.checkCollationConflict(ctx, c, entity.ID); err != nil {
+				return err
+			}
+
+			if err := cs.checkQuota(ctx, c, entity.ID, entity.UserID); err != nil {
+				return err
+			}
+
+			_, err := c.InsertOne(ctx, doc)
+			return err
+		}))
+	})
+}
@@ -0,0 +1,196 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// requireCharset fail unless s has n characters of alphabet
+func requireCharset(t *testing.T, s string, n int, alphabet string) {
+	t.Helper()
+
+	if len(s) != n {
+		t.Fatalf("%q of %d characters, want %d", s, len(s), n)
+	}
+
+	for _, r := range s {
+		if !strings.ContainsRune(alphabet, r) {
+			t.Fatalf("%q outside of the alphabet %q", s, alphabet)
+		}
+	}
+}
+
+func TestRandomString(t *testing.T) {
+	seen := make(map[string]bool)
+
+	for i := 0; i < 100; i++ {
+		s, err := randomString(32, defaultSecretAlphabet)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		requireCharset(t, s, 32, defaultSecretAlphabet)
+
+		if seen[s] {
+			t.Fatalf("%q drawn twice", s)
+		}
+
+		seen[s] = true
+	}
+
+	// every character of a small alphabet is drawn, none else
+	s, err := randomString(1000, "ab")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireCharset(t, s, 1000, "ab")
+
+	if n := strings.Count(s, "a"); n < 400 || n > 600 {
+		t.Fatalf("%d a in 1000 characters", n)
+	}
+
+	for _, alphabet := range []string{"", "a", strings.Repeat("a", 257)} {
+		if _, err := randomString(8, alphabet); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("alphabet of %d characters: %v", len(alphabet), err)
+		}
+	}
+}
+
+func TestRegenerateSecret(t *testing.T) {
+	var rec logRecorder
+
+	ccfg := NewDefaultClientConfig()
+	ccfg.SecretLength = 40
+	ccfg.SecretAlphabet = "0123456789abcdef"
+	ccfg.SecretRotationGrace = time.Hour
+
+	cs := newTestClientStore(t, ccfg, WithHashedSecrets(4), WithLogger(&rec))
+	ctx := context.Background()
+
+	if _, err := cs.RegenerateSecret(ctx, "missing"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v", err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "initial-secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := cs.GetByID(ctx, "c")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	first, err := cs.RegenerateSecret(ctx, "c")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := cs.RegenerateSecret(ctx, "c")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireCharset(t, first, 40, ccfg.SecretAlphabet)
+	requireCharset(t, second, 40, ccfg.SecretAlphabet)
+
+	if first == second {
+		t.Fatal("the same secret generated twice")
+	}
+
+	// the latest one and, within the grace window, the one it replaced
+	requireSecrets(t, cs, "c", map[string]bool{second: true, first: true, "initial-secret": false})
+
+	info, err := cs.GetByID(ctx, "c")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if secret := info.GetSecret(); secret == second || strings.Contains(secret, second) {
+		t.Fatalf("hashed secret revealed: %q", secret)
+	}
+
+	if !asClient(info).UpdatedAt.After(asClient(before).UpdatedAt) {
+		t.Fatal("update time unchanged")
+	}
+
+	for _, secret := range []string{first, second} {
+		if rec.contains(secret) {
+			t.Errorf("secret %q logged", secret)
+		}
+	}
+}
+
+func TestCreateClient(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.ClientIDPrefix = "app_"
+	ccfg.ClientIDLength = 20
+
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+
+	// generated ID and secret
+	info, err := cs.CreateClient(ctx, &models.Client{Domain: "https://example.com", UserID: "u"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(info.GetID(), "app_") {
+		t.Fatalf("generated ID %q", info.GetID())
+	}
+
+	requireCharset(t, strings.TrimPrefix(info.GetID(), "app_"), 20, defaultSecretAlphabet)
+	requireCharset(t, info.GetSecret(), 32, defaultSecretAlphabet)
+
+	requireSecrets(t, cs, info.GetID(), map[string]bool{info.GetSecret(): true})
+
+	if other, err := cs.CreateClient(ctx, &models.Client{Domain: "https://example.com"}); err != nil || other.GetID() == info.GetID() || other.GetSecret() == info.GetSecret() {
+		t.Fatalf("second client %v: %v", other, err)
+	}
+
+	// caller specified ID and secret are kept
+	info, err = cs.CreateClient(ctx, &models.Client{ID: "chosen", Secret: "chosen-secret-0123456789", Domain: "https://example.com"})
+
+	if err != nil || info.GetID() != "chosen" || info.GetSecret() != "chosen-secret-0123456789" {
+		t.Fatalf("client %v: %v", info, err)
+	}
+
+	if c := asClient(info); c == nil || c.CreatedAt.IsZero() {
+		t.Fatalf("client without creation time %+v", info)
+	}
+
+	// unlike Set, an existing ID isn't replaced
+	if _, err := cs.CreateClient(ctx, &models.Client{ID: "chosen", Domain: "https://example.org"}); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("existing ID: %v", err)
+	}
+
+	if stored, err := cs.GetByID(ctx, "chosen"); err != nil || stored.GetDomain() != "https://example.com" {
+		t.Fatalf("existing client %v: %v", stored, err)
+	}
+
+	// a public client gets no secret
+	info, err = cs.CreateClient(ctx, &Client{Client: models.Client{Domain: "https://example.com"}, Public: true})
+
+	if err != nil || info.GetSecret() != "" {
+		t.Fatalf("public client %v: %v", info, err)
+	}
+
+	// the template is validated like Set
+	if _, err := cs.CreateClient(ctx, &models.Client{Domain: "not a URI"}); !errors.Is(err, ErrInvalidClient) {
+		t.Fatalf("invalid template: %v", err)
+	}
+}
@@ -4,5 +4,6 @@ go 1.14
 
 require (
 	github.com/go-oauth2/oauth2/v4 v4.1.2
-	go.mongodb.org/mongo-driver v1.3.5
+	go.mongodb.org/mongo-driver v1.5.4
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 )
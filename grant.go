@@ -0,0 +1,55 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// grantAllowed report whether gt is one of the allowed grant types, an empty list allows every grant unless strict
+func grantAllowed(allowed []string, gt oauth2.GrantType, strict bool) bool {
+	if len(allowed) == 0 {
+		return !strict
+	}
+
+	for _, name := range allowed {
+		if name == gt.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsGrantAllowed report whether the client may use the grant type. A client without grant types(e.g. stored
+// before) may use any of them, unless ClientConfig.StrictGrantTypes is set. A missing client returns ErrClientNotFound.
+func (cs *ClientStore) IsGrantAllowed(ctx context.Context, clientID string, gt oauth2.GrantType) (ok bool, err error) {
+	o := cs.op("IsGrantAllowed", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+	o.set("grant_type", gt.String())
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, clientID, bson.M{clientGrantsField: 1, clientDeletedField: 1, clientStatusField: 1})
+
+		if err != nil {
+			return err
+		}
+
+		ok = grantAllowed(lookupStrings(raw, []string{clientGrantsField}), gt, cs.ccfg.StrictGrantTypes)
+		return nil
+	})
+
+	return
+}
+
+// ClientAuthorizedHandler the server.ClientAuthorizedHandler checking the grant types of the clients
+func (cs *ClientStore) ClientAuthorizedHandler() func(clientID string, grant oauth2.GrantType) (bool, error) {
+	return func(clientID string, grant oauth2.GrantType) (bool, error) {
+		return cs.IsGrantAllowed(context.Background(), clientID, grant)
+	}
+}
@@ -0,0 +1,78 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestIsGrantAllowed(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		strict := strict
+		name := "lenient"
+
+		if strict {
+			name = "strict"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			ccfg := NewDefaultClientConfig()
+			ccfg.StrictGrantTypes = strict
+			cs := newTestClientStore(t, ccfg)
+			ctx := context.Background()
+
+			if err := cs.Set(&Client{
+				Client:     models.Client{ID: "service", Secret: "secret", Domain: "https://example.com"},
+				GrantTypes: []string{oauth2.ClientCredentials.String()},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := cs.Set(&models.Client{ID: "legacy", Secret: "secret", Domain: "https://example.com"}); err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				id      string
+				grant   oauth2.GrantType
+				allowed bool
+			}{
+				{"service", oauth2.ClientCredentials, true},
+				{"service", oauth2.PasswordCredentials, false},
+				{"service", oauth2.AuthorizationCode, false},
+				// the clients without grant types are only denied in strict mode
+				{"legacy", oauth2.PasswordCredentials, !strict},
+				{"legacy", oauth2.ClientCredentials, !strict},
+			}
+
+			handler := cs.ClientAuthorizedHandler()
+
+			for _, tt := range tests {
+				if ok, err := cs.IsGrantAllowed(ctx, tt.id, tt.grant); err != nil || ok != tt.allowed {
+					t.Fatalf("%s %s: allowed %v(%v), want %v", tt.id, tt.grant, ok, err, tt.allowed)
+				}
+
+				if ok, err := handler(tt.id, tt.grant); err != nil || ok != tt.allowed {
+					t.Fatalf("handler %s %s: allowed %v(%v), want %v", tt.id, tt.grant, ok, err, tt.allowed)
+				}
+			}
+
+			info, err := cs.GetByID(ctx, "service")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c := asClient(info); len(c.GrantTypes) != 1 || c.GrantTypes[0] != oauth2.ClientCredentials.String() {
+				t.Fatalf("grant types %v", c.GrantTypes)
+			}
+
+			if _, err := cs.IsGrantAllowed(ctx, "missing", oauth2.ClientCredentials); !errors.Is(err, ErrClientNotFound) {
+				t.Fatalf("missing client: %v, want ErrClientNotFound", err)
+			}
+		})
+	}
+}
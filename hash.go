@@ -0,0 +1,214 @@
+package mongo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// hashedTokenPrefix marks the hashed keys of the access/refresh documents
+const hashedTokenPrefix = "sha256:"
+
+// tokenPrefixField key prefix(TokenConfig.KeyPrefix) of the basic, access and refresh documents, absent without
+// prefix. The keys themselves may be hashed, the bulk operations match this field instead.
+const tokenPrefixField = "key_prefix"
+
+// HashMigrationReport outcome of MigrateTokenHashes
+type HashMigrationReport struct {
+	// plaintext documents found
+	Scanned int64
+	// documents rewritten with a hashed key
+	Migrated int64
+}
+
+// plainKey the key prefix and the issuer of the operation prepended to a code or token
+func (ts *TokenStore) plainKey(ctx context.Context, token string) string {
+	if issuer := ts.issuer(ctx); issuer != "" {
+		token = issuer + ":" + token
+	}
+
+	return ts.tcfg.KeyPrefix + token
+}
+
+// withKeyPrefix restrict the filter of a bulk operation to the documents of the key prefix of the store
+func (ts *TokenStore) withKeyPrefix(filter bson.M) bson.M {
+	if ts.tcfg.KeyPrefix != "" {
+		filter[tokenPrefixField] = ts.tcfg.KeyPrefix
+	}
+
+	return filter
+}
+
+// codeKey the document key of an authorization code
+func (ts *TokenStore) codeKey(ctx context.Context, code string) string {
+	return ts.kindPrefix(ts.tcfg.BasicCName) + ts.plainKey(ctx, code)
+}
+
+// tokenKey the document key of an access/refresh token of the collection name: the prefixed token, hashed when
+// hashing is enabled
+func (ts *TokenStore) tokenKey(ctx context.Context, name, token string) string {
+	return ts.kindPrefix(name) + ts.hashKey(ts.plainKey(ctx, token))
+}
+
+// hashKey SHA-256(or HMAC-SHA256 with the pepper) of a plaintext key when hashing is enabled
+func (ts *TokenStore) hashKey(token string) string {
+	if !ts.tcfg.HashTokens {
+		return token
+	}
+
+	var sum []byte
+
+	if len(ts.tcfg.TokenPepper) > 0 {
+		mac := hmac.New(sha256.New, ts.tcfg.TokenPepper)
+		mac.Write([]byte(token))
+		sum = mac.Sum(nil)
+	} else {
+		digest := sha256.Sum256([]byte(token))
+		sum = digest[:]
+	}
+
+	return hashedTokenPrefix + hex.EncodeToString(sum)
+}
+
+// tokenFilter match the document of an access/refresh token, the plaintext key is only matched
+// during a migration and never for values looking like a hashed key
+func (ts *TokenStore) tokenFilter(ctx context.Context, name, token string) bson.M {
+	key := ts.tokenKey(ctx, name, token)
+
+	if ts.tcfg.HashTokens && ts.tcfg.ReadPlaintextTokens && !strings.HasPrefix(token, hashedTokenPrefix) {
+		return ts.withIssuer(ctx, bson.M{"_id": bson.M{"$in": bson.A{key, ts.kindPrefix(name) + ts.plainKey(ctx, token)}}})
+	}
+
+	return ts.withIssuer(ctx, bson.M{"_id": key})
+}
+
+// MigrateTokenHashes rewrite the plaintext keys of the access and refresh documents to hashed keys,
+// batchSize documents at a time(The default is 100). Each document is moved in its own transaction,
+// the migration can be interrupted and run again.
+func (ts *TokenStore) MigrateTokenHashes(ctx context.Context, batchSize int) (HashMigrationReport, error) {
+	var report HashMigrationReport
+
+	if err := ts.writable(); err != nil {
+		return report, err
+	}
+
+	if !ts.tcfg.HashTokens {
+		return report, fmt.Errorf("%w: HashTokens is disabled", ErrInvalidConfig)
+	}
+
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	for _, name := range []string{ts.tcfg.AccessCName, ts.tcfg.RefreshCName} {
+		o := ts.op("MigrateTokenHashes", name)
+
+		err := ts.run(ctx, o, func(ctx context.Context) error {
+			return ts.migrateTokenHashes(ctx, o, name, int64(batchSize), &report)
+		})
+
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+func (ts *TokenStore) migrateTokenHashes(ctx context.Context, o *operation, name string, batchSize int64, report *HashMigrationReport) error {
+	var migrated int64
+
+	defer func() { o.set("documents", migrated) }()
+
+	// the plaintext documents of this store, other prefixes belong to other environments: without prefix only the
+	// documents recording none are migrated
+	kind := regexp.QuoteMeta(ts.kindPrefix(name))
+	filter := bson.M{"$and": bson.A{
+		bson.M{"_id": bson.M{"$not": primitive.Regex{Pattern: "^" + kind + hashedTokenPrefix}}},
+		bson.M{"_id": primitive.Regex{Pattern: "^" + kind + regexp.QuoteMeta(ts.tcfg.KeyPrefix)}},
+	}}
+
+	if ts.tcfg.KeyPrefix == "" {
+		filter[tokenPrefixField] = bson.M{"$exists": false}
+	} else {
+		filter[tokenPrefixField] = ts.tcfg.KeyPrefix
+	}
+
+	for {
+		var batch []bson.Raw
+
+		err := ts.readHandler(ctx, name, true, func(ctx context.Context, c *mongo.Collection) error {
+			cur, err := c.Find(ctx, filter, options.Find().SetLimit(batchSize))
+
+			if err != nil {
+				return err
+			}
+
+			defer cur.Close(ctx)
+
+			for cur.Next(ctx) {
+				batch = append(batch, append(bson.Raw(nil), cur.Current...))
+			}
+
+			return cur.Err()
+		})
+
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+
+		for _, raw := range batch {
+			report.Scanned++
+			// only the document being moved can appear in the error
+			o.secrets = o.secrets[:0]
+			o.sensitive(lookupString(raw, []string{"_id"}))
+
+			if err := ts.migrateTokenHash(ctx, name, raw); err != nil {
+				return err
+			}
+
+			migrated++
+			report.Migrated++
+		}
+	}
+}
+
+// migrateTokenHash move one document to its hashed key
+func (ts *TokenStore) migrateTokenHash(ctx context.Context, name string, raw bson.Raw) error {
+	token := lookupString(raw, []string{"_id"})
+
+	elems, err := raw.Elements()
+
+	if err != nil {
+		return err
+	}
+
+	kind := ts.kindPrefix(name)
+	key := kind + ts.hashKey(strings.TrimPrefix(token, kind))
+	doc := bson.D{{Key: "_id", Value: key}}
+
+	for _, elem := range elems {
+		if elem.Key() != "_id" {
+			doc = append(doc, bson.E{Key: elem.Key(), Value: elem.Value()})
+		}
+	}
+
+	return ts.colHandler(ctx, name, func(ctx context.Context, c *mongo.Collection) error {
+		// a concurrent run may have moved the document already, a duplicate key error would abort the transaction
+		if _, err := c.ReplaceOne(ctx, bson.M{"_id": key}, doc, options.Replace().SetUpsert(true)); err != nil {
+			return err
+		}
+
+		_, err := c.DeleteOne(ctx, bson.M{"_id": token})
+		return err
+	})
+}
@@ -0,0 +1,232 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// storedKeys the document keys of the collection kind, without the kind prefix of the single collection layout
+func storedKeys(t *testing.T, ts *TokenStore, kind CollectionKind) []string {
+	t.Helper()
+
+	ctx := context.Background()
+	cur, err := ts.Collection(kind).Find(ctx, testKindFilter(ts, kind, bson.M{}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer cur.Close(ctx)
+
+	prefix := ts.kindPrefix(map[CollectionKind]string{CollectionAccess: ts.tcfg.AccessCName, CollectionRefresh: ts.tcfg.RefreshCName}[kind])
+
+	var keys []string
+
+	for cur.Next(ctx) {
+		keys = append(keys, strings.TrimPrefix(lookupString(cur.Current, []string{"_id"}), prefix))
+	}
+
+	return keys
+}
+
+func TestHashedTokens(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name   string
+		pepper []byte
+	}{
+		{"sha256", nil},
+		{"hmac", []byte("pepper")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := testDatabase(t)
+			ts := testTokenStoreWithDB(db, WithHashedTokens(tc.pepper))
+
+			if err := ts.Create(ctx, testToken("access", "refresh")); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, kind := range map[string]CollectionKind{"access": CollectionAccess, "refresh": CollectionRefresh} {
+				for _, key := range storedKeys(t, ts, kind) {
+					if !strings.HasPrefix(key, hashedTokenPrefix) || strings.Contains(key, "access") || strings.Contains(key, "refresh") {
+						t.Fatalf("%s key %s", name, key)
+					}
+				}
+			}
+
+			if _, err := ts.GetByAccess(ctx, "access"); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := ts.GetByRefresh(ctx, "refresh"); err != nil {
+				t.Fatal(err)
+			}
+
+			// the hash itself is no token
+			if _, err := ts.GetByAccess(ctx, storedKeys(t, ts, CollectionAccess)[0]); !errors.Is(err, mongo.ErrNoDocuments) {
+				t.Fatalf("lookup by the hash: %v", err)
+			}
+
+			if err := ts.RemoveByAccess(ctx, "access"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := ts.RemoveByRefresh(ctx, "refresh"); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, kind := range map[string]CollectionKind{"access": CollectionAccess, "refresh": CollectionRefresh} {
+				if keys := storedKeys(t, ts, kind); len(keys) != 0 {
+					t.Fatalf("%s keys %v after the removals", name, keys)
+				}
+			}
+		})
+	}
+}
+
+// the pepper keys the hashes, another pepper finds nothing
+func TestHashedTokensPepper(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	if err := testTokenStoreWithDB(db, WithHashedTokens([]byte("a"))).Create(ctx, testToken("access", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := testTokenStoreWithDB(db, WithHashedTokens([]byte("b"))).GetByAccess(ctx, "access"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("lookup with another pepper: %v", err)
+	}
+}
+
+func TestMigrateTokenHashes(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	plain := testTokenStoreWithDB(db)
+	hashed := testTokenStoreWithDB(db, WithHashedTokens(nil), WithTokenConfig(&TokenConfig{ReadPlaintextTokens: true}))
+
+	// the tokens created before hashing, after it, and one a previous run moved without removing the plaintext
+	for _, token := range []string{"a1", "a2", "a3"} {
+		if err := plain.Create(ctx, testToken(token, "r"+token[1:])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := hashed.Create(ctx, testToken("a4", "r4")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hashed.Create(ctx, testToken("a3", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	// both kinds of keys are found during the migration
+	for _, token := range []string{"a1", "a4"} {
+		if _, err := hashed.GetByAccess(ctx, token); err != nil {
+			t.Fatalf("%s before the migration: %v", token, err)
+		}
+	}
+
+	report, err := hashed.MigrateTokenHashes(ctx, 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Scanned != 6 || report.Migrated != 6 {
+		t.Fatalf("report %+v, want 6 scanned and migrated", report)
+	}
+
+	for name, kind := range map[string]CollectionKind{"access": CollectionAccess, "refresh": CollectionRefresh} {
+		keys := storedKeys(t, hashed, kind)
+
+		if len(keys) != 4 {
+			t.Fatalf("%s keys %v, want 4", name, keys)
+		}
+
+		for _, key := range keys {
+			if !strings.HasPrefix(key, hashedTokenPrefix) {
+				t.Fatalf("%s plaintext key %s after the migration", name, key)
+			}
+		}
+	}
+
+	// without the plaintext fallback
+	ts := testTokenStoreWithDB(db, WithHashedTokens(nil))
+
+	for _, token := range []string{"a1", "a2", "a3", "a4"} {
+		if _, err := ts.GetByAccess(ctx, token); err != nil {
+			t.Fatalf("%s after the migration: %v", token, err)
+		}
+	}
+
+	for _, token := range []string{"r1", "r2", "r3", "r4"} {
+		if _, err := ts.GetByRefresh(ctx, token); err != nil {
+			t.Fatalf("%s after the migration: %v", token, err)
+		}
+	}
+
+	if err := ts.RemoveByAccess(ctx, "a1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByAccess(ctx, "a1"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("removed migrated token: %v", err)
+	}
+
+	// nothing left to migrate
+	if report, err := hashed.MigrateTokenHashes(ctx, 2); err != nil || report.Scanned != 0 {
+		t.Fatalf("second run %+v: %v", report, err)
+	}
+}
+
+func TestMigrateTokenHashesDisabled(t *testing.T) {
+	if _, err := testTokenStoreWithDB(testDatabase(t)).MigrateTokenHashes(context.Background(), 0); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("migration without hashing: %v", err)
+	}
+}
+
+// the migration of a store leaves the documents of the other key prefixes alone, an empty prefix included
+func TestMigrateTokenHashesKeyPrefix(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	staging := testTokenStoreWithDB(db, WithKeyPrefix("staging:"))
+
+	if err := staging.Create(ctx, testToken("s1", "sr1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testTokenStoreWithDB(db).Create(ctx, testToken("a1", "r1")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, prefix := range []string{"", "staging:"} {
+		hashed := testTokenStoreWithDB(db, WithKeyPrefix(prefix), WithHashedTokens(nil))
+
+		if report, err := hashed.MigrateTokenHashes(ctx, 0); err != nil || report.Scanned != 2 || report.Migrated != 2 {
+			t.Fatalf("migration of the prefix %q %+v: %v", prefix, report, err)
+		}
+
+		// the documents of staging are migrated by its own store only
+		if keys := storedKeys(t, staging, CollectionAccess); prefix == "" && (len(keys) != 2 || !strings.Contains(strings.Join(keys, " "), "staging:s1")) {
+			t.Fatalf("access keys %v after the migration without prefix", keys)
+		}
+	}
+
+	if _, err := testTokenStoreWithDB(db, WithKeyPrefix("staging:"), WithHashedTokens(nil)).GetByRefresh(ctx, "sr1"); err != nil {
+		t.Fatalf("migrated staging token: %v", err)
+	}
+
+	for _, key := range storedKeys(t, staging, CollectionRefresh) {
+		if !strings.HasPrefix(key, hashedTokenPrefix) {
+			t.Fatalf("plaintext key %s after both migrations", key)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package mongo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// HealthReport result of a store health check
+type HealthReport struct {
+	// server round trip error, nil when the server is reachable
+	PingErr error
+	// server round trip duration
+	Latency time.Duration
+	// state of every collection used by the store
+	Collections []CollectionHealth
+}
+
+// CollectionHealth state of a single collection
+type CollectionHealth struct {
+	Name   string
+	Exists bool
+	// expected index names which were not found on the collection
+	MissingIndexes []string
+	// error raised while inspecting the collection
+	Err error
+}
+
+// OK report whether the server is reachable and all collections and indexes exist
+func (r HealthReport) OK() bool {
+	if r.PingErr != nil {
+		return false
+	}
+
+	for _, c := range r.Collections {
+		if !c.Exists || len(c.MissingIndexes) > 0 || c.Err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+func ping(ctx context.Context, client *mongo.Client, rp *readpref.ReadPref) error {
+	if rp == nil {
+		rp = readpref.Primary()
+	}
+
+	return client.Ping(ctx, rp)
+}
+
+// checkHealth ping the server and verify the expected collections(name => index names) exist
+func checkHealth(ctx context.Context, db *mongo.Database, rp *readpref.ReadPref, expected map[string][]string) HealthReport {
+	var report HealthReport
+
+	start := time.Now()
+	report.PingErr = ping(ctx, db.Client(), rp)
+	report.Latency = time.Since(start)
+
+	if report.PingErr != nil {
+		return report
+	}
+
+	for _, name := range sortedNames(expected) {
+		report.Collections = append(report.Collections, checkCollection(ctx, db, name, expected[name]))
+	}
+
+	return report
+}
+
+func checkCollection(ctx context.Context, db *mongo.Database, name string, indexes []string) CollectionHealth {
+	ch := CollectionHealth{Name: name}
+
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": name})
+
+	if err != nil {
+		ch.Err = err
+		return ch
+	}
+
+	ch.Exists = len(names) > 0
+
+	if !ch.Exists {
+		ch.MissingIndexes = indexes
+		return ch
+	}
+
+	cur, err := db.Collection(name).Indexes().List(ctx)
+
+	if err != nil {
+		ch.Err = err
+		return ch
+	}
+
+	defer cur.Close(ctx)
+
+	found := make(map[string]bool)
+
+	for cur.Next(ctx) {
+		var idx struct {
+			Name string `bson:"name"`
+		}
+
+		if err := cur.Decode(&idx); err != nil {
+			ch.Err = err
+			return ch
+		}
+
+		found[idx.Name] = true
+	}
+
+	if err := cur.Err(); err != nil {
+		ch.Err = err
+		return ch
+	}
+
+	for _, name := range indexes {
+		if !found[name] {
+			ch.MissingIndexes = append(ch.MissingIndexes, name)
+		}
+	}
+
+	return ch
+}
+
+// sortedNames collection names of expected in a stable order
+func sortedNames(expected map[string][]string) []string {
+	names := make([]string, 0, len(expected))
+
+	for name := range expected {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,134 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHealthy(t *testing.T) {
+	ctx := context.Background()
+
+	cs := newTestClientStore(t)
+
+	if err := cs.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if report := cs.Healthy(ctx); !report.OK() {
+		t.Fatalf("client store unhealthy: %+v", report)
+	}
+
+	ts := newTestTokenStore(t)
+
+	if err := ts.EnsureIndexes(ctx); err != nil {
+		skipNotImplemented(t, err)
+		t.Fatal(err)
+	}
+
+	if err := ts.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	report := ts.Healthy(ctx)
+
+	if !report.OK() {
+		t.Fatalf("token store unhealthy: %+v", report)
+	}
+
+	if len(report.Collections) != len(ts.expectedIndexes()) {
+		t.Fatalf("%d collections checked, want %d", len(report.Collections), len(ts.expectedIndexes()))
+	}
+}
+
+func TestHealthyUnreachable(t *testing.T) {
+	ts := testTokenStoreWithDB(unreachableDatabase(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := ts.Ping(ctx); err == nil {
+		t.Fatal("ping of an unreachable server succeeded")
+	}
+
+	report := ts.Healthy(ctx)
+
+	if report.OK() || report.PingErr == nil {
+		t.Fatalf("unreachable server reported healthy: %+v", report)
+	}
+
+	if len(report.Collections) != 0 {
+		t.Fatalf("collections inspected without server: %+v", report.Collections)
+	}
+}
+
+// the caller's deadline bounds the round trip
+func TestPingDeadline(t *testing.T) {
+	ts := testTokenStoreWithDB(unreachableDatabase(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := ts.Ping(ctx); err == nil {
+		t.Fatal("ping of an unreachable server succeeded")
+	}
+
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("ping returned after %v, past the deadline", d)
+	}
+}
+
+func TestHealthyMissingIndex(t *testing.T) {
+	ctx := context.Background()
+
+	cs := newTestClientStore(t)
+	name := cs.fields().Domain + "_1"
+
+	if _, err := cs.conns.database().Collection(cs.ccfg.ClientsCName).Indexes().DropOne(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	report := cs.Healthy(ctx)
+
+	if report.OK() {
+		t.Fatal("missing index reported healthy")
+	}
+
+	for _, c := range report.Collections {
+		if c.Name != cs.ccfg.ClientsCName {
+			continue
+		}
+
+		if !c.Exists || len(c.MissingIndexes) != 1 || c.MissingIndexes[0] != name {
+			t.Fatalf("clients collection %+v, want missing %s", c, name)
+		}
+
+		return
+	}
+
+	t.Fatalf("clients collection not checked: %+v", report.Collections)
+}
+
+func TestHealthyMissingCollection(t *testing.T) {
+	ctx := context.Background()
+
+	ts := newTestTokenStore(t)
+
+	if err := ts.conns.database().Collection(ts.tcfg.DenylistCName).Drop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	report := ts.Healthy(ctx)
+
+	if report.OK() {
+		t.Fatal("missing collection reported healthy")
+	}
+
+	for _, c := range report.Collections {
+		if c.Name == ts.tcfg.DenylistCName && (c.Exists || len(c.MissingIndexes) == 0) {
+			t.Fatalf("dropped collection %+v", c)
+		}
+	}
+}
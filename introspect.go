@@ -0,0 +1,132 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// token type hints of RFC 7662 introspection requests
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// IntrospectionResult the RFC 7662 introspection response of a token, marshaled as is by the introspection
+// endpoint. Only Active is set for inactive tokens.
+type IntrospectionResult struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	// Bearer or DPoP for access tokens, empty for refresh tokens which have no RFC 6749 token type
+	TokenType string `json:"token_type,omitempty"`
+}
+
+// introspect the result of the token from the collection, nil when the token isn't stored there
+func (ts *TokenStore) introspect(ctx context.Context, o *operation, cname, token string, now time.Time) (*IntrospectionResult, error) {
+	var td tokenData
+
+	// getByToken reads the collection of its operation
+	info, err := ts.getByToken(ctx, ts.op("Introspect", cname), token, cname == ts.tcfg.RefreshCName, func(data tokenData) error {
+		td = data
+		return nil
+	})
+
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	o.set("basic_id", td.BasicID)
+
+	res := &IntrospectionResult{
+		Active:   true,
+		Scope:    info.GetScope(),
+		ClientID: info.GetClientID(),
+		Subject:  info.GetUserID(),
+		Issuer:   td.Issuer,
+		Audience: td.Audience,
+	}
+
+	created, expiresIn := info.GetAccessCreateAt(), info.GetAccessExpiresIn()
+
+	if cname == ts.tcfg.RefreshCName {
+		created, expiresIn = info.GetRefreshCreateAt(), info.GetRefreshExpiresIn()
+	} else if td.Confirmation.JKT != "" {
+		res.TokenType = "DPoP"
+	} else {
+		res.TokenType = "Bearer"
+	}
+
+	if !created.IsZero() {
+		res.IssuedAt = created.Unix()
+	}
+
+	// a zero lifetime never expires, like the oauth2 manager treats it
+	if expiresIn > 0 {
+		exp := created.Add(expiresIn)
+
+		if !now.Before(exp) {
+			return &IntrospectionResult{}, nil
+		}
+
+		res.ExpiresAt = exp.Unix()
+	}
+
+	return res, nil
+}
+
+// Introspect resolve the access or refresh token for a RFC 7662 introspection response, looking it up first
+// in the collection of hint(TokenTypeHintAccessToken by default) then in the other one. Unknown, removed and
+// expired tokens are inactive without error, as are the access tokens with SkipAccessTokenStorage.
+func (ts *TokenStore) Introspect(ctx context.Context, token string, hint string) (result IntrospectionResult, err error) {
+	cnames := []string{ts.tcfg.AccessCName, ts.tcfg.RefreshCName}
+
+	if ts.tcfg.SkipAccessTokenStorage {
+		cnames = cnames[1:]
+	} else if hint == TokenTypeHintRefreshToken {
+		cnames[0], cnames[1] = cnames[1], cnames[0]
+	}
+
+	o := ts.op("Introspect", cnames[0])
+	o.sensitive(token)
+	o.set("hint", hint)
+
+	err = ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("token", token); err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		for _, cname := range cnames {
+			res, err := ts.introspect(ctx, o, cname, token, now)
+
+			if err != nil {
+				return err
+			}
+
+			if res != nil {
+				result = *res
+				o.set("active", result.Active)
+
+				return nil
+			}
+		}
+
+		o.set("active", false)
+
+		return nil
+	})
+
+	return
+}
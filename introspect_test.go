@@ -0,0 +1,137 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIntrospect(t *testing.T) {
+	ts := newTestTokenStore(t)
+	ctx := context.Background()
+	info := testToken("access", "refresh")
+
+	if err := ts.Create(ctx, info); err != nil {
+		t.Fatal(err)
+	}
+
+	expired := testToken("expired-access", "expired-refresh")
+	expired.AccessCreateAt = time.Now().Add(-2 * time.Hour)
+	expired.RefreshCreateAt = expired.AccessCreateAt
+
+	if err := ts.Create(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.CreateWithConfirmation(ctx, testToken("dpop-access", ""), Confirmation{JKT: "0ZcOCORZNYy-DWpqq30jZyJGHTN0d2HglBV3uiguA4I"}); err != nil {
+		t.Fatal(err)
+	}
+
+	access := IntrospectionResult{
+		Active:    true,
+		Scope:     "read",
+		ClientID:  "c",
+		Subject:   "u",
+		IssuedAt:  info.AccessCreateAt.Unix(),
+		ExpiresAt: info.AccessCreateAt.Add(info.AccessExpiresIn).Unix(),
+		TokenType: "Bearer",
+	}
+	refresh := access
+	refresh.IssuedAt = info.RefreshCreateAt.Unix()
+	refresh.ExpiresAt = info.RefreshCreateAt.Add(info.RefreshExpiresIn).Unix()
+	refresh.TokenType = ""
+
+	// the refresh token of the expired access token is still valid
+	expiredRefresh := refresh
+	expiredRefresh.IssuedAt = expired.RefreshCreateAt.Unix()
+	expiredRefresh.ExpiresAt = expired.RefreshCreateAt.Add(expired.RefreshExpiresIn).Unix()
+
+	tests := []struct {
+		name, token, hint string
+		want              IntrospectionResult
+	}{
+		{"access token", "access", "", access},
+		{"access token with its hint", "access", TokenTypeHintAccessToken, access},
+		{"access token with the wrong hint", "access", TokenTypeHintRefreshToken, access},
+		{"refresh token", "refresh", "", refresh},
+		{"refresh token with its hint", "refresh", TokenTypeHintRefreshToken, refresh},
+		{"expired access token", "expired-access", "", IntrospectionResult{}},
+		{"refresh token of an expired access token", "expired-refresh", TokenTypeHintRefreshToken, expiredRefresh},
+		{"unknown token", "unknown", "", IntrospectionResult{}},
+	}
+
+	for _, tt := range tests {
+		got, err := ts.Introspect(ctx, tt.token, tt.hint)
+
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if got, err := ts.Introspect(ctx, "dpop-access", ""); err != nil || !got.Active || got.TokenType != "DPoP" {
+		t.Fatalf("DPoP-bound token %+v: %v", got, err)
+	}
+
+	// an inactive token tells nothing else
+	got, err := ts.Introspect(ctx, "unknown", "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := json.Marshal(got); err != nil || string(data) != `{"active":false}` {
+		t.Fatalf("inactive response %s: %v", data, err)
+	}
+
+	// a revoked token is removed
+	if err := ts.RemoveByAccess(ctx, "access"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := ts.Introspect(ctx, "access", ""); err != nil || got.Active {
+		t.Fatalf("revoked access token %+v: %v", got, err)
+	}
+
+	if got, err := ts.Introspect(ctx, "refresh", ""); err != nil || !got.Active {
+		t.Fatalf("refresh token of the revoked access token %+v: %v", got, err)
+	}
+
+	if err := ts.RemoveByRefresh(ctx, "refresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := ts.Introspect(ctx, "refresh", TokenTypeHintRefreshToken); err != nil || got.Active {
+		t.Fatalf("revoked refresh token %+v: %v", got, err)
+	}
+
+	_, err = ts.Introspect(ctx, "", "")
+
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("empty token: %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestIntrospectWithoutAccessTokenStorage(t *testing.T) {
+	ts := newTestTokenStore(t, WithoutAccessTokenStorage(false))
+	ctx := context.Background()
+
+	if err := ts.Create(ctx, testToken("access", "refresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the access tokens are verified by their signature, not the store
+	if got, err := ts.Introspect(ctx, "access", TokenTypeHintAccessToken); err != nil || got.Active {
+		t.Fatalf("unstored access token %+v: %v", got, err)
+	}
+
+	if got, err := ts.Introspect(ctx, "refresh", TokenTypeHintAccessToken); err != nil || !got.Active || got.TokenType != "" {
+		t.Fatalf("refresh token %+v: %v", got, err)
+	}
+}
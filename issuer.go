@@ -0,0 +1,38 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type issuerKey struct{}
+
+// ContextWithIssuer attach the issuer of the request to ctx, it takes precedence over TokenConfig.Issuer
+func ContextWithIssuer(ctx context.Context, issuer string) context.Context {
+	return context.WithValue(ctx, issuerKey{}, issuer)
+}
+
+// IssuerFromContext the issuer attached by ContextWithIssuer, empty if none
+func IssuerFromContext(ctx context.Context) string {
+	issuer, _ := ctx.Value(issuerKey{}).(string)
+	return issuer
+}
+
+// issuer the issuer of the operation, empty when issuers aren't used
+func (ts *TokenStore) issuer(ctx context.Context) string {
+	if issuer := IssuerFromContext(ctx); issuer != "" {
+		return issuer
+	}
+
+	return ts.tcfg.Issuer
+}
+
+// withIssuer restrict filter to the documents of the issuer of the operation
+func (ts *TokenStore) withIssuer(ctx context.Context, filter bson.M) bson.M {
+	if issuer := ts.issuer(ctx); issuer != "" {
+		filter[ts.fields().Issuer] = issuer
+	}
+
+	return filter
+}
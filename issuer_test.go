@@ -0,0 +1,116 @@
+package mongo
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestIssuers(t *testing.T) {
+	db := testDatabase(t)
+
+	// brand-a from the configuration, brand-b from the context of a store without issuer
+	a := testTokenStoreWithDB(db, WithIssuer("brand-a"))
+	b := testTokenStoreWithDB(db)
+
+	ctx := context.Background()
+	actx, bctx := ctx, ContextWithIssuer(ctx, "brand-b")
+
+	for _, s := range []struct {
+		ts     *TokenStore
+		ctx    context.Context
+		userID string
+	}{{a, actx, "user-a"}, {b, bctx, "user-b"}} {
+		token := testToken("access", "refresh")
+		token.UserID = s.userID
+
+		if err := s.ts.Create(s.ctx, token); err != nil {
+			t.Fatal(err)
+		}
+
+		code := testCode("code")
+		code.UserID = s.userID
+
+		if err := s.ts.Create(s.ctx, code); err != nil {
+			t.Fatal(err)
+		}
+
+		expired := testToken("expired", "")
+		expired.AccessCreateAt = time.Now().Add(-2 * time.Hour)
+
+		if err := s.ts.Create(s.ctx, expired); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the identical tokens are found by their own issuer
+	for _, s := range []struct {
+		ts     *TokenStore
+		ctx    context.Context
+		userID string
+	}{{a, actx, "user-a"}, {b, bctx, "user-b"}} {
+		if info, err := s.ts.GetByAccess(s.ctx, "access"); err != nil || info.GetUserID() != s.userID {
+			t.Fatalf("access token of %s %v: %v", s.userID, info, err)
+		}
+
+		if info, err := s.ts.GetByRefresh(s.ctx, "refresh"); err != nil || info.GetUserID() != s.userID {
+			t.Fatalf("refresh token of %s %v: %v", s.userID, info, err)
+		}
+
+		if info, err := s.ts.GetByCode(s.ctx, "code"); err != nil || info.GetUserID() != s.userID {
+			t.Fatalf("code of %s %v: %v", s.userID, info, err)
+		}
+	}
+
+	// the issuer is part of the key and recorded in its field
+	raw, err := a.Collection(CollectionAccess).FindOne(ctx, bson.M{"Issuer": "brand-b", "_id": bson.M{"$regex": "access$"}}).DecodeBytes()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id := strings.TrimPrefix(lookupString(raw, []string{"_id"}), a.kindPrefix(a.tcfg.AccessCName)); !strings.HasPrefix(id, "brand-b:") {
+		t.Fatalf("key %q without the issuer", id)
+	}
+
+	// the removals and the cleanup of an issuer leave the other alone
+	if err := a.RemoveByAccess(actx, "access"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.RemoveByCode(actx, "code"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.RemoveExpired(actx); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := b.GetByAccess(bctx, "access"); err != nil || info.GetUserID() != "user-b" {
+		t.Fatalf("access token of brand-b %v: %v", info, err)
+	}
+
+	if info, err := b.GetByCode(bctx, "code"); err != nil || info.GetUserID() != "user-b" {
+		t.Fatalf("code of brand-b %v: %v", info, err)
+	}
+
+	if n, err := a.Collection(CollectionAccess).CountDocuments(ctx, testKindFilter(a, CollectionAccess, bson.M{"Issuer": "brand-b"})); err != nil || n != 2 {
+		t.Fatalf("%d access documents of brand-b: %v", n, err)
+	}
+
+	if n, err := b.RemoveAllByUserID(bctx, "user-a"); err != nil || n != 0 {
+		t.Fatalf("%d documents of brand-a removed by brand-b: %v", n, err)
+	}
+
+	if info, err := a.GetByRefresh(actx, "refresh"); err != nil || info.GetUserID() != "user-a" {
+		t.Fatalf("refresh token of brand-a %v: %v", info, err)
+	}
+
+	// no issuer finds neither
+	if info, err := testTokenStoreWithDB(db).GetByAccess(ctx, "access"); err == nil && info != nil {
+		t.Fatalf("token found without issuer: %v", info)
+	}
+}
@@ -0,0 +1,171 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fields of the public keys of the private_key_jwt clients, the JWKS kept as the JSON it was given in
+const (
+	clientJWKSField    = "jwks"
+	clientJWKSURIField = "jwks_uri"
+)
+
+// keysClient client information registering its public keys
+type keysClient interface {
+	GetJWKS() json.RawMessage
+	GetJWKSURI() string
+}
+
+// checkJWKS the reason the JWKS is rejected, empty when valid: it must be a JSON object with a keys array no
+// larger than max bytes
+func checkJWKS(jwks json.RawMessage, max int) string {
+	if max <= 0 {
+		max = 64 << 10
+	}
+
+	if len(jwks) > max {
+		return fmt.Sprintf("of %d bytes exceeds %d", len(jwks), max)
+	}
+
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+
+	if err := json.Unmarshal(jwks, &set); err != nil || set.Keys == nil {
+		return "isn't a JSON Web Key Set"
+	}
+
+	return ""
+}
+
+// checkKeys the field errors of the public keys
+func (cs *ClientStore) checkKeys(jwks json.RawMessage, jwksURI string) []ClientFieldError {
+	var fields []ClientFieldError
+
+	if len(jwks) > 0 {
+		if reason := checkJWKS(jwks, cs.ccfg.MaxJWKSSize); reason != "" {
+			fields = append(fields, ClientFieldError{Field: "jwks", Reason: reason})
+		}
+	}
+
+	if jwksURI != "" {
+		if reason := checkRedirectURI(jwksURI, cs.ccfg.RequireHTTPSURIs); reason != "" {
+			fields = append(fields, ClientFieldError{Field: "jwks_uri", Reason: reason})
+		}
+	}
+
+	if len(jwks) > 0 && jwksURI != "" {
+		fields = append(fields, ClientFieldError{Field: "jwks", Reason: "is given with a jwks_uri"})
+	}
+
+	return fields
+}
+
+// decodeJWKSURI the stored JWKS URI, the clients registered before it was stored with the client keep it in their
+// registration
+func decodeJWKSURI(raw bson.Raw) string {
+	if uri := lookupString(raw, []string{clientJWKSURIField}); uri != "" {
+		return uri
+	}
+
+	if v, ok := lookup(raw, []string{clientRegistrationField}); ok {
+		if doc, ok := v.DocumentOK(); ok {
+			return lookupString(doc, []string{clientJWKSURIField})
+		}
+	}
+
+	return ""
+}
+
+// decodeJWKS the stored JWKS, nil when the client has none
+func decodeJWKS(raw bson.Raw) json.RawMessage {
+	if jwks := lookupString(raw, []string{clientJWKSField}); jwks != "" {
+		return json.RawMessage(jwks)
+	}
+
+	return nil
+}
+
+// SetClientKeys replace the public keys of the client with the JWKS or the JWKS URI(at most one of them), empty ones
+// remove the keys. An invalid or oversized JWKS returns a ClientValidationError and a missing client ErrClientNotFound.
+func (cs *ClientStore) SetClientKeys(ctx context.Context, clientID string, jwks json.RawMessage, jwksURI string) error {
+	o := cs.op("SetClientKeys", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+
+	return cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		if fields := cs.checkKeys(jwks, jwksURI); len(fields) > 0 {
+			return &ClientValidationError{Fields: fields}
+		}
+
+		set := bson.M{clientUpdatedField: time.Now()}
+		unset := bson.M{}
+
+		if len(jwks) > 0 {
+			set[clientJWKSField] = string(jwks)
+		} else {
+			unset[clientJWKSField] = ""
+		}
+
+		if jwksURI != "" {
+			set[clientJWKSURIField] = jwksURI
+		} else {
+			unset[clientJWKSURIField] = ""
+		}
+
+		update := bson.M{"$set": set}
+
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		return cs.auditedHandler(ctx, o, clientID, func(ctx context.Context, c *mongo.Collection) error {
+			res, err := c.UpdateOne(ctx, active(bson.M{"_id": clientID}), update, cs.updateOptions())
+
+			if err == nil && res.MatchedCount == 0 {
+				err = errClientNotFound
+			}
+
+			return err
+		})
+	})
+}
+
+// GetClientKeys the public keys of the client verifying its private_key_jwt assertions: the JWKS or the URI to fetch
+// it from, both empty when it has none. Only those fields are read. A missing client returns ErrClientNotFound.
+func (cs *ClientStore) GetClientKeys(ctx context.Context, clientID string) (jwksJSON []byte, jwksURI string, err error) {
+	o := cs.op("GetClientKeys", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, clientID, bson.M{
+			clientJWKSField:         1,
+			clientJWKSURIField:      1,
+			clientRegistrationField: 1,
+			clientDeletedField:      1,
+			clientStatusField:       1,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		jwksJSON, jwksURI = decodeJWKS(raw), decodeJWKSURI(raw)
+		return nil
+	})
+
+	return
+}
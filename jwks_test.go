@@ -0,0 +1,175 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// testJWKS the public keys of RFC 7517 appendix A.1
+const testJWKS = `{"keys":
+	[
+		{"kty":"EC",
+		 "crv":"P-256",
+		 "x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4",
+		 "y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM",
+		 "use":"enc",
+		 "kid":"1"},
+		{"kty":"RSA",
+		 "n": "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		 "e":"AQAB",
+		 "alg":"RS256",
+		 "kid":"2011-04-29"}
+	]
+}`
+
+func TestClientKeys(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+
+	ccfg := NewDefaultClientConfig()
+	ccfg.AuditCName = "oauth2_client_audit"
+
+	cs := NewClientStore(cfg, WithClientConfig(ccfg))
+	defer cs.Close()
+
+	ctx := context.Background()
+
+	if err := cs.Set(&Client{
+		Client:                  models.Client{ID: "jwt", Domain: "https://example.com"},
+		TokenEndpointAuthMethod: AuthMethodPrivateKeyJWT,
+		JWKS:                    json.RawMessage(testJWKS),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// kept as given
+	info, err := cs.GetByID(ctx, "jwt")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := asClient(info)
+
+	if string(before.GetJWKS()) != testJWKS || before.GetJWKSURI() != "" {
+		t.Fatalf("keys %s %q", before.GetJWKS(), before.GetJWKSURI())
+	}
+
+	// read alone at token time
+	rec.reset()
+
+	jwks, uri, err := cs.GetClientKeys(ctx, "jwt")
+
+	if err != nil || string(jwks) != testJWKS || uri != "" {
+		t.Fatalf("client keys %s %q: %v", jwks, uri, err)
+	}
+
+	finds := rec.named("find")
+
+	if len(finds) != 1 {
+		t.Fatalf("%d finds", len(finds))
+	}
+
+	if _, err := finds[0].Lookup("projection").Document().LookupErr("secret"); err == nil {
+		t.Fatalf("find projecting the secret: %s", finds[0])
+	}
+
+	// replaced by a URI, recorded and dated
+	time.Sleep(10 * time.Millisecond)
+
+	if err := cs.SetClientKeys(ctx, "jwt", nil, "https://example.com/jwks.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if jwks, uri, err = cs.GetClientKeys(ctx, "jwt"); err != nil || jwks != nil || uri != "https://example.com/jwks.json" {
+		t.Fatalf("client keys %s %q: %v", jwks, uri, err)
+	}
+
+	if info, err = cs.GetByID(ctx, "jwt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !asClient(info).UpdatedAt.After(before.UpdatedAt) {
+		t.Fatalf("updated at %v, not after %v", asClient(info).UpdatedAt, before.UpdatedAt)
+	}
+
+	entries, err := cs.AuditForClient(ctx, "jwt", time.Time{})
+
+	if err != nil || len(entries) != 2 || entries[1].Op != "SetClientKeys" {
+		t.Fatalf("audit entries %+v: %v", entries, err)
+	}
+
+	if c := auditChanges(entries[1]); c[clientJWKSField].New != nil || c[clientJWKSURIField].New != "https://example.com/jwks.json" {
+		t.Fatalf("changes %+v", entries[1].Changes)
+	}
+
+	// removed
+	if err := cs.SetClientKeys(ctx, "jwt", nil, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if jwks, uri, err = cs.GetClientKeys(ctx, "jwt"); err != nil || jwks != nil || uri != "" {
+		t.Fatalf("removed client keys %s %q: %v", jwks, uri, err)
+	}
+
+	if err := cs.SetClientKeys(ctx, "missing", json.RawMessage(testJWKS), ""); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v, want ErrClientNotFound", err)
+	}
+
+	if _, _, err := cs.GetClientKeys(ctx, "missing"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v, want ErrClientNotFound", err)
+	}
+}
+
+func TestClientKeysValidation(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.MaxJWKSSize = len(testJWKS)
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		jwks  string
+		uri   string
+		field string
+	}{
+		{"oversized", strings.Replace(testJWKS, `"kid":"1"`, `"kid":"12"`, 1), "", "jwks"},
+		{"not JSON", "keys", "", "jwks"},
+		{"without keys", `{"kty":"RSA"}`, "", "jwks"},
+		{"relative URI", "", "/jwks.json", "jwks_uri"},
+		{"both", testJWKS, "https://example.com/jwks.json", "jwks"},
+	}
+
+	for _, tt := range tests {
+		var ve *ClientValidationError
+
+		err := cs.SetClientKeys(ctx, "c", json.RawMessage(tt.jwks), tt.uri)
+
+		if !errors.As(err, &ve) || ve.Fields[0].Field != tt.field {
+			t.Fatalf("%s: %v, want a %s error", tt.name, err, tt.field)
+		}
+
+		err = cs.Set(&Client{Client: models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}, JWKS: json.RawMessage(tt.jwks), JWKSURI: tt.uri})
+
+		if !errors.As(err, &ve) || ve.Fields[0].Field != tt.field {
+			t.Fatalf("%s: Set %v, want a %s error", tt.name, err, tt.field)
+		}
+	}
+
+	// the largest accepted
+	if err := cs.SetClientKeys(ctx, "c", json.RawMessage(testJWKS), ""); err != nil {
+		t.Fatal(err)
+	}
+}
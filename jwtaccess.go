@@ -0,0 +1,107 @@
+package mongo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// accessClaims the claims of a JWT access token identifying its denylist entry
+type accessClaims struct {
+	JTI string `json:"jti"`
+	Exp int64  `json:"exp"`
+}
+
+// accessStored fail the lookups by access token when the access tokens aren't stored
+func (ts *TokenStore) accessStored() error {
+	if ts.tcfg.SkipAccessTokenStorage {
+		return fmt.Errorf("%w: access tokens aren't stored", ErrUnsupported)
+	}
+
+	return nil
+}
+
+// accessDenial the denylist entry of the JWT access token: its jti, else the hash of the token for the
+// generators which don't set one, until its exp. The signature isn't verified.
+func accessDenial(access string) (DeniedJTI, error) {
+	var claims accessClaims
+
+	parts := strings.Split(access, ".")
+
+	if len(parts) != 3 {
+		return DeniedJTI{}, fmt.Errorf("%w: access token isn't a JWT", ErrInvalidArgument)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+
+	if err == nil {
+		err = json.Unmarshal(payload, &claims)
+	}
+
+	if err != nil {
+		return DeniedJTI{}, fmt.Errorf("%w: access token claims: %v", ErrInvalidArgument, err)
+	}
+
+	if claims.Exp == 0 {
+		return DeniedJTI{}, fmt.Errorf("%w: access token without exp", ErrInvalidArgument)
+	}
+
+	jti := claims.JTI
+
+	if jti == "" {
+		sum := sha256.Sum256([]byte(access))
+		jti = hashedTokenPrefix + hex.EncodeToString(sum[:])
+	}
+
+	return DeniedJTI{JTI: jti, ExpiresAt: time.Unix(claims.Exp, 0)}, nil
+}
+
+// removeUnstoredAccess the RemoveByAccess of an access token which isn't stored: deny it when configured,
+// else nothing
+func (ts *TokenStore) removeUnstoredAccess(ctx context.Context, access string) error {
+	if !ts.tcfg.DenyRemovedAccessTokens {
+		return nil
+	}
+
+	d, err := accessDenial(access)
+
+	if err != nil {
+		return err
+	}
+
+	return ts.colHandler(ctx, ts.tcfg.DenylistCName, func(ctx context.Context, c *mongo.Collection) error {
+		_, err := c.BulkWrite(ctx, []mongo.WriteModel{ts.denyModel(d)})
+		return err
+	})
+}
+
+// IsAccessDenied report whether the JWT access token was removed by RemoveByAccess with
+// TokenConfig.DenyRemovedAccessTokens, by its jti or its hash when it has none
+func (ts *TokenStore) IsAccessDenied(ctx context.Context, access string) (denied bool, err error) {
+	o := ts.op("IsAccessDenied", ts.tcfg.DenylistCName)
+	o.sensitive(access)
+
+	err = ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("access token", access); err != nil {
+			return err
+		}
+
+		d, err := accessDenial(access)
+
+		if err != nil {
+			return err
+		}
+
+		denied, err = ts.denied(ctx, d.JTI)
+		return err
+	})
+
+	return
+}
@@ -0,0 +1,170 @@
+package mongo
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// testJWT a JWT access token with the claims, unsigned as the store doesn't verify the signature
+func testJWT(claims string) string {
+	enc := base64.RawURLEncoding
+
+	return enc.EncodeToString([]byte(`{"alg":"HS512","typ":"JWT"}`)) + "." + enc.EncodeToString([]byte(claims)) + ".c2lnbmF0dXJl"
+}
+
+// the code and refresh token exchanges of the manager with JWT access tokens
+func TestJWTAccessRefreshFlow(t *testing.T) {
+	ts := newTestTokenStore(t, WithoutAccessTokenStorage(true))
+	ctx := context.Background()
+	exp := time.Now().Add(time.Hour).Unix()
+	first, second := testJWT(fmt.Sprintf(`{"jti":"first","exp":%d}`, exp)), testJWT(fmt.Sprintf(`{"jti":"second","exp":%d}`, exp))
+
+	// the code exchange
+	if err := ts.Create(ctx, testCode("code")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByCode(ctx, "code"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.RemoveByCode(ctx, "code"); err != nil {
+		t.Fatal(err)
+	}
+
+	token := testToken(first, "refresh-1")
+	token.AccessExpiresIn = 48 * time.Hour
+
+	if err := ts.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := ts.Collection(CollectionAccess).CountDocuments(ctx, testKindFilter(ts, CollectionAccess, bson.M{})); err != nil || n != 0 {
+		t.Fatalf("%d access documents: %v", n, err)
+	}
+
+	// the family expires with its refresh token, whatever the access token lifetime
+	raw, err := ts.Collection(CollectionBasic).FindOne(ctx, testKindFilter(ts, CollectionBasic, bson.M{})).DecodeBytes()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expiredAt := raw.Lookup("ExpiredAt").Time(); !expiredAt.Equal(token.RefreshCreateAt.Add(token.RefreshExpiresIn).Truncate(time.Millisecond)) {
+		t.Fatalf("basic document expires at %v", expiredAt)
+	}
+
+	for name, err := range map[string]error{
+		"GetByAccess":      func() error { _, err := ts.GetByAccess(ctx, first); return err }(),
+		"BasicIDByAccess":  func() error { _, err := ts.BasicIDByAccess(ctx, first); return err }(),
+		"GetByAccessBound": func() error { _, err := ts.GetByAccessBound(ctx, first, "thumbprint"); return err }(),
+	} {
+		if !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("%s: %v, want ErrUnsupported", name, err)
+		}
+	}
+
+	// the refresh token exchange
+	info, err := ts.GetByRefresh(ctx, "refresh-1")
+
+	if err != nil || info.GetAccess() != first || info.GetUserID() != "u" {
+		t.Fatalf("refresh token %v: %v", info, err)
+	}
+
+	if err := ts.Create(ctx, testToken(second, "refresh-2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.RemoveByAccess(ctx, info.GetAccess()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.RemoveByRefresh(ctx, "refresh-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByRefresh(ctx, "refresh-1"); err == nil {
+		t.Fatal("exchanged refresh token still found")
+	}
+
+	if info, err := ts.GetByRefresh(ctx, "refresh-2"); err != nil || info.GetAccess() != second {
+		t.Fatalf("new refresh token %v: %v", info, err)
+	}
+
+	for access, want := range map[string]bool{first: true, second: false} {
+		if denied, err := ts.IsAccessDenied(ctx, access); err != nil || denied != want {
+			t.Fatalf("access token denied %v(%v), want %v", denied, err, want)
+		}
+	}
+
+	if denied, err := ts.IsDenied(ctx, "first"); err != nil || !denied {
+		t.Fatalf("jti of the removed access token denied %v: %v", denied, err)
+	}
+
+	// a lone access token stores nothing
+	basic := ts.Collection(CollectionBasic)
+	before, err := basic.CountDocuments(ctx, bson.M{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Create(ctx, testToken(testJWT(fmt.Sprintf(`{"jti":"lone","exp":%d}`, exp)), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := basic.CountDocuments(ctx, bson.M{}); err != nil || n != before {
+		t.Fatalf("%d documents after the creation of a lone access token, want %d: %v", n, before, err)
+	}
+}
+
+func TestRemoveUnstoredAccess(t *testing.T) {
+	ctx := context.Background()
+	exp := time.Now().Add(time.Hour).Unix()
+
+	// without the denylist the removal does nothing
+	ts := newTestTokenStore(t, WithoutAccessTokenStorage(false))
+	access := testJWT(fmt.Sprintf(`{"jti":"j","exp":%d}`, exp))
+
+	for _, token := range []string{access, "opaque"} {
+		if err := ts.RemoveByAccess(ctx, token); err != nil {
+			t.Fatalf("removal of %s: %v", token, err)
+		}
+	}
+
+	if denied, err := ts.IsAccessDenied(ctx, access); err != nil || denied {
+		t.Fatalf("access token denied %v: %v", denied, err)
+	}
+
+	// the JWT without jti is denied by its hash
+	ts = newTestTokenStore(t, WithoutAccessTokenStorage(true))
+	anonymous := testJWT(fmt.Sprintf(`{"sub":"u","exp":%d}`, exp))
+
+	if err := ts.RemoveByAccess(ctx, anonymous); err != nil {
+		t.Fatal(err)
+	}
+
+	if denied, err := ts.IsAccessDenied(ctx, anonymous); err != nil || !denied {
+		t.Fatalf("access token without jti denied %v: %v", denied, err)
+	}
+
+	if denied, err := ts.IsAccessDenied(ctx, testJWT(fmt.Sprintf(`{"sub":"v","exp":%d}`, exp))); err != nil || denied {
+		t.Fatalf("other access token without jti denied %v: %v", denied, err)
+	}
+
+	for name, token := range map[string]string{
+		"opaque token":  "opaque",
+		"without exp":   testJWT(`{"jti":"j"}`),
+		"broken claims": "a.b.c",
+	} {
+		if err := ts.RemoveByAccess(ctx, token); !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("%s: %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
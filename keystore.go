@@ -0,0 +1,361 @@
+package mongo
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fields of the signing key documents, keyed by key ID
+const (
+	keyAlgField       = "alg"
+	keyPEMField       = "key"
+	keyEncKeyIDField  = "key_id"
+	keyCreatedField   = "created_at"
+	keyNotAfterField  = "not_after"
+	keyRetiredAtField = "retired_at"
+)
+
+// Key a signing key of the authorization server
+type Key struct {
+	// kid of the tokens signed with the key
+	ID string
+	// JWS algorithm of the key, e.g. RS256
+	Algorithm string
+	// private key, PEM encoded(PKCS #8, PKCS #1 or SEC 1)
+	PEM       []byte
+	CreatedAt time.Time
+	// end of the signing period, zero when the key signs until retired. The key still verifies after it.
+	NotAfter time.Time
+	// time RetireKey was called, zero while the key may sign
+	RetiredAt time.Time
+}
+
+// Signer parse the private key
+func (k *Key) Signer() (crypto.Signer, error) {
+	return parseSigningKey(k.PEM)
+}
+
+// PublicKey the public key verifying the tokens signed with the key
+func (k *Key) PublicKey() (crypto.PublicKey, error) {
+	signer, err := k.Signer()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return signer.Public(), nil
+}
+
+// parseSigningKey parse the first PEM block as a RSA, ECDSA or Ed25519 private key
+func parseSigningKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unsupported private key in %q block", block.Type)
+}
+
+// KeyStore the signing keys of the authorization server with their rotation history, the retired keys keep
+// verifying the tokens they signed. It shares the connection, configuration and hooks of the token store it is
+// created from, whose Encrypter encrypts the private keys of its collection(TokenConfig.KeysCName).
+type KeyStore struct {
+	ts *TokenStore
+}
+
+// NewKeyStore create the signing key store of ts
+func NewKeyStore(ts *TokenStore) *KeyStore {
+	return &KeyStore{ts: ts}
+}
+
+func (s *KeyStore) op(name string) *operation {
+	return newOperation("key", name, s.ts.tcfg.KeysCName)
+}
+
+// SaveKey store the private key kid, signing with alg until notAfter(zero for until retired). The newest key
+// becomes the active key, an existing kid returns a DuplicateKeyError.
+func (s *KeyStore) SaveKey(ctx context.Context, kid string, keyPEM []byte, alg string, notAfter time.Time) error {
+	o := s.op("SaveKey")
+	o.set("kid", kid)
+
+	return s.ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("key ID", kid); err != nil {
+			return err
+		}
+
+		if err := requireArg("algorithm", alg); err != nil {
+			return err
+		}
+
+		if _, err := parseSigningKey(keyPEM); err != nil {
+			return fmt.Errorf("%w: signing key: %v", ErrInvalidArgument, err)
+		}
+
+		doc := bson.D{
+			{Key: "_id", Value: kid},
+			{Key: keyAlgField, Value: alg},
+			{Key: keyCreatedField, Value: time.Now()},
+		}
+
+		if !notAfter.IsZero() {
+			doc = append(doc, bson.E{Key: keyNotAfterField, Value: notAfter})
+		}
+
+		data := keyPEM
+
+		if e := s.ts.tcfg.Encrypter; e != nil {
+			keyID, ciphertext, err := e.Encrypt(keyPEM)
+
+			if err != nil {
+				return err
+			}
+
+			doc = append(doc, bson.E{Key: keyEncKeyIDField, Value: keyID})
+			data = ciphertext
+		}
+
+		doc = append(doc, bson.E{Key: keyPEMField, Value: data})
+
+		return s.ts.colHandler(ctx, s.ts.tcfg.KeysCName, func(ctx context.Context, c *mongo.Collection) error {
+			_, err := c.InsertOne(ctx, doc)
+
+			if mongo.IsDuplicateKeyError(err) {
+				return &DuplicateKeyError{Field: "_id", Index: "_id_", Err: err}
+			}
+
+			return err
+		})
+	})
+}
+
+// decodeKey the key of a stored document, decrypting the private key
+func (s *KeyStore) decodeKey(raw bson.Raw) (Key, error) {
+	k := Key{
+		ID:        lookupString(raw, []string{"_id"}),
+		Algorithm: lookupString(raw, []string{keyAlgField}),
+		PEM:       lookupBinary(raw, []string{keyPEMField}),
+		CreatedAt: lookupTime(raw, []string{keyCreatedField}),
+		NotAfter:  lookupTime(raw, []string{keyNotAfterField}),
+		RetiredAt: lookupTime(raw, []string{keyRetiredAtField}),
+	}
+
+	keyID := lookupString(raw, []string{keyEncKeyIDField})
+
+	if keyID == "" {
+		return k, nil
+	}
+
+	if s.ts.tcfg.Encrypter == nil {
+		return Key{}, &DecryptError{KeyID: keyID, Err: errors.New("no encrypter configured")}
+	}
+
+	data, err := s.ts.tcfg.Encrypter.Decrypt(keyID, k.PEM)
+
+	if err != nil {
+		return Key{}, err
+	}
+
+	k.PEM = data
+
+	return k, nil
+}
+
+// newestFirst the rotation order of the keys, ties are broken by ID
+var newestFirst = bson.D{{Key: keyCreatedField, Value: -1}, {Key: "_id", Value: -1}}
+
+// ActiveKey the newest key neither retired nor past its signing period, ErrNoActiveKey when there is none
+func (s *KeyStore) ActiveKey(ctx context.Context) (key Key, err error) {
+	o := s.op("ActiveKey")
+
+	err = s.ts.run(ctx, o, func(ctx context.Context) error {
+		// a key retired on the primary must not sign from a lagging secondary
+		return s.ts.readHandler(ctx, s.ts.tcfg.KeysCName, true, func(ctx context.Context, c *mongo.Collection) error {
+			filter := notExpired(keyNotAfterField, bson.M{keyRetiredAtField: bson.M{"$exists": false}})
+			raw, err := c.FindOne(ctx, filter, options.FindOne().SetSort(newestFirst)).DecodeBytes()
+
+			if err == mongo.ErrNoDocuments {
+				return errNoActiveKey
+			}
+
+			if err != nil {
+				return err
+			}
+
+			key, err = s.decodeKey(raw)
+			o.set("kid", key.ID)
+
+			return err
+		})
+	})
+
+	return
+}
+
+// AllVerificationKeys every stored key newest first, the retired ones and those past their signing period
+// included so the tokens they signed remain verifiable
+func (s *KeyStore) AllVerificationKeys(ctx context.Context) (keys []Key, err error) {
+	o := s.op("AllVerificationKeys")
+
+	err = s.ts.run(ctx, o, func(ctx context.Context) error {
+		keys = []Key{}
+
+		return s.ts.readHandler(ctx, s.ts.tcfg.KeysCName, true, func(ctx context.Context, c *mongo.Collection) error {
+			cur, err := c.Find(ctx, bson.M{}, options.Find().SetSort(newestFirst))
+
+			if err != nil {
+				return err
+			}
+
+			defer cur.Close(ctx)
+
+			for cur.Next(ctx) {
+				key, err := s.decodeKey(cur.Current)
+
+				if err != nil {
+					return err
+				}
+
+				keys = append(keys, key)
+			}
+
+			o.set("documents", len(keys))
+
+			return cur.Err()
+		})
+	})
+
+	return
+}
+
+// RetireKey stop signing with the key kid, it remains a verification key. Retiring a retired key keeps its
+// retirement time, an unknown kid returns ErrKeyNotFound.
+func (s *KeyStore) RetireKey(ctx context.Context, kid string) error {
+	o := s.op("RetireKey")
+	o.set("kid", kid)
+
+	return s.ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("key ID", kid); err != nil {
+			return err
+		}
+
+		return s.ts.colHandler(ctx, s.ts.tcfg.KeysCName, func(ctx context.Context, c *mongo.Collection) error {
+			res, err := c.UpdateOne(ctx, bson.M{"_id": kid}, bson.M{"$min": bson.M{keyRetiredAtField: time.Now()}})
+
+			if err != nil {
+				return err
+			}
+
+			if res.MatchedCount == 0 {
+				return errKeyNotFound
+			}
+
+			return nil
+		})
+	})
+}
+
+// jwk the public JSON Web Key of a signing key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// b64 the unpadded base64url encoding of JWK members
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// padded the big-endian bytes of n left-padded to size, the fixed length of the EC coordinates
+func padded(n *big.Int, size int) []byte {
+	data := n.Bytes()
+
+	if len(data) >= size {
+		return data
+	}
+
+	return append(make([]byte, size-len(data)), data...)
+}
+
+// publicJWK the JWK of the public key of k
+func publicJWK(k Key) (jwk, error) {
+	pub, err := k.PublicKey()
+
+	if err != nil {
+		return jwk{}, fmt.Errorf("key %q: %w", k.ID, err)
+	}
+
+	j := jwk{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		j.Kty, j.N, j.E = "RSA", b64(pub.N.Bytes()), b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		j.Kty, j.Crv, j.X, j.Y = "EC", pub.Curve.Params().Name, b64(padded(pub.X, size)), b64(padded(pub.Y, size))
+	case ed25519.PublicKey:
+		j.Kty, j.Crv, j.X = "OKP", "Ed25519", b64(pub)
+	default:
+		return jwk{}, fmt.Errorf("key %q: unsupported public key %T", k.ID, pub)
+	}
+
+	return j, nil
+}
+
+// MarshalJWKS the JWKS document of the public keys, e.g. of AllVerificationKeys for the jwks_uri of the
+// discovery document. The private keys never leave it.
+func MarshalJWKS(keys []Key) ([]byte, error) {
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{Keys: make([]jwk, 0, len(keys))}
+
+	for _, k := range keys {
+		j, err := publicJWK(k)
+
+		if err != nil {
+			return nil, err
+		}
+
+		set.Keys = append(set.Keys, j)
+	}
+
+	return json.Marshal(set)
+}
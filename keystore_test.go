@@ -0,0 +1,259 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// testKeyPEM a new PKCS #8 PEM private key, "RSA", "EC" or "OKP"
+func testKeyPEM(t *testing.T, kty string) []byte {
+	t.Helper()
+
+	var (
+		key crypto.Signer
+		err error
+	)
+
+	switch kty {
+	case "RSA":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "EC":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// keyIDs the IDs of the keys
+func keyIDs(keys []Key) []string {
+	ids := []string{}
+
+	for _, k := range keys {
+		ids = append(ids, k.ID)
+	}
+
+	return ids
+}
+
+func TestKeyRotation(t *testing.T) {
+	ts := newTestTokenStore(t, WithEncrypter(testEncrypter(t, "k1")))
+	s := NewKeyStore(ts)
+	ctx := context.Background()
+
+	if _, err := s.ActiveKey(ctx); !errors.Is(err, ErrNoActiveKey) {
+		t.Fatalf("empty store: %v, want ErrNoActiveKey", err)
+	}
+
+	pems := map[string][]byte{"2024": testKeyPEM(t, "RSA"), "2025": testKeyPEM(t, "EC"), "2026": testKeyPEM(t, "OKP")}
+	algs := map[string]string{"2024": "RS256", "2025": "ES256", "2026": "EdDSA"}
+
+	// the newest key signs
+	for _, kid := range []string{"2024", "2025", "2026"} {
+		time.Sleep(10 * time.Millisecond)
+
+		if err := s.SaveKey(ctx, kid, pems[kid], algs[kid], time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := s.ActiveKey(ctx)
+
+		if err != nil || key.ID != kid || key.Algorithm != algs[kid] || !bytes.Equal(key.PEM, pems[kid]) {
+			t.Fatalf("active key %s(%v), want %s", key.ID, err, kid)
+		}
+	}
+
+	// encrypted at rest
+	raw, err := ts.Database().Collection(ts.tcfg.KeysCName).FindOne(ctx, bson.M{"_id": "2026"}).DecodeBytes()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lookupString(raw, []string{keyEncKeyIDField}) != "k1" || bytes.Contains(lookupBinary(raw, []string{keyPEMField}), []byte("PRIVATE KEY")) {
+		t.Fatalf("stored key %s", raw)
+	}
+
+	var de *DuplicateKeyError
+
+	if err := s.SaveKey(ctx, "2026", pems["2026"], "EdDSA", time.Time{}); !errors.As(err, &de) {
+		t.Fatalf("kid saved again: %v, want a DuplicateKeyError", err)
+	}
+
+	// the retired keys stop signing and keep verifying
+	if err := s.RetireKey(ctx, "2026"); err != nil {
+		t.Fatal(err)
+	}
+
+	if key, err := s.ActiveKey(ctx); err != nil || key.ID != "2025" {
+		t.Fatalf("active key %s(%v) after the retirement, want 2025", key.ID, err)
+	}
+
+	keys, err := s.AllVerificationKeys(ctx)
+
+	if err != nil || !reflect.DeepEqual(keyIDs(keys), []string{"2026", "2025", "2024"}) {
+		t.Fatalf("verification keys %v: %v", keyIDs(keys), err)
+	}
+
+	if keys[0].RetiredAt.IsZero() || !keys[1].RetiredAt.IsZero() {
+		t.Fatalf("retired at %v and %v", keys[0].RetiredAt, keys[1].RetiredAt)
+	}
+
+	// retiring again keeps the retirement time
+	time.Sleep(10 * time.Millisecond)
+
+	if err := s.RetireKey(ctx, "2026"); err != nil {
+		t.Fatal(err)
+	}
+
+	if again, err := s.AllVerificationKeys(ctx); err != nil || !again[0].RetiredAt.Equal(keys[0].RetiredAt) {
+		t.Fatalf("retired at %v after a second RetireKey, want %v: %v", again[0].RetiredAt, keys[0].RetiredAt, err)
+	}
+
+	// a key past its signing period is skipped as well
+	if err := s.SaveKey(ctx, "expired", testKeyPEM(t, "EC"), "ES256", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if key, err := s.ActiveKey(ctx); err != nil || key.ID != "2025" {
+		t.Fatalf("active key %s(%v) with an expired newer key, want 2025", key.ID, err)
+	}
+
+	for _, kid := range []string{"2025", "2024"} {
+		if err := s.RetireKey(ctx, kid); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s.ActiveKey(ctx); !errors.Is(err, ErrNoActiveKey) {
+		t.Fatalf("every key retired: %v, want ErrNoActiveKey", err)
+	}
+
+	if keys, err := s.AllVerificationKeys(ctx); err != nil || len(keys) != 4 {
+		t.Fatalf("verification keys %v: %v", keyIDs(keys), err)
+	}
+
+	if err := s.RetireKey(ctx, "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("missing key: %v, want ErrKeyNotFound", err)
+	}
+
+	// the keys aren't readable without the encrypter
+	if _, err := NewKeyStore(testTokenStoreWithDB(ts.Database())).AllVerificationKeys(ctx); err == nil {
+		t.Fatal("encrypted keys read without encrypter")
+	}
+}
+
+func TestKeyValidation(t *testing.T) {
+	s := NewKeyStore(newTestTokenStore(t))
+	ctx := context.Background()
+
+	for name, err := range map[string]error{
+		"without kid":        s.SaveKey(ctx, "", testKeyPEM(t, "EC"), "ES256", time.Time{}),
+		"without algorithm":  s.SaveKey(ctx, "k", testKeyPEM(t, "EC"), "", time.Time{}),
+		"not PEM":            s.SaveKey(ctx, "k", []byte("key"), "ES256", time.Time{}),
+		"public key":         s.SaveKey(ctx, "k", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte{0}}), "ES256", time.Time{}),
+		"retire without kid": s.RetireKey(ctx, ""),
+	} {
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("%s: %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
+
+func TestMarshalJWKS(t *testing.T) {
+	s := NewKeyStore(newTestTokenStore(t))
+	ctx := context.Background()
+
+	for _, k := range []struct{ kid, kty, alg string }{{"rsa", "RSA", "RS256"}, {"ec", "EC", "ES256"}, {"okp", "OKP", "EdDSA"}} {
+		time.Sleep(10 * time.Millisecond)
+
+		if err := s.SaveKey(ctx, k.kid, testKeyPEM(t, k.kty), k.alg, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.RetireKey(ctx, "rsa"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := s.AllVerificationKeys(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := MarshalJWKS(keys)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(set.Keys) != 3 {
+		t.Fatalf("JWKS %s", data)
+	}
+
+	members := map[string][]string{"RSA": {"n", "e"}, "EC": {"crv", "x", "y"}, "OKP": {"crv", "x"}}
+
+	for i, j := range set.Keys {
+		if j["kid"] != keys[i].ID || j["alg"] != keys[i].Algorithm || j["use"] != "sig" {
+			t.Fatalf("JWK %v of the key %s", j, keys[i].ID)
+		}
+
+		for _, m := range members[j["kty"]] {
+			if j[m] == "" {
+				t.Fatalf("JWK %v without %s", j, m)
+			}
+		}
+
+		// no private member
+		if _, ok := j["d"]; ok {
+			t.Fatalf("JWK %v with the private key", j)
+		}
+	}
+
+	if ec := set.Keys[1]; ec["crv"] != "P-256" || len(ec["x"]) != 43 || len(ec["y"]) != 43 {
+		t.Fatalf("EC JWK %v", ec)
+	}
+
+	if data, err := MarshalJWKS(nil); err != nil || string(data) != `{"keys":[]}` {
+		t.Fatalf("empty JWKS %s: %v", data, err)
+	}
+
+	if _, err := MarshalJWKS([]Key{{ID: "bad", PEM: []byte("key")}}); err == nil {
+		t.Fatal("JWKS of an invalid key")
+	}
+}
@@ -0,0 +1,117 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// tokenKindField kind of the documents of the single collection layout(basic, code, access or refresh)
+const tokenKindField = "kind"
+
+// document kinds of the single collection layout, the code, access and refresh keys are prefixed with their kind
+const (
+	kindBasic   = "basic"
+	kindCode    = "code"
+	kindAccess  = "access"
+	kindRefresh = "refresh"
+)
+
+// cname the collection storing the documents of the configured collection name: the token collection names are
+// kept as the logical kinds of the documents and all map to TokensCName in the single collection layout
+func (ts *TokenStore) cname(name string) string {
+	if ts.tcfg.SingleCollection && (name == ts.tcfg.BasicCName || name == ts.tcfg.AccessCName || name == ts.tcfg.RefreshCName) {
+		return ts.tcfg.TokensCName
+	}
+
+	return name
+}
+
+// cnames the distinct collections storing the documents of the collection names
+func (ts *TokenStore) cnames(names ...string) []string {
+	var distinct []string
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		if name = ts.cname(name); !seen[name] {
+			seen[name] = true
+			distinct = append(distinct, name)
+		}
+	}
+
+	return distinct
+}
+
+// kindPrefix the prefix of the keys of the access/refresh documents of the collection name in the single
+// collection layout, empty otherwise
+func (ts *TokenStore) kindPrefix(name string) string {
+	if !ts.tcfg.SingleCollection {
+		return ""
+	}
+
+	switch name {
+	case ts.tcfg.AccessCName:
+		return kindAccess + ":"
+	case ts.tcfg.RefreshCName:
+		return kindRefresh + ":"
+	}
+
+	return kindCode + ":"
+}
+
+// withKind tag the document with its kind in the single collection layout
+func (ts *TokenStore) withKind(doc bson.D, kind string) bson.D {
+	if !ts.tcfg.SingleCollection {
+		return doc
+	}
+
+	return append(doc, bson.E{Key: tokenKindField, Value: kind})
+}
+
+// singleIndexes the indexes of the single collection besides those of the basic collection: the expiry per kind
+// and the reference of the access/refresh documents to their basic document
+func (ts *TokenStore) singleIndexes() []mongo.IndexModel {
+	fn := ts.fields()
+
+	return []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: tokenKindField, Value: 1}, {Key: fn.ExpiredAt, Value: 1}},
+			Options: options.Index().SetName(tokenKindField + "_1_" + fn.ExpiredAt + "_1"),
+		},
+		sparseIndex(fn.BasicID),
+	}
+}
+
+// insertFamily insert the documents of a token family in a single InsertMany, ordered by the logical names
+func (ts *TokenStore) insertFamily(ctx context.Context, d routedDB, names []string, payloads map[string]bson.D) error {
+	docs := make([]interface{}, 0, len(names))
+
+	for _, name := range names {
+		docs = append(docs, payloads[name])
+	}
+
+	_, err := d.Collection(ts.tcfg.TokensCName).InsertMany(ctx, docs)
+
+	if err == nil || !ts.transactionsDisabled() {
+		return err
+	}
+
+	// the ordered insert stopped at the first failure, the documents before it were inserted
+	failed := 0
+
+	if bwe, ok := err.(mongo.BulkWriteException); ok && len(bwe.WriteErrors) > 0 {
+		failed = bwe.WriteErrors[0].Index
+	}
+
+	inserted := make(map[string]bson.D)
+
+	for _, name := range names[:failed] {
+		inserted[name] = payloads[name]
+	}
+
+	ts.compensate(ctx, d, inserted)
+
+	return err
+}
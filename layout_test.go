@@ -0,0 +1,123 @@
+package mongo
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSingleCollection(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+
+	ts := NewTokenStore(cfg, WithSingleCollection())
+	defer ts.Close()
+
+	ctx := context.Background()
+	token := testToken("access", "refresh")
+	rec.reset()
+
+	if err := ts.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Create(ctx, testCode("code")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a single insert of the token family and one of the code
+	inserts := rec.named("insert")
+
+	if len(inserts) != 2 {
+		t.Fatalf("%d inserts, want 2", len(inserts))
+	}
+
+	for _, cmd := range inserts {
+		if name := cmd.Lookup("insert").StringValue(); name != "oauth2_tokens" {
+			t.Fatalf("insert into %s", name)
+		}
+	}
+
+	info, err := ts.GetByAccess(ctx, "access")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireSameToken(t, info, token)
+
+	if _, err := ts.GetByRefresh(ctx, "refresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByCode(ctx, "code"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the keys are prefixed with the kind recorded in the documents
+	cur, err := ts.Collection(CollectionBasic).Find(ctx, bson.M{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := make(map[string]string)
+
+	for cur.Next(ctx) {
+		id, kind := lookupString(cur.Current, []string{"_id"}), lookupString(cur.Current, []string{tokenKindField})
+
+		if kind == kindBasic {
+			id = kindBasic
+		}
+
+		kinds[id] = kind
+	}
+
+	if want := map[string]string{
+		"basic":           kindBasic,
+		"access:access":   kindAccess,
+		"refresh:refresh": kindRefresh,
+		"code:code":       kindCode,
+	}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("documents %v, want %v", kinds, want)
+	}
+
+	names, err := ts.Database().ListCollectionNames(ctx, bson.M{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		if name == "oauth2_basic" || name == "oauth2_access" || name == "oauth2_refresh" {
+			t.Fatalf("collections %s", strings.Join(names, " "))
+		}
+	}
+
+	if names := tokenIndexNames(t, ts, CollectionBasic); !hasIndex(names, "kind_1_ExpiredAt_1") || !hasIndex(names, "BasicID_1") {
+		t.Fatalf("indexes %s", strings.Join(names, " "))
+	}
+
+	// the documents of the other kinds stay
+	if err := ts.RemoveByAccess(ctx, "access"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := ts.Collection(CollectionBasic).CountDocuments(ctx, bson.M{}); err != nil || n != 3 {
+		t.Fatalf("%d documents after the removal of the access token: %v", n, err)
+	}
+
+	if _, err := ts.GetByRefresh(ctx, "refresh"); err != nil {
+		t.Fatalf("refresh token of the removed access token: %v", err)
+	}
+
+	// the layouts don't read each other's documents
+	if _, err := NewTokenStoreWithDB(ts.Database()).GetByRefresh(ctx, "refresh"); err == nil {
+		t.Fatal("refresh token of the single collection read by the separate collection layout")
+	}
+}
@@ -0,0 +1,287 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fields of the basic documents referencing the basic documents of the RFC 8693 subject and actor tokens
+// they were exchanged from
+const (
+	tokenSubjectRefField = "subject_ref"
+	tokenActorRefField   = "actor_ref"
+)
+
+// maxLineageDepth the longest delegation chain Lineage walks, guarding against reference cycles
+const maxLineageDepth = 64
+
+// tokenLineage the basic IDs of the tokens a token family was exchanged from
+type tokenLineage struct {
+	Subject string
+	Actor   string
+}
+
+// TokenRecord the stored attributes of a token family, read without its payload
+type TokenRecord struct {
+	BasicID string
+	Issuer  string
+	// user id of the tokens, its pseudonym with TokenConfig.UserIDKey
+	UserID    string
+	SessionID string
+	// resource servers the tokens were issued for, empty for any
+	Audience []string
+	// basic IDs of the token families of the subject and actor tokens it was exchanged from, empty for
+	// tokens not issued by a token exchange
+	SubjectTokenRef string
+	ActorTokenRef   string
+	// base64url SHA-256 of the PKCE code challenge of an authorization code and its method(S256 or plain),
+	// empty for codes without challenge and tokens
+	CodeChallenge       string
+	CodeChallengeMethod string
+	ExpiredAt           time.Time
+}
+
+// tokenRecord the record of a basic document
+func (ts *TokenStore) tokenRecord(raw bson.Raw) TokenRecord {
+	bd := decodeBasicData(raw, ts.fields())
+
+	return TokenRecord{
+		BasicID:             bd.ID,
+		Issuer:              bd.Issuer,
+		UserID:              bd.UserID,
+		SessionID:           bd.SessionID,
+		Audience:            bd.Audience,
+		SubjectTokenRef:     bd.Lineage.Subject,
+		ActorTokenRef:       bd.Lineage.Actor,
+		CodeChallenge:       bd.Code.Challenge,
+		CodeChallengeMethod: bd.Code.Method,
+		ExpiredAt:           bd.ExpiredAt,
+	}
+}
+
+// payloadProjection leave the token payloads out of the returned basic documents
+func (ts *TokenStore) payloadProjection() bson.M {
+	projection := bson.M{}
+
+	for _, name := range aliases(ts.fields().Data, func(f FieldNames) string { return f.Data }) {
+		projection[name] = 0
+	}
+
+	return projection
+}
+
+// basicIDOf the basic ID of the access or refresh token
+func (ts *TokenStore) basicIDOf(ctx context.Context, token string) (string, error) {
+	td, err := ts.getTokenData(ctx, ts.tcfg.AccessCName, token, true)
+
+	if err == mongo.ErrNoDocuments {
+		td, err = ts.getTokenData(ctx, ts.tcfg.RefreshCName, token, true)
+	}
+
+	return td.BasicID, err
+}
+
+// BasicIDByAccess the basic ID of the token family of the access token, e.g. for Lineage
+func (ts *TokenStore) BasicIDByAccess(ctx context.Context, access string) (id string, err error) {
+	o := ts.op("BasicIDByAccess", ts.tcfg.AccessCName)
+	o.sensitive(access)
+
+	err = ts.run(ctx, o, func(ctx context.Context) (err error) {
+		if err := requireArg("access token", access); err != nil {
+			return err
+		}
+
+		if err := ts.accessStored(); err != nil {
+			return err
+		}
+
+		td, err := ts.getTokenData(ctx, ts.tcfg.AccessCName, access, false)
+		id = td.BasicID
+
+		return err
+	})
+
+	return
+}
+
+// CreateExchanged create and store the token information issued by a RFC 8693 token exchange, recording the
+// token families of the subject token and the actor token(empty for none), both access or refresh tokens of the
+// store. See Lineage and TokenConfig.CascadeExchanges.
+func (ts *TokenStore) CreateExchanged(ctx context.Context, info oauth2.TokenInfo, subjectToken, actorToken string) error {
+	o := ts.op("CreateExchanged", ts.tcfg.BasicCName)
+
+	return ts.run(ctx, o, func(ctx context.Context) error {
+		if err := validateTokenInfo(info); err != nil {
+			return err
+		}
+
+		if err := requireArg("subject token", subjectToken); err != nil {
+			return err
+		}
+
+		if info.GetCode() != "" {
+			return fmt.Errorf("%w: authorization code issued by a token exchange", ErrInvalidArgument)
+		}
+
+		o.sensitive(info.GetAccess(), info.GetRefresh(), subjectToken, actorToken)
+
+		var lineage tokenLineage
+		var err error
+
+		if lineage.Subject, err = ts.basicIDOf(ctx, subjectToken); err != nil {
+			return fmt.Errorf("subject token: %w", err)
+		}
+
+		if actorToken != "" {
+			if lineage.Actor, err = ts.basicIDOf(ctx, actorToken); err != nil {
+				return fmt.Errorf("actor token: %w", err)
+			}
+		}
+
+		o.set("subject_ref", lineage.Subject)
+
+		return ts.create(ctx, o, info, Confirmation{}, lineage)
+	})
+}
+
+// Lineage the delegation chain of the token family basicID: its record first, then those of the subject tokens
+// it was exchanged from up to the original token. The chain stops at the first family already removed or expired.
+func (ts *TokenStore) Lineage(ctx context.Context, basicID string) (chain []TokenRecord, err error) {
+	o := ts.op("Lineage", ts.tcfg.BasicCName)
+	o.set("basic_id", basicID)
+
+	err = ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("basic ID", basicID); err != nil {
+			return err
+		}
+
+		chain = []TokenRecord{}
+		projection := options.FindOne().SetProjection(ts.payloadProjection())
+
+		return ts.readHandler(ctx, ts.tcfg.BasicCName, false, func(ctx context.Context, c *mongo.Collection) error {
+			seen := make(map[string]bool)
+
+			for id := basicID; id != "" && !seen[id] && len(chain) < maxLineageDepth; {
+				seen[id] = true
+				raw, err := c.FindOne(ctx, ts.withIssuer(ctx, bson.M{"_id": id}), projection).DecodeBytes()
+
+				if err == mongo.ErrNoDocuments && len(chain) > 0 {
+					break
+				}
+
+				if err != nil {
+					return err
+				}
+
+				record := ts.tokenRecord(raw)
+				chain = append(chain, record)
+				id = record.SubjectTokenRef
+			}
+
+			o.set("documents", len(chain))
+
+			return nil
+		})
+	})
+
+	return
+}
+
+// cascadeRoots the basic IDs of the token documents matching the token, whose exchanged families are removed
+// with it when CascadeExchanges is enabled
+func (ts *TokenStore) cascadeRoots(ctx context.Context, c *mongo.Collection, cname, token string) ([]string, error) {
+	if !ts.tcfg.CascadeExchanges {
+		return nil, nil
+	}
+
+	cur, err := c.Find(ctx, ts.tokenFilter(ctx, cname, token))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer cur.Close(ctx)
+
+	var ids []string
+
+	for cur.Next(ctx) {
+		ids = append(ids, decodeTokenData(cur.Current, ts.fields()).BasicID)
+	}
+
+	return ids, cur.Err()
+}
+
+// removeDescendants delete the token families exchanged from the roots, directly or through other exchanges
+func (ts *TokenStore) removeDescendants(ctx context.Context, o *operation, d routedDB, roots []string) error {
+	fn := ts.fields()
+	seen := make(map[string]bool)
+	var removed int64
+
+	for _, id := range roots {
+		seen[id] = true
+	}
+
+	for frontier := roots; len(frontier) > 0; {
+		filter := ts.withKeyPrefix(ts.withIssuer(ctx, bson.M{"$or": bson.A{
+			bson.M{tokenSubjectRefField: bson.M{"$in": frontier}},
+			bson.M{tokenActorRefField: bson.M{"$in": frontier}},
+		}}))
+		cur, err := d.Collection(ts.cname(ts.tcfg.BasicCName)).Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+
+		if err != nil {
+			return err
+		}
+
+		var next []string
+
+		for cur.Next(ctx) {
+			if id := lookupString(cur.Current, []string{"_id"}); !seen[id] {
+				seen[id] = true
+				next = append(next, id)
+			}
+		}
+
+		err = cur.Err()
+		cur.Close(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		if len(next) == 0 {
+			break
+		}
+
+		res, err := d.Collection(ts.cname(ts.tcfg.BasicCName)).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": next}})
+
+		if err != nil {
+			return err
+		}
+
+		removed += res.DeletedCount
+
+		for _, name := range ts.cnames(ts.tcfg.AccessCName, ts.tcfg.RefreshCName) {
+			res, err := d.Collection(name).DeleteMany(ctx, anyOf(aliases(fn.BasicID, func(f FieldNames) string { return f.BasicID }), bson.M{"$in": next}))
+
+			if err != nil {
+				return err
+			}
+
+			removed += res.DeletedCount
+		}
+
+		frontier = next
+	}
+
+	if removed > 0 {
+		o.set("cascaded", removed)
+	}
+
+	return nil
+}
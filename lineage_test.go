@@ -0,0 +1,198 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// delegationChain a user token exchanged by three services in turn, the second acting with a token of its own:
+// user-access → a-access → b-access/b-refresh(actor actor-access) → c-access. It returns the basic IDs by token.
+func delegationChain(t *testing.T, ts *TokenStore) map[string]string {
+	t.Helper()
+
+	ctx := context.Background()
+
+	for _, info := range []struct{ access, refresh string }{{"user-access", "user-refresh"}, {"actor-access", ""}, {"other-access", ""}} {
+		if err := ts.Create(ctx, testToken(info.access, info.refresh)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hops := []struct{ access, refresh, subject, actor string }{
+		{"a-access", "", "user-access", ""},
+		{"b-access", "b-refresh", "a-access", "actor-access"},
+		// the refresh token of a hop is a subject token as well
+		{"c-access", "", "b-refresh", ""},
+	}
+
+	for _, hop := range hops {
+		if err := ts.CreateExchanged(ctx, testToken(hop.access, hop.refresh), hop.subject, hop.actor); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := make(map[string]string)
+
+	for _, access := range []string{"user-access", "actor-access", "other-access", "a-access", "b-access", "c-access"} {
+		id, err := ts.BasicIDByAccess(ctx, access)
+
+		if err != nil || id == "" {
+			t.Fatalf("basic ID of %s %q: %v", access, id, err)
+		}
+
+		ids[access] = id
+	}
+
+	return ids
+}
+
+// requireAccess fail unless the access tokens exist as wanted
+func requireAccess(t *testing.T, ts *TokenStore, want map[string]bool) {
+	t.Helper()
+
+	for access, exists := range want {
+		info, err := ts.GetByAccess(context.Background(), access)
+
+		if got := err == nil && info != nil; got != exists {
+			t.Fatalf("%s exists %v(%v), want %v", access, got, err, exists)
+		}
+	}
+}
+
+func TestLineage(t *testing.T) {
+	ts := newTestTokenStore(t)
+	ctx := context.Background()
+	ids := delegationChain(t, ts)
+
+	chain, err := ts.Lineage(ctx, ids["c-access"])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+
+	for _, record := range chain {
+		got = append(got, record.BasicID)
+	}
+
+	if want := []string{ids["c-access"], ids["b-access"], ids["a-access"], ids["user-access"]}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("lineage %v, want %v", got, want)
+	}
+
+	if b := chain[1]; b.SubjectTokenRef != ids["a-access"] || b.ActorTokenRef != ids["actor-access"] || b.UserID != "u" {
+		t.Fatalf("record %+v", b)
+	}
+
+	if user := chain[3]; user.SubjectTokenRef != "" || user.ActorTokenRef != "" || user.ExpiredAt.IsZero() {
+		t.Fatalf("record of the original token %+v", user)
+	}
+
+	// a token not issued by an exchange is its own lineage
+	if chain, err := ts.Lineage(ctx, ids["other-access"]); err != nil || len(chain) != 1 {
+		t.Fatalf("lineage %+v: %v", chain, err)
+	}
+
+	// the chain stops at the first removed family
+	if err := ts.RemoveByAccess(ctx, "user-access"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.Collection(CollectionBasic).DeleteOne(ctx, map[string]interface{}{"_id": ids["user-access"]}); err != nil {
+		t.Fatal(err)
+	}
+
+	if chain, err := ts.Lineage(ctx, ids["c-access"]); err != nil || len(chain) != 3 {
+		t.Fatalf("lineage %+v after the removal of the original token: %v", chain, err)
+	}
+
+	if _, err := ts.Lineage(ctx, ids["user-access"]); err == nil {
+		t.Fatal("lineage of a removed family")
+	}
+
+	if _, err := ts.Lineage(ctx, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("empty basic ID: %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestExchangeCascade(t *testing.T) {
+	ctx := context.Background()
+
+	// without the option only the removed token goes
+	ts := newTestTokenStore(t)
+	delegationChain(t, ts)
+
+	if err := ts.RemoveByAccess(ctx, "a-access"); err != nil {
+		t.Fatal(err)
+	}
+
+	requireAccess(t, ts, map[string]bool{"a-access": false, "b-access": true, "c-access": true})
+
+	// the descendants of a subject token
+	ts = newTestTokenStore(t, WithExchangeCascade())
+	delegationChain(t, ts)
+
+	if err := ts.RemoveByAccess(ctx, "a-access"); err != nil {
+		t.Fatal(err)
+	}
+
+	requireAccess(t, ts, map[string]bool{
+		"a-access": false, "b-access": false, "c-access": false,
+		"user-access": true, "actor-access": true, "other-access": true,
+	})
+
+	if info, err := ts.GetByRefresh(ctx, "b-refresh"); err == nil && info != nil {
+		t.Fatal("refresh token of a descendant kept")
+	}
+
+	if info, err := ts.GetByRefresh(ctx, "user-refresh"); err != nil || info == nil {
+		t.Fatalf("refresh token of the original token removed: %v", err)
+	}
+
+	// the descendants of an actor token, through the refresh token removal too
+	ts = newTestTokenStore(t, WithExchangeCascade())
+	delegationChain(t, ts)
+
+	if err := ts.RemoveByAccess(ctx, "actor-access"); err != nil {
+		t.Fatal(err)
+	}
+
+	requireAccess(t, ts, map[string]bool{"b-access": false, "c-access": false, "a-access": true, "user-access": true})
+
+	ts = newTestTokenStore(t, WithExchangeCascade())
+	delegationChain(t, ts)
+
+	if err := ts.RemoveByRefresh(ctx, "user-refresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	requireAccess(t, ts, map[string]bool{"a-access": false, "b-access": false, "c-access": false, "actor-access": true})
+}
+
+func TestCreateExchangedValidation(t *testing.T) {
+	ts := newTestTokenStore(t)
+	ctx := context.Background()
+
+	if err := ts.Create(ctx, testToken("subject", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, err := range map[string]error{
+		"without subject token": ts.CreateExchanged(ctx, testToken("exchanged", ""), "", ""),
+		"authorization code":    ts.CreateExchanged(ctx, testCode("code"), "subject", ""),
+	} {
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("%s: %v, want ErrInvalidArgument", name, err)
+		}
+	}
+
+	for name, tokens := range map[string][2]string{"unknown subject token": {"unknown", ""}, "unknown actor token": {"subject", "unknown"}} {
+		if err := ts.CreateExchanged(ctx, testToken("exchanged", ""), tokens[0], tokens[1]); err == nil {
+			t.Fatalf("%s accepted", name)
+		}
+	}
+
+	requireAccess(t, ts, map[string]bool{"exchanged": false})
+}
@@ -0,0 +1,343 @@
+package mongo
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClientFilter criteria of List, empty criteria match every client
+type ClientFilter struct {
+	// owner of the clients, pseudonymized like the stored user ids
+	UserID string
+	// case-insensitive substring of the client domain
+	Domain string
+	// values of top-level metadata keys
+	Metadata map[string]interface{}
+	// status of the clients, empty for any
+	Status ClientStatus
+	// clients whose secret expires before, zero for any
+	SecretExpiresBefore time.Time
+	// case-insensitive substring of the client ID or name, see Search
+	Query string
+}
+
+// ClientSort order of the clients listed by List
+type ClientSort int
+
+// client orders, ties are broken by ID
+const (
+	SortByID ClientSort = iota
+	// clients stored before the timestamps were recorded come first
+	SortByCreatedAt
+	SortByUpdatedAt
+	// clients whose secret never expires come first
+	SortBySecretExpiresAt
+)
+
+// field the sorted field, empty for the ID
+func (s ClientSort) field() string {
+	switch s {
+	case SortByCreatedAt:
+		return clientCreatedField
+	case SortByUpdatedAt:
+		return clientUpdatedField
+	case SortBySecretExpiresAt:
+		return clientSecretExpiresField
+	}
+
+	return ""
+}
+
+// listCursor position after the last client of a page
+type listCursor struct {
+	ID string `bson:"id"`
+	// sorted value of the client, nil for the ID order or a missing timestamp
+	Value *time.Time `bson:"v,omitempty"`
+}
+
+// encodeCursor the opaque cursor of the client, positioned for the given order
+func encodeCursor(sort ClientSort, info oauth2.ClientInfo) string {
+	cur := listCursor{ID: info.GetID()}
+
+	if c := asClient(info); c != nil {
+		t := c.CreatedAt
+
+		switch sort {
+		case SortByUpdatedAt:
+			t = c.UpdatedAt
+		case SortBySecretExpiresAt:
+			t = c.SecretExpiresAt
+		}
+
+		if sort != SortByID && !t.IsZero() {
+			cur.Value = &t
+		}
+	}
+
+	data, _ := bson.Marshal(cur)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// after the filter of the clients following the cursor in the given order
+func (s ClientSort) after(cursor string) (bson.M, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+
+	var cur listCursor
+
+	if err == nil {
+		err = bson.Unmarshal(data, &cur)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidArgument)
+	}
+
+	field := s.field()
+
+	if field == "" {
+		return bson.M{"_id": bson.M{"$gt": cur.ID}}, nil
+	}
+
+	if cur.Value == nil {
+		// past the clients without timestamp come all those with one
+		return bson.M{"$or": bson.A{
+			bson.M{field: bson.M{"$type": "date"}},
+			bson.M{field: nil, "_id": bson.M{"$gt": cur.ID}},
+		}}, nil
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{"$gt": *cur.Value}},
+		bson.M{field: *cur.Value, "_id": bson.M{"$gt": cur.ID}},
+	}}, nil
+}
+
+// ListOptions pagination of List
+type ListOptions struct {
+	// clients per page, capped by ClientConfig.MaxPageSize(The default is the cap)
+	Limit int
+	// cursor returned by the previous page with the same order, empty for the first page
+	Cursor string
+	// order of the clients(The default is SortByID)
+	Sort ClientSort
+	// return the client secrets, decrypted(The default leaves them empty)
+	IncludeSecrets bool
+	// also return the disabled clients(The default leaves them out)
+	IncludeDisabled bool
+}
+
+// anyOf match the field under any of its names
+func anyOf(names []string, cond interface{}) bson.M {
+	match := bson.A{}
+
+	for _, name := range names {
+		match = append(match, bson.M{name: cond})
+	}
+
+	return bson.M{"$or": match}
+}
+
+// query the mongo filter of the criteria
+func (cs *ClientStore) query(f ClientFilter) bson.M {
+	fn := cs.fields()
+	and := bson.A{}
+
+	if f.UserID != "" {
+		and = append(and, cs.ownerFilter(f.UserID))
+	}
+
+	if f.Domain != "" {
+		and = append(and, anyOf(aliases(fn.Domain, func(f FieldNames) string { return f.Domain }),
+			primitive.Regex{Pattern: regexp.QuoteMeta(f.Domain), Options: "i"}))
+	}
+
+	if f.Status != "" {
+		and = append(and, statusFilter(f.Status))
+	}
+
+	if f.Query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(f.Query), Options: "i"}
+		and = append(and, bson.M{"$or": bson.A{bson.M{"_id": pattern}, bson.M{clientNameField: pattern}}})
+	}
+
+	if !f.SecretExpiresBefore.IsZero() {
+		and = append(and, bson.M{clientSecretExpiresField: bson.M{"$lt": f.SecretExpiresBefore}})
+	}
+
+	for key, value := range f.Metadata {
+		and = append(and, bson.M{clientMetadataField + "." + key: value})
+	}
+
+	if len(and) == 0 {
+		return bson.M{}
+	}
+
+	return bson.M{"$and": and}
+}
+
+// pageSize the effective page size of limit
+func (cs *ClientStore) pageSize(limit int) int64 {
+	max := cs.ccfg.MaxPageSize
+
+	if max <= 0 {
+		max = 100
+	}
+
+	if limit <= 0 || limit > max {
+		return int64(max)
+	}
+
+	return int64(limit)
+}
+
+// secretProjection leave the secrets and their key ids out of the returned documents
+func (cs *ClientStore) secretProjection() bson.M {
+	fn := cs.fields()
+	projection := bson.M{}
+
+	for _, name := range aliases(fn.Secret, func(f FieldNames) string { return f.Secret }) {
+		projection[name] = 0
+	}
+
+	for _, name := range aliases(fn.KeyID, func(f FieldNames) string { return f.KeyID }) {
+		projection[name] = 0
+	}
+
+	return projection
+}
+
+// List the clients matching filter in the order of opts, one page at a time. The returned cursor gives the
+// next page and is empty after the last one.
+func (cs *ClientStore) List(ctx context.Context, filter ClientFilter, opts ListOptions) (infos []oauth2.ClientInfo, next string, err error) {
+	o := cs.op("List", cs.ccfg.ClientsCName)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := validateFilter(filter); err != nil {
+			return err
+		}
+
+		query := cs.query(filter)
+
+		if !opts.IncludeDisabled {
+			query = active(query)
+		}
+
+		if opts.Cursor != "" {
+			after, err := opts.Sort.after(opts.Cursor)
+
+			if err != nil {
+				return err
+			}
+
+			query = bson.M{"$and": bson.A{query, after}}
+		}
+
+		order := bson.D{{Key: "_id", Value: 1}}
+
+		if field := opts.Sort.field(); field != "" {
+			order = bson.D{{Key: field, Value: 1}, {Key: "_id", Value: 1}}
+		}
+
+		limit := cs.pageSize(opts.Limit)
+		find := options.Find().SetSort(order).SetLimit(limit + 1)
+
+		if !opts.IncludeSecrets {
+			find.SetProjection(cs.secretProjection())
+		}
+
+		infos = []oauth2.ClientInfo{}
+
+		return cs.readHandler(ctx, cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) error {
+			cur, err := c.Find(ctx, query, find)
+
+			if err != nil {
+				return err
+			}
+
+			defer cur.Close(ctx)
+
+			for cur.Next(ctx) {
+				if int64(len(infos)) == limit {
+					next = encodeCursor(opts.Sort, infos[len(infos)-1])
+					break
+				}
+
+				info, err := cs.clientInfo(o, cur.Current)
+
+				if err != nil {
+					return err
+				}
+
+				infos = append(infos, info)
+			}
+
+			o.set("documents", len(infos))
+
+			return cur.Err()
+		})
+	})
+
+	return
+}
+
+// Count the number of active clients matching filter, without reading them. A filter on
+// ClientStatus counts the suspended clients as well.
+func (cs *ClientStore) Count(ctx context.Context, filter ClientFilter) (n int64, err error) {
+	o := cs.op("Count", cs.ccfg.ClientsCName)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := validateFilter(filter); err != nil {
+			return err
+		}
+
+		return cs.readHandler(ctx, cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) (err error) {
+			n, err = c.CountDocuments(ctx, active(cs.query(filter)))
+			return
+		})
+	})
+
+	return
+}
+
+// Exists report whether an active client has the ID, reading only its ID and status: disabled and suspended
+// clients don't exist
+func (cs *ClientStore) Exists(ctx context.Context, id string) (ok bool, err error) {
+	o := cs.op("Exists", cs.ccfg.ClientsCName)
+	o.set("client_id", id)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", id); err != nil {
+			return err
+		}
+
+		_, err := cs.findClient(ctx, id, bson.M{"_id": 1, clientDeletedField: 1, clientStatusField: 1})
+
+		if errors.Is(err, ErrClientNotFound) {
+			return nil
+		}
+
+		ok = err == nil
+		return err
+	})
+
+	return
+}
+
+// ExpiringSecrets list the clients whose secret expires within the duration(or already expired) by expiry,
+// e.g. to remind their owners to rotate them. The order of opts is ignored.
+func (cs *ClientStore) ExpiringSecrets(ctx context.Context, within time.Duration, opts ListOptions) ([]oauth2.ClientInfo, string, error) {
+	opts.Sort = SortBySecretExpiresAt
+
+	return cs.List(ctx, ClientFilter{SecretExpiresBefore: time.Now().Add(within)}, opts)
+}
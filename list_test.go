@@ -0,0 +1,230 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// listIDs the IDs of the clients
+func listIDs(infos []oauth2.ClientInfo) []string {
+	ids := []string{}
+
+	for _, info := range infos {
+		ids = append(ids, info.GetID())
+	}
+
+	return ids
+}
+
+// listAll iterate the pages of the clients matching filter, failing on empty pages
+func listAll(t *testing.T, cs *ClientStore, filter ClientFilter, opts ListOptions) (ids []string, pages int) {
+	t.Helper()
+
+	ids = []string{}
+
+	for {
+		infos, next, err := cs.List(context.Background(), filter, opts)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(infos) == 0 && opts.Cursor != "" {
+			t.Fatalf("empty page after %v", ids)
+		}
+
+		pages++
+		ids = append(ids, listIDs(infos)...)
+
+		if next == "" {
+			return ids, pages
+		}
+
+		opts.Cursor = next
+	}
+}
+
+func TestListPages(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.MaxPageSize = 3
+
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+
+	var want []string
+
+	for i := 6; i >= 0; i-- {
+		id := fmt.Sprintf("c%d", i)
+		want = append([]string{id}, want...)
+
+		if err := cs.Set(&models.Client{ID: id, Secret: "secret", Domain: "https://example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the pages are capped at MaxPageSize, sorted by ID
+	ids, pages := listAll(t, cs, ClientFilter{}, ListOptions{Limit: 10})
+
+	if !reflect.DeepEqual(ids, want) || pages != 3 {
+		t.Fatalf("%d pages of %v", pages, ids)
+	}
+
+	if ids, pages := listAll(t, cs, ClientFilter{}, ListOptions{Limit: 2}); !reflect.DeepEqual(ids, want) || pages != 4 {
+		t.Fatalf("%d pages of %v", pages, ids)
+	}
+
+	// the secrets are left out unless asked for
+	infos, _, err := cs.List(ctx, ClientFilter{}, ListOptions{})
+
+	if err != nil || infos[0].GetSecret() != "" {
+		t.Fatalf("listed secret %v: %v", infos, err)
+	}
+
+	if infos, _, err := cs.List(ctx, ClientFilter{}, ListOptions{IncludeSecrets: true}); err != nil || infos[0].GetSecret() != "secret" {
+		t.Fatalf("listed secret %v: %v", infos, err)
+	}
+
+	if _, _, err := cs.List(ctx, ClientFilter{}, ListOptions{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("malformed cursor: %v", err)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	for _, c := range []*models.Client{
+		{ID: "a", Domain: "https://app.example.com", UserID: "alice"},
+		{ID: "b", Domain: "https://API.example.com", UserID: "alice"},
+		{ID: "c", Domain: "https://example.org", UserID: "bob"},
+		{ID: "d", Domain: "https://api.example.org", UserID: "bob"},
+		{ID: "e", Domain: "https://api.example.net", UserID: "carol"},
+	} {
+		c.Secret = "secret"
+
+		if err := cs.Set(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cs.SetStatus(ctx, "d", ClientSuspended); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Disable(ctx, "e"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		filter ClientFilter
+		opts   ListOptions
+		want   []string
+	}{
+		{"all", ClientFilter{}, ListOptions{}, []string{"a", "b", "c", "d"}},
+		{"with the disabled", ClientFilter{}, ListOptions{IncludeDisabled: true}, []string{"a", "b", "c", "d", "e"}},
+		{"user", ClientFilter{UserID: "bob"}, ListOptions{}, []string{"c", "d"}},
+		{"disabled user", ClientFilter{UserID: "carol"}, ListOptions{}, []string{}},
+		{"domain substring", ClientFilter{Domain: "api."}, ListOptions{}, []string{"b", "d"}},
+		{"domain metacharacters", ClientFilter{Domain: ".*"}, ListOptions{}, []string{}},
+		{"active status", ClientFilter{Status: ClientActive}, ListOptions{}, []string{"a", "b", "c"}},
+		{"suspended status", ClientFilter{Status: ClientSuspended}, ListOptions{}, []string{"d"}},
+		{"user and domain", ClientFilter{UserID: "alice", Domain: "app"}, ListOptions{}, []string{"a"}},
+	}
+
+	for _, tc := range cases {
+		if ids, _ := listAll(t, cs, tc.filter, tc.opts); !reflect.DeepEqual(ids, tc.want) {
+			t.Errorf("%s: %v, want %v", tc.name, ids, tc.want)
+		}
+	}
+}
+
+func TestCountExists(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+
+	cs := NewClientStore(cfg)
+	defer cs.Close()
+
+	ctx := context.Background()
+
+	for _, c := range []*models.Client{
+		{ID: "a", Domain: "https://example.com", UserID: "alice"},
+		{ID: "b", Domain: "https://example.com", UserID: "alice"},
+		{ID: "c", Domain: "https://example.org", UserID: "bob"},
+		{ID: "d", Domain: "https://example.org", UserID: "bob"},
+	} {
+		c.Secret = "secret"
+
+		if err := cs.Set(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cs.SetStatus(ctx, "b", ClientSuspended); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Disable(ctx, "d"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a document stored without status
+	if _, err := cs.Collection().InsertOne(ctx, map[string]interface{}{"_id": "legacy", "secret": "secret", "domain": "https://example.net"}); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := []struct {
+		name   string
+		filter ClientFilter
+		want   int64
+	}{
+		{"all", ClientFilter{}, 4},
+		{"active status", ClientFilter{Status: ClientActive}, 3},
+		{"suspended status", ClientFilter{Status: ClientSuspended}, 1},
+		{"user", ClientFilter{UserID: "alice"}, 2},
+		{"user without the disabled", ClientFilter{UserID: "bob"}, 1},
+		{"user and status", ClientFilter{UserID: "alice", Status: ClientActive}, 1},
+		{"domain", ClientFilter{Domain: "example.org"}, 1},
+		{"no match", ClientFilter{UserID: "carol"}, 0},
+	}
+
+	for _, tc := range counts {
+		if n, err := cs.Count(ctx, tc.filter); err != nil || n != tc.want {
+			t.Errorf("%s: %d(%v), want %d", tc.name, n, err, tc.want)
+		}
+	}
+
+	if _, err := cs.Count(ctx, ClientFilter{Status: "unknown"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("unknown status: %v, want ErrInvalidArgument", err)
+	}
+
+	// the suspended and disabled clients don't exist
+	exists := map[string]bool{"a": true, "b": false, "c": true, "d": false, "legacy": true, "missing": false}
+
+	for id, want := range exists {
+		rec.reset()
+
+		if ok, err := cs.Exists(ctx, id); err != nil || ok != want {
+			t.Fatalf("%s exists %v(%v), want %v", id, ok, err, want)
+		}
+
+		for _, find := range rec.named("find") {
+			if _, err := find.Lookup("projection").Document().LookupErr("secret"); err == nil {
+				t.Fatalf("find projecting the secret: %s", find)
+			}
+		}
+	}
+
+	if _, err := cs.Exists(ctx, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("empty ID: %v, want ErrInvalidArgument", err)
+	}
+}
@@ -0,0 +1,80 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// LogLevel severity of a log record
+type LogLevel int
+
+// log levels
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	case LogError:
+		return "error"
+	}
+
+	return "unknown"
+}
+
+// Logger structured logger used by the stores,
+// fields never contain raw token values or client secrets
+type Logger interface {
+	Log(ctx context.Context, level LogLevel, msg string, fields map[string]interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(context.Context, LogLevel, string, map[string]interface{}) {}
+
+// stdLogger Logger writing key=value lines to a standard library logger
+type stdLogger struct {
+	l     *log.Logger
+	level LogLevel
+}
+
+// NewStdLogger create a Logger writing key=value lines to l, records below level are dropped
+func NewStdLogger(l *log.Logger, level LogLevel) Logger {
+	return &stdLogger{l: l, level: level}
+}
+
+func (s *stdLogger) Log(_ context.Context, level LogLevel, msg string, fields map[string]interface{}) {
+	if level < s.level {
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+
+	for k := range fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "level=%s msg=%q", level, msg)
+
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%q", k, fmt.Sprint(fields[k]))
+	}
+
+	s.l.Print(b.String())
+}
@@ -0,0 +1,135 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := NewStdLogger(log.New(&buf, "", 0), LogInfo)
+
+	l.Log(context.Background(), LogDebug, "dropped", nil)
+	l.Log(context.Background(), LogWarn, "index creation failed", map[string]interface{}{"error": "boom", "collection": "oauth2_access"})
+
+	if got, want := buf.String(), "level=warn msg=\"index creation failed\" collection=\"oauth2_access\" error=\"boom\"\n"; got != want {
+		t.Fatalf("output %q, want %q", got, want)
+	}
+}
+
+func TestLoggerOperations(t *testing.T) {
+	ctx := context.Background()
+
+	var rec logRecorder
+
+	db := testDatabase(t)
+	ts := testTokenStoreWithDB(db, WithLogger(&rec))
+	cs := NewClientStoreWithDB(db, WithLogger(&rec))
+
+	if len(rec.named("index ensured")) == 0 {
+		t.Fatal("index creation not logged")
+	}
+
+	if err := ts.Create(ctx, testToken("raw-access", "raw-refresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByAccess(ctx, "raw-access"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.RemoveByRefresh(ctx, "raw-refresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "raw-secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range []string{"Create", "GetByAccess", "RemoveByRefresh", "Set"} {
+		var started, finished bool
+
+		for _, r := range rec.named("operation started") {
+			started = started || (r.level == LogDebug && r.fields["operation"] == op)
+		}
+
+		for _, r := range rec.named("operation finished") {
+			finished = finished || (r.level == LogDebug && r.fields["operation"] == op && r.fields["duration"] != nil)
+		}
+
+		if !started || !finished {
+			t.Errorf("%s started %v, finished %v", op, started, finished)
+		}
+	}
+
+	for _, secret := range []string{"raw-access", "raw-refresh", "raw-secret"} {
+		if rec.contains(secret) {
+			t.Errorf("%s logged", secret)
+		}
+	}
+}
+
+// a failed operation logs its redacted error
+func TestLoggerFailedOperation(t *testing.T) {
+	var rec logRecorder
+
+	ts := newTestTokenStore(t, WithLogger(&rec))
+
+	if _, err := ts.GetByAccess(context.Background(), "raw-access"); err == nil {
+		t.Fatal("lookup of a missing token succeeded")
+	}
+
+	var logged bool
+
+	for _, r := range rec.named("operation finished") {
+		logged = logged || (r.fields["operation"] == "GetByAccess" && strings.Contains(r.fields["error"].(string), mongo.ErrNoDocuments.Error()))
+	}
+
+	if !logged {
+		t.Fatal("error not logged")
+	}
+
+	if rec.contains("raw-access") {
+		t.Fatal("raw token logged")
+	}
+}
+
+// the best-effort index creation is reported instead of failing the construction
+func TestLoggerIndexCreationFailed(t *testing.T) {
+	ctx := context.Background()
+
+	db := testDatabase(t)
+
+	// an index of the same keys under another name conflicts with the domain index of the store
+	_, err := db.Collection("oauth2_clients").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "domain", Value: 1}},
+		Options: options.Index().SetName("conflicting"),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rec logRecorder
+
+	NewClientStoreWithDB(db, WithLogger(&rec))
+
+	records := rec.named("index creation failed")
+
+	if len(records) == 0 {
+		t.Fatal("index creation failure not logged")
+	}
+
+	if r := records[0]; r.level != LogWarn || r.fields["collection"] != "oauth2_clients" || r.fields["error"] == nil {
+		t.Fatalf("record %+v", r)
+	}
+}
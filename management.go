@@ -0,0 +1,195 @@
+package mongo
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// field of the hashed RFC 7592 registration access token, kept by Set like the timestamps
+const clientRegistrationTokenField = "registration_token"
+
+// registrationTokenHash the stored SHA-256 of a registration access token, random tokens don't need a slow hash
+func registrationTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hashedTokenPrefix + hex.EncodeToString(sum[:])
+}
+
+// IssueRegistrationToken give the client a new RFC 7592 registration access token and return it, the only time it
+// is available: the store keeps its hash. The previous token stops being valid.
+func (cs *ClientStore) IssueRegistrationToken(ctx context.Context, clientID string) (token string, err error) {
+	o := cs.op("IssueRegistrationToken", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		var err error
+
+		if token, err = cs.generateSecret(); err != nil {
+			return err
+		}
+
+		o.sensitive(token)
+
+		return cs.auditedHandler(ctx, o, clientID, func(ctx context.Context, c *mongo.Collection) error {
+			res, err := c.UpdateOne(ctx, active(bson.M{"_id": clientID}), bson.M{
+				"$set": bson.M{clientRegistrationTokenField: registrationTokenHash(token), clientUpdatedField: time.Now()},
+			}, cs.updateOptions())
+
+			if err == nil && res.MatchedCount == 0 {
+				err = errClientNotFound
+			}
+
+			return err
+		})
+	})
+
+	if err != nil {
+		token = ""
+	}
+
+	return
+}
+
+// VerifyRegistrationToken check the registration access token presented to the client configuration endpoint,
+// a wrong token or a client without one returns ErrInvalidRegistrationToken
+func (cs *ClientStore) VerifyRegistrationToken(ctx context.Context, clientID, token string) error {
+	o := cs.op("VerifyRegistrationToken", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+	o.sensitive(token)
+
+	return cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, clientID, bson.M{clientRegistrationTokenField: 1, clientDeletedField: 1, clientStatusField: 1})
+
+		if err != nil {
+			return err
+		}
+
+		stored := lookupString(raw, []string{clientRegistrationTokenField})
+
+		if stored == "" || token == "" ||
+			subtle.ConstantTimeCompare([]byte(stored), []byte(registrationTokenHash(token))) != 1 {
+			return ErrInvalidRegistrationToken
+		}
+
+		return nil
+	})
+}
+
+// UpdateRegistration replace the registration metadata of the client as an RFC 7592 update does: the metadata
+// left out is removed, while the ID, secret, owner, timestamps and deployment metadata are kept. With
+// ClientConfig.RotateRegistrationToken set it returns the new registration access token, empty otherwise.
+func (cs *ClientStore) UpdateRegistration(ctx context.Context, clientID string, meta RegisteredClientMetadata) (token string, err error) {
+	o := cs.op("UpdateRegistration", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		reg := &RegisteredClient{ClientID: clientID, RegisteredClientMetadata: meta}
+
+		fields := cs.checkKeys(reg.JWKS, reg.JWKSURI)
+
+		if method := authMethod(reg); !knownAuthMethod(method) {
+			fields = append(fields, ClientFieldError{Field: "token_endpoint_auth_method", Reason: fmt.Sprintf("%q isn't supported", method)})
+		}
+
+		if len(fields) > 0 {
+			return &ClientValidationError{Fields: fields}
+		}
+
+		stored, err := cs.registration(reg)
+
+		if err != nil {
+			return err
+		}
+
+		fn := cs.fields()
+		set := bson.M{
+			clientRegistrationField: stored,
+			clientPublicField:       reg.IsPublic(),
+			clientAuthMethodField:   authMethod(reg),
+			clientUpdatedField:      time.Now(),
+		}
+		unset := bson.M{}
+
+		if uris := reg.GetRedirectURIs(); len(uris) > 0 {
+			set[clientRedirectField] = uris
+			set[fn.Domain] = uris[0]
+		} else {
+			unset[clientRedirectField] = ""
+		}
+
+		if len(reg.JWKS) > 0 {
+			set[clientJWKSField] = string(reg.JWKS)
+		} else {
+			unset[clientJWKSField] = ""
+		}
+
+		if reg.JWKSURI != "" {
+			set[clientJWKSURIField] = reg.JWKSURI
+		} else {
+			unset[clientJWKSURIField] = ""
+		}
+
+		lists := map[string][]string{
+			clientScopesField:   reg.GetScopes(),
+			clientGrantsField:   reg.GrantTypes,
+			clientResponseField: reg.ResponseTypes,
+		}
+
+		for field, values := range lists {
+			if len(values) > 0 {
+				set[field] = values
+			} else {
+				unset[field] = ""
+			}
+		}
+
+		if cs.ccfg.RotateRegistrationToken {
+			if token, err = cs.generateSecret(); err != nil {
+				return err
+			}
+
+			o.sensitive(token)
+			set[clientRegistrationTokenField] = registrationTokenHash(token)
+		}
+
+		update := bson.M{"$set": set}
+
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		return cs.duplicateKey(cs.auditedHandler(ctx, o, clientID, func(ctx context.Context, c *mongo.Collection) error {
+			res, err := c.UpdateOne(ctx, active(bson.M{"_id": clientID}), update, cs.updateOptions())
+
+			if err == nil && res.MatchedCount == 0 {
+				err = errClientNotFound
+			}
+
+			return err
+		}))
+	})
+
+	if err != nil {
+		token = ""
+	}
+
+	return
+}
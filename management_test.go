@@ -0,0 +1,151 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRegistrationLifecycle(t *testing.T) {
+	for _, rotate := range []bool{false, true} {
+		rotate := rotate
+		name := "keep token"
+
+		if rotate {
+			name = "rotate token"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			ccfg := NewDefaultClientConfig()
+			ccfg.RotateRegistrationToken = rotate
+			cs := newTestClientStore(t, ccfg)
+			ctx := context.Background()
+
+			// register
+			created, err := cs.CreateClient(ctx, &RegisteredClient{
+				UserID: "u",
+				RegisteredClientMetadata: RegisteredClientMetadata{
+					RedirectURIs: []string{"https://client.example.org/callback"},
+					GrantTypes:   []string{"authorization_code"},
+					ClientName:   "before",
+					LogoURI:      "https://client.example.org/logo.png",
+					Extra:        map[string]interface{}{"extension": "value"},
+				},
+			})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id, secret := created.GetID(), created.GetSecret()
+			token, err := cs.IssueRegistrationToken(ctx, id)
+
+			if err != nil || token == "" {
+				t.Fatalf("registration token %q: %v", token, err)
+			}
+
+			var raw bson.M
+
+			if err := cs.Collection().FindOne(ctx, bson.M{"_id": id}).Decode(&raw); err != nil {
+				t.Fatal(err)
+			}
+
+			if stored := raw[clientRegistrationTokenField]; stored == nil || stored == token {
+				t.Fatalf("registration token stored as %v", stored)
+			}
+
+			// read
+			if err := cs.VerifyRegistrationToken(ctx, id, token); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, wrong := range []string{"", "wrong", token + "x"} {
+				if err := cs.VerifyRegistrationToken(ctx, id, wrong); !errors.Is(err, ErrInvalidRegistrationToken) {
+					t.Fatalf("token %q: %v, want ErrInvalidRegistrationToken", wrong, err)
+				}
+			}
+
+			info, err := cs.GetByID(ctx, id)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			before := asClient(info)
+
+			if reg := before.GetRegistration(); reg == nil || reg.ClientName != "before" {
+				t.Fatalf("unexpected registration %+v", reg)
+			}
+
+			// update, the metadata left out is removed
+			next, err := cs.UpdateRegistration(ctx, id, RegisteredClientMetadata{
+				RedirectURIs: []string{"https://client.example.org/other"},
+				GrantTypes:   []string{"authorization_code", "refresh_token"},
+				ClientName:   "after",
+			})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rotate {
+				if next == "" || next == token {
+					t.Fatalf("registration token not rotated: %q", next)
+				}
+
+				if err := cs.VerifyRegistrationToken(ctx, id, token); !errors.Is(err, ErrInvalidRegistrationToken) {
+					t.Fatalf("previous token: %v, want ErrInvalidRegistrationToken", err)
+				}
+
+				token = next
+			} else if next != "" {
+				t.Fatalf("registration token %q returned without rotation", next)
+			}
+
+			if err := cs.VerifyRegistrationToken(ctx, id, token); err != nil {
+				t.Fatal(err)
+			}
+
+			if info, err = cs.GetByID(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+
+			after := asClient(info)
+			reg := after.GetRegistration()
+
+			if reg == nil || reg.ClientName != "after" || reg.LogoURI != "" || len(reg.Extra) != 0 {
+				t.Fatalf("metadata not replaced: %+v", reg)
+			}
+
+			if len(after.RedirectURIs) != 1 || after.RedirectURIs[0] != "https://client.example.org/other" ||
+				len(after.GrantTypes) != 2 {
+				t.Fatalf("client fields not replaced: %v %v", after.RedirectURIs, after.GrantTypes)
+			}
+
+			// the server managed fields are kept
+			if after.ID != id || after.GetSecret() != secret || after.UserID != "u" ||
+				!after.CreatedAt.Equal(before.CreatedAt) {
+				t.Fatalf("server managed fields changed: %+v, want %+v", after, before)
+			}
+
+			// delete
+			if err := cs.RemoveByID(id); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := cs.GetByID(ctx, id); !errors.Is(err, ErrClientNotFound) {
+				t.Fatalf("deleted client: %v, want ErrClientNotFound", err)
+			}
+
+			if err := cs.VerifyRegistrationToken(ctx, id, token); err == nil {
+				t.Fatal("registration token of a deleted client verified")
+			}
+
+			if _, err := cs.UpdateRegistration(ctx, id, RegisteredClientMetadata{}); !errors.Is(err, ErrClientNotFound) {
+				t.Fatalf("update of a deleted client: %v, want ErrClientNotFound", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package mongo
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// memoryCode an authorization code kept in process memory, with the attributes of its basic document
+type memoryCode struct {
+	key     string
+	codec   string
+	data    []byte
+	expires time.Time
+	issuer  string
+	// user id of the code, its pseudonym with TokenConfig.UserIDKey
+	userID string
+	sid    string
+	attrs  codeAttributes
+}
+
+// memoryCodes the authorization codes of TokenConfig.MemoryCodes, at most max of them: the oldest code
+// is evicted to make room for a new one
+type memoryCodes struct {
+	max int
+
+	mu    sync.Mutex
+	order *list.List
+	codes map[string]*list.Element
+}
+
+func newMemoryCodes(max int) *memoryCodes {
+	return &memoryCodes{max: max, order: list.New(), codes: make(map[string]*list.Element)}
+}
+
+// put keep the code until it expires, replacing a code with the same key
+func (m *memoryCodes) put(code memoryCode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(code.key)
+
+	// the codes are inserted in roughly expiry order, the expired ones are at the front
+	now := time.Now()
+
+	for e := m.order.Front(); e != nil; e = m.order.Front() {
+		oldest := e.Value.(memoryCode)
+
+		if m.order.Len() < m.max && now.Before(oldest.expires) {
+			break
+		}
+
+		m.remove(oldest.key)
+	}
+
+	m.codes[code.key] = m.order.PushBack(code)
+}
+
+// get the code unless it expired, an expired code is removed
+func (m *memoryCodes) get(key string) (memoryCode, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.codes[key]
+
+	if !ok {
+		return memoryCode{}, false
+	}
+
+	code := e.Value.(memoryCode)
+
+	if !time.Now().Before(code.expires) {
+		m.remove(key)
+		return memoryCode{}, false
+	}
+
+	return code, true
+}
+
+// delete remove the code, reporting whether it was kept
+func (m *memoryCodes) delete(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.remove(key)
+}
+
+func (m *memoryCodes) remove(key string) bool {
+	e, ok := m.codes[key]
+
+	if ok {
+		m.order.Remove(e)
+		delete(m.codes, key)
+	}
+
+	return ok
+}
+
+// len the number of kept codes, the expired ones not yet evicted included
+func (m *memoryCodes) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.order.Len()
+}
+
+// removeMatching remove the codes match reports, returning the number of the unexpired ones
+func (m *memoryCodes) removeMatching(match func(memoryCode) bool) (n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	for e := m.order.Front(); e != nil; {
+		next := e.Next()
+
+		if code := e.Value.(memoryCode); match(code) {
+			m.remove(code.key)
+
+			if now.Before(code.expires) {
+				n++
+			}
+		}
+
+		e = next
+	}
+
+	return
+}
+
+// count the unexpired codes match reports
+func (m *memoryCodes) count(match func(memoryCode) bool) (n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	for e := m.order.Front(); e != nil; e = e.Next() {
+		if code := e.Value.(memoryCode); now.Before(code.expires) && match(code) {
+			n++
+		}
+	}
+
+	return
+}
+
+// createMemoryCode keep the authorization code of info in memory
+func (ts *TokenStore) createMemoryCode(ctx context.Context, o *operation, info oauth2.TokenInfo, codec Codec, data []byte) error {
+	attrs := newCodeAttributes(ctx, info)
+
+	ts.codes.put(memoryCode{
+		key:     ts.codeKey(ctx, info.GetCode()),
+		codec:   codec.Name(),
+		data:    data,
+		expires: info.GetCodeCreateAt().Add(info.GetCodeExpiresIn()),
+		issuer:  ts.issuer(ctx),
+		userID:  ts.userID(info.GetUserID()),
+		sid:     SessionIDFromContext(ctx),
+		attrs:   attrs,
+	})
+
+	o.set("documents", 0)
+	o.set("memory_codes", ts.codes.len())
+	o.set("code_challenge_method", attrs.methodOrNone())
+
+	return nil
+}
+
+// getMemoryCode the token information of the authorization code kept in memory and its session ID,
+// mongo.ErrNoDocuments like the stored codes when it is unknown or expired
+func (ts *TokenStore) getMemoryCode(ctx context.Context, code string) (oauth2.TokenInfo, string, error) {
+	mc, ok := ts.codes.get(ts.codeKey(ctx, code))
+
+	if !ok {
+		return nil, "", mongo.ErrNoDocuments
+	}
+
+	codec, err := ts.codecs.get(mc.codec)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	// each lookup decodes its own copy, the callers may modify it
+	var tm models.Token
+
+	if err := codec.Unmarshal(mc.data, &tm); err != nil {
+		return nil, "", err
+	}
+
+	return &tm, mc.sid, nil
+}
+
+// memoryCodeRecord the record of the authorization code kept in memory, mongo.ErrNoDocuments when it is unknown
+// or expired
+func (ts *TokenStore) memoryCodeRecord(ctx context.Context, code string) (TokenRecord, error) {
+	mc, ok := ts.codes.get(ts.codeKey(ctx, code))
+
+	if !ok {
+		return TokenRecord{}, mongo.ErrNoDocuments
+	}
+
+	return TokenRecord{
+		BasicID:             mc.key,
+		Issuer:              mc.issuer,
+		UserID:              mc.userID,
+		SessionID:           mc.sid,
+		CodeChallenge:       mc.attrs.Challenge,
+		CodeChallengeMethod: mc.attrs.Method,
+		ExpiredAt:           mc.expires,
+	}, nil
+}
+
+// memoryCodeMatch match the codes kept in memory of the issuer of the operation, like withIssuer
+func (ts *TokenStore) memoryCodeMatch(ctx context.Context, match func(memoryCode) bool) func(memoryCode) bool {
+	issuer := ts.issuer(ctx)
+
+	return func(mc memoryCode) bool {
+		return (issuer == "" || mc.issuer == issuer) && match(mc)
+	}
+}
+
+// removeMemoryCodes remove the codes kept in memory match reports, returning their number
+func (ts *TokenStore) removeMemoryCodes(ctx context.Context, match func(memoryCode) bool) int64 {
+	if ts.codes == nil {
+		return 0
+	}
+
+	return ts.codes.removeMatching(ts.memoryCodeMatch(ctx, match))
+}
+
+// memoryCodesUnsupported fail the operations reading the code documents when the codes are kept in memory
+func (ts *TokenStore) memoryCodesUnsupported() error {
+	if ts.codes != nil {
+		return fmt.Errorf("%w: authorization codes are kept in memory", ErrUnsupported)
+	}
+
+	return nil
+}
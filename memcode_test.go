@@ -0,0 +1,226 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMemoryCodes(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+
+	ts := testTokenStore(cfg, WithMemoryCodes(10))
+	defer ts.Close()
+
+	ctx := context.Background()
+	rec.reset()
+
+	if err := ts.Create(ctx, testCode("code")); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := ts.GetByCode(ctx, "code")
+
+	if err != nil || info.GetCode() != "code" || info.GetRedirectURI() != "https://example.com/cb" || info.GetUserID() != "u" {
+		t.Fatalf("code %v: %v", info, err)
+	}
+
+	// each lookup gets its own copy
+	info.SetUserID("other")
+
+	if info, err := ts.GetByCode(ctx, "code"); err != nil || info.GetUserID() != "u" {
+		t.Fatalf("code %v after a change of a copy: %v", info, err)
+	}
+
+	if cmds := append(rec.named("insert"), rec.named("find")...); len(cmds) != 0 {
+		t.Fatalf("code commands %v", cmds)
+	}
+
+	if err := ts.RemoveByCode(ctx, "code"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByCode(ctx, "code"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("removed code: %v, want mongo.ErrNoDocuments", err)
+	}
+
+	// the tokens are stored as before
+	token := testToken("access", "refresh")
+
+	if err := ts.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.named("insert")) == 0 {
+		t.Fatal("token not inserted")
+	}
+
+	got, err := testTokenStoreWithDB(ts.Database()).GetByAccess(ctx, "access")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireSameToken(t, got, token)
+
+	if _, err := ts.GetByRefresh(ctx, "refresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	// no code document beside the token family
+	want := int64(1)
+
+	if ts.tcfg.SingleCollection {
+		want = 3
+	}
+
+	if n, err := ts.Collection(CollectionBasic).CountDocuments(ctx, bson.M{}); err != nil || n != want {
+		t.Fatalf("%d documents in %s, want %d: %v", n, ts.Collection(CollectionBasic).Name(), want, err)
+	}
+
+	// the codes keep the attributes of their basic documents
+	since := time.Now().Add(-time.Second)
+	pkce := ContextWithCodeChallenge(ContextWithSessionID(ctx, "sid"), "challenge", CodeChallengeS256)
+
+	if err := ts.Create(pkce, testCode("pkce")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Create(ctx, testCode("plain")); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := ts.RecordByCode(ctx, "pkce")
+
+	if err != nil || record.UserID != "u" || record.SessionID != "sid" || record.CodeChallengeMethod != CodeChallengeS256 || record.CodeChallenge == "" {
+		t.Fatalf("record %+v: %v", record, err)
+	}
+
+	if _, err := ts.RecordByCode(ctx, "code"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("record of a removed code: %v, want mongo.ErrNoDocuments", err)
+	}
+
+	if n, err := ts.CodesWithoutPKCE(ctx, since); err != nil || n != 1 {
+		t.Fatalf("%d codes without PKCE: %v", n, err)
+	}
+}
+
+func TestMemoryCodesRemoved(t *testing.T) {
+	ts := newTestTokenStore(t, WithMemoryCodes(10))
+	ctx := context.Background()
+
+	for _, c := range []struct {
+		ctx    context.Context
+		code   string
+		userID string
+	}{
+		{ctx, "code-1", "u"},
+		{ContextWithSessionID(ctx, "sid"), "code-2", "u"},
+		{ContextWithSessionID(ctx, "sid"), "code-3", "v"},
+		{ctx, "code-4", "v"},
+	} {
+		info := testCode(c.code)
+		info.UserID = c.userID
+
+		if err := ts.Create(c.ctx, info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ts.Create(ctx, testToken("access", "refresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the codes of the user are no longer redeemable, with the tokens: 2 codes and 3 documents
+	n, err := ts.RemoveAllByUserID(ctx, "u")
+
+	if want := int64(5); err != nil || n != want {
+		t.Fatalf("removed %d, want %d: %v", n, want, err)
+	}
+
+	n, err = ts.RemoveBySessionID(ctx, "sid")
+
+	if err != nil || n != 1 {
+		t.Fatalf("removed %d of the session: %v", n, err)
+	}
+
+	for code, kept := range map[string]bool{"code-1": false, "code-2": false, "code-3": false, "code-4": true} {
+		if _, err := ts.GetByCode(ctx, code); (err == nil) != kept {
+			t.Fatalf("%s kept %v: %v", code, !kept, err)
+		}
+	}
+}
+
+func TestMemoryCodeExpiry(t *testing.T) {
+	ts := newTestTokenStore(t, WithMemoryCodes(10))
+	ctx := context.Background()
+	code := testCode("code")
+	code.CodeExpiresIn = 50 * time.Millisecond
+
+	if err := ts.Create(ctx, code); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByCode(ctx, "code"); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := ts.GetByCode(ctx, "code"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("expired code: %v, want mongo.ErrNoDocuments", err)
+	}
+
+	// the expired code is dropped on lookup
+	if n := ts.codes.len(); n != 0 {
+		t.Fatalf("%d codes kept", n)
+	}
+}
+
+func TestMemoryCodesEviction(t *testing.T) {
+	m := newMemoryCodes(2)
+	now := time.Now()
+
+	for _, key := range []string{"a", "b", "c"} {
+		m.put(memoryCode{key: key, expires: now.Add(time.Minute)})
+	}
+
+	// the oldest code makes room
+	if _, ok := m.get("a"); ok || m.len() != 2 {
+		t.Fatalf("oldest code kept, %d codes", m.len())
+	}
+
+	for _, key := range []string{"b", "c"} {
+		if _, ok := m.get(key); !ok {
+			t.Fatalf("code %s evicted", key)
+		}
+	}
+
+	// the expired codes go first
+	m = newMemoryCodes(3)
+	m.put(memoryCode{key: "expired", expires: now.Add(-time.Second)})
+	m.put(memoryCode{key: "a", expires: now.Add(time.Minute)})
+	m.put(memoryCode{key: "b", expires: now.Add(time.Minute)})
+
+	if m.len() != 2 {
+		t.Fatalf("%d codes, want the expired one dropped", m.len())
+	}
+
+	// a code put again replaces the previous one
+	m.put(memoryCode{key: "a", codec: "gob", expires: now.Add(time.Minute)})
+
+	if code, ok := m.get("a"); !ok || code.codec != "gob" || m.len() != 2 {
+		t.Fatalf("replaced code %+v, %d codes", code, m.len())
+	}
+
+	if !m.delete("a") || m.delete("a") {
+		t.Fatal("deletion of a kept code")
+	}
+}
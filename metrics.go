@@ -0,0 +1,34 @@
+package mongo
+
+import (
+	"time"
+)
+
+// Metrics receive the outcome of every public store operation,
+// op is the method name(e.g. GetByAccess) and collection its main collection
+type Metrics interface {
+	ObserveOp(op string, collection string, dur time.Duration, err error)
+	// ObserveBreaker a state transition of the circuit breaker
+	ObserveBreaker(from, to BreakerState)
+	// ObserveCache a lookup of an in-process cache, e.g. CacheRefreshPolicy
+	ObserveCache(cache string, hit bool)
+	// ObserveCleanup the documents of the collection deleted by a cleanup sweep(RemoveExpired, PurgeDisabled),
+	// i.e. the backlog the sweep found
+	ObserveCleanup(collection string, removed int64)
+}
+
+// names of the caches reported to Metrics.ObserveCache
+const (
+	// the policies kept by RefreshPolicyResolver
+	CacheRefreshPolicy = "refresh_policy"
+)
+
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveOp(string, string, time.Duration, error) {}
+
+func (nopMetrics) ObserveBreaker(BreakerState, BreakerState) {}
+
+func (nopMetrics) ObserveCache(string, bool) {}
+
+func (nopMetrics) ObserveCleanup(string, int64) {}
@@ -0,0 +1,212 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// observation an operation reported to memMetrics
+type observation struct {
+	op         string
+	collection string
+	dur        time.Duration
+	err        error
+}
+
+// memMetrics a Metrics keeping the observations in memory
+type memMetrics struct {
+	mu          sync.Mutex
+	obs         []observation
+	transitions []string
+	// cache lookups by cache and outcome(hit/miss)
+	cache map[string]int
+	// deleted documents by collection
+	cleanup map[string]int64
+}
+
+func (m *memMetrics) ObserveOp(op string, collection string, dur time.Duration, err error) {
+	m.mu.Lock()
+	m.obs = append(m.obs, observation{op: op, collection: collection, dur: dur, err: err})
+	m.mu.Unlock()
+}
+
+func (m *memMetrics) ObserveBreaker(from, to BreakerState) {
+	m.mu.Lock()
+	m.transitions = append(m.transitions, from.String()+"->"+to.String())
+	m.mu.Unlock()
+}
+
+func (m *memMetrics) ObserveCache(cache string, hit bool) {
+	outcome := "miss"
+
+	if hit {
+		outcome = "hit"
+	}
+
+	m.mu.Lock()
+
+	if m.cache == nil {
+		m.cache = make(map[string]int)
+	}
+
+	m.cache[cache+"/"+outcome]++
+	m.mu.Unlock()
+}
+
+func (m *memMetrics) ObserveCleanup(collection string, removed int64) {
+	m.mu.Lock()
+
+	if m.cleanup == nil {
+		m.cleanup = make(map[string]int64)
+	}
+
+	m.cleanup[collection] += removed
+	m.mu.Unlock()
+}
+
+// take the observations since the previous call
+func (m *memMetrics) take() []observation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obs := m.obs
+	m.obs = nil
+
+	return obs
+}
+
+func TestMetricsObserveEachOperationOnce(t *testing.T) {
+	ctx := context.Background()
+
+	var m memMetrics
+
+	db := testDatabase(t)
+	ts := testTokenStoreWithDB(db, WithMetrics(&m))
+	cs := NewClientStoreWithDB(db, WithMetrics(&m))
+
+	m.take()
+
+	for _, tc := range []struct {
+		op         string
+		collection string
+		fn         func() error
+		wantErr    error
+	}{
+		{"Create", "oauth2_basic", func() error { return ts.Create(ctx, testToken("access", "refresh")) }, nil},
+		{"GetByAccess", "oauth2_access", func() error { _, err := ts.GetByAccess(ctx, "access"); return err }, nil},
+		{"GetByRefresh", "oauth2_refresh", func() error { _, err := ts.GetByRefresh(ctx, "refresh"); return err }, nil},
+		{"GetByAccess", "oauth2_access", func() error { _, err := ts.GetByAccess(ctx, "missing"); return err }, mongo.ErrNoDocuments},
+		{"RemoveByRefresh", "oauth2_refresh", func() error { return ts.RemoveByRefresh(ctx, "refresh") }, nil},
+		{"RemoveByAccess", "oauth2_access", func() error { return ts.RemoveByAccess(ctx, "access") }, nil},
+		{"Set", "oauth2_clients", func() error { return cs.Set(&models.Client{ID: "c", Secret: "s", Domain: "https://example.com"}) }, nil},
+		{"GetByID", "oauth2_clients", func() error { _, err := cs.GetByID(ctx, "c"); return err }, nil},
+		{"GetByID", "oauth2_clients", func() error { _, err := cs.GetByID(ctx, "missing"); return err }, ErrClientNotFound},
+		{"RemoveByID", "oauth2_clients", func() error { return cs.RemoveByID("c") }, nil},
+	} {
+		err := tc.fn()
+
+		if !errors.Is(err, tc.wantErr) {
+			t.Fatalf("%s: %v, want %v", tc.op, err, tc.wantErr)
+		}
+
+		obs := m.take()
+
+		if len(obs) != 1 {
+			t.Fatalf("%s reported %d times: %+v", tc.op, len(obs), obs)
+		}
+
+		if o := obs[0]; o.op != tc.op || o.collection != tc.collection || o.err != err || o.dur <= 0 {
+			t.Fatalf("%s reported %+v", tc.op, o)
+		}
+	}
+}
+
+// the operations refused by a closed store are reported as well
+func TestMetricsObserveClosedStore(t *testing.T) {
+	var m memMetrics
+
+	ts := newTestTokenStore(t, WithMetrics(&m))
+
+	if err := ts.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	m.take()
+
+	_, err := ts.GetByAccess(context.Background(), "access")
+
+	if obs := m.take(); len(obs) != 1 || !errors.Is(obs[0].err, ErrStoreClosed) || obs[0].err != err {
+		t.Fatalf("closed store reported %+v", obs)
+	}
+}
+
+func TestMetricsCacheAndCleanup(t *testing.T) {
+	ctx := context.Background()
+
+	var m memMetrics
+
+	ts := newTestTokenStore(t, WithMetrics(&m))
+	past := time.Now().Add(-2 * time.Hour)
+	expired := testToken("expired-access", "expired-refresh")
+	expired.AccessCreateAt, expired.RefreshCreateAt = past, past
+	expired.RefreshExpiresIn = time.Hour
+
+	if err := ts.Create(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := ts.RemoveExpired(ctx)
+
+	if err != nil || n != 3 {
+		t.Fatalf("%d documents removed: %v", n, err)
+	}
+
+	var removed int64
+
+	for _, r := range m.cleanup {
+		removed += r
+	}
+
+	if removed != n || m.cleanup[ts.Collection(CollectionRefresh).Name()] == 0 {
+		t.Fatalf("cleanup %v, want %d documents", m.cleanup, n)
+	}
+
+	cs := newTestClientStore(t, WithMetrics(&m))
+
+	for _, id := range []string{"c", "disabled"} {
+		if err := cs.Set(&models.Client{ID: id, Secret: "s", Domain: "https://example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every lookup is a miss without ttl
+	for _, r := range []*RefreshPolicyResolver{NewRefreshPolicyResolver(cs, time.Hour), NewRefreshPolicyResolver(cs, 0)} {
+		for i := 0; i < 2; i++ {
+			if _, err := r.Policy(ctx, "c"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if hits, misses := m.cache[CacheRefreshPolicy+"/hit"], m.cache[CacheRefreshPolicy+"/miss"]; hits != 1 || misses != 3 {
+		t.Fatalf("%d hits and %d misses", hits, misses)
+	}
+
+	if err := cs.Disable(ctx, "disabled"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cs.Collection().UpdateOne(ctx, map[string]interface{}{"_id": "disabled"}, map[string]interface{}{"$set": map[string]interface{}{clientDeletedField: past.Add(-100 * 24 * time.Hour)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := cs.PurgeDisabled(ctx); err != nil || n != 1 || m.cleanup[cs.Collection().Name()] != 1 {
+		t.Fatalf("%d clients purged, cleanup %v: %v", n, m.cleanup, err)
+	}
+}
@@ -0,0 +1,598 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// the integration tests run against the server of MONGODB_URI, they are skipped when it isn't set
+func testURI(t *testing.T) string {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_URI")
+
+	if uri == "" {
+		t.Skip("MONGODB_URI not set")
+	}
+
+	return uri
+}
+
+var testDBSeq int32
+
+// testDBName a database name of its own for each test
+func testDBName() string {
+	return fmt.Sprintf("oauth2_test_%d_%d", os.Getpid(), atomic.AddInt32(&testDBSeq, 1))
+}
+
+// testConfig the connection configuration of a database of its own, dropped when the test ends
+func testConfig(t *testing.T) *Config {
+	t.Helper()
+
+	cfg := NewConfig(testURI(t), testDBName())
+
+	t.Cleanup(func() { dropTestDatabase(cfg.URL, cfg.DB) })
+
+	return cfg
+}
+
+// dropTestDatabase drop the database through a client of its own, the client of the test may be disconnected
+func dropTestDatabase(uri, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+
+	if err != nil {
+		return
+	}
+
+	_ = client.Database(name).Drop(ctx)
+	_ = client.Disconnect(ctx)
+}
+
+// testDatabase a database of its own with its own client, dropped and disconnected when the test ends
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	uri := testURI(t)
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := client.Database(testDBName())
+
+	t.Cleanup(func() {
+		_ = client.Disconnect(context.Background())
+		dropTestDatabase(uri, db.Name())
+	})
+
+	return db
+}
+
+// testSingleCollection the token stores of the tests use the single collection layout, see TestMain
+var testSingleCollection bool
+
+// TestMain run the tests over both layouts of the token store, the single collection one only against a server
+func TestMain(m *testing.M) {
+	code := m.Run()
+
+	if code == 0 && os.Getenv("MONGODB_URI") != "" {
+		fmt.Println("single collection layout")
+
+		testSingleCollection = true
+		code = m.Run()
+	}
+
+	os.Exit(code)
+}
+
+// testTokenOptions the options of the token stores of the tests in the layout under test
+func testTokenOptions(opts ...TokenOption) []TokenOption {
+	if !testSingleCollection {
+		return opts
+	}
+
+	return append(opts, WithSingleCollection())
+}
+
+// testTokenStore NewTokenStore in the layout under test
+func testTokenStore(cfg *Config, opts ...TokenOption) *TokenStore {
+	return NewTokenStore(cfg, testTokenOptions(opts...)...)
+}
+
+// testTokenStoreWithDB NewTokenStoreWithDB in the layout under test
+func testTokenStoreWithDB(db *mongo.Database, opts ...TokenOption) *TokenStore {
+	return NewTokenStoreWithDB(db, testTokenOptions(opts...)...)
+}
+
+// testKindFilter the filter of the documents of the collection kind, tagged with their kind in the single
+// collection layout where the kinds share a collection
+func testKindFilter(ts *TokenStore, kind CollectionKind, filter bson.M) bson.M {
+	if ts.tcfg.SingleCollection {
+		filter[tokenKindField] = map[CollectionKind]string{
+			CollectionBasic:   kindBasic,
+			CollectionAccess:  kindAccess,
+			CollectionRefresh: kindRefresh,
+		}[kind]
+	}
+
+	return filter
+}
+
+// requireSeparateCollections skip the tests of documents laid out in the separate token collections,
+// e.g. the dumps of other stores
+func requireSeparateCollections(t *testing.T) {
+	t.Helper()
+
+	if testSingleCollection {
+		t.Skip("the documents are in the separate collection layout")
+	}
+}
+
+// newTestTokenStore a token store on a database of its own
+func newTestTokenStore(t *testing.T, opts ...TokenOption) *TokenStore {
+	t.Helper()
+
+	return testTokenStoreWithDB(testDatabase(t), opts...)
+}
+
+// newTestClientStore a client store on a database of its own
+func newTestClientStore(t *testing.T, opts ...ClientOption) *ClientStore {
+	t.Helper()
+
+	return NewClientStoreWithDB(testDatabase(t), opts...)
+}
+
+// requireTransactions skip the tests which need multi-document transactions
+func requireTransactions(t *testing.T, caps Capabilities) {
+	t.Helper()
+
+	if !caps.Transactions {
+		t.Skip("the server doesn't support transactions")
+	}
+}
+
+// skipNotImplemented skip the test when err is the server rejecting a feature it doesn't implement,
+// e.g. the TTL indexes on FerretDB
+func skipNotImplemented(t *testing.T, err error) {
+	t.Helper()
+
+	var ce mongo.CommandError
+
+	if errors.As(err, &ce) && ce.Name == "NotImplemented" {
+		t.Skip(ce.Message)
+	}
+}
+
+// requireAtomicFindAndModify skip the tests racing single-use reads on FerretDB, whose findAndModify is a find
+// followed by a delete
+func requireAtomicFindAndModify(t *testing.T, db *mongo.Database) {
+	t.Helper()
+
+	var info bson.M
+
+	if err := db.RunCommand(context.Background(), bson.D{{Key: "buildInfo", Value: 1}}).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := info["ferretdbVersion"]; ok {
+		t.Skip("findAndModify isn't atomic on FerretDB")
+	}
+}
+
+// testToken an access/refresh token pair of client c, the refresh token is left out when empty
+func testToken(access, refresh string) *models.Token {
+	now := time.Now()
+
+	info := &models.Token{
+		ClientID:        "c",
+		UserID:          "u",
+		Scope:           "read",
+		Access:          access,
+		AccessCreateAt:  now,
+		AccessExpiresIn: time.Hour,
+	}
+
+	if refresh != "" {
+		info.Refresh = refresh
+		info.RefreshCreateAt = now
+		info.RefreshExpiresIn = 24 * time.Hour
+	}
+
+	return info
+}
+
+// testCode an authorization code of client c
+func testCode(code string) *models.Token {
+	return &models.Token{
+		ClientID:      "c",
+		UserID:        "u",
+		RedirectURI:   "https://example.com/cb",
+		Scope:         "read",
+		Code:          code,
+		CodeCreateAt:  time.Now(),
+		CodeExpiresIn: time.Minute,
+	}
+}
+
+// commandRecorder record the commands started by a client, by command name
+type commandRecorder struct {
+	mu       sync.Mutex
+	commands []*event.CommandStartedEvent
+}
+
+func (r *commandRecorder) monitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			r.mu.Lock()
+			r.commands = append(r.commands, e)
+			r.mu.Unlock()
+		},
+	}
+}
+
+// named the recorded commands named name, e.g. find
+func (r *commandRecorder) named(name string) []bson.Raw {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var cmds []bson.Raw
+
+	for _, e := range r.commands {
+		if e.CommandName == name {
+			cmds = append(cmds, e.Command)
+		}
+	}
+
+	return cmds
+}
+
+func (r *commandRecorder) reset() {
+	r.mu.Lock()
+	r.commands = nil
+	r.mu.Unlock()
+}
+
+// unreachableDatabase a database of a client whose server selection fails after a short timeout,
+// nothing listens on the port
+func unreachableDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	client, err := mongo.Connect(context.Background(), options.Client().
+		ApplyURI("mongodb://127.0.0.1:1").
+		SetServerSelectionTimeout(200*time.Millisecond).
+		SetConnectTimeout(200*time.Millisecond))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+
+	return client.Database("oauth2")
+}
+
+// logRecord a record of logRecorder
+type logRecord struct {
+	level  LogLevel
+	msg    string
+	fields map[string]interface{}
+}
+
+// logRecorder a Logger keeping the records
+type logRecorder struct {
+	mu      sync.Mutex
+	records []logRecord
+}
+
+func (r *logRecorder) Log(_ context.Context, level LogLevel, msg string, fields map[string]interface{}) {
+	r.mu.Lock()
+	r.records = append(r.records, logRecord{level: level, msg: msg, fields: fields})
+	r.mu.Unlock()
+}
+
+// named the records with the message msg
+func (r *logRecorder) named(msg string) []logRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var records []logRecord
+
+	for _, rec := range r.records {
+		if rec.msg == msg {
+			records = append(records, rec)
+		}
+	}
+
+	return records
+}
+
+// contains report whether a field of a record holds s
+func (r *logRecorder) contains(s string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, rec := range r.records {
+		for _, v := range rec.fields {
+			if strings.Contains(fmt.Sprint(v), s) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// stallProxy a TCP proxy to the test server which stops forwarding while stalled, the operations
+// through it then hang like on an unresponsive server
+type stallProxy struct {
+	ln     net.Listener
+	target string
+
+	mu      sync.Mutex
+	stalled chan struct{}
+}
+
+func newStallProxy(t *testing.T) *stallProxy {
+	t.Helper()
+
+	cs, err := connstring.ParseAndValidate(testURI(t))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &stallProxy{ln: ln, target: cs.Hosts[0]}
+
+	t.Cleanup(func() {
+		p.resume()
+		_ = ln.Close()
+	})
+
+	go p.serve()
+
+	return p
+}
+
+// database a database of a client connected directly through the proxy, disconnected when the test ends
+func (p *stallProxy) database(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(testURI(t)).
+		SetHosts([]string{p.ln.Addr().String()}).
+		SetDirect(true))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := client.Database(testDBName())
+
+	t.Cleanup(func() {
+		p.resume()
+
+		p.resume()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+
+	return db
+}
+
+// stall stop forwarding until resume
+func (p *stallProxy) stall() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stalled == nil {
+		p.stalled = make(chan struct{})
+	}
+}
+
+func (p *stallProxy) resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stalled != nil {
+		close(p.stalled)
+		p.stalled = nil
+	}
+}
+
+// wait block while the proxy is stalled
+func (p *stallProxy) wait() {
+	p.mu.Lock()
+	stalled := p.stalled
+	p.mu.Unlock()
+
+	if stalled != nil {
+		<-stalled
+	}
+}
+
+func (p *stallProxy) serve() {
+	for {
+		conn, err := p.ln.Accept()
+
+		if err != nil {
+			return
+		}
+
+		upstream, err := net.Dial("tcp", p.target)
+
+		if err != nil {
+			_ = conn.Close()
+			continue
+		}
+
+		go p.forward(conn, upstream)
+		go p.forward(upstream, conn)
+	}
+}
+
+func (p *stallProxy) forward(dst, src net.Conn) {
+	defer dst.Close()
+	defer src.Close()
+
+	buf := make([]byte, 32<<10)
+
+	for {
+		n, err := src.Read(buf)
+
+		if err != nil {
+			return
+		}
+
+		p.wait()
+
+		if _, err := dst.Write(buf[:n]); err != nil {
+			return
+		}
+	}
+}
+
+// testTokenOperations run every operation of the token store interface, ts must be empty
+func testTokenOperations(t *testing.T, ts *TokenStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	if err := ts.Create(ctx, testCode("code")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Create(ctx, testToken("access", "refresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := ts.GetByCode(ctx, "code")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code.GetCode() != "code" || code.GetRedirectURI() != "https://example.com/cb" {
+		t.Fatalf("code %s with redirect URI %s", code.GetCode(), code.GetRedirectURI())
+	}
+
+	for _, get := range []func() (oauth2.TokenInfo, error){
+		func() (oauth2.TokenInfo, error) { return ts.GetByAccess(ctx, "access") },
+		func() (oauth2.TokenInfo, error) { return ts.GetByRefresh(ctx, "refresh") },
+	} {
+		info, err := get()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.GetAccess() != "access" || info.GetRefresh() != "refresh" || info.GetUserID() != "u" || info.GetScope() != "read" {
+			t.Fatalf("token %+v", info)
+		}
+	}
+
+	if err := ts.RemoveByCode(ctx, "code"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByCode(ctx, "code"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("removed code: %v", err)
+	}
+
+	if err := ts.RemoveByAccess(ctx, "access"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByAccess(ctx, "access"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("removed access token: %v", err)
+	}
+
+	if _, err := ts.GetByRefresh(ctx, "refresh"); err != nil {
+		t.Fatalf("refresh token of the removed access token: %v", err)
+	}
+
+	if err := ts.RemoveByRefresh(ctx, "refresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByRefresh(ctx, "refresh"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("removed refresh token: %v", err)
+	}
+}
+
+// testClientOperations run the client lookups and writes, cs must be empty
+func testClientOperations(t *testing.T, cs *ClientStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := cs.GetByID(ctx, "c")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.GetSecret() != "secret" || info.GetDomain() != "https://example.com" || info.GetUserID() != "u" {
+		t.Fatalf("client %+v", info)
+	}
+
+	if info, err := cs.GetByDomain(ctx, "https://example.com"); err != nil || info.GetID() != "c" {
+		t.Fatalf("client by domain %v: %v", info, err)
+	}
+
+	if infos, err := cs.GetByUserID(ctx, "u", 0); err != nil || len(infos) != 1 || infos[0].GetID() != "c" {
+		t.Fatalf("clients by user %v: %v", infos, err)
+	}
+
+	if err := cs.Update(ctx, &models.Client{ID: "c", Domain: "https://example.org", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := cs.GetByID(ctx, "c"); err != nil || info.GetDomain() != "https://example.org" || info.GetSecret() != "secret" {
+		t.Fatalf("updated client %v: %v", info, err)
+	}
+
+	if err := cs.RemoveByID("c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cs.GetByID(ctx, "c"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("removed client: %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+module github.com/Jakkarin/go-oauth2-mongo/mongoprom
+
+go 1.14
+
+require (
+	github.com/Jakkarin/go-oauth2-mongo v0.0.0-20261015143823-cfadc0985a68
+	github.com/prometheus/client_golang v1.7.1
+	github.com/prometheus/client_model v0.2.0
+	go.mongodb.org/mongo-driver v1.5.4
+)
+
+// builds of this repository use the root module next to it, the importers get the required version
+replace github.com/Jakkarin/go-oauth2-mongo => ../
@@ -0,0 +1,182 @@
+// Package mongoprom exposes the operations of the mongo token and client stores as Prometheus metrics.
+package mongoprom
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	store "github.com/Jakkarin/go-oauth2-mongo"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Metrics Prometheus implementation of the store Metrics hook
+type Metrics struct {
+	duration    *prometheus.HistogramVec
+	errors      *prometheus.CounterVec
+	transitions *prometheus.CounterVec
+	cache       *prometheus.CounterVec
+	hitRatio    *prometheus.GaugeVec
+	removed     *prometheus.CounterVec
+	backlog     *prometheus.GaugeVec
+
+	mu sync.Mutex
+	// hits and lookups by cache, the hit ratio is computed from them
+	lookups map[string][2]uint64
+}
+
+var _ store.Metrics = (*Metrics)(nil)
+
+// Config collector configuration parameters
+type Config struct {
+	// metric namespace(The default is oauth2_mongo)
+	Namespace string
+	// latency histogram buckets(The default is prometheus.DefBuckets)
+	Buckets []float64
+}
+
+// New create the collectors and register them on reg
+func New(reg prometheus.Registerer, cfgs ...Config) (*Metrics, error) {
+	cfg := Config{Namespace: "oauth2_mongo"}
+
+	if len(cfgs) > 0 {
+		cfg = cfgs[0]
+	}
+
+	if cfg.Namespace == "" {
+		cfg.Namespace = "oauth2_mongo"
+	}
+
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = prometheus.DefBuckets
+	}
+
+	m := &Metrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.Namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of the store operations.",
+			Buckets:   cfg.Buckets,
+		}, []string{"operation", "collection", "status"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Name:      "operation_errors_total",
+			Help:      "Failed store operations by error category.",
+		}, []string{"operation", "collection", "category"}),
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Name:      "breaker_transitions_total",
+			Help:      "State transitions of the circuit breaker.",
+		}, []string{"from", "to"}),
+		cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Name:      "cache_lookups_total",
+			Help:      "Lookups of the in-process caches by result(hit or miss).",
+		}, []string{"cache", "result"}),
+		hitRatio: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.Namespace,
+			Name:      "cache_hit_ratio",
+			Help:      "Share of the lookups of the in-process caches served from the cache.",
+		}, []string{"cache"}),
+		removed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Name:      "cleanup_removed_documents_total",
+			Help:      "Documents deleted by the cleanup sweeps.",
+		}, []string{"collection"}),
+		backlog: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.Namespace,
+			Name:      "cleanup_backlog_documents",
+			Help:      "Expired documents found by the last cleanup sweep of the collection.",
+		}, []string{"collection"}),
+		lookups: make(map[string][2]uint64),
+	}
+
+	for _, c := range []prometheus.Collector{m.duration, m.errors, m.transitions, m.cache, m.hitRatio, m.removed, m.backlog} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// ObserveOp implement the store Metrics interface
+func (m *Metrics) ObserveOp(op string, collection string, dur time.Duration, err error) {
+	status := "ok"
+
+	if err != nil {
+		status = "error"
+		m.errors.WithLabelValues(op, collection, Category(err)).Inc()
+	}
+
+	m.duration.WithLabelValues(op, collection, status).Observe(dur.Seconds())
+}
+
+// ObserveBreaker implement the store Metrics interface
+func (m *Metrics) ObserveBreaker(from, to store.BreakerState) {
+	m.transitions.WithLabelValues(from.String(), to.String()).Inc()
+}
+
+// ObserveCache implement the store Metrics interface
+func (m *Metrics) ObserveCache(cache string, hit bool) {
+	result := "miss"
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := m.lookups[cache]
+
+	if hit {
+		result = "hit"
+		counts[0]++
+	}
+
+	counts[1]++
+	m.lookups[cache] = counts
+
+	m.cache.WithLabelValues(cache, result).Inc()
+	m.hitRatio.WithLabelValues(cache).Set(float64(counts[0]) / float64(counts[1]))
+}
+
+// ObserveCleanup implement the store Metrics interface
+func (m *Metrics) ObserveCleanup(collection string, removed int64) {
+	m.removed.WithLabelValues(collection).Add(float64(removed))
+	m.backlog.WithLabelValues(collection).Set(float64(removed))
+}
+
+// Category classify a store error into a low cardinality label value
+func Category(err error) string {
+	var cmdErr mongo.CommandError
+	var writeErr mongo.WriteException
+
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return "not_found"
+	case errors.Is(err, store.ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, store.ErrStoreClosed):
+		return "closed"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &writeErr):
+		for _, we := range writeErr.WriteErrors {
+			if we.Code == 11000 {
+				return "duplicate_key"
+			}
+		}
+
+		return "write"
+	case errors.As(err, &cmdErr):
+		if cmdErr.HasErrorLabel("NetworkError") {
+			return "network"
+		}
+
+		return "command"
+	}
+
+	return "other"
+}
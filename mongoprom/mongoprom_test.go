@@ -0,0 +1,216 @@
+package mongoprom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	store "github.com/Jakkarin/go-oauth2-mongo"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// labels the label values of m by name
+func labels(m *dto.Metric) map[string]string {
+	l := make(map[string]string)
+
+	for _, p := range m.GetLabel() {
+		l[p.GetName()] = p.GetValue()
+	}
+
+	return l
+}
+
+func TestScrape(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	m, err := New(reg, Config{Namespace: "test"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.ObserveOp("GetByAccess", "oauth2_access", 20*time.Millisecond, nil)
+	m.ObserveOp("GetByAccess", "oauth2_access", 30*time.Millisecond, nil)
+	m.ObserveOp("GetByAccess", "oauth2_access", time.Millisecond, fmt.Errorf("wrapped: %w", mongo.ErrNoDocuments))
+
+	families, err := reg.Gather()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := make(map[string]*dto.MetricFamily)
+
+	for _, f := range families {
+		found[f.GetName()] = f
+	}
+
+	duration := found["test_operation_duration_seconds"]
+
+	if duration == nil {
+		t.Fatalf("no duration histogram in %v", found)
+	}
+
+	counts := make(map[string]uint64)
+
+	for _, metric := range duration.GetMetric() {
+		l := labels(metric)
+
+		if l["operation"] != "GetByAccess" || l["collection"] != "oauth2_access" {
+			t.Fatalf("labels %v", l)
+		}
+
+		counts[l["status"]] = metric.GetHistogram().GetSampleCount()
+	}
+
+	if counts["ok"] != 2 || counts["error"] != 1 {
+		t.Fatalf("sample counts %v, want 2 ok and 1 error", counts)
+	}
+
+	errs := found["test_operation_errors_total"]
+
+	if errs == nil || len(errs.GetMetric()) != 1 {
+		t.Fatalf("error counter %v", errs)
+	}
+
+	if l := labels(errs.GetMetric()[0]); l["category"] != "not_found" || errs.GetMetric()[0].GetCounter().GetValue() != 1 {
+		t.Fatalf("error counter %v", errs.GetMetric()[0])
+	}
+}
+
+func TestScrapeBreakerTransitions(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	m, err := New(reg, Config{Namespace: "test"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.ObserveBreaker(store.BreakerClosed, store.BreakerOpen)
+	m.ObserveBreaker(store.BreakerOpen, store.BreakerHalfOpen)
+	m.ObserveBreaker(store.BreakerHalfOpen, store.BreakerOpen)
+	m.ObserveBreaker(store.BreakerOpen, store.BreakerHalfOpen)
+
+	families, err := reg.Gather()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]float64)
+
+	for _, f := range families {
+		if f.GetName() != "test_breaker_transitions_total" {
+			continue
+		}
+
+		for _, metric := range f.GetMetric() {
+			l := labels(metric)
+			counts[l["from"]+"->"+l["to"]] = metric.GetCounter().GetValue()
+		}
+	}
+
+	if len(counts) != 3 || counts["closed->open"] != 1 || counts["open->half-open"] != 2 || counts["half-open->open"] != 1 {
+		t.Fatalf("transitions %v", counts)
+	}
+}
+
+func TestScrapeCacheAndCleanup(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	m, err := New(reg, Config{Namespace: "test"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, hit := range []bool{false, true, true, true} {
+		m.ObserveCache(store.CacheRefreshPolicy, hit)
+	}
+
+	m.ObserveCleanup("oauth2_access", 40)
+	m.ObserveCleanup("oauth2_access", 2)
+	m.ObserveCleanup("oauth2_denylist", 0)
+
+	families, err := reg.Gather()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the values of each family by their label values
+	values := make(map[string]map[string]float64)
+
+	for _, f := range families {
+		v := make(map[string]float64)
+
+		for _, metric := range f.GetMetric() {
+			l := labels(metric)
+
+			switch {
+			case metric.GetCounter() != nil:
+				v[l["cache"]+l["result"]+l["collection"]] = metric.GetCounter().GetValue()
+			case metric.GetGauge() != nil:
+				v[l["cache"]+l["collection"]] = metric.GetGauge().GetValue()
+			}
+		}
+
+		values[f.GetName()] = v
+	}
+
+	if v := values["test_cache_lookups_total"]; v["refresh_policyhit"] != 3 || v["refresh_policymiss"] != 1 {
+		t.Fatalf("cache lookups %v", v)
+	}
+
+	if v := values["test_cache_hit_ratio"]; v["refresh_policy"] != 0.75 {
+		t.Fatalf("cache hit ratio %v", v)
+	}
+
+	if v := values["test_cleanup_removed_documents_total"]; v["oauth2_access"] != 42 || len(v) != 2 {
+		t.Fatalf("removed documents %v", v)
+	}
+
+	// the backlog of the last sweep
+	if v := values["test_cleanup_backlog_documents"]; v["oauth2_access"] != 2 || v["oauth2_denylist"] != 0 {
+		t.Fatalf("cleanup backlog %v", v)
+	}
+}
+
+func TestNewRegistersOnce(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	if _, err := New(reg); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(reg); err == nil {
+		t.Fatal("second registration of the same namespace succeeded")
+	}
+}
+
+func TestCategory(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{mongo.ErrNoDocuments, "not_found"},
+		{fmt.Errorf("op: %w", store.ErrCircuitOpen), "circuit_open"},
+		{store.ErrStoreClosed, "closed"},
+		{context.DeadlineExceeded, "timeout"},
+		{context.Canceled, "canceled"},
+		{mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}, "duplicate_key"},
+		{mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 121}}}, "write"},
+		{mongo.CommandError{Code: 6, Labels: []string{"NetworkError"}}, "network"},
+		{mongo.CommandError{Code: 13}, "command"},
+		{errors.New("boom"), "other"},
+	} {
+		if got := Category(tc.err); got != tc.want {
+			t.Errorf("Category(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// operation a public store operation being executed
+type operation struct {
+	store      string
+	name       string
+	collection string
+	// identifiers which are safe to log, never raw token values
+	fields map[string]interface{}
+	// values redacted from the returned error
+	secrets []string
+}
+
+func newOperation(store, name, collection string) *operation {
+	return &operation{
+		store:      store,
+		name:       name,
+		collection: collection,
+	}
+}
+
+// set record a loggable identifier of the operation
+func (o *operation) set(key string, value interface{}) {
+	if o.fields == nil {
+		o.fields = make(map[string]interface{})
+	}
+
+	o.fields[key] = value
+}
+
+// logFields the loggable fields of the operation merged with extra
+func (o *operation) logFields(extra map[string]interface{}) map[string]interface{} {
+	fields := map[string]interface{}{
+		"store":      o.store,
+		"operation":  o.name,
+		"collection": o.collection,
+	}
+
+	for k, v := range o.fields {
+		fields[k] = v
+	}
+
+	for k, v := range extra {
+		fields[k] = v
+	}
+
+	return fields
+}
+
+// wrap attach the operation context to err, with the sensitive values redacted
+func (o *operation) wrap(err error) error {
+	return &OpError{Store: o.store, Op: o.name, Collection: o.collection, Err: o.redact(err)}
+}
+
+// observer hooks invoked around every public store operation
+type observer struct {
+	tracker         *tracker
+	conns           *connHolder
+	logger          Logger
+	metrics         Metrics
+	tracer          Tracer
+	slowOpThreshold time.Duration
+}
+
+func (ob observer) run(ctx context.Context, o *operation, fn func(context.Context) error) (err error) {
+	if err = ob.tracker.acquire(); err != nil {
+		err = o.wrap(err)
+		ob.metrics.ObserveOp(o.name, o.collection, 0, err)
+
+		return err
+	}
+
+	defer ob.tracker.release()
+	defer ob.conns.acquire().release()
+
+	if ob.tracer != nil {
+		var end func(map[string]interface{}, error)
+
+		ctx, end = ob.tracer.Start(ctx, o.store, o.name, o.collection)
+		defer func() { end(o.fields, err) }()
+	}
+
+	ob.logger.Log(ctx, LogDebug, "operation started", o.logFields(nil))
+
+	start := time.Now()
+
+	err = fn(ctx)
+
+	if err != nil {
+		err = o.wrap(err)
+	}
+
+	dur := time.Since(start)
+
+	ob.metrics.ObserveOp(o.name, o.collection, dur, err)
+
+	fields := o.logFields(map[string]interface{}{"duration": dur})
+
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+
+	ob.logger.Log(ctx, LogDebug, "operation finished", fields)
+
+	if ob.slowOpThreshold > 0 && dur > ob.slowOpThreshold {
+		ob.logger.Log(ctx, LogWarn, "slow operation", fields)
+	}
+
+	return err
+}
+
+// withTimeout derive the operation context, a zero timeout adds no deadline
+// and an earlier deadline of the caller always wins
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// transaction run fn inside a session transaction, or directly when transactions are disabled
+func transaction(ctx context.Context, client *mongo.Client, disabled bool, timeout time.Duration, logger Logger, fn func(context.Context) error) error {
+	if disabled {
+		ctx, cancel := withTimeout(ctx, timeout)
+
+		defer cancel()
+
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	return client.UseSession(ctx, func(session mongo.SessionContext) error {
+		// derived from the session context so the operations of fn run in the transaction,
+		// whose reads must use the primary whatever the read preference of their collection
+		ctx, cancel := withTimeout(session, timeout)
+
+		defer cancel()
+
+		if err := session.StartTransaction(options.Transaction().SetReadPreference(readpref.Primary())); err != nil {
+			return err
+		}
+
+		err := fn(ctx)
+
+		if err != nil {
+			// the abort isn't bound by the expired timeout of a failed operation
+			if aerr := session.AbortTransaction(session); aerr != nil {
+				logger.Log(ctx, LogWarn, "transaction abort failed", map[string]interface{}{"error": aerr.Error()})
+			}
+
+			return err
+		}
+
+		return session.CommitTransaction(ctx)
+	})
+}
@@ -0,0 +1,294 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestSlowOperationThreshold(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		threshold time.Duration
+		delay     time.Duration
+		slow      bool
+	}{
+		{"above", 10 * time.Millisecond, 30 * time.Millisecond, true},
+		{"below", 100 * time.Millisecond, 0, false},
+		{"disabled", 0, 30 * time.Millisecond, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var rec logRecorder
+
+			ob := observer{
+				tracker:         &tracker{},
+				conns:           newConnHolder(nil),
+				logger:          &rec,
+				metrics:         nopMetrics{},
+				slowOpThreshold: tc.threshold,
+			}
+
+			o := newOperation("token", "GetByAccess", "oauth2_access")
+			o.set("basic_id", "b1")
+
+			_ = ob.run(context.Background(), o, func(context.Context) error {
+				time.Sleep(tc.delay)
+				return nil
+			})
+
+			records := rec.named("slow operation")
+
+			if !tc.slow {
+				if len(records) != 0 {
+					t.Fatalf("slow operation logged: %+v", records)
+				}
+
+				return
+			}
+
+			if len(records) != 1 {
+				t.Fatalf("%d slow operation records, want 1", len(records))
+			}
+
+			r := records[0]
+
+			if r.level != LogWarn || r.fields["operation"] != "GetByAccess" || r.fields["collection"] != "oauth2_access" || r.fields["basic_id"] != "b1" {
+				t.Fatalf("record %+v", r)
+			}
+
+			if d, _ := r.fields["duration"].(time.Duration); d < tc.delay {
+				t.Fatalf("duration %v, want at least %v", d, tc.delay)
+			}
+		})
+	}
+}
+
+// the latency is injected into the finds of the server, the raw token never reaches the record
+func TestSlowOperationStore(t *testing.T) {
+	var rec logRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			if e.CommandName == "find" {
+				time.Sleep(30 * time.Millisecond)
+			}
+		},
+	}
+
+	ts := testTokenStore(cfg, WithLogger(&rec), WithSlowOpThreshold(20*time.Millisecond))
+	defer ts.Close()
+
+	ctx := context.Background()
+
+	if err := ts.Create(ctx, testToken("raw-access-token", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByAccess(ctx, "raw-access-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	var found bool
+
+	for _, r := range rec.named("slow operation") {
+		found = found || r.fields["operation"] == "GetByAccess"
+	}
+
+	if !found {
+		t.Fatal("slow GetByAccess not logged")
+	}
+
+	if rec.contains("raw-access-token") {
+		t.Fatal("raw token logged")
+	}
+}
+
+// the writes of an aborted transaction leave nothing written
+func TestTransactionAbort(t *testing.T) {
+	ts := newTestTokenStore(t)
+	requireTransactions(t, ts.Capabilities())
+
+	ctx := context.Background()
+	db := ts.conns.database()
+	c := db.Collection("txn_test")
+
+	// the collection exists before the transaction, which can't create it on older servers
+	if _, err := c.InsertOne(ctx, bson.M{"_id": "existing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	errAbort := errors.New("abort")
+
+	err := transaction(ctx, db.Client(), false, time.Minute, nopLogger{}, func(ctx context.Context) error {
+		if _, err := c.InsertOne(ctx, bson.M{"_id": "first"}); err != nil {
+			return err
+		}
+
+		if _, err := c.UpdateOne(ctx, bson.M{"_id": "existing"}, bson.M{"$set": bson.M{"updated": true}}); err != nil {
+			return err
+		}
+
+		// the uncommitted writes are visible inside the transaction only
+		if err := c.FindOne(ctx, bson.M{"_id": "first"}).Err(); err != nil {
+			return err
+		}
+
+		if err := c.FindOne(context.Background(), bson.M{"_id": "first"}).Err(); err != mongo.ErrNoDocuments {
+			t.Errorf("uncommitted write visible outside the transaction: %v", err)
+		}
+
+		return errAbort
+	})
+
+	if err != errAbort {
+		t.Fatalf("transaction: %v, want the error of fn", err)
+	}
+
+	if n, err := c.CountDocuments(ctx, bson.M{"$or": bson.A{bson.M{"_id": "first"}, bson.M{"updated": true}}}); err != nil || n != 0 {
+		t.Fatalf("%d aborted writes left(%v)", n, err)
+	}
+}
+
+func TestTransactionCommit(t *testing.T) {
+	ts := newTestTokenStore(t)
+	requireTransactions(t, ts.Capabilities())
+
+	ctx := context.Background()
+	db := ts.conns.database()
+
+	// a secondary read preference of the collection doesn't apply inside the transaction
+	c := db.Collection("txn_test", options.Collection().SetReadPreference(readpref.SecondaryPreferred()))
+
+	if _, err := c.InsertOne(ctx, bson.M{"_id": "existing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := transaction(ctx, db.Client(), false, time.Minute, nopLogger{}, func(ctx context.Context) error {
+		if _, err := c.InsertOne(ctx, bson.M{"_id": "first"}); err != nil {
+			return err
+		}
+
+		return c.FindOne(ctx, bson.M{"_id": "first"}).Err()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := c.CountDocuments(ctx, bson.M{}); err != nil || n != 2 {
+		t.Fatalf("%d documents after the commit(%v), want 2", n, err)
+	}
+}
+
+// the store writes run in the transaction: a Create failing halfway leaves no document
+func TestTransactionStoreWrite(t *testing.T) {
+	ts := newTestTokenStore(t)
+	requireTransactions(t, ts.Capabilities())
+
+	ctx := context.Background()
+
+	if err := ts.Create(ctx, testToken("access", "refresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the refresh token collides with the stored one after the basic and access documents were inserted
+	if err := ts.Create(ctx, testToken("other-access", "refresh")); err == nil {
+		t.Fatal("duplicate refresh token stored")
+	}
+
+	if _, err := ts.GetByAccess(ctx, "other-access"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("access token of the failed Create: %v, want mongo.ErrNoDocuments", err)
+	}
+}
+
+// a failed abort is logged and the error of fn returned
+func TestTransactionAbortFailure(t *testing.T) {
+	var rec logRecorder
+
+	db := testDatabase(t)
+	errAbort := errors.New("abort")
+
+	err := transaction(context.Background(), db.Client(), false, time.Minute, &rec, func(ctx context.Context) error {
+		// aborted by fn itself, the abort of transaction then fails
+		if err := mongo.SessionFromContext(ctx).AbortTransaction(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		return errAbort
+	})
+
+	if err != errAbort {
+		t.Fatalf("transaction: %v, want the error of fn", err)
+	}
+
+	if records := rec.named("transaction abort failed"); len(records) != 1 || records[0].level != LogWarn || records[0].fields["error"] == "" {
+		t.Fatalf("abort records %+v", records)
+	}
+}
+
+// the writes of a transaction exceeding its timeout are aborted after the timeout expired
+func TestTransactionAbortTimeout(t *testing.T) {
+	var rec logRecorder
+
+	ts := newTestTokenStore(t)
+	requireTransactions(t, ts.Capabilities())
+
+	ctx := context.Background()
+	db := ts.conns.database()
+	c := db.Collection("txn_test")
+
+	if _, err := c.InsertOne(ctx, bson.M{"_id": "existing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := transaction(ctx, db.Client(), false, 100*time.Millisecond, &rec, func(ctx context.Context) error {
+		if _, err := c.InsertOne(ctx, bson.M{"_id": "first"}); err != nil {
+			return err
+		}
+
+		<-ctx.Done()
+
+		return ctx.Err()
+	})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("transaction: %v, want context.DeadlineExceeded", err)
+	}
+
+	if records := rec.named("transaction abort failed"); len(records) != 0 {
+		t.Fatalf("abort failed %+v", records)
+	}
+
+	if err := c.FindOne(ctx, bson.M{"_id": "first"}).Err(); err != mongo.ErrNoDocuments {
+		t.Fatalf("write of the aborted transaction: %v, want mongo.ErrNoDocuments", err)
+	}
+}
+
+// without transactions fn runs directly under the timeout
+func TestTransactionDisabled(t *testing.T) {
+	db := testDatabase(t)
+
+	err := transaction(context.Background(), db.Client(), true, time.Minute, nopLogger{}, func(ctx context.Context) error {
+		if mongo.SessionFromContext(ctx) != nil {
+			t.Error("session of a disabled transaction")
+		}
+
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("no deadline")
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,425 @@
+package mongo
+
+import (
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// TokenOption configure a token store at construction time,
+// a *TokenConfig is itself a TokenOption used as the base configuration
+type TokenOption interface {
+	applyToken(*TokenConfig)
+}
+
+// ClientOption configure a client store at construction time,
+// a *ClientConfig is itself a ClientOption used as the base configuration
+type ClientOption interface {
+	applyClient(*ClientConfig)
+}
+
+// Option functional option accepted by both stores
+type Option struct {
+	base   bool
+	token  func(*TokenConfig)
+	client func(*ClientConfig)
+}
+
+func (o Option) applyToken(tcfg *TokenConfig) {
+	if o.token != nil {
+		o.token(tcfg)
+	}
+}
+
+func (o Option) applyClient(ccfg *ClientConfig) {
+	if o.client != nil {
+		o.client(ccfg)
+	}
+}
+
+// applyToken use the configuration as the base of the options, its zero fields keep the default
+func (tcfg *TokenConfig) applyToken(c *TokenConfig) {
+	mergeConfig(c, tcfg)
+}
+
+// applyClient use the configuration as the base of the options, its zero fields keep the default
+func (ccfg *ClientConfig) applyClient(c *ClientConfig) {
+	mergeConfig(c, ccfg)
+}
+
+// mergeConfig set the fields of the configuration struct dst to the non-zero fields of src,
+// both pointers to the same struct type
+func mergeConfig(dst, src interface{}) {
+	d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+
+	for i := 0; i < s.NumField(); i++ {
+		if f := s.Field(i); !f.IsZero() {
+			d.Field(i).Set(f)
+		}
+	}
+}
+
+// isBaseOption report whether opt replaces the whole configuration, such options
+// are applied before the functional ones whatever their position
+func isBaseOption(opt interface{}) bool {
+	switch o := opt.(type) {
+	case *TokenConfig, *ClientConfig:
+		return true
+	case Option:
+		return o.base
+	}
+
+	return false
+}
+
+// newTokenConfig build the token configuration: the configuration structs(or the default one)
+// are the base, the functional options are then applied in order
+func newTokenConfig(opts []TokenOption) *TokenConfig {
+	tcfg := NewDefaultTokenConfig()
+
+	for _, base := range []bool{true, false} {
+		for _, opt := range opts {
+			if opt != nil && isBaseOption(opt) == base {
+				opt.applyToken(tcfg)
+			}
+		}
+	}
+
+	tcfg.defaultCNames()
+
+	return tcfg
+}
+
+// newClientConfig build the client configuration: the configuration structs(or the default one)
+// are the base, the functional options are then applied in order
+func newClientConfig(opts []ClientOption) *ClientConfig {
+	ccfg := NewDefaultClientConfig()
+
+	for _, base := range []bool{true, false} {
+		for _, opt := range opts {
+			if opt != nil && isBaseOption(opt) == base {
+				opt.applyClient(ccfg)
+			}
+		}
+	}
+
+	return ccfg
+}
+
+// WithTokenConfig use tcfg as the base configuration of the token store
+func WithTokenConfig(tcfg *TokenConfig) Option {
+	return Option{base: true, token: tcfg.applyToken}
+}
+
+// WithClientConfig use ccfg as the base configuration of the client store
+func WithClientConfig(ccfg *ClientConfig) Option {
+	return Option{base: true, client: ccfg.applyClient}
+}
+
+// CollectionNames collection names of both stores, empty names keep the configured value
+type CollectionNames struct {
+	Txn      string
+	Basic    string
+	Access   string
+	Refresh  string
+	Denylist string
+	Consents string
+	PAR      string
+	Keys     string
+	Tokens   string
+	States   string
+	Device   string
+	Clients  string
+}
+
+// WithCollectionNames override the collection names
+func WithCollectionNames(names CollectionNames) Option {
+	set := func(dst *string, name string) {
+		if name != "" {
+			*dst = name
+		}
+	}
+
+	return Option{
+		token: func(c *TokenConfig) {
+			set(&c.TxnCName, names.Txn)
+			set(&c.BasicCName, names.Basic)
+			set(&c.AccessCName, names.Access)
+			set(&c.RefreshCName, names.Refresh)
+			set(&c.DenylistCName, names.Denylist)
+			set(&c.ConsentsCName, names.Consents)
+			set(&c.PARCName, names.PAR)
+			set(&c.KeysCName, names.Keys)
+			set(&c.TokensCName, names.Tokens)
+			set(&c.StatesCName, names.States)
+			set(&c.DeviceCName, names.Device)
+		},
+		client: func(c *ClientConfig) {
+			set(&c.ClientsCName, names.Clients)
+		},
+	}
+}
+
+// WithOperationTimeout set the timeout of both the lookups and the writes, 0 disables it
+func WithOperationTimeout(d time.Duration) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.ReadTimeout, c.WriteTimeout = d, d },
+		client: func(c *ClientConfig) { c.ReadTimeout, c.WriteTimeout = d, d },
+	}
+}
+
+// WithReadTimeout set the timeout of the lookups, 0 disables it
+func WithReadTimeout(d time.Duration) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.ReadTimeout = d },
+		client: func(c *ClientConfig) { c.ReadTimeout = d },
+	}
+}
+
+// WithWriteTimeout set the timeout of the writes, 0 disables it
+func WithWriteTimeout(d time.Duration) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.WriteTimeout = d },
+		client: func(c *ClientConfig) { c.WriteTimeout = d },
+	}
+}
+
+// WithoutTransactions run the writes without session transactions
+func WithoutTransactions() Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.DisableTransactions = true },
+		client: func(c *ClientConfig) { c.DisableTransactions = true },
+	}
+}
+
+// WithReadPreference set the read preference of the lookups
+func WithReadPreference(rp *readpref.ReadPref) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.ReadPreference = rp },
+		client: func(c *ClientConfig) { c.ReadPreference = rp },
+	}
+}
+
+// WithReadConcern set the read concern of the lookups
+func WithReadConcern(rc *readconcern.ReadConcern) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.ReadConcern = rc },
+		client: func(c *ClientConfig) { c.ReadConcern = rc },
+	}
+}
+
+// WithBreaker guard the mongo operations with a circuit breaker
+func WithBreaker(b *Breaker) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.Breaker = b },
+		client: func(c *ClientConfig) { c.Breaker = b },
+	}
+}
+
+// WithLogger set the logger of the store
+func WithLogger(l Logger) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.Logger = l },
+		client: func(c *ClientConfig) { c.Logger = l },
+	}
+}
+
+// WithMetrics set the metrics hook of the store
+func WithMetrics(m Metrics) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.Metrics = m },
+		client: func(c *ClientConfig) { c.Metrics = m },
+	}
+}
+
+// WithTracer set the tracer of the store
+func WithTracer(t Tracer) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.Tracer = t },
+		client: func(c *ClientConfig) { c.Tracer = t },
+	}
+}
+
+// WithSlowOpThreshold log the operations taking longer than d
+func WithSlowOpThreshold(d time.Duration) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.SlowOpThreshold = d },
+		client: func(c *ClientConfig) { c.SlowOpThreshold = d },
+	}
+}
+
+// WithFieldNames set the BSON field names of the documents
+func WithFieldNames(fn FieldNames) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.FieldNames = fn },
+		client: func(c *ClientConfig) { c.FieldNames = fn },
+	}
+}
+
+// WithCodec serialize the token payloads with codec, readCodecs decode the payloads written
+// by previously configured codecs(token store only)
+func WithCodec(codec Codec, readCodecs ...Codec) Option {
+	return Option{
+		token: func(c *TokenConfig) {
+			c.Codec = codec
+			c.ReadCodecs = append(c.ReadCodecs, readCodecs...)
+		},
+	}
+}
+
+// WithHashedTokens store the access/refresh tokens as hashes keyed with pepper(may be empty),
+// see MigrateTokenHashes for existing documents(token store only)
+func WithHashedTokens(pepper []byte) Option {
+	return Option{
+		token: func(c *TokenConfig) {
+			c.HashTokens = true
+			c.TokenPepper = pepper
+		},
+	}
+}
+
+// WithEncrypter encrypt the token payloads with e(token store only)
+func WithEncrypter(e Encrypter) Option {
+	return Option{
+		token: func(c *TokenConfig) { c.Encrypter = e },
+	}
+}
+
+// WithHashedSecrets store the client secrets as bcrypt hashes of the given cost, 0 uses bcrypt.DefaultCost(client store only)
+func WithHashedSecrets(cost int) Option {
+	return Option{
+		client: func(c *ClientConfig) {
+			c.HashSecrets = true
+			c.SecretHashCost = cost
+		},
+	}
+}
+
+// WithKeyPrefix prefix the code/access/refresh document keys, e.g. with the environment name(token store only)
+func WithKeyPrefix(prefix string) Option {
+	return Option{
+		token: func(c *TokenConfig) { c.KeyPrefix = prefix },
+	}
+}
+
+// WithSecretEncrypter encrypt the client secrets with e, for deployments which need the original
+// secrets back(client store only)
+func WithSecretEncrypter(e Encrypter) Option {
+	return Option{
+		client: func(c *ClientConfig) { c.Encrypter = e },
+	}
+}
+
+// WithKeyResolver encrypt the token payloads with the key of their tenant(token store only)
+func WithKeyResolver(resolver KeyResolver) Option {
+	return Option{
+		token: func(c *TokenConfig) { c.KeyResolver = resolver },
+	}
+}
+
+// WithPseudonymizedUserIDs store HMAC pseudonyms keyed with key instead of the user ids
+func WithPseudonymizedUserIDs(key []byte) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.UserIDKey = key },
+		client: func(c *ClientConfig) { c.UserIDKey = key },
+	}
+}
+
+// WithTenantResolver route every operation to the database and collection prefix of its tenant
+func WithTenantResolver(resolver TenantResolver) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.TenantResolver = resolver },
+		client: func(c *ClientConfig) { c.TenantResolver = resolver },
+	}
+}
+
+// WithIssuer scope the token documents to issuer(token store only)
+func WithIssuer(issuer string) Option {
+	return Option{
+		token: func(c *TokenConfig) { c.Issuer = issuer },
+	}
+}
+
+// WithCompatibility adjust the stores to the MongoDB compatible service mode
+func WithCompatibility(mode CompatibilityMode) Option {
+	return Option{
+		token:  func(c *TokenConfig) { c.Compatibility = mode },
+		client: func(c *ClientConfig) { c.Compatibility = mode },
+	}
+}
+
+// WithUniqueClientIndexes enforce one client per domain and/or per user id through unique indexes(client store only)
+func WithUniqueClientIndexes(domains, userIDs bool) Option {
+	return Option{
+		client: func(c *ClientConfig) {
+			c.UniqueDomains = domains
+			c.UniqueUserIDs = userIDs
+		},
+	}
+}
+
+// WithExchangeCascade remove the token families exchanged from a removed access or refresh token(token store only)
+func WithExchangeCascade() Option {
+	return Option{
+		token: func(c *TokenConfig) { c.CascadeExchanges = true },
+	}
+}
+
+// WithSingleCollection store the basic, access and refresh documents in the single TokensCName collection(token store only)
+func WithSingleCollection() Option {
+	return Option{
+		token: func(c *TokenConfig) { c.SingleCollection = true },
+	}
+}
+
+// WithoutAccessTokenStorage don't store the access tokens, e.g. JWT access tokens. deny makes RemoveByAccess deny
+// the removed tokens, see IsAccessDenied(token store only)
+func WithoutAccessTokenStorage(deny bool) Option {
+	return Option{
+		token: func(c *TokenConfig) {
+			c.SkipAccessTokenStorage = true
+			c.DenyRemovedAccessTokens = deny
+		},
+	}
+}
+
+// WithMemoryCodes keep up to max authorization codes in process memory, for single instance deployments or
+// sticky sessions only(token store only)
+func WithMemoryCodes(max int) Option {
+	return Option{
+		token: func(c *TokenConfig) { c.MemoryCodes = max },
+	}
+}
+
+// WithReadOnly refuse every write with ErrReadOnlyStore, e.g. on resource servers(token store only)
+func WithReadOnly() Option {
+	return Option{
+		token: func(c *TokenConfig) { c.ReadOnly = true },
+	}
+}
+
+// WithClientIDCollation look the clients up with the collation c, e.g. &options.Collation{Locale: "simple"}
+// for byte by byte comparisons(client store only)
+func WithClientIDCollation(collation *options.Collation) Option {
+	return Option{
+		client: func(c *ClientConfig) { c.Collation = collation },
+	}
+}
+
+// WithRegistry decode the client metadata with the codec registry reg, the one of the database handle(client store only)
+func WithRegistry(reg *bsoncodec.Registry) Option {
+	return Option{
+		client: func(c *ClientConfig) { c.Registry = reg },
+	}
+}
+
+// WithCaseInsensitiveClientIDs find the clients whatever the case of their ID(client store only)
+func WithCaseInsensitiveClientIDs() Option {
+	return Option{
+		client: func(c *ClientConfig) { c.CaseInsensitiveClientIDs = true },
+	}
+}
@@ -0,0 +1,170 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// the functional options override the configuration structs whatever their position
+func TestTokenOptionsPrecedence(t *testing.T) {
+	base := NewDefaultTokenConfig()
+	base.BasicCName = "base_basic"
+	base.ReadTimeout = 5 * time.Second
+	base.WriteTimeout = 5 * time.Second
+
+	for _, opts := range [][]TokenOption{
+		{WithReadTimeout(2 * time.Second), base},
+		{base, WithReadTimeout(2 * time.Second)},
+		{WithReadTimeout(2 * time.Second), WithTokenConfig(base)},
+	} {
+		tcfg := newTokenConfig(opts)
+
+		if tcfg.ReadTimeout != 2*time.Second {
+			t.Errorf("read timeout %v, want the option's 2s", tcfg.ReadTimeout)
+		}
+
+		if tcfg.WriteTimeout != 5*time.Second || tcfg.BasicCName != "base_basic" {
+			t.Errorf("write timeout %v and basic collection %s, want the configuration's", tcfg.WriteTimeout, tcfg.BasicCName)
+		}
+	}
+
+	if base.ReadTimeout != 5*time.Second {
+		t.Fatal("the options modified the configuration struct")
+	}
+}
+
+// the functional options apply in order, the last one wins
+func TestTokenOptionsOrder(t *testing.T) {
+	tcfg := newTokenConfig([]TokenOption{
+		WithOperationTimeout(3 * time.Second),
+		WithWriteTimeout(time.Second),
+		nil,
+		WithCollectionNames(CollectionNames{Basic: "b"}),
+		WithCollectionNames(CollectionNames{Access: "a"}),
+		WithoutTransactions(),
+	})
+
+	if tcfg.ReadTimeout != 3*time.Second || tcfg.WriteTimeout != time.Second {
+		t.Errorf("timeouts %v/%v, want 3s/1s", tcfg.ReadTimeout, tcfg.WriteTimeout)
+	}
+
+	if tcfg.BasicCName != "b" || tcfg.AccessCName != "a" || tcfg.RefreshCName != "oauth2_refresh" {
+		t.Errorf("collections %s/%s/%s", tcfg.BasicCName, tcfg.AccessCName, tcfg.RefreshCName)
+	}
+
+	if !tcfg.DisableTransactions {
+		t.Error("transactions enabled")
+	}
+}
+
+func TestTokenOptionsDefault(t *testing.T) {
+	if tcfg, want := newTokenConfig(nil), NewDefaultTokenConfig(); tcfg.BasicCName != want.BasicCName || tcfg.ReadTimeout != want.ReadTimeout {
+		t.Fatalf("configuration without options %+v, want the default", tcfg)
+	}
+}
+
+func TestClientOptionsPrecedence(t *testing.T) {
+	base := NewDefaultClientConfig()
+	base.ClientsCName = "base_clients"
+	base.ReadTimeout = 5 * time.Second
+
+	for _, opts := range [][]ClientOption{
+		{WithReadTimeout(2 * time.Second), base},
+		{base, WithReadTimeout(2 * time.Second)},
+		{WithReadTimeout(2 * time.Second), WithClientConfig(base)},
+	} {
+		ccfg := newClientConfig(opts)
+
+		if ccfg.ReadTimeout != 2*time.Second || ccfg.ClientsCName != "base_clients" {
+			t.Errorf("read timeout %v and clients collection %s", ccfg.ReadTimeout, ccfg.ClientsCName)
+		}
+	}
+}
+
+// an Option configures both stores, the store specific ones leave the other store alone
+func TestOptionBothStores(t *testing.T) {
+	opts := []Option{WithCollectionNames(CollectionNames{Basic: "b", Clients: "c"}), WithHashedSecrets(0)}
+
+	tcfg := newTokenConfig([]TokenOption{opts[0], opts[1]})
+	ccfg := newClientConfig([]ClientOption{opts[0], opts[1]})
+
+	if tcfg.BasicCName != "b" || ccfg.ClientsCName != "c" {
+		t.Fatalf("collections %s and %s", tcfg.BasicCName, ccfg.ClientsCName)
+	}
+
+	if !ccfg.HashSecrets {
+		t.Fatal("client option not applied")
+	}
+}
+
+// the zero fields of a configuration struct keep their default
+func TestConfigStructKeepsDefaults(t *testing.T) {
+	tcfg := newTokenConfig([]TokenOption{&TokenConfig{BasicCName: "basic", ReadTimeout: time.Second}})
+	want := NewDefaultTokenConfig()
+
+	if tcfg.BasicCName != "basic" || tcfg.ReadTimeout != time.Second {
+		t.Errorf("configured fields %s/%v", tcfg.BasicCName, tcfg.ReadTimeout)
+	}
+
+	if tcfg.AccessCName != want.AccessCName || tcfg.DenylistCName != want.DenylistCName || tcfg.WriteTimeout != want.WriteTimeout {
+		t.Errorf("zero fields %s/%s/%v, want the defaults", tcfg.AccessCName, tcfg.DenylistCName, tcfg.WriteTimeout)
+	}
+
+	ccfg := newClientConfig([]ClientOption{&ClientConfig{ClientsCName: "clients"}})
+
+	if ccfg.ClientsCName != "clients" || ccfg.MaxPageSize != 100 || ccfg.ReadTimeout != 15*time.Second {
+		t.Errorf("client configuration %s/%d/%v", ccfg.ClientsCName, ccfg.MaxPageSize, ccfg.ReadTimeout)
+	}
+}
+
+// the timeout options set 0 to add no timeout
+func TestTimeoutOptionsDisable(t *testing.T) {
+	tcfg := newTokenConfig([]TokenOption{WithReadTimeout(0), &TokenConfig{WriteTimeout: time.Second}, WithWriteTimeout(0)})
+
+	if tcfg.ReadTimeout != 0 || tcfg.WriteTimeout != 0 {
+		t.Fatalf("timeouts %v/%v, want none", tcfg.ReadTimeout, tcfg.WriteTimeout)
+	}
+}
+
+// a configured timeout aborts an operation the server doesn't answer
+func TestReadTimeoutAbortsStalledOperation(t *testing.T) {
+	proxy := newStallProxy(t)
+	ts := testTokenStoreWithDB(proxy.database(t), WithReadTimeout(100*time.Millisecond))
+
+	proxy.stall()
+
+	start := time.Now()
+
+	_, err := ts.GetByAccess(context.Background(), "access")
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("stalled lookup: %v, want context.DeadlineExceeded", err)
+	}
+
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("stalled lookup returned after %v", d)
+	}
+}
+
+// the earlier deadline of the caller wins over the configured timeout
+func TestCallerDeadlineWins(t *testing.T) {
+	proxy := newStallProxy(t)
+	ts := testTokenStoreWithDB(proxy.database(t), WithReadTimeout(time.Minute))
+
+	proxy.stall()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	if _, err := ts.GetByAccess(ctx, "access"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("stalled lookup: %v, want context.DeadlineExceeded", err)
+	}
+
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("stalled lookup returned after %v", d)
+	}
+}
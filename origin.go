@@ -0,0 +1,100 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// field of the CORS origins of the client
+const clientOriginsField = "allowed_origins"
+
+// normalizeOrigin the serialized origin(lowercase scheme and host, port unless the default one) of a URL
+// without path, query or fragment
+func normalizeOrigin(origin string) (string, error) {
+	u, err := url.Parse(origin)
+
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme == "" || u.Host == "" || u.User != nil {
+		return "", errors.New("must be scheme://host[:port]")
+	}
+
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", errors.New("can't have a path, query or fragment")
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+
+	if (scheme == "https" && port == "443") || (scheme == "http" && port == "80") {
+		port = ""
+	}
+
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	if port != "" {
+		host += ":" + port
+	}
+
+	return scheme + "://" + host, nil
+}
+
+// normalizeOrigins the normalized origins, invalid ones are kept as is for validateClient to report
+func normalizeOrigins(origins []string) []string {
+	var out []string
+
+	for _, origin := range origins {
+		if normalized, err := normalizeOrigin(origin); err == nil {
+			origin = normalized
+		}
+
+		out = append(out, origin)
+	}
+
+	return out
+}
+
+// IsOriginAllowed report whether the client allows CORS requests from origin, compared exactly once both are
+// normalized: the case of the scheme and host and a default port don't matter. A missing client returns
+// ErrClientNotFound.
+func (cs *ClientStore) IsOriginAllowed(ctx context.Context, clientID, origin string) (ok bool, err error) {
+	o := cs.op("IsOriginAllowed", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+	o.set("origin", origin)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, clientID, bson.M{clientOriginsField: 1, clientDeletedField: 1, clientStatusField: 1})
+
+		if err != nil {
+			return err
+		}
+
+		normalized, err := normalizeOrigin(origin)
+
+		if err != nil {
+			return nil
+		}
+
+		for _, allowed := range lookupStrings(raw, []string{clientOriginsField}) {
+			if allowed == normalized {
+				ok = true
+			}
+		}
+
+		return nil
+	})
+
+	return
+}
@@ -0,0 +1,106 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestNormalizeOrigin(t *testing.T) {
+	tests := []struct {
+		origin, want string
+		valid        bool
+	}{
+		{"https://app.example.com", "https://app.example.com", true},
+		{"HTTPS://App.Example.COM", "https://app.example.com", true},
+		{"https://app.example.com/", "https://app.example.com", true},
+		{"https://app.example.com:443", "https://app.example.com", true},
+		{"http://app.example.com:80", "http://app.example.com", true},
+		{"http://app.example.com:443", "http://app.example.com:443", true},
+		{"https://app.example.com:8443", "https://app.example.com:8443", true},
+		{"http://[::1]:3000", "http://[::1]:3000", true},
+		{"https://app.example.com/callback", "", false},
+		{"https://app.example.com?x=1", "", false},
+		{"https://user@app.example.com", "", false},
+		{"app.example.com", "", false},
+		{"https://%zz", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeOrigin(tt.origin)
+
+		if tt.valid && (err != nil || got != tt.want) {
+			t.Errorf("%s: %q(%v), want %q", tt.origin, got, err, tt.want)
+		}
+
+		if !tt.valid && err == nil {
+			t.Errorf("%s: %q, want an error", tt.origin, got)
+		}
+	}
+}
+
+func TestIsOriginAllowed(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	origins := []string{"https://App.Example.com:443", "http://localhost:3000"}
+
+	if err := cs.Set(&Client{Client: models.Client{ID: "spa", Domain: "https://app.example.com"}, Public: true, AllowedOrigins: origins}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "legacy", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// stored normalized, and listed
+	infos, _, err := cs.List(ctx, ClientFilter{}, ListOptions{})
+
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("listed %v: %v", infos, err)
+	}
+
+	want := []string{"https://app.example.com", "http://localhost:3000"}
+
+	if got := asClient(infos[1]).GetAllowedOrigins(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("allowed origins %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		id, origin string
+		allowed    bool
+	}{
+		{"spa", "https://app.example.com", true},
+		{"spa", "https://APP.example.com", true},
+		{"spa", "https://app.example.com:443", true},
+		{"spa", "http://localhost:3000", true},
+		{"spa", "http://app.example.com", false},
+		{"spa", "https://app.example.com:8443", false},
+		{"spa", "https://evil.example.com", false},
+		{"spa", "http://localhost", false},
+		{"spa", "https://app.example.com/path", false},
+		{"spa", "null", false},
+		{"legacy", "https://example.com", false},
+	}
+
+	for _, tt := range tests {
+		if ok, err := cs.IsOriginAllowed(ctx, tt.id, tt.origin); err != nil || ok != tt.allowed {
+			t.Errorf("%s %s: %v(%v), want %v", tt.id, tt.origin, ok, err, tt.allowed)
+		}
+	}
+
+	if _, err := cs.IsOriginAllowed(ctx, "missing", "https://app.example.com"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v, want ErrClientNotFound", err)
+	}
+
+	err = cs.Set(&Client{Client: models.Client{ID: "spa", Domain: "https://app.example.com"}, Public: true, AllowedOrigins: []string{"https://app.example.com/callback"}})
+
+	var ve *ClientValidationError
+
+	if !errors.As(err, &ve) || ve.Fields[0].Field != "allowed_origins" {
+		t.Fatalf("origin with a path: %v, want an allowed_origins error", err)
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/Jakkarin/go-oauth2-mongo/otelmongooauth
+
+go 1.14
+
+require (
+	github.com/Jakkarin/go-oauth2-mongo v0.0.0-20261015143823-cfadc0985a68
+	github.com/go-oauth2/oauth2/v4 v4.1.2
+	go.mongodb.org/mongo-driver v1.5.4
+	go.opentelemetry.io/otel v0.20.0
+	go.opentelemetry.io/otel/sdk v0.20.0
+	go.opentelemetry.io/otel/trace v0.20.0
+)
+
+// builds of this repository use the root module next to it, the importers get the required version
+replace github.com/Jakkarin/go-oauth2-mongo => ../
@@ -0,0 +1,93 @@
+// Package otelmongooauth traces the operations of the mongo token and client stores with OpenTelemetry.
+package otelmongooauth
+
+import (
+	"context"
+	"fmt"
+
+	store "github.com/Jakkarin/go-oauth2-mongo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName name of the tracer requested from the provider
+const instrumentationName = "github.com/Jakkarin/go-oauth2-mongo/otelmongooauth"
+
+// storeTypes the type of each store by the name it gives its operations
+var storeTypes = map[string]string{
+	"token":   "TokenStore",
+	"client":  "ClientStore",
+	"consent": "ConsentStore",
+	"device":  "DeviceStore",
+	"key":     "KeyStore",
+	"par":     "PARStore",
+	"state":   "StateStore",
+}
+
+// spanName the name of the span of the store method, a store of unknown type keeps its name
+func spanName(storeName, op string) string {
+	if typ, ok := storeTypes[storeName]; ok {
+		return typ + "." + op
+	}
+
+	return storeName + "." + op
+}
+
+// Tracer OpenTelemetry implementation of the store Tracer hook
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New create a store tracer using tp, a nil provider uses the global one
+func New(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+// Start implement the store Tracer interface, spans are named after the store method(e.g. TokenStore.GetByAccess
+// or ConsentStore.GrantConsent)
+func (t *Tracer) Start(ctx context.Context, storeName, op, collection string) (context.Context, func(map[string]interface{}, error)) {
+	ctx, span := t.tracer.Start(ctx, spanName(storeName, op),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("db.system", "mongodb"),
+			attribute.String("db.mongodb.collection", collection),
+		),
+	)
+
+	return ctx, func(fields map[string]interface{}, err error) {
+		// fields only carry safe identifiers and counts, never raw token values
+		for k, v := range fields {
+			span.SetAttributes(attr("oauth2.mongo."+k, v))
+		}
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		span.End()
+	}
+}
+
+func attr(key string, v interface{}) attribute.KeyValue {
+	switch v := v.(type) {
+	case string:
+		return attribute.String(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	}
+
+	return attribute.String(key, fmt.Sprint(v))
+}
+
+var _ store.Tracer = (*Tracer)(nil)
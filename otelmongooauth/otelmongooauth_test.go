@@ -0,0 +1,227 @@
+package otelmongooauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	store "github.com/Jakkarin/go-oauth2-mongo"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newProvider a tracer provider exporting the ended spans to the returned exporter
+func newProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exp := tracetest.NewInMemoryExporter()
+
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)), exp
+}
+
+// attributes the attributes of span by key
+func attributes(span *sdktrace.SpanSnapshot) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+
+	return attrs
+}
+
+func TestSpan(t *testing.T) {
+	tp, exp := newProvider()
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+
+	ctx, end := New(tp).Start(ctx, "token", "GetByAccess", "oauth2_access")
+
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		t.Fatal("the context doesn't carry the span")
+	}
+
+	end(map[string]interface{}{"basic_id": "b1", "documents": 1}, nil)
+	parent.End()
+
+	spans := exp.GetSpans()
+
+	if len(spans) != 2 {
+		t.Fatalf("%d spans, want 2", len(spans))
+	}
+
+	span := spans[0]
+
+	if span.Name != "TokenStore.GetByAccess" || span.SpanKind != trace.SpanKindInternal {
+		t.Fatalf("span %s(%v)", span.Name, span.SpanKind)
+	}
+
+	if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatal("the span isn't a child of the request span")
+	}
+
+	attrs := attributes(span)
+
+	for key, want := range map[attribute.Key]attribute.Value{
+		"db.system":              attribute.StringValue("mongodb"),
+		"db.mongodb.collection":  attribute.StringValue("oauth2_access"),
+		"oauth2.mongo.basic_id":  attribute.StringValue("b1"),
+		"oauth2.mongo.documents": attribute.IntValue(1),
+	} {
+		if got, ok := attrs[key]; !ok || got != want {
+			t.Errorf("attribute %s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+
+	if span.StatusCode != codes.Unset {
+		t.Fatalf("status %v of a successful operation", span.StatusCode)
+	}
+}
+
+func TestSpanError(t *testing.T) {
+	tp, exp := newProvider()
+
+	_, end := New(tp).Start(context.Background(), "client", "GetByID", "oauth2_clients")
+	end(nil, errors.New("boom"))
+
+	spans := exp.GetSpans()
+
+	if len(spans) != 1 || spans[0].Name != "ClientStore.GetByID" {
+		t.Fatalf("spans %v", spans)
+	}
+
+	if spans[0].StatusCode != codes.Error || spans[0].StatusMessage != "boom" || len(spans[0].MessageEvents) != 1 {
+		t.Fatalf("status %v %q with %d events", spans[0].StatusCode, spans[0].StatusMessage, len(spans[0].MessageEvents))
+	}
+}
+
+func TestSpanNames(t *testing.T) {
+	tp, exp := newProvider()
+	tracer := New(tp)
+
+	for storeName, want := range map[string]string{
+		"token":   "TokenStore.Create",
+		"client":  "ClientStore.Create",
+		"consent": "ConsentStore.Create",
+		"device":  "DeviceStore.Create",
+		"key":     "KeyStore.Create",
+		"par":     "PARStore.Create",
+		"state":   "StateStore.Create",
+		"other":   "other.Create",
+	} {
+		exp.Reset()
+
+		_, end := tracer.Start(context.Background(), storeName, "Create", "c")
+		end(nil, nil)
+
+		if spans := exp.GetSpans(); len(spans) != 1 || spans[0].Name != want {
+			t.Errorf("span of the %s store %v, want %s", storeName, spans, want)
+		}
+	}
+}
+
+// the mongo commands run under the operation span and the raw tokens never become attributes
+func TestStoreSpans(t *testing.T) {
+	uri := os.Getenv("MONGODB_URI")
+
+	if uri == "" {
+		t.Skip("MONGODB_URI not set")
+	}
+
+	var (
+		mu      sync.Mutex
+		parents []trace.SpanID
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetMonitor(&event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			if e.CommandName == "find" {
+				mu.Lock()
+				parents = append(parents, trace.SpanContextFromContext(ctx).SpanID())
+				mu.Unlock()
+			}
+		},
+	}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := client.Database(fmt.Sprintf("oauth2_otel_test_%d", os.Getpid()))
+
+	defer func() {
+		_ = db.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	}()
+
+	tp, exp := newProvider()
+	ts := store.NewTokenStoreWithDB(db, store.WithTracer(New(tp)))
+
+	info := &models.Token{
+		ClientID:        "c",
+		UserID:          "u",
+		Access:          "raw-access-token",
+		AccessCreateAt:  time.Now(),
+		AccessExpiresIn: time.Hour,
+	}
+
+	if err := ts.Create(ctx, info); err != nil {
+		t.Fatal(err)
+	}
+
+	exp.Reset()
+
+	if _, err := ts.GetByAccess(ctx, "raw-access-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exp.GetSpans()
+
+	if len(spans) != 1 || spans[0].Name != "TokenStore.GetByAccess" {
+		t.Fatalf("spans %v", spans)
+	}
+
+	mu.Lock()
+	observed := parents
+	mu.Unlock()
+
+	if len(observed) == 0 {
+		t.Fatal("no find command observed")
+	}
+
+	for _, id := range observed {
+		if id != spans[0].SpanContext.SpanID() {
+			t.Fatal("a find ran outside of the operation span")
+		}
+	}
+
+	for _, kv := range spans[0].Attributes {
+		if strings.Contains(kv.Value.Emit(), "raw-access-token") {
+			t.Fatalf("attribute %s holds the raw token", kv.Key)
+		}
+	}
+
+	// the sub-stores sharing the token store name their own spans
+	exp.Reset()
+
+	if _, err := store.NewConsentStore(ts).ListByUser(ctx, "u"); err != nil {
+		t.Fatal(err)
+	}
+
+	if spans := exp.GetSpans(); len(spans) != 1 || spans[0].Name != "ConsentStore.ListByUser" {
+		t.Fatalf("spans %v", spans)
+	}
+}
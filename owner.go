@@ -0,0 +1,110 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ownerFilter match the clients of the user, pseudonymized like the stored user ids
+func (cs *ClientStore) ownerFilter(userID string) bson.M {
+	return anyOf(aliases(cs.fields().UserID, func(f FieldNames) string { return f.UserID }), cs.userID(userID))
+}
+
+// GetByUserID the active clients owned by the user in ID order, up to limit(0 for all), empty if none
+func (cs *ClientStore) GetByUserID(ctx context.Context, userID string, limit int) (infos []oauth2.ClientInfo, err error) {
+	o := cs.op("GetByUserID", cs.ccfg.ClientsCName)
+	o.set("user_id", cs.userID(userID))
+
+	err = cs.run(ctx, o, func(ctx context.Context) (err error) {
+		if err := requireArg("user ID", userID); err != nil {
+			return err
+		}
+
+		infos, err = cs.findClients(ctx, o, cs.ownerFilter(userID), int64(limit))
+		return
+	})
+
+	return
+}
+
+// CountByUserID the number of active clients owned by the user
+func (cs *ClientStore) CountByUserID(ctx context.Context, userID string) (n int64, err error) {
+	o := cs.op("CountByUserID", cs.ccfg.ClientsCName)
+	o.set("user_id", cs.userID(userID))
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("user ID", userID); err != nil {
+			return err
+		}
+
+		return cs.readHandler(ctx, cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) (err error) {
+			n, err = c.CountDocuments(ctx, active(cs.ownerFilter(userID)))
+			return
+		})
+	})
+
+	return
+}
+
+// TransferOwnership give the client to another user, recording the previous owner and the time on the document.
+// A concurrent change of the owner returns ErrOwnerChanged, a missing client ErrClientNotFound and a new owner
+// at ClientConfig.MaxClientsPerUser a QuotaError.
+func (cs *ClientStore) TransferOwnership(ctx context.Context, clientID, newUserID string) error {
+	o := cs.op("TransferOwnership", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+	o.set("user_id", cs.userID(newUserID))
+
+	return cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		if err := requireArg("user ID", newUserID); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, clientID, nil)
+
+		if err != nil {
+			return err
+		}
+
+		fn := cs.fields()
+		previous := decodeClient(raw, fn).UserID
+		names := aliases(fn.UserID, func(f FieldNames) string { return f.UserID })
+		owned := anyOf(names, previous)
+
+		if previous == "" {
+			// an owner stored as missing or empty
+			owned = anyOf(names, bson.M{"$in": bson.A{"", nil}})
+		}
+
+		now := time.Now()
+		update := bson.M{"$set": bson.M{
+			fn.UserID:                cs.userID(newUserID),
+			clientPreviousOwnerField: previous,
+			clientTransferredAtField: now,
+			clientUpdatedField:       now,
+		}}
+
+		return cs.retryQuota(func() error {
+			return cs.auditedHandler(ctx, o, clientID, func(ctx context.Context, c *mongo.Collection) error {
+				if err := cs.checkQuota(ctx, c, clientID, cs.userID(newUserID)); err != nil {
+					return err
+				}
+
+				res, err := c.UpdateOne(ctx, bson.M{"$and": bson.A{bson.M{"_id": clientID}, owned}}, update, cs.updateOptions())
+
+				if err == nil && res.MatchedCount == 0 {
+					err = ErrOwnerChanged
+				}
+
+				return err
+			})
+		})
+	})
+}
@@ -0,0 +1,140 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestGetByUserID(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if names := indexNames(t, cs); !hasIndex(names, "userid_1__id_1") {
+		t.Fatalf("indexes %v", names)
+	}
+
+	var many []string
+
+	// stored out of order, listed in ID order
+	for i := 4; i >= 0; i-- {
+		id := fmt.Sprintf("many-%d", i)
+		many = append([]string{id}, many...)
+
+		if err := cs.Set(&models.Client{ID: id, Secret: "secret", Domain: "https://example.com", UserID: "many"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cs.Set(&models.Client{ID: "one", Secret: "secret", Domain: "https://example.com", UserID: "one"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		userID string
+		limit  int
+		want   []string
+	}{
+		{"none", 0, []string{}},
+		{"one", 0, []string{"one"}},
+		{"many", 0, many},
+		{"many", 2, many[:2]},
+	}
+
+	for _, tc := range cases {
+		infos, err := cs.GetByUserID(ctx, tc.userID, tc.limit)
+
+		if err != nil || infos == nil || !reflect.DeepEqual(listIDs(infos), tc.want) {
+			t.Errorf("clients of %s up to %d %v: %v, want %v", tc.userID, tc.limit, infos, err, tc.want)
+		}
+	}
+
+	for userID, want := range map[string]int64{"none": 0, "one": 1, "many": 5} {
+		if n, err := cs.CountByUserID(ctx, userID); err != nil || n != want {
+			t.Errorf("%d clients of %s: %v, want %d", n, userID, err, want)
+		}
+	}
+
+	// the disabled clients are left out of both
+	if err := cs.Disable(ctx, "many-0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if infos, err := cs.GetByUserID(ctx, "many", 0); err != nil || !reflect.DeepEqual(listIDs(infos), many[1:]) {
+		t.Fatalf("clients after the disable %v: %v", listIDs(infos), err)
+	}
+
+	if n, err := cs.CountByUserID(ctx, "many"); err != nil || n != 4 {
+		t.Fatalf("%d clients after the disable: %v", n, err)
+	}
+}
+
+func TestTransferOwnership(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.MaxClientsPerUser = 2
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+
+	for _, c := range []*models.Client{
+		{ID: "leaving-1", UserID: "alice"},
+		{ID: "leaving-2", UserID: "alice"},
+		{ID: "owned", UserID: "bob"},
+	} {
+		c.Secret, c.Domain = "secret", "https://example.com"
+
+		if err := cs.Set(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before := time.Now()
+
+	if err := cs.TransferOwnership(ctx, "leaving-1", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := cs.GetByID(ctx, "leaving-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := asClient(info); c.UserID != "bob" || c.PreviousUserID != "alice" || c.TransferredAt.Before(before.Truncate(time.Millisecond)) {
+		t.Fatalf("transferred client %+v", c)
+	}
+
+	if n, err := cs.CountByUserID(ctx, "alice"); err != nil || n != 1 {
+		t.Fatalf("clients left to the previous owner %d: %v", n, err)
+	}
+
+	// bob is at the quota
+	var qe *QuotaError
+
+	if err := cs.TransferOwnership(ctx, "leaving-2", "bob"); !errors.As(err, &qe) || !errors.Is(err, ErrQuotaExceeded) || qe.Max != 2 {
+		t.Fatalf("transfer above the quota: %v, want a QuotaError", err)
+	}
+
+	if info, err := cs.GetByID(ctx, "leaving-2"); err != nil || info.GetUserID() != "alice" {
+		t.Fatalf("rejected transfer changed the owner: %v, %v", info, err)
+	}
+
+	// a client already owned by the user doesn't count against the quota
+	if err := cs.TransferOwnership(ctx, "leaving-1", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.TransferOwnership(ctx, "missing", "bob"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("unknown client: %v, want ErrClientNotFound", err)
+	}
+
+	for _, args := range [][2]string{{"", "bob"}, {"owned", ""}} {
+		if err := cs.TransferOwnership(ctx, args[0], args[1]); !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("transfer %q to %q: %v, want ErrInvalidArgument", args[0], args[1], err)
+		}
+	}
+}
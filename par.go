@@ -0,0 +1,127 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fields of the pushed authorization request documents, keyed by request_uri
+const (
+	parPayloadField = "payload"
+	parClientField  = "client_id"
+	parCreatedField = "created_at"
+	parExpiresField = "expires_at"
+)
+
+// PARStore the RFC 9126 pushed authorization requests, kept until the authorization endpoint consumes their
+// request_uri once. It shares the connection, configuration and hooks of the token store it is created from,
+// and a TTL index on its collection(TokenConfig.PARCName) removes the requests never consumed.
+type PARStore struct {
+	ts *TokenStore
+}
+
+// NewPARStore create the pushed authorization request store of ts and its TTL index, unless a TenantResolver is
+// configured: call EnsureIndexes then for every tenant
+func NewPARStore(ts *TokenStore) *PARStore {
+	s := &PARStore{ts: ts}
+	ts.initCollectionIndexes(ts.tcfg.PARCName, s.indexes())
+
+	return s
+}
+
+func (s *PARStore) indexes() []mongo.IndexModel {
+	return []mongo.IndexModel{expiryIndex(parExpiresField)}
+}
+
+// EnsureIndexes create the TTL index of the requests collection in the database of the tenant of ctx
+func (s *PARStore) EnsureIndexes(ctx context.Context) error {
+	return s.ts.routedIndexes(ctx, s.ts.tcfg.PARCName, s.indexes())
+}
+
+func (s *PARStore) op(name string) *operation {
+	return newOperation("par", name, s.ts.tcfg.PARCName)
+}
+
+// Save store the payload of the authorization request pushed by the client under requestURI until expiresAt.
+// An existing requestURI returns a DuplicateKeyError.
+func (s *PARStore) Save(ctx context.Context, requestURI string, payload []byte, clientID string, expiresAt time.Time) error {
+	o := s.op("Save")
+	o.set("client_id", clientID)
+	o.sensitive(requestURI)
+
+	return s.ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("request_uri", requestURI); err != nil {
+			return err
+		}
+
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		if expiresAt.IsZero() {
+			return fmt.Errorf("%w: pushed authorization request without expiry", ErrInvalidArgument)
+		}
+
+		return s.ts.colHandler(ctx, s.ts.tcfg.PARCName, func(ctx context.Context, c *mongo.Collection) error {
+			_, err := c.InsertOne(ctx, bson.D{
+				{Key: "_id", Value: requestURI},
+				{Key: parPayloadField, Value: payload},
+				{Key: parClientField, Value: clientID},
+				{Key: parCreatedField, Value: time.Now()},
+				{Key: parExpiresField, Value: expiresAt},
+			})
+
+			if mongo.IsDuplicateKeyError(err) {
+				return &DuplicateKeyError{Field: "_id", Index: "_id_", Err: err}
+			}
+
+			return err
+		})
+	})
+}
+
+// Consume remove the request of requestURI and return its payload and client ID, a single atomic FindOneAndDelete
+// so concurrent authorizations can't both use it. An unknown or consumed requestURI returns ErrRequestURINotFound,
+// an expired one ErrRequestURIExpired.
+func (s *PARStore) Consume(ctx context.Context, requestURI string) (payload []byte, clientID string, err error) {
+	o := s.op("Consume")
+	o.sensitive(requestURI)
+
+	err = s.ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("request_uri", requestURI); err != nil {
+			return err
+		}
+
+		return s.ts.colHandler(ctx, s.ts.tcfg.PARCName, func(ctx context.Context, c *mongo.Collection) error {
+			raw, err := c.FindOneAndDelete(ctx, bson.M{"_id": requestURI}).DecodeBytes()
+
+			if err == mongo.ErrNoDocuments {
+				return errRequestURINotFound
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if !time.Now().Before(lookupTime(raw, []string{parExpiresField})) {
+				return errRequestURIExpired
+			}
+
+			payload = lookupBinary(raw, []string{parPayloadField})
+			clientID = lookupString(raw, []string{parClientField})
+			o.set("client_id", clientID)
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		payload, clientID = nil, ""
+	}
+
+	return
+}
@@ -0,0 +1,143 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const testRequestURI = "urn:ietf:params:oauth:request_uri:6esc_11ACC5bwc014ltc14eY22c"
+
+func TestPARStore(t *testing.T) {
+	ts := newTestTokenStore(t, WithCollectionNames(CollectionNames{PAR: "pushed"}))
+	s := NewPARStore(ts)
+	ctx := context.Background()
+	payload := []byte("response_type=code&client_id=s6BhdRkqt3&state=af0ifjsldkj")
+
+	if err := s.Save(ctx, testRequestURI, payload, "s6BhdRkqt3", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := ts.Database().Collection("pushed").CountDocuments(ctx, bson.M{}); err != nil || n != 1 {
+		t.Fatalf("%d requests in the configured collection: %v", n, err)
+	}
+
+	// a request_uri is never reused
+	var de *DuplicateKeyError
+
+	if err := s.Save(ctx, testRequestURI, payload, "other", time.Now().Add(time.Minute)); !errors.As(err, &de) {
+		t.Fatalf("saved again: %v, want a DuplicateKeyError", err)
+	}
+
+	got, clientID, err := s.Consume(ctx, testRequestURI)
+
+	if err != nil || string(got) != string(payload) || clientID != "s6BhdRkqt3" {
+		t.Fatalf("consumed %q of %q: %v", got, clientID, err)
+	}
+
+	// single-use
+	got, clientID, err = s.Consume(ctx, testRequestURI)
+
+	if !errors.Is(err, ErrRequestURINotFound) || errors.Is(err, ErrRequestURIExpired) || got != nil || clientID != "" {
+		t.Fatalf("consumed again %q of %q: %v, want ErrRequestURINotFound", got, clientID, err)
+	}
+
+	// the request_uri, a bearer reference to the request, isn't in the errors
+	if strings.Contains(err.Error(), testRequestURI) {
+		t.Fatalf("error %q with the request_uri", err)
+	}
+
+	for name, err := range map[string]error{
+		"without request_uri": s.Save(ctx, "", payload, "c", time.Now().Add(time.Minute)),
+		"without client":      s.Save(ctx, "urn:other", payload, "", time.Now().Add(time.Minute)),
+		"without expiry":      s.Save(ctx, "urn:other", payload, "c", time.Time{}),
+		"consume without request_uri": func() error {
+			_, _, err := s.Consume(ctx, "")
+			return err
+		}(),
+	} {
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("%s: %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
+
+func TestPARConsumeConcurrent(t *testing.T) {
+	ts := newTestTokenStore(t)
+	requireAtomicFindAndModify(t, ts.Database())
+
+	s := NewPARStore(ts)
+	ctx := context.Background()
+
+	if err := s.Save(ctx, testRequestURI, []byte("payload"), "c", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	const consumers = 8
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, consumers)
+
+	for i := 0; i < consumers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, _, err := s.Consume(ctx, testRequestURI)
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	consumed := 0
+
+	for err := range errs {
+		switch {
+		case err == nil:
+			consumed++
+		case !errors.Is(err, ErrRequestURINotFound):
+			t.Fatal(err)
+		}
+	}
+
+	if consumed != 1 {
+		t.Fatalf("consumed %d times", consumed)
+	}
+}
+
+func TestPARExpiry(t *testing.T) {
+	ts := newTestTokenStore(t)
+	s := NewPARStore(ts)
+	ctx := context.Background()
+
+	if err := s.Save(ctx, testRequestURI, []byte("payload"), "c", time.Now().Add(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// expired before the TTL monitor removes it
+	payload, clientID, err := s.Consume(ctx, testRequestURI)
+
+	if !errors.Is(err, ErrRequestURIExpired) || !errors.Is(err, ErrRequestURINotFound) || payload != nil || clientID != "" {
+		t.Fatalf("expired request %q of %q: %v, want ErrRequestURIExpired", payload, clientID, err)
+	}
+
+	// and removed by the attempt
+	if _, _, err := s.Consume(ctx, testRequestURI); !errors.Is(err, ErrRequestURINotFound) || errors.Is(err, ErrRequestURIExpired) {
+		t.Fatalf("expired request consumed again: %v, want ErrRequestURINotFound", err)
+	}
+
+	if n, err := ts.Database().Collection(ts.tcfg.PARCName).CountDocuments(ctx, bson.M{}); err != nil || n != 0 {
+		t.Fatalf("%d requests left: %v", n, err)
+	}
+}
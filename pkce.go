@@ -0,0 +1,160 @@
+package mongo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fields of the authorization code basic documents
+const (
+	tokenCodeCreatedField         = "code_created_at"
+	tokenCodeChallengeField       = "code_challenge"
+	tokenCodeChallengeMethodField = "code_challenge_method"
+)
+
+// RFC 7636 code challenge methods
+const (
+	CodeChallengePlain = "plain"
+	CodeChallengeS256  = "S256"
+)
+
+type codeChallengeKey struct{}
+
+type codeChallenge struct {
+	challenge string
+	method    string
+}
+
+// ContextWithCodeChallenge attach the PKCE code challenge of the authorization request and its method(empty for
+// plain) to ctx, the authorization code created with it records them
+func ContextWithCodeChallenge(ctx context.Context, challenge, method string) context.Context {
+	return context.WithValue(ctx, codeChallengeKey{}, codeChallenge{challenge: challenge, method: method})
+}
+
+// codeAttributes the queryable attributes of an authorization code
+type codeAttributes struct {
+	CreatedAt time.Time
+	// base64url SHA-256 of the code challenge, the challenge itself isn't kept
+	Challenge string
+	Method    string
+}
+
+// newCodeAttributes the attributes of the authorization code of info created with ctx
+func newCodeAttributes(ctx context.Context, info oauth2.TokenInfo) codeAttributes {
+	attrs := codeAttributes{CreatedAt: info.GetCodeCreateAt()}
+
+	if attrs.CreatedAt.IsZero() {
+		attrs.CreatedAt = time.Now()
+	}
+
+	if cc, _ := ctx.Value(codeChallengeKey{}).(codeChallenge); cc.challenge != "" {
+		sum := sha256.Sum256([]byte(cc.challenge))
+		attrs.Challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+		attrs.Method = cc.method
+
+		if attrs.Method == "" {
+			attrs.Method = CodeChallengePlain
+		}
+	}
+
+	return attrs
+}
+
+// methodOrNone the challenge method for the operation fields, none without challenge
+func (a codeAttributes) methodOrNone() string {
+	if a.Method == "" {
+		return "none"
+	}
+
+	return a.Method
+}
+
+func (a codeAttributes) doc() bson.D {
+	if a.CreatedAt.IsZero() {
+		return nil
+	}
+
+	doc := bson.D{{Key: tokenCodeCreatedField, Value: a.CreatedAt}}
+
+	if a.Challenge != "" {
+		doc = append(doc,
+			bson.E{Key: tokenCodeChallengeField, Value: a.Challenge},
+			bson.E{Key: tokenCodeChallengeMethodField, Value: a.Method})
+	}
+
+	return doc
+}
+
+func decodeCodeAttributes(raw bson.Raw) codeAttributes {
+	return codeAttributes{
+		CreatedAt: lookupTime(raw, []string{tokenCodeCreatedField}),
+		Challenge: lookupString(raw, []string{tokenCodeChallengeField}),
+		Method:    lookupString(raw, []string{tokenCodeChallengeMethodField}),
+	}
+}
+
+// CodesWithoutPKCE count the stored authorization codes created since without code challenge, e.g. to alert on
+// clients skipping PKCE. Codes are removed once exchanged, the Create operations also report their
+// code_challenge_method(none without challenge) to the logger and tracer.
+func (ts *TokenStore) CodesWithoutPKCE(ctx context.Context, since time.Time) (n int64, err error) {
+	o := ts.op("CodesWithoutPKCE", ts.tcfg.BasicCName)
+
+	err = ts.run(ctx, o, func(ctx context.Context) error {
+		if ts.codes != nil {
+			n = ts.codes.count(ts.memoryCodeMatch(ctx, func(mc memoryCode) bool {
+				return mc.attrs.Challenge == "" && !mc.attrs.CreatedAt.Before(since)
+			}))
+			return nil
+		}
+
+		filter := ts.withKeyPrefix(ts.withIssuer(ctx, bson.M{
+			tokenCodeCreatedField:   bson.M{"$gte": since},
+			tokenCodeChallengeField: bson.M{"$exists": false},
+		}))
+
+		return ts.readHandler(ctx, ts.tcfg.BasicCName, false, func(ctx context.Context, c *mongo.Collection) (err error) {
+			n, err = c.CountDocuments(ctx, filter)
+			return
+		})
+	})
+
+	return
+}
+
+// RecordByCode the record of the authorization code, with its code challenge method, without reading its payload
+func (ts *TokenStore) RecordByCode(ctx context.Context, code string) (record TokenRecord, err error) {
+	o := ts.op("RecordByCode", ts.tcfg.BasicCName)
+	o.sensitive(code)
+
+	err = ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("code", code); err != nil {
+			return err
+		}
+
+		if ts.codes != nil {
+			record, err = ts.memoryCodeRecord(ctx, code)
+			return err
+		}
+
+		return ts.readHandler(ctx, ts.tcfg.BasicCName, true, func(ctx context.Context, c *mongo.Collection) error {
+			find := options.FindOne().SetProjection(ts.payloadProjection())
+			raw, err := c.FindOne(ctx, ts.withIssuer(ctx, bson.M{"_id": ts.codeKey(ctx, code)}), find).DecodeBytes()
+
+			if err != nil {
+				return err
+			}
+
+			record = ts.tokenRecord(raw)
+			return nil
+		})
+	})
+
+	return
+}
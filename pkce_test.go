@@ -0,0 +1,110 @@
+package mongo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCodeChallenges(t *testing.T) {
+	ts := newTestTokenStore(t)
+	ctx := context.Background()
+	since := time.Now().Add(-time.Minute)
+
+	// RFC 7636 appendix B
+	const verifier, challenge = "dBjftJeZ4CVP-1mB0wBJPoZNgaTWgTAwyQUoWXWHKM", "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	codes := []struct {
+		code, challenge, method string
+		// recorded method
+		want string
+	}{
+		{"s256", challenge, CodeChallengeS256, CodeChallengeS256},
+		{"plain", verifier, CodeChallengePlain, CodeChallengePlain},
+		{"plain-default", verifier, "", CodeChallengePlain},
+		{"none", "", "", ""},
+	}
+
+	for _, c := range codes {
+		if err := ts.Create(ContextWithCodeChallenge(ctx, c.challenge, c.method), testCode(c.code)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a code of a request without ContextWithCodeChallenge and a token, which has no challenge
+	if err := ts.Create(ctx, testCode("legacy")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Create(ctx, testToken("access", "refresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range codes {
+		record, err := ts.RecordByCode(ctx, c.code)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var want string
+
+		if c.challenge != "" {
+			sum := sha256.Sum256([]byte(c.challenge))
+			want = base64.RawURLEncoding.EncodeToString(sum[:])
+		}
+
+		if record.CodeChallenge != want || record.CodeChallengeMethod != c.want {
+			t.Fatalf("%s: challenge %q %q, want %q %q", c.code, record.CodeChallenge, record.CodeChallengeMethod, want, c.want)
+		}
+	}
+
+	// the challenges themselves aren't stored
+	var docs []bson.Raw
+
+	cur, err := ts.Collection(CollectionBasic).Find(ctx, bson.M{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cur.All(ctx, &docs); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, doc := range docs {
+		if s := doc.String(); strings.Contains(s, challenge) || strings.Contains(s, verifier) {
+			t.Fatalf("challenge stored in %s", s)
+		}
+	}
+
+	if n, err := ts.CodesWithoutPKCE(ctx, since); err != nil || n != 2 {
+		t.Fatalf("%d codes without PKCE: %v", n, err)
+	}
+
+	if n, err := ts.CodesWithoutPKCE(ctx, time.Now().Add(time.Minute)); err != nil || n != 0 {
+		t.Fatalf("%d codes without PKCE created in the future: %v", n, err)
+	}
+
+	// exchanged codes are no longer counted
+	if _, err := ts.GetByCode(ctx, "none"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.RemoveByCode(ctx, "none"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := ts.CodesWithoutPKCE(ctx, since); err != nil || n != 1 {
+		t.Fatalf("%d codes without PKCE after an exchange: %v", n, err)
+	}
+
+	if _, err := ts.RecordByCode(ctx, "unknown"); err == nil {
+		t.Fatal("record of an unknown code")
+	}
+}
@@ -0,0 +1,80 @@
+package mongo
+
+import (
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// PoolStats connection pool counters collected from driver pool events
+type PoolStats struct {
+	created         int64
+	closed          int64
+	checkedOut      int64
+	checkedIn       int64
+	checkoutFailed  int64
+	checkoutTimeout int64
+}
+
+// PoolSnapshot point in time copy of the pool counters
+type PoolSnapshot struct {
+	Created    int64
+	Closed     int64
+	CheckedOut int64
+	CheckedIn  int64
+	// checkouts which failed for any reason, including timeouts
+	CheckoutFailed int64
+	// checkouts which failed because no connection became available in time
+	CheckoutTimeout int64
+	// connections currently checked out
+	InUse int64
+}
+
+// Monitor create a pool monitor which counts the pool events and forwards them to next(may be nil),
+// the result is meant to be set as Config.PoolMonitor
+func (ps *PoolStats) Monitor(next *event.PoolMonitor) *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			ps.observe(evt)
+
+			if next != nil && next.Event != nil {
+				next.Event(evt)
+			}
+		},
+	}
+}
+
+func (ps *PoolStats) observe(evt *event.PoolEvent) {
+	switch evt.Type {
+	case event.ConnectionCreated:
+		atomic.AddInt64(&ps.created, 1)
+	case event.ConnectionClosed:
+		atomic.AddInt64(&ps.closed, 1)
+	case event.GetSucceeded:
+		atomic.AddInt64(&ps.checkedOut, 1)
+	case event.ConnectionReturned:
+		atomic.AddInt64(&ps.checkedIn, 1)
+	case event.GetFailed:
+		atomic.AddInt64(&ps.checkoutFailed, 1)
+
+		if evt.Reason == event.ReasonTimedOut {
+			atomic.AddInt64(&ps.checkoutTimeout, 1)
+		}
+	}
+}
+
+// Snapshot copy the current counters
+func (ps *PoolStats) Snapshot() PoolSnapshot {
+	s := PoolSnapshot{
+		Created:         atomic.LoadInt64(&ps.created),
+		Closed:          atomic.LoadInt64(&ps.closed),
+		CheckedOut:      atomic.LoadInt64(&ps.checkedOut),
+		CheckedIn:       atomic.LoadInt64(&ps.checkedIn),
+		CheckoutFailed:  atomic.LoadInt64(&ps.checkoutFailed),
+		CheckoutTimeout: atomic.LoadInt64(&ps.checkoutTimeout),
+	}
+
+	s.InUse = s.CheckedOut - s.CheckedIn
+
+	return s
+}
@@ -0,0 +1,90 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestPoolStatsCounters(t *testing.T) {
+	var ps PoolStats
+
+	for _, evt := range []*event.PoolEvent{
+		{Type: event.ConnectionCreated},
+		{Type: event.ConnectionCreated},
+		{Type: event.GetSucceeded},
+		{Type: event.GetSucceeded},
+		{Type: event.ConnectionReturned},
+		{Type: event.GetFailed, Reason: event.ReasonTimedOut},
+		{Type: event.GetFailed, Reason: event.ReasonConnectionErrored},
+		{Type: event.ConnectionClosed},
+	} {
+		ps.observe(evt)
+	}
+
+	want := PoolSnapshot{Created: 2, Closed: 1, CheckedOut: 2, CheckedIn: 1, CheckoutFailed: 2, CheckoutTimeout: 1, InUse: 1}
+
+	if got := ps.Snapshot(); got != want {
+		t.Fatalf("snapshot %+v, want %+v", got, want)
+	}
+}
+
+func TestPoolMonitorUnderLoad(t *testing.T) {
+	var (
+		ps        PoolStats
+		forwarded int64
+	)
+
+	cfg := testConfig(t)
+	cfg.PoolMonitor = ps.Monitor(&event.PoolMonitor{
+		Event: func(*event.PoolEvent) { atomic.AddInt64(&forwarded, 1) },
+	})
+
+	ts := testTokenStore(cfg)
+	defer ts.Close()
+
+	ctx := context.Background()
+
+	if err := ts.Create(ctx, testToken("access", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	before := ps.Snapshot()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := ts.GetByAccess(ctx, "access"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	after := ps.Snapshot()
+
+	if n := after.CheckedOut - before.CheckedOut; n < 20 {
+		t.Fatalf("%d checkouts for 20 lookups", n)
+	}
+
+	if after.InUse != 0 {
+		t.Fatalf("%d connections still checked out", after.InUse)
+	}
+
+	if after.Created == 0 {
+		t.Fatal("no connection creation observed")
+	}
+
+	if atomic.LoadInt64(&forwarded) == 0 {
+		t.Fatal("no event forwarded to the next monitor")
+	}
+}
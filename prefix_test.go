@@ -0,0 +1,158 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// prefixedStores two stores with different key prefixes sharing the collections
+func prefixedStores(t *testing.T, opts ...TokenOption) (staging, prod *TokenStore) {
+	t.Helper()
+
+	db := testDatabase(t)
+
+	return testTokenStoreWithDB(db, append([]TokenOption{WithKeyPrefix("staging:")}, opts...)...),
+		testTokenStoreWithDB(db, append([]TokenOption{WithKeyPrefix("prod:")}, opts...)...)
+}
+
+func TestKeyPrefixIsolation(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []TokenOption
+	}{
+		{"plaintext", nil},
+		{"hashed", []TokenOption{WithHashedTokens(nil)}},
+		{"single collection", []TokenOption{WithSingleCollection()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			staging, prod := prefixedStores(t, tc.opts...)
+
+			// the same values in both environments, with their own user
+			for user, ts := range map[string]*TokenStore{"staging": staging, "prod": prod} {
+				code := testCode("code")
+				code.UserID = user
+
+				token := testToken("access", "refresh")
+				token.UserID = user
+
+				for _, info := range []oauth2.TokenInfo{code, token} {
+					if err := ts.Create(ctx, info); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			requireUser := func(ts *TokenStore, user string) {
+				t.Helper()
+
+				for name, get := range map[string]func() (oauth2.TokenInfo, error){
+					"code":    func() (oauth2.TokenInfo, error) { return ts.GetByCode(ctx, "code") },
+					"access":  func() (oauth2.TokenInfo, error) { return ts.GetByAccess(ctx, "access") },
+					"refresh": func() (oauth2.TokenInfo, error) { return ts.GetByRefresh(ctx, "refresh") },
+				} {
+					info, err := get()
+
+					if err != nil {
+						t.Fatalf("%s of %s: %v", name, user, err)
+					}
+
+					if info.GetUserID() != user {
+						t.Fatalf("%s of %s belongs to %s", name, user, info.GetUserID())
+					}
+				}
+			}
+
+			requireUser(staging, "staging")
+			requireUser(prod, "prod")
+
+			// the removals of one environment leave the other untouched
+			if err := staging.RemoveByCode(ctx, "code"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := staging.RemoveByAccess(ctx, "access"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := staging.RemoveByRefresh(ctx, "refresh"); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, get := range map[string]func() (oauth2.TokenInfo, error){
+				"code":    func() (oauth2.TokenInfo, error) { return staging.GetByCode(ctx, "code") },
+				"access":  func() (oauth2.TokenInfo, error) { return staging.GetByAccess(ctx, "access") },
+				"refresh": func() (oauth2.TokenInfo, error) { return staging.GetByRefresh(ctx, "refresh") },
+			} {
+				if _, err := get(); !errors.Is(err, mongo.ErrNoDocuments) {
+					t.Fatalf("removed %s: %v", name, err)
+				}
+			}
+
+			requireUser(prod, "prod")
+		})
+	}
+}
+
+// the bulk operations only match the documents of their prefix
+func TestKeyPrefixBulkIsolation(t *testing.T) {
+	ctx := context.Background()
+	staging, prod := prefixedStores(t)
+
+	sctx := ContextWithAudience(ContextWithSessionID(ctx, "sid"), "api")
+
+	for _, ts := range []*TokenStore{staging, prod} {
+		if err := ts.Create(sctx, testToken("session", "")); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ts.Create(ContextWithAudience(ctx, "api"), testToken("audience", "")); err != nil {
+			t.Fatal(err)
+		}
+
+		expired := testToken("expired", "")
+		expired.AccessCreateAt = time.Now().Add(-2 * time.Hour)
+
+		if err := ts.Create(ctx, expired); err != nil {
+			t.Fatal(err)
+		}
+
+		code := testCode("code")
+
+		if err := ts.Create(ctx, code); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := staging.CodesWithoutPKCE(ctx, time.Now().Add(-time.Hour)); err != nil || n != 1 {
+		t.Fatalf("%d codes without PKCE: %v", n, err)
+	}
+
+	if n, err := staging.RemoveBySessionID(ctx, "sid"); err != nil || n != 2 {
+		t.Fatalf("%d session documents removed: %v", n, err)
+	}
+
+	if n, err := staging.RemoveAllByAudience(ctx, "api"); err != nil || n != 2 {
+		t.Fatalf("%d audience documents removed: %v", n, err)
+	}
+
+	if _, err := staging.RemoveExpired(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []string{"session", "audience"} {
+		if _, err := prod.GetByAccess(ctx, token); err != nil {
+			t.Fatalf("%s of the other environment: %v", token, err)
+		}
+	}
+
+	if n, err := prod.Collection(CollectionAccess).CountDocuments(ctx, testKindFilter(prod, CollectionAccess, bson.M{tokenPrefixField: "prod:"})); err != nil || n != 3 {
+		t.Fatalf("%d access documents of the other environment left: %v", n, err)
+	}
+}
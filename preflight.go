@@ -0,0 +1,110 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PreflightReport result of a startup preflight
+type PreflightReport struct {
+	// the server answered a ping
+	Connected bool
+	// the deployment supports multi-document transactions(replica set or sharded cluster with sessions)
+	Transactions bool
+	// findings for every collection used by the store
+	Collections []CollectionPreflight
+}
+
+// CollectionPreflight findings for a single collection
+type CollectionPreflight struct {
+	Name     string
+	CanRead  bool
+	CanWrite bool
+	// expected index names which were not found on the collection
+	MissingIndexes []string
+	// first error raised while probing the collection
+	Err error
+}
+
+// OK report whether every preflight check passed
+func (r PreflightReport) OK() bool {
+	if !r.Connected || !r.Transactions {
+		return false
+	}
+
+	for _, c := range r.Collections {
+		if !c.CanRead || !c.CanWrite || len(c.MissingIndexes) > 0 || c.Err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// preflight probe the connectivity, permissions and indexes of the expected collections(name => index names),
+// the error is only set when the server can't be reached at all
+func preflight(ctx context.Context, db *mongo.Database, expected map[string][]string) (PreflightReport, error) {
+	var report PreflightReport
+
+	if err := ping(ctx, db.Client(), nil); err != nil {
+		return report, err
+	}
+
+	report.Connected = true
+	report.Transactions = supportsTransactions(ctx, db.Client())
+
+	for _, name := range sortedNames(expected) {
+		report.Collections = append(report.Collections, preflightCollection(ctx, db, name, expected[name]))
+	}
+
+	return report, nil
+}
+
+func preflightCollection(ctx context.Context, db *mongo.Database, name string, indexes []string) CollectionPreflight {
+	cp := CollectionPreflight{Name: name}
+	c := db.Collection(name)
+
+	// the probe document can never collide with a token or client id
+	probeID := "__preflight__" + primitive.NewObjectID().Hex()
+
+	if _, err := c.InsertOne(ctx, bson.M{"_id": probeID}); err != nil {
+		cp.Err = err
+	} else {
+		cp.CanWrite = true
+	}
+
+	err := c.FindOne(ctx, bson.M{"_id": probeID}).Err()
+
+	if err == nil || (err == mongo.ErrNoDocuments && !cp.CanWrite) {
+		cp.CanRead = true
+	} else if cp.Err == nil {
+		cp.Err = err
+	}
+
+	if cp.CanWrite {
+		if _, err := c.DeleteOne(ctx, bson.M{"_id": probeID}); err != nil {
+			cp.CanWrite = false
+
+			if cp.Err == nil {
+				cp.Err = err
+			}
+		}
+	}
+
+	ch := checkCollection(ctx, db, name, indexes)
+	cp.MissingIndexes = ch.MissingIndexes
+
+	if cp.Err == nil {
+		cp.Err = ch.Err
+	}
+
+	return cp
+}
+
+// supportsTransactions report whether the deployment supports multi-document transactions
+func supportsTransactions(ctx context.Context, client *mongo.Client) bool {
+	return detectCapabilities(ctx, client).Transactions
+}
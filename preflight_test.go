@@ -0,0 +1,136 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestPreflight(t *testing.T) {
+	ctx := context.Background()
+
+	cs := newTestClientStore(t)
+
+	report, err := cs.Preflight(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Connected || report.Transactions != cs.Capabilities().Transactions {
+		t.Fatalf("report %+v, capabilities %+v", report, cs.Capabilities())
+	}
+
+	if len(report.Collections) != 1 {
+		t.Fatalf("%d collections probed, want 1", len(report.Collections))
+	}
+
+	if c := report.Collections[0]; !c.CanRead || !c.CanWrite || len(c.MissingIndexes) > 0 || c.Err != nil {
+		t.Fatalf("clients collection %+v", c)
+	}
+
+	// the probe documents are removed
+	n, err := cs.conns.database().Collection(cs.ccfg.ClientsCName).CountDocuments(ctx, bson.M{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 0 {
+		t.Fatalf("%d probe documents left", n)
+	}
+}
+
+func TestPreflightMissingIndex(t *testing.T) {
+	ctx := context.Background()
+
+	cs := newTestClientStore(t)
+	name := cs.fields().Domain + "_1"
+
+	if _, err := cs.conns.database().Collection(cs.ccfg.ClientsCName).Indexes().DropOne(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := cs.Preflight(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.OK() {
+		t.Fatal("missing index passed the preflight")
+	}
+
+	if c := report.Collections[0]; len(c.MissingIndexes) != 1 || c.MissingIndexes[0] != name {
+		t.Fatalf("missing indexes %v, want %s", c.MissingIndexes, name)
+	}
+}
+
+func TestPreflightUnreachable(t *testing.T) {
+	cs := NewClientStoreWithDB(unreachableDatabase(t))
+
+	report, err := cs.Preflight(context.Background())
+
+	if err == nil || report.Connected || report.OK() {
+		t.Fatalf("unreachable server passed the preflight: %+v", report)
+	}
+}
+
+// a user with the read role can read the collections but not write them
+func TestPreflightReadOnlyUser(t *testing.T) {
+	ctx := context.Background()
+
+	db := testDatabase(t)
+
+	// create the collection and its indexes
+	NewClientStoreWithDB(db)
+
+	err := db.RunCommand(ctx, bson.D{
+		{Key: "createUser", Value: "preflight"},
+		{Key: "pwd", Value: "preflight"},
+		{Key: "roles", Value: bson.A{bson.M{"role": "read", "db": db.Name()}}},
+	}).Err()
+
+	if err != nil {
+		t.Skipf("can't create the restricted user: %v", err)
+	}
+
+	t.Cleanup(func() { _ = db.RunCommand(context.Background(), bson.M{"dropUser": "preflight"}).Err() })
+
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(cctx, options.Client().ApplyURI(testURI(t)).SetAuth(options.Credential{
+		Username:   "preflight",
+		Password:   "preflight",
+		AuthSource: db.Name(),
+	}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer client.Disconnect(ctx)
+
+	restricted := NewClientStoreWithSession(client, db.Name())
+
+	report, err := restricted.Preflight(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := report.Collections[0]
+
+	if c.CanWrite {
+		t.Skip("the server doesn't enforce authorization")
+	}
+
+	if !report.Connected || !c.CanRead || c.Err == nil || report.OK() {
+		t.Fatalf("restricted user report %+v", report)
+	}
+}
@@ -0,0 +1,144 @@
+package mongo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// pseudonymPrefix marks the pseudonymized user ids
+const pseudonymPrefix = "hmac-sha256:"
+
+// PseudonymizeUserID the keyed HMAC-SHA256 pseudonym stored instead of userID, the same key and user id
+// always give the same pseudonym so lookups by user hash their input the same way
+func PseudonymizeUserID(key []byte, userID string) string {
+	if userID == "" || strings.HasPrefix(userID, pseudonymPrefix) {
+		return userID
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(userID))
+
+	return pseudonymPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// userID the user id as stored by the client store
+func (cs *ClientStore) userID(userID string) string {
+	if len(cs.ccfg.UserIDKey) == 0 {
+		return userID
+	}
+
+	return PseudonymizeUserID(cs.ccfg.UserIDKey, userID)
+}
+
+// tokenUserField user id of the basic, access and refresh documents(its pseudonym with TokenConfig.UserIDKey),
+// absent for tokens without user
+const tokenUserField = "user_id"
+
+// userID the user id as recorded by the token store
+func (ts *TokenStore) userID(userID string) string {
+	if len(ts.tcfg.UserIDKey) == 0 {
+		return userID
+	}
+
+	return PseudonymizeUserID(ts.tcfg.UserIDKey, userID)
+}
+
+// ListByUserID the records of the codes and token families of the user in basic ID order, up to limit(0 for all),
+// empty if none
+func (ts *TokenStore) ListByUserID(ctx context.Context, userID string, limit int) (records []TokenRecord, err error) {
+	o := ts.op("ListByUserID", ts.tcfg.BasicCName)
+	o.set("user_id", ts.userID(userID))
+
+	err = ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("user ID", userID); err != nil {
+			return err
+		}
+
+		// the driver takes a negative limit as a single batch one
+		if limit < 0 {
+			return fmt.Errorf("%w: negative limit", ErrInvalidArgument)
+		}
+
+		if err := ts.memoryCodesUnsupported(); err != nil {
+			return err
+		}
+
+		filter := ts.withKeyPrefix(ts.withIssuer(ctx, bson.M{tokenUserField: ts.userID(userID)}))
+		// the access/refresh documents of the single collection record the user too
+		if ts.tcfg.SingleCollection {
+			filter[tokenKindField] = bson.M{"$in": bson.A{kindBasic, kindCode}}
+		}
+
+		find := options.Find().SetSort(bson.M{"_id": 1}).SetProjection(ts.payloadProjection()).SetLimit(int64(limit))
+
+		return ts.readHandler(ctx, ts.tcfg.BasicCName, false, func(ctx context.Context, c *mongo.Collection) error {
+			records = nil
+
+			cur, err := c.Find(ctx, filter, find)
+
+			if err != nil {
+				return err
+			}
+
+			defer cur.Close(ctx)
+
+			for cur.Next(ctx) {
+				records = append(records, ts.tokenRecord(cur.Current))
+			}
+
+			o.set("documents", len(records))
+
+			return cur.Err()
+		})
+	})
+
+	return
+}
+
+// RemoveAllByUserID delete the codes and tokens of the user, e.g. on a data-subject deletion request, returning
+// the number of deleted documents and codes kept in memory
+func (ts *TokenStore) RemoveAllByUserID(ctx context.Context, userID string) (n int64, err error) {
+	o := ts.op("RemoveAllByUserID", ts.tcfg.BasicCName)
+	o.set("user_id", ts.userID(userID))
+
+	err = ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("user ID", userID); err != nil {
+			return err
+		}
+
+		if err := ts.writable(); err != nil {
+			return err
+		}
+
+		user := ts.userID(userID)
+		codes := ts.removeMemoryCodes(ctx, func(mc memoryCode) bool { return mc.userID == user })
+
+		return ts.dbHandler(ctx, func(ctx context.Context, d routedDB) error {
+			n = codes
+
+			for _, name := range ts.tokenCNames() {
+				res, err := d.Collection(name).DeleteMany(ctx, ts.withKeyPrefix(ts.withIssuer(ctx, bson.M{tokenUserField: ts.userID(userID)})))
+
+				if err != nil {
+					return err
+				}
+
+				n += res.DeletedCount
+			}
+
+			o.set("deleted", n)
+
+			return nil
+		})
+	})
+
+	return
+}
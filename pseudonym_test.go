@@ -0,0 +1,185 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestPseudonymizeUserID(t *testing.T) {
+	key := []byte("key")
+	p := PseudonymizeUserID(key, "alice")
+
+	if p == "alice" || p != PseudonymizeUserID(key, "alice") || p == PseudonymizeUserID([]byte("other"), "alice") {
+		t.Fatalf("pseudonym %q", p)
+	}
+
+	if PseudonymizeUserID(key, p) != p || PseudonymizeUserID(key, "") != "" {
+		t.Fatal("pseudonym or empty user id pseudonymized")
+	}
+}
+
+// requireRawIDAbsent fail when a document of the collection records a raw user id
+func requireRawIDAbsent(t *testing.T, db *mongo.Database, name, field string, userIDs ...string) {
+	t.Helper()
+
+	ctx := context.Background()
+	cur, err := db.Collection(name).Find(ctx, bson.M{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer cur.Close(ctx)
+
+	var n int
+
+	for ; cur.Next(ctx); n++ {
+		if got := lookupString(cur.Current, []string{field}); !strings.HasPrefix(got, pseudonymPrefix) {
+			t.Errorf("%s %s %q", name, field, got)
+		}
+
+		// the payload alone may keep it, encrypted
+		for _, userID := range userIDs {
+			if bytes.Contains(cur.Current, []byte(userID)) {
+				t.Errorf("%s document with the raw user id: %s", name, cur.Current)
+			}
+		}
+	}
+
+	if n == 0 {
+		t.Fatalf("no %s document", name)
+	}
+}
+
+func TestPseudonymizedClients(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+	cs := NewClientStoreWithDB(db, WithPseudonymizedUserIDs([]byte("key")))
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "s", UserID: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	requireRawIDAbsent(t, db, "oauth2_clients", "userid", "alice")
+
+	if infos, err := cs.GetByUserID(ctx, "alice", 0); err != nil || len(infos) != 1 || infos[0].GetID() != "c" {
+		t.Fatalf("clients of the user %v: %v", infos, err)
+	}
+
+	if n, err := cs.CountByUserID(ctx, "alice"); err != nil || n != 1 {
+		t.Fatalf("%d clients of the user: %v", n, err)
+	}
+}
+
+func TestPseudonymizedTokens(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []TokenOption
+	}{
+		{"collections", nil},
+		{"single collection", []TokenOption{WithSingleCollection()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := testDatabase(t)
+			opts := append([]TokenOption{WithPseudonymizedUserIDs([]byte("key")), WithEncrypter(testEncrypter(t, "k1"))}, tc.opts...)
+			ts := testTokenStoreWithDB(db, opts...)
+
+			for _, token := range []*models.Token{testToken("a1", "r1"), testToken("a2", "")} {
+				token.UserID = "alice"
+
+				if err := ts.Create(ctx, token); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			code := testCode("code")
+			code.UserID = "alice"
+
+			if err := ts.Create(ctx, code); err != nil {
+				t.Fatal(err)
+			}
+
+			other := testToken("b1", "")
+			other.UserID = "bob"
+
+			if err := ts.Create(ctx, other); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range ts.tokenCNames() {
+				requireRawIDAbsent(t, db, name, tokenUserField, "alice", "bob")
+			}
+
+			// the payload still has the user id
+			if info, err := ts.GetByAccess(ctx, "a1"); err != nil || info.GetUserID() != "alice" {
+				t.Fatalf("token %v: %v", info, err)
+			}
+
+			records, err := ts.ListByUserID(ctx, "alice", 0)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(records) != 3 {
+				t.Fatalf("%d records, want the 2 token families and the code", len(records))
+			}
+
+			for _, r := range records {
+				if r.UserID != PseudonymizeUserID([]byte("key"), "alice") {
+					t.Fatalf("record of %q", r.UserID)
+				}
+			}
+
+			if records, err := ts.ListByUserID(ctx, "alice", 1); err != nil || len(records) != 1 {
+				t.Fatalf("%d records with limit 1: %v", len(records), err)
+			}
+
+			if _, err := ts.ListByUserID(ctx, "alice", -1); !errors.Is(err, ErrInvalidArgument) {
+				t.Fatalf("negative limit: %v, want ErrInvalidArgument", err)
+			}
+
+			n, err := ts.RemoveAllByUserID(ctx, "alice")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the code, the 2 basic, 2 access and 1 refresh documents
+			if n != 6 {
+				t.Fatalf("%d documents removed", n)
+			}
+
+			for _, token := range []string{"a1", "a2"} {
+				if _, err := ts.GetByAccess(ctx, token); !errors.Is(err, mongo.ErrNoDocuments) {
+					t.Fatalf("%s of the removed user: %v", token, err)
+				}
+			}
+
+			if _, err := ts.GetByRefresh(ctx, "r1"); !errors.Is(err, mongo.ErrNoDocuments) {
+				t.Fatalf("refresh token of the removed user: %v", err)
+			}
+
+			if _, err := ts.GetByCode(ctx, "code"); !errors.Is(err, mongo.ErrNoDocuments) {
+				t.Fatalf("code of the removed user: %v", err)
+			}
+
+			if records, err := ts.ListByUserID(ctx, "alice", 0); err != nil || len(records) != 0 {
+				t.Fatalf("records %v of the removed user: %v", records, err)
+			}
+
+			// the other users keep their tokens
+			if _, err := ts.GetByAccess(ctx, "b1"); err != nil {
+				t.Fatalf("token of another user: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// errCodeNamespaceExists returned by create for an existing collection
+const errCodeNamespaceExists = 48
+
+// QuotaError a client write rejected by ClientConfig.MaxClientsPerUser, errors.Is(err, ErrQuotaExceeded) holds
+type QuotaError struct {
+	// pseudonymized like the stored user ids
+	UserID string
+	Max    int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: user %s already owns %d clients", ErrQuotaExceeded, e.UserID, e.Max)
+}
+
+// Is match ErrQuotaExceeded
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// quotaCollection the collection of the per-user lock documents, next to the clients collection c
+func (cs *ClientStore) quotaCollection(c *mongo.Collection) *mongo.Collection {
+	prefix := strings.TrimSuffix(c.Name(), cs.ccfg.ClientsCName)
+	return c.Database().Collection(prefix + cs.ccfg.QuotaCName)
+}
+
+// checkQuota reject giving the client id to the user(stored user id) when the user already owns
+// MaxClientsPerUser other clients, a client keeping its owner is always accepted. Within a transaction
+// the lock document of the user makes the concurrent checks for the same user conflict, so only one commits.
+func (cs *ClientStore) checkQuota(ctx context.Context, c *mongo.Collection, id, userID string) error {
+	max := cs.ccfg.MaxClientsPerUser
+
+	if max <= 0 || userID == "" {
+		return nil
+	}
+
+	names := aliases(cs.fields().UserID, func(f FieldNames) string { return f.UserID })
+	n, err := c.CountDocuments(ctx, bson.M{"$and": bson.A{bson.M{"_id": id}, anyOf(names, userID)}}, cs.countOptions())
+
+	if err != nil || n > 0 {
+		return err
+	}
+
+	if !cs.transactionsDisabled() {
+		_, err := cs.quotaCollection(c).UpdateOne(ctx, bson.M{"_id": userID},
+			bson.M{"$inc": bson.M{"writes": 1}}, options.Update().SetUpsert(true))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err = c.CountDocuments(ctx, anyOf(names, userID))
+
+	if err != nil {
+		return err
+	}
+
+	if n >= int64(max) {
+		return &QuotaError{UserID: userID, Max: max}
+	}
+
+	return nil
+}
+
+// retryQuota run the write again when its transaction lost a conflict on the lock document of a user
+func (cs *ClientStore) retryQuota(fn func() error) error {
+	var err error
+
+	for attempt := 0; attempt < 3; attempt++ {
+		var le interface{ HasErrorLabel(string) bool }
+
+		if err = fn(); cs.ccfg.MaxClientsPerUser <= 0 || !errors.As(err, &le) || !le.HasErrorLabel("TransientTransactionError") {
+			return err
+		}
+	}
+
+	return err
+}
+
+// ensureQuotaCollection create the lock collection up front, servers before 4.4 can't create it in a transaction
+func (cs *ClientStore) ensureQuotaCollection(ctx context.Context, db routedDB) error {
+	if cs.ccfg.MaxClientsPerUser <= 0 || cs.transactionsDisabled() {
+		return nil
+	}
+
+	err := db.RunCommand(ctx, bson.D{{Key: "create", Value: db.prefix + cs.ccfg.QuotaCName}}).Err()
+
+	if cerr, ok := err.(mongo.CommandError); ok && cerr.Code == errCodeNamespaceExists {
+		return nil
+	}
+
+	return err
+}
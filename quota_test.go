@@ -0,0 +1,162 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestClientQuota(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.MaxClientsPerUser = 3
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := cs.Set(&models.Client{ID: fmt.Sprintf("c%d", i), Secret: "secret", Domain: "https://example.com", UserID: "u"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the last one under the quota
+	if _, err := cs.CreateClient(ctx, &models.Client{Domain: "https://example.com", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := map[string]func() error{
+		"set": func() error {
+			return cs.Set(&models.Client{ID: "new", Secret: "secret", Domain: "https://example.com", UserID: "u"})
+		},
+		"create": func() error {
+			_, err := cs.CreateClient(ctx, &models.Client{Domain: "https://example.com", UserID: "u"})
+			return err
+		},
+		"update of another user's client": func() error {
+			if err := cs.Set(&models.Client{ID: "other", Secret: "secret", Domain: "https://example.com", UserID: "v"}); err != nil {
+				return err
+			}
+
+			return cs.Update(ctx, &models.Client{ID: "other", Domain: "https://example.com", UserID: "u"})
+		},
+	}
+
+	for name, write := range writes {
+		var qe *QuotaError
+
+		if err := write(); !errors.As(err, &qe) || !errors.Is(err, ErrQuotaExceeded) || qe.Max != 3 {
+			t.Fatalf("%s above the quota: %v, want a QuotaError", name, err)
+		}
+	}
+
+	// the clients keeping their owner are accepted
+	if err := cs.Set(&models.Client{ID: "c0", Secret: "other", Domain: "https://example.org", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Update(ctx, &models.Client{ID: "c1", Domain: "https://example.org", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a removal frees a slot
+	if err := cs.RemoveByID("c0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writes["set"](); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := cs.CountByUserID(ctx, "u"); err != nil || n != 3 {
+		t.Fatalf("clients of the user %d: %v", n, err)
+	}
+}
+
+// the concurrent registrations at the quota boundary: only one of them gets the last slot
+func TestClientQuotaConcurrent(t *testing.T) {
+	const max, writers = 3, 8
+
+	ccfg := NewDefaultClientConfig()
+	ccfg.MaxClientsPerUser = max
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+
+	requireTransactions(t, cs.Capabilities())
+
+	for i := 0; i < max-1; i++ {
+		if err := cs.Set(&models.Client{ID: fmt.Sprintf("c%d", i), Secret: "secret", Domain: "https://example.com", UserID: "u"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	errs := make([]error, writers)
+	start := make(chan struct{})
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			<-start
+
+			_, errs[i] = cs.CreateClient(ctx, &models.Client{Domain: "https://example.com", UserID: "u"})
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	created := 0
+
+	for _, err := range errs {
+		var le interface{ HasErrorLabel(string) bool }
+
+		// a writer losing every retry of its transaction created nothing either
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, ErrQuotaExceeded), errors.As(err, &le) && le.HasErrorLabel("TransientTransactionError"):
+		default:
+			t.Fatal(err)
+		}
+	}
+
+	if created != 1 {
+		t.Fatalf("%d concurrent registrations took the last slot", created)
+	}
+
+	if n, err := cs.CountByUserID(ctx, "u"); err != nil || n != max {
+		t.Fatalf("clients of the user %d: %v, want %d", n, err, max)
+	}
+}
+
+// a user repeatedly over their quota doesn't open the breaker of every client
+func TestClientQuotaBreaker(t *testing.T) {
+	b := NewBreaker(BreakerConfig{FailureThreshold: 2})
+	ccfg := NewDefaultClientConfig()
+	ccfg.MaxClientsPerUser = 1
+	cs := newTestClientStore(t, ccfg, WithBreaker(b))
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := cs.Set(&models.Client{ID: fmt.Sprintf("c%d", i), Secret: "secret", Domain: "https://example.com", UserID: "u"}); !errors.Is(err, ErrQuotaExceeded) {
+			t.Fatalf("write %d above the quota: %v, want ErrQuotaExceeded", i, err)
+		}
+	}
+
+	if s := b.State(); s != BreakerClosed {
+		t.Fatalf("state %v after the quota rejections, want closed", s)
+	}
+
+	if _, err := cs.GetByID(context.Background(), "c"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,129 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// field of the rate limit of the client
+const clientRateLimitField = "rate_limit"
+
+// RateLimit the token requests a client may make, enforced by the middleware of the token endpoint
+type RateLimit struct {
+	// requests per window
+	Requests int
+	Window   time.Duration
+	// requests allowed above the rate in a burst
+	Burst int
+}
+
+// rateLimitDoc the stored rate limit, the window in milliseconds
+type rateLimitDoc struct {
+	Requests int   `bson:"requests"`
+	WindowMS int64 `bson:"window_ms"`
+	Burst    int   `bson:"burst"`
+}
+
+func (rl *RateLimit) doc() rateLimitDoc {
+	return rateLimitDoc{Requests: rl.Requests, WindowMS: int64(rl.Window / time.Millisecond), Burst: rl.Burst}
+}
+
+// check the reason the rate limit is rejected, empty when valid
+func (rl *RateLimit) check() string {
+	switch {
+	case rl.Requests < 0:
+		return "has negative requests"
+	case rl.Window < 0:
+		return "has a negative window"
+	case rl.Burst < 0:
+		return "has a negative burst"
+	}
+
+	return ""
+}
+
+// decodeRateLimit the rate limit of a stored client, nil when it has none
+func decodeRateLimit(raw bson.Raw) *RateLimit {
+	v, ok := lookup(raw, []string{clientRateLimitField})
+
+	if !ok {
+		return nil
+	}
+
+	doc, ok := v.DocumentOK()
+
+	if !ok {
+		return nil
+	}
+
+	var d rateLimitDoc
+
+	if err := bson.Unmarshal(doc, &d); err != nil {
+		return nil
+	}
+
+	return &RateLimit{Requests: d.Requests, Window: time.Duration(d.WindowMS) * time.Millisecond, Burst: d.Burst}
+}
+
+// SetRateLimit change the rate limit of the client, the zero RateLimit removes it. Negative values return a
+// ClientValidationError and a missing or disabled client ErrClientNotFound.
+func (cs *ClientStore) SetRateLimit(ctx context.Context, clientID string, rl RateLimit) error {
+	o := cs.op("SetRateLimit", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+
+	return cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		update := bson.M{
+			"$set":   bson.M{clientUpdatedField: time.Now()},
+			"$unset": bson.M{clientRateLimitField: ""},
+		}
+
+		if reason := rl.check(); reason != "" {
+			return &ClientValidationError{Fields: []ClientFieldError{{Field: "rate_limit", Reason: reason}}}
+		}
+
+		if rl != (RateLimit{}) {
+			update = bson.M{"$set": bson.M{clientRateLimitField: rl.doc(), clientUpdatedField: time.Now()}}
+		}
+
+		return cs.auditedHandler(ctx, o, clientID, func(ctx context.Context, c *mongo.Collection) error {
+			res, err := c.UpdateOne(ctx, active(bson.M{"_id": clientID}), update, cs.updateOptions())
+
+			if err == nil && res.MatchedCount == 0 {
+				err = errClientNotFound
+			}
+
+			return err
+		})
+	})
+}
+
+// RateLimit the rate limit of the client, nil when it has none, reading only that field for the middleware
+// enforcing it. A missing client returns ErrClientNotFound.
+func (cs *ClientStore) RateLimit(ctx context.Context, clientID string) (rl *RateLimit, err error) {
+	o := cs.op("RateLimit", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, clientID, bson.M{clientRateLimitField: 1, clientDeletedField: 1, clientStatusField: 1})
+
+		if err != nil {
+			return err
+		}
+
+		rl = decodeRateLimit(raw)
+		return nil
+	})
+
+	return
+}
@@ -0,0 +1,135 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestRateLimit(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+
+	cs := NewClientStore(cfg)
+	defer cs.Close()
+
+	ctx := context.Background()
+	limit := &RateLimit{Requests: 60, Window: time.Minute, Burst: 10}
+
+	if err := cs.Set(&Client{Client: models.Client{ID: "limited", Secret: "secret", Domain: "https://example.com"}, RateLimit: limit}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "unlimited", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := cs.GetByID(ctx, "limited")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infos, _, err := cs.List(ctx, ClientFilter{}, ListOptions{})
+
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("listed %v: %v", infos, err)
+	}
+
+	for _, c := range []*Client{asClient(info), asClient(infos[0])} {
+		if c.GetRateLimit() == nil || *c.GetRateLimit() != *limit {
+			t.Fatalf("rate limit %+v, want %+v", c.GetRateLimit(), limit)
+		}
+	}
+
+	if rl := asClient(infos[1]).GetRateLimit(); rl != nil {
+		t.Fatalf("rate limit of a client without one %+v", rl)
+	}
+
+	// the middleware reads only the rate limit
+	rec.reset()
+
+	if rl, err := cs.RateLimit(ctx, "limited"); err != nil || rl == nil || *rl != *limit {
+		t.Fatalf("rate limit %+v(%v), want %+v", rl, err, limit)
+	}
+
+	finds := rec.named("find")
+
+	if len(finds) != 1 {
+		t.Fatalf("%d finds", len(finds))
+	}
+
+	if _, ok := finds[0].Lookup("projection", clientRateLimitField).AsInt64OK(); !ok {
+		t.Fatalf("find without the rate limit projection: %s", finds[0])
+	}
+
+	if _, err := finds[0].Lookup("projection").Document().LookupErr("secret"); err == nil {
+		t.Fatalf("find projecting the secret: %s", finds[0])
+	}
+
+	// changed alone, removed by the zero value
+	if err := cs.SetRateLimit(ctx, "unlimited", RateLimit{Requests: 5, Window: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rl, err := cs.RateLimit(ctx, "unlimited"); err != nil || rl == nil || *rl != (RateLimit{Requests: 5, Window: time.Second}) {
+		t.Fatalf("rate limit %+v(%v) after SetRateLimit", rl, err)
+	}
+
+	if err := cs.SetRateLimit(ctx, "limited", RateLimit{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rl, err := cs.RateLimit(ctx, "limited"); err != nil || rl != nil {
+		t.Fatalf("rate limit %+v(%v) after its removal", rl, err)
+	}
+
+	if info, err := cs.GetByID(ctx, "limited"); err != nil || info.GetSecret() != "secret" {
+		t.Fatalf("client changed by SetRateLimit: %v, %v", info, err)
+	}
+
+	if err := cs.SetRateLimit(ctx, "missing", *limit); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v, want ErrClientNotFound", err)
+	}
+
+	if _, err := cs.RateLimit(ctx, "missing"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v, want ErrClientNotFound", err)
+	}
+}
+
+func TestRateLimitValidation(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rl := range []RateLimit{
+		{Requests: -1, Window: time.Minute},
+		{Requests: 60, Window: -time.Minute},
+		{Requests: 60, Window: time.Minute, Burst: -1},
+	} {
+		var ve *ClientValidationError
+
+		if err := cs.SetRateLimit(ctx, "c", rl); !errors.As(err, &ve) || ve.Fields[0].Field != "rate_limit" {
+			t.Fatalf("SetRateLimit %+v: %v, want a rate_limit error", rl, err)
+		}
+
+		rl := rl
+		err := cs.Set(&Client{Client: models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}, RateLimit: &rl})
+
+		if !errors.As(err, &ve) || ve.Fields[0].Field != "rate_limit" {
+			t.Fatalf("Set %+v: %v, want a rate_limit error", rl, err)
+		}
+	}
+
+	if rl, err := cs.RateLimit(ctx, "c"); err != nil || rl != nil {
+		t.Fatalf("rate limit %+v(%v) after the rejected writes", rl, err)
+	}
+}
@@ -0,0 +1,150 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// the writes of the commands reaching the server
+var writeCommands = []string{"insert", "update", "delete", "findAndModify", "createIndexes", "create", "drop", "shardCollection", "commitTransaction"}
+
+func TestReadOnly(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+	enc := WithEncrypter(testEncrypter(t, "k1"))
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+
+	// the data of an authorization server
+	writer := testTokenStore(cfg, enc, WithHashedTokens(nil))
+	defer writer.Close()
+
+	if err := writer.Create(ctx, testCode("code")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Create(ContextWithSessionID(ctx, "sid"), testToken("access", "refresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.DenyJTI(ctx, "jti", expiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewConsentStore(writer).Save(ctx, "u", "c", []string{"read"}, expiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewKeyStore(writer).SaveKey(ctx, "k", testKeyPEM(t, "EC"), "ES256", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewDeviceStore(writer).SaveDeviceAuth(ctx, testDeviceAuth()); err != nil {
+		t.Fatal(err)
+	}
+
+	rec.reset()
+
+	ts := testTokenStore(cfg, enc, WithHashedTokens(nil), WithReadOnly())
+	defer ts.Close()
+
+	consents, keys, states, pars, devices := NewConsentStore(ts), NewKeyStore(ts), NewStateStore(ts), NewPARStore(ts), NewDeviceStore(ts)
+
+	for name, write := range map[string]func() error{
+		"Create":                 func() error { return ts.Create(ctx, testToken("other", "")) },
+		"CreateWithConfirmation": func() error { return ts.CreateWithConfirmation(ctx, testToken("other", ""), Confirmation{JKT: "jkt"}) },
+		"CreateExchanged":        func() error { return ts.CreateExchanged(ctx, testToken("other", ""), "access", "") },
+		"RemoveByCode":           func() error { return ts.RemoveByCode(ctx, "code") },
+		"RemoveByAccess":         func() error { return ts.RemoveByAccess(ctx, "access") },
+		"RemoveByRefresh":        func() error { return ts.RemoveByRefresh(ctx, "refresh") },
+		"RemoveAllByUserID":      func() error { _, err := ts.RemoveAllByUserID(ctx, "u"); return err },
+		"RemoveAllByAudience":    func() error { _, err := ts.RemoveAllByAudience(ctx, "api"); return err },
+		"RemoveBySessionID":      func() error { _, err := ts.RemoveBySessionID(ctx, "sid"); return err },
+		"RemoveExpired":          func() error { _, err := ts.RemoveExpired(ctx); return err },
+		"DenyJTI":                func() error { return ts.DenyJTI(ctx, "other", expiresAt) },
+		"DenyJTIs":               func() error { return ts.DenyJTIs(ctx, []DeniedJTI{{JTI: "other", ExpiresAt: expiresAt}}) },
+		"MigrateTokenHashes":     func() error { _, err := ts.MigrateTokenHashes(ctx, 0); return err },
+		"MigrateSchema":          func() error { _, err := ts.MigrateSchema(ctx, 0); return err },
+		"ReencryptPayloads":      func() error { _, err := ts.ReencryptPayloads(ctx, "k1", "k2", ReencryptOptions{}); return err },
+		"ShardCollections":       func() error { return ts.ShardCollections(ctx) },
+		"EnsureIndexes":          func() error { return ts.EnsureIndexes(ctx) },
+		"ConsentStore.Save":      func() error { return consents.Save(ctx, "u", "other", []string{"read"}, expiresAt) },
+		"ConsentStore.Revoke":    func() error { return consents.Revoke(ctx, "u", "c") },
+		"KeyStore.SaveKey":       func() error { return keys.SaveKey(ctx, "other", testKeyPEM(t, "EC"), "ES256", time.Time{}) },
+		"KeyStore.RetireKey":     func() error { return keys.RetireKey(ctx, "k") },
+		"StateStore.Put":         func() error { return states.Put(ctx, "state", []byte("v"), time.Minute) },
+		"StateStore.Take":        func() error { _, err := states.Take(ctx, "state"); return err },
+		"PARStore.Save":          func() error { return pars.Save(ctx, testRequestURI, []byte("{}"), "c", expiresAt) },
+		"PARStore.Consume":       func() error { _, _, err := pars.Consume(ctx, testRequestURI); return err },
+		"DeviceStore.Save":       func() error { return devices.SaveDeviceAuth(ctx, testDeviceAuth()) },
+		"DeviceStore.Approve":    func() error { return devices.Approve(ctx, testDeviceAuth().UserCode, "u") },
+		"DeviceStore.Consume":    func() error { _, err := devices.Consume(ctx, testDeviceAuth().DeviceCode); return err },
+	} {
+		if err := write(); !errors.Is(err, ErrReadOnlyStore) {
+			t.Errorf("%s: %v, want ErrReadOnlyStore", name, err)
+		}
+	}
+
+	// every read works
+	for name, read := range map[string]func() error{
+		"GetByCode":                func() error { _, err := ts.GetByCode(ctx, "code"); return err },
+		"GetByAccess":              func() error { _, err := ts.GetByAccess(ctx, "access"); return err },
+		"GetByRefresh":             func() error { _, err := ts.GetByRefresh(ctx, "refresh"); return err },
+		"GetConfirmationByRefresh": func() error { _, err := ts.GetConfirmationByRefresh(ctx, "refresh"); return err },
+		"BasicIDByAccess":          func() error { _, err := ts.BasicIDByAccess(ctx, "access"); return err },
+		"RecordByCode":             func() error { _, err := ts.RecordByCode(ctx, "code"); return err },
+		"CodesWithoutPKCE": func() error {
+			if n, err := ts.CodesWithoutPKCE(ctx, time.Now().Add(-time.Hour)); err != nil || n != 1 {
+				return errors.New("codes without PKCE miscounted")
+			}
+			return nil
+		},
+		"ListByUserID": func() error {
+			if records, err := ts.ListByUserID(ctx, "u", 0); err != nil || len(records) != 2 {
+				return errors.New("records miscounted")
+			}
+			return nil
+		},
+		"Introspect": func() error {
+			if res, err := ts.Introspect(ctx, "access", ""); err != nil || !res.Active {
+				return errors.New("inactive token")
+			}
+			return nil
+		},
+		"IsDenied": func() error {
+			if denied, err := ts.IsDenied(ctx, "jti"); err != nil || !denied {
+				return errors.New("jti not denied")
+			}
+			return nil
+		},
+		"ConsentStore.Get":             func() error { _, err := consents.Get(ctx, "u", "c"); return err },
+		"ConsentStore.ListByUser":      func() error { _, err := consents.ListByUser(ctx, "u"); return err },
+		"KeyStore.ActiveKey":           func() error { _, err := keys.ActiveKey(ctx); return err },
+		"KeyStore.AllVerificationKeys": func() error { _, err := keys.AllVerificationKeys(ctx); return err },
+		"DeviceStore.GetByUserCode":    func() error { _, err := devices.GetByUserCode(ctx, testDeviceAuth().UserCode); return err },
+		"DeviceStore.GetByDeviceCode":  func() error { _, err := devices.GetByDeviceCode(ctx, testDeviceAuth().DeviceCode); return err },
+	} {
+		if err := read(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	// nothing but reads reached the server, without transactions
+	for _, name := range writeCommands {
+		if cmds := rec.named(name); len(cmds) != 0 {
+			t.Errorf("%s commands %v", name, cmds)
+		}
+	}
+
+	for _, name := range []string{"find", "aggregate", "count"} {
+		for _, cmd := range rec.named(name) {
+			if _, err := cmd.LookupErr("startTransaction"); err == nil {
+				t.Errorf("%s in a transaction: %s", name, cmd)
+			}
+		}
+	}
+}
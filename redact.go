@@ -0,0 +1,83 @@
+package mongo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// redactedError an error whose message had the sensitive values of the operation replaced,
+// the original error stays available to errors.Is and errors.As
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+// Unwrap the original error, its message may contain sensitive values
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redact the stable replacement of a sensitive value: its first 4 characters(for long enough values)
+// and a short SHA-256 suffix, so the same token can be correlated across records without being revealed
+func redact(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	suffix := "***" + hex.EncodeToString(sum[:4])
+
+	if len(value) <= 12 {
+		return suffix
+	}
+
+	return value[:4] + suffix
+}
+
+// loggableBasicID the basic document id as it may be logged, the ids of the authorization code
+// documents are the codes themselves
+func loggableBasicID(id string) string {
+	if _, err := primitive.ObjectIDFromHex(id); err == nil {
+		return id
+	}
+
+	return redact(id)
+}
+
+// sensitive record values(tokens, codes, secrets) which must never appear in the errors and logs of the operation
+func (o *operation) sensitive(values ...string) {
+	for _, v := range values {
+		if v != "" {
+			o.secrets = append(o.secrets, v)
+		}
+	}
+}
+
+// redact replace the sensitive values of the operation in err
+func (o *operation) redact(err error) error {
+	if len(o.secrets) == 0 {
+		return err
+	}
+
+	msg := err.Error()
+	secrets := append([]string(nil), o.secrets...)
+
+	// longest first so that a value containing another one is replaced whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	redacted := msg
+
+	for _, v := range secrets {
+		redacted = strings.Replace(redacted, v, redact(v), -1)
+	}
+
+	if redacted == msg {
+		return err
+	}
+
+	return &redactedError{msg: redacted, err: err}
+}
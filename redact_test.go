@@ -0,0 +1,132 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRedact(t *testing.T) {
+	long := "abcdefghijklmnopqrstuvwxyz"
+
+	if got := redact(long); !strings.HasPrefix(got, "abcd***") || strings.Contains(got, long[4:]) || got != redact(long) {
+		t.Fatalf("redacted %q as %q", long, got)
+	}
+
+	if got := redact("short"); strings.Contains(got, "shor") || !strings.HasPrefix(got, "***") {
+		t.Fatalf("redacted a short value as %q", got)
+	}
+
+	if redact(long) == redact(long+"x") {
+		t.Fatal("distinct values redacted alike")
+	}
+}
+
+// the error message of the server(E11000 ... dup key: { _id: "..." }) keeps the redacted form of the value
+func TestOperationRedact(t *testing.T) {
+	value := "access-0123456789abcdef"
+	cause := fmt.Errorf("E11000 duplicate key error collection: oauth2.oauth2_access index: _id_ dup key: { _id: %q }", value)
+
+	o := &operation{}
+	o.sensitive(value, "access")
+
+	err := o.redact(cause)
+
+	if strings.Contains(err.Error(), value) || !strings.Contains(err.Error(), redact(value)) {
+		t.Fatalf("redacted error %q", err)
+	}
+
+	if !errors.Is(err, cause) {
+		t.Fatal("redacted error doesn't wrap the original")
+	}
+
+	if other := errors.New("no sensitive value"); o.redact(other) != other {
+		t.Fatal("error without sensitive values replaced")
+	}
+}
+
+// requireRedacted fail when err or the logs contain one of the values
+func requireRedacted(t *testing.T, err error, logs *logRecorder, values ...string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatal("no error")
+	}
+
+	for _, v := range values {
+		if strings.Contains(err.Error(), v) {
+			t.Errorf("error %q contains %q", err, v)
+		}
+
+		if logs.contains(v) {
+			t.Errorf("logs contain %q", v)
+		}
+	}
+}
+
+func TestRedactDuplicateKey(t *testing.T) {
+	ctx := context.Background()
+	logs := &logRecorder{}
+	ts := newTestTokenStore(t, WithLogger(logs))
+
+	code := "code-0123456789abcdef"
+	access, refresh := "access-0123456789abcdef", "refresh-0123456789abcdef"
+
+	for _, tc := range []struct {
+		name   string
+		info   *models.Token
+		values []string
+	}{
+		{"code", testCode(code), []string{code}},
+		{"access token", testToken(access, refresh), []string{access, refresh}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ts.Create(ctx, tc.info); err != nil {
+				t.Fatal(err)
+			}
+
+			err := ts.Create(ctx, tc.info)
+
+			if !mongo.IsDuplicateKeyError(err) {
+				t.Fatalf("duplicate %s: %v", tc.name, err)
+			}
+
+			requireRedacted(t, err, logs, tc.values...)
+		})
+	}
+}
+
+func TestRedactValidation(t *testing.T) {
+	ctx := context.Background()
+	logs := &logRecorder{}
+	ts := newTestTokenStore(t, WithLogger(logs))
+	cs := newTestClientStore(t, WithLogger(logs))
+
+	access := "access-0123456789abcdef"
+	secret := "secret-0123456789abcdef"
+
+	token := testToken(access, "")
+	token.AccessExpiresIn = -time.Hour
+
+	err := ts.Create(ctx, token)
+
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("negative expiry: %v", err)
+	}
+
+	requireRedacted(t, err, logs, access)
+
+	err = cs.SetWithMetadata(ctx, &models.Client{ID: "c", Secret: secret}, map[string]interface{}{"$where": secret})
+
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("invalid metadata: %v", err)
+	}
+
+	requireRedacted(t, err, logs, secret)
+}
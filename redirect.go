@@ -0,0 +1,143 @@
+package mongo
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RedirectMigrationReport outcome of MigrateRedirectURIs
+type RedirectMigrationReport struct {
+	// documents without redirect URIs found
+	Scanned int64
+	// documents given redirect URIs
+	Migrated int64
+}
+
+// redirectURIs the registered redirect URIs of a stored client, the domain for documents stored before them
+func redirectURIs(entity *client) []string {
+	if len(entity.RedirectURIs) > 0 || entity.Domain == "" {
+		return entity.RedirectURIs
+	}
+
+	return []string{entity.Domain}
+}
+
+// splitDomain the comma-separated redirect URIs crammed in a domain
+func splitDomain(domain string) []string {
+	var uris []string
+
+	for _, uri := range strings.Split(domain, ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+
+	return uris
+}
+
+// MatchRedirectURI report whether uri exactly matches(no normalization of trailing slashes, ports or case)
+// one of the registered redirect URIs of the client. A missing client returns ErrClientNotFound.
+func (cs *ClientStore) MatchRedirectURI(ctx context.Context, clientID, uri string) (ok bool, err error) {
+	o := cs.op("MatchRedirectURI", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, clientID, cs.secretProjection())
+
+		if err != nil {
+			return err
+		}
+
+		for _, registered := range redirectURIs(decodeClient(raw, cs.fields())) {
+			if uri != "" && uri == registered {
+				ok = true
+			}
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// MigrateRedirectURIs give the clients stored with a single(or comma-separated) domain their redirect URIs,
+// batchSize documents at a time(The default is 100). The domain keeps the first URI.
+func (cs *ClientStore) MigrateRedirectURIs(ctx context.Context, batchSize int) (report RedirectMigrationReport, err error) {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	name := cs.ccfg.ClientsCName
+	o := cs.op("MigrateRedirectURIs", name)
+	fn := cs.fields()
+	domains := aliases(fn.Domain, func(f FieldNames) string { return f.Domain })
+
+	filter := bson.M{"$and": bson.A{
+		bson.M{clientRedirectField: bson.M{"$exists": false}},
+		anyOf(domains, bson.M{"$gt": ""}),
+	}}
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		defer func() { o.set("documents", report.Migrated) }()
+
+		for {
+			var batch []*client
+
+			err := cs.readHandler(ctx, name, func(ctx context.Context, c *mongo.Collection) error {
+				find := options.Find().SetLimit(int64(batchSize)).SetProjection(cs.secretProjection())
+				cur, err := c.Find(ctx, filter, find)
+
+				if err != nil {
+					return err
+				}
+
+				defer cur.Close(ctx)
+
+				for cur.Next(ctx) {
+					batch = append(batch, decodeClient(cur.Current, fn))
+				}
+
+				return cur.Err()
+			})
+
+			if err != nil || len(batch) == 0 {
+				return err
+			}
+
+			for _, entity := range batch {
+				report.Scanned++
+
+				uris := splitDomain(entity.Domain)
+
+				if len(uris) == 0 {
+					uris = []string{entity.Domain}
+				}
+
+				err := cs.colHandler(ctx, name, func(ctx context.Context, c *mongo.Collection) error {
+					_, err := c.UpdateOne(ctx,
+						bson.M{"_id": entity.ID, clientRedirectField: bson.M{"$exists": false}},
+						bson.M{"$set": bson.M{clientRedirectField: uris, fn.Domain: uris[0]}},
+						cs.updateOptions(),
+					)
+					return err
+				})
+
+				if err != nil {
+					return err
+				}
+
+				report.Migrated++
+			}
+		}
+	})
+
+	return
+}
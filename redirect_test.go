@@ -0,0 +1,99 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMatchRedirectURI(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	uris := []string{"https://app.example.com/cb", "https://staging.example.com/cb", "http://localhost:8080/cb"}
+
+	if err := cs.Set(&Client{Client: models.Client{ID: "c", Secret: "secret"}, RedirectURIs: uris}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the domain keeps the first URI
+	info, err := cs.GetByID(ctx, "c")
+
+	if err != nil || info.GetDomain() != uris[0] || !reflect.DeepEqual(info.(*Client).GetRedirectURIs(), uris) {
+		t.Fatalf("client %+v: %v", info, err)
+	}
+
+	for _, tc := range []struct {
+		name, uri string
+		want      bool
+	}{
+		{"first", "https://app.example.com/cb", true},
+		{"other", "http://localhost:8080/cb", true},
+		{"trailing slash", "https://app.example.com/cb/", false},
+		{"different port", "http://localhost:8081/cb", false},
+		{"default port", "https://app.example.com:443/cb", false},
+		{"case", "https://APP.example.com/cb", false},
+		{"query", "https://app.example.com/cb?next=/", false},
+		{"prefix", "https://app.example.com/c", false},
+		{"scheme", "http://app.example.com/cb", false},
+		{"empty", "", false},
+	} {
+		if ok, err := cs.MatchRedirectURI(ctx, "c", tc.uri); err != nil || ok != tc.want {
+			t.Errorf("%s %q: %v, %v, want %v", tc.name, tc.uri, ok, err, tc.want)
+		}
+	}
+
+	if _, err := cs.MatchRedirectURI(ctx, "missing", uris[0]); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v", err)
+	}
+}
+
+func TestMigrateRedirectURIs(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	// documents stored before the redirect URIs
+	for _, doc := range []bson.M{
+		{"_id": "single", "secret": "secret", "domain": "https://example.com/cb"},
+		{"_id": "crammed", "secret": "secret", "domain": "https://a.example.com/cb, https://b.example.com/cb"},
+		{"_id": "none", "secret": "secret"},
+	} {
+		if _, err := cs.Collection().InsertOne(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the domain of a document not yet migrated is its redirect URI
+	if ok, err := cs.MatchRedirectURI(ctx, "single", "https://example.com/cb"); err != nil || !ok {
+		t.Fatalf("domain before the migration %v: %v", ok, err)
+	}
+
+	report, err := cs.MigrateRedirectURIs(ctx, 1)
+
+	if err != nil || report.Scanned != 2 || report.Migrated != 2 {
+		t.Fatalf("report %+v: %v", report, err)
+	}
+
+	for id, want := range map[string][]string{
+		"single":  {"https://example.com/cb"},
+		"crammed": {"https://a.example.com/cb", "https://b.example.com/cb"},
+	} {
+		info, err := cs.GetByID(ctx, id)
+
+		if err != nil || info.GetDomain() != want[0] || !reflect.DeepEqual(info.(*Client).GetRedirectURIs(), want) {
+			t.Errorf("migrated client %+v: %v", info, err)
+		}
+	}
+
+	if ok, err := cs.MatchRedirectURI(ctx, "crammed", "https://b.example.com/cb"); err != nil || !ok {
+		t.Fatalf("second URI of the crammed domain %v: %v", ok, err)
+	}
+
+	if report, err := cs.MigrateRedirectURIs(ctx, 0); err != nil || report.Scanned != 0 {
+		t.Fatalf("second run %+v: %v", report, err)
+	}
+}
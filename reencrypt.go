@@ -0,0 +1,190 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReencryptOptions options of ReencryptPayloads
+type ReencryptOptions struct {
+	// documents read per batch(The default is 100)
+	BatchSize int
+	// resume after this basic document id, e.g. the LastID of an interrupted run(The default is the start)
+	After string
+	// called after every batch with the progress so far(The default is none)
+	Progress func(ReencryptReport)
+}
+
+// ReencryptReport progress of ReencryptPayloads
+type ReencryptReport struct {
+	// documents encrypted with the old key found
+	Scanned int64
+	// documents now encrypted with the new key
+	Reencrypted int64
+	// documents removed or rewritten concurrently, left untouched
+	Conflicts int64
+	// documents which couldn't be decrypted with the old key
+	Failed int64
+	// id of the last scanned document, to resume from
+	LastID string
+}
+
+// ReencryptPayloads re-encrypt the basic documents encrypted with fromKeyID using toKeyID, which must be
+// the current key of the Encrypter while fromKeyID remains known to it. Documents are processed in _id order
+// and only updated if unchanged since they were read, new writes can continue meanwhile.
+// Payloads encrypted with per-tenant keys(KeyResolver) aren't handled.
+func (ts *TokenStore) ReencryptPayloads(ctx context.Context, fromKeyID, toKeyID string, opts ReencryptOptions) (ReencryptReport, error) {
+	report := ReencryptReport{LastID: opts.After}
+
+	if err := ts.writable(); err != nil {
+		return report, err
+	}
+
+	if ts.tcfg.Encrypter == nil {
+		return report, fmt.Errorf("%w: no encrypter configured", ErrInvalidConfig)
+	}
+
+	if fromKeyID == "" || fromKeyID == toKeyID {
+		return report, fmt.Errorf("%w: invalid key ids %q -> %q", ErrInvalidConfig, fromKeyID, toKeyID)
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+
+	for {
+		var done bool
+
+		o := ts.op("ReencryptPayloads", ts.tcfg.BasicCName)
+
+		err := ts.run(ctx, o, func(ctx context.Context) (err error) {
+			done, err = ts.reencryptBatch(ctx, o, fromKeyID, toKeyID, int64(opts.BatchSize), &report)
+			return
+		})
+
+		if err != nil {
+			return report, err
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(report)
+		}
+
+		if done {
+			return report, nil
+		}
+	}
+}
+
+func (ts *TokenStore) reencryptBatch(ctx context.Context, o *operation, fromKeyID, toKeyID string, batchSize int64, report *ReencryptReport) (bool, error) {
+	fn := ts.fields()
+	keyIDs := aliases(fn.KeyID, func(f FieldNames) string { return f.KeyID })
+	dataNames := aliases(fn.Data, func(f FieldNames) string { return f.Data })
+
+	match := bson.A{}
+
+	for _, name := range keyIDs {
+		match = append(match, bson.M{name: fromKeyID})
+	}
+
+	filter := bson.M{"$or": match}
+
+	if report.LastID != "" {
+		filter["_id"] = bson.M{"$gt": report.LastID}
+	}
+
+	var batch []bson.Raw
+
+	err := ts.readHandler(ctx, ts.tcfg.BasicCName, true, func(ctx context.Context, c *mongo.Collection) error {
+		cur, err := c.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}).SetLimit(batchSize))
+
+		if err != nil {
+			return err
+		}
+
+		defer cur.Close(ctx)
+
+		for cur.Next(ctx) {
+			batch = append(batch, append(bson.Raw(nil), cur.Current...))
+		}
+
+		return cur.Err()
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	var reencrypted int64
+
+	for _, raw := range batch {
+		report.Scanned++
+		report.LastID = lookupString(raw, []string{"_id"})
+
+		keyName, _ := presentName(raw, keyIDs)
+		dataName, ok := presentName(raw, dataNames)
+
+		if !ok {
+			report.Failed++
+			continue
+		}
+
+		data := lookupBinary(raw, []string{dataName})
+		plaintext, err := ts.tcfg.Encrypter.Decrypt(fromKeyID, data)
+
+		if err != nil {
+			report.Failed++
+			ts.logger().Log(ctx, LogWarn, "payload decryption failed", map[string]interface{}{
+				"basic_id": loggableBasicID(report.LastID),
+				"key_id":   fromKeyID,
+				"error":    err.Error(),
+			})
+			continue
+		}
+
+		keyID, ciphertext, err := ts.tcfg.Encrypter.Encrypt(plaintext)
+
+		if err != nil {
+			return false, err
+		}
+
+		if keyID != toKeyID {
+			return false, fmt.Errorf("%w: the current key is %q, not %q", ErrInvalidConfig, keyID, toKeyID)
+		}
+
+		var matched int64
+
+		err = ts.colHandler(ctx, ts.tcfg.BasicCName, func(ctx context.Context, c *mongo.Collection) error {
+			res, err := c.UpdateOne(ctx,
+				bson.M{"_id": report.LastID, keyName: fromKeyID, dataName: data},
+				bson.M{"$set": bson.M{keyName: toKeyID, dataName: ciphertext}},
+			)
+
+			if err == nil {
+				matched = res.MatchedCount
+			}
+
+			return err
+		})
+
+		if err != nil {
+			return false, err
+		}
+
+		if matched == 0 {
+			report.Conflicts++
+			continue
+		}
+
+		reencrypted++
+		report.Reencrypted++
+	}
+
+	o.set("documents", reencrypted)
+
+	return int64(len(batch)) < batchSize, nil
+}
@@ -0,0 +1,106 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestReencryptPayloads(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	before := testTokenStoreWithDB(db, WithEncrypter(testEncrypter(t, "k1")))
+
+	for i := 0; i < 7; i++ {
+		if err := before.Create(ctx, testToken(fmt.Sprintf("access%d", i), fmt.Sprintf("refresh%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := testTokenStoreWithDB(db, WithEncrypter(testEncrypter(t, "k2", "k1")))
+
+	// interrupted after the first batch
+	interrupted, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var batches []ReencryptReport
+
+	report, err := ts.ReencryptPayloads(interrupted, "k1", "k2", ReencryptOptions{BatchSize: 3, Progress: func(r ReencryptReport) {
+		batches = append(batches, r)
+		cancel()
+	}})
+
+	if err == nil {
+		t.Fatal("interrupted run succeeded")
+	}
+
+	if len(batches) != 1 || report.Reencrypted != 3 || report.LastID == "" {
+		t.Fatalf("interrupted run %+v after %d batches", report, len(batches))
+	}
+
+	// resumed where it stopped
+	batches = nil
+
+	resumed, err := ts.ReencryptPayloads(ctx, "k1", "k2", ReencryptOptions{BatchSize: 3, After: report.LastID, Progress: func(r ReencryptReport) {
+		batches = append(batches, r)
+	}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resumed.Scanned != 4 || resumed.Reencrypted != 4 || resumed.Failed != 0 || resumed.Conflicts != 0 {
+		t.Fatalf("resumed run %+v", resumed)
+	}
+
+	for i := 1; i < len(batches); i++ {
+		if batches[i].Scanned < batches[i-1].Scanned {
+			t.Fatalf("progress %+v went backwards", batches)
+		}
+	}
+
+	if n, err := db.Collection("oauth2_basic").CountDocuments(ctx, bson.M{"KeyID": "k1"}); err != nil || n != 0 {
+		t.Fatalf("%d documents of the old key left: %v", n, err)
+	}
+
+	// only the new key is needed afterward
+	after := testTokenStoreWithDB(db, WithEncrypter(testEncrypter(t, "k2")))
+
+	for i := 0; i < 7; i++ {
+		if _, err := after.GetByAccess(ctx, fmt.Sprintf("access%d", i)); err != nil {
+			t.Fatalf("access%d: %v", i, err)
+		}
+
+		if _, err := after.GetByRefresh(ctx, fmt.Sprintf("refresh%d", i)); err != nil {
+			t.Fatalf("refresh%d: %v", i, err)
+		}
+	}
+}
+
+func TestReencryptPayloadsInvalid(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	for name, run := range map[string]func() error{
+		"no encrypter": func() error {
+			_, err := testTokenStoreWithDB(db).ReencryptPayloads(ctx, "k1", "k2", ReencryptOptions{})
+			return err
+		},
+		"same key": func() error {
+			_, err := testTokenStoreWithDB(db, WithEncrypter(testEncrypter(t, "k1"))).ReencryptPayloads(ctx, "k1", "k1", ReencryptOptions{})
+			return err
+		},
+		"empty key": func() error {
+			_, err := testTokenStoreWithDB(db, WithEncrypter(testEncrypter(t, "k1"))).ReencryptPayloads(ctx, "", "k1", ReencryptOptions{})
+			return err
+		},
+	} {
+		if err := run(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// field of the refresh token policy of the client
+const clientRefreshPolicyField = "refresh_policy"
+
+// RefreshPolicy the refresh token handling of a client, clients without one follow the server configuration.
+// Rotation maps to the IsGenerateRefresh and OneTimeUse to the IsRemoveRefreshing of manage.RefreshingConfig.
+type RefreshPolicy struct {
+	// issue a new refresh token with each refresh
+	Rotation bool `bson:"rotation"`
+	// remove the refresh token once used, so it can't be used again
+	OneTimeUse bool `bson:"one_time_use"`
+}
+
+// decodeRefreshPolicy the refresh policy of a stored client, nil when it has none
+func decodeRefreshPolicy(raw bson.Raw) *RefreshPolicy {
+	v, ok := lookup(raw, []string{clientRefreshPolicyField})
+
+	if !ok {
+		return nil
+	}
+
+	doc, ok := v.DocumentOK()
+
+	if !ok {
+		return nil
+	}
+
+	var p RefreshPolicy
+
+	if err := bson.Unmarshal(doc, &p); err != nil {
+		return nil
+	}
+
+	return &p
+}
+
+// RefreshPolicy the refresh token policy of the client, nil when it follows the server configuration.
+// A missing client returns ErrClientNotFound.
+func (cs *ClientStore) RefreshPolicy(ctx context.Context, clientID string) (policy *RefreshPolicy, err error) {
+	o := cs.op("RefreshPolicy", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, clientID, bson.M{clientRefreshPolicyField: 1, clientDeletedField: 1, clientStatusField: 1})
+
+		if err != nil {
+			return err
+		}
+
+		policy = decodeRefreshPolicy(raw)
+		return nil
+	})
+
+	return
+}
+
+// cachedPolicy a policy read by RefreshPolicyResolver
+type cachedPolicy struct {
+	policy  *RefreshPolicy
+	expires time.Time
+}
+
+// RefreshPolicyResolver the refresh token policies of the clients, each kept for a while to spare a lookup
+// per refresh request
+type RefreshPolicyResolver struct {
+	store *ClientStore
+	ttl   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedPolicy
+}
+
+// NewRefreshPolicyResolver create a resolver keeping the policies for ttl(0 reads them every time)
+func NewRefreshPolicyResolver(cs *ClientStore, ttl time.Duration) *RefreshPolicyResolver {
+	return &RefreshPolicyResolver{store: cs, ttl: ttl, cache: make(map[string]cachedPolicy)}
+}
+
+// Policy the refresh token policy of the client, nil when it follows the server configuration
+func (r *RefreshPolicyResolver) Policy(ctx context.Context, clientID string) (*RefreshPolicy, error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	cached, ok := r.cache[clientID]
+	r.mu.Unlock()
+
+	hit := ok && now.Before(cached.expires)
+	r.store.metrics().ObserveCache(CacheRefreshPolicy, hit)
+
+	if hit {
+		return cached.policy, nil
+	}
+
+	policy, err := r.store.RefreshPolicy(ctx, clientID)
+
+	if err != nil || r.ttl <= 0 {
+		return policy, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the expired entries go with the writes, the map never outgrows the clients
+	for id, entry := range r.cache {
+		if !now.Before(entry.expires) {
+			delete(r.cache, id)
+		}
+	}
+
+	r.cache[clientID] = cachedPolicy{policy: policy, expires: now.Add(r.ttl)}
+
+	return policy, nil
+}
+
+// Invalidate forget the cached policy of the client, e.g. after changing it
+func (r *RefreshPolicyResolver) Invalidate(clientID string) {
+	r.mu.Lock()
+	delete(r.cache, clientID)
+	r.mu.Unlock()
+}
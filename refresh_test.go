@@ -0,0 +1,157 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestRefreshPolicy(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := cs.Set(&Client{
+		Client:        models.Client{ID: "third-party", Secret: "secret", Domain: "https://example.com"},
+		RefreshPolicy: &RefreshPolicy{Rotation: true, OneTimeUse: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "first-party", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stored clients keep the policy, the legacy ones have none
+	if policy, err := cs.RefreshPolicy(ctx, "third-party"); err != nil || policy == nil || !policy.Rotation || !policy.OneTimeUse {
+		t.Fatalf("policy %+v: %v", policy, err)
+	}
+
+	if policy, err := cs.RefreshPolicy(ctx, "first-party"); err != nil || policy != nil {
+		t.Fatalf("policy of a client without one %+v: %v", policy, err)
+	}
+
+	if _, err := cs.RefreshPolicy(ctx, "missing"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v, want ErrClientNotFound", err)
+	}
+
+	// the policies drive the refreshes of the token endpoint
+	ts := newTestTokenStore(t)
+	r := NewRefreshPolicyResolver(cs, time.Minute)
+	n := 0
+
+	refresh := func(clientID, token string) (string, error) {
+		if _, err := ts.GetByRefresh(ctx, token); err != nil {
+			return "", err
+		}
+
+		policy, err := r.Policy(ctx, clientID)
+
+		if err != nil {
+			return "", err
+		}
+
+		if policy != nil && policy.OneTimeUse {
+			if err := ts.RemoveByRefresh(ctx, token); err != nil {
+				return "", err
+			}
+		}
+
+		if policy != nil && policy.Rotation {
+			n++
+			next := testToken(fmt.Sprintf("access-%d", n), fmt.Sprintf("refresh-%d", n))
+			next.ClientID = clientID
+
+			return next.Refresh, ts.Create(ctx, next)
+		}
+
+		return token, nil
+	}
+
+	for _, clientID := range []string{"third-party", "first-party"} {
+		info := testToken("access-"+clientID, "refresh-"+clientID)
+		info.ClientID = clientID
+
+		if err := ts.Create(ctx, info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	next, err := refresh("third-party", "refresh-third-party")
+
+	if err != nil || next == "refresh-third-party" {
+		t.Fatalf("refresh token not rotated: %q(%v)", next, err)
+	}
+
+	if _, err := refresh("third-party", "refresh-third-party"); err == nil {
+		t.Fatal("one-time-use refresh token used again")
+	}
+
+	if _, err := refresh("third-party", next); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if next, err := refresh("first-party", "refresh-first-party"); err != nil || next != "refresh-first-party" {
+			t.Fatalf("reusable refresh token %q(%v)", next, err)
+		}
+	}
+}
+
+func TestRefreshPolicyResolver(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	set := func(policy *RefreshPolicy) {
+		t.Helper()
+
+		if err := cs.Set(&Client{Client: models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}, RefreshPolicy: policy}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set(&RefreshPolicy{Rotation: true})
+
+	cached := NewRefreshPolicyResolver(cs, time.Hour)
+	uncached := NewRefreshPolicyResolver(cs, 0)
+
+	for _, r := range []*RefreshPolicyResolver{cached, uncached} {
+		if policy, err := r.Policy(ctx, "c"); err != nil || policy == nil || !policy.Rotation {
+			t.Fatalf("policy %+v: %v", policy, err)
+		}
+	}
+
+	set(&RefreshPolicy{OneTimeUse: true})
+
+	if policy, _ := cached.Policy(ctx, "c"); policy == nil || !policy.Rotation {
+		t.Fatalf("cached policy %+v, want the previous one", policy)
+	}
+
+	if policy, _ := uncached.Policy(ctx, "c"); policy == nil || !policy.OneTimeUse {
+		t.Fatalf("uncached policy %+v, want the new one", policy)
+	}
+
+	cached.Invalidate("c")
+
+	if policy, _ := cached.Policy(ctx, "c"); policy == nil || !policy.OneTimeUse {
+		t.Fatalf("policy after Invalidate %+v, want the new one", policy)
+	}
+
+	// Update removes the policy of a client given without one
+	if err := cs.Update(ctx, &Client{Client: models.Client{ID: "c", Domain: "https://example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	cached.Invalidate("c")
+
+	if policy, err := cached.Policy(ctx, "c"); err != nil || policy != nil {
+		t.Fatalf("policy %+v after its removal: %v", policy, err)
+	}
+
+	if _, err := cached.Policy(ctx, "missing"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v, want ErrClientNotFound", err)
+	}
+}
@@ -0,0 +1,440 @@
+package mongo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RegisteredClientMetadata the client metadata of RFC 7591 dynamic client registration. The redirect URIs, scope,
+// grant and response types, token endpoint authentication method and keys are stored as those of the client, the
+// other fields in its registration subdocument.
+type RegisteredClientMetadata struct {
+	RedirectURIs            []string        `json:"redirect_uris,omitempty" bson:"-"`
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method,omitempty" bson:"-"`
+	GrantTypes              []string        `json:"grant_types,omitempty" bson:"-"`
+	ResponseTypes           []string        `json:"response_types,omitempty" bson:"-"`
+	ClientName              string          `json:"client_name,omitempty" bson:"client_name,omitempty"`
+	ClientURI               string          `json:"client_uri,omitempty" bson:"client_uri,omitempty"`
+	LogoURI                 string          `json:"logo_uri,omitempty" bson:"logo_uri,omitempty"`
+	Scope                   string          `json:"scope,omitempty" bson:"-"`
+	Contacts                []string        `json:"contacts,omitempty" bson:"contacts,omitempty"`
+	TosURI                  string          `json:"tos_uri,omitempty" bson:"tos_uri,omitempty"`
+	PolicyURI               string          `json:"policy_uri,omitempty" bson:"policy_uri,omitempty"`
+	JWKSURI                 string          `json:"jwks_uri,omitempty" bson:"-"`
+	JWKS                    json.RawMessage `json:"jwks,omitempty" bson:"-"`
+	SoftwareID              string          `json:"software_id,omitempty" bson:"software_id,omitempty"`
+	SoftwareVersion         string          `json:"software_version,omitempty" bson:"software_version,omitempty"`
+	// signed JWT the client registered with, kept as given, its software_id and software_version prevail
+	SoftwareStatement string `json:"software_statement,omitempty" bson:"software_statement,omitempty"`
+	// metadata this type doesn't know(extensions, localized names...), kept as is
+	Extra map[string]interface{} `json:"-" bson:"extra,omitempty"`
+}
+
+// registeredMetadata the known RFC 7591 fields, to tell them apart from the extra ones
+type registeredMetadata RegisteredClientMetadata
+
+// MarshalJSON the metadata with the extra fields at the top level
+func (m RegisteredClientMetadata) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(registeredMetadata(m))
+
+	if err != nil || len(m.Extra) == 0 {
+		return data, err
+	}
+
+	fields := make(map[string]interface{})
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	for key, value := range m.Extra {
+		if _, known := fields[key]; !known {
+			fields[key] = value
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON the metadata, the unknown fields are kept in Extra
+func (m *RegisteredClientMetadata) UnmarshalJSON(data []byte) error {
+	var known registeredMetadata
+
+	if err := json.Unmarshal(data, &known); err != nil {
+		return err
+	}
+
+	var fields map[string]interface{}
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	for _, key := range registeredFields {
+		delete(fields, key)
+	}
+
+	known.Extra = nil
+
+	if len(fields) > 0 {
+		known.Extra = fields
+	}
+
+	*m = RegisteredClientMetadata(known)
+
+	return nil
+}
+
+// registeredFields the JSON names of the known metadata fields
+var registeredFields = []string{
+	"redirect_uris", "token_endpoint_auth_method", "grant_types", "response_types", "client_name", "client_uri",
+	"logo_uri", "scope", "contacts", "tos_uri", "policy_uri", "jwks_uri", "jwks", "software_id", "software_version",
+	"software_statement",
+	// the fields of the registration response
+	"client_id", "client_secret", "client_id_issued_at", "client_secret_expires_at",
+	"registration_access_token", "registration_client_uri",
+}
+
+// RegisteredClient the RFC 7591 registration of a client as returned by the registration endpoint,
+// usable as the oauth2.ClientInfo given to Set and CreateClient
+type RegisteredClient struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	// seconds since the epoch
+	ClientIDIssuedAt int64 `json:"client_id_issued_at,omitempty"`
+	// seconds since the epoch, 0 when the secret doesn't expire
+	ClientSecretExpiresAt int64 `json:"client_secret_expires_at"`
+	// RFC 7592 client configuration endpoint and its access token(see IssueRegistrationToken), set by the caller
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri,omitempty"`
+	// owner of the client, not part of the registration response
+	UserID string `json:"-"`
+	RegisteredClientMetadata
+}
+
+// MarshalJSON the registration response
+func (r RegisteredClient) MarshalJSON() ([]byte, error) {
+	data, err := r.RegisteredClientMetadata.MarshalJSON()
+
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{})
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	fields["client_id"] = r.ClientID
+	fields["client_secret_expires_at"] = r.ClientSecretExpiresAt
+
+	if r.ClientSecret != "" {
+		fields["client_secret"] = r.ClientSecret
+	}
+
+	if r.ClientIDIssuedAt != 0 {
+		fields["client_id_issued_at"] = r.ClientIDIssuedAt
+	}
+
+	if r.RegistrationAccessToken != "" {
+		fields["registration_access_token"] = r.RegistrationAccessToken
+	}
+
+	if r.RegistrationClientURI != "" {
+		fields["registration_client_uri"] = r.RegistrationClientURI
+	}
+
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON a registration request or response
+func (r *RegisteredClient) UnmarshalJSON(data []byte) error {
+	var head struct {
+		ClientID              string `json:"client_id"`
+		ClientSecret          string `json:"client_secret"`
+		ClientIDIssuedAt      int64  `json:"client_id_issued_at"`
+		ClientSecretExpiresAt int64  `json:"client_secret_expires_at"`
+
+		RegistrationAccessToken string `json:"registration_access_token"`
+		RegistrationClientURI   string `json:"registration_client_uri"`
+	}
+
+	if err := json.Unmarshal(data, &head); err != nil {
+		return err
+	}
+
+	var meta RegisteredClientMetadata
+
+	if err := meta.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	*r = RegisteredClient{
+		ClientID:              head.ClientID,
+		ClientSecret:          head.ClientSecret,
+		ClientIDIssuedAt:      head.ClientIDIssuedAt,
+		ClientSecretExpiresAt: head.ClientSecretExpiresAt,
+		UserID:                r.UserID,
+
+		RegistrationAccessToken: head.RegistrationAccessToken,
+		RegistrationClientURI:   head.RegistrationClientURI,
+
+		RegisteredClientMetadata: meta,
+	}
+
+	return nil
+}
+
+// GetID client id
+func (r *RegisteredClient) GetID() string {
+	return r.ClientID
+}
+
+// GetSecret client secret
+func (r *RegisteredClient) GetSecret() string {
+	return r.ClientSecret
+}
+
+// GetDomain the first redirect URI
+func (r *RegisteredClient) GetDomain() string {
+	if len(r.RedirectURIs) > 0 {
+		return r.RedirectURIs[0]
+	}
+
+	return ""
+}
+
+// GetUserID user id
+func (r *RegisteredClient) GetUserID() string {
+	return r.UserID
+}
+
+// IsPublic report whether the client registered without authentication at the token endpoint
+func (r *RegisteredClient) IsPublic() bool {
+	return r.TokenEndpointAuthMethod == AuthMethodNone
+}
+
+// GetTokenEndpointAuthMethod the token endpoint authentication method of the registration, empty for
+// client_secret_basic
+func (r *RegisteredClient) GetTokenEndpointAuthMethod() string {
+	return r.TokenEndpointAuthMethod
+}
+
+// GetRegistration the registration metadata
+func (r *RegisteredClient) GetRegistration() *RegisteredClientMetadata {
+	return &r.RegisteredClientMetadata
+}
+
+// Registered the RFC 7591 registration of the client, e.g. to answer the registration request with the client
+// returned by CreateClient. A hashed secret is left out.
+func (c *Client) Registered() *RegisteredClient {
+	r := &RegisteredClient{ClientID: c.ID, UserID: c.UserID}
+
+	if !isSecretHash(c.Secret) {
+		r.ClientSecret = c.Secret
+	}
+
+	if c.Registration != nil {
+		r.RegisteredClientMetadata = *c.Registration
+	}
+
+	r.RedirectURIs = c.RedirectURIs
+	r.Scope = strings.Join(c.Scopes, " ")
+	r.GrantTypes, r.ResponseTypes = c.GrantTypes, c.ResponseTypes
+	r.TokenEndpointAuthMethod = c.GetTokenEndpointAuthMethod()
+	r.JWKS, r.JWKSURI = c.JWKS, c.JWKSURI
+
+	if !c.CreatedAt.IsZero() {
+		r.ClientIDIssuedAt = c.CreatedAt.Unix()
+	}
+
+	if !c.SecretExpiresAt.IsZero() {
+		r.ClientSecretExpiresAt = c.SecretExpiresAt.Unix()
+	}
+
+	return r
+}
+
+// GetRegistration the registration metadata, nil for clients not registered through RFC 7591
+func (c *Client) GetRegistration() *RegisteredClientMetadata {
+	return c.Registration
+}
+
+// validateURIs reject the display URIs which aren't absolute, or https when strict
+func (m *RegisteredClientMetadata) validateURIs(strict bool) error {
+	uris := []struct{ name, value string }{
+		{"client_uri", m.ClientURI},
+		{"logo_uri", m.LogoURI},
+		{"policy_uri", m.PolicyURI},
+		{"tos_uri", m.TosURI},
+	}
+
+	for _, uri := range uris {
+		if uri.value == "" {
+			continue
+		}
+
+		u, err := url.Parse(uri.value)
+
+		if err != nil || !u.IsAbs() || u.Host == "" {
+			return fmt.Errorf("%w: %s %q isn't an absolute URI", ErrInvalidArgument, uri.name, uri.value)
+		}
+
+		if strict && u.Scheme != "https" {
+			return fmt.Errorf("%w: %s %q isn't https", ErrInvalidArgument, uri.name, uri.value)
+		}
+	}
+
+	return nil
+}
+
+// registeredClient client information carrying RFC 7591 metadata
+type registeredClient interface {
+	GetRegistration() *RegisteredClientMetadata
+}
+
+// GetScopes the scopes of the registration
+func (r *RegisteredClient) GetScopes() []string {
+	return strings.Fields(r.Scope)
+}
+
+// GetRedirectURIs the redirect URIs of the registration
+func (r *RegisteredClient) GetRedirectURIs() []string {
+	return r.RedirectURIs
+}
+
+// GetSecretExpiresAt the expiry of the secret, zero when it never expires
+func (r *RegisteredClient) GetSecretExpiresAt() time.Time {
+	if r.ClientSecretExpiresAt == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(r.ClientSecretExpiresAt, 0)
+}
+
+// GetJWKS the JWKS of the registration
+func (r *RegisteredClient) GetJWKS() json.RawMessage {
+	return r.JWKS
+}
+
+// GetJWKSURI the JWKS URI of the registration
+func (r *RegisteredClient) GetJWKSURI() string {
+	return r.JWKSURI
+}
+
+// GetGrantTypes the grant types of the registration
+func (r *RegisteredClient) GetGrantTypes() []string {
+	return r.GrantTypes
+}
+
+// GetResponseTypes the response types of the registration
+func (r *RegisteredClient) GetResponseTypes() []string {
+	return r.ResponseTypes
+}
+
+// registration the metadata of info to store, without the fields stored with the client
+func (cs *ClientStore) registration(info oauth2.ClientInfo) (*RegisteredClientMetadata, error) {
+	rc, ok := info.(registeredClient)
+
+	if !ok || rc.GetRegistration() == nil {
+		return nil, nil
+	}
+
+	reg := *rc.GetRegistration()
+
+	if err := validateMetadataKeys(reg.Extra); err != nil {
+		return nil, err
+	}
+
+	if err := reg.validateURIs(cs.ccfg.RequireHTTPSURIs); err != nil {
+		return nil, err
+	}
+
+	if err := cs.softwareStatement(&reg); err != nil {
+		return nil, err
+	}
+
+	reg.RedirectURIs, reg.Scope, reg.GrantTypes, reg.ResponseTypes = nil, "", nil, nil
+	reg.TokenEndpointAuthMethod, reg.JWKS, reg.JWKSURI = "", nil, ""
+
+	return &reg, nil
+}
+
+// decodeRegistration the registration subdocument, with the fields stored with the client
+func decodeRegistration(raw bson.Raw, entity *client) *RegisteredClientMetadata {
+	v, ok := lookup(raw, []string{clientRegistrationField})
+
+	if !ok {
+		return nil
+	}
+
+	doc, ok := v.DocumentOK()
+
+	if !ok {
+		return nil
+	}
+
+	var reg RegisteredClientMetadata
+
+	if err := bson.Unmarshal(doc, &reg); err != nil {
+		return nil
+	}
+
+	// the extra metadata came from JSON, decoded back to the JSON types rather than bson ones(primitive.D...)
+	if extra, ok := lookup(doc, []string{"extra"}); ok {
+		reg.Extra = nil
+
+		if data, err := bson.MarshalExtJSON(extra.Document(), false, false); err == nil {
+			_ = json.Unmarshal(data, &reg.Extra)
+		}
+	}
+
+	reg.RedirectURIs = entity.RedirectURIs
+	reg.Scope = strings.Join(entity.Scopes, " ")
+	reg.GrantTypes, reg.ResponseTypes = entity.GrantTypes, entity.ResponseTypes
+	reg.TokenEndpointAuthMethod = effectiveAuthMethod(entity.AuthMethod, entity.Public)
+	reg.JWKS, reg.JWKSURI = entity.JWKS, entity.JWKSURI
+
+	return &reg
+}
+
+// display the registration metadata for the consent screen, empty for clients without registration
+func (c *Client) display() RegisteredClientMetadata {
+	if c.Registration == nil {
+		return RegisteredClientMetadata{}
+	}
+
+	return *c.Registration
+}
+
+// GetClientName the name of the client shown to the end user
+func (c *Client) GetClientName() string {
+	return c.display().ClientName
+}
+
+// GetClientURI the home page of the client
+func (c *Client) GetClientURI() string {
+	return c.display().ClientURI
+}
+
+// GetLogoURI the logo of the client
+func (c *Client) GetLogoURI() string {
+	return c.display().LogoURI
+}
+
+// GetPolicyURI the privacy policy of the client
+func (c *Client) GetPolicyURI() string {
+	return c.display().PolicyURI
+}
+
+// GetTosURI the terms of service of the client
+func (c *Client) GetTosURI() string {
+	return c.display().TosURI
+}
@@ -0,0 +1,266 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// registrationRequest the RFC 7591 section 3.1 example, with an extension field
+const registrationRequest = `{
+	"redirect_uris": ["https://client.example.org/callback", "https://client.example.org/callback2"],
+	"client_name": "My Example Client",
+	"client_name#ja-Jpan-JP": "クライアント名",
+	"token_endpoint_auth_method": "client_secret_basic",
+	"grant_types": ["authorization_code", "refresh_token"],
+	"response_types": ["code"],
+	"logo_uri": "https://client.example.org/logo.png",
+	"contacts": ["ve7jtb@example.org", "mary@example.org"],
+	"software_id": "4NRB1-0XZABZI9E6-5SM3R",
+	"scope": "read write",
+	"example_extension_parameter": {"nested": ["a", 1]}
+}`
+
+// registrationResponse the RFC 7591 section 3.2.1 example
+const registrationResponse = `{
+	"client_id": "s6BhdRkqt3",
+	"client_secret": "cf136dc3c1fc93f31185e5885805d",
+	"client_id_issued_at": 2893256800,
+	"client_secret_expires_at": 2893276800,
+	"redirect_uris": ["https://client.example.org/callback"],
+	"grant_types": ["authorization_code", "refresh_token"],
+	"client_name": "My Example Client",
+	"token_endpoint_auth_method": "client_secret_basic",
+	"logo_uri": "https://client.example.org/logo.png",
+	"jwks_uri": "https://client.example.org/my_public_keys.jwks",
+	"example_extension_parameter": "example_value"
+}`
+
+// requireSameJSON fail unless got and want hold the same JSON values
+func requireSameJSON(t *testing.T, got, want []byte) {
+	t.Helper()
+
+	var g, w interface{}
+
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(want, &w); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(g, w) {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestRegistrationJSON(t *testing.T) {
+	var meta RegisteredClientMetadata
+
+	if err := json.Unmarshal([]byte(registrationRequest), &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.ClientName != "My Example Client" || meta.TokenEndpointAuthMethod != "client_secret_basic" ||
+		meta.SoftwareID != "4NRB1-0XZABZI9E6-5SM3R" || meta.Scope != "read write" ||
+		len(meta.RedirectURIs) != 2 || len(meta.GrantTypes) != 2 || len(meta.Contacts) != 2 {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+
+	if len(meta.Extra) != 2 || meta.Extra["client_name#ja-Jpan-JP"] != "クライアント名" ||
+		meta.Extra["example_extension_parameter"] == nil {
+		t.Fatalf("unexpected extra metadata %v", meta.Extra)
+	}
+
+	data, err := json.Marshal(meta)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireSameJSON(t, data, []byte(registrationRequest))
+
+	var rc RegisteredClient
+
+	if err := json.Unmarshal([]byte(registrationResponse), &rc); err != nil {
+		t.Fatal(err)
+	}
+
+	if rc.ClientID != "s6BhdRkqt3" || rc.ClientSecret != "cf136dc3c1fc93f31185e5885805d" ||
+		rc.ClientIDIssuedAt != 2893256800 || rc.ClientSecretExpiresAt != 2893276800 ||
+		rc.JWKSURI != "https://client.example.org/my_public_keys.jwks" {
+		t.Fatalf("unexpected registration %+v", rc)
+	}
+
+	if len(rc.Extra) != 1 || rc.Extra["example_extension_parameter"] != "example_value" {
+		t.Fatalf("response fields taken as extra metadata: %v", rc.Extra)
+	}
+
+	if data, err = json.Marshal(rc); err != nil {
+		t.Fatal(err)
+	}
+
+	requireSameJSON(t, data, []byte(registrationResponse))
+
+	// the core fields of oauth2.ClientInfo
+	if rc.GetID() != rc.ClientID || rc.GetSecret() != rc.ClientSecret {
+		t.Fatalf("client info %q/%q, want %q/%q", rc.GetID(), rc.GetSecret(), rc.ClientID, rc.ClientSecret)
+	}
+}
+
+func TestRegisteredClients(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	var rc RegisteredClient
+
+	if err := json.Unmarshal([]byte(registrationRequest), &rc); err != nil {
+		t.Fatal(err)
+	}
+
+	rc.ClientID, rc.ClientSecret, rc.UserID = "registered", "secret", "u"
+
+	if err := cs.Set(&rc); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := cs.CreateClient(ctx, &RegisteredClient{RegisteredClientMetadata: rc.RegisteredClientMetadata})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"registered", created.GetID()} {
+		info, err := cs.GetByID(ctx, id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reg := asClient(info).GetRegistration()
+
+		if reg == nil {
+			t.Fatalf("%s: registration not returned", id)
+		}
+
+		data, err := json.Marshal(reg)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		requireSameJSON(t, data, []byte(registrationRequest))
+	}
+
+	infos, _, err := cs.List(ctx, ClientFilter{UserID: "u"}, ListOptions{IncludeSecrets: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(infos) != 1 || asClient(infos[0]).GetRegistration() == nil ||
+		asClient(infos[0]).GetRegistration().Extra["example_extension_parameter"] == nil {
+		t.Fatalf("registration not listed: %v", infos)
+	}
+
+	got := asClient(infos[0]).Registered()
+
+	if got.ClientID != "registered" || got.ClientSecret != "secret" || got.ClientIDIssuedAt == 0 ||
+		got.ClientName != rc.ClientName || got.UserID != "u" {
+		t.Fatalf("unexpected registration response %+v", got)
+	}
+}
+
+func TestDisplayMetadata(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	display := RegisteredClientMetadata{
+		ClientName: "Acme",
+		ClientURI:  "https://acme.example.com",
+		LogoURI:    "https://acme.example.com/logo.png",
+		PolicyURI:  "https://acme.example.com/privacy",
+		TosURI:     "https://acme.example.com/terms",
+	}
+
+	if err := cs.Set(&RegisteredClient{ClientID: "acme", ClientSecret: "secret", UserID: "u", RegisteredClientMetadata: display}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "legacy", Secret: "secret", Domain: "https://example.com", UserID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := cs.GetByID(ctx, "acme")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infos, _, err := cs.List(ctx, ClientFilter{UserID: "u"}, ListOptions{})
+
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("listed %v: %v", infos, err)
+	}
+
+	for _, c := range []*Client{asClient(info), asClient(infos[0])} {
+		got := RegisteredClientMetadata{
+			ClientName: c.GetClientName(),
+			ClientURI:  c.GetClientURI(),
+			LogoURI:    c.GetLogoURI(),
+			PolicyURI:  c.GetPolicyURI(),
+			TosURI:     c.GetTosURI(),
+		}
+
+		if !reflect.DeepEqual(got, display) {
+			t.Fatalf("display metadata %+v, want %+v", got, display)
+		}
+	}
+
+	// the clients without registration have none
+	if c := asClient(infos[1]); c.GetClientName() != "" || c.GetLogoURI() != "" {
+		t.Fatalf("display metadata of a client without registration: %+v", c.GetRegistration())
+	}
+}
+
+func TestDisplayMetadataValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		meta   RegisteredClientMetadata
+		strict bool
+		valid  bool
+	}{
+		{"https", RegisteredClientMetadata{ClientURI: "https://acme.example.com"}, true, true},
+		{"http", RegisteredClientMetadata{LogoURI: "http://acme.example.com/logo.png"}, false, true},
+		{"http when strict", RegisteredClientMetadata{LogoURI: "http://acme.example.com/logo.png"}, true, false},
+		{"relative", RegisteredClientMetadata{PolicyURI: "/privacy"}, false, false},
+		{"without host", RegisteredClientMetadata{TosURI: "https:///terms"}, false, false},
+		{"unparsable", RegisteredClientMetadata{ClientURI: "https://acme.example.com/%zz"}, false, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			ccfg := NewDefaultClientConfig()
+			ccfg.RequireHTTPSURIs = tt.strict
+			cs := newTestClientStore(t, ccfg)
+
+			tt.meta.RedirectURIs = []string{"https://acme.example.com/callback"}
+			err := cs.Set(&RegisteredClient{ClientID: "acme", ClientSecret: "secret", RegisteredClientMetadata: tt.meta})
+
+			if tt.valid && err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.valid && !errors.Is(err, ErrInvalidArgument) {
+				t.Fatalf("%v, want ErrInvalidArgument", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RotateSecret replace the secret of the client, keeping the previous one valid for grace(0 revokes it at once)
+// so the deployed instances of the client can pick up the new one. GetByID only returns the new secret.
+func (cs *ClientStore) RotateSecret(ctx context.Context, id, newSecret string, grace time.Duration) error {
+	o := cs.op("RotateSecret", cs.ccfg.ClientsCName)
+	o.set("client_id", id)
+	o.sensitive(newSecret)
+
+	return cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", id); err != nil {
+			return err
+		}
+
+		if err := requireArg("secret", newSecret); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, id, nil)
+
+		if err != nil {
+			return err
+		}
+
+		return cs.rotate(ctx, o, decodeClient(raw, cs.fields()), newSecret, grace)
+	})
+}
+
+// rotate store newSecret as the secret of entity and its current secret as the previous one for grace,
+// unless the secret changed meanwhile
+func (cs *ClientStore) rotate(ctx context.Context, o *operation, entity *client, newSecret string, grace time.Duration) error {
+	secret, err := cs.hashSecret(newSecret)
+
+	if err != nil {
+		return err
+	}
+
+	o.sensitive(secret)
+
+	keyID, secret, err := cs.sealSecret(secret)
+
+	if err != nil {
+		return err
+	}
+
+	fn := cs.fields()
+	now := time.Now()
+	set := bson.M{fn.Secret: secret, clientUpdatedField: now}
+	unset := bson.M{}
+
+	for _, name := range aliases(fn.KeyID, func(f FieldNames) string { return f.KeyID }) {
+		unset[name] = ""
+	}
+
+	if keyID != "" {
+		set[fn.KeyID] = keyID
+		delete(unset, fn.KeyID)
+	}
+
+	if lifetime := cs.ccfg.SecretLifetime; lifetime > 0 {
+		set[clientSecretExpiresField] = now.Add(lifetime)
+	} else {
+		unset[clientSecretExpiresField] = ""
+	}
+
+	if grace > 0 && entity.Secret != "" {
+		set[clientPreviousSecretField] = entity.Secret
+		set[clientPreviousExpiresField] = now.Add(grace)
+
+		if entity.KeyID != "" {
+			set[clientPreviousKeyIDField] = entity.KeyID
+		} else {
+			unset[clientPreviousKeyIDField] = ""
+		}
+	} else {
+		unset[clientPreviousSecretField] = ""
+		unset[clientPreviousKeyIDField] = ""
+		unset[clientPreviousExpiresField] = ""
+	}
+
+	filter := bson.M{"$and": bson.A{
+		bson.M{"_id": entity.ID},
+		anyOf(aliases(fn.Secret, func(f FieldNames) string { return f.Secret }), entity.Secret),
+	}}
+
+	return cs.auditedHandler(ctx, o, entity.ID, func(ctx context.Context, c *mongo.Collection) error {
+		res, err := c.UpdateOne(ctx, filter, bson.M{"$set": set, "$unset": unset}, cs.updateOptions())
+
+		if err == nil && res.MatchedCount == 0 {
+			err = ErrSecretChanged
+		}
+
+		return err
+	})
+}
+
+// verifyPrevious compare secret with the previous secret of entity during its grace window,
+// an expired previous secret is removed from the document
+func (cs *ClientStore) verifyPrevious(ctx context.Context, o *operation, entity *client, secret string) (bool, error) {
+	if entity.PreviousSecret == "" {
+		return false, nil
+	}
+
+	if !time.Now().Before(entity.PreviousExpiresAt) {
+		return false, cs.colHandler(ctx, cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) error {
+			_, err := c.UpdateOne(ctx,
+				bson.M{"_id": entity.ID, clientPreviousExpiresField: bson.M{"$lte": time.Now()}},
+				bson.M{"$unset": bson.M{clientPreviousSecretField: "", clientPreviousKeyIDField: "", clientPreviousExpiresField: ""}},
+				cs.updateOptions(),
+			)
+			return err
+		})
+	}
+
+	previous, err := cs.openSecret(entity.PreviousKeyID, entity.PreviousSecret)
+
+	if err != nil {
+		return false, err
+	}
+
+	o.sensitive(previous)
+
+	return compareSecret(previous, secret), nil
+}
@@ -0,0 +1,106 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// requireSecrets fail unless the secrets verify as expected
+func requireSecrets(t *testing.T, cs *ClientStore, id string, want map[string]bool) {
+	t.Helper()
+
+	for secret, valid := range want {
+		if ok, err := cs.VerifySecret(context.Background(), id, secret); err != nil || ok != valid {
+			t.Errorf("secret %q valid %v: %v, want %v", secret, ok, err, valid)
+		}
+	}
+}
+
+func TestRotateSecret(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []ClientOption
+	}{
+		{"plaintext", nil},
+		{"hashed", []ClientOption{WithHashedSecrets(4)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cs := newTestClientStore(t, tc.opts...)
+			ctx := context.Background()
+
+			if err := cs.Set(&models.Client{ID: "c", Secret: "old", Domain: "https://example.com"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := cs.RotateSecret(ctx, "c", "new", 300*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+
+			// both during the window
+			requireSecrets(t, cs, "c", map[string]bool{"old": true, "new": true, "other": false})
+
+			// GetByID only exposes the current secret
+			info, err := cs.GetByID(ctx, "c")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.name == "plaintext" && info.GetSecret() != "new" {
+				t.Fatalf("secret %q", info.GetSecret())
+			}
+
+			if info.GetSecret() == "old" {
+				t.Fatal("previous secret exposed")
+			}
+
+			// only the new one after, the previous one is pruned on read
+			time.Sleep(400 * time.Millisecond)
+
+			requireSecrets(t, cs, "c", map[string]bool{"old": false, "new": true})
+
+			raw, err := cs.Collection().FindOne(ctx, bson.M{"_id": "c"}).DecodeBytes()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, field := range []string{clientPreviousSecretField, clientPreviousExpiresField} {
+				if _, err := raw.LookupErr(field); err == nil {
+					t.Errorf("expired %s kept", field)
+				}
+			}
+
+			// no grace revokes the previous secret at once
+			if err := cs.RotateSecret(ctx, "c", "newer", 0); err != nil {
+				t.Fatal(err)
+			}
+
+			requireSecrets(t, cs, "c", map[string]bool{"new": false, "newer": true})
+		})
+	}
+}
+
+func TestRotateSecretInvalid(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := cs.RotateSecret(ctx, "missing", "new", time.Hour); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v", err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "old", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.RotateSecret(ctx, "c", "", time.Hour); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("empty secret: %v", err)
+	}
+
+	requireSecrets(t, cs, "c", map[string]bool{"old": true})
+}
@@ -0,0 +1,261 @@
+package mongo
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SchemaMigrationReport outcome of MigrateSchema
+type SchemaMigrationReport struct {
+	// documents in another layout found
+	Scanned int64
+	// documents rewritten in the configured layout
+	Rewritten int64
+}
+
+// mgoTxnFields the bookkeeping fields added by mgo/txn to the documents it writes
+var mgoTxnFields = []string{"txn-queue", "txn-revno"}
+
+// WithMgoSchema read and write the layout of the mgo based gopkg.in/go-oauth2/mongo.v3 stores, so that
+// instances of both can run side by side: the collections oauth2_txn, oauth2_basic, oauth2_access,
+// oauth2_refresh and oauth2_clients, the fields Data, ExpiredAt and BasicID of the token documents and
+// secret, domain and userid of the clients. The txn-queue/txn-revno fields added by mgo/txn are ignored.
+func WithMgoSchema() Option {
+	return Option{
+		token: func(c *TokenConfig) {
+			defaults := NewDefaultTokenConfig()
+			c.TxnCName = defaults.TxnCName
+			c.BasicCName = defaults.BasicCName
+			c.AccessCName = defaults.AccessCName
+			c.RefreshCName = defaults.RefreshCName
+			c.SingleCollection = false
+			c.FieldNames = LegacyFieldNames()
+		},
+		client: func(c *ClientConfig) {
+			c.ClientsCName = NewDefaultClientConfig().ClientsCName
+			c.FieldNames = LegacyFieldNames()
+		},
+	}
+}
+
+// schemaMigration rewrite the documents of a collection in the configured field names
+type schemaMigration struct {
+	// the names a field may be stored under, the configured one first
+	fields [][]string
+}
+
+// filter match the documents stored under another name or carrying mgo/txn fields
+func (m schemaMigration) filter() bson.M {
+	match := bson.A{}
+
+	for _, names := range m.fields {
+		for _, name := range names[1:] {
+			match = append(match, bson.M{name: bson.M{"$exists": true}})
+		}
+	}
+
+	for _, name := range mgoTxnFields {
+		match = append(match, bson.M{name: bson.M{"$exists": true}})
+	}
+
+	return bson.M{"$or": match}
+}
+
+// rewrite the document with the configured names and without the mgo/txn fields,
+// a field stored under several names keeps the value of the configured one
+func (m schemaMigration) rewrite(raw bson.Raw) (bson.D, error) {
+	elems, err := raw.Elements()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rename := make(map[string]string)
+
+	for _, names := range m.fields {
+		for _, name := range names[1:] {
+			rename[name] = names[0]
+		}
+	}
+
+	var doc bson.D
+
+	seen := make(map[string]bool)
+
+	for _, elem := range elems {
+		key := elem.Key()
+
+		if strings.HasPrefix(key, "txn-") {
+			continue
+		}
+
+		if target, ok := rename[key]; ok {
+			if _, err := raw.LookupErr(target); err == nil {
+				continue
+			}
+
+			key = target
+		}
+
+		if !seen[key] {
+			seen[key] = true
+			doc = append(doc, bson.E{Key: key, Value: elem.Value()})
+		}
+	}
+
+	return doc, nil
+}
+
+// run migrate the documents of col in batches through the store handlers
+func (m schemaMigration) run(
+	ctx context.Context,
+	batchSize int64,
+	read func(context.Context, func(context.Context, *mongo.Collection) error) error,
+	write func(context.Context, func(context.Context, *mongo.Collection) error) error,
+	report *SchemaMigrationReport,
+) error {
+	for {
+		var batch []bson.Raw
+
+		err := read(ctx, func(ctx context.Context, c *mongo.Collection) error {
+			cur, err := c.Find(ctx, m.filter(), options.Find().SetLimit(batchSize))
+
+			if err != nil {
+				return err
+			}
+
+			defer cur.Close(ctx)
+
+			for cur.Next(ctx) {
+				batch = append(batch, append(bson.Raw(nil), cur.Current...))
+			}
+
+			return cur.Err()
+		})
+
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+
+		for _, raw := range batch {
+			report.Scanned++
+
+			doc, err := m.rewrite(raw)
+
+			if err != nil {
+				return err
+			}
+
+			err = write(ctx, func(ctx context.Context, c *mongo.Collection) error {
+				_, err := c.ReplaceOne(ctx, bson.M{"_id": raw.Lookup("_id")}, doc)
+				return err
+			})
+
+			if err != nil {
+				return err
+			}
+
+			report.Rewritten++
+		}
+	}
+}
+
+// MigrateSchema rewrite the token documents stored with other field names(e.g. before WithFieldNames was
+// changed) or by the mgo based store in the configured layout, batchSize documents at a time(The default is 100)
+func (ts *TokenStore) MigrateSchema(ctx context.Context, batchSize int) (SchemaMigrationReport, error) {
+	var report SchemaMigrationReport
+
+	if err := ts.writable(); err != nil {
+		return report, err
+	}
+
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	fn := ts.fields()
+	m := schemaMigration{}
+
+	for _, pick := range []func(FieldNames) string{
+		func(f FieldNames) string { return f.Data },
+		func(f FieldNames) string { return f.Codec },
+		func(f FieldNames) string { return f.KeyID },
+		func(f FieldNames) string { return f.Tenant },
+		func(f FieldNames) string { return f.Issuer },
+		func(f FieldNames) string { return f.ExpiredAt },
+		func(f FieldNames) string { return f.BasicID },
+		func(f FieldNames) string { return f.Confirmation },
+	} {
+		m.fields = append(m.fields, aliases(pick(fn), pick))
+	}
+
+	for _, name := range ts.tokenCNames() {
+		o := ts.op("MigrateSchema", name)
+		before := report.Rewritten
+
+		err := ts.run(ctx, o, func(ctx context.Context) error {
+			defer func() { o.set("documents", report.Rewritten-before) }()
+
+			return m.run(ctx, int64(batchSize),
+				func(ctx context.Context, fn func(context.Context, *mongo.Collection) error) error {
+					return ts.readHandler(ctx, name, true, fn)
+				},
+				func(ctx context.Context, fn func(context.Context, *mongo.Collection) error) error {
+					return ts.colHandler(ctx, name, fn)
+				},
+				&report,
+			)
+		})
+
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// MigrateSchema rewrite the client documents stored with other field names or by the mgo based store
+// in the configured layout, batchSize documents at a time(The default is 100)
+func (cs *ClientStore) MigrateSchema(ctx context.Context, batchSize int) (SchemaMigrationReport, error) {
+	var report SchemaMigrationReport
+
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	fn := cs.fields()
+	m := schemaMigration{}
+
+	for _, pick := range []func(FieldNames) string{
+		func(f FieldNames) string { return f.Secret },
+		func(f FieldNames) string { return f.KeyID },
+		func(f FieldNames) string { return f.Domain },
+		func(f FieldNames) string { return f.UserID },
+	} {
+		m.fields = append(m.fields, aliases(pick(fn), pick))
+	}
+
+	name := cs.ccfg.ClientsCName
+	o := cs.op("MigrateSchema", name)
+
+	err := cs.run(ctx, o, func(ctx context.Context) error {
+		defer func() { o.set("documents", report.Rewritten) }()
+
+		return m.run(ctx, int64(batchSize),
+			func(ctx context.Context, fn func(context.Context, *mongo.Collection) error) error {
+				return cs.readHandler(ctx, name, fn)
+			},
+			func(ctx context.Context, fn func(context.Context, *mongo.Collection) error) error {
+				return cs.colHandler(ctx, name, fn)
+			},
+			&report,
+		)
+	})
+
+	return report, err
+}
@@ -0,0 +1,176 @@
+package mongo
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// loadMgoFixture insert the dump of the collections written by the mgo based gopkg.in/go-oauth2/mongo.v3 store:
+// a token pair, a code and a client, with the mgo/txn bookkeeping fields
+func loadMgoFixture(t *testing.T, db *mongo.Database) {
+	t.Helper()
+
+	data, err := ioutil.ReadFile("testdata/mgo_v3.json")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dump map[string][]bson.D
+
+	if err := bson.UnmarshalExtJSON(data, true, &dump); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, docs := range dump {
+		for _, doc := range docs {
+			if _, err := db.Collection(name).InsertOne(context.Background(), doc); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestMgoSchema(t *testing.T) {
+	requireSeparateCollections(t)
+
+	db := testDatabase(t)
+	loadMgoFixture(t, db)
+
+	ctx := context.Background()
+	ts := testTokenStoreWithDB(db, WithMgoSchema())
+	cs := NewClientStoreWithDB(db, WithMgoSchema())
+
+	info, err := ts.GetByRefresh(ctx, "mgo-refresh")
+
+	if err != nil || info.GetAccess() != "mgo-access" || info.GetUserID() != "mgo-user" || info.GetScope() != "read" {
+		t.Fatalf("refresh token %v: %v", info, err)
+	}
+
+	if info, err := ts.GetByAccess(ctx, "mgo-access"); err != nil || info.GetRefresh() != "mgo-refresh" {
+		t.Fatalf("access token %v: %v", info, err)
+	}
+
+	if info, err := ts.GetByCode(ctx, "mgo-code"); err != nil || info.GetRedirectURI() != "https://example.com/cb" {
+		t.Fatalf("code %v: %v", info, err)
+	}
+
+	client, err := cs.GetByID(ctx, "mgo-client")
+
+	if err != nil || client.GetSecret() != "mgo-secret" || client.GetDomain() != "https://example.com" || client.GetUserID() != "mgo-user" {
+		t.Fatalf("client %v: %v", client, err)
+	}
+
+	// refreshed as the manager does: the new pair is created, the old one removed
+	refreshed := testToken("new-access", "new-refresh")
+	refreshed.ClientID, refreshed.UserID = "mgo-client", "mgo-user"
+
+	if err := ts.Create(ctx, refreshed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.RemoveByAccess(ctx, "mgo-access"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.RemoveByRefresh(ctx, "mgo-refresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByRefresh(ctx, "mgo-refresh"); err == nil {
+		t.Fatal("refreshed token still found")
+	}
+
+	// the new documents keep the mgo layout, so the old package reads them too
+	raw, err := db.Collection("oauth2_refresh").FindOne(ctx, bson.M{"_id": "new-refresh"}).DecodeBytes()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := raw.LookupErr("BasicID"); err != nil {
+		t.Fatalf("refresh document %s", raw)
+	}
+
+	// revoked
+	if err := ts.RemoveByRefresh(ctx, "new-refresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByRefresh(ctx, "new-refresh"); err == nil {
+		t.Fatal("revoked token found")
+	}
+
+	if err := ts.RemoveByCode(ctx, "mgo-code"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByCode(ctx, "mgo-code"); err == nil {
+		t.Fatal("removed code found")
+	}
+}
+
+func TestMigrateSchema(t *testing.T) {
+	requireSeparateCollections(t)
+
+	db := testDatabase(t)
+	loadMgoFixture(t, db)
+
+	ctx := context.Background()
+	ts := testTokenStoreWithDB(db, WithFieldNames(SnakeCaseFieldNames()))
+	cs := NewClientStoreWithDB(db, WithFieldNames(SnakeCaseFieldNames()))
+
+	// the batches are smaller than the documents to migrate
+	report, err := ts.MigrateSchema(ctx, 1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Scanned != 4 || report.Rewritten != 4 {
+		t.Fatalf("token report %+v", report)
+	}
+
+	if report, err := cs.MigrateSchema(ctx, 0); err != nil || report.Rewritten != 1 {
+		t.Fatalf("client report %+v: %v", report, err)
+	}
+
+	for _, name := range []string{"oauth2_basic", "oauth2_access", "oauth2_refresh", "oauth2_clients"} {
+		cur, err := db.Collection(name).Find(ctx, bson.M{})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for cur.Next(ctx) {
+			for _, field := range []string{"Data", "ExpiredAt", "BasicID", "userid", "txn-queue", "txn-revno"} {
+				if _, err := cur.Current.LookupErr(field); err == nil {
+					t.Errorf("%s document with %s: %s", name, field, cur.Current)
+				}
+			}
+		}
+
+		_ = cur.Close(ctx)
+	}
+
+	if info, err := ts.GetByRefresh(ctx, "mgo-refresh"); err != nil || info.GetAccess() != "mgo-access" {
+		t.Fatalf("migrated refresh token %v: %v", info, err)
+	}
+
+	if info, err := ts.GetByCode(ctx, "mgo-code"); err != nil || info.GetCode() != "mgo-code" {
+		t.Fatalf("migrated code %v: %v", info, err)
+	}
+
+	if client, err := cs.GetByID(ctx, "mgo-client"); err != nil || client.GetSecret() != "mgo-secret" {
+		t.Fatalf("migrated client %v: %v", client, err)
+	}
+
+	// nothing left to migrate
+	if report, err := ts.MigrateSchema(ctx, 0); err != nil || report.Scanned != 0 {
+		t.Fatalf("second run %+v: %v", report, err)
+	}
+}
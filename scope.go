@@ -0,0 +1,55 @@
+package mongo
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// scopeAllowed report whether every scope of the space-separated request is in allowed,
+// an empty allow-list allows every scope
+func scopeAllowed(allowed []string, requested string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	set := make(map[string]bool, len(allowed))
+
+	for _, scope := range allowed {
+		set[scope] = true
+	}
+
+	for _, scope := range strings.Fields(requested) {
+		if !set[scope] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ValidateScope report whether the client may request the space-separated scopes, an empty request
+// and a client without allow-list always may. A missing client returns ErrClientNotFound.
+func (cs *ClientStore) ValidateScope(ctx context.Context, clientID, requestedScope string) (ok bool, err error) {
+	o := cs.op("ValidateScope", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+	o.set("scope", requestedScope)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, clientID, bson.M{clientScopesField: 1, clientDeletedField: 1, clientStatusField: 1})
+
+		if err != nil {
+			return err
+		}
+
+		ok = scopeAllowed(lookupStrings(raw, []string{clientScopesField}), requestedScope)
+		return nil
+	})
+
+	return
+}
@@ -0,0 +1,72 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestScopeAllowed(t *testing.T) {
+	cases := []struct {
+		name      string
+		allowed   []string
+		requested string
+		want      bool
+	}{
+		{"subset", []string{"read", "write", "admin"}, "read write", true},
+		{"equal", []string{"read", "write"}, "write read", true},
+		{"superset", []string{"read"}, "read write", false},
+		{"disjoint", []string{"read"}, "admin", false},
+		{"empty request", []string{"read"}, "", true},
+		{"extra spaces", []string{"read", "write"}, "  read   write ", true},
+		{"prefix of an allowed scope", []string{"read:all"}, "read", false},
+		{"empty allow-list", nil, "anything at all", true},
+	}
+
+	for _, tc := range cases {
+		if got := scopeAllowed(tc.allowed, tc.requested); got != tc.want {
+			t.Errorf("%s: %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestValidateScope(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := cs.Set(&Client{Client: models.Client{ID: "limited", Secret: "secret", Domain: "https://example.com"}, Scopes: []string{"read", "write"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "open", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := cs.GetByID(ctx, "limited")
+
+	if err != nil || !reflect.DeepEqual(info.(*Client).GetScopes(), []string{"read", "write"}) {
+		t.Fatalf("scopes of the client %v: %v", info, err)
+	}
+
+	for _, tc := range []struct {
+		clientID, scope string
+		want            bool
+	}{
+		{"limited", "read", true},
+		{"limited", "read write", true},
+		{"limited", "read admin", false},
+		{"limited", "", true},
+		{"open", "read admin", true},
+	} {
+		if ok, err := cs.ValidateScope(ctx, tc.clientID, tc.scope); err != nil || ok != tc.want {
+			t.Errorf("%s requesting %q: %v, %v, want %v", tc.clientID, tc.scope, ok, err, tc.want)
+		}
+	}
+
+	if _, err := cs.ValidateScope(ctx, "missing", "read"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v", err)
+	}
+}
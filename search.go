@@ -0,0 +1,17 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// field of the client name of the registrations, indexed for Search
+const clientNameField = clientRegistrationField + ".client_name"
+
+// Search list the clients whose ID or name contains query, ignoring case, one page at a time like List.
+// An empty query lists every client. The unanchored regex can't seek in an index: it scans the _id and name indexes rather than the documents,
+// which suits collections of thousands of clients. A text index would scale further but only match whole words.
+func (cs *ClientStore) Search(ctx context.Context, query string, opts ListOptions) ([]oauth2.ClientInfo, string, error) {
+	return cs.List(ctx, ClientFilter{Query: query}, opts)
+}
@@ -0,0 +1,82 @@
+package mongo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestSearch(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.MaxPageSize = 2
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+
+	if names := indexNames(t, cs); !hasIndex(names, clientNameField+"_1") {
+		t.Fatalf("indexes %v", names)
+	}
+
+	names := map[string]string{
+		"c1": "Acme Staging",
+		"c2": "Acme Production",
+		"c3": "Globex staging",
+		"c4": "Initech",
+	}
+
+	for id, name := range names {
+		reg := RegisteredClientMetadata{ClientName: name, RedirectURIs: []string{"https://example.com/callback"}}
+
+		if err := cs.Set(&RegisteredClient{ClientID: id, ClientSecret: "secret", RegisteredClientMetadata: reg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cs.Set(&models.Client{ID: "acme-legacy", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, query string
+		ids         []string
+	}{
+		{"prefix", "Acme", []string{"acme-legacy", "c1", "c2"}},
+		{"substring", "stag", []string{"c1", "c3"}},
+		{"case-insensitive", "aCmE pRoD", []string{"c2"}},
+		{"ID", "legacy", []string{"acme-legacy"}},
+		{"regex characters", "a.e", []string{}},
+		{"no match", "umbrella", []string{}},
+		{"empty", "", []string{"acme-legacy", "c1", "c2", "c3", "c4"}},
+	}
+
+	for _, tt := range tests {
+		ids := []string{}
+		opts := ListOptions{}
+
+		// the pages are capped by MaxPageSize
+		for {
+			infos, next, err := cs.Search(ctx, tt.query, opts)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(infos) > ccfg.MaxPageSize {
+				t.Fatalf("%s: page of %d clients", tt.name, len(infos))
+			}
+
+			ids = append(ids, listIDs(infos)...)
+
+			if next == "" {
+				break
+			}
+
+			opts.Cursor = next
+		}
+
+		if !reflect.DeepEqual(ids, tt.ids) {
+			t.Fatalf("%s: found %v, want %v", tt.name, ids, tt.ids)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package mongo
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashedClient client information whose secret may be a bcrypt hash, returned by GetByID when
+// HashSecrets is set so the oauth2 manager verifies the secret with VerifyPassword
+type HashedClient struct {
+	Client
+}
+
+// isSecretHash report whether the stored secret is a bcrypt hash
+func isSecretHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// compareSecret compare secret with the stored bcrypt hash or plaintext secret in constant time
+func compareSecret(stored, secret string) bool {
+	if isSecretHash(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(secret)) == nil
+	}
+
+	return stored != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(secret)) == 1
+}
+
+// hashSecret the secret to store
+func (cs *ClientStore) hashSecret(secret string) (string, error) {
+	if !cs.ccfg.HashSecrets || secret == "" || isSecretHash(secret) {
+		return secret, nil
+	}
+
+	cost := cs.ccfg.SecretHashCost
+
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), cost)
+
+	return string(hash), err
+}
+
+// VerifySecret compare secret with the stored secret of the client, or the previous one during the grace window
+// of RotateSecret. Clients authenticating without their secret(none or private_key_jwt) return an
+// AuthMethodError, an expired secret ErrSecretExpired.
+// With HashSecrets set, a legacy plaintext secret is replaced by its hash after a successful comparison.
+func (cs *ClientStore) VerifySecret(ctx context.Context, id, secret string) (ok bool, err error) {
+	o := cs.op("VerifySecret", cs.ccfg.ClientsCName)
+	o.set("client_id", id)
+	o.sensitive(secret)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", id); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, id, nil)
+
+		if err != nil {
+			return err
+		}
+
+		entity := decodeClient(raw, cs.fields())
+
+		if method := effectiveAuthMethod(entity.AuthMethod, entity.Public); !usesSecret(method) {
+			return &AuthMethodError{ClientID: id, Method: method}
+		}
+
+		stored, err := cs.openSecret(entity.KeyID, entity.Secret)
+
+		if err != nil {
+			return err
+		}
+
+		o.sensitive(stored)
+
+		if ok = compareSecret(stored, secret); !ok {
+			ok, err = cs.verifyPrevious(ctx, o, entity, secret)
+			return err
+		}
+
+		if !entity.SecretExpiresAt.IsZero() && !time.Now().Before(entity.SecretExpiresAt) {
+			ok = false
+			return ErrSecretExpired
+		}
+
+		if !cs.ccfg.HashSecrets || isSecretHash(stored) {
+			return nil
+		}
+
+		return cs.upgradeSecret(ctx, o, entity, secret)
+	})
+
+	return ok, err
+}
+
+// GetByIDAndSecret the client authenticated by its ID and secret, compared in constant time(or with bcrypt for a
+// hashed secret) like VerifyPassword, the previous secret being accepted during the grace window of RotateSecret.
+// A missing, disabled or suspended client, a wrong or expired secret and a client authenticating without its secret
+// all return ErrInvalidClientCredentials, so the caller can't tell them apart.
+func (cs *ClientStore) GetByIDAndSecret(ctx context.Context, id, secret string) (info oauth2.ClientInfo, err error) {
+	o := cs.op("GetByIDAndSecret", cs.ccfg.ClientsCName)
+	o.set("client_id", id)
+	o.sensitive(secret)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if id == "" || secret == "" {
+			return ErrInvalidClientCredentials
+		}
+
+		raw, err := cs.findClient(ctx, id, nil)
+
+		if errors.Is(err, ErrClientNotFound) {
+			return ErrInvalidClientCredentials
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if info, err = cs.clientInfo(o, raw); err != nil {
+			return err
+		}
+
+		c := asClient(info)
+
+		if c == nil || c.Secret == "" || !usesSecret(c.GetTokenEndpointAuthMethod()) || !c.VerifyPassword(secret) {
+			return ErrInvalidClientCredentials
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		info = nil
+	}
+
+	return
+}
+
+// upgradeSecret replace the verified plaintext secret of entity with its hash, unless it changed meanwhile
+func (cs *ClientStore) upgradeSecret(ctx context.Context, o *operation, entity *client, secret string) error {
+	hash, err := cs.hashSecret(secret)
+
+	if err != nil {
+		return err
+	}
+
+	keyID, hash, err := cs.sealSecret(hash)
+
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{cs.fields().Secret: hash}}
+
+	if keyID != "" {
+		update = bson.M{"$set": bson.M{cs.fields().Secret: hash, cs.fields().KeyID: keyID}}
+	}
+
+	return cs.colHandler(ctx, cs.ccfg.ClientsCName, func(ctx context.Context, c *mongo.Collection) error {
+		names := aliases(cs.fields().Secret, func(f FieldNames) string { return f.Secret })
+		match := bson.A{}
+
+		for _, name := range names {
+			match = append(match, bson.M{name: entity.Secret})
+		}
+
+		res, err := c.UpdateOne(ctx, bson.M{"_id": entity.ID, "$or": match}, update, cs.updateOptions())
+
+		if err == nil {
+			o.set("upgraded", res.ModifiedCount)
+		}
+
+		return err
+	})
+}
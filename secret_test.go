@@ -0,0 +1,285 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// storedSecret the secret field of the stored client id
+func storedSecret(t *testing.T, db *mongo.Database, id string) string {
+	t.Helper()
+
+	raw, err := db.Collection("oauth2_clients").FindOne(context.Background(), bson.M{"_id": id}).DecodeBytes()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return lookupString(raw, []string{"secret"})
+}
+
+func TestHashedSecrets(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+	cs := NewClientStoreWithDB(db, WithHashedSecrets(4))
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored := storedSecret(t, db, "c"); !isSecretHash(stored) {
+		t.Fatalf("stored secret %q, want a bcrypt hash", stored)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		id     string
+		secret string
+		ok     bool
+		err    error
+	}{
+		{"right secret", "c", "secret", true, nil},
+		{"wrong secret", "c", "wrong", false, nil},
+		{"empty secret", "c", "", false, nil},
+		{"unknown client", "unknown", "secret", false, ErrClientNotFound},
+		{"empty client ID", "", "secret", false, ErrInvalidArgument},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := cs.VerifySecret(ctx, tc.id, tc.secret)
+
+			if ok != tc.ok || !errors.Is(err, tc.err) {
+				t.Fatalf("verified %v: %v, want %v: %v", ok, err, tc.ok, tc.err)
+			}
+		})
+	}
+
+	// the hash is the secret of the oauth2 manager too
+	info, err := cs.GetByID(ctx, "c")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, ok := info.(interface{ VerifyPassword(string) bool })
+
+	if !ok || !verifier.VerifyPassword("secret") || verifier.VerifyPassword("wrong") {
+		t.Fatalf("client %T doesn't verify the hashed secret", info)
+	}
+}
+
+// a plaintext secret stored before HashSecrets is verified, then replaced by its hash
+func TestHashedSecretsLegacy(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	if err := NewClientStoreWithDB(db).Set(&models.Client{ID: "c", Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cs := NewClientStoreWithDB(db, WithHashedSecrets(4))
+
+	if ok, err := cs.VerifySecret(ctx, "c", "wrong"); ok || err != nil {
+		t.Fatalf("wrong secret verified %v: %v", ok, err)
+	}
+
+	if stored := storedSecret(t, db, "c"); stored != "secret" {
+		t.Fatalf("secret %q upgraded after a failed check", stored)
+	}
+
+	if ok, err := cs.VerifySecret(ctx, "c", "secret"); !ok || err != nil {
+		t.Fatalf("legacy secret verified %v: %v", ok, err)
+	}
+
+	if stored := storedSecret(t, db, "c"); !isSecretHash(stored) {
+		t.Fatalf("legacy secret %q not upgraded", stored)
+	}
+
+	if ok, err := cs.VerifySecret(ctx, "c", "secret"); !ok || err != nil {
+		t.Fatalf("upgraded secret verified %v: %v", ok, err)
+	}
+}
+
+func TestSecretExpiry(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	// the boundary itself is expired
+	c := &Client{SecretExpiresAt: now}
+
+	if !c.secretExpired(now) || c.secretExpired(now.Add(-time.Nanosecond)) {
+		t.Fatal("secret expiry boundary")
+	}
+
+	if (&Client{}).secretExpired(now.Add(100 * 365 * 24 * time.Hour)) {
+		t.Fatal("secret without expiry expired")
+	}
+
+	expiries := map[string]time.Time{
+		"never":   {},
+		"later":   now.Add(90 * 24 * time.Hour),
+		"soon":    now.Add(7 * 24 * time.Hour),
+		"expired": now.Add(-time.Second),
+	}
+
+	for id, at := range expiries {
+		if err := cs.Set(&Client{Client: models.Client{ID: id, Secret: "secret", Domain: "https://example.com"}, SecretExpiresAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		id, secret string
+		ok         bool
+		err        error
+	}{
+		{"never", "secret", true, nil},
+		{"later", "secret", true, nil},
+		{"soon", "secret", true, nil},
+		{"expired", "secret", false, ErrSecretExpired},
+		// a wrong secret doesn't reveal the expiry
+		{"expired", "wrong", false, nil},
+	}
+
+	for _, tt := range tests {
+		if ok, err := cs.VerifySecret(ctx, tt.id, tt.secret); ok != tt.ok || !errors.Is(err, tt.err) {
+			t.Fatalf("%s: %v(%v), want %v(%v)", tt.id, ok, err, tt.ok, tt.err)
+		}
+	}
+
+	info, err := cs.GetByID(ctx, "soon")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reg := asClient(info).Registered(); reg.ClientSecretExpiresAt != expiries["soon"].Unix() {
+		t.Fatalf("client_secret_expires_at %d, want %d", reg.ClientSecretExpiresAt, expiries["soon"].Unix())
+	}
+
+	if info, err = cs.GetByID(ctx, "never"); err != nil || asClient(info).Registered().ClientSecretExpiresAt != 0 {
+		t.Fatalf("client_secret_expires_at of a secret without expiry: %v", err)
+	}
+
+	infos, _, err := cs.ExpiringSecrets(ctx, 30*24*time.Hour, ListOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(infos) != 2 || infos[0].GetID() != "expired" || infos[1].GetID() != "soon" {
+		t.Fatalf("expiring secrets %v, want expired and soon", infos)
+	}
+
+	// the secrets generated with a lifetime expire
+	ccfg := NewDefaultClientConfig()
+	ccfg.SecretLifetime = 365 * 24 * time.Hour
+	cs = newTestClientStore(t, ccfg)
+
+	created, err := cs.CreateClient(ctx, &models.Client{Domain: "https://example.com"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := asClient(created).SecretExpiresAt
+
+	if at.Before(now.Add(ccfg.SecretLifetime)) || at.After(time.Now().Add(ccfg.SecretLifetime)) {
+		t.Fatalf("generated secret expires at %v, want a year from now", at)
+	}
+}
+
+func TestGetByIDAndSecret(t *testing.T) {
+	for _, hashed := range []bool{false, true} {
+		hashed := hashed
+		name := "plaintext"
+
+		var opts []ClientOption
+
+		if hashed {
+			name, opts = "hashed", []ClientOption{WithHashedSecrets(4)}
+		}
+
+		t.Run(name, func(t *testing.T) {
+			cs := newTestClientStore(t, opts...)
+			ctx := context.Background()
+
+			clients := []*Client{
+				{Client: models.Client{ID: "c", Secret: "secret"}},
+				{Client: models.Client{ID: "rotated", Secret: "old-secret"}},
+				{Client: models.Client{ID: "disabled", Secret: "secret"}},
+				{Client: models.Client{ID: "suspended", Secret: "secret"}},
+				{Client: models.Client{ID: "expired", Secret: "secret"}, SecretExpiresAt: time.Now().Add(-time.Second)},
+				{Client: models.Client{ID: "public"}, Public: true},
+				{Client: models.Client{ID: "jwt"}, TokenEndpointAuthMethod: AuthMethodPrivateKeyJWT, JWKSURI: "https://example.com/jwks.json"},
+			}
+
+			for _, c := range clients {
+				c.Domain = "https://example.com"
+
+				if err := cs.Set(c); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := cs.RotateSecret(ctx, "rotated", "new-secret", time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := cs.Disable(ctx, "disabled"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := cs.SetStatus(ctx, "suspended", ClientSuspended); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, tt := range []struct{ id, secret string }{
+				{"c", "secret"},
+				{"rotated", "new-secret"},
+				// the previous secret during the grace window
+				{"rotated", "old-secret"},
+			} {
+				info, err := cs.GetByIDAndSecret(ctx, tt.id, tt.secret)
+
+				if err != nil || info == nil || info.GetID() != tt.id {
+					t.Fatalf("%s/%s: %v(%v)", tt.id, tt.secret, info, err)
+				}
+			}
+
+			var first error
+
+			for _, tt := range []struct{ name, id, secret string }{
+				{"unknown ID", "missing", "secret"},
+				{"wrong secret", "c", "wrong"},
+				{"secret of another client", "rotated", "secret"},
+				{"empty ID", "", "secret"},
+				{"empty secret", "c", ""},
+				{"disabled", "disabled", "secret"},
+				{"suspended", "suspended", "secret"},
+				{"expired secret", "expired", "secret"},
+				{"public client", "public", ""},
+				{"private_key_jwt client", "jwt", ""},
+			} {
+				info, err := cs.GetByIDAndSecret(ctx, tt.id, tt.secret)
+
+				if !errors.Is(err, ErrInvalidClientCredentials) || info != nil {
+					t.Fatalf("%s: %v(%v), want ErrInvalidClientCredentials", tt.name, info, err)
+				}
+
+				// the same error for every failure, telling nothing of what happened
+				if first == nil {
+					first = err
+				} else if err.Error() != first.Error() || errors.Is(err, ErrClientNotFound) {
+					t.Fatalf("%s: %v, unlike %v", tt.name, err, first)
+				}
+			}
+		})
+	}
+}
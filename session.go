@@ -0,0 +1,228 @@
+package mongo
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// tokenSessionField OIDC session ID(sid) of the basic, access and refresh documents, absent without session
+const tokenSessionField = "sid"
+
+// sparseIndex index on the field of the documents having it
+func sparseIndex(field string) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetName(field + "_1").SetSparse(true),
+	}
+}
+
+type sessionKey struct{}
+
+// ContextWithSessionID attach the OIDC session ID(sid) of the login to ctx, the tokens created with it belong to
+// the session and are removed by RemoveBySessionID
+func ContextWithSessionID(ctx context.Context, sid string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, sid)
+}
+
+// SessionIDFromContext the session ID attached by ContextWithSessionID, empty if none
+func SessionIDFromContext(ctx context.Context) string {
+	sid, _ := ctx.Value(sessionKey{}).(string)
+	return sid
+}
+
+// grantSessionTTL how long the session ID of a code or refresh token read by a request waits for the tokens
+// the request creates
+const grantSessionTTL = time.Minute
+
+// grantSessions the session IDs of the codes and refresh tokens read by GetByCode and GetByRefresh, by the
+// context of the reading request. The oauth2 manager creates the exchanged or refreshed tokens with the same
+// context but without the code or the previous refresh token, they get the session ID from here.
+type grantSessions struct {
+	mu   sync.Mutex
+	sids map[context.Context]grantSession
+}
+
+type grantSession struct {
+	sid     string
+	expires time.Time
+}
+
+func newGrantSessions() *grantSessions {
+	return &grantSessions{sids: make(map[context.Context]grantSession)}
+}
+
+// requestContext whether ctx can stand for a single request: a map key and not a root context shared by
+// the unrelated calls
+func requestContext(ctx context.Context) bool {
+	return reflect.TypeOf(ctx).Comparable() && ctx != context.Background() && ctx != context.TODO()
+}
+
+// put keep the session ID sid of the code or refresh token read with ctx, unless ctx has one already
+func (g *grantSessions) put(ctx context.Context, sid string) {
+	if sid == "" || SessionIDFromContext(ctx) != "" || !requestContext(ctx) {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+
+	for k, v := range g.sids {
+		if now.After(v.expires) {
+			delete(g.sids, k)
+		}
+	}
+
+	g.sids[ctx] = grantSession{sid: sid, expires: now.Add(grantSessionTTL)}
+}
+
+// take remove and return the session ID kept for ctx, empty if none
+func (g *grantSessions) take(ctx context.Context) string {
+	if !requestContext(ctx) {
+		return ""
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	v, ok := g.sids[ctx]
+
+	if !ok {
+		return ""
+	}
+
+	delete(g.sids, ctx)
+
+	if time.Now().After(v.expires) {
+		return ""
+	}
+
+	return v.sid
+}
+
+// carrySession ctx with the session ID of the code or refresh token its request read when it has none
+func (ts *TokenStore) carrySession(ctx context.Context) context.Context {
+	if SessionIDFromContext(ctx) != "" {
+		return ctx
+	}
+
+	if sid := ts.grants.take(ctx); sid != "" {
+		return ContextWithSessionID(ctx, sid)
+	}
+
+	return ctx
+}
+
+// SessionRecord a session of a user having codes or token families
+type SessionRecord struct {
+	SessionID string
+	// number of codes and token families in the session
+	Families int
+	// expiry of the last expiring one
+	ExpiredAt time.Time
+}
+
+// ListSessions the sessions of the user in session ID order, empty if none, whose IDs RemoveBySessionID takes.
+// The codes kept in memory(TokenConfig.MemoryCodes) aren't listed.
+func (ts *TokenStore) ListSessions(ctx context.Context, userID string) (sessions []SessionRecord, err error) {
+	o := ts.op("ListSessions", ts.tcfg.BasicCName)
+	o.set("user_id", ts.userID(userID))
+
+	err = ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("user ID", userID); err != nil {
+			return err
+		}
+
+		filter := ts.withKeyPrefix(ts.withIssuer(ctx, bson.M{
+			tokenUserField:    ts.userID(userID),
+			tokenSessionField: bson.M{"$exists": true},
+		}))
+		// the access/refresh documents of the single collection record the session too
+		if ts.tcfg.SingleCollection {
+			filter[tokenKindField] = bson.M{"$in": bson.A{kindBasic, kindCode}}
+		}
+
+		find := options.Find().SetProjection(ts.payloadProjection())
+
+		return ts.readHandler(ctx, ts.tcfg.BasicCName, false, func(ctx context.Context, c *mongo.Collection) error {
+			sessions = nil
+
+			cur, err := c.Find(ctx, filter, find)
+
+			if err != nil {
+				return err
+			}
+
+			defer cur.Close(ctx)
+
+			index := make(map[string]int)
+
+			for cur.Next(ctx) {
+				record := ts.tokenRecord(cur.Current)
+				i, ok := index[record.SessionID]
+
+				if !ok {
+					i = len(sessions)
+					index[record.SessionID] = i
+					sessions = append(sessions, SessionRecord{SessionID: record.SessionID})
+				}
+
+				sessions[i].Families++
+
+				if record.ExpiredAt.After(sessions[i].ExpiredAt) {
+					sessions[i].ExpiredAt = record.ExpiredAt
+				}
+			}
+
+			sort.Slice(sessions, func(i, j int) bool { return sessions[i].SessionID < sessions[j].SessionID })
+			o.set("sessions", len(sessions))
+
+			return cur.Err()
+		})
+	})
+
+	return
+}
+
+// RemoveBySessionID delete the codes and tokens of every token family created in the session sid, e.g. on a
+// back-channel logout token, returning the number of deleted documents and codes kept in memory
+func (ts *TokenStore) RemoveBySessionID(ctx context.Context, sid string) (n int64, err error) {
+	o := ts.op("RemoveBySessionID", ts.tcfg.BasicCName)
+	o.sensitive(sid)
+
+	err = ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("session ID", sid); err != nil {
+			return err
+		}
+
+		codes := ts.removeMemoryCodes(ctx, func(mc memoryCode) bool { return mc.sid == sid })
+
+		return ts.dbHandler(ctx, func(ctx context.Context, d routedDB) error {
+			n = codes
+
+			for _, name := range ts.tokenCNames() {
+				res, err := d.Collection(name).DeleteMany(ctx, ts.withKeyPrefix(ts.withIssuer(ctx, bson.M{tokenSessionField: sid})))
+
+				if err != nil {
+					return err
+				}
+
+				n += res.DeletedCount
+			}
+
+			o.set("deleted", n)
+
+			return nil
+		})
+	})
+
+	return
+}
@@ -0,0 +1,239 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRemoveBySessionID(t *testing.T) {
+	ts := newTestTokenStore(t)
+	ctx := context.Background()
+	login := ContextWithSessionID(ctx, "08a5019c-17e1-4977-8f42-65a12843ea02")
+
+	if sid := SessionIDFromContext(login); sid != "08a5019c-17e1-4977-8f42-65a12843ea02" {
+		t.Fatalf("session ID %q", sid)
+	}
+
+	if sid := SessionIDFromContext(ctx); sid != "" {
+		t.Fatalf("session ID %q without session", sid)
+	}
+
+	// the token families of the session: a code, a token pair and an access token alone
+	families := []struct {
+		ctx     context.Context
+		access  string
+		refresh string
+		removed bool
+	}{
+		{login, "access-1", "refresh-1", true},
+		{login, "access-2", "", true},
+		{ContextWithSessionID(ctx, "other"), "access-3", "refresh-3", false},
+		{ctx, "access-4", "refresh-4", false},
+	}
+
+	if err := ts.Create(login, testCode("code")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range families {
+		if err := ts.Create(f.ctx, testToken(f.access, f.refresh)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := ts.Collection(CollectionAccess).CountDocuments(ctx, testKindFilter(ts, CollectionAccess, bson.M{tokenSessionField: "other"})); err != nil || n != 1 {
+		t.Fatalf("%d access tokens recorded in the session: %v", n, err)
+	}
+
+	// one call clears them all: 3 basic, 2 access and 1 refresh documents
+	n, err := ts.RemoveBySessionID(ctx, "08a5019c-17e1-4977-8f42-65a12843ea02")
+
+	if err != nil || n != 6 {
+		t.Fatalf("removed %d documents: %v", n, err)
+	}
+
+	if info, err := ts.GetByCode(ctx, "code"); err == nil && info != nil {
+		t.Fatal("code of the session kept")
+	}
+
+	for _, f := range families {
+		info, err := ts.GetByAccess(ctx, f.access)
+
+		if kept := err == nil && info != nil; kept == f.removed {
+			t.Fatalf("%s kept %v(%v)", f.access, kept, err)
+		}
+	}
+
+	if n, err := ts.RemoveBySessionID(ctx, "unknown"); err != nil || n != 0 {
+		t.Fatalf("removed %d documents of an unknown session: %v", n, err)
+	}
+
+	_, err = ts.RemoveBySessionID(ctx, "")
+
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("empty session ID: %v, want ErrInvalidArgument", err)
+	}
+}
+
+// tokenIndexNames the names of the indexes of the token collection
+func tokenIndexNames(t *testing.T, ts *TokenStore, kind CollectionKind) []string {
+	t.Helper()
+
+	ctx := context.Background()
+	cur, err := ts.Collection(kind).Indexes().List(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var specs []bson.M
+
+	if err := cur.All(ctx, &specs); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+
+	for _, spec := range specs {
+		names = append(names, spec["name"].(string))
+	}
+
+	return names
+}
+
+func TestSessionIndexes(t *testing.T) {
+	ts := newTestTokenStore(t)
+
+	for _, kind := range []CollectionKind{CollectionBasic, CollectionAccess, CollectionRefresh} {
+		if names := tokenIndexNames(t, ts, kind); !hasIndex(names, tokenSessionField+"_1") {
+			t.Fatalf("indexes of %s %s", ts.Collection(kind).Name(), strings.Join(names, " "))
+		}
+	}
+}
+
+// TestSessionCarriedForward the tokens created by the code exchange and the refreshes, without session ID
+// the way the oauth2 manager creates them, stay in the session of the code
+func TestSessionCarriedForward(t *testing.T) {
+	ts := newTestTokenStore(t)
+	ctx := context.Background()
+	sid := "4f7f8a0e-9a3b-4d0c-8f0a-2d1c7b9e6a51"
+
+	if err := ts.Create(ContextWithSessionID(ctx, sid), testCode("code")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the token request reads and removes the code, then creates the tokens
+	exchange, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if _, err := ts.GetByCode(exchange, "code"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.RemoveByCode(exchange, "code"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Create(exchange, testToken("access-1", "refresh-1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the refresh request rotates the refresh token, then removes the previous tokens
+	refresh, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	info, err := ts.GetByRefresh(refresh, "refresh-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info.SetAccess("access-2")
+	info.SetRefresh("refresh-2")
+
+	if err := ts.Create(refresh, info); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.RemoveByAccess(refresh, "access-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.RemoveByRefresh(refresh, "refresh-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tokens read with a root context shared by the unrelated calls aren't carried
+	if _, err := ts.GetByRefresh(ctx, "refresh-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Create(ctx, testToken("access-3", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := ts.ListSessions(ctx, "u")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the basic document of the first token family stays until it expires
+	if len(sessions) != 1 || sessions[0].SessionID != sid || sessions[0].Families != 2 {
+		t.Fatalf("sessions %+v", sessions)
+	}
+
+	if _, err := ts.RemoveBySessionID(ctx, sid); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := ts.GetByRefresh(ctx, "refresh-2"); err == nil && info != nil {
+		t.Fatal("refreshed tokens of the session kept")
+	}
+
+	if _, err := ts.GetByAccess(ctx, "access-3"); err != nil {
+		t.Fatalf("token without session removed: %v", err)
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	ts := newTestTokenStore(t)
+	ctx := context.Background()
+
+	for _, f := range []struct {
+		sid    string
+		access string
+	}{
+		{"b", "access-1"},
+		{"a", "access-2"},
+		{"b", "access-3"},
+		{"", "access-4"},
+	} {
+		if err := ts.Create(ContextWithSessionID(ctx, f.sid), testToken(f.access, "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := ts.ListSessions(ctx, "u")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sessions) != 2 || sessions[0].SessionID != "a" || sessions[0].Families != 1 ||
+		sessions[1].SessionID != "b" || sessions[1].Families != 2 || sessions[1].ExpiredAt.IsZero() {
+		t.Fatalf("sessions %+v", sessions)
+	}
+
+	if sessions, err := ts.ListSessions(ctx, "unknown"); err != nil || len(sessions) != 0 {
+		t.Fatalf("sessions %+v of an unknown user: %v", sessions, err)
+	}
+
+	if _, err := ts.ListSessions(ctx, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("empty user ID: %v, want ErrInvalidArgument", err)
+	}
+}
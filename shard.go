@@ -0,0 +1,69 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// errCodeAlreadyInitialized returned by enableSharding on a database already sharded by older servers
+const errCodeAlreadyInitialized = 23
+
+// shardCollections enable sharding on the database and shard the collections on a hashed _id,
+// every lookup of the stores matches the _id so it is routed to a single shard
+func shardCollections(ctx context.Context, db routedDB, names []string) error {
+	admin := db.Client().Database("admin")
+
+	err := admin.RunCommand(ctx, bson.D{{Key: "enableSharding", Value: db.Name()}}).Err()
+
+	if cerr, ok := err.(mongo.CommandError); ok && cerr.Code == errCodeAlreadyInitialized {
+		err = nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		// sharding again with the same key is a no-op
+		err := admin.RunCommand(ctx, bson.D{
+			{Key: "shardCollection", Value: db.Name() + "." + db.prefix + name},
+			{Key: "key", Value: bson.D{{Key: "_id", Value: "hashed"}}},
+		}).Err()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ShardCollections shard the token collections and the denylist on a hashed _id through mongos, once per
+// tenant when a TenantResolver is configured. Create then runs a distributed transaction(MongoDB 4.2 and later)
+// across the shards of the basic, access and refresh documents, see WithoutTransactions for older clusters.
+func (ts *TokenStore) ShardCollections(ctx context.Context) error {
+	if err := ts.writable(); err != nil {
+		return err
+	}
+
+	db, err := route(ctx, ts.conns, ts.tcfg.TenantResolver)
+
+	if err != nil {
+		return err
+	}
+
+	return shardCollections(ctx, db, append(ts.tokenCNames(), ts.tcfg.DenylistCName))
+}
+
+// ShardCollections shard the clients collection on a hashed _id through mongos
+func (cs *ClientStore) ShardCollections(ctx context.Context) error {
+	db, err := route(ctx, cs.conns, cs.ccfg.TenantResolver)
+
+	if err != nil {
+		return err
+	}
+
+	return shardCollections(ctx, db, []string{cs.ccfg.ClientsCName})
+}
@@ -0,0 +1,126 @@
+package mongo
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// shardKeyFilters the filters of the reads and writes of the recorded commands, by command name
+func shardKeyFilters(rec *commandRecorder) map[string][]bson.Raw {
+	filters := make(map[string][]bson.Raw)
+
+	for _, cmd := range rec.named("find") {
+		filters["find"] = append(filters["find"], cmd.Lookup("filter").Document())
+	}
+
+	for _, cmd := range rec.named("findAndModify") {
+		filters["findAndModify"] = append(filters["findAndModify"], cmd.Lookup("query").Document())
+	}
+
+	for name, list := range map[string]string{"delete": "deletes", "update": "updates"} {
+		for _, cmd := range rec.named(name) {
+			values, _ := cmd.Lookup(list).Array().Values()
+
+			for _, v := range values {
+				filters[name] = append(filters[name], v.Document().Lookup("q").Document())
+			}
+		}
+	}
+
+	return filters
+}
+
+// every lookup, refresh and revocation matches the _id, so mongos routes it to a single shard
+func TestShardKeyTargeted(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+
+	ts := testTokenStore(cfg)
+	defer ts.Close()
+
+	rec.reset()
+
+	testTokenOperations(t, ts)
+
+	filters := shardKeyFilters(&rec)
+
+	if len(filters["find"]) == 0 || len(filters["delete"]) == 0 {
+		t.Fatalf("no lookups recorded: %v", filters)
+	}
+
+	for name, list := range filters {
+		for _, filter := range list {
+			if _, err := filter.LookupErr("_id"); err != nil {
+				t.Errorf("%s without the shard key: %s", name, filter)
+			}
+		}
+	}
+}
+
+// requires a sharded cluster through mongos, MongoDB 4.2 or later:
+// MONGODB_SHARDED_URI=mongodb://localhost:27017 go test -run Sharded
+func TestShardedCluster(t *testing.T) {
+	uri := os.Getenv("MONGODB_SHARDED_URI")
+
+	if uri == "" {
+		t.Skip("MONGODB_SHARDED_URI not set")
+	}
+
+	cfg := NewConfig(uri, testDBName())
+	t.Cleanup(func() { dropTestDatabase(cfg.URL, cfg.DB) })
+
+	ctx := context.Background()
+
+	ts := testTokenStore(cfg)
+	defer ts.Close()
+
+	cs := NewClientStore(cfg)
+	defer cs.Close()
+
+	if !ts.Capabilities().Sharded {
+		t.Fatal("not a sharded cluster")
+	}
+
+	if err := ts.ShardCollections(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.ShardCollections(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// sharding again is a no-op
+	if err := ts.ShardCollections(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	testTokenOperations(t, ts)
+	testClientOperations(t, cs)
+
+	if err := ts.Create(ctx, testToken("access", "refresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	var explain bson.Raw
+
+	err := ts.Database().RunCommand(ctx, bson.D{
+		{Key: "explain", Value: bson.D{
+			{Key: "find", Value: ts.tcfg.AccessCName},
+			{Key: "filter", Value: bson.D{{Key: "_id", Value: ts.tokenKey(ctx, ts.tcfg.AccessCName, "access")}}},
+		}},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}).Decode(&explain)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stage := explain.Lookup("queryPlanner", "winningPlan", "stage").StringValue(); stage != "SINGLE_SHARD" {
+		t.Fatalf("lookup by access token routed to %s", stage)
+	}
+}
@@ -0,0 +1,96 @@
+package mongo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// field of the software ID of the registrations, indexed for GetBySoftwareID
+const clientSoftwareIDField = clientRegistrationField + ".software_id"
+
+// softwareClaims the claims of an RFC 7591 software statement the store keeps
+type softwareClaims struct {
+	SoftwareID      string `json:"software_id"`
+	SoftwareVersion string `json:"software_version"`
+}
+
+// parseSoftwareStatement the claims of the software statement, a JWT whose signature is left to the caller
+func parseSoftwareStatement(statement string) (softwareClaims, error) {
+	var claims softwareClaims
+
+	parts := strings.Split(statement, ".")
+
+	if len(parts) != 3 {
+		return claims, fmt.Errorf("%w: software statement isn't a JWT", ErrInvalidArgument)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+
+	if err != nil {
+		return claims, fmt.Errorf("%w: software statement payload: %v", ErrInvalidArgument, err)
+	}
+
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, fmt.Errorf("%w: software statement claims: %v", ErrInvalidArgument, err)
+	}
+
+	return claims, nil
+}
+
+// softwareStatement check the size of the software statement of reg and take its software ID and version, which
+// prevail over the plain metadata as RFC 7591 requires
+func (cs *ClientStore) softwareStatement(reg *RegisteredClientMetadata) error {
+	if reg.SoftwareStatement == "" {
+		return nil
+	}
+
+	max := cs.ccfg.MaxSoftwareStatementSize
+
+	if max <= 0 {
+		max = 16 << 10
+	}
+
+	if len(reg.SoftwareStatement) > max {
+		return fmt.Errorf("%w: software statement of %d bytes exceeds %d", ErrInvalidArgument, len(reg.SoftwareStatement), max)
+	}
+
+	claims, err := parseSoftwareStatement(reg.SoftwareStatement)
+
+	if err != nil {
+		return err
+	}
+
+	if claims.SoftwareID != "" {
+		reg.SoftwareID = claims.SoftwareID
+	}
+
+	if claims.SoftwareVersion != "" {
+		reg.SoftwareVersion = claims.SoftwareVersion
+	}
+
+	return nil
+}
+
+// GetBySoftwareID the active clients registered from the software in ID order, e.g. to disable them when its
+// software statement is revoked, empty if none
+func (cs *ClientStore) GetBySoftwareID(ctx context.Context, softwareID string) (infos []oauth2.ClientInfo, err error) {
+	o := cs.op("GetBySoftwareID", cs.ccfg.ClientsCName)
+	o.set("software_id", softwareID)
+
+	err = cs.run(ctx, o, func(ctx context.Context) (err error) {
+		if err := requireArg("software ID", softwareID); err != nil {
+			return err
+		}
+
+		infos, err = cs.findClients(ctx, o, bson.M{clientSoftwareIDField: softwareID}, 0)
+		return
+	})
+
+	return
+}
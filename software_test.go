@@ -0,0 +1,117 @@
+package mongo
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// softwareStatement a JWT with the claims and a dummy signature, the store doesn't verify it
+func softwareStatement(claims string) string {
+	enc := base64.RawURLEncoding
+
+	return enc.EncodeToString([]byte(`{"alg":"RS256"}`)) + "." + enc.EncodeToString([]byte(claims)) + "." +
+		enc.EncodeToString([]byte("signature"))
+}
+
+func TestSoftwareStatement(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if names := indexNames(t, cs); !hasIndex(names, clientSoftwareIDField+"_1") {
+		t.Fatalf("indexes %v", names)
+	}
+
+	statement := softwareStatement(`{"software_id":"4NRB1-0XZABZI9E6-5SM3R","software_version":"2.1",` +
+		`"client_name":"Example Statement-based Client","client_uri":"https://client.example.net/"}`)
+
+	register := func(id string, meta RegisteredClientMetadata) {
+		t.Helper()
+
+		meta.RedirectURIs = []string{"https://client.example.net/callback"}
+
+		if err := cs.Set(&RegisteredClient{ClientID: id, ClientSecret: "secret", RegisteredClientMetadata: meta}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the claims of the statement prevail over the plain metadata
+	register("instance-1", RegisteredClientMetadata{SoftwareStatement: statement, SoftwareID: "other", SoftwareVersion: "1.0"})
+	register("instance-2", RegisteredClientMetadata{SoftwareStatement: statement})
+	register("unrelated", RegisteredClientMetadata{SoftwareID: "other"})
+
+	if err := cs.Set(&models.Client{ID: "legacy", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := cs.GetByID(ctx, "instance-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := asClient(info).GetRegistration()
+
+	if reg == nil || reg.SoftwareStatement != statement || reg.SoftwareID != "4NRB1-0XZABZI9E6-5SM3R" || reg.SoftwareVersion != "2.1" {
+		t.Fatalf("registration %+v", reg)
+	}
+
+	infos, err := cs.GetBySoftwareID(ctx, "4NRB1-0XZABZI9E6-5SM3R")
+
+	if err != nil || strings.Join(listIDs(infos), " ") != "instance-1 instance-2" {
+		t.Fatalf("clients of the software %v: %v", listIDs(infos), err)
+	}
+
+	// the disabled instances are left out
+	if err := cs.Disable(ctx, "instance-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if infos, err = cs.GetBySoftwareID(ctx, "4NRB1-0XZABZI9E6-5SM3R"); err != nil || len(infos) != 1 {
+		t.Fatalf("clients of the software %v: %v", listIDs(infos), err)
+	}
+
+	if infos, err = cs.GetBySoftwareID(ctx, "unknown"); err != nil || infos == nil || len(infos) != 0 {
+		t.Fatalf("clients of an unknown software %v: %v", infos, err)
+	}
+
+	if _, err := cs.GetBySoftwareID(ctx, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("empty software ID: %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestSoftwareStatementValidation(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.MaxSoftwareStatementSize = 256
+	cs := newTestClientStore(t, ccfg)
+
+	tests := []struct {
+		name, statement string
+	}{
+		{"oversized", softwareStatement(`{"software_id":"` + strings.Repeat("a", 256) + `"}`)},
+		{"not a JWT", "statement"},
+		{"payload not base64", "e30.%%%.c2ln"},
+		{"claims not JSON", softwareStatement("claims")},
+	}
+
+	for _, tt := range tests {
+		reg := RegisteredClientMetadata{SoftwareStatement: tt.statement, RedirectURIs: []string{"https://client.example.net/callback"}}
+
+		if err := cs.Set(&RegisteredClient{ClientID: "c", ClientSecret: "secret", RegisteredClientMetadata: reg}); !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("%s: %v, want ErrInvalidArgument", tt.name, err)
+		}
+	}
+
+	// the largest accepted
+	statement := softwareStatement(`{"software_id":"s"}`)
+	statement += strings.Repeat("a", 256-len(statement))
+	reg := RegisteredClientMetadata{SoftwareStatement: statement, RedirectURIs: []string{"https://client.example.net/callback"}}
+
+	if err := cs.Set(&RegisteredClient{ClientID: "c", ClientSecret: "secret", RegisteredClientMetadata: reg}); err != nil {
+		t.Fatal(err)
+	}
+}
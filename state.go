@@ -0,0 +1,115 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fields of the state documents, keyed by the caller's key
+const (
+	stateValueField   = "value"
+	stateExpiresField = "expires_at"
+)
+
+// StateStore short-lived single-use values of the authorization flows, e.g. the state and nonce of a login shared
+// by every frontend. It shares the connection, configuration and hooks of the token store it is created from, and
+// a TTL index on its collection(TokenConfig.StatesCName) removes the values never taken.
+type StateStore struct {
+	ts *TokenStore
+}
+
+// NewStateStore create the state store of ts and its TTL index, unless a TenantResolver is configured: call
+// EnsureIndexes then for every tenant
+func NewStateStore(ts *TokenStore) *StateStore {
+	s := &StateStore{ts: ts}
+	ts.initCollectionIndexes(ts.tcfg.StatesCName, s.indexes())
+
+	return s
+}
+
+func (s *StateStore) indexes() []mongo.IndexModel {
+	return []mongo.IndexModel{expiryIndex(stateExpiresField)}
+}
+
+// EnsureIndexes create the TTL index of the states collection in the database of the tenant of ctx
+func (s *StateStore) EnsureIndexes(ctx context.Context) error {
+	return s.ts.routedIndexes(ctx, s.ts.tcfg.StatesCName, s.indexes())
+}
+
+func (s *StateStore) op(name string) *operation {
+	return newOperation("state", name, s.ts.tcfg.StatesCName)
+}
+
+// Put store the value under key for ttl, an existing key returns a DuplicateKeyError
+func (s *StateStore) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	o := s.op("Put")
+	o.sensitive(key)
+
+	return s.ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("key", key); err != nil {
+			return err
+		}
+
+		if ttl <= 0 {
+			return fmt.Errorf("%w: state TTL %v", ErrInvalidArgument, ttl)
+		}
+
+		return s.ts.colHandler(ctx, s.ts.tcfg.StatesCName, func(ctx context.Context, c *mongo.Collection) error {
+			_, err := c.InsertOne(ctx, bson.D{
+				{Key: "_id", Value: key},
+				{Key: stateValueField, Value: value},
+				{Key: stateExpiresField, Value: time.Now().Add(ttl)},
+			})
+
+			if mongo.IsDuplicateKeyError(err) {
+				return &DuplicateKeyError{Field: "_id", Index: "_id_", Err: err}
+			}
+
+			return err
+		})
+	})
+}
+
+// Take remove the value of key and return it, a single atomic FindOneAndDelete so only one of concurrent takes
+// gets it. An unknown, taken or expired key returns ErrStateNotFound.
+func (s *StateStore) Take(ctx context.Context, key string) (value []byte, err error) {
+	o := s.op("Take")
+	o.sensitive(key)
+
+	err = s.ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("key", key); err != nil {
+			return err
+		}
+
+		return s.ts.colHandler(ctx, s.ts.tcfg.StatesCName, func(ctx context.Context, c *mongo.Collection) error {
+			raw, err := c.FindOneAndDelete(ctx, bson.M{"_id": key}).DecodeBytes()
+
+			if err == mongo.ErrNoDocuments {
+				return errStateNotFound
+			}
+
+			if err != nil {
+				return err
+			}
+
+			// an expired value not yet removed by the TTL monitor is removed all the same
+			if !time.Now().Before(lookupTime(raw, []string{stateExpiresField})) {
+				return errStateNotFound
+			}
+
+			value = lookupBinary(raw, []string{stateValueField})
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		value = nil
+	}
+
+	return
+}
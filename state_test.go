@@ -0,0 +1,140 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestStateStore(t *testing.T) {
+	ts := newTestTokenStore(t, WithCollectionNames(CollectionNames{States: "logins"}))
+	s := NewStateStore(ts)
+	ctx := context.Background()
+
+	for key, value := range map[string]string{"state:af0ifjsldkj": "/account", "nonce:n-0S6_WzA2Mj": "n-0S6_WzA2Mj"} {
+		if err := s.Put(ctx, key, []byte(value), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := ts.Database().Collection("logins").CountDocuments(ctx, bson.M{}); err != nil || n != 2 {
+		t.Fatalf("%d values in the configured collection: %v", n, err)
+	}
+
+	var de *DuplicateKeyError
+
+	if err := s.Put(ctx, "state:af0ifjsldkj", []byte("/other"), time.Minute); !errors.As(err, &de) {
+		t.Fatalf("key put again: %v, want a DuplicateKeyError", err)
+	}
+
+	if value, err := s.Take(ctx, "state:af0ifjsldkj"); err != nil || string(value) != "/account" {
+		t.Fatalf("taken %q: %v", value, err)
+	}
+
+	// single-use, the other values kept
+	value, err := s.Take(ctx, "state:af0ifjsldkj")
+
+	if !errors.Is(err, ErrStateNotFound) || value != nil {
+		t.Fatalf("taken again %q: %v, want ErrStateNotFound", value, err)
+	}
+
+	// the key, a bearer value of the login, isn't in the errors
+	if strings.Contains(err.Error(), "af0ifjsldkj") {
+		t.Fatalf("error %q with the key", err)
+	}
+
+	if value, err := s.Take(ctx, "nonce:n-0S6_WzA2Mj"); err != nil || string(value) != "n-0S6_WzA2Mj" {
+		t.Fatalf("taken %q: %v", value, err)
+	}
+
+	for name, err := range map[string]error{
+		"without key": s.Put(ctx, "", []byte("value"), time.Minute),
+		"without TTL": s.Put(ctx, "key", []byte("value"), 0),
+		"take without key": func() error {
+			_, err := s.Take(ctx, "")
+			return err
+		}(),
+	} {
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("%s: %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
+
+func TestStateTakeConcurrent(t *testing.T) {
+	ts := newTestTokenStore(t)
+	requireAtomicFindAndModify(t, ts.Database())
+
+	s := NewStateStore(ts)
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "state", []byte("value"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	const takers = 8
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, takers)
+
+	for i := 0; i < takers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := s.Take(ctx, "state")
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	taken := 0
+
+	for err := range errs {
+		switch {
+		case err == nil:
+			taken++
+		case !errors.Is(err, ErrStateNotFound):
+			t.Fatal(err)
+		}
+	}
+
+	if taken != 1 {
+		t.Fatalf("taken %d times", taken)
+	}
+}
+
+func TestStateExpiry(t *testing.T) {
+	ts := newTestTokenStore(t)
+	s := NewStateStore(ts)
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "state", []byte("value"), 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// expired before the TTL monitor removes it, and removed by the attempt
+	if value, err := s.Take(ctx, "state"); !errors.Is(err, ErrStateNotFound) || value != nil {
+		t.Fatalf("expired value %q: %v, want ErrStateNotFound", value, err)
+	}
+
+	if n, err := ts.Database().Collection(ts.tcfg.StatesCName).CountDocuments(ctx, bson.M{}); err != nil || n != 0 {
+		t.Fatalf("%d values left: %v", n, err)
+	}
+
+	// the key can be put again
+	if err := s.Put(ctx, "state", []byte("value"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,63 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ClientStatus whether a client may be used, changed by SetStatus
+type ClientStatus string
+
+// client statuses
+const (
+	// ClientActive the default status, also of the clients stored before the status was recorded
+	ClientActive ClientStatus = "active"
+	// ClientSuspended the lookups fail with ErrClientDisabled until the client is active again,
+	// unlike Disable the client isn't purged
+	ClientSuspended ClientStatus = "disabled"
+)
+
+// field of the client status, absent for active clients
+const clientStatusField = "status"
+
+// valid report whether the status is known
+func (s ClientStatus) valid() bool {
+	return s == ClientActive || s == ClientSuspended
+}
+
+// statusFilter match the clients with the status
+func statusFilter(status ClientStatus) bson.M {
+	if status == ClientSuspended {
+		return bson.M{clientStatusField: string(ClientSuspended)}
+	}
+
+	return bson.M{clientStatusField: bson.M{"$ne": string(ClientSuspended)}}
+}
+
+// decodeStatus the status of a stored client
+func decodeStatus(raw bson.Raw) ClientStatus {
+	if ClientStatus(lookupString(raw, []string{clientStatusField})) == ClientSuspended {
+		return ClientSuspended
+	}
+
+	return ClientActive
+}
+
+// SetStatus change the status of the client, taking effect with the next lookup. The status is kept by Set.
+// A missing client returns ErrClientNotFound.
+func (cs *ClientStore) SetStatus(ctx context.Context, id string, status ClientStatus) error {
+	if !status.valid() {
+		return fmt.Errorf("%w: client status %q", ErrInvalidArgument, status)
+	}
+
+	update := bson.M{"$set": bson.M{clientUpdatedField: time.Now()}, "$unset": bson.M{clientStatusField: ""}}
+
+	if status == ClientSuspended {
+		update = bson.M{"$set": bson.M{clientStatusField: string(status), clientUpdatedField: time.Now()}}
+	}
+
+	return cs.setDisabled(ctx, "SetStatus", id, update)
+}
@@ -0,0 +1,117 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSetStatus(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	requireStatus := func(want ClientStatus) {
+		t.Helper()
+
+		var raw bson.M
+
+		if err := cs.Collection().FindOne(ctx, bson.M{"_id": "c"}).Decode(&raw); err != nil {
+			t.Fatal(err)
+		}
+
+		if status, ok := raw[clientStatusField]; want == ClientActive && ok || want == ClientSuspended && status != string(want) {
+			t.Fatalf("stored status %v, want %s", status, want)
+		}
+
+		infos, _, err := cs.List(ctx, ClientFilter{Status: want}, ListOptions{})
+
+		if err != nil || len(infos) != 1 || asClient(infos[0]).Status != want {
+			t.Fatalf("clients with the status %s: %v, %v", want, infos, err)
+		}
+	}
+
+	transitions := []struct {
+		status ClientStatus
+		err    error
+	}{
+		{ClientActive, nil},
+		{ClientSuspended, ErrClientDisabled},
+		{ClientSuspended, ErrClientDisabled},
+		{ClientActive, nil},
+	}
+
+	for _, tr := range transitions {
+		if err := cs.SetStatus(ctx, "c", tr.status); err != nil {
+			t.Fatal(err)
+		}
+
+		requireStatus(tr.status)
+
+		info, err := cs.GetByID(ctx, "c")
+
+		if !errors.Is(err, tr.err) {
+			t.Fatalf("%s client: %v, want %v", tr.status, err, tr.err)
+		}
+
+		if tr.err != nil && !errors.Is(err, ErrClientNotFound) {
+			t.Fatalf("%s client: %v isn't ErrClientNotFound", tr.status, err)
+		}
+
+		if tr.err == nil && asClient(info).Status != ClientActive {
+			t.Fatalf("status %s, want %s", asClient(info).Status, ClientActive)
+		}
+	}
+
+	// Set keeps the status
+	if err := cs.SetStatus(ctx, "c", ClientSuspended); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "other", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	requireStatus(ClientSuspended)
+
+	if err := cs.SetStatus(ctx, "c", "unknown"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("unknown status: %v, want ErrInvalidArgument", err)
+	}
+
+	if err := cs.SetStatus(ctx, "missing", ClientSuspended); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v, want ErrClientNotFound", err)
+	}
+}
+
+func TestHideSuspendedClients(t *testing.T) {
+	ccfg := NewDefaultClientConfig()
+	ccfg.HideDisabledClients = true
+	cs := newTestClientStore(t, ccfg)
+	ctx := context.Background()
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.SetStatus(ctx, "c", ClientSuspended); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cs.GetByID(ctx, "c"); !errors.Is(err, ErrClientNotFound) || errors.Is(err, ErrClientDisabled) {
+		t.Fatalf("hidden suspended client: %v, want only ErrClientNotFound", err)
+	}
+
+	if err := cs.SetStatus(ctx, "c", ClientActive); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cs.GetByID(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,100 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Store token and client stores sharing a single mongo client and configuration
+type Store struct {
+	conns   *connHolder
+	tokens  *TokenStore
+	clients *ClientStore
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewStore connect to mongodb and create the token and client stores on the same client,
+// the options apply to both stores(see WithTokenConfig and WithClientConfig for per store settings)
+func NewStore(cfg *Config, opts ...Option) (*Store, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.connectTimeout())
+
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, cfg.BuildClientOptions())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStoreWithDB(client.Database(cfg.DB), append([]Option{compatibilityOption(cfg), registryOption(cfg)}, opts...)...), nil
+}
+
+// NewStoreWithDB create the token and client stores on the given database handle
+func NewStoreWithDB(db *mongo.Database, opts ...Option) *Store {
+	conns := newConnHolder(db)
+
+	topts := make([]TokenOption, len(opts))
+	copts := make([]ClientOption, len(opts))
+
+	for i, opt := range opts {
+		topts[i], copts[i] = opt, opt
+	}
+
+	return &Store{
+		conns:   conns,
+		tokens:  newTokenStore(conns, true, topts),
+		clients: newClientStore(conns, true, copts),
+	}
+}
+
+// Tokens the token store
+func (s *Store) Tokens() *TokenStore {
+	return s.tokens
+}
+
+// Clients the client store
+func (s *Store) Clients() *ClientStore {
+	return s.clients
+}
+
+// Reconnect swap the mongo client of both stores, see TokenStore.Reconnect
+func (s *Store) Reconnect(ctx context.Context, opts *options.ClientOptions) error {
+	return s.tokens.Reconnect(ctx, opts)
+}
+
+// Close wait up to 15 seconds for the in-flight operations and close the mongo connection
+func (s *Store) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	s.Shutdown(ctx)
+}
+
+// Shutdown stop both stores, wait for their in-flight operations until ctx is done
+// and close the shared mongo connection, only the first call has any effect
+func (s *Store) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		err := s.tokens.Shutdown(ctx)
+
+		if cerr := s.clients.Shutdown(ctx); err == nil {
+			err = cerr
+		}
+
+		if derr := s.conns.client().Disconnect(ctx); err == nil {
+			err = derr
+		}
+
+		s.closeErr = err
+	})
+
+	return s.closeErr
+}
@@ -0,0 +1,145 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestStoreSharesOneClient(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events = make(map[string]int)
+	)
+
+	cfg := testConfig(t)
+	cfg.PoolMonitor = &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			mu.Lock()
+			events[e.Type]++
+			mu.Unlock()
+		},
+	}
+
+	s, err := NewStore(cfg)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	if s.Tokens().conns != s.Clients().conns {
+		t.Fatal("the stores don't share their connection")
+	}
+
+	if err := s.Tokens().Create(ctx, testToken("access", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Clients().Set(&models.Client{ID: "c", Secret: "s", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Close()
+	s.Close()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown after close: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// a single server: one pool per client
+	if events[event.PoolCreated] != 1 || events[event.PoolClosedEvent] != 1 {
+		t.Fatalf("%d pools created and %d closed, want 1", events[event.PoolCreated], events[event.PoolClosedEvent])
+	}
+}
+
+func TestStoreCloseStopsBoth(t *testing.T) {
+	s, err := NewStore(testConfig(t))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Tokens().GetByAccess(ctx, "access"); !errors.Is(err, ErrStoreClosed) {
+		t.Fatalf("token store after shutdown: %v, want ErrStoreClosed", err)
+	}
+
+	if _, err := s.Clients().GetByID(ctx, "c"); !errors.Is(err, ErrStoreClosed) {
+		t.Fatalf("client store after shutdown: %v, want ErrStoreClosed", err)
+	}
+}
+
+// the options apply to both stores, the per store configuration to its store only
+func TestStoreOptions(t *testing.T) {
+	tcfg := NewDefaultTokenConfig()
+	tcfg.BasicCName = "store_basic"
+
+	s := NewStoreWithDB(testDatabase(t), WithCollectionNames(CollectionNames{Clients: "store_clients"}), WithTokenConfig(tcfg), WithSlowOpThreshold(1))
+
+	if s.Tokens().tcfg.BasicCName != "store_basic" || s.Clients().ccfg.ClientsCName != "store_clients" {
+		t.Fatalf("collections %s and %s", s.Tokens().tcfg.BasicCName, s.Clients().ccfg.ClientsCName)
+	}
+
+	if s.Tokens().tcfg.SlowOpThreshold != 1 || s.Clients().ccfg.SlowOpThreshold != 1 {
+		t.Fatal("shared option not applied to both stores")
+	}
+}
+
+// the combined store resolves the compatibility mode like the stores created on their own
+func TestStoreCompatibility(t *testing.T) {
+	// unreachable, the index creation and the probes fail fast
+	cfg := NewConfig("mongodb://acct.mongo.cosmos.azure.com:10255/?serverSelectionTimeoutMS=50&connectTimeoutMS=50", "oauth2")
+
+	s, err := NewStore(cfg)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	ts := NewTokenStore(cfg)
+	defer ts.Close()
+
+	cs := NewClientStore(cfg)
+	defer cs.Close()
+
+	if got, want := s.Tokens().tcfg.Compatibility, ts.tcfg.Compatibility; got != want || got != CompatCosmosDB {
+		t.Fatalf("token store mode %v, want %v", got, want)
+	}
+
+	if got, want := s.Clients().ccfg.Compatibility, cs.ccfg.Compatibility; got != want || got != CompatCosmosDB {
+		t.Fatalf("client store mode %v, want %v", got, want)
+	}
+
+	if !s.Tokens().transactionsDisabled() || !s.Clients().transactionsDisabled() {
+		t.Fatal("transactions enabled on Cosmos DB")
+	}
+
+	// an explicit mode wins over the detected one
+	explicit, err := NewStore(cfg, WithCompatibility(CompatMongoDB))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer explicit.Close()
+
+	if mode := explicit.Tokens().tcfg.Compatibility; mode != CompatMongoDB {
+		t.Fatalf("explicit mode %v", mode)
+	}
+}
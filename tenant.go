@@ -0,0 +1,115 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type tenantKey struct{}
+
+// ContextWithTenant attach the tenant of the request to ctx
+func ContextWithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext the tenant attached by ContextWithTenant, empty if none
+func TenantFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantKey{}).(string)
+	return tenantID
+}
+
+// KeyResolver resolve an encryption key(AES-256, 32 bytes) of a tenant: its current key when keyID is empty,
+// used to encrypt, else the key keyID which encrypted a stored payload, so previous keys remain readable
+type KeyResolver func(ctx context.Context, tenantID, keyID string) (resolvedKeyID string, key []byte, err error)
+
+// KeyResolutionError returned when the key of a tenant can't be resolved, as opposed to
+// a DecryptError returned when the resolved key doesn't decrypt the payload
+type KeyResolutionError struct {
+	TenantID string
+	KeyID    string
+	Err      error
+}
+
+func (e *KeyResolutionError) Error() string {
+	return fmt.Sprintf("mongo: resolve key %q of tenant %q: %v", e.KeyID, e.TenantID, e.Err)
+}
+
+// Unwrap the underlying error
+func (e *KeyResolutionError) Unwrap() error {
+	return e.Err
+}
+
+// tenantOf the tenant owning the token information: the tenant of ctx, else the client
+func (ts *TokenStore) tenantOf(ctx context.Context, info oauth2.TokenInfo) string {
+	if ts.tcfg.TenantOf != nil {
+		return ts.tcfg.TenantOf(ctx, info)
+	}
+
+	if tenantID := TenantFromContext(ctx); tenantID != "" {
+		return tenantID
+	}
+
+	return info.GetClientID()
+}
+
+// tenantEncrypter the encrypter of a tenant key: the current one when keyID is empty, else the key keyID
+func (ts *TokenStore) tenantEncrypter(ctx context.Context, tenantID, keyID string) (Encrypter, error) {
+	resolved, key, err := ts.tcfg.KeyResolver(ctx, tenantID, keyID)
+
+	if err != nil {
+		return nil, &KeyResolutionError{TenantID: tenantID, KeyID: keyID, Err: err}
+	}
+
+	if keyID != "" && resolved != keyID {
+		return nil, &KeyResolutionError{TenantID: tenantID, KeyID: keyID, Err: fmt.Errorf("resolved key %q", resolved)}
+	}
+
+	e, err := NewAESGCMEncrypter(resolved, map[string][]byte{resolved: key})
+
+	if err != nil {
+		return nil, &KeyResolutionError{TenantID: tenantID, KeyID: resolved, Err: err}
+	}
+
+	return e, nil
+}
+
+// TenantResolver the database(empty for the store's database) and the collection name prefix
+// of the tenant of an operation, e.g. from TenantFromContext
+type TenantResolver func(ctx context.Context) (dbName string, prefix string, err error)
+
+// routedDB the database of a tenant, its collections carry the tenant prefix
+type routedDB struct {
+	*mongo.Database
+	prefix string
+}
+
+// Collection the prefixed collection
+func (d routedDB) Collection(name string, opts ...*options.CollectionOptions) *mongo.Collection {
+	return d.Database.Collection(d.prefix+name, opts...)
+}
+
+// route the database of the operation, the store's one unless a tenant resolver is configured.
+// A tenant database keeps the read/write settings of the store's database.
+func route(ctx context.Context, conns *connHolder, resolver TenantResolver) (routedDB, error) {
+	db := conns.database()
+
+	if resolver == nil {
+		return routedDB{Database: db}, nil
+	}
+
+	name, prefix, err := resolver(ctx)
+
+	if err != nil {
+		return routedDB{}, err
+	}
+
+	if name != "" && name != db.Name() {
+		db = sibling(db.Client(), name, db)
+	}
+
+	return routedDB{Database: db, prefix: prefix}, nil
+}
@@ -0,0 +1,301 @@
+package mongo
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// tenantKeys the keys of the tenants by key id, the last added key of a tenant is its current one
+type tenantKeys struct {
+	mu      sync.Mutex
+	current map[string]string
+	keys    map[string]map[string][]byte
+}
+
+func newTenantKeys() *tenantKeys {
+	return &tenantKeys{current: make(map[string]string), keys: make(map[string]map[string][]byte)}
+}
+
+// add a key derived from the tenant and key id, made current
+func (tk *tenantKeys) add(tenantID, keyID string) {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+
+	if tk.keys[tenantID] == nil {
+		tk.keys[tenantID] = make(map[string][]byte)
+	}
+
+	key := sha256.Sum256([]byte(tenantID + "/" + keyID))
+	tk.keys[tenantID][keyID] = key[:]
+	tk.current[tenantID] = keyID
+}
+
+func (tk *tenantKeys) resolve(_ context.Context, tenantID, keyID string) (string, []byte, error) {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+
+	if keyID == "" {
+		keyID = tk.current[tenantID]
+	}
+
+	key, ok := tk.keys[tenantID][keyID]
+
+	if !ok {
+		return "", nil, fmt.Errorf("no key %q", keyID)
+	}
+
+	return keyID, key, nil
+}
+
+func TestTenantKeys(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	keys := newTenantKeys()
+	keys.add("a", "a1")
+	keys.add("b", "b1")
+
+	ts := testTokenStoreWithDB(db, WithKeyResolver(keys.resolve))
+	actx, bctx := ContextWithTenant(ctx, "a"), ContextWithTenant(ctx, "b")
+
+	if err := ts.Create(actx, testToken("a-before", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Create(bctx, testToken("b-token", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a rotation of tenant a, its previous key stays readable
+	keys.add("a", "a2")
+
+	if err := ts.Create(actx, testToken("a-after", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := make(map[string]string)
+	cur, err := ts.Collection(CollectionBasic).Find(ctx, testKindFilter(ts, CollectionBasic, bson.M{}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for cur.Next(ctx) {
+		stored[lookupString(cur.Current, []string{"KeyID"})] = lookupString(cur.Current, []string{"Tenant"})
+	}
+
+	if want := map[string]string{"a1": "a", "a2": "a", "b1": "b"}; fmt.Sprint(stored) != fmt.Sprint(want) {
+		t.Fatalf("stored keys %v, want %v", stored, want)
+	}
+
+	for _, token := range []string{"a-before", "a-after", "b-token"} {
+		if _, err := ts.GetByAccess(ctx, token); err != nil {
+			t.Fatalf("%s: %v", token, err)
+		}
+	}
+}
+
+// the keys of the tenants swapped: the payloads don't decrypt
+func TestTenantKeysSwapped(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	keys := newTenantKeys()
+	keys.add("a", "k1")
+	keys.add("b", "k1")
+
+	if err := testTokenStoreWithDB(db, WithKeyResolver(keys.resolve)).Create(ContextWithTenant(ctx, "a"), testToken("access", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	swapped := func(ctx context.Context, tenantID, keyID string) (string, []byte, error) {
+		return keys.resolve(ctx, map[string]string{"a": "b", "b": "a"}[tenantID], keyID)
+	}
+
+	var de *DecryptError
+
+	if _, err := testTokenStoreWithDB(db, WithKeyResolver(swapped)).GetByAccess(ctx, "access"); !errors.As(err, &de) {
+		t.Fatalf("swapped keys: %v", err)
+	}
+}
+
+// a key which can't be resolved is a KeyResolutionError, not a DecryptError
+func TestTenantKeyResolutionError(t *testing.T) {
+	ctx := context.Background()
+	db := testDatabase(t)
+
+	keys := newTenantKeys()
+	keys.add("a", "a1")
+
+	ts := testTokenStoreWithDB(db, WithKeyResolver(keys.resolve))
+
+	if err := ts.Create(ContextWithTenant(ctx, "a"), testToken("access", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	var re *KeyResolutionError
+	var de *DecryptError
+
+	// the key removed from the key store
+	forgot := testTokenStoreWithDB(db, WithKeyResolver(newTenantKeys().resolve))
+
+	if _, err := forgot.GetByAccess(ctx, "access"); !errors.As(err, &re) || errors.As(err, &de) || re.TenantID != "a" || re.KeyID != "a1" {
+		t.Fatalf("unknown key: %v", err)
+	}
+
+	// a tenant without key can't create
+	if err := ts.Create(ContextWithTenant(ctx, "unknown"), testToken("other", "")); !errors.As(err, &re) {
+		t.Fatalf("tenant without key: %v", err)
+	}
+}
+
+// tenantStores token and client stores serving the tenants "a" and "b" through resolver
+func tenantStores(t *testing.T, resolver func(db *mongo.Database, tenantID string) (string, string)) (*TokenStore, *ClientStore, *mongo.Database) {
+	t.Helper()
+
+	db := testDatabase(t)
+
+	opt := WithTenantResolver(func(ctx context.Context) (string, string, error) {
+		tenantID := TenantFromContext(ctx)
+
+		if tenantID == "" {
+			return "", "", errors.New("no tenant")
+		}
+
+		dbName, prefix := resolver(db, tenantID)
+		return dbName, prefix, nil
+	})
+
+	for _, tenantID := range []string{"a", "b"} {
+		if dbName, _ := resolver(db, tenantID); dbName != "" {
+			uri := testURI(t)
+			t.Cleanup(func() { dropTestDatabase(uri, dbName) })
+		}
+	}
+
+	return testTokenStoreWithDB(db, opt), NewClientStoreWithDB(db, opt), db
+}
+
+func TestTenantResolver(t *testing.T) {
+	cases := []struct {
+		name     string
+		resolver func(db *mongo.Database, tenantID string) (string, string)
+	}{
+		{"prefix", func(_ *mongo.Database, tenantID string) (string, string) { return "", tenantID + "_" }},
+		{"database", func(db *mongo.Database, tenantID string) (string, string) { return db.Name() + "_" + tenantID, "" }},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			ts, cs, db := tenantStores(t, tc.resolver)
+
+			ctx := context.Background()
+			actx, bctx := ContextWithTenant(ctx, "a"), ContextWithTenant(ctx, "b")
+
+			// the same keys and client in both tenants, interleaved
+			for _, tenantID := range []string{"a", "b"} {
+				tctx := ContextWithTenant(ctx, tenantID)
+
+				token := testToken("access", "refresh")
+				token.UserID = "user-" + tenantID
+
+				if err := ts.Create(tctx, token); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := ts.Create(tctx, testToken("only-"+tenantID, "")); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := cs.SetWithMetadata(tctx, &models.Client{ID: "c", Secret: "secret-" + tenantID, Domain: "https://example.com"}, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, tenantID := range []string{"a", "b"} {
+				tctx := ContextWithTenant(ctx, tenantID)
+
+				if info, err := ts.GetByAccess(tctx, "access"); err != nil || info.GetUserID() != "user-"+tenantID {
+					t.Fatalf("tenant %s access token %v: %v", tenantID, info, err)
+				}
+
+				if info, err := ts.GetByRefresh(tctx, "refresh"); err != nil || info.GetUserID() != "user-"+tenantID {
+					t.Fatalf("tenant %s refresh token %v: %v", tenantID, info, err)
+				}
+
+				if info, err := cs.GetByID(tctx, "c"); err != nil || info.GetSecret() != "secret-"+tenantID {
+					t.Fatalf("tenant %s client %v: %v", tenantID, info, err)
+				}
+			}
+
+			// the tokens of a tenant are never found by the other
+			if info, err := ts.GetByAccess(bctx, "only-a"); err == nil && info != nil {
+				t.Fatalf("tenant b found the token of tenant a: %v", info)
+			}
+
+			if info, err := ts.GetByAccess(actx, "only-b"); err == nil && info != nil {
+				t.Fatalf("tenant a found the token of tenant b: %v", info)
+			}
+
+			// removals stay within their tenant
+			if err := ts.RemoveByAccess(actx, "access"); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := cs.RemoveClient(actx, "c"); err != nil {
+				t.Fatal(err)
+			}
+
+			if info, err := ts.GetByAccess(bctx, "access"); err != nil || info.GetUserID() != "user-b" {
+				t.Fatalf("tenant b access token after the removal in tenant a %v: %v", info, err)
+			}
+
+			if info, err := cs.GetByID(bctx, "c"); err != nil || info.GetSecret() != "secret-b" {
+				t.Fatalf("tenant b client after the removal in tenant a %v: %v", info, err)
+			}
+
+			// nothing is stored outside the tenants
+			names, err := db.ListCollectionNames(ctx, bson.M{})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range names {
+				if tc.name == "database" || !strings.HasPrefix(name, "a_") && !strings.HasPrefix(name, "b_") {
+					if n, err := db.Collection(name).CountDocuments(ctx, bson.M{}); err != nil || n != 0 {
+						t.Errorf("%d documents in the untenanted collection %s: %v", n, name, err)
+					}
+				}
+			}
+
+			// an operation without tenant fails rather than reaching a default one
+			if _, err := ts.GetByAccess(ctx, "access"); err == nil {
+				t.Fatal("token lookup without tenant")
+			}
+
+			// the indexes are created per tenant, last: the TTL indexes may not be implemented
+			for _, tctx := range []context.Context{actx, bctx} {
+				if err := cs.EnsureIndexes(tctx); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := ts.EnsureIndexes(tctx); err != nil {
+					skipNotImplemented(t, err)
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
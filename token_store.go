@@ -2,7 +2,6 @@ package mongo
 
 import (
 	"context"
-	"encoding/json"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -12,6 +11,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // TokenConfig token configuration parameters
@@ -24,148 +25,685 @@ type TokenConfig struct {
 	AccessCName string
 	// store refresh token data collection name(The default is oauth2_refresh)
 	RefreshCName string
+	// store revoked JWT ids collection name, expired entries are removed by a TTL index(The default is oauth2_denylist)
+	DenylistCName string
+	// collection of the ConsentStore(The default is oauth2_consents)
+	ConsentsCName string
+	// collection of the PARStore, expired requests are removed by a TTL index(The default is oauth2_par)
+	PARCName string
+	// collection of the KeyStore(The default is oauth2_keys)
+	KeysCName string
+	// collection of the StateStore, expired values are removed by a TTL index(The default is oauth2_states)
+	StatesCName string
+	// collection of the DeviceStore, expired authorizations are removed by a TTL index(The default is oauth2_device)
+	DeviceCName string
+	// read preference used by GetByAccess(The default is the client's read preference).
+	// GetByCode and GetByRefresh consume their data right after reading it,
+	// so they always read from the primary regardless of this setting.
+	ReadPreference *readpref.ReadPref
+	// read concern used by GetByAccess(The default is the client's read concern)
+	ReadConcern *readconcern.ReadConcern
+	// circuit breaker guarding the mongo operations(The default is no breaker)
+	Breaker *Breaker
+	// logger receiving the store records(The default discards them)
+	Logger Logger
+	// metrics receiving the outcome of every operation(The default discards them)
+	Metrics Metrics
+	// tracer starting a span around every operation(The default is no tracing)
+	Tracer Tracer
+	// operations taking longer are logged as slow operations(The default 0 disables it)
+	SlowOpThreshold time.Duration
+	// timeout of the lookups, WithReadTimeout(0) adds no timeout beyond the caller's context(The default is 15s)
+	ReadTimeout time.Duration
+	// timeout of the writes and their transaction, WithWriteTimeout(0) adds no timeout beyond the caller's
+	// context(The default is 15s)
+	WriteTimeout time.Duration
+	// serialization of the token payload, BSONCodec stores it as a subdocument(The default is JSONCodec)
+	Codec Codec
+	// additional codecs able to read payloads written by previously configured codecs,
+	// JSONCodec and ExtJSONCodec are always known
+	ReadCodecs []Codec
+	// database and collection prefix of the tenant of each operation, one store then serves every
+	// tenant, see EnsureIndexes(The default is the store's database without prefix)
+	TenantResolver TenantResolver
+	// per-tenant encryption keys of the token payloads, taking precedence over Encrypter for new documents.
+	// The stored documents record their tenant and key, which decrypts them(The default is none)
+	KeyResolver KeyResolver
+	// tenant owning the token information(The default is the tenant of the context, else the client ID)
+	TenantOf func(ctx context.Context, info oauth2.TokenInfo) string
+	// issuer of the tokens, recorded on the documents, part of their keys and of every lookup.
+	// Stores of several issuers then share the collections even with colliding token values,
+	// ContextWithIssuer overrides it per operation(The default is none)
+	Issuer string
+	// prefix of the code/access/refresh document keys, also recorded on the documents to scope the bulk
+	// operations: stores with different prefixes sharing the collections never see each other's tokens(The default is none)
+	KeyPrefix string
+	// key of the HMAC pseudonyms recorded instead of the user ids on the documents, the lookups by user hash
+	// their input alike. The payload keeps the user id, see Encrypter(The default records the user ids as given)
+	UserIDKey []byte
+	// encryption of the token payload, documents stored before it was set remain readable(The default is no encryption)
+	Encrypter Encrypter
+	// store the access/refresh tokens as SHA-256 hashes instead of plaintext keys(The default is false)
+	HashTokens bool
+	// secret mixed into the token hashes with HMAC-SHA256(The default is none)
+	TokenPepper []byte
+	// also match the plaintext keys while MigrateTokenHashes hasn't completed(The default is false)
+	ReadPlaintextTokens bool
+	// BSON field names of the documents(The default is LegacyFieldNames)
+	FieldNames FieldNames
+	// run the writes without session transactions, e.g. on standalone servers. A failed Create then removes
+	// the documents it already inserted(The default is false)
+	DisableTransactions bool
+	// the MongoDB compatible service, adjusting transactions and indexes to it(The default is CompatAuto)
+	Compatibility CompatibilityMode
+	// store the basic, access and refresh documents in the single TokensCName collection, the code/access/refresh
+	// keys prefixed with their kind. The other token collection names then name the kinds only(The default is false)
+	SingleCollection bool
+	// collection of the single collection layout(The default is oauth2_tokens)
+	TokensCName string
+	// don't store the access tokens, e.g. self-contained JWT access tokens: the lookups by access token return
+	// ErrUnsupported while the codes and refresh tokens are stored as usual(The default is false)
+	SkipAccessTokenStorage bool
+	// with SkipAccessTokenStorage, RemoveByAccess denies the jti of the JWT(or its hash without jti) until its exp
+	// instead of doing nothing, see IsAccessDenied(The default is false)
+	DenyRemovedAccessTokens bool
+	// RemoveByAccess and RemoveByRefresh also remove the token families exchanged from the removed token,
+	// see CreateExchanged(The default is false)
+	CascadeExchanges bool
+	// keep up to MemoryCodes authorization codes in process memory instead of mongo, evicting the oldest ones
+	// beyond. The codes are then only known to the instance which issued them: every request of an authorization
+	// must reach the same instance(e.g. sticky sessions), and they are lost on restart(The default is 0, stored in mongo)
+	MemoryCodes int
+	// refuse every write with ErrReadOnlyStore before reaching mongo, e.g. on resource servers connecting as a read-only
+	// user: no index is created and no transaction started, the lookups which read from the primary after a write
+	// follow ReadPreference as well(The default is false)
+	ReadOnly bool
 }
 
 // NewDefaultTokenConfig create a default token configuration
 func NewDefaultTokenConfig() *TokenConfig {
 	return &TokenConfig{
-		TxnCName:     "oauth2_txn",
-		BasicCName:   "oauth2_basic",
-		AccessCName:  "oauth2_access",
-		RefreshCName: "oauth2_refresh",
+		TxnCName:      "oauth2_txn",
+		BasicCName:    "oauth2_basic",
+		AccessCName:   "oauth2_access",
+		RefreshCName:  "oauth2_refresh",
+		TokensCName:   "oauth2_tokens",
+		DenylistCName: "oauth2_denylist",
+		ConsentsCName: "oauth2_consents",
+		PARCName:      "oauth2_par",
+		KeysCName:     "oauth2_keys",
+		StatesCName:   "oauth2_states",
+		DeviceCName:   "oauth2_device",
+
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+	}
+}
+
+// defaultCNames give the collection names left empty their default, e.g. the DenylistCName
+// of a configuration written before the denylist existed
+func (tcfg *TokenConfig) defaultCNames() {
+	def := NewDefaultTokenConfig()
+
+	for _, name := range []struct {
+		dst *string
+		def string
+	}{
+		{&tcfg.TxnCName, def.TxnCName},
+		{&tcfg.BasicCName, def.BasicCName},
+		{&tcfg.AccessCName, def.AccessCName},
+		{&tcfg.RefreshCName, def.RefreshCName},
+		{&tcfg.TokensCName, def.TokensCName},
+		{&tcfg.DenylistCName, def.DenylistCName},
+		{&tcfg.ConsentsCName, def.ConsentsCName},
+		{&tcfg.PARCName, def.PARCName},
+		{&tcfg.KeysCName, def.KeysCName},
+		{&tcfg.StatesCName, def.StatesCName},
+		{&tcfg.DeviceCName, def.DeviceCName},
+	} {
+		if *name.dst == "" {
+			*name.dst = name.def
+		}
 	}
 }
 
 // NewTokenStore create a token store instance based on mongodb
-func NewTokenStore(cfg *Config, tcfgs ...*TokenConfig) (store *TokenStore) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func NewTokenStore(cfg *Config, opts ...TokenOption) (store *TokenStore) {
+	if err := cfg.Validate(); err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.connectTimeout())
 
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URL))
+	client, err := mongo.Connect(ctx, cfg.BuildClientOptions())
 
 	if err != nil {
 		panic(err)
 	}
 
-	return NewTokenStoreWithSession(client, cfg.DB, tcfgs...)
+	return NewTokenStoreWithSession(client, cfg.DB, append([]TokenOption{compatibilityOption(cfg)}, opts...)...)
+}
+
+// NewTokenStoreWithSession create a token store instance based on mongodb,
+// monitoring(pool, server and command events) of the given client is the caller's responsibility
+func NewTokenStoreWithSession(client *mongo.Client, dbName string, opts ...TokenOption) *TokenStore {
+	return NewTokenStoreWithDB(client.Database(dbName), opts...)
 }
 
-// NewTokenStoreWithSession create a token store instance based on mongodb
-func NewTokenStoreWithSession(client *mongo.Client, dbName string, tcfgs ...*TokenConfig) *TokenStore {
+// NewTokenStoreWithDB create a token store instance using the given database handle,
+// all collections inherit its read/write concerns, read preference and codec registry
+func NewTokenStoreWithDB(db *mongo.Database, opts ...TokenOption) *TokenStore {
+	return newTokenStore(newConnHolder(db), false, opts)
+}
+
+// newTokenStore create a token store on top of conns, a shared store leaves the
+// disconnection to its owner
+func newTokenStore(conns *connHolder, shared bool, opts []TokenOption) *TokenStore {
 	ts := &TokenStore{
-		client: client,
-		dbName: dbName,
-		tcfg:   NewDefaultTokenConfig(),
+		conns:  conns,
+		shared: shared,
+		tcfg:   newTokenConfig(opts),
+		grants: newGrantSessions(),
+	}
+
+	ts.codecs = newCodecs(ts.tcfg.Codec, ts.tcfg.ReadCodecs)
+
+	if ts.tcfg.MemoryCodes > 0 {
+		ts.codes = newMemoryCodes(ts.tcfg.MemoryCodes)
 	}
 
-	if len(tcfgs) > 0 {
-		ts.tcfg = tcfgs[0]
+	ts.caps = probeCapabilities(ts.tcfg.Compatibility, &ts.tcfg.DisableTransactions, conns, ts.logger())
+
+	// a routed store has no database of its own, EnsureIndexes runs per tenant
+	if ts.tcfg.TenantResolver == nil && !ts.tcfg.ReadOnly {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+
+		defer cancel()
+
+		ts.ensureIndexes(ctx, routedDB{Database: conns.database()})
+	}
+
+	return ts
+}
+
+// EnsureIndexes create the indexes of the token collections, the store creates them at construction
+// unless a TenantResolver is configured: call it then for every tenant with a context resolving to it
+func (ts *TokenStore) EnsureIndexes(ctx context.Context) error {
+	if err := ts.writable(); err != nil {
+		return err
+	}
+
+	db, err := route(ctx, ts.conns, ts.tcfg.TenantResolver)
+
+	if err != nil {
+		return err
+	}
+
+	return ts.ensureIndexes(ctx, db)
+}
+
+// ensureIndexes create the ExpiredAt and lookup indexes of the token collections and the TTL index of the denylist,
+// returning the first failure
+func (ts *TokenStore) ensureIndexes(ctx context.Context, db routedDB) (err error) {
+	for _, name := range ts.tokenCNames() {
+		if cerr := ts.ensureCollectionIndexes(ctx, db, name, ts.tokenIndexes(name)); err == nil {
+			err = cerr
+		}
+	}
+
+	for _, name := range append(ts.tokenCNames(), ts.tcfg.DenylistCName) {
+		model := mongo.IndexModel{
+			Keys: bson.M{
+				ts.fields().ExpiredAt: 1, // index in ascending order
+			},
+		}
+
+		if name == ts.tcfg.DenylistCName {
+			model = ts.denylistIndex()
+		}
+
+		_, cerr := db.Collection(name).Indexes().CreateOne(ctx, model)
+
+		if cerr != nil {
+			ts.logger().Log(ctx, LogWarn, "index creation failed", map[string]interface{}{
+				"collection": db.prefix + name,
+				"error":      cerr.Error(),
+			})
+
+			if err == nil {
+				err = cerr
+			}
+
+			continue
+		}
+
+		index := ts.fields().expiredAtIndexName()
+
+		if name == ts.tcfg.DenylistCName {
+			index = ts.denylistIndexName()
+		}
+
+		ts.logger().Log(ctx, LogInfo, "index ensured", map[string]interface{}{
+			"collection": db.prefix + name,
+			"index":      index,
+		})
+	}
+
+	return err
+}
+
+// ensureCollectionIndexes create the indexes of a collection of a store built on the token store(e.g. ConsentStore),
+// returning the first failure
+func (ts *TokenStore) ensureCollectionIndexes(ctx context.Context, db routedDB, name string, models []mongo.IndexModel) (err error) {
+	for _, model := range models {
+		index, cerr := db.Collection(name).Indexes().CreateOne(ctx, model)
+
+		if cerr != nil {
+			ts.logger().Log(ctx, LogWarn, "index creation failed", map[string]interface{}{
+				"collection": db.prefix + name,
+				"error":      cerr.Error(),
+			})
+
+			if err == nil {
+				err = cerr
+			}
+
+			continue
+		}
+
+		ts.logger().Log(ctx, LogInfo, "index ensured", map[string]interface{}{
+			"collection": db.prefix + name,
+			"index":      index,
+		})
+	}
+
+	return err
+}
+
+// initCollectionIndexes create the indexes of a store built on the token store at its construction, a routed
+// store has no database of its own and runs EnsureIndexes per tenant
+func (ts *TokenStore) initCollectionIndexes(name string, models []mongo.IndexModel) {
+	if ts.tcfg.TenantResolver != nil || ts.tcfg.ReadOnly {
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 
 	defer cancel()
 
-	ts.col(ts.tcfg.BasicCName).Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: bson.M{
-			"ExpiredAt": 1, // index in ascending order
-		},
-		Options: nil,
-	})
+	ts.ensureCollectionIndexes(ctx, routedDB{Database: ts.conns.database()}, name, models)
+}
 
-	ts.col(ts.tcfg.AccessCName).Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: bson.M{
-			"ExpiredAt": 1, // index in ascending order
-		},
-		Options: nil,
-	})
+// routedIndexes create the indexes of a collection of a store built on the token store in the database of the tenant
+// of ctx
+func (ts *TokenStore) routedIndexes(ctx context.Context, name string, models []mongo.IndexModel) error {
+	if err := ts.writable(); err != nil {
+		return err
+	}
 
-	ts.col(ts.tcfg.RefreshCName).Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: bson.M{
-			"ExpiredAt": 1, // index in ascending order
-		},
-		Options: nil,
-	})
+	db, err := route(ctx, ts.conns, ts.tcfg.TenantResolver)
 
-	return ts
+	if err != nil {
+		return err
+	}
+
+	return ts.ensureCollectionIndexes(ctx, db, name, models)
+}
+
+// notExpired match the documents whose optional expiry field hasn't passed, the TTL monitor only removes them
+// about every minute
+func notExpired(field string, filter bson.M) bson.M {
+	return bson.M{"$and": bson.A{filter, bson.M{"$or": bson.A{
+		bson.M{field: bson.M{"$exists": false}},
+		bson.M{field: bson.M{"$gt": time.Now()}},
+	}}}}
+}
+
+// expiryIndex the TTL index removing the documents once their expiry field has passed, those without it are kept
+func expiryIndex(field string) mongo.IndexModel {
+	return mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}
 }
 
 // TokenStore MongoDB storage for OAuth 2.0
 type TokenStore struct {
-	tcfg   *TokenConfig
-	dbName string
-	client *mongo.Client
+	tcfg    *TokenConfig
+	conns   *connHolder
+	tracker tracker
+	codecs  codecs
+	caps    Capabilities
+	// the authorization codes of TokenConfig.MemoryCodes, nil when they are stored
+	codes *memoryCodes
+	// the session IDs of the codes and refresh tokens read by the requests
+	grants *grantSessions
+	// the mongo client belongs to a Store
+	shared bool
 }
 
-// Close the mongo connection
+// Close wait up to 15 seconds for the in-flight operations and close the mongo connection
 func (ts *TokenStore) Close() {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
-	ts.client.Disconnect(ctx)
+
+	if err := ts.Shutdown(ctx); err != nil {
+		ts.logger().Log(ctx, LogWarn, "close failed", map[string]interface{}{"error": err.Error()})
+	}
 }
 
-func (ts *TokenStore) col(name string) *mongo.Collection {
-	return ts.client.Database(ts.dbName).Collection(name)
+// Reconnect connect a new mongo client with opts(e.g. rotated credentials) and swap it in,
+// the previous client is disconnected once the operations using it have finished
+func (ts *TokenStore) Reconnect(ctx context.Context, opts *options.ClientOptions) error {
+	err := ts.conns.reconnect(ctx, opts)
+
+	if err == nil {
+		ts.logger().Log(ctx, LogInfo, "mongo client reconnected", nil)
+	}
+
+	return err
 }
 
-func (ts *TokenStore) dbHandler(fn func(context.Context, *mongo.Database) error) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// Shutdown stop accepting new operations, wait for the in-flight ones until ctx is done
+// and close the mongo connection, operations started afterwards fail with ErrStoreClosed
+func (ts *TokenStore) Shutdown(ctx context.Context) error {
+	err := ts.tracker.drain(ctx)
 
-	return ts.client.UseSession(ctx, func(session mongo.SessionContext) error {
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	if ts.shared {
+		return err
+	}
 
-		defer cancel()
+	if derr := ts.conns.client().Disconnect(ctx); err == nil {
+		err = derr
+	}
 
-		session.StartTransaction()
+	return err
+}
 
-		err := fn(ctx, session.Client().Database(ts.dbName))
+// CollectionKind collections used by the token store
+type CollectionKind int
+
+// collection kinds
+const (
+	// CollectionBasic token payloads and authorization codes
+	CollectionBasic CollectionKind = iota
+	// CollectionAccess access token references
+	CollectionAccess
+	// CollectionRefresh refresh token references
+	CollectionRefresh
+	// CollectionTxn transaction collection
+	CollectionTxn
+	// CollectionDenylist revoked JWT ids
+	CollectionDenylist
+)
 
-		if err != nil {
-			return session.AbortTransaction(ctx)
+// Client the current mongo client of the store
+func (ts *TokenStore) Client() *mongo.Client {
+	return ts.conns.client()
+}
+
+// Database the current database handle of the store
+func (ts *TokenStore) Database() *mongo.Database {
+	return ts.conns.database()
+}
+
+// Collection the collection of the given kind as configured for the store, nil for an unknown kind
+func (ts *TokenStore) Collection(kind CollectionKind) *mongo.Collection {
+	var name string
+
+	switch kind {
+	case CollectionBasic:
+		name = ts.tcfg.BasicCName
+	case CollectionAccess:
+		name = ts.tcfg.AccessCName
+	case CollectionRefresh:
+		name = ts.tcfg.RefreshCName
+	case CollectionTxn:
+		name = ts.tcfg.TxnCName
+	case CollectionDenylist:
+		name = ts.tcfg.DenylistCName
+	default:
+		return nil
+	}
+
+	return ts.col(ts.cname(name))
+}
+
+// Ping check the connection to the mongo server, honoring the deadline of ctx
+func (ts *TokenStore) Ping(ctx context.Context) error {
+	return ping(ctx, ts.conns.client(), ts.tcfg.ReadPreference)
+}
+
+// Healthy ping the mongo server and verify the token collections and their indexes exist
+func (ts *TokenStore) Healthy(ctx context.Context) HealthReport {
+	return checkHealth(ctx, ts.conns.database(), ts.tcfg.ReadPreference, ts.expectedIndexes())
+}
+
+// Preflight verify the connectivity, the read/write permissions and indexes of the token collections
+// and the transaction support of the deployment, meant to be run once at startup
+func (ts *TokenStore) Preflight(ctx context.Context) (PreflightReport, error) {
+	return preflight(ctx, ts.conns.database(), ts.expectedIndexes())
+}
+
+// expectedIndexes index names expected on every token collection
+func (ts *TokenStore) expectedIndexes() map[string][]string {
+	expected := make(map[string][]string)
+
+	for _, name := range ts.tokenCNames() {
+		expected[name] = []string{ts.fields().expiredAtIndexName()}
+
+		for _, model := range ts.tokenIndexes(name) {
+			expected[name] = append(expected[name], *model.Options.Name)
 		}
+	}
 
-		return session.CommitTransaction(ctx)
-	})
+	expected[ts.tcfg.DenylistCName] = []string{ts.denylistIndexName()}
+
+	return expected
 }
 
-func (ts *TokenStore) colHandler(name string, fn func(context.Context, *mongo.Collection) error) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// fields the effective field names of the documents
+func (ts *TokenStore) fields() FieldNames {
+	return ts.tcfg.FieldNames.withDefaults()
+}
 
-	return ts.client.UseSession(ctx, func(session mongo.SessionContext) error {
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+// tokenIndexes the lookup indexes of the token collection besides the ExpiredAt index
+func (ts *TokenStore) tokenIndexes(name string) []mongo.IndexModel {
+	models := []mongo.IndexModel{sparseIndex(tokenSessionField), sparseIndex(tokenAudienceField), sparseIndex(tokenUserField)}
+
+	if ts.tcfg.SingleCollection {
+		models = append(models, ts.singleIndexes()...)
+	}
+
+	if name == ts.cname(ts.tcfg.BasicCName) {
+		models = append(models, sparseIndex(tokenSubjectRefField), sparseIndex(tokenActorRefField), sparseIndex(tokenCodeCreatedField))
+	}
+
+	return models
+}
+
+// tokenCNames collections which hold token data
+func (ts *TokenStore) tokenCNames() []string {
+	return ts.cnames(ts.tcfg.BasicCName, ts.tcfg.AccessCName, ts.tcfg.RefreshCName)
+}
+
+func (ts *TokenStore) logger() Logger {
+	if ts.tcfg.Logger == nil {
+		return nopLogger{}
+	}
+
+	return ts.tcfg.Logger
+}
+
+func (ts *TokenStore) metrics() Metrics {
+	if ts.tcfg.Metrics == nil {
+		return nopMetrics{}
+	}
+
+	return ts.tcfg.Metrics
+}
+
+// breaker run fn under the circuit breaker, the transitions are reported through the hooks of the store
+func (ts *TokenStore) breaker(ctx context.Context, fn func() error) error {
+	return ts.tcfg.Breaker.do(fn, breakerObserver(ctx, ts.logger(), ts.metrics()))
+}
+
+func (ts *TokenStore) op(name, cname string) *operation {
+	return newOperation("token", name, cname)
+}
+
+// run execute a public operation with the configured hooks
+func (ts *TokenStore) run(ctx context.Context, o *operation, fn func(context.Context) error) error {
+	ob := observer{
+		tracker:         &ts.tracker,
+		conns:           ts.conns,
+		logger:          ts.logger(),
+		metrics:         ts.metrics(),
+		tracer:          ts.tcfg.Tracer,
+		slowOpThreshold: ts.tcfg.SlowOpThreshold,
+	}
+
+	return ob.run(ctx, o, fn)
+}
+
+func (ts *TokenStore) col(name string) *mongo.Collection {
+	return ts.conns.database().Collection(name)
+}
+
+// readCol returns the collection of db with the configured read preference and read concern applied,
+// strong forces primary reads for operations that can't tolerate stale data, unless the store is read-only
+func (ts *TokenStore) readCol(db routedDB, name string, strong bool) *mongo.Collection {
+	opts := options.Collection()
+
+	if strong && !ts.tcfg.ReadOnly {
+		opts.SetReadPreference(readpref.Primary())
+	} else {
+		if ts.tcfg.ReadPreference != nil {
+			opts.SetReadPreference(ts.tcfg.ReadPreference)
+		}
+
+		if ts.tcfg.ReadConcern != nil {
+			opts.SetReadConcern(ts.tcfg.ReadConcern)
+		}
+	}
+
+	return db.Collection(ts.cname(name), opts)
+}
+
+func (ts *TokenStore) readHandler(ctx context.Context, name string, strong bool, fn func(context.Context, *mongo.Collection) error) error {
+	db, err := route(ctx, ts.conns, ts.tcfg.TenantResolver)
+
+	if err != nil {
+		return err
+	}
+
+	return ts.breaker(ctx, func() error {
+		ctx, cancel := withTimeout(ctx, ts.tcfg.ReadTimeout)
 
 		defer cancel()
 
-		session.StartTransaction()
+		return fn(ctx, ts.readCol(db, name, strong))
+	})
+}
+
+// writable fail the writes of a read-only store
+func (ts *TokenStore) writable() error {
+	if ts.tcfg.ReadOnly {
+		return ErrReadOnlyStore
+	}
 
-		err := fn(ctx, ts.col(name))
+	return nil
+}
 
-		if err != nil {
-			return session.AbortTransaction(ctx)
+func (ts *TokenStore) dbHandler(ctx context.Context, fn func(context.Context, routedDB) error) error {
+	if err := ts.writable(); err != nil {
+		return err
+	}
+
+	db, err := route(ctx, ts.conns, ts.tcfg.TenantResolver)
+
+	if err != nil {
+		return err
+	}
+
+	return ts.breaker(ctx, func() error {
+		return transaction(ctx, db.Client(), ts.transactionsDisabled(), ts.tcfg.WriteTimeout, ts.logger(), func(ctx context.Context) error {
+			return fn(ctx, db)
+		})
+	})
+}
+
+func (ts *TokenStore) colHandler(ctx context.Context, name string, fn func(context.Context, *mongo.Collection) error) error {
+	return ts.dbHandler(ctx, func(ctx context.Context, d routedDB) error {
+		return fn(ctx, d.Collection(ts.cname(name)))
+	})
+}
+
+// Create create and store the new token information, in the session of the context or else of the code or
+// refresh token read by GetByCode or GetByRefresh with the same context
+func (ts *TokenStore) Create(ctx context.Context, info oauth2.TokenInfo) error {
+	o := ts.op("Create", ts.tcfg.BasicCName)
+	ctx = ts.carrySession(ctx)
+
+	return ts.run(ctx, o, func(ctx context.Context) error {
+		if err := validateTokenInfo(info); err != nil {
+			return err
 		}
 
-		return session.CommitTransaction(ctx)
+		o.sensitive(info.GetCode(), info.GetAccess(), info.GetRefresh())
+
+		return ts.create(ctx, o, info, Confirmation{}, tokenLineage{})
 	})
 }
 
-// Create create and store the new token information
-func (ts *TokenStore) Create(_ context.Context, info oauth2.TokenInfo) (err error) {
-	jv, err := json.Marshal(info)
+// create store the token information, the access and refresh token documents record cnf and the basic
+// document the tokens it was exchanged from
+func (ts *TokenStore) create(ctx context.Context, o *operation, info oauth2.TokenInfo, cnf Confirmation, lineage tokenLineage) (err error) {
+	if err = ts.writable(); err != nil {
+		return
+	}
+
+	codec := ts.codecs.write
+	jv, err := codec.Marshal(info)
+
+	if err != nil {
+		return
+	}
+
+	if info.GetCode() != "" && ts.codes != nil {
+		return ts.createMemoryCode(ctx, o, info, codec, jv)
+	}
+
+	tenantID, keyID, jv, err := ts.seal(ctx, info, jv)
 
 	if err != nil {
 		return
 	}
 
+	issuer := ts.issuer(ctx)
+	userID := ts.userID(info.GetUserID())
+	sid := SessionIDFromContext(ctx)
+	aud := tokenAudience(ctx, info)
+
 	if code := info.GetCode(); code != "" {
-		return ts.colHandler(ts.tcfg.BasicCName, func(ctx context.Context, c *mongo.Collection) error {
-			_, err := c.InsertOne(ctx, basicData{
-				ID:        code,
+		attrs := newCodeAttributes(ctx, info)
+		o.set("documents", 1)
+		o.set("code_challenge_method", attrs.methodOrNone())
+
+		return ts.colHandler(ctx, ts.tcfg.BasicCName, func(ctx context.Context, c *mongo.Collection) error {
+			_, err := c.InsertOne(ctx, ts.withKind(basicData{
+				ID:        ts.codeKey(ctx, code),
 				Data:      jv,
+				Codec:     codec.Name(),
+				KeyID:     keyID,
+				Tenant:    tenantID,
+				Issuer:    issuer,
+				KeyPrefix: ts.tcfg.KeyPrefix,
+				UserID:    userID,
+				SessionID: sid,
+				Audience:  aud,
+				Code:      attrs,
 				ExpiredAt: info.GetCodeCreateAt().Add(info.GetCodeExpiresIn()),
-			})
+			}.doc(ts.fields()), kindCode))
 			return err
 		})
 	}
@@ -181,37 +719,89 @@ func (ts *TokenStore) Create(_ context.Context, info oauth2.TokenInfo) (err erro
 	}
 
 	// var payloads map[string]interface{}
-	payloads := make(map[string]interface{})
+	payloads := make(map[string]bson.D)
 
 	id := primitive.NewObjectID().Hex()
+	o.set("basic_id", id)
 
-	payloads[ts.tcfg.BasicCName] = basicData{
+	payloads[ts.tcfg.BasicCName] = ts.withKind(basicData{
 		ID:        id,
 		Data:      jv,
+		Codec:     codec.Name(),
+		KeyID:     keyID,
+		Tenant:    tenantID,
+		Issuer:    issuer,
+		KeyPrefix: ts.tcfg.KeyPrefix,
+		UserID:    userID,
+		SessionID: sid,
+		Audience:  aud,
+		Lineage:   lineage,
 		ExpiredAt: rexp,
+	}.doc(ts.fields()), kindBasic)
+
+	access := ts.withKind(tokenData{
+		ID:           ts.tokenKey(ctx, ts.tcfg.AccessCName, info.GetAccess()),
+		BasicID:      id,
+		Issuer:       issuer,
+		KeyPrefix:    ts.tcfg.KeyPrefix,
+		UserID:       userID,
+		SessionID:    sid,
+		Audience:     aud,
+		ExpiredAt:    aexp,
+		Confirmation: cnf,
+	}.doc(ts.fields()), kindAccess)
+
+	if !ts.tcfg.SkipAccessTokenStorage {
+		payloads[ts.tcfg.AccessCName] = access
+	} else if info.GetRefresh() == "" {
+		// nothing would ever read the basic document of a lone access token
+		o.set("documents", 0)
+		return nil
 	}
 
-	payloads[ts.tcfg.AccessCName] = tokenData{
-		ID:        info.GetAccess(),
-		BasicID:   id,
-		ExpiredAt: aexp,
+	if refresh := info.GetRefresh(); refresh != "" {
+		payloads[ts.tcfg.RefreshCName] = ts.withKind(tokenData{
+			ID:           ts.tokenKey(ctx, ts.tcfg.RefreshCName, refresh),
+			BasicID:      id,
+			Issuer:       issuer,
+			KeyPrefix:    ts.tcfg.KeyPrefix,
+			UserID:       userID,
+			SessionID:    sid,
+			Audience:     aud,
+			ExpiredAt:    rexp,
+			Confirmation: cnf,
+		}.doc(ts.fields()), kindRefresh)
 	}
 
-	if refresh := info.GetRefresh(); refresh != "" {
-		payloads[ts.tcfg.RefreshCName] = tokenData{
-			ID:        refresh,
-			BasicID:   id,
-			ExpiredAt: rexp,
+	o.set("documents", len(payloads))
+
+	return ts.dbHandler(ctx, func(ctx context.Context, d routedDB) error {
+		if ts.tcfg.SingleCollection {
+			var names []string
+
+			for _, name := range []string{ts.tcfg.BasicCName, ts.tcfg.AccessCName, ts.tcfg.RefreshCName} {
+				if _, ok := payloads[name]; ok {
+					names = append(names, name)
+				}
+			}
+
+			return ts.insertFamily(ctx, d, names, payloads)
 		}
-	}
 
-	return ts.dbHandler(func(ctx context.Context, d *mongo.Database) error {
+		inserted := make(map[string]bson.D)
+
 		for key, value := range payloads {
 			_, err := d.Collection(key).InsertOne(ctx, value)
 
 			if err != nil {
+				if ts.transactionsDisabled() {
+					ts.compensate(ctx, d, inserted)
+				}
+
 				return err
 			}
+
+			inserted[key] = value
 		}
 
 		return nil
@@ -219,99 +809,404 @@ func (ts *TokenStore) Create(_ context.Context, info oauth2.TokenInfo) (err erro
 }
 
 // RemoveByCode use the authorization code to delete the token information
-func (ts *TokenStore) RemoveByCode(_ context.Context, code string) error {
-	return ts.colHandler(ts.tcfg.BasicCName, func(ctx context.Context, c *mongo.Collection) error {
-		_, err := c.DeleteOne(ctx, bson.M{"_id": code})
-		return err
+func (ts *TokenStore) RemoveByCode(ctx context.Context, code string) error {
+	o := ts.op("RemoveByCode", ts.tcfg.BasicCName)
+	o.sensitive(code)
+
+	return ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("code", code); err != nil {
+			return err
+		}
+
+		if err := ts.writable(); err != nil {
+			return err
+		}
+
+		if ts.codes != nil {
+			o.set("deleted", ts.codes.delete(ts.codeKey(ctx, code)))
+			return nil
+		}
+
+		return ts.colHandler(ctx, ts.tcfg.BasicCName, func(ctx context.Context, c *mongo.Collection) error {
+			res, err := c.DeleteOne(ctx, ts.withIssuer(ctx, bson.M{"_id": ts.codeKey(ctx, code)}))
+
+			if err == nil {
+				o.set("deleted", res.DeletedCount)
+			}
+
+			return err
+		})
 	})
 }
 
 // RemoveByAccess use the access token to delete the token information
-func (ts *TokenStore) RemoveByAccess(_ context.Context, access string) error {
-	return ts.colHandler(ts.tcfg.AccessCName, func(ctx context.Context, c *mongo.Collection) error {
-		_, err := c.DeleteOne(ctx, bson.M{"_id": access})
-		return err
+func (ts *TokenStore) RemoveByAccess(ctx context.Context, access string) error {
+	o := ts.op("RemoveByAccess", ts.tcfg.AccessCName)
+	o.sensitive(access)
+
+	return ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("access token", access); err != nil {
+			return err
+		}
+
+		if err := ts.writable(); err != nil {
+			return err
+		}
+
+		if ts.tcfg.SkipAccessTokenStorage {
+			return ts.removeUnstoredAccess(ctx, access)
+		}
+
+		return ts.dbHandler(ctx, func(ctx context.Context, d routedDB) error {
+			c := d.Collection(ts.cname(ts.tcfg.AccessCName))
+			roots, err := ts.cascadeRoots(ctx, c, ts.tcfg.AccessCName, access)
+
+			if err != nil {
+				return err
+			}
+
+			res, err := c.DeleteMany(ctx, ts.tokenFilter(ctx, ts.tcfg.AccessCName, access))
+
+			if err != nil {
+				return err
+			}
+
+			o.set("deleted", res.DeletedCount)
+
+			return ts.removeDescendants(ctx, o, d, roots)
+		})
 	})
 }
 
 // RemoveByRefresh use the refresh token to delete the token information
-func (ts *TokenStore) RemoveByRefresh(_ context.Context, refresh string) error {
-	return ts.colHandler(ts.tcfg.RefreshCName, func(ctx context.Context, c *mongo.Collection) error {
-		_, err := c.DeleteOne(ctx, bson.M{"_id": refresh})
-		return err
+func (ts *TokenStore) RemoveByRefresh(ctx context.Context, refresh string) error {
+	o := ts.op("RemoveByRefresh", ts.tcfg.RefreshCName)
+	o.sensitive(refresh)
+
+	return ts.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("refresh token", refresh); err != nil {
+			return err
+		}
+
+		if err := ts.writable(); err != nil {
+			return err
+		}
+
+		return ts.dbHandler(ctx, func(ctx context.Context, d routedDB) error {
+			c := d.Collection(ts.cname(ts.tcfg.RefreshCName))
+			roots, err := ts.cascadeRoots(ctx, c, ts.tcfg.RefreshCName, refresh)
+
+			if err != nil {
+				return err
+			}
+
+			res, err := c.DeleteMany(ctx, ts.tokenFilter(ctx, ts.tcfg.RefreshCName, refresh))
+
+			if err != nil {
+				return err
+			}
+
+			o.set("deleted", res.DeletedCount)
+
+			return ts.removeDescendants(ctx, o, d, roots)
+		})
 	})
 }
 
-func (ts *TokenStore) getData(basicID string) (oauth2.TokenInfo, error) {
+func (ts *TokenStore) getData(ctx context.Context, basicID string, strong bool) (oauth2.TokenInfo, error) {
+	ti, _, err := ts.getBasic(ctx, basicID, strong)
+	return ti, err
+}
+
+// getBasic the token information of the basic document and its session ID
+func (ts *TokenStore) getBasic(ctx context.Context, basicID string, strong bool) (oauth2.TokenInfo, string, error) {
 	var tm models.Token
+	var sid string
+
+	err := ts.readHandler(ctx, ts.tcfg.BasicCName, strong, func(ctx context.Context, c *mongo.Collection) error {
+		raw, err := c.FindOne(ctx, ts.withIssuer(ctx, bson.M{"_id": basicID})).DecodeBytes()
+
+		if err != nil {
+			return err
+		}
 
-	err := ts.colHandler(ts.tcfg.BasicCName, func(ctx context.Context, c *mongo.Collection) error {
-		var bd basicData
-		err := c.FindOne(ctx, bson.M{"_id": basicID}).Decode(&bd)
+		bd := decodeBasicData(raw, ts.fields())
+		sid = bd.SessionID
+		codec, err := ts.codecs.get(bd.Codec)
 
 		if err != nil {
 			return err
 		}
 
-		return json.Unmarshal(bd.Data, &tm)
+		data, err := ts.open(ctx, bd.Tenant, bd.KeyID, bd.Data)
+
+		if err != nil {
+			return err
+		}
+
+		return codec.Unmarshal(data, &tm)
 	})
 
-	return &tm, err
+	return &tm, sid, err
 }
 
-func (ts *TokenStore) getBasicID(cname, token string) (string, error) {
-	var basicID string
+func (ts *TokenStore) getTokenData(ctx context.Context, cname, token string, strong bool) (tokenData, error) {
+	var td tokenData
 
-	err := ts.colHandler(cname, func(ctx context.Context, c *mongo.Collection) error {
-		var td tokenData
-		err := c.FindOne(ctx, bson.M{"_id": token}).Decode(&td)
+	err := ts.readHandler(ctx, cname, strong, func(ctx context.Context, c *mongo.Collection) error {
+		raw, err := c.FindOne(ctx, ts.tokenFilter(ctx, cname, token)).DecodeBytes()
 
 		if err != nil {
 			return err
 		}
 
-		basicID = td.BasicID
+		td = decodeTokenData(raw, ts.fields())
 		return nil
 	})
 
-	return basicID, err
+	return td, err
 }
 
-// GetByCode use the authorization code for token information data
-func (ts *TokenStore) GetByCode(_ context.Context, code string) (oauth2.TokenInfo, error) {
-	return ts.getData(code)
+// getByToken resolve the basic data referenced by a token document, check(optional) vets
+// the token document before the basic data is read
+func (ts *TokenStore) getByToken(ctx context.Context, o *operation, token string, strong bool, check func(tokenData) error) (oauth2.TokenInfo, error) {
+	td, err := ts.getTokenData(ctx, o.collection, token, strong)
+
+	if err != nil && td.BasicID == "" {
+		return nil, err
+	}
+
+	o.set("basic_id", td.BasicID)
+
+	if check != nil {
+		if err := check(td); err != nil {
+			return nil, err
+		}
+	}
+
+	return ts.getData(ctx, td.BasicID, strong)
 }
 
-// GetByAccess use the access token for token information data
-func (ts *TokenStore) GetByAccess(_ context.Context, access string) (oauth2.TokenInfo, error) {
-	basicID, err := ts.getBasicID(ts.tcfg.AccessCName, access)
+// GetByCode use the authorization code for token information data, the tokens the request creates next
+// with the same context stay in the session of the code
+func (ts *TokenStore) GetByCode(ctx context.Context, code string) (ti oauth2.TokenInfo, err error) {
+	o := ts.op("GetByCode", ts.tcfg.BasicCName)
+	o.sensitive(code)
 
-	if err != nil && basicID == "" {
-		return nil, err
+	var sid string
+
+	err = ts.run(ctx, o, func(ctx context.Context) (err error) {
+		if err := requireArg("code", code); err != nil {
+			return err
+		}
+
+		if ts.codes != nil {
+			ti, sid, err = ts.getMemoryCode(ctx, code)
+			return
+		}
+
+		ti, sid, err = ts.getBasic(ctx, ts.codeKey(ctx, code), true)
+		return
+	})
+
+	if err == nil {
+		ts.grants.put(ctx, sid)
 	}
 
-	return ts.getData(basicID)
+	return
 }
 
-// GetByRefresh use the refresh token for token information data
-func (ts *TokenStore) GetByRefresh(_ context.Context, refresh string) (oauth2.TokenInfo, error) {
-	basicID, err := ts.getBasicID(ts.tcfg.RefreshCName, refresh)
+// GetByAccess use the access token for token information data
+func (ts *TokenStore) GetByAccess(ctx context.Context, access string) (ti oauth2.TokenInfo, err error) {
+	o := ts.op("GetByAccess", ts.tcfg.AccessCName)
+	o.sensitive(access)
 
-	if err != nil && basicID == "" {
-		return nil, err
+	err = ts.run(ctx, o, func(ctx context.Context) (err error) {
+		if err := requireArg("access token", access); err != nil {
+			return err
+		}
+
+		if err := ts.accessStored(); err != nil {
+			return err
+		}
+
+		ti, err = ts.getByToken(ctx, o, access, false, nil)
+		return
+	})
+
+	return
+}
+
+// GetByRefresh use the refresh token for token information data, the tokens the request creates next
+// with the same context stay in the session of the refresh token
+func (ts *TokenStore) GetByRefresh(ctx context.Context, refresh string) (ti oauth2.TokenInfo, err error) {
+	o := ts.op("GetByRefresh", ts.tcfg.RefreshCName)
+	o.sensitive(refresh)
+
+	var sid string
+
+	err = ts.run(ctx, o, func(ctx context.Context) (err error) {
+		if err := requireArg("refresh token", refresh); err != nil {
+			return err
+		}
+
+		ti, err = ts.getByToken(ctx, o, refresh, true, func(td tokenData) error {
+			sid = td.SessionID
+			return nil
+		})
+		return
+	})
+
+	if err == nil {
+		ts.grants.put(ctx, sid)
 	}
 
-	return ts.getData(basicID)
+	return
 }
 
 type basicData struct {
-	ID        string    `bson:"_id"`
-	Data      []byte    `bson:"Data"`
-	ExpiredAt time.Time `bson:"ExpiredAt"`
+	ID        string
+	Data      []byte
+	Codec     string
+	KeyID     string
+	Tenant    string
+	Issuer    string
+	KeyPrefix string
+	UserID    string
+	SessionID string
+	Audience  []string
+	Lineage   tokenLineage
+	Code      codeAttributes
+	ExpiredAt time.Time
+}
+
+func (bd basicData) doc(fn FieldNames) bson.D {
+	var data interface{} = bd.Data
+
+	if structured(bd.Codec, bd.KeyID) {
+		data = bson.Raw(bd.Data)
+	}
+
+	doc := bson.D{
+		{Key: "_id", Value: bd.ID},
+		{Key: fn.Data, Value: data},
+		{Key: fn.Codec, Value: bd.Codec},
+		{Key: fn.KeyID, Value: bd.KeyID},
+		{Key: fn.ExpiredAt, Value: bd.ExpiredAt},
+	}
+
+	if bd.Tenant != "" {
+		doc = append(doc, bson.E{Key: fn.Tenant, Value: bd.Tenant})
+	}
+
+	if bd.Issuer != "" {
+		doc = append(doc, bson.E{Key: fn.Issuer, Value: bd.Issuer})
+	}
+
+	if bd.KeyPrefix != "" {
+		doc = append(doc, bson.E{Key: tokenPrefixField, Value: bd.KeyPrefix})
+	}
+
+	if bd.UserID != "" {
+		doc = append(doc, bson.E{Key: tokenUserField, Value: bd.UserID})
+	}
+
+	if bd.SessionID != "" {
+		doc = append(doc, bson.E{Key: tokenSessionField, Value: bd.SessionID})
+	}
+
+	if len(bd.Audience) > 0 {
+		doc = append(doc, bson.E{Key: tokenAudienceField, Value: bd.Audience})
+	}
+
+	if bd.Lineage.Subject != "" {
+		doc = append(doc, bson.E{Key: tokenSubjectRefField, Value: bd.Lineage.Subject})
+	}
+
+	if bd.Lineage.Actor != "" {
+		doc = append(doc, bson.E{Key: tokenActorRefField, Value: bd.Lineage.Actor})
+	}
+
+	return append(doc, bd.Code.doc()...)
+}
+
+func decodeBasicData(raw bson.Raw, fn FieldNames) basicData {
+	return basicData{
+		ID:        lookupString(raw, []string{"_id"}),
+		Data:      lookupPayload(raw, aliases(fn.Data, func(f FieldNames) string { return f.Data })),
+		Codec:     lookupString(raw, aliases(fn.Codec, func(f FieldNames) string { return f.Codec })),
+		KeyID:     lookupString(raw, aliases(fn.KeyID, func(f FieldNames) string { return f.KeyID })),
+		Tenant:    lookupString(raw, aliases(fn.Tenant, func(f FieldNames) string { return f.Tenant })),
+		Issuer:    lookupString(raw, aliases(fn.Issuer, func(f FieldNames) string { return f.Issuer })),
+		KeyPrefix: lookupString(raw, []string{tokenPrefixField}),
+		UserID:    lookupString(raw, []string{tokenUserField}),
+		SessionID: lookupString(raw, []string{tokenSessionField}),
+		Audience:  lookupStrings(raw, []string{tokenAudienceField}),
+		Lineage: tokenLineage{
+			Subject: lookupString(raw, []string{tokenSubjectRefField}),
+			Actor:   lookupString(raw, []string{tokenActorRefField}),
+		},
+		Code:      decodeCodeAttributes(raw),
+		ExpiredAt: lookupTime(raw, aliases(fn.ExpiredAt, func(f FieldNames) string { return f.ExpiredAt })),
+	}
 }
 
 type tokenData struct {
-	ID        string    `bson:"_id"`
-	BasicID   string    `bson:"BasicID"`
-	ExpiredAt time.Time `bson:"ExpiredAt"`
+	ID           string
+	BasicID      string
+	Issuer       string
+	KeyPrefix    string
+	UserID       string
+	SessionID    string
+	Audience     []string
+	ExpiredAt    time.Time
+	Confirmation Confirmation
+}
+
+func (td tokenData) doc(fn FieldNames) bson.D {
+	doc := bson.D{
+		{Key: "_id", Value: td.ID},
+		{Key: fn.BasicID, Value: td.BasicID},
+		{Key: fn.ExpiredAt, Value: td.ExpiredAt},
+	}
+
+	if td.Issuer != "" {
+		doc = append(doc, bson.E{Key: fn.Issuer, Value: td.Issuer})
+	}
+
+	if td.KeyPrefix != "" {
+		doc = append(doc, bson.E{Key: tokenPrefixField, Value: td.KeyPrefix})
+	}
+
+	if td.UserID != "" {
+		doc = append(doc, bson.E{Key: tokenUserField, Value: td.UserID})
+	}
+
+	if td.SessionID != "" {
+		doc = append(doc, bson.E{Key: tokenSessionField, Value: td.SessionID})
+	}
+
+	if len(td.Audience) > 0 {
+		doc = append(doc, bson.E{Key: tokenAudienceField, Value: td.Audience})
+	}
+
+	if !td.Confirmation.empty() {
+		doc = append(doc, bson.E{Key: fn.Confirmation, Value: td.Confirmation.doc()})
+	}
+
+	return doc
+}
+
+func decodeTokenData(raw bson.Raw, fn FieldNames) tokenData {
+	return tokenData{
+		ID:           lookupString(raw, []string{"_id"}),
+		BasicID:      lookupString(raw, aliases(fn.BasicID, func(f FieldNames) string { return f.BasicID })),
+		Issuer:       lookupString(raw, aliases(fn.Issuer, func(f FieldNames) string { return f.Issuer })),
+		KeyPrefix:    lookupString(raw, []string{tokenPrefixField}),
+		UserID:       lookupString(raw, []string{tokenUserField}),
+		SessionID:    lookupString(raw, []string{tokenSessionField}),
+		Audience:     lookupStrings(raw, []string{tokenAudienceField}),
+		ExpiredAt:    lookupTime(raw, aliases(fn.ExpiredAt, func(f FieldNames) string { return f.ExpiredAt })),
+		Confirmation: decodeConfirmation(raw, aliases(fn.Confirmation, func(f FieldNames) string { return f.Confirmation })),
+	}
 }
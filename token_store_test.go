@@ -0,0 +1,234 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+func TestReadSettingsOnLookupsOnly(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+
+	ts := testTokenStore(cfg,
+		WithReadPreference(readpref.SecondaryPreferred()),
+		WithReadConcern(readconcern.Local()),
+	)
+	defer ts.Close()
+
+	ctx := context.Background()
+
+	if err := ts.Create(ctx, testToken("access", "refresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.GetByAccess(ctx, "access"); err != nil {
+		t.Fatal(err)
+	}
+
+	finds := rec.named("find")
+
+	if len(finds) == 0 {
+		t.Fatal("GetByAccess issued no find command")
+	}
+
+	for _, cmd := range finds {
+		if level, _ := cmd.Lookup("readConcern", "level").StringValueOK(); level != "local" {
+			t.Errorf("find without the configured read concern: %s", cmd)
+		}
+
+		// the read preference isn't sent to standalone servers
+		if ts.Capabilities().ReplicaSet {
+			if mode, _ := cmd.Lookup("$readPreference", "mode").StringValueOK(); mode != "secondaryPreferred" {
+				t.Errorf("find without the configured read preference: %s", cmd)
+			}
+		}
+	}
+
+	for _, cmd := range rec.named("insert") {
+		if _, err := cmd.LookupErr("readConcern"); err == nil {
+			t.Errorf("insert with a read concern: %s", cmd)
+		}
+
+		if _, err := cmd.LookupErr("$readPreference"); err == nil {
+			t.Errorf("insert with a read preference: %s", cmd)
+		}
+	}
+}
+
+func TestStrongReadsUsePrimary(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+
+	ts := testTokenStore(cfg, WithReadPreference(readpref.SecondaryPreferred()), WithReadConcern(readconcern.Local()))
+	defer ts.Close()
+
+	ctx := context.Background()
+
+	if err := ts.Create(ctx, testToken("access", "refresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	rec.reset()
+
+	if _, err := ts.GetByRefresh(ctx, "refresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cmd := range rec.named("find") {
+		if _, err := cmd.LookupErr("readConcern"); err == nil {
+			t.Errorf("GetByRefresh find with the lookup read concern: %s", cmd)
+		}
+
+		if mode, ok := cmd.Lookup("$readPreference", "mode").StringValueOK(); ok && mode != "primary" {
+			t.Errorf("GetByRefresh find not on the primary: %s", cmd)
+		}
+	}
+}
+
+// the write concern of the database handle applies to the writes of both stores
+func TestDatabaseWriteConcern(t *testing.T) {
+	var rec commandRecorder
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(testURI(t)).SetMonitor(rec.monitor()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := client.Database(testDBName(), options.Database().SetWriteConcern(writeconcern.New(writeconcern.W(1), writeconcern.WTimeout(4*time.Second))))
+
+	defer func() {
+		_ = db.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	}()
+
+	// the writes of a transaction carry no write concern of their own
+	ts := testTokenStoreWithDB(db, WithoutTransactions())
+	cs := NewClientStoreWithDB(db, WithoutTransactions())
+
+	rec.reset()
+
+	if err := ts.Create(ctx, testToken("access", "refresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "c", Secret: "s", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := append(rec.named("insert"), rec.named("update")...)
+
+	// a single InsertMany of the token family in the single collection layout
+	want := 4
+
+	if ts.tcfg.SingleCollection {
+		want = 2
+	}
+
+	if len(writes) < want {
+		t.Fatalf("%d writes recorded, want the token family and the client", len(writes))
+	}
+
+	for _, cmd := range writes {
+		wc, err := cmd.LookupErr("writeConcern")
+
+		if err != nil {
+			t.Fatalf("write without write concern: %s", cmd)
+		}
+
+		if w, _ := wc.Document().Lookup("w").AsInt64OK(); w != 1 {
+			t.Fatalf("write concern %s, want w 1", wc)
+		}
+
+		if ms, _ := wc.Document().Lookup("wtimeout").AsInt64OK(); ms != 4000 {
+			t.Fatalf("write concern %s, want wtimeout 4000", wc)
+		}
+	}
+}
+
+func TestCollectionAccessors(t *testing.T) {
+	db := testDatabase(t)
+
+	for _, tc := range []struct {
+		name string
+		opts []TokenOption
+		want map[CollectionKind]string
+	}{
+		{"default", nil, map[CollectionKind]string{
+			CollectionBasic:    "oauth2_basic",
+			CollectionAccess:   "oauth2_access",
+			CollectionRefresh:  "oauth2_refresh",
+			CollectionTxn:      "oauth2_txn",
+			CollectionDenylist: "oauth2_denylist",
+		}},
+		{"custom", []TokenOption{WithCollectionNames(CollectionNames{Basic: "b", Access: "a", Refresh: "r", Txn: "t", Denylist: "d"})}, map[CollectionKind]string{
+			CollectionBasic:    "b",
+			CollectionAccess:   "a",
+			CollectionRefresh:  "r",
+			CollectionTxn:      "t",
+			CollectionDenylist: "d",
+		}},
+		{"single collection", []TokenOption{WithSingleCollection()}, map[CollectionKind]string{
+			CollectionBasic:    "oauth2_tokens",
+			CollectionAccess:   "oauth2_tokens",
+			CollectionRefresh:  "oauth2_tokens",
+			CollectionTxn:      "oauth2_txn",
+			CollectionDenylist: "oauth2_denylist",
+		}},
+	} {
+		ts := NewTokenStoreWithDB(db, tc.opts...)
+
+		if ts.Client() != db.Client() || ts.Database().Name() != db.Name() {
+			t.Errorf("%s: client or database isn't the store's", tc.name)
+		}
+
+		for kind, want := range tc.want {
+			c := ts.Collection(kind)
+
+			if c == nil || c.Name() != want || c.Database().Name() != db.Name() {
+				t.Errorf("%s: collection %d is %v, want %s", tc.name, kind, c, want)
+			}
+		}
+
+		if c := ts.Collection(CollectionKind(-1)); c != nil {
+			t.Errorf("%s: unknown kind has collection %s", tc.name, c.Name())
+		}
+	}
+
+	cs := NewClientStoreWithDB(db, WithCollectionNames(CollectionNames{Clients: "apps"}))
+
+	if cs.Client() != db.Client() || cs.Database().Name() != db.Name() || cs.Collection().Name() != "apps" {
+		t.Errorf("client store accessors %s/%s", cs.Database().Name(), cs.Collection().Name())
+	}
+}
+
+// the accessors follow the client swapped in by Reconnect
+func TestAccessorsAfterReconnect(t *testing.T) {
+	ts := newTestTokenStore(t)
+	before := ts.Client()
+
+	if err := ts.Reconnect(context.Background(), options.Client().ApplyURI(testURI(t))); err != nil {
+		t.Fatal(err)
+	}
+
+	defer ts.Close()
+
+	if ts.Client() == before || ts.Collection(CollectionBasic).Database().Client() != ts.Client() {
+		t.Fatal("the accessors return the previous client")
+	}
+}
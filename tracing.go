@@ -0,0 +1,14 @@
+package mongo
+
+import (
+	"context"
+)
+
+// Tracer start a trace span around every public store operation, see the
+// otelmongooauth package for an OpenTelemetry implementation
+type Tracer interface {
+	// Start return the context carrying the span, which is passed down to the
+	// mongo operations, and a function ending the span. The fields given to the end
+	// function hold the loggable identifiers and document counts of the operation.
+	Start(ctx context.Context, store, op, collection string) (context.Context, func(fields map[string]interface{}, err error))
+}
@@ -0,0 +1,52 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+)
+
+// tracker counts the in-flight operations of a store so it can be shut down gracefully
+type tracker struct {
+	mu      sync.Mutex
+	closing bool
+	wg      sync.WaitGroup
+}
+
+// acquire register a new operation, failing once the store is closing
+func (t *tracker) acquire() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closing {
+		return ErrStoreClosed
+	}
+
+	t.wg.Add(1)
+
+	return nil
+}
+
+func (t *tracker) release() {
+	t.wg.Done()
+}
+
+// drain stop accepting operations and wait for the in-flight ones until ctx is done
+func (t *tracker) drain(ctx context.Context) error {
+	t.mu.Lock()
+	t.closing = true
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,136 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// slowFindConfig a configuration whose finds are delayed by delay once started is closed
+func slowFindConfig(t *testing.T, delay time.Duration, started chan<- struct{}) *Config {
+	var once sync.Once
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			if e.CommandName == "find" {
+				once.Do(func() { close(started) })
+				time.Sleep(delay)
+			}
+		},
+	}
+
+	return cfg
+}
+
+func TestShutdownDrainsInFlight(t *testing.T) {
+	started := make(chan struct{})
+	ts := testTokenStore(slowFindConfig(t, 200*time.Millisecond, started))
+
+	ctx := context.Background()
+
+	if err := ts.Create(ctx, testToken("access", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		opDone time.Time
+		opErr  error
+		wg     sync.WaitGroup
+	)
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		_, opErr = ts.GetByAccess(ctx, "access")
+		opDone = time.Now()
+	}()
+
+	<-started
+
+	shutdown := make(chan error)
+
+	go func() { shutdown <- ts.Shutdown(ctx) }()
+
+	// new operations fail fast once the shutdown began
+	deadline := time.Now().Add(time.Second)
+
+	for {
+		_, err := ts.GetByAccess(ctx, "access")
+
+		if errors.Is(err, ErrStoreClosed) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("operation during shutdown: %v, want ErrStoreClosed", err)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+
+	shutdownDone := time.Now()
+
+	wg.Wait()
+
+	if opErr != nil {
+		t.Fatalf("in-flight operation failed: %v", opErr)
+	}
+
+	if opDone.After(shutdownDone) {
+		t.Fatal("shutdown returned before the in-flight operation finished")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	ts := testTokenStore(slowFindConfig(t, 300*time.Millisecond, started))
+
+	ctx := context.Background()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		_, _ = ts.GetByAccess(ctx, "access")
+	}()
+
+	<-started
+
+	sctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+
+	if err := ts.Shutdown(sctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("shutdown: %v, want context.DeadlineExceeded", err)
+	}
+
+	<-done
+}
+
+func TestTrackerClosed(t *testing.T) {
+	var tr tracker
+
+	if err := tr.acquire(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr.release()
+
+	if err := tr.drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tr.acquire(); err != ErrStoreClosed {
+		t.Fatalf("acquire after drain: %v, want ErrStoreClosed", err)
+	}
+}
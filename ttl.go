@@ -0,0 +1,96 @@
+package mongo
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TokenTTL the access and refresh token lifetimes of the client, zero when it uses the server default.
+// A missing client returns ErrClientNotFound.
+func (cs *ClientStore) TokenTTL(ctx context.Context, clientID string) (access, refresh time.Duration, err error) {
+	o := cs.op("TokenTTL", cs.ccfg.ClientsCName)
+	o.set("client_id", clientID)
+
+	err = cs.run(ctx, o, func(ctx context.Context) error {
+		if err := requireArg("client ID", clientID); err != nil {
+			return err
+		}
+
+		raw, err := cs.findClient(ctx, clientID, bson.M{
+			clientAccessTTLField: 1, clientRefreshTTLField: 1, clientDeletedField: 1, clientStatusField: 1,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		access = time.Duration(lookupInt64(raw, []string{clientAccessTTLField})) * time.Second
+		refresh = time.Duration(lookupInt64(raw, []string{clientRefreshTTLField})) * time.Second
+
+		return nil
+	})
+
+	return
+}
+
+// TokenTTLResolver the token lifetimes of the clients with the server defaults for those without, for the
+// callbacks of the oauth2 server
+type TokenTTLResolver struct {
+	store *ClientStore
+	// lifetimes of the clients without their own
+	access, refresh time.Duration
+}
+
+// NewTokenTTLResolver create a resolver of the client token lifetimes falling back to access and refresh
+func NewTokenTTLResolver(cs *ClientStore, access, refresh time.Duration) *TokenTTLResolver {
+	return &TokenTTLResolver{store: cs, access: access, refresh: refresh}
+}
+
+// AccessTokenTTL the access token lifetime of the client
+func (r *TokenTTLResolver) AccessTokenTTL(ctx context.Context, clientID string) (time.Duration, error) {
+	access, _, err := r.store.TokenTTL(ctx, clientID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if access <= 0 {
+		access = r.access
+	}
+
+	return access, nil
+}
+
+// RefreshTokenTTL the refresh token lifetime of the client
+func (r *TokenTTLResolver) RefreshTokenTTL(ctx context.Context, clientID string) (time.Duration, error) {
+	_, refresh, err := r.store.TokenTTL(ctx, clientID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if refresh <= 0 {
+		refresh = r.refresh
+	}
+
+	return refresh, nil
+}
+
+// AccessTokenExpHandler the server.AccessTokenExpHandler of the resolver, identifying the client of the request
+// with clientInfo(e.g. server.ClientFormHandler)
+func (r *TokenTTLResolver) AccessTokenExpHandler(
+	clientInfo func(req *http.Request) (clientID, clientSecret string, err error),
+) func(w http.ResponseWriter, req *http.Request) (time.Duration, error) {
+	return func(w http.ResponseWriter, req *http.Request) (time.Duration, error) {
+		clientID, _, err := clientInfo(req)
+
+		if err != nil {
+			return 0, err
+		}
+
+		return r.AccessTokenTTL(req.Context(), clientID)
+	}
+}
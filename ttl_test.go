@@ -0,0 +1,116 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestTokenTTL(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := cs.Set(&Client{
+		Client:         models.Client{ID: "dashboard", Secret: "secret", Domain: "https://example.com"},
+		AccessTokenTTL: 8 * time.Hour, RefreshTokenTTL: 30 * 24 * time.Hour,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&Client{Client: models.Client{ID: "partner", Secret: "secret", Domain: "https://example.com"}, AccessTokenTTL: 10 * time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "legacy", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id              string
+		access, refresh time.Duration
+	}{
+		{"dashboard", 8 * time.Hour, 30 * 24 * time.Hour},
+		{"partner", 10 * time.Minute, 0},
+		{"legacy", 0, 0},
+	}
+
+	for _, tt := range tests {
+		info, err := cs.GetByID(ctx, tt.id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ttl, ok := info.(interface {
+			GetAccessTokenTTL() time.Duration
+			GetRefreshTokenTTL() time.Duration
+		})
+
+		if !ok || ttl.GetAccessTokenTTL() != tt.access || ttl.GetRefreshTokenTTL() != tt.refresh {
+			t.Fatalf("%s: lifetimes of %+v, want %v/%v", tt.id, info, tt.access, tt.refresh)
+		}
+
+		access, refresh, err := cs.TokenTTL(ctx, tt.id)
+
+		if err != nil || access != tt.access || refresh != tt.refresh {
+			t.Fatalf("%s: TokenTTL %v/%v(%v), want %v/%v", tt.id, access, refresh, err, tt.access, tt.refresh)
+		}
+	}
+
+	if _, _, err := cs.TokenTTL(ctx, "missing"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v, want ErrClientNotFound", err)
+	}
+}
+
+func TestTokenTTLResolver(t *testing.T) {
+	cs := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := cs.Set(&Client{Client: models.Client{ID: "partner", Secret: "secret", Domain: "https://example.com"}, AccessTokenTTL: 10 * time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Set(&models.Client{ID: "legacy", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewTokenTTLResolver(cs, 2*time.Hour, 72*time.Hour)
+
+	tests := []struct {
+		id              string
+		access, refresh time.Duration
+	}{
+		// the refresh token falls back to the default on its own
+		{"partner", 10 * time.Minute, 72 * time.Hour},
+		{"legacy", 2 * time.Hour, 72 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if access, err := r.AccessTokenTTL(ctx, tt.id); err != nil || access != tt.access {
+			t.Fatalf("%s: access token lifetime %v(%v), want %v", tt.id, access, err, tt.access)
+		}
+
+		if refresh, err := r.RefreshTokenTTL(ctx, tt.id); err != nil || refresh != tt.refresh {
+			t.Fatalf("%s: refresh token lifetime %v(%v), want %v", tt.id, refresh, err, tt.refresh)
+		}
+	}
+
+	if _, err := r.AccessTokenTTL(ctx, "missing"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("missing client: %v, want ErrClientNotFound", err)
+	}
+
+	handler := r.AccessTokenExpHandler(func(req *http.Request) (string, string, error) {
+		return req.FormValue("client_id"), "", nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/token?client_id=partner", nil)
+
+	if access, err := handler(httptest.NewRecorder(), req); err != nil || access != 10*time.Minute {
+		t.Fatalf("handler lifetime %v(%v), want %v", access, err, 10*time.Minute)
+	}
+}
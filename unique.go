@@ -0,0 +1,65 @@
+package mongo
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// error codes of the index creation conflicting with an existing index
+const (
+	errCodeIndexOptionsConflict  = 85
+	errCodeIndexKeySpecsConflict = 86
+)
+
+// uniqueIndex unique index on the non-empty values of the field, the clients without value don't collide
+func uniqueIndex(field string) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys: bson.D{{Key: field, Value: 1}},
+		Options: options.Index().
+			SetName(field + "_1_unique").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{field: bson.M{"$gt": ""}}),
+	}
+}
+
+// indexError explain the failure to create an index over existing data or next to a conflicting index
+func indexError(model mongo.IndexModel, err error) error {
+	name := *model.Options.Name
+	field := model.Keys.(bson.D)[0].Key
+
+	if mongo.IsDuplicateKeyError(err) {
+		return &DuplicateKeyError{
+			Field: field,
+			Index: name,
+			Err:   fmt.Errorf("several clients share a %s, resolve them before enabling the constraint: %w", field, err),
+		}
+	}
+
+	if cerr, ok := err.(mongo.CommandError); ok && (cerr.Code == errCodeIndexOptionsConflict || cerr.Code == errCodeIndexKeySpecsConflict) {
+		return fmt.Errorf("%w: index %s conflicts with an existing index on %s, drop it to switch: %v", ErrInvalidConfig, name, field, err)
+	}
+
+	return err
+}
+
+// duplicateKey report which constraint a duplicate key error of a client write violated
+func (cs *ClientStore) duplicateKey(err error) error {
+	if err == nil || !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	dup := &DuplicateKeyError{Field: "_id", Index: "_id_", Err: err}
+
+	for _, model := range cs.indexes() {
+		if name := *model.Options.Name; strings.Contains(err.Error(), "index: "+name+" ") {
+			dup.Field = model.Keys.(bson.D)[0].Key
+			dup.Index = name
+		}
+	}
+
+	return dup
+}
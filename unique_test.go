@@ -0,0 +1,102 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// duplicateKeyException a duplicate key error of the server on the index
+func duplicateKeyException(index string) error {
+	return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+		Code:    11000,
+		Message: "E11000 duplicate key error collection: oauth2.oauth2_clients index: " + index + " dup key: { : \"x\" }",
+	}}}
+}
+
+func TestDuplicateKeyConstraint(t *testing.T) {
+	cs := NewClientStoreWithDB(unreachableDatabase(t), WithUniqueClientIndexes(true, true))
+
+	for index, field := range map[string]string{"_id_": "_id", "domain_1_unique": "domain", "userid_1_unique": "userid"} {
+		var dup *DuplicateKeyError
+
+		if err := cs.duplicateKey(duplicateKeyException(index)); !errors.As(err, &dup) || dup.Field != field || dup.Index != index {
+			t.Errorf("%s: %v", index, err)
+		}
+	}
+
+	if err := cs.duplicateKey(duplicateKeyException("_id_")); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("%v isn't ErrDuplicateKey", err)
+	}
+
+	if other := errors.New("other"); cs.duplicateKey(other) != other {
+		t.Fatal("other error replaced")
+	}
+}
+
+func TestUniqueClientIndexes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("unique", func(t *testing.T) {
+		cs := newTestClientStore(t, WithUniqueClientIndexes(true, true))
+
+		if err := cs.EnsureIndexes(ctx); err != nil {
+			skipNotImplemented(t, err)
+			t.Fatal(err)
+		}
+
+		if names := indexNames(t, cs); !hasIndex(names, "domain_1_unique") || !hasIndex(names, "userid_1_unique") {
+			t.Fatalf("indexes %v", names)
+		}
+
+		if err := cs.Set(&models.Client{ID: "a", Secret: "secret", Domain: "https://example.com", UserID: "alice"}); err != nil {
+			t.Fatal(err)
+		}
+
+		for name, c := range map[string]*models.Client{
+			"domain":  {ID: "b", Secret: "secret", Domain: "https://example.com", UserID: "bob"},
+			"user id": {ID: "c", Secret: "secret", Domain: "https://example.org", UserID: "alice"},
+		} {
+			if err := cs.Set(c); !errors.Is(err, ErrDuplicateKey) {
+				t.Errorf("duplicate %s: %v", name, err)
+			}
+		}
+
+		// the clients without value don't collide
+		for _, id := range []string{"d", "e"} {
+			if err := cs.Set(&models.Client{ID: id, Secret: "secret", Domain: "https://" + id + ".example.com"}); err != nil {
+				t.Fatalf("client %s without user id: %v", id, err)
+			}
+		}
+	})
+
+	t.Run("not unique", func(t *testing.T) {
+		cs := newTestClientStore(t)
+
+		if names := indexNames(t, cs); !hasIndex(names, "domain_1") || !hasIndex(names, "userid_1__id_1") || hasIndex(names, "domain_1_unique") {
+			t.Fatalf("indexes %v", names)
+		}
+
+		for _, id := range []string{"a", "b"} {
+			if err := cs.Set(&models.Client{ID: id, Secret: "secret", Domain: "https://example.com", UserID: "alice"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// enabling the constraint over the duplicates explains what to resolve
+		unique := NewClientStoreWithDB(cs.Database(), WithUniqueClientIndexes(true, false))
+
+		var dup *DuplicateKeyError
+
+		err := unique.EnsureIndexes(ctx)
+		skipNotImplemented(t, err)
+
+		if !errors.As(err, &dup) || dup.Field != "domain" || !strings.Contains(err.Error(), "resolve them") {
+			t.Fatalf("unique index over duplicates: %v", err)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package mongo
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// requireArg reject an empty token, code or ID argument
+func requireArg(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("%w: empty %s", ErrInvalidArgument, name)
+	}
+
+	return nil
+}
+
+// validateTokenInfo reject token information which can't be stored: without code and access token,
+// or with a negative expiration
+func validateTokenInfo(info oauth2.TokenInfo) error {
+	if info == nil {
+		return fmt.Errorf("%w: nil token information", ErrInvalidArgument)
+	}
+
+	if info.GetCode() == "" && info.GetAccess() == "" {
+		return fmt.Errorf("%w: token information without code or access token", ErrInvalidArgument)
+	}
+
+	switch {
+	case info.GetCodeExpiresIn() < 0:
+		return fmt.Errorf("%w: negative code expiration", ErrInvalidArgument)
+	case info.GetAccessExpiresIn() < 0:
+		return fmt.Errorf("%w: negative access token expiration", ErrInvalidArgument)
+	case info.GetRefreshExpiresIn() < 0:
+		return fmt.Errorf("%w: negative refresh token expiration", ErrInvalidArgument)
+	}
+
+	return nil
+}
+
+// loopbackHost report whether the host of a redirect URI is the local machine, allowed over http
+func loopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkRedirectURI the reason the domain or redirect URI is rejected, empty when valid. Private schemes of native
+// apps(e.g. com.example.app:/callback) are allowed, http only for loopback hosts when strict.
+func checkRedirectURI(uri string, strict bool) string {
+	u, err := url.Parse(uri)
+
+	if err != nil || !u.IsAbs() {
+		return "must be an absolute URL"
+	}
+
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+		return "must have a host"
+	}
+
+	if strict && u.Scheme == "http" && !loopbackHost(u.Hostname()) {
+		return "must use https"
+	}
+
+	return ""
+}
+
+// validateClient check the client information against the validation rules, a confidential client must have
+// a secret when requireSecret is set
+func (cs *ClientStore) validateClient(info oauth2.ClientInfo, requireSecret bool) error {
+	if info == nil {
+		return fmt.Errorf("%w: nil client information", ErrInvalidArgument)
+	}
+
+	var fields []ClientFieldError
+
+	fail := func(field, reason string) {
+		fields = append(fields, ClientFieldError{Field: field, Reason: reason})
+	}
+
+	id := info.GetID()
+
+	switch {
+	case id == "":
+		fail("id", "is empty")
+	case cs.ccfg.ClientIDPattern != nil && !cs.ccfg.ClientIDPattern.MatchString(id):
+		fail("id", "doesn't match "+cs.ccfg.ClientIDPattern.String())
+	}
+
+	strict := cs.ccfg.RequireHTTPSURIs
+
+	if domain := info.GetDomain(); domain != "" {
+		if reason := checkRedirectURI(domain, strict); reason != "" {
+			fail("domain", reason)
+		}
+	}
+
+	if rc, ok := info.(redirectClient); ok {
+		for _, uri := range rc.GetRedirectURIs() {
+			if reason := checkRedirectURI(uri, strict); reason != "" {
+				fail("redirect_uris", fmt.Sprintf("%q %s", uri, reason))
+			}
+		}
+	}
+
+	if oc, ok := info.(originClient); ok {
+		for _, origin := range oc.GetAllowedOrigins() {
+			if _, err := normalizeOrigin(origin); err != nil {
+				fail("allowed_origins", fmt.Sprintf("%q %v", origin, err))
+			}
+		}
+	}
+
+	if rc, ok := info.(rateLimitedClient); ok {
+		if rl := rc.GetRateLimit(); rl != nil {
+			if reason := rl.check(); reason != "" {
+				fail("rate_limit", reason)
+			}
+		}
+	}
+
+	if kc, ok := info.(keysClient); ok {
+		fields = append(fields, cs.checkKeys(kc.GetJWKS(), kc.GetJWKSURI())...)
+	}
+
+	method := authMethod(info)
+
+	switch {
+	case !knownAuthMethod(method):
+		fail("token_endpoint_auth_method", fmt.Sprintf("%q isn't supported", method))
+	case isPublic(info) && method != AuthMethodNone:
+		fail("token_endpoint_auth_method", fmt.Sprintf("%q for a public client", method))
+	}
+
+	secret := info.GetSecret()
+
+	switch {
+	case secret == "" && requireSecret && usesSecret(method):
+		fail("secret", "is empty for a confidential client")
+	case secret != "" && !isSecretHash(secret) && len(secret) < cs.ccfg.MinSecretLength:
+		fail("secret", fmt.Sprintf("is shorter than %d characters", cs.ccfg.MinSecretLength))
+	}
+
+	if len(fields) > 0 {
+		return &ClientValidationError{Fields: fields}
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// requireNoCommands fail when the operations sent a command
+func requireNoCommands(t *testing.T, rec *commandRecorder, name string) {
+	t.Helper()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if len(rec.commands) != 0 {
+		t.Errorf("%s sent %s before failing", name, rec.commands[0].CommandName)
+	}
+}
+
+func TestTokenStoreInvalidArguments(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+
+	ts := testTokenStore(cfg)
+	defer ts.Close()
+
+	ctx := context.Background()
+
+	negative := func(set func(*models.Token)) *models.Token {
+		info := testToken("access", "refresh")
+		set(info)
+		return info
+	}
+
+	for _, tc := range []struct {
+		name string
+		run  func() error
+	}{
+		{"create nil", func() error { return ts.Create(ctx, nil) }},
+		{"create without code or access", func() error { return ts.Create(ctx, &models.Token{ClientID: "c"}) }},
+		{"create negative code expiry", func() error {
+			return ts.Create(ctx, negative(func(m *models.Token) { m.Code, m.CodeExpiresIn = "code", -time.Second }))
+		}},
+		{"create negative access expiry", func() error {
+			return ts.Create(ctx, negative(func(m *models.Token) { m.AccessExpiresIn = -time.Second }))
+		}},
+		{"create negative refresh expiry", func() error {
+			return ts.Create(ctx, negative(func(m *models.Token) { m.RefreshExpiresIn = -time.Second }))
+		}},
+		{"get empty code", func() error { _, err := ts.GetByCode(ctx, ""); return err }},
+		{"get empty access", func() error { _, err := ts.GetByAccess(ctx, ""); return err }},
+		{"get empty refresh", func() error { _, err := ts.GetByRefresh(ctx, ""); return err }},
+		{"remove empty code", func() error { return ts.RemoveByCode(ctx, "") }},
+		{"remove empty access", func() error { return ts.RemoveByAccess(ctx, "") }},
+		{"remove empty refresh", func() error { return ts.RemoveByRefresh(ctx, "") }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec.reset()
+
+			if err := tc.run(); !errors.Is(err, ErrInvalidArgument) {
+				t.Fatalf("%v, want ErrInvalidArgument", err)
+			}
+
+			requireNoCommands(t, &rec, tc.name)
+		})
+	}
+}
+
+func TestClientStoreInvalidArguments(t *testing.T) {
+	var rec commandRecorder
+
+	cfg := testConfig(t)
+	cfg.CommandMonitor = rec.monitor()
+
+	cs := NewClientStore(cfg)
+	defer cs.Close()
+
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name string
+		run  func() error
+	}{
+		{"set nil", func() error { return cs.Set(nil) }},
+		{"set empty ID", func() error { return cs.Set(&models.Client{Secret: "s"}) }},
+		{"set relative domain", func() error { return cs.Set(&models.Client{ID: "c", Secret: "s", Domain: "example.com/cb"}) }},
+		{"set invalid redirect URI", func() error {
+			return cs.Set(&Client{Client: models.Client{ID: "c", Secret: "s"}, RedirectURIs: []string{"/cb"}})
+		}},
+		{"set unknown auth method", func() error {
+			return cs.Set(&Client{Client: models.Client{ID: "c", Secret: "s"}, TokenEndpointAuthMethod: "unknown"})
+		}},
+		{"set public client with a secret method", func() error {
+			return cs.Set(&Client{Client: models.Client{ID: "c"}, Public: true, TokenEndpointAuthMethod: AuthMethodClientSecretBasic})
+		}},
+		{"set metadata operator", func() error {
+			return cs.SetWithMetadata(ctx, &models.Client{ID: "c", Secret: "s"}, map[string]interface{}{"$set": 1})
+		}},
+		{"update nil", func() error { return cs.Update(ctx, nil) }},
+		{"update empty ID", func() error { return cs.Update(ctx, &models.Client{Domain: "https://example.com"}) }},
+		{"get empty ID", func() error { _, err := cs.GetByID(ctx, ""); return err }},
+		{"remove empty ID", func() error { return cs.RemoveByID("") }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec.reset()
+
+			if err := tc.run(); !errors.Is(err, ErrInvalidArgument) {
+				t.Fatalf("%v, want ErrInvalidArgument", err)
+			}
+
+			requireNoCommands(t, &rec, tc.name)
+		})
+	}
+}
+
+// the field errors of an invalid client
+func TestClientValidationErrorFields(t *testing.T) {
+	cs := newTestClientStore(t)
+
+	err := cs.Set(&Client{Client: models.Client{Domain: "example.com"}, TokenEndpointAuthMethod: "unknown"})
+
+	var ve *ClientValidationError
+
+	if !errors.As(err, &ve) || !errors.Is(err, ErrInvalidClient) {
+		t.Fatalf("%v, want a ClientValidationError", err)
+	}
+
+	fields := make(map[string]bool)
+
+	for _, f := range ve.Fields {
+		fields[f.Field] = true
+	}
+
+	for _, field := range []string{"id", "domain", "token_endpoint_auth_method"} {
+		if !fields[field] {
+			t.Errorf("no %s error in %v", field, ve.Fields)
+		}
+	}
+}
+
+// each validation rule of Set, Update and CreateClient
+func TestClientValidationRules(t *testing.T) {
+	pattern := regexp.MustCompile(`^[a-z0-9-]+$`)
+
+	client := func(id, secret, domain string, redirectURIs ...string) oauth2.ClientInfo {
+		return &Client{Client: models.Client{ID: id, Secret: secret, Domain: domain}, RedirectURIs: redirectURIs}
+	}
+
+	ops := map[string]func(cs *ClientStore, info oauth2.ClientInfo) error{
+		"set":    func(cs *ClientStore, info oauth2.ClientInfo) error { return cs.Set(info) },
+		"update": func(cs *ClientStore, info oauth2.ClientInfo) error { return cs.Update(context.Background(), info) },
+		"create": func(cs *ClientStore, info oauth2.ClientInfo) error {
+			_, err := cs.CreateClient(context.Background(), info)
+			return err
+		},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		strict bool
+		info   oauth2.ClientInfo
+		// field of the error, empty when valid
+		field string
+		// the operations checking the rule, all when empty
+		only []string
+	}{
+		{"ID", false, client("client-1", "secret-123", "https://example.com"), "", nil},
+		{"empty ID", false, client("", "secret-123", "https://example.com"), "id", []string{"set", "update"}},
+		{"ID outside the pattern", false, client("Client_1", "secret-123", "https://example.com"), "id", nil},
+		{"relative domain", false, client("c", "secret-123", "example.com/cb"), "domain", nil},
+		{"domain without host", false, client("c", "secret-123", "https:///cb"), "domain", nil},
+		{"http domain", false, client("c", "secret-123", "http://example.com"), "", nil},
+		{"http domain when strict", true, client("c", "secret-123", "http://example.com"), "domain", nil},
+		{"localhost when strict", true, client("c", "secret-123", "http://localhost:8080"), "", nil},
+		{"loopback IP when strict", true, client("c", "secret-123", "http://127.0.0.1:8080/cb"), "", nil},
+		{"native app scheme when strict", true, client("c", "secret-123", "com.example.app:/cb"), "", nil},
+		{"relative redirect URI", false, client("c", "secret-123", "", "/cb"), "redirect_uris", nil},
+		{"http redirect URI when strict", true, client("c", "secret-123", "", "http://example.com/cb"), "redirect_uris", nil},
+		{"empty secret", false, client("c", "", "https://example.com"), "secret", []string{"set"}},
+		{"short secret", false, client("c", "secret", "https://example.com"), "secret", nil},
+		{"public client without secret", false, &Client{Client: models.Client{ID: "c", Domain: "https://example.com"}, Public: true}, "", nil},
+	} {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			ccfg := NewDefaultClientConfig()
+			ccfg.ClientIDPattern = pattern
+			ccfg.RequireHTTPSURIs = tc.strict
+			ccfg.MinSecretLength = 8
+			cs := newTestClientStore(t, ccfg)
+
+			names := tc.only
+
+			if len(names) == 0 {
+				names = []string{"set", "update", "create"}
+			}
+
+			for _, name := range names {
+				err := ops[name](cs, tc.info)
+
+				if tc.field == "" {
+					if errors.Is(err, ErrInvalidClient) {
+						t.Fatalf("%s: %v", name, err)
+					}
+
+					continue
+				}
+
+				var ve *ClientValidationError
+
+				if !errors.As(err, &ve) || !errors.Is(err, ErrInvalidClient) || !errors.Is(err, ErrInvalidArgument) {
+					t.Fatalf("%s: %v, want a ClientValidationError", name, err)
+				}
+
+				if len(ve.Fields) != 1 || ve.Fields[0].Field != tc.field {
+					t.Fatalf("%s: field errors %v, want %s", name, ve.Fields, tc.field)
+				}
+			}
+		})
+	}
+}